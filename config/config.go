@@ -0,0 +1,763 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// devJWTSecret is only accepted outside production so local setups work without a .env file
+const devJWTSecret = "your-secret-key-change-in-production"
+
+// minJWTSecretLength is the shortest JWT_SECRET accepted in production (256 bits for HS256)
+const minJWTSecretLength = 32
+
+// Config holds all settings read from the environment at startup
+type Config struct {
+	Environment string // APP_ENV: development or production
+	Port        string
+	BaseURL     string
+
+	DatabaseURL string
+	DBHost      string
+	DBPort      int
+	DBUser      string
+	DBPassword  string
+	DBName      string
+	DBSSLMode   string
+
+	JWTSecret      string
+	AllowedOrigins []string
+	// Signing keys as a JSON array (JWT_KEYS) or a file holding one (JWT_KEYS_FILE, re-read
+	// periodically); when set they replace the keys rotated through the admin API
+	JWTKeys     string
+	JWTKeysFile string
+
+	// Session token lifetime for the supervisor/admin dashboard and the crew mobile app
+	DashboardTokenTTL time.Duration
+	MobileTokenTTL    time.Duration
+	// Oldest token claims version accepted; 2 rejects tokens issued before audiences
+	MinTokenVersion int
+	// Let the dashboard keep its session in an HttpOnly cookie, with CSRF tokens required on
+	// writes; the cookie is scoped to SessionCookieDomain when set
+	SessionCookies      bool
+	SessionCookieDomain string
+	// Date API v1 stops being served; once set, v1 responses carry Deprecation and Sunset headers
+	APIV1Sunset time.Time
+
+	LocationIQAPIKey string
+
+	// Uploaded files are only served through short-lived signed URLs
+	UploadURLSecret string
+	SignedURLTTL    time.Duration
+
+	// Share of users whose client telemetry is stored (0-100)
+	TelemetrySamplePercent int
+
+	// Directory of the default videos served under /assets/ and how long clients cache them
+	AssetsDir        string
+	AssetCacheMaxAge time.Duration
+
+	// Firebase service account key used for push delivery (optional)
+	FCMCredentialsFile string
+
+	// Refuse to start when a startup diagnostic check fails
+	StrictStartup bool
+
+	// Where uploaded files are stored; a shared volume when running several instances
+	UploadsDir string
+	// "memory" (single instance) or "postgres" (rate limits, lockouts and cache
+	// invalidation shared between instances)
+	SharedStateBackend string
+
+	// IANA timezone for users with no timezone of their own and no site shift timezone
+	DefaultTimezone string
+
+	// OpenID Connect single sign-on (Azure AD, Google Workspace); off unless the issuer is set
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	// Email domains whose unknown users are created as supervisors on first sign-in
+	OIDCAutoProvisionDomains []string
+	// Web page the callback sends the session token to (as a URL fragment); empty returns JSON
+	OIDCPostLoginRedirect string
+
+	// Outgoing email (STARTTLS when the server offers it); without a host emails are only logged
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Page miners open from their invitation email; the token is appended as ?token=
+	InviteAcceptURL string
+	// How long an invitation link stays valid
+	InviteTTL time.Duration
+	// Page supervisors open from their sign-up verification email; the token is appended as ?token=
+	EmailVerifyURL string
+	// How long an email verification link stays valid
+	EmailVerifyTTL time.Duration
+
+	// Password policy for passwords users choose: minimum length and how many of lowercase,
+	// uppercase, digits and symbols must appear
+	PasswordMinLength  int
+	PasswordMinClasses int
+	// Algorithm and cost new password hashes are made with; stored hashes made otherwise are
+	// replaced when their user next logs in
+	PasswordHashAlgorithm string
+	BcryptCost            int
+	Argon2MemoryKiB       int
+	Argon2Iterations      int
+	Argon2Parallelism     int
+
+	// Registration code for the first admin account; ignored once any admin exists. Later
+	// admins register with codes minted by an existing admin.
+	AdminBootstrapCode string
+
+	// CAPTCHA checked on public sign-up endpoints: none, hcaptcha or turnstile. The verify
+	// URL is only needed to point at something other than the provider's siteverify endpoint.
+	CaptchaProvider  string
+	CaptchaSecret    string
+	CaptchaVerifyURL string
+
+	// OpenAI-compatible chat completions endpoint that drafts quiz questions from module
+	// transcripts for supervisors to review; off unless the URL is set
+	QuizGenerationURL    string
+	QuizGenerationAPIKey string
+	QuizGenerationModel  string
+
+	// clamd address (host:port) uploads are streamed to when an organization's upload policy
+	// turns virus scanning on; scanning can't be turned on without it
+	UploadScanAddr string
+
+	// SMS for HIGH and CRITICAL emergencies: none, twilio or sns. SNS uses the standard AWS
+	// credential variables.
+	SMSProvider        string
+	TwilioAccountSID   string
+	TwilioAuthToken    string
+	TwilioFrom         string
+	AWSRegion          string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	SNSSenderID        string
+
+	// Where database backups are written; keep it off the uploads volume, which is served
+	BackupDir string
+	// How many successful backups to keep; older ones are deleted after each new backup
+	BackupRetention int
+}
+
+var current *Config
+
+// Get returns the configuration loaded at startup. Before Load is called it returns
+// development defaults so packages can still be used in isolation.
+func Get() *Config {
+	if current == nil {
+		return defaults()
+	}
+	return current
+}
+
+func defaults() *Config {
+	return &Config{
+		Environment:    "development",
+		Port:           "8080",
+		DBPort:         5432,
+		DBSSLMode:      "disable",
+		JWTSecret:      devJWTSecret,
+		AllowedOrigins: []string{"*"},
+		SignedURLTTL:   time.Hour,
+
+		DashboardTokenTTL: 7 * 24 * time.Hour,
+		MobileTokenTTL:    7 * 24 * time.Hour,
+		MinTokenVersion:   1,
+
+		TelemetrySamplePercent: 100,
+
+		AssetsDir:        "database/assets",
+		AssetCacheMaxAge: 24 * time.Hour,
+
+		UploadsDir:         "uploads",
+		SharedStateBackend: "memory",
+
+		BackupDir:       "backups",
+		BackupRetention: 7,
+
+		DefaultTimezone: "UTC",
+
+		SMTPPort:       587,
+		InviteTTL:      72 * time.Hour,
+		EmailVerifyTTL: 48 * time.Hour,
+
+		PasswordMinLength:  8,
+		PasswordMinClasses: 1,
+
+		PasswordHashAlgorithm: "argon2id",
+		BcryptCost:            10,
+		Argon2MemoryKiB:       19456,
+		Argon2Iterations:      2,
+		Argon2Parallelism:     1,
+	}
+}
+
+// Load reads the .env file (if present) and the environment, validates the result and
+// makes it available through Get. All problems are reported together.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+
+	cfg := defaults()
+	var problems []string
+
+	cfg.Environment = strings.ToLower(getEnv("APP_ENV", cfg.Environment))
+	if cfg.Environment != "development" && cfg.Environment != "production" {
+		problems = append(problems, "APP_ENV must be development or production")
+	}
+
+	cfg.Port = getEnv("PORT", cfg.Port)
+	if p, err := strconv.Atoi(cfg.Port); err != nil || p < 1 || p > 65535 {
+		problems = append(problems, "PORT must be a number between 1 and 65535")
+	}
+
+	cfg.BaseURL = strings.TrimRight(os.Getenv("BASE_URL"), "/")
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "BASE_URL must be an absolute http(s) URL, e.g. https://api.example.com")
+		}
+	} else if cfg.IsProduction() {
+		problems = append(problems, "BASE_URL is required in production")
+	}
+
+	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
+	if cfg.DatabaseURL == "" {
+		cfg.DBHost = os.Getenv("DB_HOST")
+		cfg.DBUser = os.Getenv("DB_USER")
+		cfg.DBPassword = os.Getenv("DB_PASSWORD")
+		cfg.DBName = os.Getenv("DB_NAME")
+		cfg.DBSSLMode = getEnv("DB_SSLMODE", cfg.DBSSLMode)
+
+		port, err := strconv.Atoi(getEnv("DB_PORT", strconv.Itoa(cfg.DBPort)))
+		if err != nil {
+			problems = append(problems, "DB_PORT must be a number")
+		}
+		cfg.DBPort = port
+
+		for name, value := range map[string]string{"DB_HOST": cfg.DBHost, "DB_USER": cfg.DBUser, "DB_NAME": cfg.DBName} {
+			if value == "" {
+				problems = append(problems, name+" is required when DATABASE_URL is not set")
+			}
+		}
+	}
+
+	cfg.JWTSecret = os.Getenv("JWT_SECRET")
+	switch {
+	case cfg.JWTSecret == "" && cfg.IsProduction():
+		problems = append(problems, "JWT_SECRET is required in production")
+	case cfg.JWTSecret == "":
+		log.Println("Warning: JWT_SECRET not set, using insecure development secret")
+		cfg.JWTSecret = devJWTSecret
+	case cfg.IsProduction() && len(cfg.JWTSecret) < minJWTSecretLength:
+		problems = append(problems, fmt.Sprintf("JWT_SECRET must be at least %d characters in production", minJWTSecretLength))
+	case cfg.IsProduction() && strings.Contains(cfg.JWTSecret, "change"):
+		problems = append(problems, "JWT_SECRET still contains the example placeholder value")
+	}
+
+	cfg.JWTKeys = os.Getenv("JWT_KEYS")
+	cfg.JWTKeysFile = os.Getenv("JWT_KEYS_FILE")
+	if cfg.JWTKeys != "" && cfg.JWTKeysFile != "" {
+		problems = append(problems, "set only one of JWT_KEYS and JWT_KEYS_FILE")
+	} else if _, err := cfg.ConfiguredJWTKeys(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for name, ttl := range map[string]*time.Duration{"DASHBOARD_TOKEN_TTL_HOURS": &cfg.DashboardTokenTTL, "MOBILE_TOKEN_TTL_HOURS": &cfg.MobileTokenTTL} {
+		if v := os.Getenv(name); v != "" {
+			hours, err := strconv.Atoi(v)
+			if err != nil || hours < 1 {
+				problems = append(problems, name+" must be a positive number")
+			}
+			*ttl = time.Duration(hours) * time.Hour
+		}
+	}
+	if v := os.Getenv("MIN_TOKEN_VERSION"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil || version < 1 || version > 2 {
+			problems = append(problems, "MIN_TOKEN_VERSION must be 1 or 2")
+		}
+		cfg.MinTokenVersion = version
+	}
+
+	if v := os.Getenv("SESSION_COOKIES"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			problems = append(problems, "SESSION_COOKIES must be true or false")
+		}
+		cfg.SessionCookies = enabled
+	}
+	cfg.SessionCookieDomain = os.Getenv("SESSION_COOKIE_DOMAIN")
+
+	if v := os.Getenv("API_V1_SUNSET"); v != "" {
+		sunset, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			problems = append(problems, "API_V1_SUNSET must be a date (YYYY-MM-DD)")
+		}
+		cfg.APIV1Sunset = sunset
+	}
+
+	if origins := parseCommaSeparated(os.Getenv("ALLOWED_ORIGINS")); len(origins) > 0 {
+		cfg.AllowedOrigins = origins
+	} else if cfg.IsProduction() {
+		log.Println("Warning: ALLOWED_ORIGINS not set, allowing all origins")
+	}
+
+	cfg.LocationIQAPIKey = os.Getenv("LOCATIONIQ_API_KEY")
+
+	// Falls back to JWT_SECRET so existing deployments keep working without a new variable
+	cfg.UploadURLSecret = getEnv("UPLOAD_URL_SECRET", cfg.JWTSecret)
+	if v := os.Getenv("SIGNED_URL_TTL_MINUTES"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 1 {
+			problems = append(problems, "SIGNED_URL_TTL_MINUTES must be a positive number")
+		}
+		cfg.SignedURLTTL = time.Duration(minutes) * time.Minute
+	}
+
+	if v := os.Getenv("TELEMETRY_SAMPLE_PERCENT"); v != "" {
+		percent, err := strconv.Atoi(v)
+		if err != nil || percent < 0 || percent > 100 {
+			problems = append(problems, "TELEMETRY_SAMPLE_PERCENT must be a number between 0 and 100")
+		}
+		cfg.TelemetrySamplePercent = percent
+	}
+
+	cfg.AssetsDir = getEnv("ASSETS_DIR", cfg.AssetsDir)
+	if v := os.Getenv("ASSET_CACHE_MAX_AGE_HOURS"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours < 0 {
+			problems = append(problems, "ASSET_CACHE_MAX_AGE_HOURS must be a non-negative number")
+		}
+		cfg.AssetCacheMaxAge = time.Duration(hours) * time.Hour
+	}
+
+	cfg.FCMCredentialsFile = os.Getenv("FCM_CREDENTIALS_FILE")
+
+	if v := os.Getenv("STRICT_STARTUP"); v != "" {
+		strict, err := strconv.ParseBool(v)
+		if err != nil {
+			problems = append(problems, "STRICT_STARTUP must be true or false")
+		}
+		cfg.StrictStartup = strict
+	}
+
+	cfg.UploadsDir = getEnv("UPLOADS_DIR", cfg.UploadsDir)
+	cfg.SharedStateBackend = strings.ToLower(getEnv("SHARED_STATE_BACKEND", cfg.SharedStateBackend))
+	if cfg.SharedStateBackend != "memory" && cfg.SharedStateBackend != "postgres" {
+		problems = append(problems, "SHARED_STATE_BACKEND must be memory or postgres")
+	}
+
+	cfg.DefaultTimezone = getEnv("DEFAULT_TIMEZONE", cfg.DefaultTimezone)
+	if _, err := time.LoadLocation(cfg.DefaultTimezone); err != nil {
+		problems = append(problems, "DEFAULT_TIMEZONE must be an IANA timezone such as Asia/Kolkata")
+	}
+
+	cfg.OIDCIssuerURL = strings.TrimRight(os.Getenv("OIDC_ISSUER_URL"), "/")
+	if cfg.OIDCIssuerURL != "" {
+		cfg.OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+		cfg.OIDCClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+		if cfg.OIDCClientID == "" || cfg.OIDCClientSecret == "" {
+			problems = append(problems, "OIDC_CLIENT_ID and OIDC_CLIENT_SECRET are required when OIDC_ISSUER_URL is set")
+		}
+		cfg.OIDCRedirectURL = os.Getenv("OIDC_REDIRECT_URL")
+		if cfg.OIDCRedirectURL == "" && cfg.BaseURL != "" {
+			cfg.OIDCRedirectURL = cfg.BaseURL + "/api/auth/oidc/callback"
+		}
+		if cfg.OIDCRedirectURL == "" {
+			problems = append(problems, "OIDC_REDIRECT_URL or BASE_URL is required when OIDC_ISSUER_URL is set")
+		}
+		for _, domain := range parseCommaSeparated(os.Getenv("OIDC_AUTO_PROVISION_DOMAINS")) {
+			cfg.OIDCAutoProvisionDomains = append(cfg.OIDCAutoProvisionDomains, strings.ToLower(strings.TrimPrefix(domain, "@")))
+		}
+		cfg.OIDCPostLoginRedirect = os.Getenv("OIDC_POST_LOGIN_REDIRECT")
+	}
+
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			problems = append(problems, "SMTP_PORT must be a number between 1 and 65535")
+		}
+		cfg.SMTPPort = port
+	}
+	cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	cfg.SMTPFrom = getEnv("SMTP_FROM", cfg.SMTPUsername)
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		problems = append(problems, "SMTP_FROM is required when SMTP_HOST is set")
+	}
+	if cfg.SMTPHost == "" && cfg.IsProduction() {
+		log.Println("Warning: SMTP_HOST not set, invitation emails will not be sent")
+	}
+
+	cfg.InviteAcceptURL = os.Getenv("INVITE_ACCEPT_URL")
+	if cfg.InviteAcceptURL == "" && cfg.BaseURL != "" {
+		cfg.InviteAcceptURL = cfg.BaseURL + "/activate"
+	}
+	if v := os.Getenv("INVITE_TTL_HOURS"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours < 1 {
+			problems = append(problems, "INVITE_TTL_HOURS must be a positive number")
+		}
+		cfg.InviteTTL = time.Duration(hours) * time.Hour
+	}
+
+	cfg.EmailVerifyURL = os.Getenv("EMAIL_VERIFY_URL")
+	if cfg.EmailVerifyURL == "" && cfg.BaseURL != "" {
+		cfg.EmailVerifyURL = cfg.BaseURL + "/verify-email"
+	}
+	if v := os.Getenv("EMAIL_VERIFY_TTL_HOURS"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours < 1 {
+			problems = append(problems, "EMAIL_VERIFY_TTL_HOURS must be a positive number")
+		}
+		cfg.EmailVerifyTTL = time.Duration(hours) * time.Hour
+	}
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		length, err := strconv.Atoi(v)
+		if err != nil || length < 6 || length > 72 {
+			// bcrypt ignores everything after 72 bytes
+			problems = append(problems, "PASSWORD_MIN_LENGTH must be a number between 6 and 72")
+		}
+		cfg.PasswordMinLength = length
+	}
+	if v := os.Getenv("PASSWORD_MIN_CHARACTER_CLASSES"); v != "" {
+		classes, err := strconv.Atoi(v)
+		if err != nil || classes < 1 || classes > 4 {
+			problems = append(problems, "PASSWORD_MIN_CHARACTER_CLASSES must be a number between 1 and 4")
+		}
+		cfg.PasswordMinClasses = classes
+	}
+
+	cfg.PasswordHashAlgorithm = strings.ToLower(getEnv("PASSWORD_HASH_ALGORITHM", cfg.PasswordHashAlgorithm))
+	if cfg.PasswordHashAlgorithm != "argon2id" && cfg.PasswordHashAlgorithm != "bcrypt" {
+		problems = append(problems, "PASSWORD_HASH_ALGORITHM must be argon2id or bcrypt")
+	}
+	for _, setting := range []struct {
+		name     string
+		value    *int
+		min, max int
+	}{
+		{"BCRYPT_COST", &cfg.BcryptCost, 10, 31},
+		{"ARGON2_MEMORY_KIB", &cfg.Argon2MemoryKiB, 8192, 1 << 22},
+		{"ARGON2_ITERATIONS", &cfg.Argon2Iterations, 1, 100},
+		{"ARGON2_PARALLELISM", &cfg.Argon2Parallelism, 1, 255},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < setting.min || n > setting.max {
+				problems = append(problems, fmt.Sprintf("%s must be a number between %d and %d", setting.name, setting.min, setting.max))
+			}
+			*setting.value = n
+		}
+	}
+
+	cfg.AdminBootstrapCode = os.Getenv("ADMIN_BOOTSTRAP_CODE")
+	if cfg.AdminBootstrapCode != "" && len(cfg.AdminBootstrapCode) < 8 {
+		problems = append(problems, "ADMIN_BOOTSTRAP_CODE must be at least 8 characters")
+	}
+
+	cfg.CaptchaProvider = strings.ToLower(getEnv("CAPTCHA_PROVIDER", "none"))
+	switch cfg.CaptchaProvider {
+	case "none":
+		if cfg.IsProduction() {
+			log.Println("Warning: CAPTCHA_PROVIDER is none, public sign-up endpoints accept scripted requests")
+		}
+	case "hcaptcha", "turnstile":
+		cfg.CaptchaSecret = os.Getenv("CAPTCHA_SECRET")
+		if cfg.CaptchaSecret == "" {
+			problems = append(problems, "CAPTCHA_SECRET is required when CAPTCHA_PROVIDER is set")
+		}
+		cfg.CaptchaVerifyURL = os.Getenv("CAPTCHA_VERIFY_URL")
+	default:
+		problems = append(problems, "CAPTCHA_PROVIDER must be none, hcaptcha or turnstile")
+	}
+
+	cfg.QuizGenerationURL = os.Getenv("QUIZ_GENERATION_URL")
+	if cfg.QuizGenerationURL != "" {
+		if u, err := url.Parse(cfg.QuizGenerationURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "QUIZ_GENERATION_URL must be an http(s) URL")
+		}
+		cfg.QuizGenerationAPIKey = os.Getenv("QUIZ_GENERATION_API_KEY")
+		cfg.QuizGenerationModel = os.Getenv("QUIZ_GENERATION_MODEL")
+		if cfg.QuizGenerationModel == "" {
+			problems = append(problems, "QUIZ_GENERATION_MODEL is required when QUIZ_GENERATION_URL is set")
+		}
+	}
+
+	cfg.UploadScanAddr = os.Getenv("UPLOAD_SCAN_ADDR")
+	if cfg.UploadScanAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.UploadScanAddr); err != nil {
+			problems = append(problems, "UPLOAD_SCAN_ADDR must be host:port")
+		}
+	}
+
+	cfg.SMSProvider = strings.ToLower(getEnv("SMS_PROVIDER", "none"))
+	switch cfg.SMSProvider {
+	case "none":
+	case "twilio":
+		cfg.TwilioAccountSID = os.Getenv("TWILIO_ACCOUNT_SID")
+		cfg.TwilioAuthToken = os.Getenv("TWILIO_AUTH_TOKEN")
+		cfg.TwilioFrom = os.Getenv("TWILIO_FROM")
+		if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioFrom == "" {
+			problems = append(problems, "TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM are required when SMS_PROVIDER is twilio")
+		}
+	case "sns":
+		cfg.AWSRegion = os.Getenv("AWS_REGION")
+		cfg.AWSAccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.AWSSecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SNSSenderID = os.Getenv("SNS_SENDER_ID")
+		if cfg.AWSRegion == "" || cfg.AWSAccessKeyID == "" || cfg.AWSSecretAccessKey == "" {
+			problems = append(problems, "AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required when SMS_PROVIDER is sns")
+		}
+	default:
+		problems = append(problems, "SMS_PROVIDER must be none, twilio or sns")
+	}
+
+	cfg.BackupDir = getEnv("BACKUP_DIR", cfg.BackupDir)
+	if v := os.Getenv("BACKUP_RETENTION"); v != "" {
+		keep, err := strconv.Atoi(v)
+		if err != nil || keep < 1 {
+			problems = append(problems, "BACKUP_RETENTION must be a positive number")
+		}
+		cfg.BackupRetention = keep
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+
+	current = cfg
+	return cfg, nil
+}
+
+// IsProduction reports whether APP_ENV is production
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// DatabaseDSN returns DATABASE_URL or a DSN built from the individual DB_* settings. Sessions
+// are pinned to UTC so CURRENT_DATE and ::date casts don't depend on the server's setting.
+func (c *Config) DatabaseDSN() string {
+	if c.DatabaseURL != "" {
+		return withUTCSession(c.DatabaseURL)
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s timezone=UTC",
+		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
+}
+
+// ToolDSN is the connection string for pg_dump and pg_restore. It is DatabaseDSN without the
+// timezone setting, which libpq doesn't accept.
+func (c *Config) ToolDSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
+}
+
+// withUTCSession adds timezone=UTC to a URL or key=value DSN that doesn't set one
+func withUTCSession(dsn string) string {
+	if strings.Contains(dsn, "timezone=") {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if strings.Contains(dsn, "?") {
+			return dsn + "&timezone=UTC"
+		}
+		return dsn + "?timezone=UTC"
+	}
+	return dsn + " timezone=UTC"
+}
+
+// Redacted returns the configuration with secrets masked, safe to show to admins
+func (c *Config) Redacted() map[string]interface{} {
+	databaseURL := ""
+	if c.DatabaseURL != "" {
+		databaseURL = redactURL(c.DatabaseURL)
+	}
+
+	return map[string]interface{}{
+		"app_env":                  c.Environment,
+		"port":                     c.Port,
+		"base_url":                 c.BaseURL,
+		"database_url":             databaseURL,
+		"db_host":                  c.DBHost,
+		"db_port":                  c.DBPort,
+		"db_user":                  c.DBUser,
+		"db_password":              redactSecret(c.DBPassword),
+		"db_name":                  c.DBName,
+		"db_sslmode":               c.DBSSLMode,
+		"jwt_secret":               redactSecret(c.JWTSecret),
+		"jwt_keys":                 redactSecret(c.JWTKeys),
+		"jwt_keys_file":            c.JWTKeysFile,
+		"allowed_origins":          c.AllowedOrigins,
+		"dashboard_token_ttl":      c.DashboardTokenTTL.String(),
+		"mobile_token_ttl":         c.MobileTokenTTL.String(),
+		"min_token_version":        c.MinTokenVersion,
+		"session_cookies":          c.SessionCookies,
+		"session_cookie_domain":    c.SessionCookieDomain,
+		"api_v1_sunset":            c.APIV1Sunset,
+		"locationiq_api_key":       redactSecret(c.LocationIQAPIKey),
+		"upload_url_secret":        redactSecret(c.UploadURLSecret),
+		"signed_url_ttl":           c.SignedURLTTL.String(),
+		"telemetry_sample_percent": c.TelemetrySamplePercent,
+		"assets_dir":               c.AssetsDir,
+		"asset_cache_max_age":      c.AssetCacheMaxAge.String(),
+		"fcm_credentials_file":     c.FCMCredentialsFile,
+		"strict_startup":           c.StrictStartup,
+		"uploads_dir":              c.UploadsDir,
+		"shared_state_backend":     c.SharedStateBackend,
+		"default_timezone":         c.DefaultTimezone,
+		"oidc_issuer_url":          c.OIDCIssuerURL,
+		"oidc_client_id":           c.OIDCClientID,
+		"oidc_client_secret":       redactSecret(c.OIDCClientSecret),
+		"oidc_redirect_url":        c.OIDCRedirectURL,
+		"oidc_auto_provision":      c.OIDCAutoProvisionDomains,
+		"oidc_post_login_redirect": c.OIDCPostLoginRedirect,
+		"smtp_host":                c.SMTPHost,
+		"smtp_port":                c.SMTPPort,
+		"smtp_username":            c.SMTPUsername,
+		"smtp_password":            redactSecret(c.SMTPPassword),
+		"smtp_from":                c.SMTPFrom,
+		"invite_accept_url":        c.InviteAcceptURL,
+		"invite_ttl":               c.InviteTTL.String(),
+		"email_verify_url":         c.EmailVerifyURL,
+		"email_verify_ttl":         c.EmailVerifyTTL.String(),
+		"password_min_length":      c.PasswordMinLength,
+		"password_min_classes":     c.PasswordMinClasses,
+		"password_hash_algorithm":  c.PasswordHashAlgorithm,
+		"bcrypt_cost":              c.BcryptCost,
+		"argon2_memory_kib":        c.Argon2MemoryKiB,
+		"argon2_iterations":        c.Argon2Iterations,
+		"argon2_parallelism":       c.Argon2Parallelism,
+		"admin_bootstrap_code":     redactSecret(c.AdminBootstrapCode),
+		"captcha_provider":         c.CaptchaProvider,
+		"captcha_secret":           redactSecret(c.CaptchaSecret),
+		"captcha_verify_url":       c.CaptchaVerifyURL,
+		"quiz_generation_url":      c.QuizGenerationURL,
+		"quiz_generation_api_key":  redactSecret(c.QuizGenerationAPIKey),
+		"quiz_generation_model":    c.QuizGenerationModel,
+		"upload_scan_addr":         c.UploadScanAddr,
+		"sms_provider":             c.SMSProvider,
+		"twilio_account_sid":       c.TwilioAccountSID,
+		"twilio_auth_token":        redactSecret(c.TwilioAuthToken),
+		"twilio_from":              c.TwilioFrom,
+		"aws_region":               c.AWSRegion,
+		"aws_access_key_id":        c.AWSAccessKeyID,
+		"aws_secret_access_key":    redactSecret(c.AWSSecretAccessKey),
+		"sns_sender_id":            c.SNSSenderID,
+		"backup_dir":               c.BackupDir,
+		"backup_retention":         c.BackupRetention,
+	}
+}
+
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("<redacted, %d chars>", len(s))
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<redacted>"
+	}
+	if u.User != nil {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// JWTKey is a signing key from JWT_KEYS or JWT_KEYS_FILE
+type JWTKey struct {
+	ID     string `json:"kid"`
+	Secret string `json:"secret"`
+	// Stop accepting tokens signed with this key after this time; unset keeps accepting them
+	// until the key is removed. Not allowed on the first key.
+	RetireAfter *time.Time `json:"retire_after,omitempty"`
+}
+
+// ConfiguredJWTKeys returns the signing keys from JWT_KEYS or JWT_KEYS_FILE, nil when neither
+// is set. The file is read on every call so keys can be rotated by editing it. The first key
+// signs new tokens; the others only verify existing ones. A key with kid "legacy" and no
+// secret stands for JWT_SECRET, which verifies tokens issued without a kid.
+func (c *Config) ConfiguredJWTKeys() ([]JWTKey, error) {
+	raw := []byte(c.JWTKeys)
+	source := "JWT_KEYS"
+	if c.JWTKeysFile != "" {
+		data, err := os.ReadFile(c.JWTKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_KEYS_FILE could not be read: %v", err)
+		}
+		raw, source = data, "JWT_KEYS_FILE"
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var keys []JWTKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON array of {\"kid\", \"secret\", \"retire_after\"}: %v", source, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no keys", source)
+	}
+	if keys[0].RetireAfter != nil {
+		return nil, fmt.Errorf("%s: the first key signs new tokens and can't have retire_after", source)
+	}
+	seen := map[string]bool{}
+	for i, k := range keys {
+		switch {
+		case k.ID == "":
+			return nil, fmt.Errorf("%s: key %d has no kid", source, i+1)
+		case seen[k.ID]:
+			return nil, fmt.Errorf("%s: kid %s appears twice", source, k.ID)
+		case k.Secret == "" && k.ID != "legacy":
+			return nil, fmt.Errorf("%s: key %s has no secret", source, k.ID)
+		case k.Secret != "" && c.IsProduction() && len(k.Secret) < minJWTSecretLength:
+			return nil, fmt.Errorf("%s: key %s must be at least %d characters in production", source, k.ID, minJWTSecretLength)
+		}
+		seen[k.ID] = true
+	}
+	return keys, nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func parseCommaSeparated(s string) []string {
+	result := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"MineSafeBackend/config"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Backups are logical dumps taken with pg_dump in its custom format, so pg_restore can
+// restore them whole or table by table. Both tools come with the PostgreSQL client
+// package, which the Docker image installs.
+
+// DumpDatabase writes a backup of the whole database to path
+func DumpDatabase(ctx context.Context, path string) error {
+	return runPGTool(exec.CommandContext(ctx, "pg_dump",
+		"--format=custom", "--no-owner", "--no-privileges",
+		"--file="+path, "--dbname="+config.Get().ToolDSN()))
+}
+
+// RestoreDatabase replaces the database's contents with a backup taken by DumpDatabase, in
+// one transaction so a failed restore leaves the database as it was. Migrations bring the
+// restored schema up to date the next time the server starts.
+func RestoreDatabase(path string) error {
+	return runPGTool(exec.Command("pg_restore",
+		"--clean", "--if-exists", "--no-owner", "--no-privileges",
+		"--single-transaction", "--exit-on-error",
+		"--dbname="+config.Get().ToolDSN(), path))
+}
+
+func runPGTool(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if len(detail) > 1000 {
+			detail = detail[len(detail)-1000:]
+		}
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(cmd.Path), err, detail)
+	}
+	return nil
+}
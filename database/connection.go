@@ -1,15 +1,17 @@
 package database
 
 import (
+	"MineSafeBackend/config"
+	"crypto/sha256"
 	"database/sql"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
@@ -22,37 +24,8 @@ var embeddedVideosJSON []byte
 var DB *sql.DB
 
 func InitDB() error {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
-	}
-
-	var psqlInfo string
-
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL != "" {
-		psqlInfo = databaseURL
-	} else {
-		host := os.Getenv("DB_HOST")
-		portstr := os.Getenv("DB_PORT")
-		port, err := strconv.Atoi(portstr)
-		if err != nil {
-			return fmt.Errorf("invalid DB_PORT, must be a number: %w", err)
-		}
-		user := os.Getenv("DB_USER")
-		password := os.Getenv("DB_PASSWORD")
-		dbname := os.Getenv("DB_NAME")
-		sslmode := os.Getenv("DB_SSLMODE")
-
-		if sslmode == "" {
-			sslmode = "disable"
-		}
-
-		psqlInfo = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			host, port, user, password, dbname, sslmode)
-	}
-
 	var err error
-	DB, err = sql.Open("postgres", psqlInfo)
+	DB, err = sql.Open("postgres", config.Get().DatabaseDSN())
 	if err != nil {
 		return fmt.Errorf("error opening database: %w", err)
 	}
@@ -80,6 +53,43 @@ func CloseDB() error {
 	return nil
 }
 
+// videoTrendingView is video engagement over the last 7 days for the explore tab, refreshed
+// periodically. A quiz counts as passed at 80%, the same mark induction modules use.
+const videoTrendingView = `CREATE MATERIALIZED VIEW IF NOT EXISTS video_trending AS
+	SELECT vm.id AS video_id,
+		COALESCE(v.views, 0) AS views,
+		COALESCE(r.likes, 0) AS likes,
+		COALESCE(r.dislikes, 0) AS dislikes,
+		COALESCE(c.completions, 0) AS completions,
+		COALESCE(c.passes, 0) AS passes,
+		CASE WHEN COALESCE(v.views, 0) > 0 THEN LEAST(c.completions::float / v.views, 1) ELSE 0 END AS completion_rate,
+		CASE WHEN COALESCE(c.quiz_attempts, 0) > 0 THEN c.passes::float / c.quiz_attempts ELSE 0 END AS pass_rate,
+		COALESCE(v.views, 0) + 3 * COALESCE(r.likes, 0) - 2 * COALESCE(r.dislikes, 0) + 4 * COALESCE(c.completions, 0) AS trending_score,
+		NOW() AS refreshed_at
+	FROM video_modules vm
+	LEFT JOIN (
+		SELECT video_id, COUNT(*) AS views FROM video_views
+		WHERE viewed_at > NOW() - INTERVAL '7 days' GROUP BY video_id
+	) v ON v.video_id = vm.id
+	LEFT JOIN (
+		SELECT video_id,
+			COUNT(*) FILTER (WHERE reaction_type = 'like') AS likes,
+			COUNT(*) FILTER (WHERE reaction_type = 'dislike') AS dislikes
+		FROM video_reactions
+		WHERE created_at > NOW() - INTERVAL '7 days' GROUP BY video_id
+	) r ON r.video_id = vm.id
+	LEFT JOIN (
+		SELECT video_id, COUNT(*) AS completions,
+			COUNT(*) FILTER (WHERE total_questions > 0) AS quiz_attempts,
+			COUNT(*) FILTER (WHERE total_questions > 0 AND score * 100 >= total_questions * 80) AS passes
+		FROM module_completions
+		WHERE completed_at > NOW() - INTERVAL '7 days' GROUP BY video_id
+	) c ON c.video_id = vm.id
+	WHERE vm.is_active = true`
+
+// videoTrendingIndex lets the view be refreshed concurrently
+const videoTrendingIndex = `CREATE UNIQUE INDEX IF NOT EXISTS idx_video_trending_video ON video_trending(video_id)`
+
 func runMigrations() error {
 	migrations := []string{
 		`CREATE TABLE IF NOT EXISTS users (
@@ -95,8 +105,8 @@ func runMigrations() error {
 			supervisor_id VARCHAR(255),
 			profile_picture_url TEXT,
 			tags JSONB DEFAULT '[]',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		`CREATE TABLE IF NOT EXISTS video_modules (
 			id SERIAL PRIMARY KEY,
@@ -111,8 +121,8 @@ func runMigrations() error {
 			dislikes_count INTEGER DEFAULT 0,
 			is_active BOOLEAN DEFAULT true,
 			created_by VARCHAR(255) REFERENCES users(user_id),
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		// Video likes/dislikes tracking
 		`CREATE TABLE IF NOT EXISTS video_reactions (
@@ -120,7 +130,7 @@ func runMigrations() error {
 			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
 			video_id INTEGER REFERENCES video_modules(id) ON DELETE CASCADE,
 			reaction_type VARCHAR(10) NOT NULL CHECK (reaction_type IN ('like', 'dislike')),
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(user_id, video_id)
 		)`,
 		// Quizzes table (linked to video modules by title or video_id)
@@ -130,8 +140,8 @@ func runMigrations() error {
 			title VARCHAR(255) NOT NULL,
 			tags JSONB DEFAULT '[]',
 			created_by VARCHAR(255) REFERENCES users(user_id),
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		// Quiz questions (separate from video questions for more flexibility)
 		`CREATE TABLE IF NOT EXISTS quiz_questions (
@@ -141,7 +151,7 @@ func runMigrations() error {
 			options JSONB NOT NULL,
 			correct_answer INTEGER NOT NULL,
 			tags JSONB DEFAULT '[]',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		// Quiz completions
 		`CREATE TABLE IF NOT EXISTS quiz_completions (
@@ -150,7 +160,7 @@ func runMigrations() error {
 			quiz_id INTEGER REFERENCES quizzes(id) ON DELETE CASCADE,
 			score INTEGER NOT NULL,
 			total_questions INTEGER NOT NULL,
-			completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		`CREATE TABLE IF NOT EXISTS star_videos (
 			id SERIAL PRIMARY KEY,
@@ -171,7 +181,7 @@ func runMigrations() error {
 			id SERIAL PRIMARY KEY,
 			miner_id VARCHAR(255) REFERENCES users(user_id),
 			video_id INTEGER REFERENCES video_modules(id) ON DELETE CASCADE,
-			completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			score INTEGER,
 			total_questions INTEGER
 		)`,
@@ -186,10 +196,10 @@ func runMigrations() error {
 			media_status VARCHAR(50) DEFAULT 'NOT_APPLICABLE',
 			media_url TEXT,
 			location TEXT,
-			incident_time TIMESTAMP,
-			reporting_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			incident_time TIMESTAMPTZ,
+			reporting_time TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			status VARCHAR(50) DEFAULT 'PENDING',
-			resolution_time TIMESTAMP,
+			resolution_time TIMESTAMPTZ,
 			UNIQUE(user_id, emergency_id)
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
@@ -197,6 +207,8 @@ func runMigrations() error {
 		`CREATE INDEX IF NOT EXISTS idx_users_supervisor ON users(supervisor_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_emergencies_user ON emergencies(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_emergencies_status ON emergencies(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_reporting_time ON emergencies(reporting_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_severity ON emergencies(severity, reporting_time)`,
 		`CREATE INDEX IF NOT EXISTS idx_module_completions_miner ON module_completions(miner_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_star_videos_active ON star_videos(is_active, set_date)`,
 		`CREATE INDEX IF NOT EXISTS idx_video_reactions_user ON video_reactions(user_id)`,
@@ -211,8 +223,8 @@ func runMigrations() error {
 			description TEXT,
 			is_default BOOLEAN DEFAULT false,
 			is_active BOOLEAN DEFAULT true,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		// PPE Checklist table
 		`CREATE TABLE IF NOT EXISTS ppe_checklist (
@@ -222,8 +234,8 @@ func runMigrations() error {
 			description TEXT,
 			is_default BOOLEAN DEFAULT false,
 			is_active BOOLEAN DEFAULT true,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		// Pre-Start Checklist Completions (tracks user tick/no tick)
 		`CREATE TABLE IF NOT EXISTS pre_start_checklist_completions (
@@ -231,7 +243,7 @@ func runMigrations() error {
 			user_id VARCHAR(255) REFERENCES users(user_id),
 			item_id INTEGER REFERENCES pre_start_checklist(id) ON DELETE CASCADE,
 			is_completed BOOLEAN DEFAULT false,
-			completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			date DATE NOT NULL,
 			UNIQUE(user_id, item_id, date)
 		)`,
@@ -241,7 +253,7 @@ func runMigrations() error {
 			user_id VARCHAR(255) REFERENCES users(user_id),
 			item_id INTEGER REFERENCES ppe_checklist(id) ON DELETE CASCADE,
 			is_completed BOOLEAN DEFAULT false,
-			completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			date DATE NOT NULL,
 			UNIQUE(user_id, item_id, date)
 		)`,
@@ -258,8 +270,8 @@ func runMigrations() error {
 			mining_site VARCHAR(255),
 			is_active BOOLEAN DEFAULT true,
 			created_by VARCHAR(255) REFERENCES users(user_id),
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		// Emergency forwards tracking table
 		`CREATE TABLE IF NOT EXISTS emergency_forwards (
@@ -268,7 +280,7 @@ func runMigrations() error {
 			forwarded_by VARCHAR(255) REFERENCES users(user_id),
 			recipients TEXT,
 			message TEXT,
-			forwarded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			forwarded_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_mine_zones_mining_site ON mine_zones(mining_site)`,
 		`CREATE INDEX IF NOT EXISTS idx_mine_zones_active ON mine_zones(is_active)`,
@@ -295,12 +307,827 @@ func runMigrations() error {
 			completion_percentage FLOAT DEFAULT 0,
 			items_detected INTEGER DEFAULT 0,
 			total_items INTEGER DEFAULT 10,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(user_id, date)
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_ppe_stats_user ON ppe_stats(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_ppe_stats_date ON ppe_stats(date)`,
 		`CREATE INDEX IF NOT EXISTS idx_ppe_stats_user_date ON ppe_stats(user_id, date)`,
+		// Visitor passes - temporary site access for visitors with induction requirement
+		`CREATE TABLE IF NOT EXISTS visitor_passes (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			host_supervisor_id VARCHAR(255) REFERENCES users(user_id),
+			company VARCHAR(255),
+			purpose TEXT,
+			induction_video_id INTEGER REFERENCES video_modules(id) ON DELETE SET NULL,
+			badge_number VARCHAR(50) UNIQUE NOT NULL,
+			valid_from TIMESTAMPTZ NOT NULL,
+			valid_until TIMESTAMPTZ NOT NULL,
+			status VARCHAR(50) DEFAULT 'PENDING_INDUCTION',
+			inducted_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_visitor_passes_user ON visitor_passes(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_visitor_passes_host ON visitor_passes(host_supervisor_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_visitor_passes_valid_until ON visitor_passes(valid_until)`,
+		// Miner induction workflow - gates zone allocation for new miners
+		`CREATE TABLE IF NOT EXISTS miner_inductions (
+			id SERIAL PRIMARY KEY,
+			miner_id VARCHAR(255) UNIQUE REFERENCES users(user_id) ON DELETE CASCADE,
+			status VARCHAR(50) DEFAULT 'NOT_STARTED',
+			documents_uploaded_at TIMESTAMPTZ,
+			medical_cleared_at TIMESTAMPTZ,
+			modules_passed_at TIMESTAMPTZ,
+			ppe_issued_at TIMESTAMPTZ,
+			required_module_ids JSONB DEFAULT '[]',
+			notes TEXT,
+			updated_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_miner_inductions_status ON miner_inductions(status)`,
+		// In-app notifications
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			type VARCHAR(50) NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			message TEXT,
+			reference_type VARCHAR(50),
+			reference_id VARCHAR(255),
+			is_read BOOLEAN DEFAULT false,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, is_read)`,
+		// User documents - licenses, medical certificates and blasting tickets with expiry
+		`CREATE TABLE IF NOT EXISTS user_documents (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			document_type VARCHAR(50) NOT NULL,
+			document_number VARCHAR(255),
+			file_url TEXT NOT NULL,
+			issued_at DATE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			uploaded_by VARCHAR(255),
+			expiry_warned_at TIMESTAMPTZ,
+			expired_notified_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_documents_user ON user_documents(user_id, document_type)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_documents_expires ON user_documents(expires_at)`,
+		// Siren/display board endpoints triggered on critical emergencies and evacuations
+		`CREATE TABLE IF NOT EXISTS siren_endpoints (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			endpoint_type VARCHAR(50) DEFAULT 'WEBHOOK',
+			url TEXT NOT NULL,
+			mining_site VARCHAR(255),
+			secret TEXT,
+			unit_id INTEGER,
+			register INTEGER,
+			value INTEGER,
+			is_active BOOLEAN DEFAULT true,
+			created_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS siren_triggers (
+			id SERIAL PRIMARY KEY,
+			endpoint_id INTEGER REFERENCES siren_endpoints(id) ON DELETE CASCADE,
+			emergency_id INTEGER REFERENCES emergencies(id) ON DELETE CASCADE,
+			reason VARCHAR(50) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			attempts INTEGER DEFAULT 1,
+			response_code INTEGER,
+			response_body TEXT,
+			error TEXT,
+			triggered_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			acknowledged_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_siren_triggers_emergency ON siren_triggers(emergency_id)`,
+		// Shift windows - restrict miner checklist/PPE submissions to working hours per site
+		`CREATE TABLE IF NOT EXISTS shift_windows (
+			id SERIAL PRIMARY KEY,
+			mining_site VARCHAR(255) NOT NULL,
+			name VARCHAR(255),
+			start_time TIME NOT NULL,
+			end_time TIME NOT NULL,
+			days JSONB DEFAULT '[]',
+			timezone VARCHAR(100) DEFAULT 'UTC',
+			grace_minutes INTEGER DEFAULT 0,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_shift_windows_site ON shift_windows(mining_site)`,
+		// Client metadata and integrity signals recorded with sensitive submissions
+		`CREATE TABLE IF NOT EXISTS submission_attestations (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			submission_type VARCHAR(50) NOT NULL,
+			submission_id INTEGER,
+			app_version VARCHAR(50),
+			device_model VARCHAR(255),
+			device_id VARCHAR(255),
+			platform VARCHAR(50),
+			integrity_provider VARCHAR(50),
+			integrity_verdict VARCHAR(100),
+			ip_address VARCHAR(100),
+			user_agent TEXT,
+			flags JSONB DEFAULT '[]',
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_submission_attestations_user ON submission_attestations(user_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_submission_attestations_device ON submission_attestations(device_id)`,
+		// Minimum supported app version and feature flags per platform
+		`CREATE TABLE IF NOT EXISTS app_versions (
+			platform VARCHAR(20) PRIMARY KEY,
+			min_version VARCHAR(50) NOT NULL,
+			latest_version VARCHAR(50) NOT NULL,
+			update_url TEXT,
+			message TEXT,
+			feature_flags JSONB DEFAULT '{}',
+			updated_by VARCHAR(255),
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Feature flags targeted by role, mining site and rollout percentage
+		`CREATE TABLE IF NOT EXISTS feature_flags (
+			key VARCHAR(64) PRIMARY KEY,
+			description TEXT,
+			enabled BOOLEAN DEFAULT false,
+			rollout_percent INTEGER DEFAULT 100,
+			roles JSONB DEFAULT '[]',
+			mining_sites JSONB DEFAULT '[]',
+			updated_by VARCHAR(255),
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// System-wide settings shared by all instances (e.g. maintenance mode)
+		`CREATE TABLE IF NOT EXISTS system_settings (
+			key VARCHAR(100) PRIMARY KEY,
+			value JSONB NOT NULL,
+			updated_by VARCHAR(255),
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// JWT signing keys - rotated keys identified by kid; the legacy row tracks JWT_SECRET
+		`CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+			kid VARCHAR(64) PRIMARY KEY,
+			secret TEXT,
+			status VARCHAR(20) NOT NULL,
+			retire_after TIMESTAMPTZ,
+			rotated_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Individual video views - source of truth for video_modules.views_count
+		`CREATE TABLE IF NOT EXISTS video_views (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			video_id INTEGER REFERENCES video_modules(id) ON DELETE CASCADE,
+			viewed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_views_video ON video_views(video_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_views_user_video ON video_views(user_id, video_id, viewed_at)`,
+		// Feed filters: category/language lookups, newest-first listing, hide-completed
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_active_created ON video_modules(created_at DESC) WHERE is_active = true`,
+		`CREATE INDEX IF NOT EXISTS idx_module_completions_miner_video ON module_completions(miner_id, video_id)`,
+		videoTrendingView,
+		videoTrendingIndex,
+		`CREATE INDEX IF NOT EXISTS idx_video_views_viewed_at ON video_views(viewed_at)`,
+		// Every quiz attempt; module_completions keeps the best attempt per day
+		`CREATE TABLE IF NOT EXISTS module_attempts (
+			id SERIAL PRIMARY KEY,
+			miner_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			video_id INTEGER REFERENCES video_modules(id) ON DELETE CASCADE,
+			completion_id INTEGER REFERENCES module_completions(id) ON DELETE SET NULL,
+			score INTEGER NOT NULL,
+			total_questions INTEGER NOT NULL,
+			percentage FLOAT NOT NULL,
+			passed BOOLEAN NOT NULL,
+			attempted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_module_attempts_miner_video ON module_attempts(miner_id, video_id, attempted_at)`,
+		// Corrective training assigned after an emergency or failed PPE check
+		`CREATE TABLE IF NOT EXISTS remedial_assignments (
+			id SERIAL PRIMARY KEY,
+			miner_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			video_id INTEGER REFERENCES video_modules(id) ON DELETE CASCADE,
+			assigned_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			source_type VARCHAR(50) NOT NULL,
+			source_id INTEGER NOT NULL,
+			reason TEXT,
+			due_date DATE,
+			completion_id INTEGER REFERENCES module_completions(id) ON DELETE SET NULL,
+			completed_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(miner_id, video_id, source_type, source_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_remedial_assignments_source ON remedial_assignments(source_type, source_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_remedial_assignments_open ON remedial_assignments(miner_id, video_id) WHERE completed_at IS NULL`,
+		// When each supervisor first opened and acknowledged an emergency
+		`CREATE TABLE IF NOT EXISTS emergency_acknowledgments (
+			id SERIAL PRIMARY KEY,
+			emergency_id INTEGER REFERENCES emergencies(id) ON DELETE CASCADE,
+			supervisor_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			first_viewed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			acknowledged_at TIMESTAMPTZ,
+			UNIQUE(emergency_id, supervisor_id)
+		)`,
+		// Client analytics events from the app, PII scrubbed before insert
+		`CREATE TABLE IF NOT EXISTS client_events (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			event_type VARCHAR(50) NOT NULL,
+			screen VARCHAR(100),
+			session_id VARCHAR(100),
+			properties JSONB DEFAULT '{}',
+			app_version VARCHAR(50),
+			platform VARCHAR(50),
+			occurred_at TIMESTAMPTZ NOT NULL,
+			received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_client_events_type_time ON client_events(event_type, occurred_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_client_events_user ON client_events(user_id, occurred_at)`,
+		// Handled errors reported by the app, grouped per release for admins
+		`CREATE TABLE IF NOT EXISTS client_error_reports (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			error_type VARCHAR(100) NOT NULL,
+			message TEXT,
+			stack_trace TEXT,
+			screen VARCHAR(100),
+			request_id VARCHAR(64),
+			app_version VARCHAR(50),
+			platform VARCHAR(50),
+			device_model VARCHAR(255),
+			os_version VARCHAR(50),
+			occurred_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_client_error_reports_release ON client_error_reports(app_version, platform, occurred_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_client_error_reports_request ON client_error_reports(request_id)`,
+		// A/B experiments and the first time each user saw their assigned variant
+		`CREATE TABLE IF NOT EXISTS experiments (
+			key VARCHAR(64) PRIMARY KEY,
+			description TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'DRAFT',
+			variants JSONB NOT NULL DEFAULT '[]',
+			roles JSONB DEFAULT '[]',
+			mining_sites JSONB DEFAULT '[]',
+			started_at TIMESTAMPTZ,
+			updated_by VARCHAR(255),
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS experiment_exposures (
+			id SERIAL PRIMARY KEY,
+			experiment_key VARCHAR(64) REFERENCES experiments(key) ON DELETE CASCADE,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			variant VARCHAR(64) NOT NULL,
+			exposed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(experiment_key, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_experiment_exposures_variant ON experiment_exposures(experiment_key, variant)`,
+		// Content reported by users and the admin moderation audit log
+		`CREATE TABLE IF NOT EXISTS content_reports (
+			id SERIAL PRIMARY KEY,
+			content_type VARCHAR(50) NOT NULL,
+			content_id VARCHAR(255) NOT NULL,
+			reported_by VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			reason TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+			resolved_by VARCHAR(255),
+			resolved_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_content_reports_open ON content_reports(content_type, content_id, reported_by) WHERE status = 'OPEN'`,
+		`CREATE TABLE IF NOT EXISTS moderation_actions (
+			id SERIAL PRIMARY KEY,
+			admin_id VARCHAR(255) NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			content_type VARCHAR(50) NOT NULL,
+			content_id VARCHAR(255) NOT NULL,
+			uploader_id VARCHAR(255),
+			reason TEXT,
+			previous_value TEXT,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_moderation_actions_content ON moderation_actions(content_type, content_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS daily_compliance_summary (
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			supervisor_id VARCHAR(255),
+			mining_site VARCHAR(255),
+			date DATE NOT NULL,
+			checklist_items INTEGER NOT NULL DEFAULT 0,
+			checklist_completed INTEGER NOT NULL DEFAULT 0,
+			checklist_pct NUMERIC(5,1) NOT NULL DEFAULT 0,
+			ppe_items INTEGER NOT NULL DEFAULT 0,
+			ppe_completed INTEGER NOT NULL DEFAULT 0,
+			ppe_pct NUMERIC(5,1) NOT NULL DEFAULT 0,
+			modules_completed INTEGER NOT NULL DEFAULT 0,
+			refreshed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_daily_compliance_supervisor ON daily_compliance_summary(supervisor_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_daily_compliance_site ON daily_compliance_summary(mining_site, date)`,
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti VARCHAR(64) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			reason VARCHAR(50),
+			revoked_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens(expires_at)`,
+		`CREATE TABLE IF NOT EXISTS user_token_revocations (
+			user_id VARCHAR(255) PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			revoked_before TIMESTAMPTZ NOT NULL,
+			revoked_by VARCHAR(255),
+			reason VARCHAR(50),
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS shared_counters (
+			key VARCHAR(255) PRIMARY KEY,
+			count INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS seed_entries (
+			seed_key VARCHAR(255) PRIMARY KEY,
+			content_hash VARCHAR(64) NOT NULL,
+			video_id INTEGER REFERENCES video_modules(id) ON DELETE SET NULL,
+			applied_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS seed_audit (
+			id SERIAL PRIMARY KEY,
+			seed_key VARCHAR(255) NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			content_hash VARCHAR(64) NOT NULL,
+			video_id INTEGER,
+			applied_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Pre-use checks operators run per piece of equipment; equipment_type narrows an
+		// item to one kind of machine, empty applies to all
+		`CREATE TABLE IF NOT EXISTS equipment_checklist (
+			id SERIAL PRIMARY KEY,
+			supervisor_id VARCHAR(255) NOT NULL,
+			equipment_type VARCHAR(100) NOT NULL DEFAULT '',
+			title VARCHAR(255) NOT NULL,
+			description TEXT,
+			is_active BOOLEAN DEFAULT true,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_equipment_checklist_supervisor ON equipment_checklist(supervisor_id, equipment_type) WHERE is_active = true`,
+		`CREATE TABLE IF NOT EXISTS equipment_checklist_completions (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			item_id INTEGER REFERENCES equipment_checklist(id) ON DELETE CASCADE,
+			equipment_id VARCHAR(100) NOT NULL,
+			is_completed BOOLEAN DEFAULT false,
+			notes TEXT,
+			completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			date DATE NOT NULL,
+			UNIQUE(user_id, item_id, equipment_id, date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_equipment_completions_date ON equipment_checklist_completions(date, user_id)`,
+		// Permissions the server checks and the roles granted them; seeded from code on startup
+		`CREATE TABLE IF NOT EXISTS permissions (
+			name VARCHAR(100) PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS role_permissions (
+			role VARCHAR(50) NOT NULL,
+			permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+			granted_by VARCHAR(255),
+			granted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (role, permission)
+		)`,
+		// Single sign-on: pending logins and the external identities linked to users
+		`CREATE TABLE IF NOT EXISTS oidc_login_states (
+			state VARCHAR(64) PRIMARY KEY,
+			nonce VARCHAR(64) NOT NULL,
+			code_verifier VARCHAR(128) NOT NULL,
+			link_user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_identities (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			issuer VARCHAR(500) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			email VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			last_login_at TIMESTAMPTZ,
+			UNIQUE(issuer, subject)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id)`,
+		// Invitations for crew members who set their own password; only the token hash is kept
+		`CREATE TABLE IF NOT EXISTS user_invitations (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL UNIQUE REFERENCES users(user_id) ON DELETE CASCADE,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			invited_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			sent_count INTEGER NOT NULL DEFAULT 1,
+			last_sent_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			accepted_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Days crew members are on leave; inclusive dates in the site's local calendar
+		`CREATE TABLE IF NOT EXISTS miner_leave (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL,
+			leave_type VARCHAR(20) NOT NULL DEFAULT 'OTHER' CHECK (leave_type IN ('ANNUAL', 'SICK', 'TRAINING', 'OTHER')),
+			note TEXT,
+			recorded_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			CHECK (end_date >= start_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_miner_leave_user ON miner_leave(user_id, start_date, end_date)`,
+		// One row per issued session token, so users can see and sign out their devices
+		`CREATE TABLE IF NOT EXISTS user_sessions (
+			id SERIAL PRIMARY KEY,
+			jti VARCHAR(64) NOT NULL UNIQUE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			audience VARCHAR(50),
+			device_name VARCHAR(255),
+			device_id VARCHAR(255),
+			platform VARCHAR(50),
+			user_agent TEXT,
+			ip_address VARCHAR(64),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, expires_at)`,
+		// Single- or limited-use codes for registering admin accounts; only the code hash is kept
+		`CREATE TABLE IF NOT EXISTS admin_codes (
+			id SERIAL PRIMARY KEY,
+			code_hash VARCHAR(64) NOT NULL UNIQUE,
+			label VARCHAR(255),
+			created_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			max_uses INTEGER NOT NULL DEFAULT 1,
+			use_count INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ,
+			revoked_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Which code created which admin; code_id is NULL for the bootstrap code
+		`CREATE TABLE IF NOT EXISTS admin_code_redemptions (
+			id SERIAL PRIMARY KEY,
+			code_id INTEGER REFERENCES admin_codes(id) ON DELETE SET NULL,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			ip_address VARCHAR(64),
+			redeemed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_code_redemptions_code ON admin_code_redemptions(code_id)`,
+		// Pending sign-up email verifications; only the token hash is kept
+		`CREATE TABLE IF NOT EXISTS email_verifications (
+			user_id VARCHAR(255) PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			sent_count INTEGER NOT NULL DEFAULT 1,
+			last_sent_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Lower-resolution renditions of uploaded media, keyed by the original's /uploads/ path
+		`CREATE TABLE IF NOT EXISTS media_renditions (
+			source_url TEXT NOT NULL,
+			quality VARCHAR(10) NOT NULL CHECK (quality IN ('low', 'medium')),
+			url TEXT NOT NULL,
+			width INTEGER,
+			height INTEGER,
+			bitrate_kbps INTEGER,
+			bytes BIGINT,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (source_url, quality)
+		)`,
+		// Photos and videos uploaded for an emergency; a report can have several
+		`CREATE TABLE IF NOT EXISTS emergency_media (
+			id SERIAL PRIMARY KEY,
+			emergency_id INTEGER NOT NULL REFERENCES emergencies(id) ON DELETE CASCADE,
+			media_type VARCHAR(10) NOT NULL,
+			media_url TEXT NOT NULL,
+			file_name VARCHAR(255) NOT NULL DEFAULT '',
+			size_bytes BIGINT NOT NULL DEFAULT 0,
+			uploaded_by VARCHAR(255) NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergency_media_emergency ON emergency_media(emergency_id)`,
+		// Texts sent about HIGH and CRITICAL emergencies, with their outcome
+		`CREATE TABLE IF NOT EXISTS emergency_sms (
+			id SERIAL PRIMARY KEY,
+			emergency_id INTEGER NOT NULL REFERENCES emergencies(id) ON DELETE CASCADE,
+			recipient_name VARCHAR(255),
+			phone VARCHAR(32) NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			error TEXT,
+			sent_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergency_sms_emergency ON emergency_sms(emergency_id)`,
+		// Transcode, thumbnail and caption jobs reported by the media pipeline per original
+		`CREATE TABLE IF NOT EXISTS media_jobs (
+			source_url TEXT NOT NULL,
+			job_type VARCHAR(20) NOT NULL,
+			quality VARCHAR(10) NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL,
+			progress INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 1,
+			started_at TIMESTAMPTZ,
+			finished_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (source_url, job_type, quality)
+		)`,
+		// Every admin impersonation of a user, with the token it issued
+		`CREATE TABLE IF NOT EXISTS impersonation_log (
+			id SERIAL PRIMARY KEY,
+			admin_id VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			jti VARCHAR(64) NOT NULL,
+			reason TEXT NOT NULL,
+			ip_address VARCHAR(64),
+			user_agent TEXT,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_impersonation_log_user ON impersonation_log(user_id, created_at)`,
+		// Read-only tokens minted for external auditors, scoped to record types and a date range
+		`CREATE TABLE IF NOT EXISTS audit_tokens (
+			id SERIAL PRIMARY KEY,
+			jti VARCHAR(64) UNIQUE NOT NULL,
+			auditor_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			org_id INTEGER,
+			company VARCHAR(255),
+			scopes TEXT[] NOT NULL,
+			data_from DATE NOT NULL,
+			data_to DATE NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_by VARCHAR(255) NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMPTZ,
+			revoked_by VARCHAR(255)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_tokens_auditor ON audit_tokens(auditor_id)`,
+		// Database backups taken from the dashboard; the files live in BACKUP_DIR
+		`CREATE TABLE IF NOT EXISTS database_backups (
+			id SERIAL PRIMARY KEY,
+			file_name VARCHAR(255) NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			size_bytes BIGINT,
+			error TEXT,
+			requested_by VARCHAR(255) NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMPTZ
+		)`,
+		// Items the app queued offline and uploaded through /api/app/sync, keyed by the
+		// UUID the app gave them so a re-sent queue is never applied twice. The row is
+		// written in the same transaction as the item itself.
+		`CREATE TABLE IF NOT EXISTS offline_sync_items (
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			client_id UUID NOT NULL,
+			item_type VARCHAR(30) NOT NULL,
+			record_id INTEGER,
+			recorded_at TIMESTAMPTZ NOT NULL,
+			synced_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, client_id)
+		)`,
+		// Progress of the online migrations in online_migrations.go; last_id is where a
+		// resumed backfill carries on
+		`CREATE TABLE IF NOT EXISTS online_migrations (
+			name VARCHAR(100) PRIMARY KEY,
+			status VARCHAR(20) NOT NULL,
+			last_id BIGINT NOT NULL DEFAULT 0,
+			rows_done BIGINT NOT NULL DEFAULT 0,
+			rows_total BIGINT,
+			error TEXT,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMPTZ
+		)`,
+		// Phones registered to the miner app; session tokens are bound to one of these
+		`CREATE TABLE IF NOT EXISTS devices (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			device_id VARCHAR(255) NOT NULL,
+			model VARCHAR(255),
+			os VARCHAR(50),
+			os_version VARCHAR(50),
+			app_version VARCHAR(50),
+			push_token TEXT,
+			registered_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			deactivated_at TIMESTAMPTZ,
+			deactivated_by VARCHAR(255),
+			deactivation_reason TEXT,
+			UNIQUE (user_id, device_id)
+		)`,
+		// Site directory of medics, control rooms and other numbers for the SOS call list
+		`CREATE TABLE IF NOT EXISTS emergency_contacts (
+			id SERIAL PRIMARY KEY,
+			mining_site VARCHAR(255) NOT NULL,
+			contact_type VARCHAR(50) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			phone VARCHAR(50) NOT NULL,
+			priority INTEGER NOT NULL DEFAULT 0,
+			shift_window_id INTEGER REFERENCES shift_windows(id) ON DELETE SET NULL,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Lone-worker mode: the app checks in on a timer and a missed check-in raises an emergency
+		`CREATE TABLE IF NOT EXISTS lone_worker_sessions (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			interval_seconds INTEGER NOT NULL,
+			grace_seconds INTEGER NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_checkin_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			next_due_at TIMESTAMPTZ NOT NULL,
+			last_latitude DOUBLE PRECISION,
+			last_longitude DOUBLE PRECISION,
+			ended_at TIMESTAMPTZ,
+			missed_at TIMESTAMPTZ,
+			emergency_id INTEGER REFERENCES emergencies(id) ON DELETE SET NULL
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_lone_worker_sessions_active ON lone_worker_sessions(user_id) WHERE ended_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_lone_worker_sessions_due ON lone_worker_sessions(next_due_at) WHERE ended_at IS NULL`,
+		// Every login attempt, for investigating suspicious access; user_id is NULL for unknown accounts
+		`CREATE TABLE IF NOT EXISTS login_events (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(255),
+			email VARCHAR(255),
+			role VARCHAR(50),
+			method VARCHAR(20) NOT NULL,
+			outcome VARCHAR(50) NOT NULL,
+			ip_address VARCHAR(64),
+			user_agent TEXT,
+			org_id INTEGER,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_login_events_created ON login_events(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_login_events_user ON login_events(user_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_login_events_ip ON login_events(ip_address, created_at)`,
+		// Acting supervisor cover: the delegate manages the supervisor's crew between the dates
+		`CREATE TABLE IF NOT EXISTS supervisor_delegations (
+			id SERIAL PRIMARY KEY,
+			supervisor_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			delegate_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL,
+			reason TEXT,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMPTZ,
+			revoked_by VARCHAR(255)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_supervisor_delegations_delegate ON supervisor_delegations(delegate_id, start_date, end_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_supervisor_delegations_supervisor ON supervisor_delegations(supervisor_id, start_date)`,
+		// Wearables issued to miners; each posts readings with its own API key (stored hashed)
+		`CREATE TABLE IF NOT EXISTS wearable_devices (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			mining_site VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			key_hash VARCHAR(64) NOT NULL UNIQUE,
+			created_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at TIMESTAMPTZ,
+			last_heart_rate_alert_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS wearable_readings (
+			id BIGSERIAL PRIMARY KEY,
+			device_id INTEGER NOT NULL REFERENCES wearable_devices(id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			reading_type VARCHAR(20) NOT NULL,
+			heart_rate INTEGER,
+			latitude DOUBLE PRECISION,
+			longitude DOUBLE PRECISION,
+			recorded_at TIMESTAMPTZ NOT NULL,
+			received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_wearable_readings_user ON wearable_readings(user_id, recorded_at)`,
+		// Detected falls; an SOS is raised at sos_due_at unless the miner dismisses it first
+		`CREATE TABLE IF NOT EXISTS wearable_falls (
+			id SERIAL PRIMARY KEY,
+			device_id INTEGER NOT NULL REFERENCES wearable_devices(id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			latitude DOUBLE PRECISION,
+			longitude DOUBLE PRECISION,
+			detected_at TIMESTAMPTZ NOT NULL,
+			sos_due_at TIMESTAMPTZ NOT NULL,
+			dismissed_at TIMESTAMPTZ,
+			raised_at TIMESTAMPTZ,
+			emergency_id INTEGER REFERENCES emergencies(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_wearable_falls_due ON wearable_falls(sos_due_at) WHERE raised_at IS NULL AND dismissed_at IS NULL`,
+		// Gas, dust, temperature and airflow sensors installed in a zone; keys are stored hashed
+		`CREATE TABLE IF NOT EXISTS zone_sensors (
+			id SERIAL PRIMARY KEY,
+			zone_id INTEGER NOT NULL REFERENCES mine_zones(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			key_hash VARCHAR(64) NOT NULL UNIQUE,
+			created_by VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS zone_environment_readings (
+			id BIGSERIAL PRIMARY KEY,
+			sensor_id INTEGER NOT NULL REFERENCES zone_sensors(id) ON DELETE CASCADE,
+			zone_id INTEGER NOT NULL REFERENCES mine_zones(id) ON DELETE CASCADE,
+			metric VARCHAR(20) NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			recorded_at TIMESTAMPTZ NOT NULL,
+			received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_zone_environment_readings_zone ON zone_environment_readings(zone_id, metric, recorded_at)`,
+		// A metric outside its limit in a zone, from the first reading past it until one back within it
+		`CREATE TABLE IF NOT EXISTS zone_environment_breaches (
+			id SERIAL PRIMARY KEY,
+			zone_id INTEGER NOT NULL REFERENCES mine_zones(id) ON DELETE CASCADE,
+			metric VARCHAR(20) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			threshold DOUBLE PRECISION NOT NULL,
+			peak_value DOUBLE PRECISION NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			ended_at TIMESTAMPTZ
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_zone_environment_breaches_open ON zone_environment_breaches(zone_id, metric) WHERE ended_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_zone_environment_breaches_started ON zone_environment_breaches(zone_id, started_at)`,
+		// Clearing a zone before a blast; one open clearance per zone locks allocations to it
+		`CREATE TABLE IF NOT EXISTS blast_clearances (
+			id SERIAL PRIMARY KEY,
+			zone_id INTEGER NOT NULL REFERENCES mine_zones(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			blast_at TIMESTAMPTZ,
+			notes TEXT,
+			initiated_by VARCHAR(255) NOT NULL,
+			initiated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			cleared_by VARCHAR(255),
+			cleared_at TIMESTAMPTZ,
+			reopened_by VARCHAR(255),
+			reopened_at TIMESTAMPTZ,
+			cancelled_by VARCHAR(255),
+			cancelled_at TIMESTAMPTZ
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_blast_clearances_open ON blast_clearances(zone_id) WHERE status IN ('CLEARING', 'CLEARED')`,
+		`CREATE TABLE IF NOT EXISTS blast_clearance_evacuations (
+			clearance_id INTEGER NOT NULL REFERENCES blast_clearances(id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			confirmed_by VARCHAR(255) NOT NULL,
+			confirmed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (clearance_id, user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS blast_clearance_signoffs (
+			id SERIAL PRIMARY KEY,
+			clearance_id INTEGER NOT NULL REFERENCES blast_clearances(id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			role VARCHAR(50) NOT NULL,
+			notes TEXT,
+			signed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (clearance_id, user_id)
+		)`,
+		// Audit trail of every step of a clearance
+		`CREATE TABLE IF NOT EXISTS blast_clearance_events (
+			id SERIAL PRIMARY KEY,
+			clearance_id INTEGER NOT NULL REFERENCES blast_clearances(id) ON DELETE CASCADE,
+			event VARCHAR(30) NOT NULL,
+			user_id VARCHAR(255),
+			details TEXT,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_blast_clearance_events_clearance ON blast_clearance_events(clearance_id, created_at)`,
+		// Responses to app submissions sent with an X-Idempotency-Key, replayed on retry;
+		// status_code is NULL while the first request is still being processed
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			scope VARCHAR(50) NOT NULL,
+			idempotency_key VARCHAR(255) NOT NULL,
+			request_hash VARCHAR(64) NOT NULL,
+			status_code INTEGER,
+			response TEXT,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, scope, idempotency_key)
+		)`,
+		// Quiz questions drafted from a module's transcript; they only reach the questions
+		// table once a supervisor approves them
+		`CREATE TABLE IF NOT EXISTS question_drafts (
+			id SERIAL PRIMARY KEY,
+			video_id INTEGER NOT NULL REFERENCES video_modules(id) ON DELETE CASCADE,
+			question TEXT NOT NULL,
+			options JSONB NOT NULL,
+			answer INTEGER NOT NULL,
+			explanation TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'DRAFT',
+			model VARCHAR(255),
+			generated_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			reviewed_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+			reviewed_at TIMESTAMPTZ,
+			question_id INTEGER REFERENCES questions(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_question_drafts_video ON question_drafts(video_id, status)`,
 		// Add columns if they don't exist (for existing databases)
 		`DO $$ BEGIN
 			ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_picture_url TEXT;
@@ -312,18 +1139,84 @@ func runMigrations() error {
 			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS dislikes_count INTEGER DEFAULT 0;
 			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS approval_status VARCHAR(50) DEFAULT 'approved';
 			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS reviewed_by VARCHAR(255);
-			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
 			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS review_feedback TEXT;
 			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS views_count INTEGER DEFAULT 0;
+			ALTER TABLE mine_zones ADD COLUMN IF NOT EXISTS required_documents JSONB DEFAULT '[]';
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS evacuation_started_at TIMESTAMPTZ;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS evacuation_started_by VARCHAR(255);
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS completions_count INTEGER DEFAULT 0;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS language VARCHAR(50);
+			ALTER TABLE questions ADD COLUMN IF NOT EXISTS explanation TEXT;
+			ALTER TABLE quiz_questions ADD COLUMN IF NOT EXISTS explanation TEXT;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS primary_emergency_id INTEGER REFERENCES emergencies(id) ON DELETE SET NULL;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS category VARCHAR(50);
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS details JSONB;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS media_status_updated_at TIMESTAMPTZ;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS media_reminders INTEGER DEFAULT 0;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS media_reminded_at TIMESTAMPTZ;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMPTZ;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS stale_flagged_at TIMESTAMPTZ;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMPTZ;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS closure_reason TEXT;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS closed_by VARCHAR(255);
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS feed_last_visit_at TIMESTAMPTZ;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS feed_previous_visit_at TIMESTAMPTZ;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(100);
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS review_due_at TIMESTAMPTZ;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS review_reminded_at TIMESTAMPTZ;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS last_reviewed_at TIMESTAMPTZ;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS review_notes TEXT;
+			ALTER TABLE pre_start_checklist ADD COLUMN IF NOT EXISTS mining_site VARCHAR(255);
+			ALTER TABLE ppe_checklist ADD COLUMN IF NOT EXISTS mining_site VARCHAR(255);
+			ALTER TABLE daily_compliance_summary ADD COLUMN IF NOT EXISTS on_leave BOOLEAN NOT NULL DEFAULT false;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT true;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255);
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS passing_score FLOAT;
+			ALTER TABLE module_completions ADD COLUMN IF NOT EXISTS passed BOOLEAN NOT NULL DEFAULT true;
+			ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS transcript TEXT;
+			ALTER TABLE emergency_forwards ADD COLUMN IF NOT EXISTS redacted JSONB;
+			ALTER TABLE mine_zones ADD COLUMN IF NOT EXISTS boundary JSONB;
+			ALTER TABLE mine_zones ADD COLUMN IF NOT EXISTS center_latitude DOUBLE PRECISION;
+			ALTER TABLE mine_zones ADD COLUMN IF NOT EXISTS center_longitude DOUBLE PRECISION;
+			ALTER TABLE mine_zones ADD COLUMN IF NOT EXISTS radius_meters DOUBLE PRECISION;
+			ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS zone_id INTEGER REFERENCES mine_zones(id) ON DELETE SET NULL;
+			ALTER TABLE oidc_login_states ADD COLUMN IF NOT EXISTS link_user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE;
 		EXCEPTION WHEN OTHERS THEN NULL;
 		END $$`,
+		// Runs after the ALTER block so the columns exist on older databases
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_category ON video_modules(category)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_language ON video_modules(language)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_primary ON emergencies(primary_emergency_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_category ON emergencies(category, reporting_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_zone ON emergencies(zone_id, reporting_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_scheduled ON video_modules(publish_at) WHERE approval_status = 'scheduled'`,
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_expires ON video_modules(expires_at) WHERE is_active = true`,
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_review_due ON video_modules(review_due_at) WHERE is_active = true`,
+		`CREATE INDEX IF NOT EXISTS idx_pre_start_checklist_site ON pre_start_checklist(mining_site) WHERE is_default = true`,
+		`CREATE INDEX IF NOT EXISTS idx_ppe_checklist_site ON ppe_checklist(mining_site) WHERE is_default = true`,
+		// Supervisor dashboards: team lookups; the completion and PPE history indexes are
+		// built online, see onlineMigrations
+		`CREATE INDEX IF NOT EXISTS idx_users_supervisor_role ON users(supervisor_id, role)`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_media_pending ON emergencies(media_status) WHERE media_status IN ('PENDING_UPLOAD', 'UPLOAD_FAILED')`,
 	}
 
+	migrations = append(migrations, constraintMigrations...)
+	migrations = append(migrations, siteMigrations()...)
+	migrations = append(migrations, timestampMigrations...)
+
 	for _, migration := range migrations {
 		if _, err := DB.Exec(migration); err != nil {
 			return fmt.Errorf("migration failed: %w\nSQL: %s", err, migration)
 		}
 	}
+	if err := expandOnlineMigrations(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
 
 	log.Println("Migrations completed successfully")
 
@@ -332,21 +1225,21 @@ func runMigrations() error {
 		log.Printf("Warning: Failed to update video URLs: %v", err)
 	}
 
-	// Seed default YouTube video tutorials
+	// Seed new or changed default video tutorials and their quizzes
 	if err := seedDefaultVideos(); err != nil {
 		log.Printf("Warning: Failed to seed default videos: %v", err)
 	}
 
-	// Seed default checklists
-	if err := seedDefaultChecklists(); err != nil {
-		log.Printf("Warning: Failed to seed default checklists: %v", err)
+	// Move global default checklists to per-organization copies
+	if err := migrateGlobalDefaultChecklists(); err != nil {
+		log.Printf("Warning: Failed to provision organization checklists: %v", err)
 	}
 
 	return nil
 }
 
 func updateVideoURLsWithBaseURL() error {
-	baseURL := os.Getenv("BASE_URL")
+	baseURL := config.Get().BaseURL
 	if baseURL == "" {
 		log.Println("BASE_URL not set, skipping video URL update")
 		return nil
@@ -387,60 +1280,207 @@ func updateVideoURLsWithBaseURL() error {
 	return nil
 }
 
+// seedDefaultVideos applies videos.json incrementally. Each entry is keyed on its filename
+// and fingerprinted with a content hash, so entries added to or edited in videos.json reach
+// existing databases without re-seeding entries that are unchanged. Every insert or update
+// is recorded in seed_audit.
 func seedDefaultVideos() error {
-	// Check if videos already exist
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM video_modules").Scan(&count)
+	videosConfig, err := loadVideosConfig()
 	if err != nil {
-		return err
+		log.Printf("Warning: Could not load videos.json, using defaults: %v", err)
+		return nil
 	}
 
-	if count > 0 {
-		log.Println("Videos already seeded, skipping...")
-		return nil
+	// Get base URL for absolute video URLs (relative paths if BASE_URL not set, for local dev)
+	baseURL := config.Get().BaseURL
+
+	applied := 0
+	for _, v := range videosConfig.Videos {
+		action, err := applySeedVideo(v, baseURL)
+		if err != nil {
+			return err
+		}
+		if action != "" {
+			applied++
+		}
 	}
 
-	// Load videos from JSON configuration file
-	videosConfig, err := loadVideosConfig()
-	if err != nil {
-		log.Printf("Warning: Could not load videos.json, using defaults: %v", err)
-		return nil
+	if applied > 0 {
+		log.Printf("Applied %d new or changed default video modules from videos.json", applied)
+	} else {
+		log.Println("Default videos up to date, skipping...")
 	}
+	return nil
+}
 
-	// Get base URL from environment for absolute video URLs
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "" // Will use relative paths if BASE_URL not set (for local dev)
+// seedEntryHash fingerprints a videos.json entry, including its quiz
+func seedEntryHash(v VideoEntry) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// applySeedVideo inserts or updates one default video and its quiz when its content hash
+// differs from the one last applied. It returns the action taken, or "" if unchanged.
+func applySeedVideo(v VideoEntry, baseURL string) (string, error) {
+	hash := seedEntryHash(v)
+
+	var appliedHash string
+	var videoID sql.NullInt64
+	err := DB.QueryRow("SELECT content_hash, video_id FROM seed_entries WHERE seed_key = $1", v.Filename).Scan(&appliedHash, &videoID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	recorded := err == nil
+	if recorded && appliedHash == hash {
+		return "", nil
 	}
 
-	log.Println("Seeding default video modules from videos.json...")
-	for _, v := range videosConfig.Videos {
-		videoURL := baseURL + "/assets/" + v.Filename
-		tagsJSON, _ := json.Marshal(v.Tags)
+	// Databases seeded before seed_entries existed already hold the video; match it by
+	// asset path or title instead of inserting a duplicate
+	if !videoID.Valid {
+		var id int
+		err := DB.QueryRow(`
+			SELECT id FROM video_modules
+			WHERE created_by IS NULL AND (video_url LIKE '%/assets/' || $1 OR title = $2)
+			ORDER BY id LIMIT 1
+		`, v.Filename, v.Title).Scan(&id)
+		if err == nil {
+			videoID = sql.NullInt64{Int64: int64(id), Valid: true}
+		} else if err != sql.ErrNoRows {
+			return "", err
+		}
+	}
 
-		var videoID int
-		err := DB.QueryRow(
-			`INSERT INTO video_modules (title, description, video_url, duration, category, tags, is_active, created_at, updated_at)
-			 VALUES ($1, $2, $3, $4, $5, $6, true, NOW(), NOW())
+	tx, err := DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	tagsJSON, _ := json.Marshal(v.Tags)
+	action := "updated"
+	if videoID.Valid {
+		// is_active is left alone so a default an admin took down stays down
+		_, err = tx.Exec(`
+			UPDATE video_modules SET title = $1, description = $2, duration = $3, category = $4,
+			       language = $5, tags = $6, updated_at = NOW()
+			WHERE id = $7
+		`, v.Title, v.Description, v.Duration, v.Category, v.Language, tagsJSON, videoID.Int64)
+		if err != nil {
+			return "", fmt.Errorf("failed to update seeded video '%s': %w", v.Title, err)
+		}
+		if !recorded {
+			action = "adopted"
+		}
+	} else {
+		var id int
+		err = tx.QueryRow(
+			`INSERT INTO video_modules (title, description, video_url, duration, category, language, tags, is_active, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, true, NOW(), NOW())
 			 RETURNING id`,
-			v.Title, v.Description, videoURL, v.Duration, v.Category, tagsJSON,
-		).Scan(&videoID)
+			v.Title, v.Description, baseURL+"/assets/"+v.Filename, v.Duration, v.Category, v.Language, tagsJSON,
+		).Scan(&id)
 		if err != nil {
-			return fmt.Errorf("failed to seed video '%s': %w", v.Title, err)
+			return "", fmt.Errorf("failed to seed video '%s': %w", v.Title, err)
 		}
-		log.Printf("Seeded video: %s (ID: %d, Language: %s)", v.Title, videoID, v.Language)
+		videoID = sql.NullInt64{Int64: int64(id), Valid: true}
+		action = "added"
+	}
+
+	if err := applySeedQuiz(tx, int(videoID.Int64), v); err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO seed_entries (seed_key, content_hash, video_id, applied_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (seed_key) DO UPDATE SET content_hash = EXCLUDED.content_hash, video_id = EXCLUDED.video_id, applied_at = NOW()
+	`, v.Filename, hash, videoID.Int64)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO seed_audit (seed_key, action, content_hash, video_id, applied_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, v.Filename, action, hash, videoID.Int64)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	log.Printf("Seed %s video: %s (ID: %d, Language: %s)", action, v.Title, videoID.Int64, v.Language)
+	return action, nil
+}
+
+// applySeedQuiz creates or refreshes the quiz of a default video. Questions are replaced
+// wholesale; completions reference the quiz, not individual questions, so history is kept.
+func applySeedQuiz(tx *sql.Tx, videoID int, video VideoEntry) error {
+	if video.Quiz == nil || len(video.Quiz.Questions) == 0 {
+		return nil
 	}
 
-	log.Printf("Successfully seeded %d default video modules", len(videosConfig.Videos))
+	// Build quiz tags from video tags
+	quizTags := append(append([]string{}, video.Tags...), video.Category, video.Language)
+	quizTagsJSON, _ := json.Marshal(quizTags)
 
-	// Seed quizzes for each video
-	if err := seedDefaultQuizzes(); err != nil {
-		log.Printf("Warning: Failed to seed default quizzes: %v", err)
+	var quizID int
+	err := tx.QueryRow("SELECT id FROM quizzes WHERE video_id = $1 ORDER BY id LIMIT 1", videoID).Scan(&quizID)
+	switch {
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`
+			INSERT INTO quizzes (video_id, title, tags, created_at, updated_at)
+			VALUES ($1, $2, $3, NOW(), NOW())
+			RETURNING id
+		`, videoID, video.Quiz.Title, quizTagsJSON).Scan(&quizID)
+		if err != nil {
+			return fmt.Errorf("failed to seed quiz '%s': %w", video.Quiz.Title, err)
+		}
+	case err != nil:
+		return err
+	default:
+		_, err = tx.Exec("UPDATE quizzes SET title = $1, tags = $2, updated_at = NOW() WHERE id = $3", video.Quiz.Title, quizTagsJSON, quizID)
+		if err != nil {
+			return fmt.Errorf("failed to update quiz '%s': %w", video.Quiz.Title, err)
+		}
+		if _, err = tx.Exec("DELETE FROM quiz_questions WHERE quiz_id = $1", quizID); err != nil {
+			return err
+		}
 	}
 
+	// Insert questions
+	for _, q := range video.Quiz.Questions {
+		optionsJSON, _ := json.Marshal(q.Options)
+		qTagsJSON, _ := json.Marshal(q.Tags)
+		_, err = tx.Exec(`
+			INSERT INTO quiz_questions (quiz_id, question, options, correct_answer, tags, explanation, created_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NOW())
+		`, quizID, q.Question, optionsJSON, q.Correct, qTagsJSON, q.Explanation)
+		if err != nil {
+			return fmt.Errorf("failed to seed question for quiz '%s': %w", video.Quiz.Title, err)
+		}
+	}
 	return nil
 }
 
+// MissingSeedAssets lists the videos.json files that are not present in the assets directory
+func MissingSeedAssets(dir string) []string {
+	missing := []string{}
+	videosConfig, err := loadVideosConfig()
+	if err != nil {
+		return missing
+	}
+	for _, v := range videosConfig.Videos {
+		info, err := os.Stat(filepath.Join(dir, v.Filename))
+		if err != nil || info.IsDir() {
+			missing = append(missing, v.Filename)
+		}
+	}
+	return missing
+}
+
 // VideoConfig represents the structure of videos.json
 type VideoConfig struct {
 	Videos []VideoEntry `json:"videos"`
@@ -463,10 +1503,11 @@ type QuizEntry struct {
 }
 
 type QuestionEntry struct {
-	Question string   `json:"question"`
-	Options  []string `json:"options"`
-	Correct  int      `json:"correct"`
-	Tags     []string `json:"tags"`
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+	Correct     int      `json:"correct"`
+	Tags        []string `json:"tags"`
+	Explanation string   `json:"explanation,omitempty"`
 }
 
 // loadVideosConfig loads video configuration from embedded videos.json
@@ -485,142 +1526,92 @@ func loadVideosConfig() (*VideoConfig, error) {
 	return &config, nil
 }
 
-func seedDefaultQuizzes() error {
-	// Check if quizzes already exist
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM quizzes").Scan(&count)
-	if err != nil {
-		return err
-	}
+// defaultChecklistItem is a checklist item every new organization starts with
+type defaultChecklistItem struct {
+	title       string
+	description string
+}
 
-	if count > 0 {
-		log.Println("Quizzes already seeded, skipping...")
-		return nil
-	}
+var defaultPreStartItems = []defaultChecklistItem{
+	{"Vehicle Inspection", "Check all vehicle fluids, lights, brakes, and tires before operation"},
+	{"Communication Check", "Verify radio and communication equipment is functioning properly"},
+	{"Work Area Assessment", "Inspect work area for hazards, obstacles, and safe access routes"},
+}
 
-	// Load videos config which contains quiz data
-	videosConfig, err := loadVideosConfig()
-	if err != nil {
-		log.Printf("Warning: Could not load videos.json for quizzes: %v", err)
+var defaultPPEItems = []defaultChecklistItem{
+	{"Hard Hat", "Ensure hard hat is worn and in good condition with no cracks or damage"},
+	{"Safety Boots", "Steel-toe safety boots must be worn at all times in operational areas"},
+	{"High-Visibility Vest", "High-visibility reflective vest must be worn for visibility"},
+}
+
+// ProvisionOrganizationChecklists gives an organization (mining site) its own copy of the
+// default pre-start and PPE items, so each site can edit or remove them without affecting
+// others. It does nothing for sites that already have defaults.
+func ProvisionOrganizationChecklists(miningSite string) error {
+	if miningSite == "" {
 		return nil
 	}
-
-	log.Println("Seeding default quizzes from videos.json...")
-	quizCount := 0
-	for _, video := range videosConfig.Videos {
-		// Skip videos without quiz data
-		if video.Quiz == nil || len(video.Quiz.Questions) == 0 {
-			log.Printf("Skipping quiz for video '%s' - no quiz data defined", video.Title)
-			continue
-		}
-
-		// Get video ID by title
-		var videoID int
-		err := DB.QueryRow("SELECT id FROM video_modules WHERE title = $1", video.Title).Scan(&videoID)
+	for table, items := range map[string][]defaultChecklistItem{
+		"pre_start_checklist": defaultPreStartItems,
+		"ppe_checklist":       defaultPPEItems,
+	} {
+		var count int
+		err := DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE is_default = true AND LOWER(mining_site) = LOWER(TRIM($1))", miningSite).Scan(&count)
 		if err != nil {
-			log.Printf("Warning: Could not find video '%s', skipping quiz", video.Title)
-			continue
+			return err
 		}
-
-		// Build quiz tags from video tags
-		quizTags := append(video.Tags, video.Category, video.Language)
-		quizTagsJSON, _ := json.Marshal(quizTags)
-
-		// Insert quiz
-		var quizID int
-		err = DB.QueryRow(`
-			INSERT INTO quizzes (video_id, title, tags, created_at, updated_at)
-			VALUES ($1, $2, $3, NOW(), NOW())
-			RETURNING id
-		`, videoID, video.Quiz.Title, quizTagsJSON).Scan(&quizID)
-		if err != nil {
-			return fmt.Errorf("failed to seed quiz '%s': %w", video.Quiz.Title, err)
+		if count > 0 {
+			continue
 		}
-
-		// Insert questions
-		for _, q := range video.Quiz.Questions {
-			optionsJSON, _ := json.Marshal(q.Options)
-			qTagsJSON, _ := json.Marshal(q.Tags)
-			_, err = DB.Exec(`
-				INSERT INTO quiz_questions (quiz_id, question, options, correct_answer, tags, created_at)
-				VALUES ($1, $2, $3, $4, $5, NOW())
-			`, quizID, q.Question, optionsJSON, q.Correct, qTagsJSON)
+		for _, item := range items {
+			_, err := DB.Exec(`
+				INSERT INTO `+table+` (supervisor_id, mining_site, title, description, is_default, is_active, created_at, updated_at)
+				VALUES ('SYSTEM', $1, $2, $3, true, true, NOW(), NOW())
+			`, miningSite, item.title, item.description)
 			if err != nil {
-				return fmt.Errorf("failed to seed question for quiz '%s': %w", video.Quiz.Title, err)
+				return fmt.Errorf("failed to provision %s item '%s' for %s: %w", table, item.title, miningSite, err)
 			}
 		}
-
-		log.Printf("Seeded quiz: %s with %d questions (Language: %s)", video.Quiz.Title, len(video.Quiz.Questions), video.Language)
-		quizCount++
+		log.Printf("Provisioned %d default %s items for %s", len(items), table, miningSite)
 	}
-
-	log.Printf("Successfully seeded %d default quizzes from videos.json", quizCount)
 	return nil
 }
 
-func seedDefaultChecklists() error {
-	// Seed Pre-Start Checklist defaults
-	var preStartCount int
-	err := DB.QueryRow("SELECT COUNT(*) FROM pre_start_checklist WHERE is_default = true").Scan(&preStartCount)
+// migrateGlobalDefaultChecklists replaces the global SYSTEM defaults older databases were
+// seeded with by per-organization copies for every site in use. The global rows are only
+// deactivated so completion history that references them is kept.
+func migrateGlobalDefaultChecklists() error {
+	rows, err := DB.Query("SELECT DISTINCT mining_site FROM users WHERE COALESCE(mining_site, '') != ''")
 	if err != nil {
 		return err
 	}
-
-	if preStartCount == 0 {
-		preStartItems := []struct {
-			title       string
-			description string
-		}{
-			{"Vehicle Inspection", "Check all vehicle fluids, lights, brakes, and tires before operation"},
-			{"Communication Check", "Verify radio and communication equipment is functioning properly"},
-			{"Work Area Assessment", "Inspect work area for hazards, obstacles, and safe access routes"},
+	sites := []string{}
+	for rows.Next() {
+		var site string
+		if err := rows.Scan(&site); err == nil {
+			sites = append(sites, site)
 		}
-
-		for _, item := range preStartItems {
-			_, err := DB.Exec(`
-				INSERT INTO pre_start_checklist (supervisor_id, title, description, is_default, is_active, created_at, updated_at)
-				VALUES ('SYSTEM', $1, $2, true, true, NOW(), NOW())
-			`, item.title, item.description)
-			if err != nil {
-				return fmt.Errorf("failed to seed pre-start item '%s': %w", item.title, err)
-			}
-		}
-		log.Printf("Successfully seeded %d default pre-start checklist items", len(preStartItems))
-	} else {
-		log.Println("Pre-start checklist already seeded, skipping...")
 	}
+	rows.Close()
 
-	// Seed PPE Checklist defaults
-	var ppeCount int
-	err = DB.QueryRow("SELECT COUNT(*) FROM ppe_checklist WHERE is_default = true").Scan(&ppeCount)
-	if err != nil {
-		return err
+	for _, site := range sites {
+		if err := ProvisionOrganizationChecklists(site); err != nil {
+			return err
+		}
 	}
 
-	if ppeCount == 0 {
-		ppeItems := []struct {
-			title       string
-			description string
-		}{
-			{"Hard Hat", "Ensure hard hat is worn and in good condition with no cracks or damage"},
-			{"Safety Boots", "Steel-toe safety boots must be worn at all times in operational areas"},
-			{"High-Visibility Vest", "High-visibility reflective vest must be worn for visibility"},
+	for _, table := range []string{"pre_start_checklist", "ppe_checklist"} {
+		result, err := DB.Exec(`
+			UPDATE ` + table + ` SET is_active = false, updated_at = NOW()
+			WHERE is_default = true AND mining_site IS NULL AND is_active = true
+		`)
+		if err != nil {
+			return err
 		}
-
-		for _, item := range ppeItems {
-			_, err := DB.Exec(`
-				INSERT INTO ppe_checklist (supervisor_id, title, description, is_default, is_active, created_at, updated_at)
-				VALUES ('SYSTEM', $1, $2, true, true, NOW(), NOW())
-			`, item.title, item.description)
-			if err != nil {
-				return fmt.Errorf("failed to seed PPE item '%s': %w", item.title, err)
-			}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("Retired %d global default %s items", n, table)
 		}
-		log.Printf("Successfully seeded %d default PPE checklist items", len(ppeItems))
-	} else {
-		log.Println("PPE checklist already seeded, skipping...")
 	}
-
 	return nil
 }
 
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"MineSafeBackend/models"
+	"fmt"
+)
+
+// Allowed values enforced by CHECK constraints. Handlers validate too; the constraints stop
+// anything that slips past them (older app builds, manual SQL) from being stored.
+const (
+	userRoles           = `'SUPERVISOR', 'MINER', 'ADMIN', 'VISITOR', 'OPERATOR'`
+	emergencySeverities = `'LOW', 'MEDIUM', 'HIGH', 'CRITICAL'`
+	emergencyStatuses   = `'PENDING', 'RESOLVING', 'RESOLVED', 'CANCELLED'`
+	mediaStatuses       = `'SYNCED', 'PENDING_UPLOAD', 'NOT_APPLICABLE', 'UPLOAD_FAILED', 'UPLOAD_EXPIRED'`
+	approvalStatuses    = `'pending', 'approved', 'rejected', 'draft', 'scheduled', 'expired', 'removed'`
+	e164Phone           = `'^\+[1-9][0-9]{7,14}$'`
+)
+
+// constraintMigrations clean rows written before the constraints existed and then add them.
+// They run after the ALTER block so every column exists. A constraint whose legacy rows
+// could not all be repaired is still added NOT VALID, which enforces it for new writes,
+// and is validated on a later start once the data has been fixed.
+var constraintMigrations = []string{
+	// Roles: only case differences are repairable
+	`UPDATE users SET role = UPPER(role) WHERE role <> UPPER(role)`,
+	dropConstraintLacking("users", "users_role_check", "OPERATOR"),
+	addCheckConstraint("users", "users_role_check", "role IN ("+userRoles+")"),
+
+	// Emergencies: an unknown severity is treated as HIGH so nothing gets downplayed, and an
+	// unknown status goes back to PENDING so someone looks at it
+	`UPDATE emergencies SET severity = UPPER(TRIM(severity)) WHERE severity <> UPPER(TRIM(severity))`,
+	`UPDATE emergencies SET severity = 'HIGH' WHERE severity IS NULL OR severity NOT IN (` + emergencySeverities + `)`,
+	`UPDATE emergencies SET status = UPPER(TRIM(status)) WHERE status <> UPPER(TRIM(status))`,
+	`UPDATE emergencies SET status = 'PENDING' WHERE status IS NULL OR status NOT IN (` + emergencyStatuses + `)`,
+	`UPDATE emergencies SET media_status = CASE WHEN media_url IS NULL THEN 'NOT_APPLICABLE' ELSE 'SYNCED' END
+	 WHERE media_status IS NULL OR media_status NOT IN (` + mediaStatuses + `)`,
+	`ALTER TABLE emergencies ALTER COLUMN severity SET NOT NULL`,
+	`ALTER TABLE emergencies ALTER COLUMN status SET NOT NULL`,
+	`ALTER TABLE emergencies ALTER COLUMN media_status SET NOT NULL`,
+	addCheckConstraint("emergencies", "emergencies_severity_check", "severity IN ("+emergencySeverities+")"),
+	addCheckConstraint("emergencies", "emergencies_status_check", "status IN ("+emergencyStatuses+")"),
+	addCheckConstraint("emergencies", "emergencies_media_status_check", "media_status IN ("+mediaStatuses+")"),
+
+	// Modules: a missing status follows is_active, anything unknown needs review again
+	`UPDATE video_modules SET approval_status = CASE WHEN is_active THEN 'approved' ELSE 'pending' END
+	 WHERE approval_status IS NULL`,
+	`UPDATE video_modules SET approval_status = LOWER(approval_status) WHERE approval_status <> LOWER(approval_status)`,
+	`UPDATE video_modules SET approval_status = 'pending', is_active = false WHERE approval_status NOT IN (` + approvalStatuses + `)`,
+	`ALTER TABLE video_modules ALTER COLUMN approval_status SET DEFAULT 'approved'`,
+	`ALTER TABLE video_modules ALTER COLUMN approval_status SET NOT NULL`,
+	addCheckConstraint("video_modules", "video_modules_approval_status_check", "approval_status IN ("+approvalStatuses+")"),
+
+	// Zone allocations pointing at deleted zones are dropped; deleting a zone unallocates
+	`UPDATE users SET zone_id = NULL WHERE zone_id IS NOT NULL AND zone_id NOT IN (SELECT id FROM mine_zones)`,
+	addForeignKey("users", "users_zone_id_fkey", "zone_id", "mine_zones(id) ON DELETE SET NULL"),
+
+	// Emails are stored trimmed and lower-cased. Rows that would collide with another account
+	// once normalized are left alone so an admin can decide which one to keep.
+	`UPDATE users u SET email = LOWER(TRIM(u.email))
+	 WHERE u.email <> LOWER(TRIM(u.email))
+	   AND NOT EXISTS (SELECT 1 FROM users o WHERE o.id <> u.id AND LOWER(TRIM(o.email)) = LOWER(TRIM(u.email)))`,
+	addCheckConstraint("users", "users_email_normalized_check", "email = LOWER(TRIM(email))"),
+	addUniqueIndex("idx_users_email_lower", "users (LOWER(email))"),
+
+	// Phones are stored as E.164, following models.NormalizePhone, with the same rule for collisions
+	`DO $$ DECLARE r RECORD; normalized TEXT; BEGIN
+		FOR r IN SELECT id, phone FROM users WHERE phone IS NOT NULL AND phone !~ ` + e164Phone + ` LOOP
+			normalized := regexp_replace(r.phone, '[\s().-]', '', 'g');
+			normalized := CASE
+				WHEN normalized ~ '^00' THEN '+' || substr(normalized, 3)
+				WHEN normalized ~ '^[0-9]{10}$' THEN '+` + models.DefaultPhoneCountryCode + `' || normalized
+				WHEN normalized ~ '^0[0-9]{10}$' THEN '+` + models.DefaultPhoneCountryCode + `' || substr(normalized, 2)
+				WHEN normalized ~ '^` + models.DefaultPhoneCountryCode + `[0-9]{10}$' THEN '+' || normalized
+				ELSE normalized
+			END;
+			IF normalized <> r.phone AND NOT EXISTS (SELECT 1 FROM users WHERE phone = normalized AND normalized <> '') THEN
+				UPDATE users SET phone = normalized WHERE id = r.id;
+			END IF;
+		END LOOP;
+	END $$`,
+	addCheckConstraint("users", "users_phone_e164_check", "phone = '' OR phone ~ "+e164Phone),
+	addUniqueIndex("idx_users_phone", "users (phone) WHERE phone <> ''"),
+}
+
+// addCheckConstraint adds a CHECK constraint once, validating it when the data allows
+func addCheckConstraint(table, name, expr string) string {
+	return addConstraint(table, name, "CHECK ("+expr+")")
+}
+
+// addForeignKey adds a foreign key once, validating it when the data allows
+func addForeignKey(table, name, column, references string) string {
+	return addConstraint(table, name, "FOREIGN KEY ("+column+") REFERENCES "+references)
+}
+
+// dropConstraintLacking drops a constraint whose definition does not mention value, so a
+// constraint created before value was allowed is recreated by the addCheckConstraint after it.
+// Pass the most recently allowed value.
+func dropConstraintLacking(table, name, value string) string {
+	return fmt.Sprintf(`DO $$ BEGIN
+		IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%[2]s' AND pg_get_constraintdef(oid) NOT LIKE '%%''%[3]s''%%') THEN
+			ALTER TABLE %[1]s DROP CONSTRAINT %[2]s;
+		END IF;
+	END $$`, table, name, value)
+}
+
+// addUniqueIndex creates a unique index once. While duplicates remain it is skipped with a
+// warning and retried on the next start; ContactInUse keeps new duplicates out meanwhile.
+func addUniqueIndex(name, definition string) string {
+	return fmt.Sprintf(`DO $$ BEGIN
+		CREATE UNIQUE INDEX IF NOT EXISTS %[1]s ON %[2]s;
+	EXCEPTION WHEN unique_violation THEN
+		RAISE WARNING 'unique index %[1]s not created: existing rows contain duplicates';
+	END $$`, name, definition)
+}
+
+func addConstraint(table, name, definition string) string {
+	return fmt.Sprintf(`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%[2]s') THEN
+			ALTER TABLE %[1]s ADD CONSTRAINT %[2]s %[3]s NOT VALID;
+		END IF;
+		BEGIN
+			ALTER TABLE %[1]s VALIDATE CONSTRAINT %[2]s;
+		EXCEPTION WHEN check_violation OR foreign_key_violation THEN
+			RAISE WARNING 'constraint %[2]s is enforced for new rows but existing rows violate it';
+		END;
+	END $$`, table, name, definition)
+}
@@ -15,6 +15,7 @@ type User struct {
 	SupervisorID *string
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	IsActive     bool
 }
 
 type Supervisor struct {
@@ -29,6 +30,7 @@ type Supervisor struct {
 	Location   *string
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+	IsActive   bool
 }
 
 type MinerMetrics struct {
@@ -47,4 +49,5 @@ type Admin struct {
 	Role      string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	IsActive  bool
 }
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"MineSafeBackend/internal/passhash"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// DemoPassword is the password of every account created by SeedDemoData
+const DemoPassword = "minesafe-demo"
+
+// demoAdminEmail marks a database that already holds demo data
+const demoAdminEmail = "admin@demo.minesafe.app"
+
+type demoSite struct {
+	name       string
+	location   string
+	lat, lon   float64
+	supervisor string
+	zones      []string
+	miners     []string
+}
+
+var demoSites = []demoSite{
+	{
+		name:       "Jharia Coalfield",
+		location:   "Dhanbad, Jharkhand",
+		lat:        23.7479,
+		lon:        86.4143,
+		supervisor: "Rakesh Mahato",
+		zones:      []string{"Seam 12 North", "Haul Road", "Ventilation Shaft B"},
+		miners:     []string{"Anil Kumar", "Suresh Yadav", "Birsa Munda", "Pooja Kumari", "Manoj Singh", "Ravi Soren"},
+	},
+	{
+		name:       "Singrauli Opencast",
+		location:   "Singrauli, Madhya Pradesh",
+		lat:        24.1997,
+		lon:        82.6753,
+		supervisor: "Meena Sharma",
+		zones:      []string{"Bench 3", "Dragline Pit", "Crusher Plant"},
+		miners:     []string{"Deepak Verma", "Sunita Devi", "Imran Khan", "Vijay Patel", "Lakshmi Rao", "Arjun Gond"},
+	},
+}
+
+var demoIssues = []struct {
+	severity string
+	issue    string
+}{
+	{"CRITICAL", "Roof fall reported near the working face, one miner trapped"},
+	{"HIGH", "Methane reading above 1% at the return airway"},
+	{"HIGH", "Dumper brake failure on the haul road"},
+	{"MEDIUM", "Conveyor belt misalignment causing spillage"},
+	{"MEDIUM", "Water seepage increasing at the sump"},
+	{"LOW", "Damaged PPE reported at the lamp room"},
+	{"LOW", "Lighting failure along the travelling road"},
+}
+
+// SeedDemoData loads realistic demo data for staging environments: an admin, a supervisor,
+// zones and miners per site, plus a few weeks of PPE checks, module completions and
+// emergencies. It refuses to run twice on the same database.
+func SeedDemoData() error {
+	var exists bool
+	if err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", demoAdminEmail).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("demo data already loaded (%s exists)", demoAdminEmail)
+	}
+
+	hash, err := passhash.Hash(DemoPassword)
+	if err != nil {
+		return err
+	}
+
+	var videoIDs []int
+	rows, err := DB.Query("SELECT id FROM video_modules WHERE is_active = true ORDER BY id")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		videoIDs = append(videoIDs, id)
+	}
+	rows.Close()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A fixed seed keeps the generated history reproducible between fresh databases
+	rng := rand.New(rand.NewSource(42))
+	now := time.Now()
+
+	admin, err := insertDemoUser(tx, rng, "Demo Admin", demoAdminEmail, string(hash), "", "", models.RoleAdmin, nil)
+	if err != nil {
+		return err
+	}
+
+	for s, site := range demoSites {
+		supervisor, err := insertDemoUser(tx, rng, site.supervisor, fmt.Sprintf("supervisor%d@demo.minesafe.app", s+1),
+			string(hash), site.name, site.location, models.RoleSupervisor, nil)
+		if err != nil {
+			return err
+		}
+
+		var zoneIDs []int
+		for _, zone := range site.zones {
+			var zoneID int
+			err := tx.QueryRow(`
+				INSERT INTO mine_zones (name, location, capacity, mining_site, created_by)
+				VALUES ($1, $2, $3, $4, $5) RETURNING id
+			`, zone, site.location, 20+rng.Intn(30), site.name, admin.UserID).Scan(&zoneID)
+			if err != nil {
+				return fmt.Errorf("seeding zone %s: %w", zone, err)
+			}
+			zoneIDs = append(zoneIDs, zoneID)
+		}
+
+		var minerIDs []string
+		for m, name := range site.miners {
+			miner, err := insertDemoUser(tx, rng, name, fmt.Sprintf("miner%d.%d@demo.minesafe.app", s+1, m+1),
+				string(hash), site.name, site.location, models.RoleMiner, &supervisor.UserID)
+			if err != nil {
+				return err
+			}
+			minerIDs = append(minerIDs, miner.UserID)
+
+			if _, err := tx.Exec("UPDATE users SET zone_id = $1 WHERE user_id = $2", zoneIDs[m%len(zoneIDs)], miner.UserID); err != nil {
+				return err
+			}
+			_, err = tx.Exec(`
+				INSERT INTO miner_inductions (miner_id, status, documents_uploaded_at, medical_cleared_at,
+					modules_passed_at, ppe_issued_at, updated_by)
+				VALUES ($1, 'COMPLETE', $2, $2, $2, $2, $3)
+			`, miner.UserID, now.AddDate(0, -2, 0), supervisor.UserID)
+			if err != nil {
+				return fmt.Errorf("seeding induction for %s: %w", name, err)
+			}
+
+			if err := seedDemoActivity(tx, rng, miner, videoIDs, now); err != nil {
+				return err
+			}
+		}
+
+		// Emergencies spread over the last 60 days, older ones resolved
+		for e := 0; e < 8; e++ {
+			issue := demoIssues[rng.Intn(len(demoIssues))]
+			reportedAt := now.Add(-time.Duration(rng.Intn(60*24)) * time.Hour)
+			status := models.ResolutionComplete
+			var resolvedAt *time.Time
+			switch {
+			case now.Sub(reportedAt) < 48*time.Hour:
+				status = models.ResolutionPending
+			case now.Sub(reportedAt) < 96*time.Hour:
+				status = models.ResolutionActive
+			default:
+				t := reportedAt.Add(time.Duration(30+rng.Intn(240)) * time.Minute)
+				resolvedAt = &t
+			}
+
+			_, err := tx.Exec(`
+				INSERT INTO emergencies (user_id, emergency_id, severity, latitude, longitude, issue,
+					media_status, location, incident_time, reporting_time, status, resolution_time)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9, $10, $11)
+			`, minerIDs[rng.Intn(len(minerIDs))], e+1, issue.severity,
+				site.lat+rng.Float64()/100, site.lon+rng.Float64()/100, issue.issue,
+				models.StatusNotApplicable, site.location, reportedAt, status, resolvedAt)
+			if err != nil {
+				return fmt.Errorf("seeding emergency: %w", err)
+			}
+		}
+
+		log.Printf("Seeded demo site %s: %d zones, %d miners", site.name, len(zoneIDs), len(minerIDs))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, site := range demoSites {
+		if err := ProvisionOrganizationChecklists(site.name); err != nil {
+			return fmt.Errorf("provisioning checklists for %s: %w", site.name, err)
+		}
+	}
+
+	log.Printf("Demo data loaded. Admin: %s, supervisors: supervisor1@demo.minesafe.app, supervisor2@demo.minesafe.app, password: %s",
+		demoAdminEmail, DemoPassword)
+	return nil
+}
+
+func insertDemoUser(tx *sql.Tx, rng *rand.Rand, name, email, hash, miningSite, location string, role models.Role, supervisorID *string) (*models.User, error) {
+	user, err := models.NewUser(name, email, fmt.Sprintf("+91%010d", rng.Int63n(1e10)), hash, miningSite, location, role, supervisorID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, supervisor_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+	`, user.UserID, user.Name, user.Email, user.Phone, user.Password, user.Role,
+		user.MiningSite, user.Location, user.SupervisorID, user.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("seeding user %s: %w", email, err)
+	}
+	return user, nil
+}
+
+// seedDemoActivity gives a miner two weeks of PPE checks and some completed modules
+func seedDemoActivity(tx *sql.Tx, rng *rand.Rand, miner *models.User, videoIDs []int, now time.Time) error {
+	ppeItems := []string{"safety_helmet", "protective_gloves", "safety_shoes", "high_visibility_vest", "safety_goggles"}
+
+	for day := 0; day < 14; day++ {
+		// Miners skip the occasional day
+		if rng.Intn(5) == 0 {
+			continue
+		}
+		checklist := map[string]string{}
+		detected := 0
+		for _, item := range ppeItems {
+			checklist[item] = "no"
+			if rng.Intn(10) < 9 {
+				checklist[item] = "yes"
+				detected++
+			}
+		}
+		manualJSON, _ := json.Marshal(checklist)
+
+		_, err := tx.Exec(`
+			INSERT INTO ppe_stats (user_id, miner_name, date, safety_helmet, protective_gloves, safety_shoes,
+				high_visibility_vest, safety_goggles, manual_checklist, completion_percentage, items_detected, total_items)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, miner.UserID, miner.Name, now.AddDate(0, 0, -day).Format("2006-01-02"),
+			checklist["safety_helmet"], checklist["protective_gloves"], checklist["safety_shoes"],
+			checklist["high_visibility_vest"], checklist["safety_goggles"], manualJSON,
+			float64(detected)*100/float64(len(ppeItems)), detected, len(ppeItems))
+		if err != nil {
+			return fmt.Errorf("seeding PPE stats for %s: %w", miner.Name, err)
+		}
+	}
+
+	for _, videoID := range videoIDs {
+		if rng.Intn(3) == 0 {
+			continue
+		}
+		total := 5
+		_, err := tx.Exec(`
+			INSERT INTO module_completions (miner_id, video_id, completed_at, score, total_questions)
+			VALUES ($1, $2, $3, $4, $5)
+		`, miner.UserID, videoID, now.Add(-time.Duration(rng.Intn(30*24))*time.Hour), 3+rng.Intn(total-2), total)
+		if err != nil {
+			return fmt.Errorf("seeding module completion for %s: %w", miner.Name, err)
+		}
+	}
+
+	return nil
+}
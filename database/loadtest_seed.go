@@ -0,0 +1,88 @@
+package database
+
+import (
+	"MineSafeBackend/internal/passhash"
+	"MineSafeBackend/models"
+	"fmt"
+	"log"
+)
+
+// LoadTestPassword is the password of every miner created by SeedLoadTestMiners
+const LoadTestPassword = "minesafe-loadtest"
+
+const (
+	loadTestSite            = "Load Test Site"
+	loadTestSupervisorEmail = "supervisor@loadtest.minesafe.app"
+)
+
+// LoadTestMinerEmail is the login of the i-th load test miner
+func LoadTestMinerEmail(i int) string {
+	return fmt.Sprintf("miner%04d@loadtest.minesafe.app", i)
+}
+
+// SeedLoadTestMiners makes sure one supervisor and n miners exist at a dedicated load test
+// site, with that site's default checklists. Existing accounts are kept, so the seed can
+// be re-run with a larger n.
+func SeedLoadTestMiners(n int) error {
+	// One hash for everyone; hashing per miner would dominate seeding 500 accounts
+	hash, err := passhash.Hash(LoadTestPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var supervisorID string
+	err = tx.QueryRow("SELECT user_id FROM users WHERE email = $1", loadTestSupervisorEmail).Scan(&supervisorID)
+	if err != nil {
+		supervisor, err := models.NewUser("Load Test Supervisor", loadTestSupervisorEmail, "", string(hash),
+			loadTestSite, loadTestSite, models.RoleSupervisor, nil)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, supervisor_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULL, NOW(), NOW())
+		`, supervisor.UserID, supervisor.Name, supervisor.Email, supervisor.Phone, supervisor.Password, supervisor.Role,
+			supervisor.MiningSite, supervisor.Location)
+		if err != nil {
+			return fmt.Errorf("seeding load test supervisor: %w", err)
+		}
+		supervisorID = supervisor.UserID
+	}
+
+	created := 0
+	for i := 0; i < n; i++ {
+		miner, err := models.NewUser(fmt.Sprintf("Load Test Miner %d", i), LoadTestMinerEmail(i), "", string(hash),
+			loadTestSite, loadTestSite, models.RoleMiner, &supervisorID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, supervisor_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+			ON CONFLICT (email) DO NOTHING
+		`, miner.UserID, miner.Name, miner.Email, miner.Phone, miner.Password, miner.Role,
+			miner.MiningSite, miner.Location, supervisorID)
+		if err != nil {
+			return fmt.Errorf("seeding load test miner %d: %w", i, err)
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			created++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := ProvisionOrganizationChecklists(loadTestSite); err != nil {
+		return fmt.Errorf("provisioning load test checklists: %w", err)
+	}
+
+	log.Printf("Load test data ready: %d miners at %s (%d new), password: %s", n, loadTestSite, created, LoadTestPassword)
+	return nil
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// runMigrations takes whatever locks its statements need, which is fine for small tables.
+// On ppe_stats or module_completions an index build or a full-table UPDATE blocks app
+// writes for minutes, and since a new instance migrates while the old ones keep serving,
+// a deploy during a shift stalls the API. Changes to large tables are registered in
+// onlineMigrations instead and follow expand/contract:
+//
+//  1. Expand: quick, metadata-only DDL run at startup, e.g. AddColumnOnline. Code deployed
+//     with it writes the new column and copes with it being NULL on older rows.
+//  2. Indexes are built CONCURRENTLY and existing rows are backfilled in small batches, in
+//     the background on one instance. Progress is kept in online_migrations, so a restart
+//     resumes where it stopped.
+//  3. Contract: once both are done, the schema is tightened (SetNotNullOnline) or, in a
+//     later release that no longer reads it, the old column dropped (DropColumnOnline).
+//
+// DDL waits at most onlineLockTimeout for its lock and is retried, since an ALTER queued
+// behind a long query holds up every query on the table that comes after it.
+
+// Online migration states
+const (
+	OnlineMigrationPending     = "PENDING" // expanded; background work not started yet
+	OnlineMigrationIndexing    = "INDEXING"
+	OnlineMigrationBackfilling = "BACKFILLING"
+	OnlineMigrationContracting = "CONTRACTING"
+	OnlineMigrationDone        = "DONE"
+	OnlineMigrationFailed      = "FAILED" // resumed on the next start
+)
+
+const (
+	onlineLockTimeout        = 2 * time.Second
+	onlineDDLAttempts        = 10
+	defaultBackfillBatchSize = 1000
+	defaultBackfillPause     = 200 * time.Millisecond
+	backfillProgressInterval = 30 * time.Second
+)
+
+// OnlineMigration is a change to a large table made without blocking the API
+type OnlineMigration struct {
+	Name     string // recorded in online_migrations; never rename one that has run
+	Expand   []string
+	Indexes  []OnlineIndex
+	Backfill *Backfill
+	Contract []string
+}
+
+// OnlineIndex is built with CREATE INDEX CONCURRENTLY; Definition is what follows ON
+type OnlineIndex struct {
+	Name       string
+	Definition string
+}
+
+// Backfill fills existing rows of a table with an integer id primary key, in id order.
+// Where selects the rows still to fill, e.g. "passed IS NULL", so it can be rerun safely.
+type Backfill struct {
+	Table     string
+	Set       string // the SET clause, e.g. "passed = score * 100 >= total_questions * 80"
+	Where     string
+	BatchSize int           // rows per UPDATE; defaultBackfillBatchSize when zero
+	Pause     time.Duration // between batches; defaultBackfillPause when zero
+}
+
+var onlineMigrations = []OnlineMigration{
+	{
+		// Supervisor dashboards: per-miner completion history and recent PPE stats
+		Name: "dashboard_history_indexes",
+		Indexes: []OnlineIndex{
+			{"idx_module_completions_miner_completed", "module_completions(miner_id, completed_at) INCLUDE (video_id, score, total_questions)"},
+			{"idx_ppe_stats_user_recent", "ppe_stats(user_id, date DESC, created_at DESC)"},
+		},
+	},
+}
+
+// AddColumnOnline adds a column. Without a default, or with a constant one, PostgreSQL only
+// changes the catalog; a volatile default such as NOW() rewrites the table and has to be
+// backfilled instead.
+func AddColumnOnline(table, column, definition string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, definition)
+}
+
+// SetNotNullOnline makes a backfilled column NOT NULL. The rows are checked through a
+// NOT VALID constraint validated without blocking writes, which SET NOT NULL then relies on
+// instead of scanning the table under an exclusive lock.
+func SetNotNullOnline(table, column string) []string {
+	name := table + "_" + column + "_not_null"
+	return []string{
+		fmt.Sprintf(`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%[2]s') THEN
+				ALTER TABLE %[1]s ADD CONSTRAINT %[2]s CHECK (%[3]s IS NOT NULL) NOT VALID;
+			END IF;
+		END $$`, table, name, column),
+		fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", table, name),
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, column),
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", table, name),
+	}
+}
+
+// DropColumnOnline drops a column once no deployed code reads it
+func DropColumnOnline(table, column string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", table, column)
+}
+
+// expandOnlineMigrations registers the online migrations and runs their expand steps. It
+// runs at the end of runMigrations, so the tables exist.
+func expandOnlineMigrations() error {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, m := range onlineMigrations {
+		var status string
+		err := conn.QueryRowContext(ctx, `
+			INSERT INTO online_migrations (name, status) VALUES ($1, $2)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING status
+		`, m.Name, OnlineMigrationPending).Scan(&status)
+		if err != nil {
+			return err
+		}
+		if status == OnlineMigrationDone {
+			continue
+		}
+		for _, stmt := range m.Expand {
+			if err := execOnlineDDL(ctx, conn, stmt); err != nil {
+				return fmt.Errorf("online migration %s: %w\nSQL: %s", m.Name, err, stmt)
+			}
+		}
+	}
+	return nil
+}
+
+// StartOnlineMigrations builds indexes, backfills and contracts the pending online
+// migrations in the background, one at a time in the order they're registered
+func StartOnlineMigrations() {
+	go func() {
+		for _, m := range onlineMigrations {
+			runOnlineMigration(m)
+		}
+	}()
+}
+
+// runOnlineMigration runs one migration's background steps unless it's done or another
+// instance is running it. The advisory lock is held by the connection, so it's released if
+// the instance dies.
+func runOnlineMigration(m OnlineMigration) {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		log.Printf("Warning: online migration %s not started: %v", m.Name, err)
+		return
+	}
+	defer conn.Close()
+
+	var locked bool
+	lockKey := "online_migration:" + m.Name
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", lockKey).Scan(&locked); err != nil || !locked {
+		return
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", lockKey)
+
+	var status string
+	conn.QueryRowContext(ctx, "SELECT status FROM online_migrations WHERE name = $1", m.Name).Scan(&status)
+	if status == OnlineMigrationDone {
+		return
+	}
+
+	log.Printf("Online migration %s started", m.Name)
+	if err := m.run(ctx, conn); err != nil {
+		log.Printf("Warning: online migration %s failed: %v", m.Name, err)
+		message := err.Error()
+		if len(message) > 2000 {
+			message = message[:2000]
+		}
+		DB.Exec(`
+			UPDATE online_migrations SET status = $2, error = $3, updated_at = NOW() WHERE name = $1
+		`, m.Name, OnlineMigrationFailed, message)
+		return
+	}
+	DB.Exec(`
+		UPDATE online_migrations SET status = $2, error = NULL, updated_at = NOW(), finished_at = NOW()
+		WHERE name = $1
+	`, m.Name, OnlineMigrationDone)
+	log.Printf("Online migration %s done", m.Name)
+}
+
+func (m OnlineMigration) run(ctx context.Context, conn *sql.Conn) error {
+	setStatus := func(status string) {
+		DB.Exec("UPDATE online_migrations SET status = $2, updated_at = NOW() WHERE name = $1", m.Name, status)
+	}
+
+	if len(m.Indexes) > 0 {
+		setStatus(OnlineMigrationIndexing)
+		for _, idx := range m.Indexes {
+			if err := buildIndexOnline(ctx, conn, idx); err != nil {
+				return fmt.Errorf("index %s: %w", idx.Name, err)
+			}
+		}
+	}
+	if m.Backfill != nil {
+		setStatus(OnlineMigrationBackfilling)
+		if err := m.Backfill.run(ctx, conn, m.Name); err != nil {
+			return fmt.Errorf("backfill of %s: %w", m.Backfill.Table, err)
+		}
+	}
+	if len(m.Contract) > 0 {
+		setStatus(OnlineMigrationContracting)
+		for _, stmt := range m.Contract {
+			if err := execOnlineDDL(ctx, conn, stmt); err != nil {
+				return fmt.Errorf("%w\nSQL: %s", err, stmt)
+			}
+		}
+	}
+	return nil
+}
+
+// execOnlineDDL runs a statement with onlineLockTimeout, retrying with backoff while the
+// lock isn't available
+func execOnlineDDL(ctx context.Context, conn *sql.Conn, stmt string) error {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", onlineLockTimeout.Milliseconds())); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "RESET lock_timeout")
+
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		_, err := conn.ExecContext(ctx, stmt)
+		if !lockNotAvailable(err) || attempt == onlineDDLAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func lockNotAvailable(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "55P03"
+}
+
+// buildIndexOnline builds an index without blocking writes. An interrupted concurrent build
+// leaves an invalid index behind, which is dropped and built again.
+func buildIndexOnline(ctx context.Context, conn *sql.Conn, idx OnlineIndex) error {
+	var valid bool
+	err := conn.QueryRowContext(ctx, "SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1)", idx.Name).Scan(&valid)
+	if err == nil && valid {
+		return nil
+	}
+	if err == nil {
+		if _, err := conn.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+idx.Name); err != nil {
+			return err
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "CREATE INDEX CONCURRENTLY IF NOT EXISTS "+idx.Name+" ON "+idx.Definition)
+	return err
+}
+
+// run updates the rows still to fill in batches of BatchSize, each its own short
+// transaction, recording the last id after every batch
+func (b *Backfill) run(ctx context.Context, conn *sql.Conn, name string) error {
+	batchSize, pause := b.BatchSize, b.Pause
+	if batchSize <= 0 {
+		batchSize = defaultBackfillBatchSize
+	}
+	if pause <= 0 {
+		pause = defaultBackfillPause
+	}
+
+	var lastID, done int64
+	var total sql.NullInt64
+	err := conn.QueryRowContext(ctx, `
+		SELECT last_id, rows_done, rows_total FROM online_migrations WHERE name = $1
+	`, name).Scan(&lastID, &done, &total)
+	if err != nil {
+		return err
+	}
+	if !total.Valid {
+		// Counted on the first run only; a resumed backfill keeps its original total
+		if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+b.Table+" WHERE "+b.Where).Scan(&total.Int64); err != nil {
+			return err
+		}
+		DB.Exec("UPDATE online_migrations SET rows_total = $2 WHERE name = $1", name, total.Int64)
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", onlineLockTimeout.Milliseconds())); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "RESET lock_timeout")
+
+	update := fmt.Sprintf(`
+		UPDATE %[1]s SET %[2]s
+		WHERE id IN (SELECT id FROM %[1]s WHERE id > $1 AND (%[3]s) ORDER BY id LIMIT $2)
+		RETURNING id
+	`, b.Table, b.Set, b.Where)
+	lastLogged := time.Now()
+	for {
+		n, maxID, err := backfillBatch(ctx, conn, update, lastID, batchSize)
+		if lockNotAvailable(err) {
+			time.Sleep(10 * pause)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		lastID, done = maxID, done+n
+		DB.Exec(`
+			UPDATE online_migrations SET last_id = $2, rows_done = $3, updated_at = NOW() WHERE name = $1
+		`, name, lastID, done)
+
+		if time.Since(lastLogged) >= backfillProgressInterval {
+			log.Printf("Online migration %s: backfilled %d of about %d rows of %s", name, done, total.Int64, b.Table)
+			lastLogged = time.Now()
+		}
+		time.Sleep(pause)
+	}
+}
+
+// backfillBatch runs one backfill UPDATE, returning how many rows it changed and the highest id
+func backfillBatch(ctx context.Context, conn *sql.Conn, update string, afterID int64, limit int) (int64, int64, error) {
+	rows, err := conn.QueryContext(ctx, update, afterID, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var n, maxID int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, 0, err
+		}
+		n++
+		if id > maxID {
+			maxID = id
+		}
+	}
+	return n, maxID, rows.Err()
+}
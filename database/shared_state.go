@@ -0,0 +1,91 @@
+package database
+
+import (
+	"MineSafeBackend/config"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SharedCounters keeps fixed-window counters in Postgres so every instance behind the load
+// balancer sees the same rate limit and login failure counts. It satisfies
+// middleware.SharedCounterStore.
+type SharedCounters struct{}
+
+// Incr adds one to key, starting a new window of the given length if the current one has
+// ended, and returns the count and when the window ends
+func (SharedCounters) Incr(key string, window time.Duration) (int, time.Time, error) {
+	var count int
+	var expiresAt time.Time
+	err := DB.QueryRow(`
+		INSERT INTO shared_counters (key, count, expires_at)
+		VALUES ($1, 1, NOW() + $2 * INTERVAL '1 second')
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN shared_counters.expires_at <= NOW() THEN 1 ELSE shared_counters.count + 1 END,
+			expires_at = CASE WHEN shared_counters.expires_at <= NOW() THEN EXCLUDED.expires_at ELSE shared_counters.expires_at END
+		RETURNING count, expires_at
+	`, key, window.Seconds()).Scan(&count, &expiresAt)
+	return count, expiresAt, err
+}
+
+// Get returns the count in key's current window, or 0 if the window has ended
+func (SharedCounters) Get(key string) (int, time.Time, error) {
+	var count int
+	var expiresAt time.Time
+	err := DB.QueryRow("SELECT count, expires_at FROM shared_counters WHERE key = $1 AND expires_at > NOW()", key).Scan(&count, &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, nil
+	}
+	return count, expiresAt, err
+}
+
+// Reset clears key
+func (SharedCounters) Reset(key string) error {
+	_, err := DB.Exec("DELETE FROM shared_counters WHERE key = $1", key)
+	return err
+}
+
+// PurgeExpiredCounters deletes counters whose window has ended
+func PurgeExpiredCounters() (int64, error) {
+	result, err := DB.Exec("DELETE FROM shared_counters WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Publish sends payload to every instance listening on channel (Postgres NOTIFY)
+func Publish(channel, payload string) error {
+	_, err := DB.Exec("SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+// Listen calls handle for every payload published on channel, including by this instance.
+// The listener reconnects on its own; after a reconnect notifications may have been missed,
+// so handle is called with an empty payload to let the caller resynchronise.
+func Listen(channel string, handle func(payload string)) error {
+	listener := pq.NewListener(config.Get().DatabaseDSN(), time.Second, time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("Warning: listener on %s: %v", channel, err)
+			}
+		})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		for n := range listener.Notify {
+			if n == nil {
+				// Connection was re-established
+				handle("")
+				continue
+			}
+			handle(n.Extra)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,284 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// siteTables carry both a site_id and the older mining_site string. The string stays because
+// most queries still compare it; a trigger keeps the two in step and stores the site's
+// canonical name, and RenameSite rewrites it so a rename never splits a site in two.
+var siteTables = []string{
+	"users",
+	"mine_zones",
+	"siren_endpoints",
+	"shift_windows",
+	"emergency_contacts",
+	"wearable_devices",
+	"pre_start_checklist",
+	"ppe_checklist",
+	"daily_compliance_summary",
+}
+
+// ErrSiteNameTaken is returned when a site is created or renamed to a name already in use
+var ErrSiteNameTaken = errors.New("site name already in use")
+
+// Site is a mining site. Users, zones, shift windows, sirens, default checklists and
+// modules reference it by id.
+type Site struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Location  string    `json:"location"`
+	OrgID     int       `json:"org_id"`
+	Users     int       `json:"users"`
+	Zones     int       `json:"zones"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// siteMigrations create the sites table, point every mining_site column at it and backfill
+// ids from the strings already stored. Strings that differ only in case or surrounding
+// whitespace become one site. Run after the constraint migrations so every column exists.
+//
+// Each site belongs to an organization, the tenant boundary. A site first seen through a
+// mining_site string starts its own organization; admins add further sites to theirs. Rows
+// in siteTables take org_id from their site, and modules and emergencies from their author.
+func siteMigrations() []string {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS organizations (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Branding applied to the documents and emails generated for the organization
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS logo_url TEXT`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS primary_color VARCHAR(7)`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS accent_color VARCHAR(7)`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS email_footer TEXT`,
+		`CREATE TABLE IF NOT EXISTS sites (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			location TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sites_name_lower ON sites(LOWER(name))`,
+		`ALTER TABLE sites ADD COLUMN IF NOT EXISTS org_id INTEGER REFERENCES organizations(id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sites_org ON sites(org_id)`,
+
+		// A site created without an organization gets one of its own, named after it
+		`CREATE OR REPLACE FUNCTION site_org_id() RETURNS TRIGGER AS $$
+		BEGIN
+			IF NEW.org_id IS NULL THEN
+				INSERT INTO organizations (name) VALUES (NEW.name) RETURNING id INTO NEW.org_id;
+			END IF;
+			RETURN NEW;
+		END $$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS sites_org_id ON sites`,
+		`CREATE TRIGGER sites_org_id BEFORE INSERT ON sites
+		 FOR EACH ROW EXECUTE FUNCTION site_org_id()`,
+		// Backfill: the UPDATE fires no trigger, so existing sites get their organization here
+		`DO $$ DECLARE s RECORD; oid INTEGER;
+		BEGIN
+			FOR s IN SELECT id, name FROM sites WHERE org_id IS NULL LOOP
+				INSERT INTO organizations (name) VALUES (s.name) RETURNING id INTO oid;
+				UPDATE sites SET org_id = oid WHERE id = s.id;
+			END LOOP;
+		END $$`,
+
+		// site_id_for returns the site with this name, creating it on first use
+		`CREATE OR REPLACE FUNCTION site_id_for(site_name TEXT) RETURNS INTEGER AS $$
+		DECLARE sid INTEGER;
+		BEGIN
+			IF site_name IS NULL OR TRIM(site_name) = '' THEN
+				RETURN NULL;
+			END IF;
+			SELECT id INTO sid FROM sites WHERE LOWER(name) = LOWER(TRIM(site_name));
+			IF sid IS NULL THEN
+				INSERT INTO sites (name) VALUES (TRIM(site_name)) ON CONFLICT DO NOTHING RETURNING id INTO sid;
+				IF sid IS NULL THEN
+					SELECT id INTO sid FROM sites WHERE LOWER(name) = LOWER(TRIM(site_name));
+				END IF;
+			END IF;
+			RETURN sid;
+		END $$ LANGUAGE plpgsql`,
+
+		// sync_site_id resolves site_id and org_id whenever mining_site is written and stores
+		// the site's canonical name back into mining_site. A row left without a site keeps its
+		// organization, so admins who aren't posted to a site stay in theirs.
+		`CREATE OR REPLACE FUNCTION sync_site_id() RETURNS TRIGGER AS $$
+		BEGIN
+			NEW.site_id := site_id_for(NEW.mining_site);
+			IF NEW.site_id IS NOT NULL THEN
+				SELECT name, org_id INTO NEW.mining_site, NEW.org_id FROM sites WHERE id = NEW.site_id;
+			END IF;
+			RETURN NEW;
+		END $$ LANGUAGE plpgsql`,
+
+		// Modules have no site of their own; they belong to their creator's site
+		`ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS site_id INTEGER REFERENCES sites(id) ON DELETE SET NULL`,
+		`ALTER TABLE video_modules ADD COLUMN IF NOT EXISTS org_id INTEGER REFERENCES organizations(id)`,
+		`CREATE OR REPLACE FUNCTION module_site_id() RETURNS TRIGGER AS $$
+		BEGIN
+			IF NEW.site_id IS NULL THEN
+				SELECT site_id, org_id INTO NEW.site_id, NEW.org_id FROM users WHERE user_id = NEW.created_by;
+			END IF;
+			RETURN NEW;
+		END $$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS video_modules_site_id ON video_modules`,
+		`CREATE TRIGGER video_modules_site_id BEFORE INSERT ON video_modules
+		 FOR EACH ROW EXECUTE FUNCTION module_site_id()`,
+
+		// Emergencies belong to the reporter's organization
+		`ALTER TABLE emergencies ADD COLUMN IF NOT EXISTS org_id INTEGER REFERENCES organizations(id)`,
+		`CREATE OR REPLACE FUNCTION emergency_org_id() RETURNS TRIGGER AS $$
+		BEGIN
+			IF NEW.org_id IS NULL THEN
+				SELECT org_id INTO NEW.org_id FROM users WHERE user_id = NEW.user_id;
+			END IF;
+			RETURN NEW;
+		END $$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS emergencies_org_id ON emergencies`,
+		`CREATE TRIGGER emergencies_org_id BEFORE INSERT ON emergencies
+		 FOR EACH ROW EXECUTE FUNCTION emergency_org_id()`,
+	}
+
+	for _, table := range siteTables {
+		migrations = append(migrations,
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS site_id INTEGER REFERENCES sites(id) ON DELETE SET NULL`, table),
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS org_id INTEGER REFERENCES organizations(id)`, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_site_id ON %s(site_id)`, table, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_org_id ON %s(org_id)`, table, table),
+			fmt.Sprintf(`DROP TRIGGER IF EXISTS %s_site_id ON %s`, table, table),
+			fmt.Sprintf(`CREATE TRIGGER %s_site_id BEFORE INSERT OR UPDATE OF mining_site ON %s
+			 FOR EACH ROW EXECUTE FUNCTION sync_site_id()`, table, table),
+			// Backfill: the UPDATE names mining_site, so the trigger fills site_id and
+			// canonicalizes the string
+			fmt.Sprintf(`UPDATE %s SET mining_site = mining_site
+			 WHERE site_id IS NULL AND TRIM(COALESCE(mining_site, '')) <> ''`, table),
+			fmt.Sprintf(`UPDATE %s t SET org_id = s.org_id
+			 FROM sites s WHERE s.id = t.site_id AND t.org_id IS DISTINCT FROM s.org_id`, table),
+		)
+	}
+
+	return append(migrations,
+		`UPDATE video_modules m SET site_id = u.site_id
+		 FROM users u WHERE m.created_by = u.user_id AND m.site_id IS NULL AND u.site_id IS NOT NULL`,
+		`UPDATE video_modules m SET org_id = s.org_id
+		 FROM sites s WHERE s.id = m.site_id AND m.org_id IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_site_id ON video_modules(site_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_modules_org_id ON video_modules(org_id)`,
+		`UPDATE emergencies e SET org_id = u.org_id
+		 FROM users u WHERE u.user_id = e.user_id AND e.org_id IS NULL AND u.org_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_emergencies_org_id ON emergencies(org_id, reporting_time)`,
+		// Admin codes register admins into the organization that issued them. Existing codes
+		// take their creator's organization once, when the column is added.
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+			               WHERE table_name = 'admin_codes' AND column_name = 'org_id') THEN
+				ALTER TABLE admin_codes ADD COLUMN org_id INTEGER REFERENCES organizations(id);
+				UPDATE admin_codes c SET org_id = u.org_id FROM users u WHERE u.user_id = c.created_by;
+			END IF;
+		END $$`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_codes_org_id ON admin_codes(org_id)`,
+	)
+}
+
+// GetSites lists every site with how many users and zones reference it
+func GetSites(orgID int) ([]Site, error) {
+	rows, err := DB.Query(`
+		SELECT s.id, s.name, s.location, COALESCE(s.org_id, 0),
+		       (SELECT COUNT(*) FROM users u WHERE u.site_id = s.id),
+		       (SELECT COUNT(*) FROM mine_zones z WHERE z.site_id = s.id),
+		       s.created_at, s.updated_at
+		FROM sites s
+		WHERE $1 = 0 OR s.org_id = $1
+		ORDER BY s.name
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sites := []Site{}
+	for rows.Next() {
+		var s Site
+		if err := rows.Scan(&s.ID, &s.Name, &s.Location, &s.OrgID, &s.Users, &s.Zones, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// CreateSite adds a site to an organization ahead of anyone being assigned to it. With
+// orgID 0 the site starts an organization of its own.
+func CreateSite(name, location string, orgID int) (int, error) {
+	var id int
+	err := DB.QueryRow(`
+		INSERT INTO sites (name, location, org_id) VALUES ($1, $2, NULLIF($3, 0))
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`, strings.TrimSpace(name), location, orgID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrSiteNameTaken
+	}
+	return id, err
+}
+
+// RenameSite changes a site's name and location. Every mining_site string that refers to it
+// is rewritten in the same transaction, along with feature flag and experiment targeting,
+// so queries that still match on the string keep finding the site's rows.
+func RenameSite(id int, name, location string) error {
+	name = strings.TrimSpace(name)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRow("SELECT name FROM sites WHERE id = $1 FOR UPDATE", id).Scan(&oldName)
+	if err != nil {
+		return err
+	}
+
+	var taken bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM sites WHERE LOWER(name) = LOWER($1) AND id <> $2)", name, id).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSiteNameTaken
+	}
+
+	if _, err := tx.Exec("UPDATE sites SET name = $1, location = $2, updated_at = NOW() WHERE id = $3", name, location, id); err != nil {
+		return err
+	}
+
+	if name != oldName {
+		for _, table := range siteTables {
+			if _, err := tx.Exec("UPDATE "+table+" SET mining_site = $1 WHERE site_id = $2", name, id); err != nil {
+				return fmt.Errorf("failed to rename site in %s: %w", table, err)
+			}
+		}
+		for _, table := range []string{"feature_flags", "experiments"} {
+			_, err := tx.Exec(`
+				UPDATE `+table+` SET mining_sites = (
+					SELECT COALESCE(jsonb_agg(CASE WHEN LOWER(s) = LOWER($1::text) THEN $2::text ELSE s END), '[]'::jsonb)
+					FROM jsonb_array_elements_text(mining_sites) AS s
+				)
+				WHERE EXISTS (SELECT 1 FROM jsonb_array_elements_text(mining_sites) AS s WHERE LOWER(s) = LOWER($1::text))
+			`, oldName, name)
+			if err != nil {
+				return fmt.Errorf("failed to rename site in %s: %w", table, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
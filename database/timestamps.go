@@ -0,0 +1,25 @@
+package database
+
+// timestampMigrations move databases created before the schema used TIMESTAMPTZ onto it.
+// Deployments run the server and database in UTC, so existing values are read as UTC.
+// video_trending reads some of these columns and has to be dropped for the change, then is
+// rebuilt; on an up-to-date schema nothing happens.
+var timestampMigrations = []string{
+	`DO $$ DECLARE c RECORD; BEGIN
+		FOR c IN
+			SELECT col.table_name, col.column_name
+			FROM information_schema.columns col
+			JOIN information_schema.tables t ON t.table_schema = col.table_schema AND t.table_name = col.table_name
+			WHERE col.table_schema = current_schema()
+			  AND t.table_type = 'BASE TABLE'
+			  AND col.data_type = 'timestamp without time zone'
+		LOOP
+			DROP MATERIALIZED VIEW IF EXISTS video_trending;
+			EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMPTZ USING %I AT TIME ZONE ''UTC''',
+				c.table_name, c.column_name, c.column_name);
+			RAISE NOTICE 'converted %.% to TIMESTAMPTZ', c.table_name, c.column_name;
+		END LOOP;
+	END $$`,
+	videoTrendingView,
+	videoTrendingIndex,
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"MineSafeBackend/models"
 	"context"
 	"database/sql"
 	"errors"
@@ -36,8 +37,9 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 	if DB == nil {
 		return nil, errors.New("database not initialized")
 	}
+	email = models.NormalizeEmail(email)
 	switch role {
-	case "MINER":
+	case "MINER", "OPERATOR":
 		const query = `
 					SELECT 
 						id,
@@ -51,10 +53,12 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 						location,
 						supervisor_id,
 						created_at,
-						updated_at
+						updated_at,
+						COALESCE(is_active, true)
 					FROM users
-					WHERE LOWER(email) = LOWER($1)
+					WHERE LOWER(email) = $1
 					AND role = $2
+					AND deleted_at IS NULL
 					LIMIT 1
 				`
 
@@ -72,6 +76,7 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 			&u.SupervisorID,
 			&u.CreatedAt,
 			&u.UpdatedAt,
+			&u.IsActive,
 		)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
@@ -93,10 +98,12 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 						mining_site,
 						location,
 						created_at,
-						updated_at
+						updated_at,
+						COALESCE(is_active, true)
 					FROM users
-					WHERE LOWER(email) = LOWER($1)
+					WHERE LOWER(email) = $1
 					AND role = 'SUPERVISOR'
+					AND deleted_at IS NULL
 					LIMIT 1
 				`
 
@@ -113,6 +120,7 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 			&s.Location,
 			&s.CreatedAt,
 			&s.UpdatedAt,
+			&s.IsActive,
 		)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
@@ -132,10 +140,12 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 						password,
 						role,
 						created_at,
-						updated_at
+						updated_at,
+						COALESCE(is_active, true)
 					FROM users
-					WHERE LOWER(email) = LOWER($1)
+					WHERE LOWER(email) = $1
 					AND role = 'ADMIN'
+					AND deleted_at IS NULL
 					LIMIT 1
 				`
 
@@ -150,6 +160,7 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 			&a.Role,
 			&a.CreatedAt,
 			&a.UpdatedAt,
+			&a.IsActive,
 		)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
@@ -161,3 +172,47 @@ func GetUserByEmail(ctx context.Context, email string, role string) (interface{}
 	}
 	return nil, fmt.Errorf("invalid role")
 }
+
+// ContactInUse reports whether a normalized email or phone already belongs to a user other
+// than exceptUserID, returning "email", "phone" or "" when both are free. Empty values are
+// not checked.
+func ContactInUse(email, phone, exceptUserID string) (string, error) {
+	if DB == nil {
+		return "", errors.New("database not initialized")
+	}
+	const query = `
+        SELECT
+            EXISTS(SELECT 1 FROM users WHERE $1 <> '' AND LOWER(email) = $1 AND user_id <> $3),
+            EXISTS(SELECT 1 FROM users WHERE $2 <> '' AND phone = $2 AND user_id <> $3)
+    `
+	var emailTaken, phoneTaken bool
+	if err := DB.QueryRow(query, email, phone, exceptUserID).Scan(&emailTaken, &phoneTaken); err != nil {
+		return "", fmt.Errorf("ContactInUse query failed: %w", err)
+	}
+	switch {
+	case emailTaken:
+		return "email", nil
+	case phoneTaken:
+		return "phone", nil
+	}
+	return "", nil
+}
+
+// ContactIssues counts what kept the email and phone cleanup from finishing: emails shared by
+// several accounts once normalized, duplicate phones, and phones that are not E.164.
+func ContactIssues() (duplicateEmails, duplicatePhones, invalidPhones int, err error) {
+	if DB == nil {
+		return 0, 0, 0, errors.New("database not initialized")
+	}
+	const query = `
+        SELECT
+            (SELECT COUNT(*) FROM (SELECT 1 FROM users GROUP BY LOWER(TRIM(email)) HAVING COUNT(*) > 1) d),
+            (SELECT COUNT(*) FROM (SELECT 1 FROM users WHERE phone <> '' GROUP BY phone HAVING COUNT(*) > 1) d),
+            (SELECT COUNT(*) FROM users WHERE phone <> '' AND phone !~ '^\+[1-9][0-9]{7,14}$')
+    `
+	err = DB.QueryRow(query).Scan(&duplicateEmails, &duplicatePhones, &invalidPhones)
+	if err != nil {
+		err = fmt.Errorf("ContactIssues query failed: %w", err)
+	}
+	return duplicateEmails, duplicatePhones, invalidPhones, err
+}
@@ -11,3 +11,5 @@ require (
 	github.com/rs/cors v1.11.1
 	golang.org/x/crypto v0.18.0
 )
+
+require golang.org/x/sys v0.16.0 // indirect
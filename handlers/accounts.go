@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== ACCOUNT DEACTIVATION ====================
+
+// Users are never removed from the database: completions, emergencies and audit records
+// keep pointing at them. A deactivated account can't log in but still shows up in admin
+// listings; a deleted one (deleted_at set) is also hidden everywhere else. Reactivating
+// brings back either kind.
+
+// softDeleteUser marks the user matched by where as deleted and ends their sessions. The
+// condition uses placeholders from $1 on, filled by args. It reports false when no such
+// user exists or they were already deleted.
+func softDeleteUser(deletedBy, where string, args ...interface{}) (bool, error) {
+	args = append(args, deletedBy)
+	var userID string
+	err := database.DB.QueryRow(`
+		UPDATE users SET deleted_at = NOW(), deleted_by = $`+strconv.Itoa(len(args))+`, is_active = false, updated_at = NOW()
+		WHERE deleted_at IS NULL AND `+where+`
+		RETURNING user_id`, args...).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := revokeUserTokens(userID, deletedBy, "account_deleted", time.Now()); err != nil {
+		log.Printf("Warning: failed to revoke sessions of deleted user %s: %v", userID, err)
+	}
+	log.Printf("User %s deleted by %s", userID, deletedBy)
+	return true, nil
+}
+
+// accountStatus is the status shown for an account in admin listings
+func accountStatus(active bool) string {
+	if active {
+		return "active"
+	}
+	return "inactive"
+}
+
+// setUserActive switches an account in the caller's organization on or off. Reactivating
+// also restores a deleted account.
+func setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	userID := mux.Vars(r)["id"]
+	if userID == adminID {
+		respondWithError(w, http.StatusBadRequest, "You cannot change the status of your own account")
+		return
+	}
+
+	args := []interface{}{userID, active}
+	scope := tenantClause(r, "org_id", &args)
+	set := "is_active = $2"
+	if active {
+		set += ", deleted_at = NULL, deleted_by = NULL"
+	}
+	result, err := database.DB.Exec("UPDATE users SET "+set+", updated_at = NOW() WHERE user_id = $1"+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	message := "Account reactivated"
+	if !active {
+		message = "Account deactivated"
+		if err := revokeUserTokens(userID, adminID, "account_deactivated", time.Now()); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Account deactivated but sessions could not be revoked: "+err.Error())
+			return
+		}
+	}
+	log.Printf("%s: %s by %s", message, userID, adminID)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": message,
+	})
+}
+
+// AdminDeactivateUser - Block an account from logging in and end its sessions. Its history
+// stays in place and it can be reactivated later.
+// POST /api/admin/users/{id}/deactivate
+func AdminDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	setUserActive(w, r, false)
+}
+
+// AdminReactivateUser - Let a deactivated or deleted account log in again
+// POST /api/admin/users/{id}/reactivate
+func AdminReactivateUser(w http.ResponseWriter, r *http.Request) {
+	setUserActive(w, r, true)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== EMERGENCY ACKNOWLEDGMENT SLA ====================
+
+// recordEmergencyView stores the first time a supervisor opened an emergency
+func recordEmergencyView(emergencyID int, supervisorID string) {
+	_, err := database.DB.Exec(`
+		INSERT INTO emergency_acknowledgments (emergency_id, supervisor_id, first_viewed_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (emergency_id, supervisor_id) DO NOTHING
+	`, emergencyID, supervisorID)
+	if err != nil {
+		log.Printf("Warning: failed to record view of emergency %d: %v", emergencyID, err)
+	}
+}
+
+// AcknowledgeEmergency - Supervisor confirms they have seen and are handling an emergency
+// POST /api/emergencies/{id}/ack
+func AcknowledgeEmergency(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	if role != string(models.RoleSupervisor) && role != string(models.RoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "Only supervisors can acknowledge emergencies")
+		return
+	}
+
+	emergencyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid emergency ID")
+		return
+	}
+
+	var reportedAt time.Time
+	err = database.DB.QueryRow("SELECT reporting_time FROM emergencies WHERE id = $1", emergencyID).Scan(&reportedAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Emergency not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Acknowledging again keeps the original timestamp so the SLA can't be reset
+	var firstViewedAt, acknowledgedAt time.Time
+	err = database.DB.QueryRow(`
+		INSERT INTO emergency_acknowledgments (emergency_id, supervisor_id, first_viewed_at, acknowledged_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (emergency_id, supervisor_id) DO UPDATE
+		SET acknowledged_at = COALESCE(emergency_acknowledgments.acknowledged_at, NOW())
+		RETURNING first_viewed_at, acknowledged_at
+	`, emergencyID, userID).Scan(&firstViewedAt, &acknowledgedAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error acknowledging emergency: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"emergency_id":    emergencyID,
+		"first_viewed_at": firstViewedAt,
+		"acknowledged_at": acknowledgedAt,
+		"seconds_to_ack":  acknowledgedAt.Sub(reportedAt).Seconds(),
+	})
+}
+
+// SupervisorAckStats is one supervisor's acknowledgment performance
+type SupervisorAckStats struct {
+	SupervisorID     string   `json:"supervisor_id"`
+	Name             string   `json:"name"`
+	Acknowledged     int      `json:"acknowledged"`
+	AvgSecondsToView *float64 `json:"avg_seconds_to_view"`
+	AvgSecondsToAck  *float64 `json:"avg_seconds_to_ack"`
+	Unacknowledged   int      `json:"unacknowledged"`
+}
+
+// GetEmergencyAckStats - Average time from report to first view and acknowledgment
+// GET /api/admin/emergencies/ack-stats?days=30
+func GetEmergencyAckStats(w http.ResponseWriter, r *http.Request) {
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	// Each emergency is measured against its reporter's supervisor
+	args := []interface{}{days}
+	scope := tenantClause(r, "e.org_id", &args)
+	rows, err := database.DB.Query(`
+		SELECT s.user_id, s.name,
+			COUNT(a.acknowledged_at),
+			AVG(EXTRACT(EPOCH FROM (a.first_viewed_at - e.reporting_time))),
+			AVG(EXTRACT(EPOCH FROM (a.acknowledged_at - e.reporting_time))),
+			COUNT(*) FILTER (WHERE a.acknowledged_at IS NULL)
+		FROM emergencies e
+		JOIN users m ON m.user_id = e.user_id
+		JOIN users s ON s.user_id = m.supervisor_id
+		LEFT JOIN emergency_acknowledgments a ON a.emergency_id = e.id AND a.supervisor_id = s.user_id
+		WHERE e.reporting_time >= NOW() - $1 * INTERVAL '1 day' AND e.primary_emergency_id IS NULL`+scope+`
+		GROUP BY s.user_id, s.name
+		ORDER BY AVG(EXTRACT(EPOCH FROM (a.acknowledged_at - e.reporting_time))) DESC NULLS FIRST
+	`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	supervisors := []SupervisorAckStats{}
+	var totalAcked int
+	var totalAckSeconds float64
+	for rows.Next() {
+		var s SupervisorAckStats
+		var avgView, avgAck sql.NullFloat64
+		if err := rows.Scan(&s.SupervisorID, &s.Name, &s.Acknowledged, &avgView, &avgAck, &s.Unacknowledged); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning acknowledgment stats")
+			return
+		}
+		if avgView.Valid {
+			s.AvgSecondsToView = &avgView.Float64
+		}
+		if avgAck.Valid {
+			s.AvgSecondsToAck = &avgAck.Float64
+			totalAcked += s.Acknowledged
+			totalAckSeconds += avgAck.Float64 * float64(s.Acknowledged)
+		}
+		supervisors = append(supervisors, s)
+	}
+
+	var overall *float64
+	if totalAcked > 0 {
+		avg := totalAckSeconds / float64(totalAcked)
+		overall = &avg
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"days":               days,
+		"avg_seconds_to_ack": overall,
+		"acknowledged":       totalAcked,
+		"supervisors":        supervisors,
+	})
+}
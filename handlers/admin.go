@@ -6,10 +6,10 @@ import (
 	"MineSafeBackend/models"
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // ==================== ADMIN AUTH ====================
@@ -49,82 +49,18 @@ func RegisterAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if signup.Name == "" || signup.Email == "" || signup.Password == "" {
-		respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Name, email, and password are required",
-		})
-		return
-	}
-
-	// Validate admin code (hardcoded as "8888" as per app requirement)
-	if signup.AdminCode != "8888" {
-		respondWithJSON(w, http.StatusUnauthorized, map[string]interface{}{
+	// The code must be one minted by an existing admin, or the bootstrap code for the first admin
+	admin, code, msg := createAdminWithCode(r, signup)
+	if code != 0 {
+		respondWithJSON(w, code, map[string]interface{}{
 			"success": false,
-			"message": "Invalid admin authorization code",
-		})
-		return
-	}
-
-	// Check if email already exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", signup.Email).Scan(&exists)
-	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Database error",
-		})
-		return
-	}
-	if exists {
-		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
-			"success": false,
-			"message": "Email already registered",
-		})
-		return
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signup.Password), bcrypt.DefaultCost)
-	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error processing password",
-		})
-		return
-	}
-
-	// Create admin user with mine_name as mining_site and mine_location as location
-	admin, err := models.NewUser(signup.Name, signup.Email, signup.Phone, string(hashedPassword),
-		signup.MineName, signup.MineLocation, models.RoleAdmin, nil)
-	if err != nil {
-		respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
-	}
-
-	// Insert into database
-	err = database.DB.QueryRow(
-		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		 RETURNING id`,
-		admin.UserID, admin.Name, admin.Email, admin.Phone, admin.Password, admin.Role,
-		admin.MiningSite, admin.Location, admin.CreatedAt, admin.UpdatedAt,
-	).Scan(&admin.ID)
-
-	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error creating admin: " + err.Error(),
+			"message": msg,
 		})
 		return
 	}
 
 	// Generate token
-	token, err := middleware.GenerateToken(admin.UserID, string(admin.Role))
+	token, err := issueLoginToken(r, admin.UserID, string(admin.Role))
 	if err != nil {
 		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -142,7 +78,7 @@ func RegisterAdmin(w http.ResponseWriter, r *http.Request) {
 }
 
 // AdminSignup - POST /api/admin/signup (legacy endpoint)
-// Create a new admin account
+// Create a new admin account with an admin registration code
 func AdminSignup(w http.ResponseWriter, r *http.Request) {
 	var signup AdminSignupRequest
 	if err := json.NewDecoder(r.Body).Decode(&signup); err != nil {
@@ -150,61 +86,24 @@ func AdminSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if signup.Name == "" || signup.Email == "" || signup.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "Name, email, and password are required")
+	admin, code, msg := createAdminWithCode(r, signup)
+	if code != 0 {
+		respondWithError(w, code, msg)
 		return
 	}
 
-	// Check if email already exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", signup.Email).Scan(&exists)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Database error")
-		return
-	}
-	if exists {
-		respondWithError(w, http.StatusConflict, "Email already registered")
-		return
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signup.Password), bcrypt.DefaultCost)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error processing password")
-		return
-	}
-
-	// Create admin user
-	admin, err := models.NewUser(signup.Name, signup.Email, signup.Phone, string(hashedPassword),
-		signup.MineName, signup.MineLocation, models.RoleAdmin, nil)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	// Insert into database
-	err = database.DB.QueryRow(
-		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		 RETURNING id`,
-		admin.UserID, admin.Name, admin.Email, admin.Phone, admin.Password, admin.Role,
-		admin.MiningSite, admin.Location, admin.CreatedAt, admin.UpdatedAt,
-	).Scan(&admin.ID)
-
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating admin: "+err.Error())
-		return
+	// Each organization gets its own editable copy of the default checklists
+	if err := database.ProvisionOrganizationChecklists(admin.MiningSite); err != nil {
+		log.Printf("Warning: failed to provision checklists for %s: %v", admin.MiningSite, err)
 	}
 
 	// Generate token
-	token, err := middleware.GenerateToken(admin.UserID, string(admin.Role))
+	token, err := issueLoginToken(r, admin.UserID, string(admin.Role))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	admin.Password = "" // Don't send password back
 	respondWithJSON(w, http.StatusCreated, AuthResponse{
 		Token:  token,
 		UserID: admin.UserID,
@@ -229,14 +128,16 @@ func AdminLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Find admin by email
 	var admin models.User
+	var active bool
 	err := database.DB.QueryRow(
-		`SELECT id, user_id, name, email, phone, password, role, created_at, updated_at
-		 FROM users WHERE email = $1 AND role = 'ADMIN'`,
-		login.Email,
+		`SELECT id, user_id, name, email, phone, password, role, created_at, updated_at, COALESCE(is_active, true)
+		 FROM users WHERE LOWER(email) = $1 AND role = 'ADMIN' AND deleted_at IS NULL`,
+		models.NormalizeEmail(login.Email),
 	).Scan(&admin.ID, &admin.UserID, &admin.Name, &admin.Email, &admin.Phone, &admin.Password,
-		&admin.Role, &admin.CreatedAt, &admin.UpdatedAt)
+		&admin.Role, &admin.CreatedAt, &admin.UpdatedAt, &active)
 
 	if err == sql.ErrNoRows {
+		recordLoginEvent(r, LoginMethodAdmin, login.Email, "", "", LoginUnknownAccount)
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
@@ -246,17 +147,24 @@ func AdminLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(login.Password)); err != nil {
+	if !checkPassword(admin.UserID, admin.Password, login.Password) {
+		recordLoginEvent(r, LoginMethodAdmin, login.Email, admin.UserID, string(admin.Role), LoginBadPassword)
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
+	if !active {
+		recordLoginEvent(r, LoginMethodAdmin, login.Email, admin.UserID, string(admin.Role), LoginDeactivated)
+		respondWithError(w, http.StatusForbidden, "This account has been deactivated")
+		return
+	}
 
 	// Generate token
-	token, err := middleware.GenerateToken(admin.UserID, string(admin.Role))
+	token, err := issueLoginToken(r, admin.UserID, string(admin.Role))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
+	recordLoginEvent(r, LoginMethodAdmin, login.Email, admin.UserID, string(admin.Role), LoginSuccess)
 
 	admin.Password = "" // Don't send password back
 	respondWithJSON(w, http.StatusOK, AuthResponse{
@@ -309,26 +217,17 @@ func AdminCreateSupervisor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if email already exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", req.Email).Scan(&exists)
-	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
+	// Check if email or phone already exists
+	if code, msg := checkContactAvailable(&req.Email, &req.Phone, ""); code != 0 {
+		respondWithJSON(w, code, map[string]interface{}{
 			"success": false,
-			"message": "Database error",
-		})
-		return
-	}
-	if exists {
-		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
-			"success": false,
-			"message": "Email already registered",
+			"message": msg,
 		})
 		return
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(req.Password)
 	if err != nil {
 		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -381,9 +280,12 @@ func AdminCreateSupervisor(w http.ResponseWriter, r *http.Request) {
 // AdminGetSupervisors - GET /api/admin/supervisors
 // Admin gets all supervisors
 func AdminGetSupervisors(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{}
+	scope := tenantClause(r, "org_id", &args)
 	rows, err := database.DB.Query(
-		`SELECT id, user_id, name, email, phone, role, mining_site, location, created_at, updated_at
-		 FROM users WHERE role = 'SUPERVISOR' ORDER BY created_at DESC`,
+		`SELECT id, user_id, name, email, phone, role, mining_site, location, created_at, updated_at, COALESCE(is_active, true)
+		 FROM users WHERE role = 'SUPERVISOR' AND deleted_at IS NULL`+scope+` ORDER BY created_at DESC`,
+		args...,
 	)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
@@ -404,8 +306,9 @@ func AdminGetSupervisors(w http.ResponseWriter, r *http.Request) {
 	supervisors := []SupervisorResponse{}
 	for rows.Next() {
 		var sup models.User
+		var active bool
 		err := rows.Scan(&sup.ID, &sup.UserID, &sup.Name, &sup.Email, &sup.Phone,
-			&sup.Role, &sup.MiningSite, &sup.Location, &sup.CreatedAt, &sup.UpdatedAt)
+			&sup.Role, &sup.MiningSite, &sup.Location, &sup.CreatedAt, &sup.UpdatedAt, &active)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning supervisor")
 			return
@@ -417,7 +320,7 @@ func AdminGetSupervisors(w http.ResponseWriter, r *http.Request) {
 			Phone:        sup.Phone,
 			Department:   sup.MiningSite, // Using mining_site as department
 			Role:         string(sup.Role),
-			Status:       "active",
+			Status:       accountStatus(active),
 		})
 	}
 
@@ -433,10 +336,12 @@ func AdminGetSupervisor(w http.ResponseWriter, r *http.Request) {
 	supervisorID := vars["id"]
 
 	var supervisor models.User
+	args := []interface{}{supervisorID}
+	scope := tenantClause(r, "org_id", &args)
 	err := database.DB.QueryRow(
 		`SELECT id, user_id, name, email, phone, role, mining_site, location, created_at, updated_at
-		 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR'`,
-		supervisorID,
+		 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR' AND deleted_at IS NULL`+scope,
+		args...,
 	).Scan(&supervisor.ID, &supervisor.UserID, &supervisor.Name, &supervisor.Email, &supervisor.Phone,
 		&supervisor.Role, &supervisor.MiningSite, &supervisor.Location, &supervisor.CreatedAt, &supervisor.UpdatedAt)
 
@@ -473,7 +378,7 @@ func AdminUpdateSupervisor(w http.ResponseWriter, r *http.Request) {
 	// Check if supervisor exists
 	var exists bool
 	err := database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR')",
+		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR' AND deleted_at IS NULL)",
 		supervisorID,
 	).Scan(&exists)
 	if err != nil || !exists {
@@ -481,6 +386,11 @@ func AdminUpdateSupervisor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if code, msg := checkContactAvailable(&updateData.Email, &updateData.Phone, supervisorID); code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+
 	result, err := database.DB.Exec(
 		`UPDATE users SET name = $1, email = $2, phone = $3, mining_site = $4, location = $5, updated_at = NOW()
 		 WHERE user_id = $6 AND role = 'SUPERVISOR'`,
@@ -515,22 +425,20 @@ func AdminUpdateSupervisor(w http.ResponseWriter, r *http.Request) {
 }
 
 // AdminDeleteSupervisor - DELETE /api/admin/supervisors/{id}
-// Admin deletes a supervisor
+// Admin deletes a supervisor; the account is soft-deleted so its history stays intact
 func AdminDeleteSupervisor(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	supervisorID := vars["id"]
+	adminID, _ := middleware.GetUserIDFromContext(r.Context())
 
-	result, err := database.DB.Exec(
-		"DELETE FROM users WHERE user_id = $1 AND role = 'SUPERVISOR'",
-		supervisorID,
-	)
+	args := []interface{}{supervisorID}
+	deleted, err := softDeleteUser(adminID, "user_id = $1 AND role = 'SUPERVISOR'"+tenantClause(r, "org_id", &args), args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !deleted {
 		respondWithError(w, http.StatusNotFound, "Supervisor not found")
 		return
 	}
@@ -585,20 +493,11 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if email already exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", req.Email).Scan(&exists)
-	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Database error",
-		})
-		return
-	}
-	if exists {
-		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+	// Check if email or phone already exists
+	if code, msg := checkContactAvailable(&req.Email, &req.Phone, ""); code != 0 {
+		respondWithJSON(w, code, map[string]interface{}{
 			"success": false,
-			"message": "Email already registered",
+			"message": msg,
 		})
 		return
 	}
@@ -608,7 +507,7 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
 	if req.SupervisorID != "" {
 		var supExists bool
 		err := database.DB.QueryRow(
-			"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR')",
+			"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR' AND deleted_at IS NULL)",
 			req.SupervisorID,
 		).Scan(&supExists)
 		if err != nil {
@@ -643,7 +542,7 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(req.Password)
 	if err != nil {
 		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -680,6 +579,8 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	createMinerInduction(miner.UserID)
+
 	respondWithJSON(w, http.StatusCreated, MinerCreateResponse{
 		Success: true,
 		MinerID: miner.UserID,
@@ -692,21 +593,17 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
 func AdminGetMiners(w http.ResponseWriter, r *http.Request) {
 	supervisorID := r.URL.Query().Get("supervisor_id")
 
-	var rows *sql.Rows
-	var err error
-
+	query := `SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at,
+		 COALESCE(is_active, true)
+		 FROM users WHERE role = 'MINER' AND deleted_at IS NULL`
+	args := []interface{}{}
 	if supervisorID != "" {
-		rows, err = database.DB.Query(
-			`SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at
-			 FROM users WHERE role = 'MINER' AND supervisor_id = $1 ORDER BY created_at DESC`,
-			supervisorID,
-		)
-	} else {
-		rows, err = database.DB.Query(
-			`SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at
-			 FROM users WHERE role = 'MINER' ORDER BY created_at DESC`,
-		)
+		args = append(args, supervisorID)
+		query += " AND supervisor_id = $1"
 	}
+	query += tenantClause(r, "org_id", &args) + " ORDER BY created_at DESC"
+
+	rows, err := database.DB.Query(query, args...)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
@@ -727,8 +624,9 @@ func AdminGetMiners(w http.ResponseWriter, r *http.Request) {
 	miners := []MinerResponse{}
 	for rows.Next() {
 		var miner models.User
+		var active bool
 		err := rows.Scan(&miner.ID, &miner.UserID, &miner.Name, &miner.Email, &miner.Phone,
-			&miner.Role, &miner.MiningSite, &miner.Location, &miner.SupervisorID, &miner.CreatedAt, &miner.UpdatedAt)
+			&miner.Role, &miner.MiningSite, &miner.Location, &miner.SupervisorID, &miner.CreatedAt, &miner.UpdatedAt, &active)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning miner")
 			return
@@ -740,7 +638,7 @@ func AdminGetMiners(w http.ResponseWriter, r *http.Request) {
 			Phone:   miner.Phone,
 			Zone:    miner.MiningSite, // Using mining_site as zone
 			Role:    string(miner.Role),
-			Status:  "active",
+			Status:  accountStatus(active),
 		})
 	}
 
@@ -756,10 +654,12 @@ func AdminGetMiner(w http.ResponseWriter, r *http.Request) {
 	minerID := vars["id"]
 
 	var miner models.User
+	args := []interface{}{minerID}
+	scope := tenantClause(r, "org_id", &args)
 	err := database.DB.QueryRow(
 		`SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at
-		 FROM users WHERE user_id = $1 AND role = 'MINER'`,
-		minerID,
+		 FROM users WHERE user_id = $1 AND role = 'MINER' AND deleted_at IS NULL`+scope,
+		args...,
 	).Scan(&miner.ID, &miner.UserID, &miner.Name, &miner.Email, &miner.Phone,
 		&miner.Role, &miner.MiningSite, &miner.Location, &miner.SupervisorID, &miner.CreatedAt, &miner.UpdatedAt)
 
@@ -797,7 +697,7 @@ func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
 	// Check if miner exists
 	var exists bool
 	err := database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'MINER')",
+		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'MINER' AND deleted_at IS NULL)",
 		minerID,
 	).Scan(&exists)
 	if err != nil || !exists {
@@ -809,7 +709,7 @@ func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
 	if updateData.SupervisorID != "" {
 		var supExists bool
 		err := database.DB.QueryRow(
-			"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR')",
+			"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND role = 'SUPERVISOR' AND deleted_at IS NULL)",
 			updateData.SupervisorID,
 		).Scan(&supExists)
 		if err != nil {
@@ -822,6 +722,11 @@ func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if code, msg := checkContactAvailable(&updateData.Email, &updateData.Phone, minerID); code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+
 	result, err := database.DB.Exec(
 		`UPDATE users SET name = $1, email = $2, phone = $3, mining_site = $4, location = $5, supervisor_id = $6, updated_at = NOW()
 		 WHERE user_id = $7 AND role = 'MINER'`,
@@ -856,15 +761,14 @@ func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
 }
 
 // AdminDeleteMiner - DELETE /api/admin/miners/{id}
-// Admin deletes a miner
+// Admin deletes a miner; the account is soft-deleted so its history stays intact
 func AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	minerID := vars["id"]
+	adminID, _ := middleware.GetUserIDFromContext(r.Context())
 
-	result, err := database.DB.Exec(
-		"DELETE FROM users WHERE user_id = $1 AND role = 'MINER'",
-		minerID,
-	)
+	args := []interface{}{minerID}
+	deleted, err := softDeleteUser(adminID, "user_id = $1 AND role = 'MINER'"+tenantClause(r, "org_id", &args), args...)
 	if err != nil {
 		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -873,8 +777,7 @@ func AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !deleted {
 		respondWithJSON(w, http.StatusNotFound, map[string]interface{}{
 			"success": false,
 			"message": "Miner not found",
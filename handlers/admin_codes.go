@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== ADMIN REGISTRATION CODES ====================
+
+// Admin code states as listed to admins
+const (
+	AdminCodeActive    = "ACTIVE"
+	AdminCodeExpired   = "EXPIRED"
+	AdminCodeExhausted = "EXHAUSTED"
+	AdminCodeRevoked   = "REVOKED"
+)
+
+const (
+	defaultAdminCodeTTL = 72 * time.Hour
+	maxAdminCodeTTL     = 30 * 24 * time.Hour
+	maxAdminCodeUses    = 50
+)
+
+// adminCodeAlphabet leaves out characters that are easily misread (0/O, 1/I/L)
+const adminCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// CreateAdminCodeRequest represents the request body for minting an admin code
+type CreateAdminCodeRequest struct {
+	Label          string `json:"label"`
+	MaxUses        int    `json:"max_uses"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+}
+
+// AdminCodeRedemption is an admin account created with a code
+type AdminCodeRedemption struct {
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// AdminCode is a registration code as shown to admins; the code itself is only shown once
+type AdminCode struct {
+	ID            int                   `json:"id"`
+	Label         string                `json:"label"`
+	CreatedBy     string                `json:"created_by"`
+	CreatedByName string                `json:"created_by_name"`
+	MaxUses       int                   `json:"max_uses"`
+	UseCount      int                   `json:"use_count"`
+	Status        string                `json:"status"`
+	ExpiresAt     time.Time             `json:"expires_at"`
+	RevokedAt     *time.Time            `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	Redemptions   []AdminCodeRedemption `json:"redemptions"`
+}
+
+// generateAdminCode returns a code like K7QM-3XPA-W9TD (about 60 bits)
+func generateAdminCode() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(adminCodeAlphabet[int(c)%len(adminCodeAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+// normalizeAdminCode ignores case, spaces and dashes so codes can be typed loosely
+func normalizeAdminCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// redeemAdminCode uses up one use of code inside tx. It returns the code's id, or 0 for the
+// bootstrap code, which only works while there are no admins.
+func redeemAdminCode(tx *sql.Tx, code string) (int, bool, error) {
+	normalized := normalizeAdminCode(code)
+	if normalized == "" {
+		return 0, false, nil
+	}
+
+	// Conditional so concurrent registrations can't go over the limit
+	var id int
+	err := tx.QueryRow(`
+		UPDATE admin_codes SET use_count = use_count + 1
+		WHERE code_hash = $1 AND revoked_at IS NULL AND expires_at > NOW() AND use_count < max_uses
+		RETURNING id
+	`, hashInviteToken(normalized)).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	bootstrap := config.Get().AdminBootstrapCode
+	if bootstrap == "" || subtle.ConstantTimeCompare([]byte(normalized), []byte(normalizeAdminCode(bootstrap))) != 1 {
+		return 0, false, nil
+	}
+	// Serialize bootstrap attempts so two can't both see an empty admin list
+	if _, err := tx.Exec("LOCK TABLE admin_code_redemptions IN EXCLUSIVE MODE"); err != nil {
+		return 0, false, err
+	}
+	var admins int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = $1", models.RoleAdmin).Scan(&admins); err != nil {
+		return 0, false, err
+	}
+	return 0, admins == 0, nil
+}
+
+// createAdminWithCode registers an admin account if signup carries a valid registration
+// code, recording which code was used. The admin joins the organization that issued the
+// code; a mine they name must be one of its sites or becomes a new one. Codes from platform
+// admins and the bootstrap code start a new organization for a new mine. On failure it
+// returns an HTTP status and message.
+func createAdminWithCode(r *http.Request, signup AdminSignupRequest) (*models.User, int, string) {
+	if signup.Name == "" || signup.Email == "" || signup.Password == "" {
+		return nil, http.StatusBadRequest, "Name, email, and password are required"
+	}
+	if code, msg := checkContactAvailable(&signup.Email, &signup.Phone, ""); code != 0 {
+		return nil, code, msg
+	}
+
+	hashedPassword, err := hashPassword(signup.Password)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Error processing password"
+	}
+	admin, err := models.NewUser(signup.Name, signup.Email, signup.Phone, string(hashedPassword),
+		signup.MineName, signup.MineLocation, models.RoleAdmin, nil)
+	if err != nil {
+		return nil, http.StatusBadRequest, err.Error()
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	defer tx.Rollback()
+
+	codeID, ok, err := redeemAdminCode(tx, signup.AdminCode)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	if !ok {
+		return nil, http.StatusUnauthorized, "Invalid admin authorization code"
+	}
+
+	var codeOrg sql.NullInt64
+	if codeID != 0 {
+		if err := tx.QueryRow("SELECT org_id FROM admin_codes WHERE id = $1", codeID).Scan(&codeOrg); err != nil {
+			return nil, http.StatusInternalServerError, "Database error"
+		}
+	}
+	if strings.TrimSpace(admin.MiningSite) != "" {
+		_, err = tx.Exec(`
+			INSERT INTO sites (name, location, org_id) VALUES (TRIM($1), $2, $3)
+			ON CONFLICT DO NOTHING
+		`, admin.MiningSite, admin.Location, codeOrg)
+		var siteOrg sql.NullInt64
+		if err == nil {
+			err = tx.QueryRow("SELECT org_id FROM sites WHERE LOWER(name) = LOWER(TRIM($1))", admin.MiningSite).Scan(&siteOrg)
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Database error"
+		}
+		if codeOrg.Valid && siteOrg != codeOrg {
+			return nil, http.StatusForbidden, "This mine belongs to another organization"
+		}
+	}
+
+	// The site trigger sets org_id from the mine; without one the admin takes the code's
+	err = tx.QueryRow(
+		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, created_at, updated_at, org_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id`,
+		admin.UserID, admin.Name, admin.Email, admin.Phone, admin.Password, admin.Role,
+		admin.MiningSite, admin.Location, admin.CreatedAt, admin.UpdatedAt, codeOrg,
+	).Scan(&admin.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Error creating admin: " + err.Error()
+	}
+	_, err = tx.Exec(
+		"INSERT INTO admin_code_redemptions (code_id, user_id, ip_address) VALUES (NULLIF($1, 0), $2, $3)",
+		codeID, admin.UserID, clientIP(r),
+	)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+
+	admin.Password = "" // Don't send password back
+	return admin, 0, ""
+}
+
+// CreateAdminCode - Mint a code another person can use to register an admin account in the
+// caller's organization. The code is only returned here; afterwards only its hash is kept.
+// POST /api/admin/codes
+func CreateAdminCode(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreateAdminCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 1 || req.MaxUses > maxAdminCodeUses {
+		respondWithError(w, http.StatusBadRequest, "max_uses must be between 1 and "+strconv.Itoa(maxAdminCodeUses))
+		return
+	}
+	ttl := defaultAdminCodeTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > maxAdminCodeTTL {
+		respondWithError(w, http.StatusBadRequest, "expires_in_hours must be between 1 and "+strconv.Itoa(int(maxAdminCodeTTL.Hours())))
+		return
+	}
+
+	code, err := generateAdminCode()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating code")
+		return
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	var id int
+	err = database.DB.QueryRow(`
+		INSERT INTO admin_codes (code_hash, label, created_by, max_uses, expires_at, org_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+		RETURNING id
+	`, hashInviteToken(normalizeAdminCode(code)), truncate(strings.TrimSpace(req.Label), 255), adminID, req.MaxUses, expiresAt,
+		requestOrg(r)).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating code: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":         id,
+		"code":       code,
+		"max_uses":   req.MaxUses,
+		"expires_at": expiresAt,
+		"message":    "Share this code securely; it will not be shown again",
+	})
+}
+
+// GetAdminCodes - The organization's registration codes with who minted them and which
+// admins used them
+// GET /api/admin/codes
+func GetAdminCodes(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{}
+	scope := tenantClause(r, "c.org_id", &args)
+	rows, err := database.DB.Query(`
+		SELECT c.id, COALESCE(c.label, ''), COALESCE(c.created_by, ''), COALESCE(u.name, ''),
+		       c.max_uses, c.use_count, c.expires_at, c.revoked_at, c.created_at
+		FROM admin_codes c
+		LEFT JOIN users u ON u.user_id = c.created_by
+		WHERE 1=1`+scope+`
+		ORDER BY c.created_at DESC
+	`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	codes := []*AdminCode{}
+	byID := map[int]*AdminCode{}
+	for rows.Next() {
+		c := &AdminCode{Redemptions: []AdminCodeRedemption{}}
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Label, &c.CreatedBy, &c.CreatedByName,
+			&c.MaxUses, &c.UseCount, &c.ExpiresAt, &revokedAt, &c.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning codes")
+			return
+		}
+		switch {
+		case revokedAt.Valid:
+			c.RevokedAt = &revokedAt.Time
+			c.Status = AdminCodeRevoked
+		case c.UseCount >= c.MaxUses:
+			c.Status = AdminCodeExhausted
+		case time.Now().After(c.ExpiresAt):
+			c.Status = AdminCodeExpired
+		default:
+			c.Status = AdminCodeActive
+		}
+		codes = append(codes, c)
+		byID[c.ID] = c
+	}
+	rows.Close()
+
+	// Admins registered with the bootstrap code have no code row and are listed separately,
+	// to platform admins only
+	bootstrapped := []AdminCodeRedemption{}
+	rows, err = database.DB.Query(`
+		SELECT COALESCE(r.code_id, 0), r.user_id, u.name, u.email, COALESCE(r.ip_address, ''), r.redeemed_at
+		FROM admin_code_redemptions r
+		JOIN users u ON u.user_id = r.user_id
+		LEFT JOIN admin_codes c ON c.id = r.code_id
+		WHERE 1=1`+scope+`
+		ORDER BY r.redeemed_at
+	`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var codeID int
+		var red AdminCodeRedemption
+		if err := rows.Scan(&codeID, &red.UserID, &red.Name, &red.Email, &red.IPAddress, &red.RedeemedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning redemptions")
+			return
+		}
+		if c, ok := byID[codeID]; ok {
+			c.Redemptions = append(c.Redemptions, red)
+		} else if codeID == 0 {
+			bootstrapped = append(bootstrapped, red)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"codes":              codes,
+		"bootstrap_accounts": bootstrapped,
+	})
+}
+
+// RevokeAdminCode - Stop a code from registering any more admins
+// DELETE /api/admin/codes/{id}
+func RevokeAdminCode(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := middleware.GetUserIDFromContext(r.Context())
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid code ID")
+		return
+	}
+
+	args := []interface{}{id, adminID}
+	scope := tenantClause(r, "org_id", &args)
+	result, err := database.DB.Exec(
+		"UPDATE admin_codes SET revoked_at = NOW(), revoked_by = $2 WHERE id = $1 AND revoked_at IS NULL"+scope,
+		args...,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Code not found or already revoked")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Code revoked",
+	})
+}
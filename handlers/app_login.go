@@ -4,11 +4,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"MineSafeBackend/database"
 	"MineSafeBackend/middleware"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Request shape coming from the app
@@ -48,6 +47,7 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 	result, err := database.GetUserByEmail(ctx, req.Email, req.Role)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			recordLoginEvent(r, LoginMethodApp, req.Email, "", "", LoginUnknownAccount)
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
@@ -58,10 +58,11 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 	// 4. Handle different user types based on role
 	var userID, userName, role, phoneNumber, miningSite string
 	var supervisorName string
+	var active bool
 
 	// Type assertion based on role
 	switch req.Role {
-	case "MINER":
+	case "MINER", "OPERATOR":
 		usr, ok := result.(*database.User)
 		if !ok {
 			http.Error(w, "Invalid user type for role", http.StatusInternalServerError)
@@ -69,13 +70,15 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 5. Compare password provided vs stored hash
-		if err := bcrypt.CompareHashAndPassword([]byte(usr.Password), []byte(req.Password)); err != nil {
+		if !checkPassword(usr.UserID, usr.Password, req.Password) {
+			recordLoginEvent(r, LoginMethodApp, req.Email, usr.UserID, usr.Role, LoginBadPassword)
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
 
 		// 6. Ensure miner is assigned to a supervisor
 		if usr.SupervisorID == nil || *usr.SupervisorID == "" {
+			recordLoginEvent(r, LoginMethodApp, req.Email, usr.UserID, usr.Role, LoginDenied)
 			http.Error(w, "User is not assigned to a supervisor", http.StatusConflict)
 			return
 		}
@@ -95,6 +98,7 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 		userName = usr.Name
 		phoneNumber = *usr.Phone
 		role = usr.Role
+		active = usr.IsActive
 		if usr.MiningSite != nil {
 			miningSite = *usr.MiningSite
 		}
@@ -107,7 +111,8 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 5. Compare password provided vs stored hash
-		if err := bcrypt.CompareHashAndPassword([]byte(sup.Password), []byte(req.Password)); err != nil {
+		if !checkPassword(sup.UserID, sup.Password, req.Password) {
+			recordLoginEvent(r, LoginMethodApp, req.Email, sup.UserID, sup.Role, LoginBadPassword)
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
@@ -116,6 +121,7 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 		userName = sup.Name
 		phoneNumber = *sup.Phone
 		role = sup.Role
+		active = sup.IsActive
 		supervisorName = sup.Name // Supervisor's own name
 		if sup.MiningSite != nil {
 			miningSite = *sup.MiningSite
@@ -129,7 +135,8 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 5. Compare password provided vs stored hash
-		if err := bcrypt.CompareHashAndPassword([]byte(adm.Password), []byte(req.Password)); err != nil {
+		if !checkPassword(adm.UserID, adm.Password, req.Password) {
+			recordLoginEvent(r, LoginMethodApp, req.Email, adm.UserID, adm.Role, LoginBadPassword)
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
@@ -140,6 +147,7 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 			phoneNumber = *adm.Phone
 		}
 		role = adm.Role
+		active = adm.IsActive
 		supervisorName = "" // Admin has no supervisor
 
 	default:
@@ -147,13 +155,21 @@ func MinerAppLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !active {
+		recordLoginEvent(r, LoginMethodApp, req.Email, userID, role, LoginDeactivated)
+		http.Error(w, "This account has been deactivated", http.StatusForbidden)
+		return
+	}
+
 	// 8. Generate JWT
-	token, err := middleware.GenerateToken(userID, role)
+	token, err := issueSessionToken(r, userID, role, middleware.AudienceMobile, time.Now().Add(middleware.TokenLifetimeFor(middleware.AudienceMobile)))
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
+	recordLoginEvent(r, LoginMethodApp, req.Email, userID, role, LoginSuccess)
+
 	// 9. Build response
 	resp := MinerLoginResponse{
 		Token:          token,
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== APP VERSION GATING ====================
+
+var validAppPlatforms = map[string]bool{"android": true, "ios": true}
+
+// AppVersionPolicy is the supported version range and feature flags for one platform
+type AppVersionPolicy struct {
+	Platform        string          `json:"platform"`
+	MinVersion      string          `json:"min_supported_version"`
+	LatestVersion   string          `json:"latest_version"`
+	UpdateURL       string          `json:"update_url"`
+	Message         string          `json:"message"`
+	FeatureFlags    map[string]bool `json:"feature_flags"`
+	UpdatedAt       *time.Time      `json:"updated_at,omitempty"`
+	ForceUpdate     bool            `json:"force_update"`
+	UpdateAvailable bool            `json:"update_available"`
+}
+
+func loadAppVersionPolicy(platform string) (AppVersionPolicy, error) {
+	policy := AppVersionPolicy{
+		Platform:      platform,
+		MinVersion:    "0.0.0",
+		LatestVersion: "0.0.0",
+		FeatureFlags:  map[string]bool{},
+	}
+
+	var flagsJSON []byte
+	var updatedAt time.Time
+	err := database.DB.QueryRow(`
+		SELECT min_version, latest_version, COALESCE(update_url, ''), COALESCE(message, ''),
+		       COALESCE(feature_flags, '{}'::jsonb), updated_at
+		FROM app_versions WHERE platform = $1
+	`, platform).Scan(&policy.MinVersion, &policy.LatestVersion, &policy.UpdateURL, &policy.Message,
+		&flagsJSON, &updatedAt)
+	if err == sql.ErrNoRows {
+		// No policy configured yet: every version is supported
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+
+	json.Unmarshal(flagsJSON, &policy.FeatureFlags)
+	if policy.FeatureFlags == nil {
+		policy.FeatureFlags = map[string]bool{}
+	}
+	policy.UpdatedAt = &updatedAt
+	return policy, nil
+}
+
+// compareVersions compares dotted numeric versions ("1.4.10" > "1.4.9").
+// Missing or non-numeric parts count as zero; pre-release suffixes after '-' or '+' are ignored.
+func compareVersions(a, b string) int {
+	parse := func(v string) []int {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+		if i := strings.IndexAny(v, "-+ "); i >= 0 {
+			v = v[:i]
+		}
+		parts := strings.Split(v, ".")
+		nums := make([]int, len(parts))
+		for i, p := range parts {
+			nums[i], _ = strconv.Atoi(p)
+		}
+		return nums
+	}
+
+	pa, pb := parse(a), parse(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// AppVersionCheck - Minimum supported version and feature flags for a platform (public)
+// GET /api/app/version-check?platform=android&version=1.2.0
+// The version may also be sent in the X-App-Version header.
+func AppVersionCheck(w http.ResponseWriter, r *http.Request) {
+	platform := strings.ToLower(r.URL.Query().Get("platform"))
+	if platform == "" {
+		platform = strings.ToLower(r.Header.Get(headerPlatform))
+	}
+	if !validAppPlatforms[platform] {
+		respondWithError(w, http.StatusBadRequest, "platform must be android or ios")
+		return
+	}
+
+	policy, err := loadAppVersionPolicy(platform)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = r.Header.Get(headerAppVersion)
+	}
+	if version != "" {
+		policy.ForceUpdate = compareVersions(version, policy.MinVersion) < 0
+		policy.UpdateAvailable = compareVersions(version, policy.LatestVersion) < 0
+	}
+
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// UpdateAppVersionRequest represents the request body for setting a platform's version policy
+type UpdateAppVersionRequest struct {
+	MinVersion    string          `json:"min_supported_version"`
+	LatestVersion string          `json:"latest_version"`
+	UpdateURL     string          `json:"update_url"`
+	Message       string          `json:"message"`
+	FeatureFlags  map[string]bool `json:"feature_flags"`
+}
+
+// UpdateAppVersionPolicy - Set minimum/latest version and feature flags for a platform
+// PUT /api/admin/app-versions/{platform}
+func UpdateAppVersionPolicy(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	platform := strings.ToLower(vars["platform"])
+	if !validAppPlatforms[platform] {
+		respondWithError(w, http.StatusBadRequest, "platform must be android or ios")
+		return
+	}
+
+	var req UpdateAppVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.MinVersion == "" {
+		respondWithError(w, http.StatusBadRequest, "min_supported_version is required")
+		return
+	}
+	if req.LatestVersion == "" {
+		req.LatestVersion = req.MinVersion
+	}
+	if compareVersions(req.LatestVersion, req.MinVersion) < 0 {
+		respondWithError(w, http.StatusBadRequest, "latest_version cannot be lower than min_supported_version")
+		return
+	}
+	if req.FeatureFlags == nil {
+		req.FeatureFlags = map[string]bool{}
+	}
+
+	flagsJSON, _ := json.Marshal(req.FeatureFlags)
+	_, err := database.DB.Exec(`
+		INSERT INTO app_versions (platform, min_version, latest_version, update_url, message, feature_flags, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (platform) DO UPDATE SET
+			min_version = EXCLUDED.min_version,
+			latest_version = EXCLUDED.latest_version,
+			update_url = EXCLUDED.update_url,
+			message = EXCLUDED.message,
+			feature_flags = EXCLUDED.feature_flags,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+	`, platform, req.MinVersion, req.LatestVersion, req.UpdateURL, req.Message, flagsJSON, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving version policy: "+err.Error())
+		return
+	}
+
+	policy, err := loadAppVersionPolicy(platform)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching version policy")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ==================== BUNDLED ASSETS ====================
+
+const assetsURLPrefix = "/assets/"
+
+// ServeAsset serves the default videos bundled with the server. Unlike uploads they are
+// public and rarely change, so they get a long shared cache lifetime and an ETag derived
+// from size and modification time; a changed file gets a new ETag and clients revalidate.
+// Directory listings are never served.
+// GET /assets/{filename}
+func ServeAsset(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+
+	// Clean the path so a request can never escape the assets directory
+	rel := filepath.Clean("/" + strings.TrimPrefix(r.URL.Path, assetsURLPrefix))
+	filePath := filepath.Join(cfg.AssetsDir, rel)
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		respondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(cfg.AssetCacheMaxAge.Seconds())))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	// ServeFile answers If-None-Match / If-Modified-Since and Range requests for seeking
+	http.ServeFile(w, r, filePath)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== DEVICE ATTESTATION ====================
+
+// Submission types that carry device attestation
+const (
+	SubmissionTypePPEStat     = "PPE_STAT"
+	SubmissionTypeQuizAttempt = "QUIZ_ATTEMPT"
+)
+
+// Suspicion flags raised on a submission
+const (
+	AttestationFlagIntegrityFailed = "INTEGRITY_FAILED"
+	AttestationFlagNoMetadata      = "NO_DEVICE_METADATA"
+	AttestationFlagSharedDevice    = "SHARED_DEVICE"
+	AttestationFlagDeviceChanged   = "DEVICE_CHANGED"
+)
+
+// Client metadata headers sent by the mobile app
+const (
+	headerAppVersion        = "X-App-Version"
+	headerDeviceModel       = "X-Device-Model"
+	headerDeviceID          = "X-Device-ID"
+	headerPlatform          = "X-Platform"
+	headerIntegrityProvider = "X-Integrity-Provider" // PLAY_INTEGRITY or DEVICE_CHECK
+	headerIntegrityVerdict  = "X-Integrity-Verdict"
+)
+
+// passingIntegrityVerdicts are the verdicts treated as a trustworthy device
+var passingIntegrityVerdicts = map[string]bool{
+	"MEETS_DEVICE_INTEGRITY": true,
+	"MEETS_STRONG_INTEGRITY": true,
+	"MEETS_BASIC_INTEGRITY":  true,
+	"VALID":                  true,
+}
+
+// SubmissionAttestation is the client metadata captured with a sensitive submission
+type SubmissionAttestation struct {
+	ID                int       `json:"id"`
+	UserID            string    `json:"user_id"`
+	UserName          string    `json:"user_name"`
+	SubmissionType    string    `json:"submission_type"`
+	SubmissionID      int       `json:"submission_id"`
+	AppVersion        string    `json:"app_version"`
+	DeviceModel       string    `json:"device_model"`
+	DeviceID          string    `json:"device_id"`
+	Platform          string    `json:"platform"`
+	IntegrityProvider string    `json:"integrity_provider"`
+	IntegrityVerdict  string    `json:"integrity_verdict"`
+	IPAddress         string    `json:"ip_address"`
+	Flags             []string  `json:"flags"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// recordSubmissionAttestation stores the client metadata sent with a submission and flags
+// suspicious patterns. Integrity verdicts are recorded as reported by the app; they are not
+// verified server-side against Google or Apple.
+func recordSubmissionAttestation(r *http.Request, userID, submissionType string, submissionID int) {
+	deviceID := strings.TrimSpace(r.Header.Get(headerDeviceID))
+	verdict := strings.ToUpper(strings.TrimSpace(r.Header.Get(headerIntegrityVerdict)))
+	appVersion := strings.TrimSpace(r.Header.Get(headerAppVersion))
+
+	flags := []string{}
+	if verdict != "" && !passingIntegrityVerdicts[verdict] {
+		flags = append(flags, AttestationFlagIntegrityFailed)
+	}
+	if deviceID == "" && appVersion == "" {
+		flags = append(flags, AttestationFlagNoMetadata)
+	}
+	if deviceID != "" {
+		// Same device used by another account in the last day
+		var otherUsers int
+		database.DB.QueryRow(`
+			SELECT COUNT(DISTINCT user_id) FROM submission_attestations
+			WHERE device_id = $1 AND user_id != $2 AND created_at > NOW() - INTERVAL '24 hours'
+		`, deviceID, userID).Scan(&otherUsers)
+		if otherUsers > 0 {
+			flags = append(flags, AttestationFlagSharedDevice)
+		}
+
+		var lastDevice string
+		database.DB.QueryRow(`
+			SELECT COALESCE(device_id, '') FROM submission_attestations
+			WHERE user_id = $1 AND COALESCE(device_id, '') != ''
+			ORDER BY created_at DESC LIMIT 1
+		`, userID).Scan(&lastDevice)
+		if lastDevice != "" && lastDevice != deviceID {
+			flags = append(flags, AttestationFlagDeviceChanged)
+		}
+	}
+
+	flagsJSON, _ := json.Marshal(flags)
+	_, err := database.DB.Exec(`
+		INSERT INTO submission_attestations (user_id, submission_type, submission_id, app_version, device_model,
+		                                     device_id, platform, integrity_provider, integrity_verdict,
+		                                     ip_address, user_agent, flags, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
+	`, userID, submissionType, submissionID, appVersion, r.Header.Get(headerDeviceModel), deviceID,
+		r.Header.Get(headerPlatform), strings.ToUpper(r.Header.Get(headerIntegrityProvider)), verdict,
+		clientIP(r), r.UserAgent(), flagsJSON)
+	if err != nil {
+		log.Printf("Warning: failed to record attestation for %s %d: %v", submissionType, submissionID, err)
+	}
+}
+
+// clientIP returns the caller's address, preferring the first X-Forwarded-For hop
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// GetSuspiciousSubmissions - Flagged PPE and quiz submissions from this supervisor's miners
+// GET /api/supervisor/integrity?days=7&all=true
+func GetSuspiciousSubmissions(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days < 1 || days > 90 {
+		days = 7
+	}
+
+	query := `
+		SELECT a.id, a.user_id, u.name, a.submission_type, a.submission_id, COALESCE(a.app_version, ''),
+		       COALESCE(a.device_model, ''), COALESCE(a.device_id, ''), COALESCE(a.platform, ''),
+		       COALESCE(a.integrity_provider, ''), COALESCE(a.integrity_verdict, ''),
+		       COALESCE(a.ip_address, ''), COALESCE(a.flags, '[]'::jsonb), a.created_at
+		FROM submission_attestations a
+		JOIN users u ON a.user_id = u.user_id
+		WHERE u.supervisor_id = $1 AND a.created_at > NOW() - make_interval(days => $2)
+	`
+	if r.URL.Query().Get("all") != "true" {
+		query += " AND jsonb_array_length(COALESCE(a.flags, '[]'::jsonb)) > 0"
+	}
+	query += " ORDER BY a.created_at DESC LIMIT 500"
+
+	rows, err := database.DB.Query(query, supervisorID, days)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	submissions := []SubmissionAttestation{}
+	flagCounts := map[string]int{}
+	for rows.Next() {
+		var a SubmissionAttestation
+		var flagsJSON []byte
+		if err := rows.Scan(&a.ID, &a.UserID, &a.UserName, &a.SubmissionType, &a.SubmissionID,
+			&a.AppVersion, &a.DeviceModel, &a.DeviceID, &a.Platform, &a.IntegrityProvider,
+			&a.IntegrityVerdict, &a.IPAddress, &flagsJSON, &a.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		json.Unmarshal(flagsJSON, &a.Flags)
+		if a.Flags == nil {
+			a.Flags = []string{}
+		}
+		for _, f := range a.Flags {
+			flagCounts[f]++
+		}
+		submissions = append(submissions, a)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"submissions": submissions,
+		"flag_counts": flagCounts,
+		"days":        days,
+	})
+}
@@ -0,0 +1,608 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// ==================== AUDITOR ACCESS ====================
+
+// External safety auditors don't get an account they log in to. An admin mints them a
+// time-boxed token limited to some kinds of records from a date range, e.g. Q3's
+// emergencies and training records, and can revoke it early. AUDITOR tokens only work on
+// GET /api/audit/... (see middleware.AuditorReadOnly); admins can read the same endpoints
+// unscoped.
+
+// Records an audit token can be scoped to
+const (
+	AuditScopeEmergencies = "emergencies"
+	AuditScopeTraining    = "training"
+)
+
+var auditScopes = []string{AuditScopeEmergencies, AuditScopeTraining}
+
+// Audit token states
+const (
+	AuditTokenActive  = "ACTIVE"
+	AuditTokenExpired = "EXPIRED"
+	AuditTokenRevoked = "REVOKED"
+)
+
+// maxAuditTokenDuration caps how long an auditor's token stays valid
+const maxAuditTokenDuration = 90 * 24 * time.Hour
+
+// maxAuditRecords bounds one page of records returned to an auditor
+const maxAuditRecords = 1000
+
+// AuditTokenRequest represents the request body for minting an auditor token
+type AuditTokenRequest struct {
+	AuditorName string   `json:"auditor_name"`
+	Email       string   `json:"email"`
+	Company     string   `json:"company"`
+	Scopes      []string `json:"scopes"`     // emergencies, training
+	DataFrom    string   `json:"data_from"`  // YYYY-MM-DD, first day of visible records
+	DataTo      string   `json:"data_to"`    // YYYY-MM-DD, last day of visible records
+	ExpiresAt   string   `json:"expires_at"` // RFC3339, at most 90 days away
+}
+
+// AuditToken is a minted auditor token as admins see it; the token itself is only
+// returned when it is minted
+type AuditToken struct {
+	ID          int        `json:"id"`
+	AuditorID   string     `json:"auditor_id"`
+	AuditorName string     `json:"auditor_name"`
+	Email       string     `json:"email"`
+	Company     string     `json:"company"`
+	Scopes      []string   `json:"scopes"`
+	DataFrom    string     `json:"data_from"`
+	DataTo      string     `json:"data_to"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	Status      string     `json:"status"`
+}
+
+const auditTokenSelect = `
+	SELECT t.id, t.auditor_id, u.name, u.email, COALESCE(t.company, ''), t.scopes,
+	       to_char(t.data_from, 'YYYY-MM-DD'), to_char(t.data_to, 'YYYY-MM-DD'), t.expires_at,
+	       t.created_by, t.created_at, t.revoked_at
+	FROM audit_tokens t
+	JOIN users u ON u.user_id = t.auditor_id
+`
+
+func scanAuditToken(scanner interface{ Scan(...interface{}) error }) (AuditToken, error) {
+	var t AuditToken
+	var revokedAt sql.NullTime
+	err := scanner.Scan(&t.ID, &t.AuditorID, &t.AuditorName, &t.Email, &t.Company, pq.Array(&t.Scopes),
+		&t.DataFrom, &t.DataTo, &t.ExpiresAt, &t.CreatedBy, &t.CreatedAt, &revokedAt)
+	if err != nil {
+		return t, err
+	}
+	switch {
+	case revokedAt.Valid:
+		t.RevokedAt = &revokedAt.Time
+		t.Status = AuditTokenRevoked
+	case time.Now().After(t.ExpiresAt):
+		t.Status = AuditTokenExpired
+	default:
+		t.Status = AuditTokenActive
+	}
+	return t, nil
+}
+
+// AdminCreateAuditToken - Mint a read-only token for an external auditor, limited to the
+// given scopes and date range. The auditor's account is created on first use of the email.
+// POST /api/admin/audit-tokens?org_id=3 (org_id only for platform admins)
+func AdminCreateAuditToken(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseImpersonated(w, r) {
+		return
+	}
+
+	var req AuditTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.AuditorName = strings.TrimSpace(req.AuditorName)
+	req.Email = models.NormalizeEmail(req.Email)
+	if req.AuditorName == "" || req.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "auditor_name and email are required")
+		return
+	}
+
+	scopes := []string{}
+	for _, s := range req.Scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !containsString(auditScopes, s) {
+			respondWithError(w, http.StatusBadRequest, "scopes accepts "+strings.Join(auditScopes, " and "))
+			return
+		}
+		if !containsString(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+
+	dataFrom, err := time.Parse("2006-01-02", req.DataFrom)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "data_from must be YYYY-MM-DD")
+		return
+	}
+	dataTo, err := time.Parse("2006-01-02", req.DataTo)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "data_to must be YYYY-MM-DD")
+		return
+	}
+	if dataTo.Before(dataFrom) {
+		respondWithError(w, http.StatusBadRequest, "data_to can't be before data_from")
+		return
+	}
+	expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "expires_at must be RFC3339")
+		return
+	}
+	if !expiresAt.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+	if time.Until(expiresAt) > maxAuditTokenDuration {
+		respondWithError(w, http.StatusBadRequest, "Audit tokens cannot last more than 90 days")
+		return
+	}
+
+	// Platform admins mint for an organization; everyone else for their own
+	orgID := requestOrg(r)
+	if platformAdmin(r) && r.URL.Query().Get("org_id") != "" {
+		id, err := strconv.Atoi(r.URL.Query().Get("org_id"))
+		if err != nil || id < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid org_id")
+			return
+		}
+		orgID = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// One auditor account per email and organization, reused for later audits
+	var auditorID string
+	var role models.Role
+	var auditorOrg sql.NullInt64
+	err = tx.QueryRow("SELECT user_id, role, org_id FROM users WHERE email = $1", req.Email).
+		Scan(&auditorID, &role, &auditorOrg)
+	switch {
+	case err == sql.ErrNoRows:
+		// The password is never handed out; auditors can only use their tokens
+		password, err := generateRandomHex(24)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error generating credentials")
+			return
+		}
+		hashed, err := hashPassword(password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error processing password")
+			return
+		}
+		auditor, err := models.NewUser(req.AuditorName, req.Email, "", hashed, "", "", models.RoleAuditor, nil)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		_, err = tx.Exec(`
+			INSERT INTO users (user_id, name, email, phone, password, role, org_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, auditor.UserID, auditor.Name, auditor.Email, auditor.Phone, auditor.Password, auditor.Role,
+			orgID, auditor.CreatedAt, auditor.UpdatedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating auditor: "+err.Error())
+			return
+		}
+		auditorID = auditor.UserID
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	case role != models.RoleAuditor:
+		respondWithError(w, http.StatusConflict, "This email belongs to an account that isn't an auditor")
+		return
+	case auditorOrg != orgID:
+		respondWithError(w, http.StatusConflict, "This auditor belongs to another organization")
+		return
+	}
+
+	token, info, err := middleware.GenerateSessionToken(auditorID, string(models.RoleAuditor), middleware.AudienceDashboard, expiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	// No token is handed out unless it's on record
+	var tokenID int
+	err = tx.QueryRow(`
+		INSERT INTO audit_tokens (jti, auditor_id, org_id, company, scopes, data_from, data_to, expires_at, created_by)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+		RETURNING id
+	`, info.ID, auditorID, orgID, truncate(strings.TrimSpace(req.Company), 255), pq.Array(scopes),
+		dataFrom, dataTo, info.ExpiresAt, adminID).Scan(&tokenID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving audit token: "+err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	auditToken, err := scanAuditToken(database.DB.QueryRow(auditTokenSelect+" WHERE t.id = $1", tokenID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":       token,
+		"audit_token": auditToken,
+		"message":     "Give the token to the auditor; it is not shown again",
+	})
+}
+
+// AdminGetAuditTokens - Auditor tokens minted for the organization, newest first
+// GET /api/admin/audit-tokens?status=ACTIVE
+func AdminGetAuditTokens(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{}
+	where := " WHERE 1=1" + tenantClause(r, "t.org_id", &args)
+	rows, err := database.DB.Query(auditTokenSelect+where+" ORDER BY t.created_at DESC", args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	statusFilter := strings.ToUpper(r.URL.Query().Get("status"))
+	tokens := []AuditToken{}
+	for rows.Next() {
+		t, err := scanAuditToken(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		if statusFilter != "" && t.Status != statusFilter {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"audit_tokens": tokens,
+		"count":        len(tokens),
+	})
+}
+
+// AdminRevokeAuditToken - Revoke an auditor's token before it expires
+// DELETE /api/admin/audit-tokens/{id}
+func AdminRevokeAuditToken(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	tokenID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid audit token ID")
+		return
+	}
+
+	var jti, auditorID string
+	var expiresAt time.Time
+	args := []interface{}{tokenID, adminID}
+	scope := tenantClause(r, "org_id", &args)
+	err = database.DB.QueryRow(`
+		UPDATE audit_tokens SET revoked_at = NOW(), revoked_by = $2
+		WHERE id = $1 AND revoked_at IS NULL`+scope+`
+		RETURNING jti, auditor_id, expires_at
+	`, args...).Scan(&jti, &auditorID, &expiresAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Active audit token not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Audit endpoints check revoked_at themselves; this also stops the token at the middleware
+	if err := revokeToken(auditorID, middleware.TokenInfo{ID: jti, ExpiresAt: expiresAt}, "audit_token_revoked"); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking token: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Audit token revoked",
+	})
+}
+
+// auditAccess is what the caller may read through the auditor API. A zero From or To
+// leaves that end of the range open; To is exclusive.
+type auditAccess struct {
+	Scopes    []string
+	From, To  time.Time
+	ExpiresAt *time.Time
+}
+
+// requestAuditAccess looks up the scope of the caller's audit token. Callers other than
+// auditors, e.g. admins, read every record of their organization. It writes the error
+// response itself when the token is no longer valid.
+func requestAuditAccess(w http.ResponseWriter, r *http.Request) (auditAccess, bool) {
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	if role != string(models.RoleAuditor) {
+		return auditAccess{Scopes: auditScopes}, true
+	}
+
+	token, _ := middleware.GetTokenFromContext(r.Context())
+	var access auditAccess
+	var expiresAt time.Time
+	err := database.DB.QueryRow(`
+		SELECT scopes, data_from, data_to, expires_at FROM audit_tokens
+		WHERE jti = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`, token.ID).Scan(pq.Array(&access.Scopes), &access.From, &access.To, &expiresAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusForbidden, "This audit token is no longer valid")
+		return access, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return access, false
+	}
+	access.To = access.To.AddDate(0, 0, 1)
+	access.ExpiresAt = &expiresAt
+	return access, true
+}
+
+// window narrows the access range to ?from= and ?to= (YYYY-MM-DD, inclusive) when given
+func (a auditAccess) window(r *http.Request) (time.Time, time.Time, error) {
+	from, to := a.From, a.To
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, err
+		}
+		if t.After(from) {
+			from = t
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, err
+		}
+		if t = t.AddDate(0, 0, 1); to.IsZero() || t.Before(to) {
+			to = t
+		}
+	}
+	return from, to, nil
+}
+
+// windowClause restricts column to [from, to), appending the arguments it uses
+func windowClause(column string, from, to time.Time, args *[]interface{}) string {
+	clause := ""
+	if !from.IsZero() {
+		*args = append(*args, from)
+		clause += " AND " + column + " >= $" + strconv.Itoa(len(*args))
+	}
+	if !to.IsZero() {
+		*args = append(*args, to)
+		clause += " AND " + column + " < $" + strconv.Itoa(len(*args))
+	}
+	return clause
+}
+
+// auditWindow checks the caller may read scope and returns the date range to read. It
+// writes the error response itself when they can't.
+func auditWindow(w http.ResponseWriter, r *http.Request, scope string) (time.Time, time.Time, bool) {
+	access, ok := requestAuditAccess(w, r)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	if !containsString(access.Scopes, scope) {
+		respondWithError(w, http.StatusForbidden, "This audit token doesn't cover "+scope)
+		return time.Time{}, time.Time{}, false
+	}
+	from, to, err := access.window(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "from and to must be YYYY-MM-DD")
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// AuditGetAccess - The records and date range the caller's audit token covers
+// GET /api/audit/access
+func AuditGetAccess(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAuditAccess(w, r)
+	if !ok {
+		return
+	}
+	response := map[string]interface{}{
+		"scopes":     access.Scopes,
+		"expires_at": access.ExpiresAt,
+	}
+	if !access.From.IsZero() {
+		response["data_from"] = access.From.Format("2006-01-02")
+		response["data_to"] = access.To.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// AuditEmergency is an emergency as shown to auditors
+type AuditEmergency struct {
+	ID            int                    `json:"id"`
+	Severity      string                 `json:"severity"`
+	Category      string                 `json:"category"`
+	Status        string                 `json:"status"`
+	Issue         string                 `json:"issue"`
+	Location      string                 `json:"location"`
+	MiningSite    string                 `json:"mining_site"`
+	ReportedBy    string                 `json:"reported_by"`
+	ReportedAt    time.Time              `json:"reported_at"`
+	ResolvedAt    *time.Time             `json:"resolved_at,omitempty"`
+	ClosureReason string                 `json:"closure_reason,omitempty"`
+	Details       map[string]interface{} `json:"details"`
+	Attachments   int                    `json:"attachments"`
+}
+
+// AuditGetEmergencies - Emergencies reported in the token's date range, oldest first. The
+// reporter and injury details are redacted unless the caller's role may see them.
+// GET /api/audit/emergencies?from=2026-07-01&to=2026-09-30&offset=0
+func AuditGetEmergencies(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := auditWindow(w, r, AuditScopeEmergencies)
+	if !ok {
+		return
+	}
+	redaction, err := reportRedaction(r, strings.Split(r.URL.Query().Get("redact"), ","))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	args := []interface{}{}
+	where := " WHERE 1=1" + tenantClause(r, "e.org_id", &args) + windowClause("e.reporting_time", from, to, &args)
+	args = append(args, maxAuditRecords, offset)
+	rows, err := database.DB.Query(`
+		SELECT e.id, COALESCE(e.severity, ''), COALESCE(e.category, ''), COALESCE(e.status, ''),
+		       COALESCE(e.issue, ''), COALESCE(e.location, ''), COALESCE(u.mining_site, ''), u.name,
+		       e.reporting_time, e.resolution_time, COALESCE(e.closure_reason, ''), e.details,
+		       (SELECT COUNT(*) FROM emergency_media m WHERE m.emergency_id = e.id)
+		FROM emergencies e
+		JOIN users u ON u.user_id = e.user_id`+where+`
+		ORDER BY e.reporting_time, e.id
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	emergencies := []AuditEmergency{}
+	for rows.Next() {
+		var e AuditEmergency
+		var reporter string
+		var resolvedAt sql.NullTime
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.Severity, &e.Category, &e.Status, &e.Issue, &e.Location, &e.MiningSite,
+			&reporter, &e.ReportedAt, &resolvedAt, &e.ClosureReason, &details, &e.Attachments); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		e.ReportedBy = redaction.reporter(reporter)
+		e.Details = redaction.details(e.Category, details)
+		if resolvedAt.Valid {
+			e.ResolvedAt = &resolvedAt.Time
+		}
+		emergencies = append(emergencies, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"emergencies": emergencies,
+		"count":       len(emergencies),
+		"offset":      offset,
+		"redacted":    redaction.applied(),
+	})
+}
+
+// AuditTrainingRecord is a completed training module as shown to auditors
+type AuditTrainingRecord struct {
+	ID             int       `json:"id"`
+	MinerID        string    `json:"miner_id"`
+	MinerName      string    `json:"miner_name"`
+	Role           string    `json:"role"`
+	MiningSite     string    `json:"mining_site"`
+	ModuleID       int       `json:"module_id"`
+	ModuleTitle    string    `json:"module_title"`
+	Score          *int      `json:"score"`
+	TotalQuestions *int      `json:"total_questions"`
+	Passed         bool      `json:"passed"`
+	CompletedAt    time.Time `json:"completed_at"`
+}
+
+// AuditGetTrainingRecords - Training modules completed in the token's date range, oldest first
+// GET /api/audit/training?from=2026-07-01&to=2026-09-30&offset=0
+func AuditGetTrainingRecords(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := auditWindow(w, r, AuditScopeTraining)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	args := []interface{}{}
+	where := " WHERE 1=1" + tenantClause(r, "u.org_id", &args) + windowClause("mc.completed_at", from, to, &args)
+	args = append(args, maxAuditRecords, offset)
+	rows, err := database.DB.Query(`
+		SELECT mc.id, u.user_id, u.name, u.role, COALESCE(u.mining_site, ''), vm.id, vm.title,
+		       mc.score, mc.total_questions, mc.passed, mc.completed_at
+		FROM module_completions mc
+		JOIN users u ON u.user_id = mc.miner_id
+		JOIN video_modules vm ON vm.id = mc.video_id`+where+`
+		ORDER BY mc.completed_at, mc.id
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	records := []AuditTrainingRecord{}
+	for rows.Next() {
+		var t AuditTrainingRecord
+		var score, total sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.MinerID, &t.MinerName, &t.Role, &t.MiningSite, &t.ModuleID, &t.ModuleTitle,
+			&score, &total, &t.Passed, &t.CompletedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		if score.Valid {
+			s := int(score.Int64)
+			t.Score = &s
+		}
+		if total.Valid {
+			n := int(total.Int64)
+			t.TotalQuestions = &n
+		}
+		records = append(records, t)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"training_records": records,
+		"count":            len(records),
+		"offset":           offset,
+	})
+}
@@ -6,9 +6,9 @@ import (
 	"MineSafeBackend/models"
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
-
-	"golang.org/x/crypto/bcrypt"
+	"strings"
 )
 
 type AuthResponse struct {
@@ -37,20 +37,14 @@ func SupervisorSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if email already exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", signup.Email).Scan(&exists)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Database error")
-		return
-	}
-	if exists {
-		respondWithError(w, http.StatusConflict, "Email already registered")
+	// Check if email or phone already exists
+	if code, msg := checkContactAvailable(&signup.Email, &signup.Phone, ""); code != 0 {
+		respondWithError(w, code, msg)
 		return
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signup.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(signup.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error processing password")
 		return
@@ -64,33 +58,71 @@ func SupervisorSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert into database
-	err = database.DB.QueryRow(
-		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Signing up never joins an existing organization: the supervisor's site must be new and
+	// starts an organization of its own. Existing sites are joined through an invitation.
+	var orgID int
+	if strings.TrimSpace(user.MiningSite) != "" {
+		err = tx.QueryRow(`
+			INSERT INTO sites (name, location) VALUES (TRIM($1), $2)
+			ON CONFLICT DO NOTHING
+			RETURNING org_id
+		`, user.MiningSite, user.Location).Scan(&orgID)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusConflict, "This mining site is already registered; ask its supervisor or admin for an invitation")
+			return
+		}
+	} else {
+		err = tx.QueryRow("INSERT INTO organizations (name) VALUES ($1) RETURNING id", user.Name).Scan(&orgID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Insert into database; the account can't be logged in to until the email is verified
+	err = tx.QueryRow(
+		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, created_at, updated_at, email_verified, org_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, false, $11)
 		 RETURNING id`,
 		user.UserID, user.Name, user.Email, user.Phone, user.Password, user.Role,
-		user.MiningSite, user.Location, user.CreatedAt, user.UpdatedAt,
+		user.MiningSite, user.Location, user.CreatedAt, user.UpdatedAt, orgID,
 	).Scan(&user.ID)
-
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating user: "+err.Error())
 		return
 	}
 
-	// Generate token
-	token, err := middleware.GenerateToken(user.UserID, string(user.Role))
+	// A supervisor may be the first user of a new site
+	if err := database.ProvisionOrganizationChecklists(user.MiningSite); err != nil {
+		log.Printf("Warning: failed to provision checklists for %s: %v", user.MiningSite, err)
+	}
+
+	// The account stays even if the email fails so the link can be resent
+	expiresAt, err := issueEmailVerification(user.UserID, user.Name, user.Email)
+	emailSent := err == nil
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error generating token")
-		return
+		log.Printf("Warning: verification email for %s not sent: %v", user.UserID, err)
 	}
 
 	user.Password = "" // Don't send password back
-	respondWithJSON(w, http.StatusCreated, AuthResponse{
-		Token:  token,
-		UserID: user.UserID,
-		Role:   string(user.Role),
-		User:   user,
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"user_id":               user.UserID,
+		"role":                  string(user.Role),
+		"user":                  user,
+		"verification_required": true,
+		"verification_expires":  expiresAt,
+		"email_sent":            emailSent,
+		"message":               "Check your email for a link to verify your address before logging in",
 	})
 }
 
@@ -108,14 +140,17 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	// Find user by email
 	var user models.User
+	var emailVerified, active bool
 	err := database.DB.QueryRow(
-		`SELECT id, user_id, name, email, phone, password, role, mining_site, location, supervisor_id, created_at, updated_at
-		 FROM users WHERE email = $1`,
-		login.Email,
+		`SELECT id, user_id, name, email, phone, password, role, mining_site, location, supervisor_id, created_at, updated_at, email_verified,
+		        COALESCE(is_active, true)
+		 FROM users WHERE LOWER(email) = $1 AND deleted_at IS NULL`,
+		models.NormalizeEmail(login.Email),
 	).Scan(&user.ID, &user.UserID, &user.Name, &user.Email, &user.Phone, &user.Password,
-		&user.Role, &user.MiningSite, &user.Location, &user.SupervisorID, &user.CreatedAt, &user.UpdatedAt)
+		&user.Role, &user.MiningSite, &user.Location, &user.SupervisorID, &user.CreatedAt, &user.UpdatedAt, &emailVerified, &active)
 
 	if err == sql.ErrNoRows {
+		recordLoginEvent(r, LoginMethodPassword, login.Email, "", "", LoginUnknownAccount)
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
@@ -125,27 +160,65 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(login.Password)); err != nil {
+	if !checkPassword(user.UserID, user.Password, login.Password) {
+		recordLoginEvent(r, LoginMethodPassword, login.Email, user.UserID, string(user.Role), LoginBadPassword)
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
-	// Generate token
-	token, err := middleware.GenerateToken(user.UserID, string(user.Role))
+	// Only checked once the password matches, so it doesn't reveal who has signed up
+	if !emailVerified {
+		recordLoginEvent(r, LoginMethodPassword, login.Email, user.UserID, string(user.Role), LoginEmailUnverified)
+		respondWithJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error":          "Verify your email address before logging in. Check your inbox or request a new link.",
+			"email_verified": false,
+		})
+		return
+	}
+
+	// Visitors are deactivated when their pass ends; they get the pass check's message below
+	if !active && user.Role != models.RoleVisitor {
+		recordLoginEvent(r, LoginMethodPassword, login.Email, user.UserID, string(user.Role), LoginDeactivated)
+		respondWithError(w, http.StatusForbidden, "This account has been deactivated")
+		return
+	}
+
+	// Auditors only use the scoped tokens an admin minted for them
+	if user.Role == models.RoleAuditor {
+		recordLoginEvent(r, LoginMethodPassword, login.Email, user.UserID, string(user.Role), LoginDenied)
+		respondWithError(w, http.StatusForbidden, "Auditors sign in with the access token they were given")
+		return
+	}
+
+	// Generate token (visitor tokens end with their pass)
+	var token string
+	if user.Role == models.RoleVisitor {
+		validUntil, err := activeVisitorPassExpiry(user.UserID)
+		if err != nil {
+			recordLoginEvent(r, LoginMethodPassword, login.Email, user.UserID, string(user.Role), LoginDenied)
+			respondWithError(w, http.StatusForbidden, "Visitor pass is not active")
+			return
+		}
+		token, err = issueSessionToken(r, user.UserID, string(user.Role), tokenAudience(r, string(user.Role)), validUntil)
+	} else {
+		token, err = issueLoginToken(r, user.UserID, string(user.Role))
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	// If user is a miner, fetch supervisor name
+	// If user is crew (miner or operator), fetch supervisor name
 	supervisorName := ""
-	if user.Role == models.RoleMiner && user.SupervisorID != nil {
+	if middleware.HasPermission(string(user.Role), middleware.PermChecklistSubmit) && user.SupervisorID != nil {
 		database.DB.QueryRow(
 			"SELECT name FROM users WHERE user_id = $1",
 			*user.SupervisorID,
 		).Scan(&supervisorName)
 	}
 
+	recordLoginEvent(r, LoginMethodPassword, login.Email, user.UserID, string(user.Role), LoginSuccess)
+
 	user.Password = "" // Don't send password back
 	respondWithJSON(w, http.StatusOK, AuthResponse{
 		Token:          token,
@@ -157,6 +230,18 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// tokenAudience picks the client app a login's token is for: the X-Client-ID header when it
+// names one, otherwise the mobile app for crew and visitors and the dashboard for everyone else
+func tokenAudience(r *http.Request, role string) string {
+	if client := r.Header.Get("X-Client-ID"); middleware.IsAudience(client) {
+		return client
+	}
+	if role == string(models.RoleVisitor) || middleware.HasPermission(role, middleware.PermChecklistSubmit) {
+		return middleware.AudienceMobile
+	}
+	return middleware.AudienceDashboard
+}
+
 func GetMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -181,7 +266,36 @@ func GetMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, user)
+	// Apps show a banner while an admin is signed in as the user
+	respondWithJSON(w, http.StatusOK, struct {
+		models.User
+		Impersonated   bool   `json:"impersonated"`
+		ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	}{user, impersonator(r) != "", impersonator(r)})
+}
+
+// checkContactAvailable normalizes email and phone in place and checks neither belongs to
+// another account. It returns 0 when they can be used, otherwise the status and message to
+// respond with.
+func checkContactAvailable(email, phone *string, exceptUserID string) (int, string) {
+	*email = models.NormalizeEmail(*email)
+	normalized, err := models.NormalizePhone(*phone)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	*phone = normalized
+
+	taken, err := database.ContactInUse(*email, *phone, exceptUserID)
+	if err != nil {
+		return http.StatusInternalServerError, "Database error"
+	}
+	switch taken {
+	case "email":
+		return http.StatusConflict, "Email already registered"
+	case "phone":
+		return http.StatusConflict, "Phone number already registered"
+	}
+	return 0, ""
 }
 
 func respondWithError(w http.ResponseWriter, code int, message string) {
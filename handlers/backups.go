@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== DATABASE BACKUPS ====================
+
+// Many self-hosted deployments have no DBA, so a platform admin can take a database backup
+// from the dashboard. pg_dump runs in the background and writes to BACKUP_DIR; only the
+// newest BACKUP_RETENTION successful backups are kept. Restoring is deliberately a CLI step
+// with the API stopped: ./main restore-backup <file>.
+
+// Backup states
+const (
+	BackupRunning   = "RUNNING"
+	BackupSucceeded = "SUCCEEDED"
+	BackupFailed    = "FAILED"
+	BackupPruned    = "PRUNED" // deleted to keep within BACKUP_RETENTION
+)
+
+// backupTimeout bounds one pg_dump run; a RUNNING backup older than this was interrupted
+const backupTimeout = 2 * time.Hour
+
+// DatabaseBackup is a backup taken from the dashboard
+type DatabaseBackup struct {
+	ID          int        `json:"id"`
+	FileName    string     `json:"file_name"`
+	Status      string     `json:"status"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Error       string     `json:"error,omitempty"`
+	RequestedBy string     `json:"requested_by"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+const databaseBackupSelect = `
+	SELECT id, file_name, status, COALESCE(size_bytes, 0), COALESCE(error, ''), requested_by,
+	       started_at, finished_at
+	FROM database_backups
+`
+
+func scanDatabaseBackup(scanner interface{ Scan(...interface{}) error }) (DatabaseBackup, error) {
+	var b DatabaseBackup
+	var finishedAt sql.NullTime
+	err := scanner.Scan(&b.ID, &b.FileName, &b.Status, &b.SizeBytes, &b.Error, &b.RequestedBy,
+		&b.StartedAt, &finishedAt)
+	if finishedAt.Valid {
+		b.FinishedAt = &finishedAt.Time
+	}
+	return b, err
+}
+
+// backupPath is where a backup file lives; names are generated, never taken from requests
+func backupPath(fileName string) string {
+	return filepath.Join(config.Get().BackupDir, filepath.Base(fileName))
+}
+
+// AdminCreateBackup - Start a database backup in the background. Only one runs at a time.
+// POST /api/admin/backups
+func AdminCreateBackup(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseTenantAdmin(w, r) || refuseImpersonated(w, r) {
+		return
+	}
+
+	// An instance that stopped mid-backup leaves its row RUNNING
+	database.DB.Exec(`
+		UPDATE database_backups SET status = $1, error = 'interrupted', finished_at = NOW()
+		WHERE status = $2 AND started_at < NOW() - $3 * INTERVAL '1 second'
+	`, BackupFailed, BackupRunning, backupTimeout.Seconds())
+
+	fileName := "minesafe-" + time.Now().UTC().Format("20060102T150405Z") + ".dump"
+	backup, err := scanDatabaseBackup(database.DB.QueryRow(`
+		INSERT INTO database_backups (file_name, status, requested_by, started_at)
+		SELECT $1, $2, $3, NOW()
+		WHERE NOT EXISTS (SELECT 1 FROM database_backups WHERE status = $2)
+		RETURNING id, file_name, status, COALESCE(size_bytes, 0), COALESCE(error, ''), requested_by,
+		          started_at, finished_at
+	`, fileName, BackupRunning, adminID))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "A backup is already running")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	go runBackup(backup.ID, backup.FileName)
+
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"backup":  backup,
+		"message": "Backup started; check GET /api/admin/backups for its status",
+	})
+}
+
+// runBackup dumps the database to the backup's file and records the outcome. The dump is
+// written under a temporary name so a partial file is never listed as a backup.
+func runBackup(id int, fileName string) {
+	path := backupPath(fileName)
+	partial := path + ".partial"
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+		err = database.DumpDatabase(ctx, partial)
+		cancel()
+	}
+	if err == nil {
+		err = os.Rename(partial, path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		log.Printf("Warning: database backup %d failed: %v", id, err)
+		database.DB.Exec(`
+			UPDATE database_backups SET status = $2, error = $3, finished_at = NOW() WHERE id = $1
+		`, id, BackupFailed, truncate(err.Error(), 2000))
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	_, err = database.DB.Exec(`
+		UPDATE database_backups SET status = $2, size_bytes = $3, finished_at = NOW() WHERE id = $1
+	`, id, BackupSucceeded, size)
+	if err != nil {
+		log.Printf("Warning: failed to record database backup %d: %v", id, err)
+		return
+	}
+	log.Printf("Database backup %s written (%d bytes)", fileName, size)
+	pruneBackups()
+}
+
+// pruneBackups deletes successful backups beyond the newest BACKUP_RETENTION
+func pruneBackups() {
+	rows, err := database.DB.Query(`
+		SELECT id, file_name FROM database_backups WHERE status = $1
+		ORDER BY started_at DESC OFFSET $2
+	`, BackupSucceeded, config.Get().BackupRetention)
+	if err != nil {
+		log.Printf("Warning: failed to list old database backups: %v", err)
+		return
+	}
+	type oldBackup struct {
+		id       int
+		fileName string
+	}
+	old := []oldBackup{}
+	for rows.Next() {
+		var b oldBackup
+		if err := rows.Scan(&b.id, &b.fileName); err == nil {
+			old = append(old, b)
+		}
+	}
+	rows.Close()
+
+	for _, b := range old {
+		if err := os.Remove(backupPath(b.fileName)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to delete old database backup %s: %v", b.fileName, err)
+			continue
+		}
+		database.DB.Exec("UPDATE database_backups SET status = $2 WHERE id = $1", b.id, BackupPruned)
+	}
+}
+
+// AdminGetBackups - Backups taken from the dashboard, newest first, and how to restore one
+// GET /api/admin/backups
+func AdminGetBackups(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	rows, err := database.DB.Query(databaseBackupSelect + " ORDER BY started_at DESC LIMIT 100")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	backups := []DatabaseBackup{}
+	for rows.Next() {
+		b, err := scanDatabaseBackup(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		backups = append(backups, b)
+	}
+
+	cfg := config.Get()
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"backups":   backups,
+		"retention": cfg.BackupRetention,
+		"directory": cfg.BackupDir,
+		"restore":   "Stop the API, then run: ./main restore-backup <file_name>",
+	})
+}
+
+// AdminDownloadBackup - Download a backup file, e.g. to keep a copy off the server
+// GET /api/admin/backups/{id}/download
+func AdminDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid backup ID")
+		return
+	}
+	backup, err := scanDatabaseBackup(database.DB.QueryRow(databaseBackupSelect+" WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Backup not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if backup.Status != BackupSucceeded {
+		respondWithError(w, http.StatusConflict, "Backup is "+backup.Status)
+		return
+	}
+
+	file, err := os.Open(backupPath(backup.FileName))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Backup file is missing")
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backup.FileName))
+	http.ServeContent(w, r, backup.FileName, backup.StartedAt, file)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"MineSafeBackend/middleware"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ==================== BATCH REQUESTS ====================
+
+// maxBatchRequests bounds how much work one batch call can queue
+const maxBatchRequests = 20
+
+// batchSubRequestKey marks the context of a request sent from inside a batch, so a batch
+// can't reach /api/batch again however its path is spelled
+type batchSubRequestKey struct{}
+
+// BatchSubRequest is one API call inside a batch
+type BatchSubRequest struct {
+	ID     string          `json:"id,omitempty"` // echoed back so the client can match responses
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchSubResponse is the result of one sub-request
+type BatchSubResponse struct {
+	ID     string      `json:"id,omitempty"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body"`
+}
+
+var batchMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+}
+
+// batchRecorder captures a sub-request's response in memory
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *batchRecorder) Header() http.Header { return b.header }
+
+func (b *batchRecorder) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *batchRecorder) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Batch - Run several API calls in one round trip, in order, with the caller's credentials.
+// Each sub-request goes through the full router, so auth, rate limits and role checks apply
+// exactly as if it had been sent on its own.
+// POST /api/batch
+func Batch(router http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(batchSubRequestKey{}) != nil {
+			respondWithError(w, http.StatusBadRequest, "Batches cannot be nested")
+			return
+		}
+
+		var requests []BatchSubRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		if len(requests) == 0 {
+			respondWithError(w, http.StatusBadRequest, "At least one request is required")
+			return
+		}
+		if len(requests) > maxBatchRequests {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A batch can contain at most %d requests", maxBatchRequests))
+			return
+		}
+
+		// Validate everything first so a bad entry doesn't leave the batch half applied
+		for i, sub := range requests {
+			sub.Method = strings.ToUpper(sub.Method)
+			if !batchMethods[sub.Method] {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Request %d: unsupported method", i))
+				return
+			}
+			// Check the decoded path the router will see, not the raw string
+			parsed, err := url.Parse(sub.Path)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Request %d: invalid path", i))
+				return
+			}
+			path, _ := middleware.SplitAPIVersion(parsed.Path)
+			if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/api/batch") {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Request %d: path must be an /api/ route other than /api/batch", i))
+				return
+			}
+			requests[i].Method = sub.Method
+		}
+
+		responses := make([]BatchSubResponse, 0, len(requests))
+		for _, sub := range requests {
+			req, err := http.NewRequest(sub.Method, sub.Path, bytes.NewReader(sub.Body))
+			if err != nil {
+				responses = append(responses, BatchSubResponse{ID: sub.ID, Status: http.StatusBadRequest,
+					Body: map[string]string{"error": "Invalid request"}})
+				continue
+			}
+			req = req.WithContext(context.WithValue(r.Context(), batchSubRequestKey{}, true))
+			req.RemoteAddr = r.RemoteAddr
+			for key, values := range r.Header {
+				if key == "Content-Length" {
+					continue
+				}
+				req.Header[key] = values
+			}
+			if len(sub.Body) > 0 {
+				req.Header.Set("Content-Type", "application/json")
+			}
+
+			rec := &batchRecorder{header: http.Header{}}
+			router.ServeHTTP(rec, req)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			var body interface{}
+			if raw := bytes.TrimSpace(rec.body.Bytes()); len(raw) > 0 {
+				if json.Valid(raw) {
+					body = json.RawMessage(raw)
+				} else {
+					body = string(raw)
+				}
+			}
+			responses = append(responses, BatchSubResponse{ID: sub.ID, Status: rec.status, Body: body})
+		}
+
+		respondWithJSON(w, http.StatusOK, responses)
+	}
+}
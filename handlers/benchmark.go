@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ==================== CROSS-SITE BENCHMARKING ====================
+
+// benchmarkPeriods are the preset periods, in days ending today
+var benchmarkPeriods = map[string]int{"7d": 7, "30d": 30, "90d": 90, "365d": 365}
+
+// benchmarkKPI describes one normalized measure and which direction is better
+type benchmarkKPI struct {
+	key          string
+	higherBetter bool
+}
+
+// Every KPI is normalized by headcount or expressed as a rate so large and small sites compare
+var benchmarkKPIs = []benchmarkKPI{
+	{"checklist_pct", true},
+	{"ppe_pct", true},
+	{"modules_per_miner", true},
+	{"incidents_per_100_crew", false},
+	{"critical_incidents_per_100_crew", false},
+	{"avg_resolution_minutes", false},
+}
+
+// SiteBenchmark is one site's KPIs for the period with its percentile rank on each; a KPI
+// is missing when the site had no data for it
+type SiteBenchmark struct {
+	Site        string              `json:"site"`
+	Crew        int                 `json:"crew"`
+	KPIs        map[string]*float64 `json:"kpis"`
+	Percentiles map[string]*float64 `json:"percentiles"`
+	Score       *float64            `json:"score"`
+	Rank        int                 `json:"rank,omitempty"`
+	Standing    string              `json:"standing"` // LEADING, MIDDLE, LAGGING or UNRANKED
+}
+
+func roundTo1(v float64) *float64 {
+	v = math.Round(v*10) / 10
+	return &v
+}
+
+// percentileRanks gives each value the share of other sites it does better than, 0-100.
+// Ties split the difference so equal sites get equal ranks.
+func percentileRanks(values []float64, higherBetter bool) []float64 {
+	ranks := make([]float64, len(values))
+	if len(values) == 1 {
+		ranks[0] = 100
+		return ranks
+	}
+	for i, v := range values {
+		better, equal := 0, 0
+		for j, other := range values {
+			switch {
+			case i == j:
+			case v == other:
+				equal++
+			case (v > other) == higherBetter:
+				better++
+			}
+		}
+		ranks[i] = (float64(better) + float64(equal)/2) / float64(len(values)-1) * 100
+	}
+	return ranks
+}
+
+// median of values, which are reordered
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// AdminGetBenchmark - Compare the organization's sites on normalized safety KPIs with percentile rankings, to
+// find lagging sites and the leading sites they can learn from
+// GET /api/admin/benchmark?period=30d
+// GET /api/admin/benchmark?from=2025-01-01&to=2025-03-31
+func AdminGetBenchmark(w http.ResponseWriter, r *http.Request) {
+	var from, to string
+	q := r.URL.Query()
+	if q.Get("from") != "" || q.Get("to") != "" {
+		var ok bool
+		if from, to, ok = complianceRange(w, r); !ok {
+			return
+		}
+	} else {
+		period := q.Get("period")
+		if period == "" {
+			period = "30d"
+		}
+		days, ok := benchmarkPeriods[period]
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "period must be 7d, 30d, 90d or 365d")
+			return
+		}
+		now := time.Now()
+		from = now.AddDate(0, 0, 1-days).Format("2006-01-02")
+		to = now.Format("2006-01-02")
+	}
+
+	// Compliance comes from the daily summary and leaves out days on leave; incidents are
+	// attributed to the reporter's site
+	rows, err := database.DB.Query(`
+		WITH crew AS (
+			SELECT mining_site, COUNT(*) AS headcount FROM users
+			WHERE role = ANY($3) AND is_active = true AND COALESCE(mining_site, '') <> ''
+			GROUP BY mining_site
+		), compliance AS (
+			SELECT mining_site, AVG(checklist_pct) AS checklist_pct, AVG(ppe_pct) AS ppe_pct,
+			       SUM(modules_completed)::float / NULLIF(COUNT(DISTINCT user_id), 0) AS modules_per_miner
+			FROM daily_compliance_summary
+			WHERE date BETWEEN $1 AND $2 AND NOT on_leave
+			GROUP BY mining_site
+		), incidents AS (
+			SELECT u.mining_site, COUNT(*) AS total,
+			       COUNT(*) FILTER (WHERE UPPER(e.severity) = 'CRITICAL') AS critical,
+			       AVG(EXTRACT(EPOCH FROM (e.resolution_time - e.reporting_time)) / 60)
+			           FILTER (WHERE e.status = 'RESOLVED' AND e.resolution_time IS NOT NULL) AS avg_resolution
+			FROM emergencies e
+			JOIN users u ON u.user_id = e.user_id
+			WHERE e.reporting_time >= $1::date AND e.reporting_time < $2::date + 1
+			GROUP BY u.mining_site
+		)
+		SELECT s.name, COALESCE(c.headcount, 0), cm.checklist_pct, cm.ppe_pct, cm.modules_per_miner,
+		       COALESCE(i.total, 0), COALESCE(i.critical, 0), i.avg_resolution
+		FROM sites s
+		LEFT JOIN crew c ON c.mining_site = s.name
+		LEFT JOIN compliance cm ON cm.mining_site = s.name
+		LEFT JOIN incidents i ON i.mining_site = s.name
+		WHERE $4 = 0 OR s.org_id = $4
+		ORDER BY s.name
+	`, from, to, crewRoles(), middleware.GetOrgIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	sites := []*SiteBenchmark{}
+	for rows.Next() {
+		var s SiteBenchmark
+		var checklist, ppe, modules, resolution sql.NullFloat64
+		var incidents, critical int
+		if err := rows.Scan(&s.Site, &s.Crew, &checklist, &ppe, &modules, &incidents, &critical, &resolution); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		s.KPIs = map[string]*float64{}
+		s.Percentiles = map[string]*float64{}
+		for key, v := range map[string]sql.NullFloat64{
+			"checklist_pct": checklist, "ppe_pct": ppe, "modules_per_miner": modules, "avg_resolution_minutes": resolution,
+		} {
+			if v.Valid {
+				s.KPIs[key] = roundTo1(v.Float64)
+			}
+		}
+		// Rates per head need a crew to divide by
+		if s.Crew > 0 {
+			s.KPIs["incidents_per_100_crew"] = roundTo1(float64(incidents) * 100 / float64(s.Crew))
+			s.KPIs["critical_incidents_per_100_crew"] = roundTo1(float64(critical) * 100 / float64(s.Crew))
+		}
+		sites = append(sites, &s)
+	}
+	if err := rows.Err(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Sites without crew have nothing meaningful to compare and are left unranked
+	medians := map[string]*float64{}
+	for _, kpi := range benchmarkKPIs {
+		var ranked []*SiteBenchmark
+		var values []float64
+		for _, s := range sites {
+			if v := s.KPIs[kpi.key]; v != nil && s.Crew > 0 {
+				ranked = append(ranked, s)
+				values = append(values, *v)
+			}
+		}
+		if len(values) == 0 {
+			medians[kpi.key] = nil
+			continue
+		}
+		for i, p := range percentileRanks(values, kpi.higherBetter) {
+			ranked[i].Percentiles[kpi.key] = roundTo1(p)
+		}
+		medians[kpi.key] = roundTo1(median(values))
+	}
+
+	// The overall score is the mean of whatever percentiles a site has
+	for _, s := range sites {
+		s.Standing = "UNRANKED"
+		if len(s.Percentiles) == 0 {
+			continue
+		}
+		total := 0.0
+		for _, p := range s.Percentiles {
+			total += *p
+		}
+		s.Score = roundTo1(total / float64(len(s.Percentiles)))
+		switch {
+		case *s.Score >= 75:
+			s.Standing = "LEADING"
+		case *s.Score <= 25:
+			s.Standing = "LAGGING"
+		default:
+			s.Standing = "MIDDLE"
+		}
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		if (sites[i].Score == nil) != (sites[j].Score == nil) {
+			return sites[i].Score != nil
+		}
+		return sites[i].Score != nil && *sites[i].Score > *sites[j].Score
+	})
+	for i, s := range sites {
+		if s.Score != nil {
+			s.Rank = i + 1
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"sites":   sites,
+		"medians": medians,
+	})
+}
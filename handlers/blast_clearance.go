@@ -0,0 +1,670 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// ==================== BLAST CLEARANCE ====================
+
+// Before a blast a supervisor opens a clearance for the zone, which locks allocations to it
+// and tells everyone present to get out. Zones have no boundaries to test positions against,
+// so a miner counts as present if they are allocated to the zone and checked in today (PPE
+// submission) or have a lone-worker session running, until they or a supervisor confirm
+// they are out. With nobody left and at least one sign-off the zone is cleared for firing;
+// the all-clear reopens it. Every step is kept as an event for the audit trail.
+
+// Blast clearance statuses
+const (
+	BlastClearing  = "CLEARING"  // evacuating, allocations locked
+	BlastCleared   = "CLEARED"   // verified empty, firing may proceed
+	BlastReopened  = "REOPENED"  // all-clear given
+	BlastCancelled = "CANCELLED" // called off before the all-clear
+)
+
+// Blast clearance audit events
+const (
+	BlastEventInitiated    = "INITIATED"
+	BlastEventEvacuated    = "EVACUATED"
+	BlastEventSignedOff    = "SIGNED_OFF"
+	BlastEventClearRefused = "CLEAR_REFUSED"
+	BlastEventCleared      = "CLEARED"
+	BlastEventAllClear     = "ALL_CLEAR"
+	BlastEventCancelled    = "CANCELLED"
+)
+
+// blastOpenStatusesClause matches clearances that keep their zone locked
+const blastOpenStatusesClause = "status IN ('" + BlastClearing + "', '" + BlastCleared + "')"
+
+// BlastClearance is a zone's clearance for one blast
+type BlastClearance struct {
+	ID          int              `json:"id"`
+	ZoneID      int              `json:"zone_id"`
+	ZoneName    string           `json:"zone_name"`
+	Status      string           `json:"status"`
+	BlastAt     *time.Time       `json:"blast_at,omitempty"`
+	Notes       string           `json:"notes,omitempty"`
+	InitiatedBy string           `json:"initiated_by"`
+	InitiatedAt time.Time        `json:"initiated_at"`
+	ClearedAt   *time.Time       `json:"cleared_at,omitempty"`
+	ClosedAt    *time.Time       `json:"closed_at,omitempty"`
+	Occupants   []ZoneOccupant   `json:"occupants,omitempty"`
+	SignOffs    []BlastSignOff   `json:"sign_offs,omitempty"`
+	Events      []BlastAuditItem `json:"events,omitempty"`
+}
+
+// ZoneOccupant is someone still counted as inside a zone being cleared
+type ZoneOccupant struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Source string `json:"source"` // CHECKED_IN or LONE_WORKER
+}
+
+// BlastSignOff is a person confirming the area they are responsible for is clear
+type BlastSignOff struct {
+	UserID   string    `json:"user_id"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	Notes    string    `json:"notes,omitempty"`
+	SignedAt time.Time `json:"signed_at"`
+}
+
+// BlastAuditItem is one step in a clearance's audit trail
+type BlastAuditItem struct {
+	Event     string    `json:"event"`
+	UserID    string    `json:"user_id,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func recordBlastEvent(clearanceID int, event, userID, details string) {
+	_, err := database.DB.Exec(`
+		INSERT INTO blast_clearance_events (clearance_id, event, user_id, details)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+	`, clearanceID, event, userID, details)
+	if err != nil {
+		log.Printf("Warning: failed to record blast clearance event for %d: %v", clearanceID, err)
+	}
+}
+
+// zoneOccupants lists who still counts as inside the zone for a clearance
+func zoneOccupants(zoneID, clearanceID int, today string) ([]ZoneOccupant, error) {
+	rows, err := database.DB.Query(`
+		SELECT u.user_id, u.name,
+		       CASE WHEN EXISTS(SELECT 1 FROM lone_worker_sessions s WHERE s.user_id = u.user_id AND s.ended_at IS NULL)
+		            THEN 'LONE_WORKER' ELSE 'CHECKED_IN' END
+		FROM users u
+		WHERE u.zone_id = $1 AND u.deleted_at IS NULL
+		AND (EXISTS(SELECT 1 FROM ppe_stats p WHERE p.user_id = u.user_id AND p.date = $2)
+		     OR EXISTS(SELECT 1 FROM lone_worker_sessions s WHERE s.user_id = u.user_id AND s.ended_at IS NULL))
+		AND NOT EXISTS(SELECT 1 FROM miner_leave l WHERE l.user_id = u.user_id AND $2::date BETWEEN l.start_date AND l.end_date)
+		AND NOT EXISTS(SELECT 1 FROM blast_clearance_evacuations e WHERE e.clearance_id = $3 AND e.user_id = u.user_id)
+		ORDER BY u.name
+	`, zoneID, today, clearanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	occupants := []ZoneOccupant{}
+	for rows.Next() {
+		var o ZoneOccupant
+		if err := rows.Scan(&o.UserID, &o.Name, &o.Source); err != nil {
+			return nil, err
+		}
+		occupants = append(occupants, o)
+	}
+	return occupants, nil
+}
+
+// zoneBlastLocked reports whether a clearance is in progress for the zone, which blocks
+// allocating anyone to it
+func zoneBlastLocked(zoneID int) (bool, error) {
+	var locked bool
+	err := database.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM blast_clearances WHERE zone_id = $1 AND "+blastOpenStatusesClause+")", zoneID,
+	).Scan(&locked)
+	return locked, err
+}
+
+// supervisorClearance loads a clearance in a zone the supervisor can see
+func supervisorClearance(r *http.Request, supervisorID string) (BlastClearance, error) {
+	var c BlastClearance
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return c, sql.ErrNoRows
+	}
+	var blastAt, clearedAt, closedAt sql.NullTime
+	err = database.DB.QueryRow(`
+		SELECT id, zone_id, status, blast_at, COALESCE(notes, ''), initiated_by, initiated_at, cleared_at,
+		       COALESCE(reopened_at, cancelled_at)
+		FROM blast_clearances WHERE id = $1
+	`, id).Scan(&c.ID, &c.ZoneID, &c.Status, &blastAt, &c.Notes, &c.InitiatedBy, &c.InitiatedAt, &clearedAt, &closedAt)
+	if err != nil {
+		return c, err
+	}
+	if c.ZoneName, err = supervisorZone(r, supervisorID, c.ZoneID); err != nil {
+		return c, err
+	}
+	if blastAt.Valid {
+		c.BlastAt = &blastAt.Time
+	}
+	if clearedAt.Valid {
+		c.ClearedAt = &clearedAt.Time
+	}
+	if closedAt.Valid {
+		c.ClosedAt = &closedAt.Time
+	}
+	return c, nil
+}
+
+// respondClearanceLookupError answers a failed supervisorClearance
+func respondClearanceLookupError(w http.ResponseWriter, err error) {
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Blast clearance not found")
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Database error")
+}
+
+// InitiateBlastClearance - Start clearing a zone for a blast: locks allocations and tells
+// everyone present to leave. blast_at (RFC 3339) is optional.
+// POST /api/supervisor/zones/{id}/blast-clearance
+func InitiateBlastClearance(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid zone id")
+		return
+	}
+
+	var req struct {
+		BlastAt *time.Time `json:"blast_at"`
+		Notes   string     `json:"notes"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	zoneName, err := supervisorZone(r, supervisorID, zoneID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var id int
+	err = database.DB.QueryRow(`
+		INSERT INTO blast_clearances (zone_id, status, blast_at, notes, initiated_by)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (zone_id) WHERE `+blastOpenStatusesClause+` DO NOTHING
+		RETURNING id
+	`, zoneID, BlastClearing, req.BlastAt, truncate(strings.TrimSpace(req.Notes), 1000), supervisorID).Scan(&id)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "A blast clearance is already in progress for this zone")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting blast clearance: "+err.Error())
+		return
+	}
+	recordBlastEvent(id, BlastEventInitiated, supervisorID, req.Notes)
+
+	occupants, err := zoneOccupants(zoneID, id, userToday(supervisorID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	message := fmt.Sprintf("Blast clearance started for %s. Leave the zone now and confirm in the app once you are out.", zoneName)
+	if req.BlastAt != nil {
+		message = fmt.Sprintf("Blast in %s at %s UTC. Leave the zone now and confirm in the app once you are out.",
+			zoneName, req.BlastAt.UTC().Format("15:04"))
+	}
+	for _, o := range occupants {
+		notifyUser(o.UserID, "BLAST_CLEARANCE", "Evacuate for blast", message, "blast_clearance", strconv.Itoa(id))
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":   true,
+		"id":        id,
+		"occupants": occupants,
+		"message":   "Zone locked for blast clearance",
+	})
+}
+
+// GetBlastClearance - A clearance with who is still inside, sign-offs and the audit trail
+// GET /api/supervisor/blast-clearances/{id}
+func GetBlastClearance(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	c, err := supervisorClearance(r, supervisorID)
+	if err != nil {
+		respondClearanceLookupError(w, err)
+		return
+	}
+
+	if c.Status == BlastClearing || c.Status == BlastCleared {
+		if c.Occupants, err = zoneOccupants(c.ZoneID, c.ID, userToday(supervisorID)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.user_id, COALESCE(u.name, ''), s.role, COALESCE(s.notes, ''), s.signed_at
+		FROM blast_clearance_signoffs s
+		LEFT JOIN users u ON u.user_id = s.user_id
+		WHERE s.clearance_id = $1
+		ORDER BY s.signed_at
+	`, c.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	c.SignOffs = []BlastSignOff{}
+	for rows.Next() {
+		var s BlastSignOff
+		if err := rows.Scan(&s.UserID, &s.Name, &s.Role, &s.Notes, &s.SignedAt); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		c.SignOffs = append(c.SignOffs, s)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT event, COALESCE(user_id, ''), COALESCE(details, ''), created_at
+		FROM blast_clearance_events WHERE clearance_id = $1
+		ORDER BY created_at, id
+	`, c.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	c.Events = []BlastAuditItem{}
+	for rows.Next() {
+		var e BlastAuditItem
+		if err := rows.Scan(&e.Event, &e.UserID, &e.Details, &e.CreatedAt); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		c.Events = append(c.Events, e)
+	}
+	rows.Close()
+
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+// GetZoneBlastClearances - A zone's clearances, newest first
+// GET /api/supervisor/zones/{id}/blast-clearances
+func GetZoneBlastClearances(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid zone id")
+		return
+	}
+	zoneName, err := supervisorZone(r, supervisorID, zoneID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, status, blast_at, COALESCE(notes, ''), initiated_by, initiated_at, cleared_at,
+		       COALESCE(reopened_at, cancelled_at)
+		FROM blast_clearances WHERE zone_id = $1
+		ORDER BY initiated_at DESC
+		LIMIT 100
+	`, zoneID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	clearances := []BlastClearance{}
+	for rows.Next() {
+		c := BlastClearance{ZoneID: zoneID, ZoneName: zoneName}
+		var blastAt, clearedAt, closedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Status, &blastAt, &c.Notes, &c.InitiatedBy, &c.InitiatedAt, &clearedAt, &closedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		if blastAt.Valid {
+			c.BlastAt = &blastAt.Time
+		}
+		if clearedAt.Valid {
+			c.ClearedAt = &clearedAt.Time
+		}
+		if closedAt.Valid {
+			c.ClosedAt = &closedAt.Time
+		}
+		clearances = append(clearances, c)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"clearances": clearances,
+	})
+}
+
+// confirmEvacuated marks people as out of the zone for a clearance that is still evacuating
+func confirmEvacuated(clearanceID int, userIDs []string, confirmedBy string) (int, error) {
+	result, err := database.DB.Exec(`
+		INSERT INTO blast_clearance_evacuations (clearance_id, user_id, confirmed_by)
+		SELECT $1, u, $3 FROM UNNEST($2::text[]) AS u
+		WHERE EXISTS(SELECT 1 FROM blast_clearances WHERE id = $1 AND status = $4)
+		ON CONFLICT DO NOTHING
+	`, clearanceID, pq.Array(userIDs), confirmedBy, BlastClearing)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	if n > 0 {
+		recordBlastEvent(clearanceID, BlastEventEvacuated, confirmedBy, strings.Join(userIDs, ", "))
+	}
+	return int(n), nil
+}
+
+// ConfirmEvacuatedBySupervisor - Record crew a supervisor has seen out of the zone at headcount
+// POST /api/supervisor/blast-clearances/{id}/evacuated
+func ConfirmEvacuatedBySupervisor(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		UserIDs []string `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.UserIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "user_ids is required")
+		return
+	}
+	c, err := supervisorClearance(r, supervisorID)
+	if err != nil {
+		respondClearanceLookupError(w, err)
+		return
+	}
+	if c.Status != BlastClearing {
+		respondWithError(w, http.StatusConflict, "This clearance is no longer evacuating")
+		return
+	}
+
+	// Only people allocated to the zone can be accounted for
+	var allocated []string
+	database.DB.QueryRow(`
+		SELECT COALESCE(array_agg(user_id), '{}') FROM users
+		WHERE user_id = ANY($1) AND zone_id = $2 AND deleted_at IS NULL
+	`, pq.Array(req.UserIDs), c.ZoneID).Scan(pq.Array(&allocated))
+	if len(allocated) == 0 {
+		respondWithError(w, http.StatusBadRequest, "None of these users are allocated to the zone")
+		return
+	}
+	if _, err := confirmEvacuated(c.ID, allocated, supervisorID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	occupants, err := zoneOccupants(c.ZoneID, c.ID, userToday(supervisorID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"occupants": occupants,
+	})
+}
+
+// ConfirmEvacuatedByMiner - The miner confirms they have left a zone being cleared
+// POST /api/app/blast-clearances/{id}/evacuated
+func ConfirmEvacuatedByMiner(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid clearance id")
+		return
+	}
+
+	var inZone bool
+	database.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM blast_clearances c JOIN users u ON u.zone_id = c.zone_id
+		              WHERE c.id = $1 AND u.user_id = $2 AND c.status = $3)
+	`, id, userID, BlastClearing).Scan(&inZone)
+	if !inZone {
+		respondWithError(w, http.StatusNotFound, "No blast clearance in progress for your zone")
+		return
+	}
+	if _, err := confirmEvacuated(id, []string{userID}, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Thanks, you are marked as out of the zone",
+	})
+}
+
+// SignOffBlastClearance - Confirm the area the caller is responsible for is clear, e.g. as
+// shotfirer or sentry. Refused while anyone still counts as inside.
+// POST /api/supervisor/blast-clearances/{id}/sign-off
+func SignOffBlastClearance(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Role  string `json:"role"`
+		Notes string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Role = strings.ToUpper(strings.TrimSpace(req.Role))
+	if req.Role == "" {
+		req.Role = "SUPERVISOR"
+	}
+
+	c, err := supervisorClearance(r, supervisorID)
+	if err != nil {
+		respondClearanceLookupError(w, err)
+		return
+	}
+	if c.Status != BlastClearing {
+		respondWithError(w, http.StatusConflict, "This clearance is no longer evacuating")
+		return
+	}
+	occupants, err := zoneOccupants(c.ZoneID, c.ID, userToday(supervisorID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(occupants) > 0 {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "The zone is not empty yet",
+			"occupants": occupants,
+		})
+		return
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO blast_clearance_signoffs (clearance_id, user_id, role, notes)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (clearance_id, user_id) DO UPDATE SET role = EXCLUDED.role, notes = EXCLUDED.notes, signed_at = NOW()
+	`, c.ID, supervisorID, truncate(req.Role, 50), truncate(strings.TrimSpace(req.Notes), 1000))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	recordBlastEvent(c.ID, BlastEventSignedOff, supervisorID, req.Role)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Sign-off recorded",
+	})
+}
+
+// ClearBlastZone - Declare the zone clear for firing. Occupancy is checked again and at least
+// one sign-off is required.
+// POST /api/supervisor/blast-clearances/{id}/clear
+func ClearBlastZone(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	c, err := supervisorClearance(r, supervisorID)
+	if err != nil {
+		respondClearanceLookupError(w, err)
+		return
+	}
+	if c.Status != BlastClearing {
+		respondWithError(w, http.StatusConflict, "Only a clearance that is evacuating can be cleared")
+		return
+	}
+
+	occupants, err := zoneOccupants(c.ZoneID, c.ID, userToday(supervisorID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(occupants) > 0 {
+		recordBlastEvent(c.ID, BlastEventClearRefused, supervisorID, fmt.Sprintf("%d still inside", len(occupants)))
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "The zone is not empty yet",
+			"occupants": occupants,
+		})
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE blast_clearances SET status = $2, cleared_at = NOW(), cleared_by = $3
+		WHERE id = $1 AND status = $4
+		AND EXISTS(SELECT 1 FROM blast_clearance_signoffs WHERE clearance_id = $1)
+	`, c.ID, BlastCleared, supervisorID, BlastClearing)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		recordBlastEvent(c.ID, BlastEventClearRefused, supervisorID, "no sign-offs")
+		respondWithError(w, http.StatusConflict, "At least one sign-off is required before clearing")
+		return
+	}
+	recordBlastEvent(c.ID, BlastEventCleared, supervisorID, "")
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Zone cleared for blasting",
+	})
+}
+
+// closeBlastClearance ends a clearance and unlocks the zone, telling everyone allocated to it
+func closeBlastClearance(w http.ResponseWriter, r *http.Request, status string) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Notes string `json:"notes"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	c, err := supervisorClearance(r, supervisorID)
+	if err != nil {
+		respondClearanceLookupError(w, err)
+		return
+	}
+
+	// The all-clear follows a cleared zone; cancelling is possible any time before it
+	query := "UPDATE blast_clearances SET status = $2, reopened_at = NOW(), reopened_by = $3 WHERE id = $1 AND status = '" + BlastCleared + "'"
+	event, title, message := BlastEventAllClear, "All clear", "The all-clear was given for "+c.ZoneName+". The zone is open again."
+	if status == BlastCancelled {
+		query = "UPDATE blast_clearances SET status = $2, cancelled_at = NOW(), cancelled_by = $3 WHERE id = $1 AND " + blastOpenStatusesClause
+		event, title, message = BlastEventCancelled, "Blast called off", "The blast in "+c.ZoneName+" was called off. The zone is open again."
+	}
+	result, err := database.DB.Exec(query, c.ID, status, supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusConflict, "This clearance can't be "+strings.ToLower(status)+" in its current state")
+		return
+	}
+	recordBlastEvent(c.ID, event, supervisorID, strings.TrimSpace(req.Notes))
+
+	rows, err := database.DB.Query("SELECT user_id FROM users WHERE zone_id = $1 AND deleted_at IS NULL", c.ZoneID)
+	if err == nil {
+		for rows.Next() {
+			var userID string
+			if rows.Scan(&userID) == nil {
+				notifyUser(userID, "BLAST_ALL_CLEAR", title, message, "blast_clearance", strconv.Itoa(c.ID))
+			}
+		}
+		rows.Close()
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Zone reopened",
+	})
+}
+
+// GiveBlastAllClear - Give the all-clear after the blast and reopen the zone
+// POST /api/supervisor/blast-clearances/{id}/all-clear
+func GiveBlastAllClear(w http.ResponseWriter, r *http.Request) {
+	closeBlastClearance(w, r, BlastReopened)
+}
+
+// CancelBlastClearance - Call off a blast before the all-clear and reopen the zone
+// POST /api/supervisor/blast-clearances/{id}/cancel
+func CancelBlastClearance(w http.ResponseWriter, r *http.Request) {
+	closeBlastClearance(w, r, BlastCancelled)
+}
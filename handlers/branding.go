@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/internal/pdf"
+	"database/sql"
+	"encoding/json"
+	"image"
+	_ "image/jpeg" // logo formats accepted by image.Decode
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ==================== ORGANIZATION BRANDING ====================
+
+// Each mining company can set a logo, colors and an email footer. PDFs, report data and
+// emails generated for its users carry them; anything left unset uses MineSafe's own.
+
+const (
+	defaultBrandName    = "MineSafe"
+	defaultPrimaryColor = "#1E3A5F"
+	defaultAccentColor  = "#F2A900"
+)
+
+// maxLogoBytes bounds logo uploads; logos are decoded whenever a PDF is generated
+const maxLogoBytes = 2 << 20
+
+// OrgBranding is the branding applied to an organization's generated documents
+type OrgBranding struct {
+	OrgID        int    `json:"org_id"`
+	Name         string `json:"name"`
+	LogoURL      string `json:"logo_url,omitempty"`
+	PrimaryColor string `json:"primary_color"`
+	AccentColor  string `json:"accent_color"`
+	EmailFooter  string `json:"email_footer,omitempty"`
+}
+
+// brandingForOrg loads an organization's branding, filling gaps with the defaults. Org 0
+// is MineSafe's own.
+func brandingForOrg(orgID int) OrgBranding {
+	b := OrgBranding{Name: defaultBrandName, PrimaryColor: defaultPrimaryColor, AccentColor: defaultAccentColor}
+	if orgID == 0 {
+		return b
+	}
+	var name, logo, primary, accent, footer sql.NullString
+	err := database.DB.QueryRow(
+		"SELECT name, logo_url, primary_color, accent_color, email_footer FROM organizations WHERE id = $1",
+		orgID,
+	).Scan(&name, &logo, &primary, &accent, &footer)
+	if err != nil {
+		return b
+	}
+	b.OrgID = orgID
+	if name.String != "" {
+		b.Name = name.String
+	}
+	if primary.String != "" {
+		b.PrimaryColor = primary.String
+	}
+	if accent.String != "" {
+		b.AccentColor = accent.String
+	}
+	b.LogoURL, b.EmailFooter = logo.String, footer.String
+	return b
+}
+
+// brandingForUser loads the branding of the user's organization
+func brandingForUser(userID string) OrgBranding {
+	orgID, _ := userOrganization(userID)
+	return brandingForOrg(orgID)
+}
+
+// reportData is the branding sent along with report data the dashboard renders itself
+func (b OrgBranding) reportData() map[string]interface{} {
+	data := map[string]interface{}{
+		"organization": b.Name,
+		"primaryColor": b.PrimaryColor,
+		"accentColor":  b.AccentColor,
+	}
+	if b.LogoURL != "" {
+		data["logoUrl"] = signUploadURL(b.LogoURL)
+	}
+	return data
+}
+
+// logo decodes the organization's logo, if it has one that can be read
+func (b OrgBranding) logo() (image.Image, bool) {
+	if b.LogoURL == "" {
+		return nil, false
+	}
+	f, err := os.Open(uploadFilePath(b.LogoURL))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	img, _, err := image.Decode(io.LimitReader(f, maxLogoBytes))
+	return img, err == nil
+}
+
+// pdfBanner draws a band in the primary color across the top of the current page with the
+// title, the organization's name and its logo, and returns the y below it
+func (b OrgBranding) pdfBanner(doc *pdf.Document, margin float64, title string) float64 {
+	const height = 56.0
+	primary, _ := pdf.HexColor(b.PrimaryColor)
+	accent, _ := pdf.HexColor(b.AccentColor)
+	white := pdf.Color{R: 255, G: 255, B: 255}
+
+	doc.SetColor(primary)
+	doc.FillRect(0, 0, pdf.A4Width, height)
+	doc.SetColor(accent)
+	doc.FillRect(0, height, pdf.A4Width, 3)
+	doc.SetColor(white)
+	doc.Text(margin, 26, 16, true, title)
+	doc.Text(margin, 44, 10, false, b.Name)
+	if img, ok := b.logo(); ok {
+		// Fit the logo to the band's height, keeping its shape
+		bounds := img.Bounds()
+		h := height - 16
+		w := h * float64(bounds.Dx()) / float64(bounds.Dy())
+		if w > 160 {
+			w, h = 160, 160*float64(bounds.Dy())/float64(bounds.Dx())
+		}
+		doc.Image(pdf.A4Width-margin-w, (height-h)/2, w, h, img)
+	}
+	doc.SetColor(pdf.Black)
+	return height + 3
+}
+
+// sendBrandedEmail sends an email in the organization's name: the sender is shown as the
+// organization and its footer is appended
+func sendBrandedEmail(b OrgBranding, to, subject, body string) error {
+	from := ""
+	if b.OrgID != 0 {
+		if addr, err := mail.ParseAddress(config.Get().SMTPFrom); err == nil {
+			addr.Name = b.Name + " via MineSafe"
+			from = addr.String()
+		}
+	}
+	if b.EmailFooter != "" {
+		body += "\n--\n" + b.EmailFooter + "\n"
+	}
+	return sendEmailFrom(from, to, subject, body)
+}
+
+// brandingOrg is the organization whose branding an admin request is about: their own, or
+// ?org_id= for platform admins
+func brandingOrg(w http.ResponseWriter, r *http.Request) (int, bool) {
+	if platformAdmin(r) {
+		orgID, err := strconv.Atoi(r.URL.Query().Get("org_id"))
+		if err != nil || orgID < 1 {
+			respondWithError(w, http.StatusBadRequest, "org_id is required")
+			return 0, false
+		}
+		return orgID, true
+	}
+	return int(requestOrg(r).Int64), true
+}
+
+// AdminGetBranding - The organization's branding as applied to documents and emails
+// GET /api/admin/branding
+func AdminGetBranding(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := brandingOrg(w, r)
+	if !ok {
+		return
+	}
+	b := brandingForOrg(orgID)
+	if b.OrgID == 0 {
+		respondWithError(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+	if b.LogoURL != "" {
+		b.LogoURL = signUploadURL(b.LogoURL)
+	}
+	respondWithJSON(w, http.StatusOK, b)
+}
+
+// UpdateBrandingRequest represents the request body for updating branding; omitted fields
+// are left as they are and empty strings reset them to the default
+type UpdateBrandingRequest struct {
+	PrimaryColor *string `json:"primary_color"`
+	AccentColor  *string `json:"accent_color"`
+	EmailFooter  *string `json:"email_footer"`
+}
+
+// AdminUpdateBranding - Set the organization's colors (#RRGGBB) and email footer
+// PUT /api/admin/branding
+func AdminUpdateBranding(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := brandingOrg(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateBrandingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	for _, color := range []*string{req.PrimaryColor, req.AccentColor} {
+		if color == nil {
+			continue
+		}
+		*color = strings.ToUpper(strings.TrimSpace(*color))
+		if _, valid := pdf.HexColor(*color); *color != "" && !valid {
+			respondWithError(w, http.StatusBadRequest, "Colors must be #RRGGBB")
+			return
+		}
+	}
+	if req.EmailFooter != nil {
+		*req.EmailFooter = truncate(strings.TrimSpace(*req.EmailFooter), 1000)
+	}
+
+	// A nil field keeps the stored value; an empty one clears it
+	result, err := database.DB.Exec(`
+		UPDATE organizations SET
+			primary_color = CASE WHEN $2::text IS NULL THEN primary_color ELSE NULLIF($2, '') END,
+			accent_color = CASE WHEN $3::text IS NULL THEN accent_color ELSE NULLIF($3, '') END,
+			email_footer = CASE WHEN $4::text IS NULL THEN email_footer ELSE NULLIF($4, '') END
+		WHERE id = $1
+	`, orgID, req.PrimaryColor, req.AccentColor, req.EmailFooter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	b := brandingForOrg(orgID)
+	if b.LogoURL != "" {
+		b.LogoURL = signUploadURL(b.LogoURL)
+	}
+	respondWithJSON(w, http.StatusOK, b)
+}
+
+// AdminUploadBrandingLogo - Upload the organization's logo, a PNG or JPEG up to 2 MB
+// (multipart/form-data, field "logo")
+// POST /api/admin/branding/logo
+func AdminUploadBrandingLogo(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := brandingOrg(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseMultipartForm(maxLogoBytes); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+	file, header, err := r.FormFile("logo")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Logo file is required")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxLogoBytes {
+		respondWithError(w, http.StatusBadRequest, "Logo must be 2 MB or smaller")
+		return
+	}
+
+	// Checked by decoding, since the logo is drawn into PDFs
+	_, format, err := image.DecodeConfig(file)
+	if err != nil || (format != "png" && format != "jpeg") {
+		respondWithError(w, http.StatusBadRequest, "Only PNG and JPEG logos are allowed")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to read logo")
+		return
+	}
+	policy, err := loadUploadPolicy(orgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading upload policy")
+		return
+	}
+	if !screenUpload(w, policy, file) {
+		return
+	}
+
+	dir := uploadDir("branding")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create upload directory")
+		return
+	}
+	fileName := uuid.New().String() + "." + format
+	dest, err := os.Create(filepath.Join(dir, fileName))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save logo")
+		return
+	}
+	defer dest.Close()
+	if _, err := io.Copy(dest, file); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save logo")
+		return
+	}
+
+	logoURL := "/uploads/branding/" + fileName
+	result, err := database.DB.Exec("UPDATE organizations SET logo_url = $2 WHERE id = $1", orgID, logoURL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"logo_url": signUploadURL(logoURL),
+	})
+}
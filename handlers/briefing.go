@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ==================== SUPERVISOR MORNING BRIEFING ====================
+
+// Today's briefing changes as miners check in, past days only change through late edits
+const (
+	briefingCacheTTL     = 2 * time.Minute
+	briefingPastCacheTTL = time.Hour
+)
+
+type briefingCacheEntry struct {
+	briefing SupervisorBriefing
+	loadedAt time.Time
+}
+
+var (
+	briefingCacheMu sync.Mutex
+	briefingCache   = map[string]briefingCacheEntry{}
+)
+
+// BriefingStarVideo is the day's star video and how many miners have completed it
+type BriefingStarVideo struct {
+	VideoID   int    `json:"video_id"`
+	Title     string `json:"title"`
+	Completed int    `json:"completed"`
+}
+
+// BriefingAttendance counts miners who checked in (any checklist or PPE check) on the day
+type BriefingAttendance struct {
+	TotalMiners int `json:"total_miners"`
+	CheckedIn   int `json:"checked_in"`
+	Absent      int `json:"absent"`
+	// Miners on recorded leave aren't counted as absent
+	OnLeave int `json:"on_leave"`
+}
+
+// BriefingApprovals counts items waiting on the supervisor
+type BriefingApprovals struct {
+	PendingModules       int `json:"pending_modules"`
+	InductionsInProgress int `json:"inductions_in_progress"`
+	OpenRemedial         int `json:"open_remedial_training"`
+}
+
+// BriefingEmergency is an open emergency from one of the supervisor's miners
+type BriefingEmergency struct {
+	ID            int       `json:"id"`
+	UserName      string    `json:"user_name"`
+	Severity      string    `json:"severity"`
+	Issue         string    `json:"issue"`
+	Status        string    `json:"status"`
+	ReportingTime time.Time `json:"reporting_time"`
+	Stale         bool      `json:"stale"`
+}
+
+// BriefingChecklist is a miner who has not finished the pre-start checklist
+type BriefingChecklist struct {
+	MinerID   string `json:"miner_id"`
+	MinerName string `json:"miner_name"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+}
+
+// SupervisorBriefing is everything a supervisor checks at the start of a shift
+type SupervisorBriefing struct {
+	Date              string              `json:"date"`
+	StarVideo         *BriefingStarVideo  `json:"star_video"`
+	Attendance        BriefingAttendance  `json:"attendance"`
+	PendingApprovals  BriefingApprovals   `json:"pending_approvals"`
+	OpenEmergencies   []BriefingEmergency `json:"open_emergencies"`
+	OverdueChecklists []BriefingChecklist `json:"overdue_checklists"`
+	GeneratedAt       time.Time           `json:"generated_at"`
+}
+
+func buildSupervisorBriefing(supervisorID, date string) (SupervisorBriefing, error) {
+	b := SupervisorBriefing{
+		Date:              date,
+		OpenEmergencies:   []BriefingEmergency{},
+		OverdueChecklists: []BriefingChecklist{},
+		GeneratedAt:       time.Now(),
+	}
+
+	var star BriefingStarVideo
+	err := database.DB.QueryRow(`
+		SELECT sv.video_id, vm.title,
+			(SELECT COUNT(DISTINCT mc.miner_id) FROM module_completions mc
+			 JOIN users u ON mc.miner_id = u.user_id
+			 WHERE mc.video_id = sv.video_id AND mc.passed AND u.supervisor_id = $1 AND (mc.completed_at AT TIME ZONE $3)::date = $2)
+		FROM star_videos sv
+		JOIN video_modules vm ON vm.id = sv.video_id
+		WHERE sv.supervisor_id = $1 AND sv.set_date = $2 AND sv.is_active = true
+	`, supervisorID, date, userLocation(supervisorID).String()).Scan(&star.VideoID, &star.Title, &star.Completed)
+	if err == nil {
+		b.StarVideo = &star
+	} else if err != sql.ErrNoRows {
+		return b, err
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE
+				EXISTS(SELECT 1 FROM pre_start_checklist_completions c WHERE c.user_id = u.user_id AND c.date = $2) OR
+				EXISTS(SELECT 1 FROM ppe_checklist_completions c WHERE c.user_id = u.user_id AND c.date = $2) OR
+				EXISTS(SELECT 1 FROM ppe_stats p WHERE p.user_id = u.user_id AND p.date = $2)),
+			COUNT(*) FILTER (WHERE EXISTS(SELECT 1 FROM miner_leave l
+				WHERE l.user_id = u.user_id AND $2::date BETWEEN l.start_date AND l.end_date))
+		FROM users u
+		WHERE u.supervisor_id = $1 AND u.role = ANY($3) AND u.deleted_at IS NULL
+	`, supervisorID, date, crewRoles()).Scan(&b.Attendance.TotalMiners, &b.Attendance.CheckedIn, &b.Attendance.OnLeave)
+	if err != nil {
+		return b, err
+	}
+	b.Attendance.Absent = b.Attendance.TotalMiners - b.Attendance.CheckedIn - b.Attendance.OnLeave
+	if b.Attendance.Absent < 0 {
+		// Someone on leave still checked in
+		b.Attendance.Absent = 0
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM video_modules vm WHERE vm.approval_status = 'pending'
+			 AND (vm.created_by IN (SELECT user_id FROM users WHERE supervisor_id = $1 AND deleted_at IS NULL) OR vm.created_by = $1)),
+			(SELECT COUNT(*) FROM miner_inductions mi JOIN users u ON u.user_id = mi.miner_id
+			 WHERE u.supervisor_id = $1 AND mi.status <> $2),
+			(SELECT COUNT(*) FROM remedial_assignments ra JOIN users u ON u.user_id = ra.miner_id
+			 WHERE u.supervisor_id = $1 AND ra.completed_at IS NULL)
+	`, supervisorID, InductionStatusComplete).Scan(&b.PendingApprovals.PendingModules,
+		&b.PendingApprovals.InductionsInProgress, &b.PendingApprovals.OpenRemedial)
+	if err != nil {
+		return b, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT e.id, u.name, COALESCE(e.severity, ''), COALESCE(e.issue, ''), e.status, e.reporting_time,
+			e.stale_flagged_at IS NOT NULL
+		FROM emergencies e
+		JOIN users u ON e.user_id = u.user_id
+		WHERE u.supervisor_id = $1 AND e.status IN ('PENDING', 'RESOLVING') AND e.primary_emergency_id IS NULL
+		ORDER BY e.reporting_time
+	`, supervisorID)
+	if err != nil {
+		return b, err
+	}
+	for rows.Next() {
+		var e BriefingEmergency
+		if err := rows.Scan(&e.ID, &e.UserName, &e.Severity, &e.Issue, &e.Status, &e.ReportingTime, &e.Stale); err != nil {
+			rows.Close()
+			return b, err
+		}
+		b.OpenEmergencies = append(b.OpenEmergencies, e)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		WITH items AS (
+			SELECT id FROM pre_start_checklist
+			WHERE (supervisor_id = $1 OR (is_default = true AND mining_site = (SELECT mining_site FROM users WHERE user_id = $1))) AND is_active = true
+		)
+		SELECT u.user_id, u.name,
+			(SELECT COUNT(*) FROM pre_start_checklist_completions c
+			 WHERE c.user_id = u.user_id AND c.date = $2 AND c.is_completed = true AND c.item_id IN (SELECT id FROM items)),
+			(SELECT COUNT(*) FROM items)
+		FROM users u
+		WHERE u.supervisor_id = $1 AND u.role = ANY($3) AND u.deleted_at IS NULL
+		AND NOT EXISTS(SELECT 1 FROM miner_leave l WHERE l.user_id = u.user_id AND $2::date BETWEEN l.start_date AND l.end_date)
+		ORDER BY u.name
+	`, supervisorID, date, crewRoles())
+	if err != nil {
+		return b, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c BriefingChecklist
+		if err := rows.Scan(&c.MinerID, &c.MinerName, &c.Completed, &c.Total); err != nil {
+			return b, err
+		}
+		if c.Completed < c.Total {
+			b.OverdueChecklists = append(b.OverdueChecklists, c)
+		}
+	}
+
+	return b, rows.Err()
+}
+
+// GetSupervisorBriefing - Star video, attendance, approvals, emergencies and checklists in one call
+// GET /api/supervisor/briefing?date=2024-01-15&refresh=true
+func GetSupervisorBriefing(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	today := userToday(supervisorID)
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = today
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		respondWithError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+
+	ttl := briefingCacheTTL
+	if date < today {
+		ttl = briefingPastCacheTTL
+	}
+
+	key := supervisorID + "|" + date
+	if r.URL.Query().Get("refresh") != "true" {
+		briefingCacheMu.Lock()
+		entry, ok := briefingCache[key]
+		briefingCacheMu.Unlock()
+		if ok && time.Since(entry.loadedAt) < ttl {
+			respondWithJSON(w, http.StatusOK, entry.briefing)
+			return
+		}
+	}
+
+	briefing, err := buildSupervisorBriefing(supervisorID, date)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error building briefing: "+err.Error())
+		return
+	}
+
+	briefingCacheMu.Lock()
+	// Drop expired entries so the cache doesn't grow with every supervisor and date
+	for k, e := range briefingCache {
+		if time.Since(e.loadedAt) > briefingPastCacheTTL {
+			delete(briefingCache, k)
+		}
+	}
+	briefingCache[key] = briefingCacheEntry{briefing: briefing, loadedAt: time.Now()}
+	briefingCacheMu.Unlock()
+
+	respondWithJSON(w, http.StatusOK, briefing)
+}
@@ -32,13 +32,14 @@ func GetQuizCalendarAndStreak(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all distinct dates when user attempted quizzes
+	// Get all distinct dates when user attempted quizzes, as days in the user's timezone
+	loc := userLocation(userID)
 	rows, err := database.DB.Query(`
-		SELECT DISTINCT DATE(completed_at) as attempt_date
+		SELECT DISTINCT DATE(completed_at AT TIME ZONE $2) as attempt_date
 		FROM module_completions
-		WHERE miner_id = $1
+		WHERE miner_id = $1 AND passed
 		ORDER BY attempt_date DESC
-	`, userID)
+	`, userID, loc.String())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -55,9 +56,10 @@ func GetQuizCalendarAndStreak(w http.ResponseWriter, r *http.Request) {
 		attemptDates = append(attemptDates, date.Format("2006-01-02"))
 	}
 
-	// Calculate current streak (consecutive days ending today or yesterday)
-	currentStreak := calculateCurrentStreak(attemptDates)
-	longestStreak := calculateLongestStreak(attemptDates)
+	// Calculate current streak (consecutive days ending today or yesterday); leave doesn't break it
+	leave := minerLeaveDays(userID)
+	currentStreak := calculateCurrentStreak(attemptDates, loc, leave)
+	longestStreak := calculateLongestStreak(attemptDates, leave)
 
 	response := models.CalendarStreakResponse{
 		UserID:        userID,
@@ -71,27 +73,36 @@ func GetQuizCalendarAndStreak(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, response)
 }
 
-// calculateCurrentStreak calculates consecutive days ending today or yesterday
-func calculateCurrentStreak(dates []string) int {
+// consecutiveDays reports whether earlier directly precedes later (YYYY-MM-DD), counting
+// days on leave in between as if they weren't there
+func consecutiveDays(later, earlier string, leave map[string]bool) bool {
+	laterDate, _ := time.Parse("2006-01-02", later)
+	day := laterDate.AddDate(0, 0, -1).Format("2006-01-02")
+	for day > earlier && leave[day] {
+		d, _ := time.Parse("2006-01-02", day)
+		day = d.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return day == earlier
+}
+
+// calculateCurrentStreak calculates consecutive days ending today or yesterday in loc,
+// skipping over days on leave
+func calculateCurrentStreak(dates []string, loc *time.Location, leave map[string]bool) int {
 	if len(dates) == 0 {
 		return 0
 	}
 
-	today := time.Now().Format("2006-01-02")
-	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	today := time.Now().In(loc).Format("2006-01-02")
 
-	// Check if most recent date is today or yesterday
-	if dates[0] != today && dates[0] != yesterday {
+	// Check if most recent date is today or yesterday (or the last day before leave)
+	if dates[0] != today && !consecutiveDays(today, dates[0], leave) {
 		return 0
 	}
 
 	streak := 1
 	for i := 1; i < len(dates); i++ {
-		currentDate, _ := time.Parse("2006-01-02", dates[i-1])
-		prevDate, _ := time.Parse("2006-01-02", dates[i])
-
 		// Check if dates are consecutive
-		if currentDate.AddDate(0, 0, -1).Format("2006-01-02") == prevDate.Format("2006-01-02") {
+		if consecutiveDays(dates[i-1], dates[i], leave) {
 			streak++
 		} else {
 			break
@@ -101,8 +112,8 @@ func calculateCurrentStreak(dates []string) int {
 	return streak
 }
 
-// calculateLongestStreak calculates the longest consecutive streak
-func calculateLongestStreak(dates []string) int {
+// calculateLongestStreak calculates the longest consecutive streak, skipping over days on leave
+func calculateLongestStreak(dates []string, leave map[string]bool) int {
 	if len(dates) == 0 {
 		return 0
 	}
@@ -111,10 +122,7 @@ func calculateLongestStreak(dates []string) int {
 	current := 1
 
 	for i := 1; i < len(dates); i++ {
-		currentDate, _ := time.Parse("2006-01-02", dates[i-1])
-		prevDate, _ := time.Parse("2006-01-02", dates[i])
-
-		if currentDate.AddDate(0, 0, -1).Format("2006-01-02") == prevDate.Format("2006-01-02") {
+		if consecutiveDays(dates[i-1], dates[i], leave) {
 			current++
 			if current > longest {
 				longest = current
@@ -129,6 +137,10 @@ func calculateLongestStreak(dates []string) int {
 
 // ==================== PRE-START CHECKLIST ROUTES ====================
 
+// ownChecklistItem matches checklist rows the supervisors in $2 (see managedSupervisors) may
+// edit or delete: their own items and the default items of their organization (mining site)
+const ownChecklistItem = `(supervisor_id = ANY($2) OR (is_default = true AND mining_site IN (SELECT mining_site FROM users WHERE user_id = ANY($2))))`
+
 // CreatePreStartChecklistItem - Supervisor creates a new pre-start checklist item
 // POST /api/checklists/pre-start
 func CreatePreStartChecklistItem(w http.ResponseWriter, r *http.Request) {
@@ -188,9 +200,9 @@ func GetPreStartChecklistItems(w http.ResponseWriter, r *http.Request) {
 	rows, err := database.DB.Query(`
 		SELECT id, supervisor_id, title, description, is_default, is_active, created_at, updated_at
 		FROM pre_start_checklist
-		WHERE (supervisor_id = $1 OR is_default = true) AND is_active = true
+		WHERE (supervisor_id = ANY($1) OR (is_default = true AND mining_site IN (SELECT mining_site FROM users WHERE user_id = ANY($1)))) AND is_active = true
 		ORDER BY is_default DESC, created_at ASC
-	`, supervisorID)
+	`, managedSupervisors(supervisorID))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -224,11 +236,11 @@ func DeletePreStartChecklistItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	itemID := vars["id"]
 
-	// Can only delete non-default items created by this supervisor
+	// Items created by this supervisor, or the defaults of their own organization
 	result, err := database.DB.Exec(`
 		UPDATE pre_start_checklist SET is_active = false, updated_at = NOW()
-		WHERE id = $1 AND supervisor_id = $2 AND is_default = false
-	`, itemID, supervisorID)
+		WHERE id = $1 AND is_active = true AND `+ownChecklistItem+`
+	`, itemID, managedSupervisors(supervisorID))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -243,6 +255,44 @@ func DeletePreStartChecklistItem(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item deleted successfully"})
 }
 
+// UpdatePreStartChecklistItem - Supervisor edits a pre-start checklist item, including their
+// organization's defaults
+// PUT /api/checklists/pre-start/{id}
+func UpdatePreStartChecklistItem(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var item models.ChecklistItemCreate
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if item.Title == "" {
+		respondWithError(w, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE pre_start_checklist SET title = $3, description = $4, updated_at = NOW()
+		WHERE id = $1 AND is_active = true AND `+ownChecklistItem+`
+	`, mux.Vars(r)["id"], managedSupervisors(supervisorID), item.Title, item.Description)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Item not found or cannot be edited")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item updated successfully"})
+}
+
 // UpdatePreStartChecklistCompletion - Supervisor marks item as completed/not completed
 // PUT /api/checklists/pre-start/complete
 func UpdatePreStartChecklistCompletion(w http.ResponseWriter, r *http.Request) {
@@ -258,7 +308,7 @@ func UpdatePreStartChecklistCompletion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := userToday(supervisorID)
 
 	// Upsert completion record
 	_, err := database.DB.Exec(`
@@ -277,7 +327,9 @@ func UpdatePreStartChecklistCompletion(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetPreStartChecklistForApp - User (Miner) gets pre-start checklist with status
-// GET /api/app/checklists/pre-start
+// GET /api/app/checklists/pre-start?updated_since=2024-01-15T06:00:00Z
+// With updated_since, only items added, edited or ticked since then are returned along with
+// the IDs of items removed since then
 func GetPreStartChecklistForApp(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -298,19 +350,41 @@ func GetPreStartChecklistForApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	since, err := parseUpdatedSince(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	serverTime := time.Now()
+	loc := userLocation(userID)
+	frequency := userSiteSettings(userID).ChecklistFrequency
+	period := checklistPeriod(serverTime.In(loc), frequency)
+
+	args := []interface{}{userID, *supervisorID, period}
+	filter := "p.is_active = true"
+	// Ticks reset every day (or week, at weekly sites), so a sync from an earlier period
+	// needs the whole list again
+	if since != nil {
+		args = append(args, *since)
+		filter = "p.updated_at > $4"
+		if checklistPeriod(since.In(loc), frequency) == period {
+			filter += " OR c.completed_at > $4"
+		} else {
+			filter += " OR p.is_active = true"
+		}
+	}
 
 	rows, err := database.DB.Query(`
 		SELECT 
 			p.id, p.title, p.description,
 			COALESCE(c.is_completed, false) as is_completed,
-			c.completed_at
+			c.completed_at, COALESCE(p.is_active, false)
 		FROM pre_start_checklist p
 		LEFT JOIN pre_start_checklist_completions c 
 			ON p.id = c.item_id AND c.user_id = $1 AND c.date = $3
-		WHERE (p.supervisor_id = $2 OR p.is_default = true) AND p.is_active = true
+		WHERE (p.supervisor_id = $2 OR (p.is_default = true AND p.mining_site = (SELECT mining_site FROM users WHERE user_id = $2))) AND (`+filter+`)
 		ORDER BY p.is_default DESC, p.created_at ASC
-	`, userID, *supervisorID, today)
+	`, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
@@ -318,20 +392,30 @@ func GetPreStartChecklistForApp(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	items := []models.ChecklistItemWithStatus{}
+	deleted := []int{}
 	for rows.Next() {
 		var item models.ChecklistItemWithStatus
 		var completedAt sql.NullTime
-		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.IsCompleted, &completedAt)
+		var isActive bool
+		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.IsCompleted, &completedAt, &isActive)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning item")
 			return
 		}
+		if !isActive {
+			deleted = append(deleted, item.ID)
+			continue
+		}
 		if completedAt.Valid {
 			item.CompletedAt = &completedAt.Time
 		}
 		items = append(items, item)
 	}
 
+	if since != nil {
+		respondWithJSON(w, http.StatusOK, SyncResponse{Items: items, Deleted: deleted, ServerTime: serverTime})
+		return
+	}
 	respondWithJSON(w, http.StatusOK, items)
 }
 
@@ -350,14 +434,14 @@ func UpdatePreStartChecklistForApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	period := userChecklistPeriod(userID)
 
 	_, err := database.DB.Exec(`
 		INSERT INTO pre_start_checklist_completions (user_id, item_id, is_completed, completed_at, date)
 		VALUES ($1, $2, $3, NOW(), $4)
 		ON CONFLICT (user_id, item_id, date)
 		DO UPDATE SET is_completed = $3, completed_at = NOW()
-	`, userID, update.ItemID, update.IsCompleted, today)
+	`, userID, update.ItemID, update.IsCompleted, period)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating completion: "+err.Error())
@@ -428,9 +512,9 @@ func GetPPEChecklistItems(w http.ResponseWriter, r *http.Request) {
 	rows, err := database.DB.Query(`
 		SELECT id, supervisor_id, title, description, is_default, is_active, created_at, updated_at
 		FROM ppe_checklist
-		WHERE (supervisor_id = $1 OR is_default = true) AND is_active = true
+		WHERE (supervisor_id = ANY($1) OR (is_default = true AND mining_site IN (SELECT mining_site FROM users WHERE user_id = ANY($1)))) AND is_active = true
 		ORDER BY is_default DESC, created_at ASC
-	`, supervisorID)
+	`, managedSupervisors(supervisorID))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -466,8 +550,8 @@ func DeletePPEChecklistItem(w http.ResponseWriter, r *http.Request) {
 
 	result, err := database.DB.Exec(`
 		UPDATE ppe_checklist SET is_active = false, updated_at = NOW()
-		WHERE id = $1 AND supervisor_id = $2 AND is_default = false
-	`, itemID, supervisorID)
+		WHERE id = $1 AND is_active = true AND `+ownChecklistItem+`
+	`, itemID, managedSupervisors(supervisorID))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -482,6 +566,44 @@ func DeletePPEChecklistItem(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item deleted successfully"})
 }
 
+// UpdatePPEChecklistItem - Supervisor edits a PPE checklist item, including their
+// organization's defaults
+// PUT /api/checklists/ppe/{id}
+func UpdatePPEChecklistItem(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var item models.ChecklistItemCreate
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if item.Title == "" {
+		respondWithError(w, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE ppe_checklist SET title = $3, description = $4, updated_at = NOW()
+		WHERE id = $1 AND is_active = true AND `+ownChecklistItem+`
+	`, mux.Vars(r)["id"], managedSupervisors(supervisorID), item.Title, item.Description)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Item not found or cannot be edited")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item updated successfully"})
+}
+
 // UpdatePPEChecklistCompletion - Supervisor marks PPE item as completed/not completed
 // PUT /api/checklists/ppe/complete
 func UpdatePPEChecklistCompletion(w http.ResponseWriter, r *http.Request) {
@@ -497,7 +619,7 @@ func UpdatePPEChecklistCompletion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := userToday(supervisorID)
 
 	_, err := database.DB.Exec(`
 		INSERT INTO ppe_checklist_completions (user_id, item_id, is_completed, completed_at, date)
@@ -515,7 +637,9 @@ func UpdatePPEChecklistCompletion(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetPPEChecklistForApp - User (Miner) gets PPE checklist with status
-// GET /api/app/checklists/ppe
+// GET /api/app/checklists/ppe?updated_since=2024-01-15T06:00:00Z
+// With updated_since, only items added, edited or ticked since then are returned along with
+// the IDs of items removed since then
 func GetPPEChecklistForApp(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -536,19 +660,41 @@ func GetPPEChecklistForApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	since, err := parseUpdatedSince(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	serverTime := time.Now()
+	loc := userLocation(userID)
+	frequency := userSiteSettings(userID).ChecklistFrequency
+	period := checklistPeriod(serverTime.In(loc), frequency)
+
+	args := []interface{}{userID, *supervisorID, period}
+	filter := "p.is_active = true"
+	// Ticks reset every day (or week, at weekly sites), so a sync from an earlier period
+	// needs the whole list again
+	if since != nil {
+		args = append(args, *since)
+		filter = "p.updated_at > $4"
+		if checklistPeriod(since.In(loc), frequency) == period {
+			filter += " OR c.completed_at > $4"
+		} else {
+			filter += " OR p.is_active = true"
+		}
+	}
 
 	rows, err := database.DB.Query(`
 		SELECT 
 			p.id, p.title, p.description,
 			COALESCE(c.is_completed, false) as is_completed,
-			c.completed_at
+			c.completed_at, COALESCE(p.is_active, false)
 		FROM ppe_checklist p
 		LEFT JOIN ppe_checklist_completions c 
 			ON p.id = c.item_id AND c.user_id = $1 AND c.date = $3
-		WHERE (p.supervisor_id = $2 OR p.is_default = true) AND p.is_active = true
+		WHERE (p.supervisor_id = $2 OR (p.is_default = true AND p.mining_site = (SELECT mining_site FROM users WHERE user_id = $2))) AND (`+filter+`)
 		ORDER BY p.is_default DESC, p.created_at ASC
-	`, userID, *supervisorID, today)
+	`, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
@@ -556,20 +702,30 @@ func GetPPEChecklistForApp(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	items := []models.ChecklistItemWithStatus{}
+	deleted := []int{}
 	for rows.Next() {
 		var item models.ChecklistItemWithStatus
 		var completedAt sql.NullTime
-		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.IsCompleted, &completedAt)
+		var isActive bool
+		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.IsCompleted, &completedAt, &isActive)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning item")
 			return
 		}
+		if !isActive {
+			deleted = append(deleted, item.ID)
+			continue
+		}
 		if completedAt.Valid {
 			item.CompletedAt = &completedAt.Time
 		}
 		items = append(items, item)
 	}
 
+	if since != nil {
+		respondWithJSON(w, http.StatusOK, SyncResponse{Items: items, Deleted: deleted, ServerTime: serverTime})
+		return
+	}
 	respondWithJSON(w, http.StatusOK, items)
 }
 
@@ -588,14 +744,14 @@ func UpdatePPEChecklistForApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	period := userChecklistPeriod(userID)
 
 	_, err := database.DB.Exec(`
 		INSERT INTO ppe_checklist_completions (user_id, item_id, is_completed, completed_at, date)
 		VALUES ($1, $2, $3, NOW(), $4)
 		ON CONFLICT (user_id, item_id, date)
 		DO UPDATE SET is_completed = $3, completed_at = NOW()
-	`, userID, update.ItemID, update.IsCompleted, today)
+	`, userID, update.ItemID, update.IsCompleted, period)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating completion: "+err.Error())
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// ==================== EMERGENCY CLUSTERING ====================
+
+// Reports are treated as the same incident when they come from the same zone, within
+// clusterRadiusMeters of each other and within clusterWindow of each other
+const (
+	clusterRadiusMeters = 250.0
+	clusterWindow       = 15 * time.Minute
+)
+
+// severityRank orders severities so a cluster reports its most severe report
+var severityRank = map[string]int{"LOW": 1, "MEDIUM": 2, "HIGH": 3, "CRITICAL": 4}
+
+// distanceMetersSQL is the haversine distance between report e and candidate p
+const distanceMetersSQL = `6371000 * 2 * ASIN(SQRT(
+	POWER(SIN(RADIANS(p.latitude - e.latitude) / 2), 2) +
+	COS(RADIANS(e.latitude)) * COS(RADIANS(p.latitude)) * POWER(SIN(RADIANS(p.longitude - e.longitude) / 2), 2)
+))`
+
+// clusterEmergency links a new report to the open primary report of the same incident, if
+// there is one, and returns the primary's ID. Reports without coordinates are only clustered
+// by zone and time.
+func clusterEmergency(emergencyID int) *int {
+	var primaryID int
+	err := database.DB.QueryRow(`
+		SELECT p.id
+		FROM emergencies e
+		JOIN users eu ON eu.user_id = e.user_id
+		JOIN emergencies p ON p.id <> e.id AND p.primary_emergency_id IS NULL
+		JOIN users pu ON pu.user_id = p.user_id
+		WHERE e.id = $1
+		AND p.status IN ('PENDING', 'RESOLVING')
+		AND ABS(EXTRACT(EPOCH FROM (p.reporting_time - e.reporting_time))) <= $2
+		AND (COALESCE(e.zone_id, eu.zone_id) IS NULL OR COALESCE(p.zone_id, pu.zone_id) IS NULL
+		     OR COALESCE(e.zone_id, eu.zone_id) = COALESCE(p.zone_id, pu.zone_id))
+		AND (
+			(e.latitude = 0 AND e.longitude = 0) OR (p.latitude = 0 AND p.longitude = 0) OR
+			`+distanceMetersSQL+` <= $3
+		)
+		AND COALESCE(eu.mining_site, '') = COALESCE(pu.mining_site, '')
+		ORDER BY p.reporting_time
+		LIMIT 1
+	`, emergencyID, clusterWindow.Seconds(), clusterRadiusMeters).Scan(&primaryID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Warning: failed to cluster emergency %d: %v", emergencyID, err)
+		}
+		return nil
+	}
+
+	if _, err := database.DB.Exec("UPDATE emergencies SET primary_emergency_id = $1 WHERE id = $2", primaryID, emergencyID); err != nil {
+		log.Printf("Warning: failed to link emergency %d to %d: %v", emergencyID, primaryID, err)
+		return nil
+	}
+	return &primaryID
+}
+
+// clusterPrimaryID resolves a report to the primary report of its cluster
+func clusterPrimaryID(emergencyID int) (int, error) {
+	var primaryID sql.NullInt64
+	err := database.DB.QueryRow("SELECT primary_emergency_id FROM emergencies WHERE id = $1", emergencyID).Scan(&primaryID)
+	if err != nil {
+		return 0, err
+	}
+	if primaryID.Valid {
+		return int(primaryID.Int64), nil
+	}
+	return emergencyID, nil
+}
+
+// MergeEmergenciesRequest lists the reports to link under a primary report
+type MergeEmergenciesRequest struct {
+	EmergencyIDs []int `json:"emergency_ids"`
+}
+
+// MergeEmergencies - Link reports of the same incident under a primary report
+// POST /api/supervisor/emergencies/{id}/merge
+func MergeEmergencies(w http.ResponseWriter, r *http.Request) {
+	emergencyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid emergency ID")
+		return
+	}
+
+	var req MergeEmergenciesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.EmergencyIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "emergency_ids is required")
+		return
+	}
+
+	// Merging into a linked report merges into its primary instead
+	primaryID, err := clusterPrimaryID(emergencyID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Emergency not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	ids := []int{}
+	for _, id := range req.EmergencyIDs {
+		if id != primaryID {
+			ids = append(ids, id)
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Reports already linked under one of the merged reports move with it
+	result, err := tx.Exec(`
+		UPDATE emergencies SET primary_emergency_id = $1
+		WHERE id = ANY($2) OR primary_emergency_id = ANY($2)
+	`, primaryID, pq.Array(ids))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error merging emergencies: "+err.Error())
+		return
+	}
+	merged, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error merging emergencies: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":              true,
+		"primary_emergency_id": primaryID,
+		"merged":               merged,
+	})
+}
+
+// UnmergeEmergency - Detach a report from its cluster so it stands as its own incident
+// POST /api/supervisor/emergencies/{id}/unmerge
+func UnmergeEmergency(w http.ResponseWriter, r *http.Request) {
+	emergencyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid emergency ID")
+		return
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE emergencies SET primary_emergency_id = NULL WHERE id = $1 AND primary_emergency_id IS NOT NULL",
+		emergencyID,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unmerging emergency: "+err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Emergency not found or not part of a cluster")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Emergency unmerged",
+	})
+}
+
+// EmergencyClusterReport is one report within a cluster
+type EmergencyClusterReport struct {
+	models.Emergency
+	UserName string `json:"user_name"`
+}
+
+// GetEmergencyCluster - Combined view of an incident and every report linked to it
+// GET /api/emergencies/{id}/cluster
+func GetEmergencyCluster(w http.ResponseWriter, r *http.Request) {
+	emergencyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid emergency ID")
+		return
+	}
+
+	primaryID, err := clusterPrimaryID(emergencyID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Emergency not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT e.id, e.user_id, e.emergency_id, e.severity, e.latitude, e.longitude, e.issue,
+		       e.media_status, e.media_url, e.location, e.incident_time, e.reporting_time,
+		       e.status, e.resolution_time, e.primary_emergency_id, u.name
+		FROM emergencies e
+		JOIN users u ON e.user_id = u.user_id
+		WHERE e.id = $1 OR e.primary_emergency_id = $1
+		ORDER BY e.primary_emergency_id NULLS FIRST, e.reporting_time
+	`, primaryID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	reports := []EmergencyClusterReport{}
+	reporters := map[string]bool{}
+	severity := ""
+	var firstReported, lastReported time.Time
+	for rows.Next() {
+		var report EmergencyClusterReport
+		var linkedTo sql.NullInt64
+		err := rows.Scan(&report.ID, &report.UserID, &report.EmergencyID, &report.Severity,
+			&report.Lat, &report.Lon, &report.Issue, &report.MediaStatus, &report.MediaURL,
+			&report.Location, &report.IncidentTime, &report.IncidentReportingTime,
+			&report.Status, &report.ResolutionTime, &linkedTo, &report.UserName)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning emergency data")
+			return
+		}
+		if linkedTo.Valid {
+			id := int(linkedTo.Int64)
+			report.PrimaryEmergencyID = &id
+		}
+		report.MediaURL = signUploadURLPtr(report.MediaURL)
+
+		reporters[report.UserID] = true
+		if severityRank[report.Severity] > severityRank[severity] {
+			severity = report.Severity
+		}
+		if firstReported.IsZero() || report.IncidentReportingTime.Before(firstReported) {
+			firstReported = report.IncidentReportingTime
+		}
+		if report.IncidentReportingTime.After(lastReported) {
+			lastReported = report.IncidentReportingTime
+		}
+		reports = append(reports, report)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"primary_emergency_id": primaryID,
+		"report_count":         len(reports),
+		"reporter_count":       len(reporters),
+		"severity":             severity,
+		"first_reported_at":    firstReported,
+		"last_reported_at":     lastReported,
+		"reports":              reports,
+	})
+}
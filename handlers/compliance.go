@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// ==================== DAILY COMPLIANCE SUMMARY ====================
+
+// complianceBackfillDays is how much history the first refresh fills in
+const complianceBackfillDays = 30
+
+// DailyCompliance is one miner's compliance on one day
+type DailyCompliance struct {
+	UserID             string  `json:"user_id"`
+	MinerName          string  `json:"miner_name"`
+	Date               string  `json:"date"`
+	ChecklistItems     int     `json:"checklist_items"`
+	ChecklistCompleted int     `json:"checklist_completed"`
+	ChecklistPct       float64 `json:"checklist_pct"`
+	PPEItems           int     `json:"ppe_items"`
+	PPECompleted       int     `json:"ppe_completed"`
+	PPEPct             float64 `json:"ppe_pct"`
+	ModulesCompleted   int     `json:"modules_completed"`
+	OnLeave            bool    `json:"on_leave"`
+}
+
+// TeamDailyCompliance averages a team's compliance for one day over the miners not on leave
+type TeamDailyCompliance struct {
+	Date             string  `json:"date"`
+	Miners           int     `json:"miners"`
+	OnLeave          int     `json:"on_leave"`
+	ChecklistPct     float64 `json:"checklist_pct"`
+	PPEPct           float64 `json:"ppe_pct"`
+	ModulesCompleted int     `json:"modules_completed"`
+}
+
+// refreshComplianceSummary recomputes daily_compliance_summary for every miner and every
+// day from..to (YYYY-MM-DD). A miner's checklist is what the app shows them: their
+// supervisor's items plus the site defaults currently active. Days on leave are flagged so
+// dashboards leave them out.
+func refreshComplianceSummary(from, to string) (int64, error) {
+	result, err := database.DB.Exec(`
+		INSERT INTO daily_compliance_summary (user_id, supervisor_id, mining_site, date,
+			checklist_items, checklist_completed, checklist_pct,
+			ppe_items, ppe_completed, ppe_pct, modules_completed, on_leave, refreshed_at)
+		SELECT u.user_id, u.supervisor_id, u.mining_site, d.day::date,
+			pi.n, pc.n, CASE WHEN pi.n > 0 THEN ROUND(100.0 * LEAST(pc.n, pi.n) / pi.n, 1) ELSE 0 END,
+			qi.n, qc.n, CASE WHEN qi.n > 0 THEN ROUND(100.0 * LEAST(qc.n, qi.n) / qi.n, 1) ELSE 0 END,
+			mc.n, EXISTS(SELECT 1 FROM miner_leave l WHERE l.user_id = u.user_id AND d.day::date BETWEEN l.start_date AND l.end_date),
+			NOW()
+		FROM users u
+		LEFT JOIN users s ON s.user_id = u.supervisor_id
+		CROSS JOIN generate_series($1::date, $2::date, INTERVAL '1 day') AS d(day)
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS n FROM pre_start_checklist p
+			WHERE p.is_active = true AND (p.supervisor_id = u.supervisor_id OR (p.is_default = true AND p.mining_site = s.mining_site))
+		) pi
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS n FROM pre_start_checklist_completions c
+			WHERE c.user_id = u.user_id AND c.date = d.day::date AND c.is_completed = true
+		) pc
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS n FROM ppe_checklist p
+			WHERE p.is_active = true AND (p.supervisor_id = u.supervisor_id OR (p.is_default = true AND p.mining_site = s.mining_site))
+		) qi
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS n FROM ppe_checklist_completions c
+			WHERE c.user_id = u.user_id AND c.date = d.day::date AND c.is_completed = true
+		) qc
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS n FROM module_completions m
+			WHERE m.miner_id = u.user_id AND m.passed AND m.completed_at >= d.day AND m.completed_at < d.day + INTERVAL '1 day'
+		) mc
+		WHERE u.role = ANY($3) AND d.day::date >= u.created_at::date
+		AND (u.deleted_at IS NULL OR d.day::date < u.deleted_at::date)
+		ON CONFLICT (user_id, date) DO UPDATE SET
+			supervisor_id = EXCLUDED.supervisor_id, mining_site = EXCLUDED.mining_site,
+			checklist_items = EXCLUDED.checklist_items, checklist_completed = EXCLUDED.checklist_completed,
+			checklist_pct = EXCLUDED.checklist_pct, ppe_items = EXCLUDED.ppe_items,
+			ppe_completed = EXCLUDED.ppe_completed, ppe_pct = EXCLUDED.ppe_pct,
+			modules_completed = EXCLUDED.modules_completed, on_leave = EXCLUDED.on_leave, refreshed_at = NOW()
+	`, from, to, crewRoles())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartComplianceSummaryJob keeps today's and yesterday's summaries current (late ticks
+// still land on yesterday's date) and backfills history on a fresh table
+func StartComplianceSummaryJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var empty bool
+		database.DB.QueryRow("SELECT NOT EXISTS(SELECT 1 FROM daily_compliance_summary)").Scan(&empty)
+		from := -1
+		if empty {
+			from = -complianceBackfillDays
+		}
+		for {
+			now := time.Now()
+			if _, err := refreshComplianceSummary(now.AddDate(0, 0, from).Format("2006-01-02"), now.Format("2006-01-02")); err != nil {
+				log.Printf("Warning: compliance summary refresh failed: %v", err)
+			}
+			from = -1
+			<-ticker.C
+		}
+	}()
+}
+
+// GetComplianceSummary - Daily compliance per miner and per day for the supervisor's team
+// GET /api/supervisor/compliance?from=2025-01-01&to=2025-01-31&miner_id=MIN-xxx
+func GetComplianceSummary(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	from, to, ok := complianceRange(w, r)
+	if !ok {
+		return
+	}
+
+	query := `
+		SELECT s.user_id, u.name, s.date, s.checklist_items, s.checklist_completed, s.checklist_pct,
+		       s.ppe_items, s.ppe_completed, s.ppe_pct, s.modules_completed, s.on_leave
+		FROM daily_compliance_summary s
+		JOIN users u ON u.user_id = s.user_id
+		WHERE s.supervisor_id = $1 AND s.date BETWEEN $2 AND $3`
+	args := []interface{}{supervisorID, from, to}
+	if minerID := r.URL.Query().Get("miner_id"); minerID != "" {
+		query += " AND s.user_id = $4"
+		args = append(args, minerID)
+	}
+	query += " ORDER BY s.date DESC, u.name"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	days := []DailyCompliance{}
+	team := []*TeamDailyCompliance{}
+	byDate := map[string]*TeamDailyCompliance{}
+	for rows.Next() {
+		var d DailyCompliance
+		var date time.Time
+		if err := rows.Scan(&d.UserID, &d.MinerName, &date, &d.ChecklistItems, &d.ChecklistCompleted, &d.ChecklistPct,
+			&d.PPEItems, &d.PPECompleted, &d.PPEPct, &d.ModulesCompleted, &d.OnLeave); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		d.Date = date.Format("2006-01-02")
+		days = append(days, d)
+
+		t, ok := byDate[d.Date]
+		if !ok {
+			t = &TeamDailyCompliance{Date: d.Date}
+			byDate[d.Date] = t
+			team = append(team, t)
+		}
+		if d.OnLeave {
+			t.OnLeave++
+			continue
+		}
+		t.Miners++
+		t.ChecklistPct += d.ChecklistPct
+		t.PPEPct += d.PPEPct
+		t.ModulesCompleted += d.ModulesCompleted
+	}
+	for _, t := range team {
+		if t.Miners == 0 {
+			continue
+		}
+		t.ChecklistPct = math.Round(t.ChecklistPct/float64(t.Miners)*10) / 10
+		t.PPEPct = math.Round(t.PPEPct/float64(t.Miners)*10) / 10
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"team":   team,
+		"miners": days,
+	})
+}
+
+// AdminRefreshComplianceSummary - Recompute the summary for a date range, e.g. after
+// correcting historical checklist data
+// POST /api/admin/compliance/refresh?from=2025-01-01&to=2025-01-31
+func AdminRefreshComplianceSummary(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := complianceRange(w, r)
+	if !ok {
+		return
+	}
+	n, err := refreshComplianceSummary(from, to)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error refreshing summary: "+err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"from":    from,
+		"to":      to,
+		"rows":    n,
+	})
+}
+
+// complianceRange reads from/to, defaulting to the last 7 days and capping at a year
+func complianceRange(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	now := time.Now()
+	from := now.AddDate(0, 0, -6)
+	to := now
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid from date, use YYYY-MM-DD")
+			return "", "", false
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid to date, use YYYY-MM-DD")
+			return "", "", false
+		}
+		to = t
+	}
+	if to.Before(from) || to.Sub(from) > 366*24*time.Hour {
+		respondWithError(w, http.StatusBadRequest, "Date range must be between 1 and 366 days")
+		return "", "", false
+	}
+	return from.Format("2006-01-02"), to.Format("2006-01-02"), true
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"net/http"
+)
+
+// AdminGetConfig - Runtime configuration with secrets redacted
+// GET /api/admin/config
+func AdminGetConfig(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, config.Get().Redacted())
+}
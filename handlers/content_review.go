@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== CONTENT EXPIRY & REVIEW CYCLES ====================
+
+// ModuleStatusExpired is the approval status of a module taken down at its expires_at
+const ModuleStatusExpired = "expired"
+
+const (
+	// reviewReminderLead is how far ahead of review_due_at the author is first reminded
+	reviewReminderLead = 7 * 24 * time.Hour
+	// reviewReminderInterval is the gap between repeated reminders for an overdue review
+	reviewReminderInterval = 7 * 24 * time.Hour
+)
+
+// ReviewDueModule is a module whose review is coming up or overdue, or that expires soon
+type ReviewDueModule struct {
+	ID             int        `json:"id"`
+	Title          string     `json:"title"`
+	Category       string     `json:"category"`
+	Status         string     `json:"status"`
+	ReviewDueAt    *time.Time `json:"review_due_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at,omitempty"`
+	Overdue        bool       `json:"overdue"`
+}
+
+// StartContentReviewJob periodically takes expired modules out of circulation and reminds
+// authors about procedures due for review
+func StartContentReviewJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			expireModules()
+			remindModuleReviews()
+			<-ticker.C
+		}
+	}()
+}
+
+// expireModules deactivates modules past expires_at. Deactivating rather than filtering
+// every query keeps expired videos out of the feed, trending, quizzes and delta sync alike.
+func expireModules() {
+	rows, err := database.DB.Query(`
+		UPDATE video_modules SET is_active = false, approval_status = $1, updated_at = NOW()
+		WHERE is_active = true AND expires_at <= NOW()
+		RETURNING id, title, COALESCE(created_by, '')
+	`, ModuleStatusExpired)
+	if err != nil {
+		log.Printf("Warning: module expiry failed: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var title, createdBy string
+		if err := rows.Scan(&id, &title, &createdBy); err != nil {
+			continue
+		}
+		for _, recipient := range moduleReviewers(createdBy) {
+			notifyUser(recipient, "MODULE_EXPIRED", "Module expired",
+				fmt.Sprintf("\"%s\" reached its expiry date and was removed from the feed. Review and renew it to republish.", title),
+				"video_module", strconv.Itoa(id))
+		}
+	}
+}
+
+// remindModuleReviews notifies authors when a module's review is due within a week,
+// repeating weekly until it is reviewed
+func remindModuleReviews() {
+	rows, err := database.DB.Query(`
+		UPDATE video_modules SET review_reminded_at = NOW()
+		WHERE is_active = true AND review_due_at <= NOW() + $1 * INTERVAL '1 second'
+		AND (review_reminded_at IS NULL OR review_reminded_at <= NOW() - $2 * INTERVAL '1 second')
+		RETURNING id, title, COALESCE(created_by, ''), review_due_at
+	`, reviewReminderLead.Seconds(), reviewReminderInterval.Seconds())
+	if err != nil {
+		log.Printf("Warning: module review reminders failed: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var title, createdBy string
+		var dueAt time.Time
+		if err := rows.Scan(&id, &title, &createdBy, &dueAt); err != nil {
+			continue
+		}
+		message := fmt.Sprintf("\"%s\" is due for review on %s. Check it still matches current procedures.", title, dueAt.Format("2006-01-02"))
+		if dueAt.Before(time.Now()) {
+			message = fmt.Sprintf("\"%s\" was due for review on %s and may describe outdated procedures.", title, dueAt.Format("2006-01-02"))
+		}
+		for _, recipient := range moduleReviewers(createdBy) {
+			notifyUser(recipient, "MODULE_REVIEW_DUE", "Module review due", message, "video_module", strconv.Itoa(id))
+		}
+	}
+}
+
+// moduleReviewers is the module's author, or every admin for seeded content with no author
+func moduleReviewers(createdBy string) []string {
+	if createdBy != "" {
+		return []string{createdBy}
+	}
+	reviewers := []string{}
+	rows, err := database.DB.Query("SELECT user_id FROM users WHERE role = 'ADMIN' AND deleted_at IS NULL")
+	if err != nil {
+		return reviewers
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			reviewers = append(reviewers, id)
+		}
+	}
+	return reviewers
+}
+
+// GetModulesDueForReview - This supervisor's modules due for review or expiring within 30 days
+// GET /api/supervisor/modules/review-due?days=30
+func GetModulesDueForReview(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, title, COALESCE(category, ''), COALESCE(approval_status, ''), review_due_at, expires_at, last_reviewed_at
+		FROM video_modules
+		WHERE created_by = $1
+		AND (review_due_at <= NOW() + make_interval(days => $2)
+		     OR expires_at <= NOW() + make_interval(days => $2))
+		ORDER BY LEAST(COALESCE(review_due_at, 'infinity'), COALESCE(expires_at, 'infinity')) ASC
+	`, supervisorID, days)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	modules := []ReviewDueModule{}
+	for rows.Next() {
+		var m ReviewDueModule
+		var reviewDue, expires, lastReviewed sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Title, &m.Category, &m.Status, &reviewDue, &expires, &lastReviewed); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning module data: "+err.Error())
+			return
+		}
+		if reviewDue.Valid {
+			m.ReviewDueAt = &reviewDue.Time
+			m.Overdue = reviewDue.Time.Before(now)
+		}
+		if expires.Valid {
+			m.ExpiresAt = &expires.Time
+		}
+		if lastReviewed.Valid {
+			m.LastReviewedAt = &lastReviewed.Time
+		}
+		modules = append(modules, m)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"modules": modules,
+		"days":    days,
+	})
+}
+
+// ReviewModuleContentRequest represents the request body for recording a content review.
+// Omitted dates are left unchanged; clear_expiry removes the expiry date.
+type ReviewModuleContentRequest struct {
+	ReviewDueAt *time.Time `json:"review_due_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	ClearExpiry bool       `json:"clear_expiry"`
+	Notes       string     `json:"notes"`
+}
+
+// ReviewModuleContent - Confirm a module is still accurate and set its next review and
+// expiry dates. An expired module given a later (or no) expiry date is republished.
+// PUT /api/supervisor/modules/{id}/content-review
+func ReviewModuleContent(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	moduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+
+	var req ReviewModuleContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	now := time.Now()
+	if req.ReviewDueAt != nil && !req.ReviewDueAt.After(now) {
+		respondWithError(w, http.StatusBadRequest, "review_due_at must be in the future")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		respondWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	var status string
+	var expiresAt sql.NullTime
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(approval_status, ''), expires_at FROM video_modules WHERE id = $1 AND created_by = $2
+	`, moduleID, supervisorID).Scan(&status, &expiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Module not found")
+		return
+	}
+
+	newExpiry := expiresAt
+	if req.ClearExpiry {
+		newExpiry = sql.NullTime{}
+	} else if req.ExpiresAt != nil {
+		newExpiry = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+	if status == ModuleStatusExpired && newExpiry.Valid && !newExpiry.Time.After(now) {
+		respondWithError(w, http.StatusBadRequest, "Set a future expires_at or clear_expiry to republish an expired module")
+		return
+	}
+
+	query := `
+		UPDATE video_modules SET expires_at = $1, last_reviewed_at = NOW(), review_reminded_at = NULL,
+		       review_notes = $2, review_due_at = COALESCE($3, review_due_at), updated_at = NOW()`
+	if status == ModuleStatusExpired {
+		query += ", is_active = true, approval_status = 'approved'"
+	}
+	query += " WHERE id = $4"
+	if _, err := database.DB.Exec(query, newExpiry, req.Notes, req.ReviewDueAt, moduleID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating module: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"message":     "Module review recorded",
+		"republished": status == ModuleStatusExpired,
+	})
+}
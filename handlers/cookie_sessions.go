@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"MineSafeBackend/middleware"
+	"net/http"
+)
+
+// ==================== COOKIE SESSIONS ====================
+
+// StartCookieSession - Move the dashboard's current session into an HttpOnly cookie so
+// scripts on the page never hold the token. The response carries the CSRF token that every
+// POST, PUT and DELETE made with the cookie must send in X-CSRF-Token; it is also in the
+// readable minesafe_csrf cookie. Log out with POST /api/auth/logout as usual.
+// POST /api/auth/session
+func StartCookieSession(w http.ResponseWriter, r *http.Request) {
+	if !middleware.CookieSessionsEnabled() {
+		respondWithError(w, http.StatusNotFound, "Cookie sessions are not enabled")
+		return
+	}
+	token, ok := middleware.GetTokenFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if token.FromCookie {
+		// Already a cookie session; hand back its CSRF token, e.g. after a page reload
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"csrf_token": middleware.CSRFToken(token.ID),
+			"expires_at": token.ExpiresAt,
+		})
+		return
+	}
+	if token.Audience != middleware.AudienceDashboard || token.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Only dashboard sessions can use cookies; log in again from the dashboard")
+		return
+	}
+
+	bearer := r.Header.Get("Authorization")[len("Bearer "):]
+	csrf := middleware.SetSessionCookies(w, bearer, token)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"csrf_token": csrf,
+		"expires_at": token.ExpiresAt,
+	})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ==================== COUNTER RECONCILIATION ====================
+
+// reconcileVideoCounters recomputes the denormalized counters on video_modules from their
+// source tables and returns how many videos had drifted. Only rows that differ are updated.
+func reconcileVideoCounters() (int64, error) {
+	result, err := database.DB.Exec(`
+		UPDATE video_modules vm SET
+			likes_count = src.likes,
+			dislikes_count = src.dislikes,
+			views_count = src.views,
+			completions_count = src.completions
+		FROM (
+			SELECT v.id,
+				(SELECT COUNT(*) FROM video_reactions r WHERE r.video_id = v.id AND r.reaction_type = 'like') AS likes,
+				(SELECT COUNT(*) FROM video_reactions r WHERE r.video_id = v.id AND r.reaction_type = 'dislike') AS dislikes,
+				(SELECT COUNT(*) FROM video_views vv WHERE vv.video_id = v.id) AS views,
+				(SELECT COUNT(*) FROM module_completions mc WHERE mc.video_id = v.id AND mc.passed) AS completions
+			FROM video_modules v
+		) src
+		WHERE vm.id = src.id AND (
+			COALESCE(vm.likes_count, 0) <> src.likes OR
+			COALESCE(vm.dislikes_count, 0) <> src.dislikes OR
+			COALESCE(vm.views_count, 0) <> src.views OR
+			COALESCE(vm.completions_count, 0) <> src.completions
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartCounterReconciliationJob periodically repairs like, dislike, view and completion
+// counters that drifted from their source tables
+func StartCounterReconciliationJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			corrected, err := reconcileVideoCounters()
+			if err != nil {
+				log.Printf("Warning: counter reconciliation failed: %v", err)
+			} else if corrected > 0 {
+				log.Printf("Counter reconciliation corrected %d videos", corrected)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ReconcileCounters - Recompute video counters from source tables now
+// POST /api/admin/counters/reconcile
+func ReconcileCounters(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	corrected, err := reconcileVideoCounters()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reconciling counters: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"videos_corrected": corrected,
+	})
+}
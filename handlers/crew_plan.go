@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ==================== CREW PLANNING ====================
+
+// Problems a proposed plan can have. Violations block the plan, warnings don't.
+const (
+	PlanViolationUnknownMiner   = "UNKNOWN_MINER"
+	PlanViolationUnknownZone    = "UNKNOWN_ZONE"
+	PlanViolationUnknownShift   = "UNKNOWN_SHIFT"
+	PlanViolationDuplicate      = "DUPLICATE_ASSIGNMENT"
+	PlanViolationCapacity       = "ZONE_CAPACITY"
+	PlanViolationMissingDocs    = "MISSING_DOCUMENTS"
+	PlanViolationExpiredMedical = "EXPIRED_MEDICAL"
+	PlanViolationInduction      = "ONBOARDING_INCOMPLETE"
+	PlanWarningShiftNotRunning  = "SHIFT_NOT_RUNNING"
+	PlanWarningOnLeave          = "ON_LEAVE"
+)
+
+// maxPlanAssignments bounds the work one validation request can cause
+const maxPlanAssignments = 500
+
+// PlanAssignment puts one crew member in a zone, optionally on a shift window
+type PlanAssignment struct {
+	MinerID string `json:"miner_id"`
+	ZoneID  int    `json:"zone_id"`
+	ShiftID int    `json:"shift_id,omitempty"`
+}
+
+// ValidatePlanRequest represents the request body for checking a crew plan
+type ValidatePlanRequest struct {
+	Date        string           `json:"date"` // YYYY-MM-DD, defaults to tomorrow
+	Assignments []PlanAssignment `json:"assignments"`
+}
+
+// PlanIssue is one problem found in a plan
+type PlanIssue struct {
+	Type      string   `json:"type"`
+	MinerID   string   `json:"miner_id,omitempty"`
+	MinerName string   `json:"miner_name,omitempty"`
+	ZoneID    int      `json:"zone_id,omitempty"`
+	ShiftID   int      `json:"shift_id,omitempty"`
+	Message   string   `json:"message"`
+	Documents []string `json:"documents,omitempty"`
+}
+
+type planZone struct {
+	name      string
+	capacity  int
+	required  []string
+	occupants []string // crew currently allocated to the zone
+}
+
+// planDocumentsMissing lists the required document types the miner won't hold a valid copy
+// of for the whole of date
+func planDocumentsMissing(minerID, date string, required []string) ([]string, error) {
+	if len(required) == 0 {
+		return []string{}, nil
+	}
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT document_type FROM user_documents
+		WHERE user_id = $1 AND document_type = ANY($2) AND expires_at >= $3::date + 1
+	`, minerID, pq.Array(required), date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	held := map[string]bool{}
+	for rows.Next() {
+		var docType string
+		if err := rows.Scan(&docType); err != nil {
+			return nil, err
+		}
+		held[docType] = true
+	}
+	missing := []string{}
+	for _, docType := range required {
+		if !held[docType] {
+			missing = append(missing, docType)
+		}
+	}
+	return missing, rows.Err()
+}
+
+// ValidateCrewPlan - Check proposed zone and shift assignments for a day before committing
+// them: zone capacity, required documents, expired medicals and unfinished induction
+// POST /api/supervisor/plan/validate
+func ValidateCrewPlan(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ValidatePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.Assignments) == 0 || len(req.Assignments) > maxPlanAssignments {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("assignments must have between 1 and %d entries", maxPlanAssignments))
+		return
+	}
+	if req.Date == "" {
+		req.Date = time.Now().In(userLocation(supervisorID)).AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	day, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+
+	// Crew under this supervisor
+	crew := map[string]string{}
+	rows, err := database.DB.Query(
+		"SELECT user_id, name FROM users WHERE supervisor_id = $1 AND role = ANY($2) AND deleted_at IS NULL",
+		supervisorID, crewRoles(),
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning crew")
+			return
+		}
+		crew[id] = name
+	}
+	rows.Close()
+
+	// Zones named in the plan, with who is allocated to them now
+	zones := map[int]*planZone{}
+	for _, a := range req.Assignments {
+		if _, seen := zones[a.ZoneID]; seen {
+			continue
+		}
+		var z planZone
+		var requiredJSON []byte
+		err := database.DB.QueryRow(`
+			SELECT name, capacity, COALESCE(required_documents, '[]'::jsonb),
+			       COALESCE((SELECT array_agg(user_id) FROM users WHERE zone_id = z.id AND deleted_at IS NULL), '{}')
+			FROM mine_zones z WHERE z.id = $1 AND z.is_active = true
+		`, a.ZoneID).Scan(&z.name, &z.capacity, &requiredJSON, pq.Array(&z.occupants))
+		if err == sql.ErrNoRows {
+			zones[a.ZoneID] = nil
+			continue
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		z.required = parseRequiredDocuments(requiredJSON)
+		zones[a.ZoneID] = &z
+	}
+
+	var miningSite string
+	database.DB.QueryRow("SELECT COALESCE(mining_site, '') FROM users WHERE user_id = $1", supervisorID).Scan(&miningSite)
+	windows, err := loadShiftWindows(miningSite)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	shifts := map[int]ShiftWindow{}
+	for _, sw := range windows {
+		shifts[sw.ID] = sw
+	}
+
+	violations := []PlanIssue{}
+	warnings := []PlanIssue{}
+	planned := map[string]bool{}
+	// Headcount per zone and shift; shift 0 is "no particular shift"
+	type slot struct{ zone, shift int }
+	headcount := map[slot]int{}
+
+	for _, a := range req.Assignments {
+		issue := PlanIssue{MinerID: a.MinerID, MinerName: crew[a.MinerID], ZoneID: a.ZoneID, ShiftID: a.ShiftID}
+		add := func(list *[]PlanIssue, kind, message string, docs []string) {
+			i := issue
+			i.Type, i.Message, i.Documents = kind, message, docs
+			*list = append(*list, i)
+		}
+
+		if _, ok := crew[a.MinerID]; !ok {
+			add(&violations, PlanViolationUnknownMiner, "Not a member of your crew", nil)
+			continue
+		}
+		if planned[a.MinerID] {
+			add(&violations, PlanViolationDuplicate, "Assigned more than once in this plan", nil)
+			continue
+		}
+		planned[a.MinerID] = true
+
+		zone := zones[a.ZoneID]
+		if zone == nil {
+			add(&violations, PlanViolationUnknownZone, "Zone not found or inactive", nil)
+			continue
+		}
+		if a.ShiftID != 0 {
+			sw, ok := shifts[a.ShiftID]
+			if !ok {
+				add(&violations, PlanViolationUnknownShift, "Shift window not found for your site", nil)
+				continue
+			}
+			if !sw.runsOn(day.Weekday()) {
+				add(&warnings, PlanWarningShiftNotRunning, fmt.Sprintf("%s does not run on %s", sw.Name, day.Weekday()), nil)
+			}
+		}
+		headcount[slot{a.ZoneID, a.ShiftID}]++
+
+		inducted, err := minerInductionComplete(a.MinerID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if !inducted {
+			add(&violations, PlanViolationInduction, "Has not completed induction", nil)
+		}
+
+		missing, err := planDocumentsMissing(a.MinerID, req.Date, zone.required)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if len(missing) > 0 {
+			add(&violations, PlanViolationMissingDocs,
+				fmt.Sprintf("Needs valid %s for %s", strings.Join(missing, ", "), zone.name), missing)
+		}
+
+		// A medical that has lapsed by the plan date, and no newer one, is flagged even where
+		// the zone doesn't list it
+		var medicalLapsed bool
+		err = database.DB.QueryRow(`
+			SELECT COALESCE(MAX(expires_at) < $2::date + 1, false) FROM user_documents
+			WHERE user_id = $1 AND document_type = $3
+		`, a.MinerID, req.Date, DocumentTypeMedical).Scan(&medicalLapsed)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if medicalLapsed && !containsString(missing, DocumentTypeMedical) {
+			add(&violations, PlanViolationExpiredMedical, "Medical certificate expires before or on this day", nil)
+		}
+
+		if until, err := minerLeaveOn(a.MinerID, req.Date); err == nil && until != "" {
+			add(&warnings, PlanWarningOnLeave, "On leave until "+until, nil)
+		}
+	}
+
+	// Crew already in a zone and not moved by the plan stay there, on every shift
+	for slotKey, count := range headcount {
+		zone := zones[slotKey.zone]
+		staying := 0
+		for _, userID := range zone.occupants {
+			if !planned[userID] {
+				staying++
+			}
+		}
+		if total := count + staying; total > zone.capacity {
+			message := fmt.Sprintf("%s would hold %d people, capacity is %d", zone.name, total, zone.capacity)
+			if slotKey.shift != 0 {
+				message += " on " + shifts[slotKey.shift].Name
+			}
+			violations = append(violations, PlanIssue{
+				Type: PlanViolationCapacity, ZoneID: slotKey.zone, ShiftID: slotKey.shift, Message: message,
+			})
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"date":        req.Date,
+		"valid":       len(violations) == 0,
+		"assignments": len(req.Assignments),
+		"violations":  violations,
+		"warnings":    warnings,
+	})
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// ==================== ACTING SUPERVISOR ====================
+
+// A supervisor going on leave can hand their crew to another supervisor for a date range.
+// While the delegation is active the acting supervisor sees those miners, their PPE stats
+// and the absent supervisor's checklists as if they were their own. Delegation doesn't chain:
+// an acting supervisor can't pass on crew that was only delegated to them.
+
+// maxDelegationDays bounds a single delegation; longer absences should reassign the crew
+const maxDelegationDays = 90
+
+// Delegation hands a supervisor's crew to an acting supervisor for a date range
+type Delegation struct {
+	ID             int        `json:"id"`
+	SupervisorID   string     `json:"supervisor_id"`
+	SupervisorName string     `json:"supervisor_name"`
+	DelegateID     string     `json:"delegate_id"`
+	DelegateName   string     `json:"delegate_name"`
+	StartDate      string     `json:"start_date"`
+	EndDate        string     `json:"end_date"`
+	Reason         string     `json:"reason,omitempty"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// DelegationRequest represents the request body for delegating crew to an acting supervisor
+type DelegationRequest struct {
+	DelegateID string `json:"delegate_id"`
+	StartDate  string `json:"start_date"` // YYYY-MM-DD
+	EndDate    string `json:"end_date"`   // YYYY-MM-DD, inclusive
+	Reason     string `json:"reason"`
+}
+
+// actingFor returns the supervisors whose crew supervisorID is covering today
+func actingFor(supervisorID string) []string {
+	rows, err := database.DB.Query(`
+		SELECT supervisor_id FROM supervisor_delegations
+		WHERE delegate_id = $1 AND revoked_at IS NULL AND $2::date BETWEEN start_date AND end_date
+	`, supervisorID, userToday(supervisorID))
+	if err != nil {
+		log.Printf("Warning: failed to load delegations for %s: %v", supervisorID, err)
+		return nil
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// managedSupervisors is the query argument for supervisor_id = ANY(...) matching the
+// supervisor themselves and everyone they are acting for today
+func managedSupervisors(supervisorID string) interface{} {
+	return pq.Array(append([]string{supervisorID}, actingFor(supervisorID)...))
+}
+
+// CreateDelegation - Hand this supervisor's crew to another supervisor for a date range
+// POST /api/supervisor/delegations
+func CreateDelegation(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req DelegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.DelegateID = strings.TrimSpace(req.DelegateID)
+	if req.DelegateID == "" || req.DelegateID == supervisorID {
+		respondWithError(w, http.StatusBadRequest, "delegate_id must be another supervisor")
+		return
+	}
+	start, errStart := time.Parse("2006-01-02", req.StartDate)
+	end, errEnd := time.Parse("2006-01-02", req.EndDate)
+	if errStart != nil || errEnd != nil {
+		respondWithError(w, http.StatusBadRequest, "start_date and end_date must be YYYY-MM-DD")
+		return
+	}
+	if end.Before(start) || req.EndDate < userToday(supervisorID) {
+		respondWithError(w, http.StatusBadRequest, "end_date must be today or later and not before start_date")
+		return
+	}
+	if end.Sub(start) >= maxDelegationDays*24*time.Hour {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A delegation can last at most %d days", maxDelegationDays))
+		return
+	}
+
+	// The acting supervisor must be an active supervisor of the same organization
+	var delegateName string
+	err := database.DB.QueryRow(`
+		SELECT d.name FROM users d, users s
+		WHERE d.user_id = $1 AND s.user_id = $2 AND d.role = $3 AND COALESCE(d.is_active, true) AND d.deleted_at IS NULL
+		AND d.org_id IS NOT DISTINCT FROM s.org_id
+	`, req.DelegateID, supervisorID, models.RoleSupervisor).Scan(&delegateName)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Acting supervisor not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// One acting supervisor at a time, so the crew always knows who to go to
+	var overlapping bool
+	database.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM supervisor_delegations
+		              WHERE supervisor_id = $1 AND revoked_at IS NULL AND start_date <= $3 AND end_date >= $2)
+	`, supervisorID, req.StartDate, req.EndDate).Scan(&overlapping)
+	if overlapping {
+		respondWithError(w, http.StatusConflict, "You already have a delegation covering some of these dates")
+		return
+	}
+
+	var id int
+	err = database.DB.QueryRow(`
+		INSERT INTO supervisor_delegations (supervisor_id, delegate_id, start_date, end_date, reason)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id
+	`, supervisorID, req.DelegateID, req.StartDate, req.EndDate, truncate(strings.TrimSpace(req.Reason), 500)).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating delegation: "+err.Error())
+		return
+	}
+
+	var supervisorName string
+	database.DB.QueryRow("SELECT name FROM users WHERE user_id = $1", supervisorID).Scan(&supervisorName)
+	notifyUser(req.DelegateID, "DELEGATION_ASSIGNED", "You are acting supervisor",
+		fmt.Sprintf("You are covering %s's crew from %s to %s", supervisorName, req.StartDate, req.EndDate),
+		"delegation", fmt.Sprint(id))
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Crew delegated to " + delegateName,
+	})
+}
+
+// GetDelegations - Delegations this supervisor has made and those made to them, current and
+// upcoming; ?include_past=true adds finished and revoked ones
+// GET /api/supervisor/delegations
+func GetDelegations(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	today := userToday(supervisorID)
+	where := " WHERE (d.supervisor_id = $1 OR d.delegate_id = $1)"
+	if r.URL.Query().Get("include_past") != "true" {
+		where += " AND d.revoked_at IS NULL AND d.end_date >= $2::date"
+	}
+	rows, err := database.DB.Query(`
+		SELECT d.id, d.supervisor_id, COALESCE(s.name, ''), d.delegate_id, COALESCE(a.name, ''),
+		       TO_CHAR(d.start_date, 'YYYY-MM-DD'), TO_CHAR(d.end_date, 'YYYY-MM-DD'), COALESCE(d.reason, ''),
+		       d.revoked_at IS NULL AND $2::date BETWEEN d.start_date AND d.end_date,
+		       d.created_at, d.revoked_at
+		FROM supervisor_delegations d
+		LEFT JOIN users s ON s.user_id = d.supervisor_id
+		LEFT JOIN users a ON a.user_id = d.delegate_id`+where+`
+		ORDER BY d.start_date DESC
+		LIMIT 200`, supervisorID, today)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	given, received := []Delegation{}, []Delegation{}
+	for rows.Next() {
+		var d Delegation
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.SupervisorID, &d.SupervisorName, &d.DelegateID, &d.DelegateName,
+			&d.StartDate, &d.EndDate, &d.Reason, &d.Active, &d.CreatedAt, &revokedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		if revokedAt.Valid {
+			d.RevokedAt = &revokedAt.Time
+		}
+		if d.SupervisorID == supervisorID {
+			given = append(given, d)
+		} else {
+			received = append(received, d)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"delegated": given,
+		"acting":    received,
+	})
+}
+
+// RevokeDelegation - End a delegation early; either the supervisor or the acting supervisor
+// can do this
+// DELETE /api/supervisor/delegations/{id}
+func RevokeDelegation(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var ownerID, delegateID string
+	err := database.DB.QueryRow(`
+		UPDATE supervisor_delegations SET revoked_at = NOW(), revoked_by = $2
+		WHERE id = $1 AND revoked_at IS NULL AND (supervisor_id = $2 OR delegate_id = $2)
+		RETURNING supervisor_id, delegate_id
+	`, mux.Vars(r)["id"], supervisorID).Scan(&ownerID, &delegateID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Delegation not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Tell whichever side didn't end it
+	other := delegateID
+	if supervisorID == delegateID {
+		other = ownerID
+	}
+	notifyUser(other, "DELEGATION_REVOKED", "Delegation ended", "An acting supervisor delegation was ended early",
+		"delegation", mux.Vars(r)["id"])
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Delegation ended",
+	})
+}
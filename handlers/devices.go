@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== DEVICE REGISTRATION ====================
+
+// The miner app registers the phone it runs on and gets back a token bound to that phone's
+// X-Device-ID. Supervisors can deactivate a lost or stolen phone, which signs it out and
+// stops it from submitting PPE checks.
+
+// Device is a phone registered to a user
+type Device struct {
+	ID            int        `json:"id"`
+	UserID        string     `json:"user_id"`
+	UserName      string     `json:"user_name,omitempty"`
+	DeviceID      string     `json:"device_id"`
+	Model         string     `json:"model"`
+	OS            string     `json:"os"`
+	OSVersion     string     `json:"os_version"`
+	AppVersion    string     `json:"app_version"`
+	HasPushToken  bool       `json:"has_push_token"`
+	RegisteredAt  time.Time  `json:"registered_at"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	DeactivatedBy string     `json:"deactivated_by,omitempty"`
+	Active        bool       `json:"active"`
+}
+
+// RegisterDeviceRequest represents the request body for registering a device
+type RegisterDeviceRequest struct {
+	Model      string `json:"model"`
+	OS         string `json:"os"`
+	OSVersion  string `json:"os_version"`
+	AppVersion string `json:"app_version"`
+	PushToken  string `json:"push_token"`
+}
+
+// deviceDeactivated reports whether the request comes from a device deactivated for this user
+func deviceDeactivated(r *http.Request, userID string) bool {
+	deviceID := strings.TrimSpace(r.Header.Get(headerDeviceID))
+	if deviceID == "" {
+		return false
+	}
+	var deactivated bool
+	database.DB.QueryRow(
+		"SELECT deactivated_at IS NOT NULL FROM devices WHERE user_id = $1 AND device_id = $2",
+		userID, deviceID,
+	).Scan(&deactivated)
+	return deactivated
+}
+
+// RegisterDevice - Register the phone the app runs on, identified by its X-Device-ID, or
+// update its details and push token. Returns a session token bound to the device that
+// replaces the one used to call this; it is only accepted with the same X-Device-ID.
+// POST /api/app/devices
+func RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseImpersonated(w, r) {
+		return
+	}
+	current, _ := middleware.GetTokenFromContext(r.Context())
+	if current.Audience != middleware.AudienceMobile {
+		respondWithError(w, http.StatusBadRequest, "Only app sessions can register a device")
+		return
+	}
+	deviceID := strings.TrimSpace(r.Header.Get(headerDeviceID))
+	if deviceID == "" {
+		respondWithError(w, http.StatusBadRequest, headerDeviceID+" header is required")
+		return
+	}
+
+	var req RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Model == "" {
+		req.Model = r.Header.Get(headerDeviceModel)
+	}
+	if req.OS == "" {
+		req.OS = r.Header.Get(headerPlatform)
+	}
+	if req.AppVersion == "" {
+		req.AppVersion = r.Header.Get(headerAppVersion)
+	}
+
+	var device Device
+	var deactivatedAt sql.NullTime
+	err := database.DB.QueryRow(`
+		INSERT INTO devices (user_id, device_id, model, os, os_version, app_version, push_token)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''))
+		ON CONFLICT (user_id, device_id) DO UPDATE SET
+			model = COALESCE(EXCLUDED.model, devices.model),
+			os = COALESCE(EXCLUDED.os, devices.os),
+			os_version = COALESCE(EXCLUDED.os_version, devices.os_version),
+			app_version = COALESCE(EXCLUDED.app_version, devices.app_version),
+			push_token = CASE WHEN devices.deactivated_at IS NULL
+			                  THEN COALESCE(EXCLUDED.push_token, devices.push_token) END,
+			last_seen_at = NOW()
+		RETURNING id, COALESCE(model, ''), COALESCE(os, ''), COALESCE(os_version, ''), COALESCE(app_version, ''),
+		          push_token IS NOT NULL, registered_at, last_seen_at, deactivated_at
+	`, userID, truncate(deviceID, 255), truncate(req.Model, 255), truncate(strings.ToUpper(req.OS), 50),
+		truncate(req.OSVersion, 50), truncate(req.AppVersion, 50), strings.TrimSpace(req.PushToken),
+	).Scan(&device.ID, &device.Model, &device.OS, &device.OSVersion, &device.AppVersion,
+		&device.HasPushToken, &device.RegisteredAt, &device.LastSeenAt, &deactivatedAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if deactivatedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "This device has been deactivated; ask your supervisor")
+		return
+	}
+	device.UserID, device.DeviceID, device.Active = userID, deviceID, true
+
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	token, info, err := middleware.GenerateDeviceToken(userID, role, current.Audience,
+		middleware.DeviceFingerprint(deviceID), current.ExpiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+	recordSession(r, userID, current.Audience, info)
+	// The unbound token would still work from any phone
+	if current.Device == "" {
+		revokeToken(userID, current, "device_bound")
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"device":     device,
+		"token":      token,
+		"expires_at": info.ExpiresAt,
+	})
+}
+
+// GetCrewDevices - Phones registered by this supervisor's miners, most recently seen first
+// GET /api/supervisor/devices?user_id=&include_deactivated=true
+func GetCrewDevices(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	q := r.URL.Query()
+	args := []interface{}{supervisorID}
+	where := " WHERE u.supervisor_id = $1"
+	if v := q.Get("user_id"); v != "" {
+		args = append(args, v)
+		where += " AND d.user_id = $" + strconv.Itoa(len(args))
+	}
+	if q.Get("include_deactivated") != "true" {
+		where += " AND d.deactivated_at IS NULL"
+	}
+
+	// Sessions on the device show when it was last used, not just last registered
+	rows, err := database.DB.Query(`
+		SELECT d.id, d.user_id, u.name, d.device_id, COALESCE(d.model, ''), COALESCE(d.os, ''),
+		       COALESCE(d.os_version, ''), COALESCE(d.app_version, ''), d.push_token IS NOT NULL,
+		       d.registered_at,
+		       GREATEST(d.last_seen_at, (SELECT MAX(s.last_seen_at) FROM user_sessions s
+		                                 WHERE s.user_id = d.user_id AND s.device_id = d.device_id)),
+		       d.deactivated_at, COALESCE(d.deactivated_by, '')
+		FROM devices d
+		JOIN users u ON u.user_id = d.user_id`+where+`
+		ORDER BY 11 DESC
+		LIMIT 500`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		var d Device
+		var deactivatedAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.UserID, &d.UserName, &d.DeviceID, &d.Model, &d.OS, &d.OSVersion,
+			&d.AppVersion, &d.HasPushToken, &d.RegisteredAt, &d.LastSeenAt, &deactivatedAt, &d.DeactivatedBy); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		if deactivatedAt.Valid {
+			d.DeactivatedAt = &deactivatedAt.Time
+		}
+		d.Active = !deactivatedAt.Valid
+		devices = append(devices, d)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"devices": devices,
+		"count":   len(devices),
+	})
+}
+
+// DeactivateDeviceRequest represents the request body for deactivating a device
+type DeactivateDeviceRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DeactivateDevice - Deactivate a miner's lost or stolen phone. It is signed out, loses its
+// push token and can't register again or submit PPE checks.
+// POST /api/supervisor/devices/{id}/deactivate
+func DeactivateDevice(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+	var req DeactivateDeviceRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	var userID, deviceID string
+	err = database.DB.QueryRow(`
+		UPDATE devices d SET deactivated_at = COALESCE(d.deactivated_at, NOW()),
+		       deactivated_by = COALESCE(d.deactivated_by, $2),
+		       deactivation_reason = COALESCE(d.deactivation_reason, NULLIF($3, '')),
+		       push_token = NULL
+		FROM users u
+		WHERE d.id = $1 AND u.user_id = d.user_id AND u.supervisor_id = $2
+		RETURNING d.user_id, d.device_id
+	`, id, supervisorID, truncate(strings.TrimSpace(req.Reason), 500)).Scan(&userID, &deviceID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Sign out every session started on the device
+	rows, err := database.DB.Query(`
+		SELECT jti, expires_at FROM user_sessions
+		WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`, userID, deviceID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	var sessions []middleware.TokenInfo
+	for rows.Next() {
+		var token middleware.TokenInfo
+		if rows.Scan(&token.ID, &token.ExpiresAt) == nil {
+			sessions = append(sessions, token)
+		}
+	}
+	rows.Close()
+	for _, token := range sessions {
+		if err := revokeToken(userID, token, "device_deactivated"); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error signing out device: "+err.Error())
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"message":          "Device deactivated",
+		"sessions_revoked": len(sessions),
+	})
+}
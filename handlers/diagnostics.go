@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== STARTUP DIAGNOSTICS ====================
+
+// Diagnostic check outcomes. A failing check stops the server in strict mode; a warning
+// never does.
+const (
+	DiagnosticOK   = "ok"
+	DiagnosticWarn = "warn"
+	DiagnosticFail = "fail"
+	DiagnosticSkip = "skipped"
+)
+
+// DiagnosticCheck is the outcome of one startup check
+type DiagnosticCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// DiagnosticsReport is the result of the startup self-check
+type DiagnosticsReport struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Strict      bool              `json:"strict"`
+	Passed      bool              `json:"passed"`
+	Checks      []DiagnosticCheck `json:"checks"`
+}
+
+var (
+	lastDiagnosticsMu sync.RWMutex
+	lastDiagnostics   *DiagnosticsReport
+)
+
+// RunStartupDiagnostics verifies the environment the server depends on, logs one line per
+// check and keeps the report for GET /api/admin/diagnostics. Passed is false if any check
+// failed; the caller decides whether that is fatal (STRICT_STARTUP).
+func RunStartupDiagnostics() DiagnosticsReport {
+	cfg := config.Get()
+	report := DiagnosticsReport{
+		GeneratedAt: time.Now(),
+		Strict:      cfg.StrictStartup,
+		Passed:      true,
+		Checks: []DiagnosticCheck{
+			checkDatabase(),
+			checkRequiredEnv(cfg),
+			checkUploadsStorage(),
+			checkSeedAssets(cfg),
+			checkUserContacts(),
+			checkGeocoding(cfg),
+			checkFCMCredentials(cfg),
+		},
+	}
+
+	for _, c := range report.Checks {
+		if c.Status == DiagnosticFail {
+			report.Passed = false
+		}
+		log.Printf("diagnostics check=%s status=%s detail=%q", c.Name, c.Status, c.Detail)
+	}
+	log.Printf("diagnostics passed=%t strict=%t", report.Passed, report.Strict)
+
+	lastDiagnosticsMu.Lock()
+	lastDiagnostics = &report
+	lastDiagnosticsMu.Unlock()
+	return report
+}
+
+func checkDatabase() DiagnosticCheck {
+	check := DiagnosticCheck{Name: "database"}
+	if database.DB == nil {
+		check.Status, check.Detail = DiagnosticFail, "not connected"
+	} else if err := database.DB.Ping(); err != nil {
+		check.Status, check.Detail = DiagnosticFail, err.Error()
+	} else {
+		check.Status, check.Detail = DiagnosticOK, "connected"
+	}
+	return check
+}
+
+// checkRequiredEnv flags settings that config.Load accepts but that are unsafe or
+// incomplete for a real deployment
+func checkRequiredEnv(cfg *config.Config) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "environment", Status: DiagnosticOK}
+	var issues []string
+	if cfg.BaseURL == "" {
+		issues = append(issues, "BASE_URL not set, media URLs are relative")
+	}
+	if len(cfg.AllowedOrigins) == 1 && cfg.AllowedOrigins[0] == "*" {
+		issues = append(issues, "ALLOWED_ORIGINS allows all origins")
+	}
+	if os.Getenv("UPLOAD_URL_SECRET") == "" {
+		issues = append(issues, "UPLOAD_URL_SECRET not set, signing uploads with JWT_SECRET")
+	}
+	if len(issues) > 0 {
+		check.Status = DiagnosticWarn
+		check.Detail = strings.Join(issues, "; ")
+	} else {
+		check.Detail = "all recommended variables set"
+	}
+	return check
+}
+
+// checkUploadsStorage makes sure the uploads directory exists and a file can be written to it
+func checkUploadsStorage() DiagnosticCheck {
+	check := DiagnosticCheck{Name: "uploads_storage"}
+	// The handlers create their subdirectories on demand
+	uploadsRoot := config.Get().UploadsDir
+	created := false
+	if _, err := os.Stat(uploadsRoot); os.IsNotExist(err) {
+		if err := os.MkdirAll(uploadsRoot, 0755); err != nil {
+			check.Status, check.Detail = DiagnosticFail, "uploads directory missing and cannot be created: "+err.Error()
+			return check
+		}
+		created = true
+	}
+
+	probe, err := os.CreateTemp(uploadsRoot, ".write-check-*")
+	if err != nil {
+		check.Status, check.Detail = DiagnosticFail, "uploads directory is not writable: "+err.Error()
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	abs, _ := filepath.Abs(uploadsRoot)
+	check.Status, check.Detail = DiagnosticOK, "writable at "+abs
+	if created {
+		check.Status, check.Detail = DiagnosticWarn, "uploads directory was missing and has been created at "+abs
+	}
+	return check
+}
+
+func checkSeedAssets(cfg *config.Config) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "seed_assets", Status: DiagnosticOK, Detail: "all default videos present in " + cfg.AssetsDir}
+	if missing := database.MissingSeedAssets(cfg.AssetsDir); len(missing) > 0 {
+		check.Status = DiagnosticWarn
+		check.Detail = fmt.Sprintf("missing from %s: %s", cfg.AssetsDir, strings.Join(missing, ", "))
+	}
+	return check
+}
+
+// checkGeocoding makes one reverse-geocoding call so a revoked or mistyped LocationIQ key
+// shows up at startup instead of as coordinates in emergency reports
+// checkUserContacts flags accounts the email/phone cleanup could not normalize without
+// merging them; the unique indexes are not created until they are resolved
+func checkUserContacts() DiagnosticCheck {
+	check := DiagnosticCheck{Name: "user_contacts", Status: DiagnosticOK, Detail: "emails and phones are normalized and unique"}
+	emails, phones, invalid, err := database.ContactIssues()
+	if err != nil {
+		check.Status = DiagnosticSkip
+		check.Detail = err.Error()
+		return check
+	}
+	if emails+phones+invalid > 0 {
+		check.Status = DiagnosticWarn
+		check.Detail = fmt.Sprintf("%d duplicate emails, %d duplicate phones, %d phones not in E.164", emails, phones, invalid)
+	}
+	return check
+}
+
+func checkGeocoding(cfg *config.Config) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "geocoding"}
+	if cfg.LocationIQAPIKey == "" {
+		check.Status, check.Detail = DiagnosticWarn, "LOCATIONIQ_API_KEY not set, emergencies show raw coordinates"
+		return check
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("https://us1.locationiq.com/v1/reverse?key=" + cfg.LocationIQAPIKey + "&lat=-31.9523&lon=115.8613&format=json")
+	if err != nil {
+		check.Status, check.Detail = DiagnosticWarn, "LocationIQ unreachable: "+err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		check.Status, check.Detail = DiagnosticOK, "LocationIQ key accepted"
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		check.Status, check.Detail = DiagnosticFail, fmt.Sprintf("LocationIQ rejected the API key (%d)", resp.StatusCode)
+	default:
+		// Rate limits and outages are not a configuration problem
+		check.Status, check.Detail = DiagnosticWarn, fmt.Sprintf("LocationIQ returned %d", resp.StatusCode)
+	}
+	return check
+}
+
+// checkFCMCredentials validates the Firebase service account file used for push delivery.
+// Notifications are in-app only until it is configured.
+func checkFCMCredentials(cfg *config.Config) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "fcm_credentials"}
+	if cfg.FCMCredentialsFile == "" {
+		check.Status, check.Detail = DiagnosticSkip, "FCM_CREDENTIALS_FILE not set, push notifications disabled"
+		return check
+	}
+
+	data, err := os.ReadFile(cfg.FCMCredentialsFile)
+	if err != nil {
+		check.Status, check.Detail = DiagnosticFail, "cannot read FCM credentials: "+err.Error()
+		return check
+	}
+	var creds struct {
+		Type        string `json:"type"`
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		check.Status, check.Detail = DiagnosticFail, "FCM credentials are not valid JSON: "+err.Error()
+		return check
+	}
+	if creds.Type != "service_account" || creds.ProjectID == "" || creds.ClientEmail == "" || creds.PrivateKey == "" {
+		check.Status, check.Detail = DiagnosticFail, "FCM credentials are not a complete service account key"
+		return check
+	}
+	check.Status, check.Detail = DiagnosticOK, "service account for project "+creds.ProjectID
+	return check
+}
+
+// AdminGetDiagnostics - The startup self-check report; refresh=true runs the checks again
+// GET /api/admin/diagnostics?refresh=true
+func AdminGetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	if r.URL.Query().Get("refresh") == "true" {
+		respondWithJSON(w, http.StatusOK, RunStartupDiagnostics())
+		return
+	}
+
+	lastDiagnosticsMu.RLock()
+	report := lastDiagnostics
+	lastDiagnosticsMu.RUnlock()
+	if report == nil {
+		respondWithError(w, http.StatusNotFound, "Diagnostics have not run yet")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
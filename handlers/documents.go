@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ==================== DOCUMENT MANAGEMENT ====================
+
+// Document types that can be uploaded and required by zones
+const (
+	DocumentTypeLicense        = "LICENSE"
+	DocumentTypeMedical        = "MEDICAL_CERTIFICATE"
+	DocumentTypeBlastingTicket = "BLASTING_TICKET"
+)
+
+var validDocumentTypes = map[string]bool{
+	DocumentTypeLicense:        true,
+	DocumentTypeMedical:        true,
+	DocumentTypeBlastingTicket: true,
+}
+
+// Document status is derived from the expiry date at read time
+const (
+	DocumentStatusValid    = "VALID"
+	DocumentStatusExpiring = "EXPIRING"
+	DocumentStatusExpired  = "EXPIRED"
+)
+
+// documentExpiryWarningDays is how far ahead of expiry holders and supervisors are warned
+const documentExpiryWarningDays = 30
+
+// UserDocument represents an uploaded license or certificate
+type UserDocument struct {
+	ID             int        `json:"id"`
+	UserID         string     `json:"user_id"`
+	UserName       string     `json:"user_name"`
+	DocumentType   string     `json:"document_type"`
+	DocumentNumber string     `json:"document_number"`
+	FileURL        string     `json:"file_url"`
+	IssuedAt       *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	Status         string     `json:"status"`
+	DaysRemaining  int        `json:"days_remaining"`
+	UploadedBy     string     `json:"uploaded_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const userDocumentSelect = `
+	SELECT d.id, d.user_id, u.name, d.document_type, COALESCE(d.document_number, ''),
+	       d.file_url, d.issued_at, d.expires_at, COALESCE(d.uploaded_by, ''), d.created_at
+	FROM user_documents d
+	JOIN users u ON d.user_id = u.user_id
+`
+
+func scanUserDocument(scanner interface{ Scan(...interface{}) error }) (UserDocument, error) {
+	var doc UserDocument
+	var issuedAt sql.NullTime
+	err := scanner.Scan(&doc.ID, &doc.UserID, &doc.UserName, &doc.DocumentType, &doc.DocumentNumber,
+		&doc.FileURL, &issuedAt, &doc.ExpiresAt, &doc.UploadedBy, &doc.CreatedAt)
+	if err != nil {
+		return doc, err
+	}
+	if issuedAt.Valid {
+		doc.IssuedAt = &issuedAt.Time
+	}
+	doc.FileURL = signUploadURL(doc.FileURL)
+
+	doc.DaysRemaining = int(time.Until(doc.ExpiresAt).Hours() / 24)
+	switch {
+	case !doc.ExpiresAt.After(time.Now()):
+		doc.Status = DocumentStatusExpired
+	case doc.DaysRemaining <= documentExpiryWarningDays:
+		doc.Status = DocumentStatusExpiring
+	default:
+		doc.Status = DocumentStatusValid
+	}
+	return doc, nil
+}
+
+// UploadDocument - Upload a license, medical certificate or blasting ticket (multipart/form-data)
+// POST /api/documents
+// Fields: file, document_type, expires_at (YYYY-MM-DD), issued_at, document_number,
+// user_id (admins for anyone, supervisors for their own miners; defaults to the caller)
+func UploadDocument(w http.ResponseWriter, r *http.Request) {
+	callerID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+
+	// Parse multipart form (max 10MB)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	docType := strings.ToUpper(r.FormValue("document_type"))
+	if !validDocumentTypes[docType] {
+		respondWithError(w, http.StatusBadRequest, "document_type must be LICENSE, MEDICAL_CERTIFICATE or BLASTING_TICKET")
+		return
+	}
+
+	expiresAt, err := time.Parse("2006-01-02", r.FormValue("expires_at"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "expires_at is required (YYYY-MM-DD)")
+		return
+	}
+
+	var issuedAt interface{}
+	if v := r.FormValue("issued_at"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "issued_at must be YYYY-MM-DD")
+			return
+		}
+		issuedAt = t
+	}
+
+	ownerID := callerID
+	if v := r.FormValue("user_id"); v != "" && v != callerID {
+		switch role {
+		case string(models.RoleAdmin):
+			ownerID = v
+		case string(models.RoleSupervisor):
+			var supervisorID sql.NullString
+			database.DB.QueryRow("SELECT supervisor_id FROM users WHERE user_id = $1", v).Scan(&supervisorID)
+			if !supervisorID.Valid || supervisorID.String != callerID {
+				respondWithError(w, http.StatusForbidden, "You can only upload documents for miners under your supervision")
+				return
+			}
+			ownerID = v
+		default:
+			respondWithError(w, http.StatusForbidden, "You can only upload your own documents")
+			return
+		}
+	}
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Document file is required")
+		return
+	}
+	defer file.Close()
+
+	// Validate file type
+	ext := strings.ToLower(filepath.Ext(handler.Filename))
+	allowedExts := map[string]bool{".pdf": true, ".jpg": true, ".jpeg": true, ".png": true}
+	if !allowedExts[ext] {
+		respondWithError(w, http.StatusBadRequest, "Only PDF, JPG and PNG files are allowed")
+		return
+	}
+	if !screenUpload(w, uploadPolicyForUser(callerID), file) {
+		return
+	}
+
+	uploadsDir := uploadDir("documents")
+	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create upload directory")
+		return
+	}
+
+	fileName := uuid.New().String() + ext
+	destFile, err := os.Create(filepath.Join(uploadsDir, fileName))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save document")
+		return
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, file); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save document")
+		return
+	}
+
+	fileURL := "/uploads/documents/" + fileName
+
+	var docID int
+	err = database.DB.QueryRow(`
+		INSERT INTO user_documents (user_id, document_type, document_number, file_url, issued_at, expires_at, uploaded_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id
+	`, ownerID, docType, r.FormValue("document_number"), fileURL, issuedAt, expiresAt, callerID).Scan(&docID)
+	if err != nil {
+		os.Remove(filepath.Join(uploadsDir, fileName))
+		respondWithError(w, http.StatusInternalServerError, "Error saving document: "+err.Error())
+		return
+	}
+
+	doc, err := scanUserDocument(database.DB.QueryRow(userDocumentSelect+" WHERE d.id = $1", docID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching document")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, doc)
+}
+
+// GetMyDocuments - Get the current user's documents
+// GET /api/documents/me
+func GetMyDocuments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	listUserDocuments(w, userDocumentSelect+" WHERE d.user_id = $1 ORDER BY d.expires_at ASC", userID)
+}
+
+// DeleteDocument - Delete a document (owner or admin)
+// DELETE /api/documents/{id}
+func DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+
+	vars := mux.Vars(r)
+	docID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid document ID")
+		return
+	}
+
+	// Admins only see documents of their own organization's users
+	args := []interface{}{docID}
+	scope := tenantClause(r, "u.org_id", &args)
+	var ownerID, fileURL string
+	err = database.DB.QueryRow(
+		"SELECT d.user_id, d.file_url FROM user_documents d JOIN users u ON u.user_id = d.user_id WHERE d.id = $1"+scope,
+		args...,
+	).Scan(&ownerID, &fileURL)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Document not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if ownerID != userID && role != string(models.RoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "You can only delete your own documents")
+		return
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM user_documents WHERE id = $1", docID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting document")
+		return
+	}
+	os.Remove(uploadFilePath(fileURL))
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Document deleted successfully",
+	})
+}
+
+// GetSupervisorDocuments - Documents held by miners under this supervisor
+// GET /api/supervisor/documents?status=EXPIRING&type=MEDICAL_CERTIFICATE
+func GetSupervisorDocuments(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query, args := documentFilterQuery(r, " WHERE u.supervisor_id = $1", []interface{}{supervisorID})
+	listUserDocuments(w, query, args...)
+}
+
+// AdminGetDocuments - Documents across all users
+// GET /api/admin/documents?status=EXPIRED&type=LICENSE&user_id=MIN-xxx
+func AdminGetDocuments(w http.ResponseWriter, r *http.Request) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		where += " AND d.user_id = $1"
+		args = append(args, userID)
+	}
+	where += tenantClause(r, "u.org_id", &args)
+
+	query, args := documentFilterQuery(r, where, args)
+	listUserDocuments(w, query, args...)
+}
+
+// documentFilterQuery appends the shared status/type filters to a document listing query
+func documentFilterQuery(r *http.Request, where string, args []interface{}) (string, []interface{}) {
+	query := userDocumentSelect + where
+	argCount := len(args) + 1
+
+	if docType := r.URL.Query().Get("type"); docType != "" {
+		query += fmt.Sprintf(" AND d.document_type = $%d", argCount)
+		args = append(args, strings.ToUpper(docType))
+		argCount++
+	}
+
+	switch strings.ToUpper(r.URL.Query().Get("status")) {
+	case DocumentStatusExpired:
+		query += " AND d.expires_at <= NOW()"
+	case DocumentStatusExpiring:
+		query += fmt.Sprintf(" AND d.expires_at > NOW() AND d.expires_at <= NOW() + INTERVAL '%d days'", documentExpiryWarningDays)
+	case DocumentStatusValid:
+		query += fmt.Sprintf(" AND d.expires_at > NOW() + INTERVAL '%d days'", documentExpiryWarningDays)
+	}
+
+	query += " ORDER BY d.expires_at ASC"
+	return query, args
+}
+
+func listUserDocuments(w http.ResponseWriter, query string, args ...interface{}) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	documents := []UserDocument{}
+	for rows.Next() {
+		doc, err := scanUserDocument(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		documents = append(documents, doc)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"documents": documents,
+		"count":     len(documents),
+	})
+}
+
+// missingZoneDocuments returns the document types a zone requires that the miner
+// does not hold an unexpired copy of
+func missingZoneDocuments(minerID string, requiredTypes []string) ([]string, error) {
+	missing := []string{}
+	for _, docType := range requiredTypes {
+		var valid bool
+		err := database.DB.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 FROM user_documents
+				WHERE user_id = $1 AND document_type = $2 AND expires_at > NOW()
+			)
+		`, minerID, docType).Scan(&valid)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			missing = append(missing, docType)
+		}
+	}
+	return missing, nil
+}
+
+// parseRequiredDocuments decodes a zone's required_documents column
+func parseRequiredDocuments(raw []byte) []string {
+	var required []string
+	json.Unmarshal(raw, &required)
+	if required == nil {
+		required = []string{}
+	}
+	return required
+}
+
+// StartDocumentExpiryJob periodically notifies document holders and their supervisors
+// about documents that are about to expire or have expired
+func StartDocumentExpiryJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			notifyExpiringDocuments()
+			<-ticker.C
+		}
+	}()
+}
+
+func notifyExpiringDocuments() {
+	// Warn once when a document enters the expiry window
+	rows, err := database.DB.Query(fmt.Sprintf(`
+		UPDATE user_documents d SET expiry_warned_at = NOW()
+		FROM users u
+		WHERE d.user_id = u.user_id AND d.expiry_warned_at IS NULL
+		AND d.expires_at > NOW() AND d.expires_at <= NOW() + INTERVAL '%d days'
+		RETURNING d.id, d.user_id, u.name, COALESCE(u.supervisor_id, ''), d.document_type, d.expires_at
+	`, documentExpiryWarningDays))
+	if err != nil {
+		log.Printf("Warning: document expiry job failed: %v", err)
+		return
+	}
+	sendDocumentNotifications(rows, "DOCUMENT_EXPIRING", "Document expiring soon", "expires on")
+
+	// Notify once more when it actually expires
+	rows, err = database.DB.Query(`
+		UPDATE user_documents d SET expired_notified_at = NOW()
+		FROM users u
+		WHERE d.user_id = u.user_id AND d.expired_notified_at IS NULL AND d.expires_at <= NOW()
+		RETURNING d.id, d.user_id, u.name, COALESCE(u.supervisor_id, ''), d.document_type, d.expires_at
+	`)
+	if err != nil {
+		log.Printf("Warning: document expiry job failed: %v", err)
+		return
+	}
+	sendDocumentNotifications(rows, "DOCUMENT_EXPIRED", "Document expired", "expired on")
+}
+
+func sendDocumentNotifications(rows *sql.Rows, notifType, title, verb string) {
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var docID int
+		var userID, userName, supervisorID, docType string
+		var expiresAt time.Time
+		if err := rows.Scan(&docID, &userID, &userName, &supervisorID, &docType, &expiresAt); err != nil {
+			log.Printf("Warning: error scanning expiring document: %v", err)
+			continue
+		}
+
+		label := strings.ReplaceAll(strings.ToLower(docType), "_", " ")
+		date := expiresAt.Format("2006-01-02")
+		ref := strconv.Itoa(docID)
+		notifyUser(userID, notifType, title, fmt.Sprintf("Your %s %s %s", label, verb, date), "document", ref)
+		notifyUser(supervisorID, notifType, title, fmt.Sprintf("%s's %s %s %s", userName, label, verb, date), "document", ref)
+		count++
+	}
+	if count > 0 {
+		log.Printf("Sent %s notifications for %d documents", notifType, count)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// ==================== EMAIL ====================
+
+// errEmailNotConfigured is returned in production when there is no SMTP server to send with
+var errEmailNotConfigured = errors.New("SMTP_HOST is not configured")
+
+// sendEmail sends a plain-text email through the configured SMTP server. In development
+// without SMTP_HOST the message is logged instead so links can be copied from the console.
+func sendEmail(to, subject, body string) error {
+	return sendEmailFrom("", to, subject, body)
+}
+
+// sendEmailFrom sends an email showing from in the From header instead of SMTP_FROM. The
+// envelope sender is always SMTP_FROM.
+func sendEmailFrom(from, to, subject, body string) error {
+	cfg := config.Get()
+	if from == "" {
+		from = cfg.SMTPFrom
+	}
+	if cfg.SMTPHost == "" {
+		if cfg.IsProduction() {
+			return errEmailNotConfigured
+		}
+		log.Printf("Email to %s not sent (SMTP_HOST not set)\nSubject: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+	msg := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	if err := smtp.SendMail(addr, auth, cfg.SMTPFrom, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("sending email to %s failed: %w", to, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ==================== EMAIL VERIFICATION ====================
+
+// Supervisors who sign up themselves can't log in until they follow the link emailed to
+// them. Accounts created by someone else (invitations, admins, SSO) start out verified.
+
+// verifyResendInterval stops a verification email being sent again in quick succession
+const verifyResendInterval = time.Minute
+
+// issueEmailVerification creates or replaces the user's verification token and emails the
+// link. Replacing the token invalidates any link sent earlier.
+func issueEmailVerification(userID, name, email string) (time.Time, error) {
+	cfg := config.Get()
+	token, err := generateRandomHex(32)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiresAt := time.Now().Add(cfg.EmailVerifyTTL)
+
+	_, err = database.DB.Exec(`
+		INSERT INTO email_verifications (user_id, token_hash, expires_at, sent_count, last_sent_at, created_at)
+		VALUES ($1, $2, $3, 1, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at,
+			sent_count = email_verifications.sent_count + 1, last_sent_at = NOW()
+	`, userID, hashInviteToken(token), expiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	verifyURL := cfg.EmailVerifyURL
+	if verifyURL == "" {
+		verifyURL = "http://localhost:" + cfg.Port + "/verify-email"
+	}
+	link := verifyURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nThanks for signing up to MineSafe. "+
+		"Open the link below to confirm your email address and activate your account:\n\n%s\n\n"+
+		"The link expires on %s. If it has expired, request a new one from the login page.\n",
+		name, link, expiresAt.In(userLocation(userID)).Format("2 Jan 2006 15:04 MST"))
+
+	return expiresAt, sendBrandedEmail(brandingForUser(userID), email, "Confirm your MineSafe email address", body)
+}
+
+// VerifyEmailRequest represents the request body for verifying an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmail - Confirm a sign-up email address from the emailed link; the user is logged in
+// POST /api/auth/verify-email
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	var userID string
+	var expiresAt time.Time
+	err := database.DB.QueryRow(
+		"SELECT user_id, expires_at FROM email_verifications WHERE token_hash = $1",
+		hashInviteToken(req.Token),
+	).Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "This verification link is not valid. Use the most recent email you received.")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if time.Now().After(expiresAt) {
+		respondWithError(w, http.StatusGone, "This verification link has expired. Request a new one from the login page.")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Deleting the token is what claims it, so two requests with the same link can't both log in
+	result, err := tx.Exec("DELETE FROM email_verifications WHERE user_id = $1 AND token_hash = $2", userID, hashInviteToken(req.Token))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusConflict, "This email address has already been verified; log in with your password")
+		return
+	}
+	if _, err := tx.Exec("UPDATE users SET email_verified = true, updated_at = NOW() WHERE user_id = $1", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var user models.User
+	err = database.DB.QueryRow(
+		`SELECT id, user_id, name, email, phone, role, COALESCE(mining_site, ''), COALESCE(location, ''), supervisor_id, created_at, updated_at
+		 FROM users WHERE user_id = $1`,
+		userID,
+	).Scan(&user.ID, &user.UserID, &user.Name, &user.Email, &user.Phone,
+		&user.Role, &user.MiningSite, &user.Location, &user.SupervisorID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	token, err := issueLoginToken(r, user.UserID, string(user.Role))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, AuthResponse{
+		Token:          token,
+		UserID:         user.UserID,
+		Role:           string(user.Role),
+		User:           &user,
+		OrganizationID: user.MiningSite,
+	})
+}
+
+// ResendVerificationRequest represents the request body for resending a verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// ResendEmailVerification - Email a new verification link, invalidating the previous one.
+// The response is the same whether or not the address has an unverified account, so it
+// can't be used to find out who has signed up.
+// POST /api/auth/verify-email/resend
+func ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	var userID, name, email string
+	var lastSentAt sql.NullTime
+	err := database.DB.QueryRow(`
+		SELECT u.user_id, u.name, u.email, v.last_sent_at
+		FROM users u LEFT JOIN email_verifications v ON v.user_id = u.user_id
+		WHERE LOWER(u.email) = $1 AND u.email_verified = false
+	`, models.NormalizeEmail(req.Email)).Scan(&userID, &name, &email, &lastSentAt)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	// Repeated requests within a minute are quietly ignored rather than refused, which would
+	// give the account away
+	if err == nil && (!lastSentAt.Valid || time.Since(lastSentAt.Time) >= verifyResendInterval) {
+		if _, err := issueEmailVerification(userID, name, email); err != nil {
+			log.Printf("Warning: verification email for %s not resent: %v", userID, err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "If that address has an unverified account, a new verification link has been sent",
+	})
+}
@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"MineSafeBackend/config"
 	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
 	"MineSafeBackend/models"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -42,9 +45,15 @@ func CreateEmergency(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	detailsJSON, err := normaliseEmergencyCreate(&emergencyData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Check if emergency already exists (duplicate detection)
 	var existingID int
-	err := database.DB.QueryRow(
+	err = database.DB.QueryRow(
 		"SELECT id FROM emergencies WHERE user_id = $1 AND emergency_id = $2",
 		emergencyData.UserID, emergencyData.EmergencyID,
 	).Scan(&existingID)
@@ -63,6 +72,7 @@ func CreateEmergency(w http.ResponseWriter, r *http.Request) {
 			&emergency.IncidentReportingTime, &emergency.Status, &emergency.ResolutionTime)
 
 		if err == nil {
+			emergency.MediaURL = signUploadURLPtr(emergency.MediaURL)
 			respondWithJSON(w, http.StatusOK, map[string]interface{}{
 				"message":   "Emergency already exists",
 				"emergency": emergency,
@@ -72,11 +82,6 @@ func CreateEmergency(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Set default media status if not provided
-	if emergencyData.MediaStatus == "" {
-		emergencyData.MediaStatus = models.StatusNotApplicable
-	}
-
 	// Reverse geocode location if coordinates are provided
 	var location *string
 	if emergencyData.Latitude != 0 && emergencyData.Longitude != 0 {
@@ -104,15 +109,18 @@ func CreateEmergency(w http.ResponseWriter, r *http.Request) {
 	}
 
 	emergency.Location = location
+	emergency.Category = emergencyData.Category
+	emergency.Details = emergencyData.Details
 
 	// Insert into database
 	err = database.DB.QueryRow(
 		`INSERT INTO emergencies (user_id, emergency_id, severity, latitude, longitude, issue, 
-		                          media_status, location, reporting_time, status)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		                          media_status, location, reporting_time, status, category, details)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
 		 RETURNING id`,
 		emergency.UserID, emergency.EmergencyID, emergency.Severity, emergency.Lat, emergency.Lon,
 		emergency.Issue, emergency.MediaStatus, emergency.Location, emergency.IncidentReportingTime, emergency.Status,
+		emergency.Category, detailsJSON,
 	).Scan(&emergency.ID)
 
 	if err != nil {
@@ -125,12 +133,106 @@ func CreateEmergency(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Place the report in the zone it falls in, then link reports of the same incident
+	// from other miners
+	emergency.ZoneID = resolveEmergencyZone(emergency.ID)
+	emergency.PrimaryEmergencyID = clusterEmergency(emergency.ID)
+	go publishLiveAlert(emergency.UserID, LiveAlertEmergencyCreated, emergencyAlert(emergency))
+
+	// Text supervisors and on-call contacts who may have no data signal
+	go sendEmergencySMS(emergency)
+
+	// Sound site sirens and display boards for critical emergencies
+	if strings.EqualFold(emergency.Severity, "CRITICAL") {
+		go triggerSirens(emergency.ID, SirenReasonCritical)
+	}
+
 	respondWithJSON(w, http.StatusCreated, emergency)
 }
 
+// normaliseEmergencyCreate checks a report's category details and fills in defaults,
+// returning the details as JSON for storage
+func normaliseEmergencyCreate(emergencyData *models.EmergencyCreate) ([]byte, error) {
+	// Quick reports carry typed details for their category instead of only free text
+	var detailsJSON []byte
+	if emergencyData.Category != "" {
+		category, ok := findEmergencyCategory(emergencyData.Category)
+		if !ok {
+			return nil, fmt.Errorf("Unknown emergency category")
+		}
+		if err := validateEmergencyDetails(category, emergencyData.Details); err != nil {
+			return nil, err
+		}
+		if emergencyData.Severity == "" {
+			emergencyData.Severity = category.DefaultSeverity
+		}
+		if emergencyData.Issue == "" {
+			emergencyData.Issue = category.Name
+		}
+		if len(emergencyData.Details) > 0 {
+			detailsJSON, _ = json.Marshal(emergencyData.Details)
+		}
+	} else if len(emergencyData.Details) > 0 {
+		return nil, fmt.Errorf("details require a category")
+	}
+
+	// Normalise rather than reject: a report must never be lost over a bad enum value.
+	// Unknown severities count as HIGH so nothing gets downplayed.
+	emergencyData.Severity = strings.ToUpper(strings.TrimSpace(emergencyData.Severity))
+	if _, ok := severityRank[emergencyData.Severity]; !ok {
+		emergencyData.Severity = "HIGH"
+	}
+	switch emergencyData.MediaStatus {
+	case models.StatusSynced, models.StatusPendingUpload, models.StatusNotApplicable, models.StatusUploadFailed, models.StatusUploadExpired:
+	default:
+		// Also sets the default when no media status was provided
+		emergencyData.MediaStatus = models.StatusNotApplicable
+	}
+	return detailsJSON, nil
+}
+
+// raiseEmergencyFor files an emergency on a miner's behalf when the server detects one
+// itself, e.g. a missed lone-worker check-in. The app numbers its own reports from 1, so
+// these take the next free negative emergency_id.
+func raiseEmergencyFor(userID, severity, issue string, lat, lon float64) (int, error) {
+	var location *string
+	if lat != 0 && lon != 0 {
+		if name, err := reverseGeocode(lat, lon); err == nil {
+			location = &name
+		}
+	}
+
+	emergency, err := models.NewEmergency(userID, 0, severity, lat, lon, issue, models.StatusNotApplicable, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	emergency.Location = location
+	err = database.DB.QueryRow(
+		`INSERT INTO emergencies (user_id, emergency_id, severity, latitude, longitude, issue,
+		                          media_status, location, reporting_time, status)
+		 VALUES ($1, (SELECT LEAST(COALESCE(MIN(emergency_id), 0), 0) - 1 FROM emergencies WHERE user_id = $1),
+		         $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id`,
+		emergency.UserID, emergency.Severity, emergency.Lat, emergency.Lon,
+		emergency.Issue, emergency.MediaStatus, emergency.Location, emergency.IncidentReportingTime, emergency.Status,
+	).Scan(&emergency.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	emergency.ZoneID = resolveEmergencyZone(emergency.ID)
+	emergency.PrimaryEmergencyID = clusterEmergency(emergency.ID)
+	go publishLiveAlert(emergency.UserID, LiveAlertEmergencyCreated, emergencyAlert(emergency))
+	go sendEmergencySMS(emergency)
+	if strings.EqualFold(severity, "CRITICAL") {
+		go triggerSirens(emergency.ID, SirenReasonCritical)
+	}
+	return emergency.ID, nil
+}
+
 // reverseGeocode - Get location name from coordinates using LocationIQ
 func reverseGeocode(lat, lon float64) (string, error) {
-	apiKey := os.Getenv("LOCATIONIQ_API_KEY")
+	apiKey := config.Get().LocationIQAPIKey
 	if apiKey == "" {
 		return fmt.Sprintf("%.6f, %.6f", lat, lon), nil // Return coordinates if no API key
 	}
@@ -192,7 +294,8 @@ func reverseGeocode(lat, lon float64) (string, error) {
 	return locationResp.DisplayName, nil
 }
 
-// UpdateEmergencyMedia - Update emergency with media URL after upload
+// UpdateEmergencyMedia - Update emergency with media URL after upload. For files stored by
+// the server, POST them to /api/emergencies/{id}/media instead.
 func UpdateEmergencyMedia(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	emergencyID := vars["id"]
@@ -207,10 +310,13 @@ func UpdateEmergencyMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	args := []interface{}{updateData.MediaURL, updateData.MediaStatus, emergencyID}
+	scope := tenantClause(r, "org_id", &args)
 	result, err := database.DB.Exec(
-		`UPDATE emergencies SET media_url = $1, media_status = $2
-		 WHERE id = $3`,
-		updateData.MediaURL, updateData.MediaStatus, emergencyID,
+		`UPDATE emergencies SET media_url = $1, media_status = $2,
+		        media_status_updated_at = NOW(), media_reminders = 0, media_reminded_at = NULL
+		 WHERE id = $3`+scope,
+		args...,
 	)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating emergency")
@@ -226,22 +332,28 @@ func UpdateEmergencyMedia(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Media updated successfully"})
 }
 
-// GetEmergencies - Get all emergencies (supervisor view)
+// GetEmergencies - Get all emergencies (supervisor view). ?zone_id= filters by the zone the
+// report was placed in, or none for reports outside every zone.
 func GetEmergencies(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters for filtering
 	status := r.URL.Query().Get("status")
 	userID := r.URL.Query().Get("user_id")
+	primaryOnly := r.URL.Query().Get("primary_only") == "true"
 
 	query := `
 		SELECT e.id, e.user_id, e.emergency_id, e.severity, e.latitude, e.longitude, e.issue,
 		       e.media_status, e.media_url, e.location, e.incident_time, e.reporting_time, 
-		       e.status, e.resolution_time, u.name as user_name
+		       e.status, e.resolution_time, u.name as user_name, e.primary_emergency_id,
+		       (SELECT COUNT(*) FROM emergencies l WHERE l.primary_emergency_id = e.id) as linked_reports,
+		       COALESCE(e.category, ''), e.details, e.zone_id, z.name
 		FROM emergencies e
 		JOIN users u ON e.user_id = u.user_id
+		LEFT JOIN mine_zones z ON z.id = e.zone_id
 		WHERE 1=1
 	`
 	args := []interface{}{}
-	argCount := 1
+	query += tenantClause(r, "e.org_id", &args)
+	argCount := len(args) + 1
 
 	if status != "" {
 		query += fmt.Sprintf(" AND e.status = $%d", argCount)
@@ -255,6 +367,35 @@ func GetEmergencies(w http.ResponseWriter, r *http.Request) {
 		argCount++
 	}
 
+	if category := r.URL.Query().Get("category"); category != "" {
+		query += fmt.Sprintf(" AND e.category = $%d", argCount)
+		args = append(args, category)
+		argCount++
+	}
+
+	// zone_id=none lists reports outside every zone or without coordinates
+	if zone := r.URL.Query().Get("zone_id"); zone == "none" {
+		query += " AND e.zone_id IS NULL"
+	} else if zone != "" {
+		zoneID, err := strconv.Atoi(zone)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "zone_id must be a zone ID or none")
+			return
+		}
+		query += fmt.Sprintf(" AND e.zone_id = $%d", argCount)
+		args = append(args, zoneID)
+		argCount++
+	}
+
+	if r.URL.Query().Get("stale") == "true" {
+		query += " AND e.stale_flagged_at IS NOT NULL AND e.status IN ('PENDING', 'RESOLVING')"
+	}
+
+	// Hide reports clustered under another report of the same incident
+	if primaryOnly {
+		query += " AND e.primary_emergency_id IS NULL"
+	}
+
 	query += " ORDER BY e.reporting_time DESC LIMIT 100"
 
 	rows, err := database.DB.Query(query, args...)
@@ -268,11 +409,17 @@ func GetEmergencies(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var emergency models.Emergency
 		var userName string
+		var primaryID sql.NullInt64
+		var linkedReports int
+		var detailsJSON []byte
+		var zoneID sql.NullInt64
+		var zoneName sql.NullString
 		err := rows.Scan(&emergency.ID, &emergency.UserID, &emergency.EmergencyID,
 			&emergency.Severity, &emergency.Lat, &emergency.Lon, &emergency.Issue,
 			&emergency.MediaStatus, &emergency.MediaURL, &emergency.Location,
 			&emergency.IncidentTime, &emergency.IncidentReportingTime,
-			&emergency.Status, &emergency.ResolutionTime, &userName)
+			&emergency.Status, &emergency.ResolutionTime, &userName, &primaryID, &linkedReports,
+			&emergency.Category, &detailsJSON, &zoneID, &zoneName)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning emergency data")
 			return
@@ -288,12 +435,25 @@ func GetEmergencies(w http.ResponseWriter, r *http.Request) {
 			"longitude":     emergency.Lon,
 			"issue":         emergency.Issue,
 			"media_status":  emergency.MediaStatus,
-			"media_url":     emergency.MediaURL,
+			"media_url":     signUploadURLPtr(emergency.MediaURL),
 			"location":      emergency.Location,
 			"incident_time": emergency.IncidentTime,
 			"reporting_time": emergency.IncidentReportingTime,
 			"status":        emergency.Status,
 			"resolution_time": emergency.ResolutionTime,
+			"linked_reports": linkedReports,
+		}
+		if primaryID.Valid {
+			emergencyMap["primary_emergency_id"] = primaryID.Int64
+		}
+		if zoneID.Valid {
+			emergencyMap["zone_id"] = zoneID.Int64
+			emergencyMap["zone_name"] = zoneName.String
+		}
+		if emergency.Category != "" {
+			emergencyMap["category"] = emergency.Category
+			json.Unmarshal(detailsJSON, &emergency.Details)
+			emergencyMap["details"] = emergency.Details
 		}
 		emergencies = append(emergencies, emergencyMap)
 	}
@@ -301,6 +461,121 @@ func GetEmergencies(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, emergencies)
 }
 
+// EmergencyStatsGroup is the emergency count for one severity, zone or month
+type EmergencyStatsGroup struct {
+	Group                string   `json:"group"`
+	Total                int      `json:"total"`
+	Pending              int      `json:"pending"`
+	Resolving            int      `json:"resolving"`
+	Resolved             int      `json:"resolved"`
+	Cancelled            int      `json:"cancelled"`
+	AvgResolutionMinutes *float64 `json:"avg_resolution_minutes"`
+}
+
+// emergencyStatsGroupings maps the group_by parameter to its SQL expression
+var emergencyStatsGroupings = map[string]string{
+	"severity": "COALESCE(e.severity, 'UNKNOWN')",
+	"zone":     "COALESCE(z.name, 'Unassigned')",
+	"month":    "TO_CHAR(DATE_TRUNC('month', e.reporting_time), 'YYYY-MM')",
+	"category": "COALESCE(e.category, 'uncategorized')",
+}
+
+// GetEmergencyStats - Emergency counts grouped for dashboards
+// GET /api/emergencies/stats?group_by=severity|zone|month|category&from=2024-01-01&to=2024-12-31&status=PENDING&user_id=
+func GetEmergencyStats(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "severity"
+	}
+	groupExpr, ok := emergencyStatsGroupings[groupBy]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "group_by must be severity, zone, month or category")
+		return
+	}
+
+	args := []interface{}{}
+	where := " WHERE 1=1" + tenantClause(r, "e.org_id", &args)
+	argCount := len(args) + 1
+
+	if from := r.URL.Query().Get("from"); from != "" {
+		fromDate, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
+			return
+		}
+		where += fmt.Sprintf(" AND e.reporting_time >= $%d", argCount)
+		args = append(args, fromDate)
+		argCount++
+	}
+
+	if to := r.URL.Query().Get("to"); to != "" {
+		toDate, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
+			return
+		}
+		where += fmt.Sprintf(" AND e.reporting_time < $%d", argCount)
+		args = append(args, toDate.AddDate(0, 0, 1))
+		argCount++
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		where += fmt.Sprintf(" AND e.status = $%d", argCount)
+		args = append(args, status)
+		argCount++
+	}
+
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		where += fmt.Sprintf(" AND e.user_id = $%d", argCount)
+		args = append(args, userID)
+		argCount++
+	}
+
+	query := `
+		SELECT ` + groupExpr + ` AS grp,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE e.status = 'PENDING'),
+			COUNT(*) FILTER (WHERE e.status = 'RESOLVING'),
+			COUNT(*) FILTER (WHERE e.status = 'RESOLVED'),
+			COUNT(*) FILTER (WHERE e.status = 'CANCELLED'),
+			AVG(EXTRACT(EPOCH FROM (e.resolution_time - e.reporting_time)) / 60)
+				FILTER (WHERE e.status = 'RESOLVED' AND e.resolution_time IS NOT NULL)
+		FROM emergencies e
+		JOIN users u ON e.user_id = u.user_id
+		LEFT JOIN mine_zones z ON z.id = COALESCE(e.zone_id, u.zone_id)` + where + `
+		GROUP BY grp
+		ORDER BY grp`
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	groups := []EmergencyStatsGroup{}
+	total := 0
+	for rows.Next() {
+		var g EmergencyStatsGroup
+		var avgMinutes sql.NullFloat64
+		if err := rows.Scan(&g.Group, &g.Total, &g.Pending, &g.Resolving, &g.Resolved, &g.Cancelled, &avgMinutes); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning emergency stats")
+			return
+		}
+		if avgMinutes.Valid {
+			g.AvgResolutionMinutes = &avgMinutes.Float64
+		}
+		total += g.Total
+		groups = append(groups, g)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"group_by": groupBy,
+		"total":    total,
+		"groups":   groups,
+	})
+}
+
 // GetEmergency - Get a single emergency
 func GetEmergency(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -308,19 +583,27 @@ func GetEmergency(w http.ResponseWriter, r *http.Request) {
 
 	var emergency models.Emergency
 	var userName string
+	var detailsJSON []byte
+	var zoneID sql.NullInt64
+	var zoneName sql.NullString
+	args := []interface{}{emergencyID}
+	scope := tenantClause(r, "e.org_id", &args)
 	err := database.DB.QueryRow(
 		`SELECT e.id, e.user_id, e.emergency_id, e.severity, e.latitude, e.longitude, e.issue,
 		        e.media_status, e.media_url, e.location, e.incident_time, e.reporting_time, 
-		        e.status, e.resolution_time, u.name as user_name
+		        e.status, e.resolution_time, u.name as user_name, COALESCE(e.category, ''), e.details,
+		        e.zone_id, z.name
 		 FROM emergencies e
 		 JOIN users u ON e.user_id = u.user_id
-		 WHERE e.id = $1`,
-		emergencyID,
+		 LEFT JOIN mine_zones z ON z.id = e.zone_id
+		 WHERE e.id = $1`+scope,
+		args...,
 	).Scan(&emergency.ID, &emergency.UserID, &emergency.EmergencyID,
 		&emergency.Severity, &emergency.Lat, &emergency.Lon, &emergency.Issue,
 		&emergency.MediaStatus, &emergency.MediaURL, &emergency.Location,
 		&emergency.IncidentTime, &emergency.IncidentReportingTime,
-		&emergency.Status, &emergency.ResolutionTime, &userName)
+		&emergency.Status, &emergency.ResolutionTime, &userName, &emergency.Category, &detailsJSON,
+		&zoneID, &zoneName)
 
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Emergency not found")
@@ -341,13 +624,34 @@ func GetEmergency(w http.ResponseWriter, r *http.Request) {
 		"longitude":     emergency.Lon,
 		"issue":         emergency.Issue,
 		"media_status":  emergency.MediaStatus,
-		"media_url":     emergency.MediaURL,
+		"media_url":     signUploadURLPtr(emergency.MediaURL),
 		"location":      emergency.Location,
 		"incident_time": emergency.IncidentTime,
 		"reporting_time": emergency.IncidentReportingTime,
 		"status":        emergency.Status,
 		"resolution_time": emergency.ResolutionTime,
 	}
+	if emergency.Category != "" {
+		emergencyMap["category"] = emergency.Category
+		json.Unmarshal(detailsJSON, &emergency.Details)
+		emergencyMap["details"] = emergency.Details
+	}
+	if zoneID.Valid {
+		emergencyMap["zone_id"] = zoneID.Int64
+		emergencyMap["zone_name"] = zoneName.String
+	}
+	attachments, err := loadEmergencyAttachments(emergency.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	emergencyMap["attachments"] = attachments
+
+	// Opening the report starts the supervisor's acknowledgment clock
+	if role, _ := middleware.GetUserRoleFromContext(r.Context()); role == string(models.RoleSupervisor) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		recordEmergencyView(emergency.ID, userID)
+	}
 
 	respondWithJSON(w, http.StatusOK, emergencyMap)
 }
@@ -358,7 +662,8 @@ func UpdateEmergencyStatus(w http.ResponseWriter, r *http.Request) {
 	emergencyID := vars["id"]
 
 	var updateData struct {
-		Status models.ResolutionStatus `json:"status"`
+		Status        models.ResolutionStatus `json:"status"`
+		ClosureReason string                  `json:"closure_reason"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
@@ -366,30 +671,60 @@ func UpdateEmergencyStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch updateData.Status {
+	case models.ResolutionPending, models.ResolutionActive, models.ResolutionComplete:
+	case models.ResolutionCancelled:
+		// Cancelled reports must say why, so stale reports aren't just swept away
+		if strings.TrimSpace(updateData.ClosureReason) == "" {
+			respondWithError(w, http.StatusBadRequest, "closure_reason is required when cancelling an emergency")
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
 	var resolutionTime *time.Time
 	if updateData.Status == models.ResolutionComplete {
 		now := time.Now()
 		resolutionTime = &now
 	}
 
-	result, err := database.DB.Exec(
-		`UPDATE emergencies SET status = $1, resolution_time = $2
-		 WHERE id = $3`,
-		updateData.Status, resolutionTime, emergencyID,
-	)
+	closedBy, _ := middleware.GetUserIDFromContext(r.Context())
+
+	// A status change restarts the stale policy clock
+	args := []interface{}{updateData.Status, resolutionTime, emergencyID, strings.TrimSpace(updateData.ClosureReason), closedBy}
+	scope := tenantClause(r, "org_id", &args)
+	var id int
+	var reporterID string
+	err := database.DB.QueryRow(
+		`UPDATE emergencies SET status = $1, resolution_time = $2,
+		        closure_reason = NULLIF($4, ''), closed_by = NULLIF($5, ''),
+		        status_updated_at = NOW(), stale_flagged_at = NULL, escalated_at = NULL
+		 WHERE id = $3`+scope+`
+		 RETURNING id, user_id`,
+		args...,
+	).Scan(&id, &reporterID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Emergency not found")
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating emergency status")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		respondWithError(w, http.StatusNotFound, "Emergency not found")
-		return
-	}
+	go publishLiveAlert(reporterID, LiveAlertEmergencyStatus, map[string]interface{}{
+		"id":             id,
+		"user_id":        reporterID,
+		"status":         updateData.Status,
+		"closure_reason": strings.TrimSpace(updateData.ClosureReason),
+		"updated_by":     closedBy,
+	})
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"message": "Emergency status updated successfully",
-		"status":  updateData.Status,
+		"message":        "Emergency status updated successfully",
+		"status":         updateData.Status,
+		"closure_reason": updateData.ClosureReason,
 	})
 }
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ==================== EMERGENCY CATEGORIES ====================
+
+// Field types for structured emergency details
+const (
+	FieldTypeText    = "text"
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeSelect  = "select"
+)
+
+// EmergencyCategoryField is one structured field the app shows for a category
+type EmergencyCategoryField struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+	// Medical fields are left out of reports shared with medical details redacted
+	Medical bool `json:"medical,omitempty"`
+}
+
+// EmergencyCategory is a quick-report template
+type EmergencyCategory struct {
+	Key             string                   `json:"key"`
+	Name            string                   `json:"name"`
+	DefaultSeverity string                   `json:"default_severity"`
+	Fields          []EmergencyCategoryField `json:"fields"`
+}
+
+// emergencyCategories are the predefined quick-report categories, in display order
+var emergencyCategories = []EmergencyCategory{
+	{
+		Key: "roof_fall", Name: "Roof fall", DefaultSeverity: "CRITICAL",
+		Fields: []EmergencyCategoryField{
+			{Key: "area", Label: "Area / gallery", Type: FieldTypeText, Required: true},
+			{Key: "persons_trapped", Label: "Persons trapped", Type: FieldTypeNumber},
+			{Key: "injuries", Label: "Injuries", Type: FieldTypeBoolean, Medical: true},
+			{Key: "support_type", Label: "Roof support", Type: FieldTypeSelect,
+				Options: []string{"roof_bolt", "timber", "steel_arch", "none", "unknown"}},
+		},
+	},
+	{
+		Key: "gas_alarm", Name: "Gas alarm", DefaultSeverity: "HIGH",
+		Fields: []EmergencyCategoryField{
+			{Key: "gas", Label: "Gas", Type: FieldTypeSelect, Required: true,
+				Options: []string{"methane", "carbon_monoxide", "hydrogen_sulphide", "oxygen_deficiency", "other"}},
+			{Key: "reading", Label: "Reading", Type: FieldTypeNumber},
+			{Key: "unit", Label: "Unit", Type: FieldTypeSelect, Options: []string{"percent", "ppm"}},
+			{Key: "detector_id", Label: "Detector ID", Type: FieldTypeText},
+		},
+	},
+	{
+		Key: "vehicle_collision", Name: "Vehicle collision", DefaultSeverity: "HIGH",
+		Fields: []EmergencyCategoryField{
+			{Key: "vehicles_involved", Label: "Vehicles involved", Type: FieldTypeNumber, Required: true},
+			{Key: "vehicle_ids", Label: "Vehicle numbers", Type: FieldTypeText},
+			{Key: "injuries", Label: "Injuries", Type: FieldTypeBoolean, Medical: true},
+			{Key: "route_blocked", Label: "Haul route blocked", Type: FieldTypeBoolean},
+		},
+	},
+	{
+		Key: "electrical", Name: "Electrical", DefaultSeverity: "HIGH",
+		Fields: []EmergencyCategoryField{
+			{Key: "equipment", Label: "Equipment", Type: FieldTypeText, Required: true},
+			{Key: "shock_injury", Label: "Electric shock injury", Type: FieldTypeBoolean, Medical: true},
+			{Key: "fire", Label: "Fire or smoke", Type: FieldTypeBoolean},
+			{Key: "isolated", Label: "Supply isolated", Type: FieldTypeBoolean},
+		},
+	},
+	{
+		Key: "other", Name: "Other", DefaultSeverity: "MEDIUM",
+		Fields: []EmergencyCategoryField{},
+	},
+}
+
+func findEmergencyCategory(key string) (EmergencyCategory, bool) {
+	for _, c := range emergencyCategories {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return EmergencyCategory{}, false
+}
+
+// validateEmergencyDetails checks the structured details against the category's fields.
+// Unknown keys are rejected so analytics only ever see defined fields.
+func validateEmergencyDetails(category EmergencyCategory, details map[string]interface{}) error {
+	fields := map[string]EmergencyCategoryField{}
+	for _, f := range category.Fields {
+		fields[f.Key] = f
+		if _, ok := details[f.Key]; f.Required && !ok {
+			return fmt.Errorf("%s is required for %s", f.Key, category.Name)
+		}
+	}
+
+	for key, value := range details {
+		f, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown field %s for %s", key, category.Name)
+		}
+		valid := false
+		switch f.Type {
+		case FieldTypeText:
+			_, valid = value.(string)
+		case FieldTypeNumber:
+			_, valid = value.(float64)
+		case FieldTypeBoolean:
+			_, valid = value.(bool)
+		case FieldTypeSelect:
+			s, ok := value.(string)
+			valid = ok && containsString(f.Options, s)
+		}
+		if !valid {
+			return fmt.Errorf("invalid value for %s", key)
+		}
+	}
+	return nil
+}
+
+// GetEmergencyCategories - Quick-report categories and their structured fields
+// GET /api/emergencies/categories
+func GetEmergencyCategories(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, emergencyCategories)
+}
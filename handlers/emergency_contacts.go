@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== EMERGENCY CONTACTS ====================
+
+// The SOS screen dials whoever is first on the miner's call list: their supervisor, then the
+// site's medics and control room from the contact directory. Contacts tied to a shift window
+// only count as available during that shift, and a supervisor on leave is passed over.
+
+// Emergency contact types, in the order they are called
+const (
+	ContactTypeSupervisor  = "SUPERVISOR"
+	ContactTypeMedic       = "MEDIC"
+	ContactTypeControlRoom = "CONTROL_ROOM"
+	ContactTypeOther       = "OTHER"
+)
+
+var contactTypeOrder = map[string]int{
+	ContactTypeSupervisor:  0,
+	ContactTypeMedic:       1,
+	ContactTypeControlRoom: 2,
+	ContactTypeOther:       3,
+}
+
+// DirectoryContact is an entry in a site's emergency contact directory
+type DirectoryContact struct {
+	ID            int       `json:"id"`
+	MiningSite    string    `json:"mining_site"`
+	ContactType   string    `json:"contact_type"`
+	Name          string    `json:"name"`
+	Phone         string    `json:"phone"`
+	Priority      int       `json:"priority"`
+	ShiftWindowID *int      `json:"shift_window_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// DirectoryContactRequest represents the request body for adding a directory contact
+type DirectoryContactRequest struct {
+	MiningSite    string `json:"mining_site"`
+	ContactType   string `json:"contact_type"`
+	Name          string `json:"name"`
+	Phone         string `json:"phone"`
+	Priority      int    `json:"priority"`        // lower is called first within a type
+	ShiftWindowID *int   `json:"shift_window_id"` // only on call during this shift; omit for 24/7
+}
+
+// CreateEmergencyContact - Add a medic, control room or other number to a site's directory
+// POST /api/admin/emergency-contacts
+func CreateEmergencyContact(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req DirectoryContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.ContactType = strings.ToUpper(strings.TrimSpace(req.ContactType))
+	if _, known := contactTypeOrder[req.ContactType]; !known || req.ContactType == ContactTypeSupervisor {
+		respondWithError(w, http.StatusBadRequest, "contact_type must be MEDIC, CONTROL_ROOM or OTHER")
+		return
+	}
+	req.Name, req.Phone = strings.TrimSpace(req.Name), strings.TrimSpace(req.Phone)
+	if req.MiningSite == "" || req.Name == "" || req.Phone == "" {
+		respondWithError(w, http.StatusBadRequest, "mining_site, name and phone are required")
+		return
+	}
+	if inOrg, err := siteInOrganization(r, req.MiningSite); err != nil || !inOrg {
+		respondWithError(w, http.StatusBadRequest, "Unknown mining site")
+		return
+	}
+	if req.ShiftWindowID != nil {
+		var sameSite bool
+		database.DB.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM shift_windows WHERE id = $1 AND LOWER(mining_site) = LOWER(TRIM($2)))",
+			*req.ShiftWindowID, req.MiningSite,
+		).Scan(&sameSite)
+		if !sameSite {
+			respondWithError(w, http.StatusBadRequest, "shift_window_id must be a shift window of the same site")
+			return
+		}
+	}
+
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO emergency_contacts (mining_site, contact_type, name, phone, priority, shift_window_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, req.MiningSite, req.ContactType, truncate(req.Name, 255), truncate(req.Phone, 50), req.Priority,
+		req.ShiftWindowID, adminID).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating contact: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Emergency contact added",
+	})
+}
+
+// GetEmergencyContactDirectory - List the contact directory, optionally for one site
+// GET /api/admin/emergency-contacts?mining_site=North%20Pit
+func GetEmergencyContactDirectory(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{}
+	where := " WHERE is_active = true" + tenantClause(r, "org_id", &args)
+	if site := r.URL.Query().Get("mining_site"); site != "" {
+		args = append(args, site)
+		where += " AND LOWER(mining_site) = LOWER(TRIM($" + strconv.Itoa(len(args)) + "))"
+	}
+	contacts, err := loadDirectoryContacts(where, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"contacts": contacts,
+	})
+}
+
+// DeleteEmergencyContact - Remove a contact from the directory
+// DELETE /api/admin/emergency-contacts/{id}
+func DeleteEmergencyContact(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{mux.Vars(r)["id"]}
+	scope := tenantClause(r, "org_id", &args)
+	result, err := database.DB.Exec("UPDATE emergency_contacts SET is_active = false WHERE id = $1 AND is_active = true"+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error removing contact")
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Emergency contact not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Emergency contact removed",
+	})
+}
+
+func loadDirectoryContacts(where string, args ...interface{}) ([]DirectoryContact, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, mining_site, contact_type, name, phone, priority, shift_window_id, created_at
+		FROM emergency_contacts`+where+`
+		ORDER BY mining_site, contact_type, priority, name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []DirectoryContact{}
+	for rows.Next() {
+		var c DirectoryContact
+		var windowID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.MiningSite, &c.ContactType, &c.Name, &c.Phone, &c.Priority,
+			&windowID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if windowID.Valid {
+			id := int(windowID.Int64)
+			c.ShiftWindowID = &id
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+// CallListEntry is one number on a miner's emergency call list
+type CallListEntry struct {
+	Rank         int    `json:"rank"`
+	ContactType  string `json:"contact_type"`
+	Name         string `json:"name"`
+	Phone        string `json:"phone"`
+	AvailableNow bool   `json:"available_now"`
+	// Always is true for contacts on call around the clock; otherwise Availability lists
+	// the shifts they cover
+	Always            bool          `json:"always"`
+	Availability      []ShiftWindow `json:"availability,omitempty"`
+	UnavailableReason string        `json:"unavailable_reason,omitempty"`
+	priority          int
+}
+
+// GetMyEmergencyContacts - The prioritized call list for the SOS screen. Contacts available
+// right now come first, in the order supervisor, medic, control room; dial is the number to
+// call with one tap.
+// GET /api/app/emergency-contacts
+func GetMyEmergencyContacts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var miningSite, supervisorID, supervisorName, supervisorPhone string
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(u.mining_site, ''), COALESCE(s.user_id, ''), COALESCE(s.name, ''), COALESCE(s.phone, '')
+		FROM users u
+		LEFT JOIN users s ON s.user_id = u.supervisor_id AND COALESCE(s.is_active, true)
+		WHERE u.user_id = $1
+	`, userID).Scan(&miningSite, &supervisorID, &supervisorName, &supervisorPhone)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The site's shift roster; with none configured, everyone counts as on call
+	windows := []ShiftWindow{}
+	if miningSite != "" {
+		if windows, err = loadShiftWindows(miningSite); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+	windowByID := map[int]ShiftWindow{}
+	for _, sw := range windows {
+		windowByID[sw.ID] = sw
+	}
+	now := time.Now()
+	onShift := func(list []ShiftWindow) bool {
+		for _, sw := range list {
+			if sw.contains(now) {
+				return true
+			}
+		}
+		return len(list) == 0
+	}
+
+	calls := []CallListEntry{}
+	if supervisorID != "" && supervisorPhone != "" {
+		entry := CallListEntry{ContactType: ContactTypeSupervisor, Name: supervisorName, Phone: supervisorPhone,
+			Always: len(windows) == 0, Availability: windows}
+		entry.AvailableNow = onShift(windows)
+		if !entry.AvailableNow {
+			entry.UnavailableReason = "Off shift"
+		}
+		if until, err := minerLeaveOn(supervisorID, userToday(supervisorID)); err == nil && until != "" {
+			entry.AvailableNow = false
+			entry.UnavailableReason = "On leave until " + until
+		}
+		calls = append(calls, entry)
+	}
+
+	if miningSite != "" {
+		contacts, err := loadDirectoryContacts(
+			" WHERE is_active = true AND LOWER(mining_site) = LOWER(TRIM($1))", miningSite)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		for _, c := range contacts {
+			entry := CallListEntry{ContactType: c.ContactType, Name: c.Name, Phone: c.Phone, priority: c.Priority,
+				Always: true, AvailableNow: true}
+			if c.ShiftWindowID != nil {
+				// A contact whose shift was deleted has no hours left to be on call in
+				sw, exists := windowByID[*c.ShiftWindowID]
+				entry.Always = false
+				entry.AvailableNow = exists && sw.contains(now)
+				if exists {
+					entry.Availability = []ShiftWindow{sw}
+				}
+				if !entry.AvailableNow {
+					entry.UnavailableReason = "Off shift"
+				}
+			}
+			calls = append(calls, entry)
+		}
+	}
+
+	sort.SliceStable(calls, func(i, j int) bool {
+		a, b := calls[i], calls[j]
+		if a.AvailableNow != b.AvailableNow {
+			return a.AvailableNow
+		}
+		if contactTypeOrder[a.ContactType] != contactTypeOrder[b.ContactType] {
+			return contactTypeOrder[a.ContactType] < contactTypeOrder[b.ContactType]
+		}
+		return a.priority < b.priority
+	})
+	dial := ""
+	for i := range calls {
+		calls[i].Rank = i + 1
+		if dial == "" && calls[i].AvailableNow {
+			dial = calls[i].Phone
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"contacts":    calls,
+		"dial":        dial,
+		"mining_site": miningSite,
+		"server_time": now.UTC().Format(time.RFC3339),
+	})
+}
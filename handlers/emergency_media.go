@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ==================== EMERGENCY MEDIA ATTACHMENTS ====================
+
+// Photos and videos of an emergency are uploaded straight to the server instead of to
+// storage the app manages itself. Each report can carry several attachments; the first
+// one also fills media_url for clients that only show a single file.
+
+// Attachment types
+const (
+	EmergencyMediaImage = "IMAGE"
+	EmergencyMediaVideo = "VIDEO"
+)
+
+const (
+	// maxEmergencyAttachments bounds the attachments on one report
+	maxEmergencyAttachments = 10
+	// maxEmergencyImageBytes bounds each photo; videos follow the upload policy
+	maxEmergencyImageBytes = 10 << 20
+)
+
+// emergencyImageTypes maps accepted photo extensions to the content type they must sniff as
+var emergencyImageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+// EmergencyAttachment is a photo or video attached to an emergency
+type EmergencyAttachment struct {
+	ID         int       `json:"id"`
+	MediaType  string    `json:"media_type"`
+	URL        string    `json:"url"`
+	FileName   string    `json:"file_name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	UploadedBy string    `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// loadEmergencyAttachments lists an emergency's attachments, oldest first, with signed URLs
+func loadEmergencyAttachments(emergencyID int) ([]EmergencyAttachment, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, media_type, media_url, file_name, size_bytes, uploaded_by, created_at
+		FROM emergency_media WHERE emergency_id = $1
+		ORDER BY created_at, id
+	`, emergencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []EmergencyAttachment{}
+	for rows.Next() {
+		var a EmergencyAttachment
+		if err := rows.Scan(&a.ID, &a.MediaType, &a.URL, &a.FileName, &a.SizeBytes, &a.UploadedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.URL = signUploadURL(a.URL)
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// checkEmergencyImage checks a photo's extension, size and content. It writes the error
+// response itself when the photo is refused.
+func checkEmergencyImage(w http.ResponseWriter, file multipart.File, header *multipart.FileHeader) bool {
+	want := emergencyImageTypes[strings.ToLower(filepath.Ext(header.Filename))]
+	if header.Size > maxEmergencyImageBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, header.Filename+": photos must be 10 MB or smaller")
+		return false
+	}
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	if http.DetectContentType(sniff[:n]) != want {
+		respondWithError(w, http.StatusBadRequest, header.Filename+": not a JPEG, PNG or WebP photo")
+		return false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to read upload")
+		return false
+	}
+	return true
+}
+
+// UploadEmergencyMedia - Attach photos and videos to an emergency (multipart/form-data, one
+// or more "files"). Photos are JPEG, PNG or WebP up to 10 MB; videos follow the reporter's
+// upload policy. The report's media_status becomes SYNCED once they are stored.
+// POST /api/emergencies/{id}/media
+func UploadEmergencyMedia(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	emergencyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid emergency ID")
+		return
+	}
+
+	var reporterID string
+	var attached int
+	args := []interface{}{emergencyID}
+	scope := tenantClause(r, "e.org_id", &args)
+	err = database.DB.QueryRow(`
+		SELECT e.user_id, (SELECT COUNT(*) FROM emergency_media m WHERE m.emergency_id = e.id)
+		FROM emergencies e WHERE e.id = $1`+scope, args...).Scan(&reporterID, &attached)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Emergency not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if reporterID != userID && role != string(models.RoleSupervisor) && role != string(models.RoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "Only the reporter or a supervisor can attach media to this emergency")
+		return
+	}
+
+	err = r.ParseMultipartForm(32 << 20)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Upload is too large")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+	headers := append(r.MultipartForm.File["files"], r.MultipartForm.File["file"]...)
+	if len(headers) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one file is required")
+		return
+	}
+	if attached+len(headers) > maxEmergencyAttachments {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("An emergency can have at most %d attachments (%d already attached)", maxEmergencyAttachments, attached))
+		return
+	}
+
+	dir := uploadDir("emergencies")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create upload directory")
+		return
+	}
+
+	// Files already written are removed if a later one is refused or the insert fails
+	saved := []storedAttachment{}
+	cleanup := func() {
+		for _, f := range saved {
+			os.Remove(f.path)
+		}
+	}
+	policy := uploadPolicyForUser(userID)
+	for _, header := range headers {
+		f, ok := saveEmergencyAttachment(w, policy, dir, header)
+		if !ok {
+			cleanup()
+			return
+		}
+		saved = append(saved, f)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		cleanup()
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+	for _, f := range saved {
+		_, err := tx.Exec(`
+			INSERT INTO emergency_media (emergency_id, media_type, media_url, file_name, size_bytes, uploaded_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		`, emergencyID, f.mediaType, f.url, truncate(f.name, 255), f.size, userID)
+		if err != nil {
+			cleanup()
+			respondWithError(w, http.StatusInternalServerError, "Error saving attachment: "+err.Error())
+			return
+		}
+	}
+	_, err = tx.Exec(`
+		UPDATE emergencies SET media_status = $2, media_url = COALESCE(NULLIF(media_url, ''), $3),
+		       media_status_updated_at = NOW(), media_reminders = 0, media_reminded_at = NULL
+		WHERE id = $1
+	`, emergencyID, models.StatusSynced, saved[0].url)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		cleanup()
+		respondWithError(w, http.StatusInternalServerError, "Error updating emergency")
+		return
+	}
+
+	attachments, err := loadEmergencyAttachments(emergencyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"emergency_id": emergencyID,
+		"media_status": models.StatusSynced,
+		"uploaded":     len(saved),
+		"attachments":  attachments,
+	})
+}
+
+// storedAttachment is a file written under uploads/emergencies
+type storedAttachment struct {
+	path, url, name, mediaType string
+	size                       int64
+}
+
+// saveEmergencyAttachment checks, scans and writes one uploaded file. It writes the error
+// response itself when the file is refused or can't be saved.
+func saveEmergencyAttachment(w http.ResponseWriter, policy UploadPolicy, dir string, header *multipart.FileHeader) (storedAttachment, bool) {
+	file, err := header.Open()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read "+header.Filename)
+		return storedAttachment{}, false
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	mediaType := EmergencyMediaImage
+	if _, image := emergencyImageTypes[ext]; image {
+		if !checkEmergencyImage(w, file, header) {
+			return storedAttachment{}, false
+		}
+	} else {
+		mediaType = EmergencyMediaVideo
+		format, ok := checkVideoUpload(w, policy, file, header)
+		if !ok {
+			return storedAttachment{}, false
+		}
+		ext = "." + format
+	}
+	if !screenUpload(w, policy, file) {
+		return storedAttachment{}, false
+	}
+
+	fileName := uuid.New().String() + ext
+	path := filepath.Join(dir, fileName)
+	dest, err := os.Create(path)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save "+header.Filename)
+		return storedAttachment{}, false
+	}
+	size, err := io.Copy(dest, file)
+	dest.Close()
+	if err != nil {
+		os.Remove(path)
+		respondWithError(w, http.StatusInternalServerError, "Failed to save "+header.Filename)
+		return storedAttachment{}, false
+	}
+	return storedAttachment{
+		path:      path,
+		url:       "/uploads/emergencies/" + fileName,
+		name:      filepath.Base(header.Filename),
+		mediaType: mediaType,
+		size:      size,
+	}, true
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ==================== STALE EMERGENCY POLICY ====================
+
+const emergencyStalePolicySettingKey = "emergency_stale_policy"
+
+// EmergencyStalePolicy controls how long an emergency may stay open in each status.
+// Past the limit the supervisor is alerted; past twice the limit the site admins are.
+type EmergencyStalePolicy struct {
+	PendingMinutes   int `json:"pending_minutes"`
+	ResolvingMinutes int `json:"resolving_minutes"`
+}
+
+var defaultEmergencyStalePolicy = EmergencyStalePolicy{
+	PendingMinutes:   60,
+	ResolvingMinutes: 8 * 60,
+}
+
+func loadEmergencyStalePolicy() (EmergencyStalePolicy, error) {
+	policy := defaultEmergencyStalePolicy
+	var value []byte
+	err := database.DB.QueryRow("SELECT value FROM system_settings WHERE key = $1", emergencyStalePolicySettingKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	err = json.Unmarshal(value, &policy)
+	return policy, err
+}
+
+// StartStaleEmergencyJob periodically flags emergencies left open past the stale policy
+func StartStaleEmergencyJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			flagStaleEmergencies()
+			<-ticker.C
+		}
+	}()
+}
+
+func flagStaleEmergencies() {
+	policy, err := loadEmergencyStalePolicy()
+	if err != nil {
+		log.Printf("Warning: failed to load emergency stale policy: %v", err)
+		return
+	}
+
+	// Minutes allowed in the emergency's current status
+	limit := `(CASE e.status WHEN 'PENDING' THEN $1 ELSE $2 END) * INTERVAL '1 minute'`
+	age := `NOW() - COALESCE(e.status_updated_at, e.reporting_time)`
+
+	// First level: alert the reporter's supervisor
+	rows, err := database.DB.Query(`
+		UPDATE emergencies e SET stale_flagged_at = NOW()
+		FROM users u
+		WHERE e.user_id = u.user_id AND e.status IN ('PENDING', 'RESOLVING')
+		AND e.primary_emergency_id IS NULL AND e.stale_flagged_at IS NULL
+		AND `+age+` > `+limit+`
+		RETURNING e.id, e.status, COALESCE(u.supervisor_id, '')
+	`, policy.PendingMinutes, policy.ResolvingMinutes)
+	if err != nil {
+		log.Printf("Warning: stale emergency job failed: %v", err)
+		return
+	}
+	for rows.Next() {
+		var id int
+		var status, supervisorID string
+		if err := rows.Scan(&id, &status, &supervisorID); err != nil {
+			continue
+		}
+		notifyUser(supervisorID, "EMERGENCY_STALE", "Emergency needs attention",
+			fmt.Sprintf("Emergency #%d has been %s longer than policy allows", id, status), "emergency", strconv.Itoa(id))
+	}
+	rows.Close()
+
+	// Second level: still open at twice the limit, escalate to the site's admins
+	rows, err = database.DB.Query(`
+		UPDATE emergencies e SET escalated_at = NOW()
+		FROM users u
+		WHERE e.user_id = u.user_id AND e.status IN ('PENDING', 'RESOLVING')
+		AND e.stale_flagged_at IS NOT NULL AND e.escalated_at IS NULL
+		AND `+age+` > 2 * `+limit+`
+		RETURNING e.id, e.status, COALESCE(u.mining_site, '')
+	`, policy.PendingMinutes, policy.ResolvingMinutes)
+	if err != nil {
+		log.Printf("Warning: stale emergency escalation failed: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var status, site string
+		if err := rows.Scan(&id, &status, &site); err != nil {
+			continue
+		}
+		for _, adminID := range siteAdmins(site) {
+			notifyUser(adminID, "EMERGENCY_ESCALATED", "Emergency escalated",
+				fmt.Sprintf("Emergency #%d at %s is still %s", id, site, status), "emergency", strconv.Itoa(id))
+		}
+	}
+}
+
+// siteAdmins returns the admins of a mining site
+func siteAdmins(site string) []string {
+	rows, err := database.DB.Query("SELECT user_id FROM users WHERE role = $1 AND mining_site = $2 AND deleted_at IS NULL", models.RoleAdmin, site)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	admins := []string{}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			admins = append(admins, id)
+		}
+	}
+	return admins
+}
+
+// GetEmergencyStalePolicy - Current stale emergency policy
+// GET /api/admin/emergency-stale-policy
+func GetEmergencyStalePolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := loadEmergencyStalePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading emergency stale policy")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// UpdateEmergencyStalePolicy - Change how long emergencies may stay pending or resolving
+// PUT /api/admin/emergency-stale-policy
+func UpdateEmergencyStalePolicy(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var policy EmergencyStalePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if policy.PendingMinutes < 1 || policy.ResolvingMinutes < 1 {
+		respondWithError(w, http.StatusBadRequest, "pending_minutes and resolving_minutes must be at least 1")
+		return
+	}
+
+	value, _ := json.Marshal(policy)
+	_, err := database.DB.Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, emergencyStalePolicySettingKey, value, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving emergency stale policy: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"MineSafeBackend/middleware"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ==================== EMERGENCY REPORT REDACTION ====================
+
+// Downloaded and forwarded emergency reports can leave out who reported the emergency and
+// any injury details, so they can be shared beyond the supervisors who handled it. The
+// caller asks for redactions; fields their role isn't permitted to see are redacted
+// whether they ask or not.
+
+// Parts of an emergency report that can be redacted
+const (
+	RedactReporter = "reporter"
+	RedactMedical  = "medical"
+)
+
+const redactedText = "[redacted]"
+
+// emergencyRedaction is what is left out of one report
+type emergencyRedaction struct {
+	Reporter bool
+	Medical  bool
+}
+
+// reportRedaction combines the redactions asked for with those the caller's role forces.
+// requested is a list like "reporter,medical", as given in ?redact= or the request body.
+func reportRedaction(r *http.Request, requested []string) (emergencyRedaction, error) {
+	var red emergencyRedaction
+	for _, item := range requested {
+		switch strings.ToLower(strings.TrimSpace(item)) {
+		case RedactReporter:
+			red.Reporter = true
+		case RedactMedical:
+			red.Medical = true
+		case "":
+		default:
+			return red, fmt.Errorf("redact accepts %s and %s", RedactReporter, RedactMedical)
+		}
+	}
+
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	if !middleware.HasPermission(role, middleware.PermEmergencyReporterView) {
+		red.Reporter = true
+	}
+	if !middleware.HasPermission(role, middleware.PermEmergencyMedicalView) {
+		red.Medical = true
+	}
+	return red, nil
+}
+
+// applied lists the redactions for the response and the forwarding log
+func (red emergencyRedaction) applied() []string {
+	applied := []string{}
+	if red.Reporter {
+		applied = append(applied, RedactReporter)
+	}
+	if red.Medical {
+		applied = append(applied, RedactMedical)
+	}
+	return applied
+}
+
+// reporter is the reporter's name as printed in the report
+func (red emergencyRedaction) reporter(name string) string {
+	if red.Reporter {
+		return redactedText
+	}
+	return name
+}
+
+// details decodes an emergency's structured details, leaving out the category's medical
+// fields when they are redacted
+func (red emergencyRedaction) details(category string, detailsJSON []byte) map[string]interface{} {
+	details := map[string]interface{}{}
+	json.Unmarshal(detailsJSON, &details)
+	out := map[string]interface{}{}
+	medical := map[string]bool{}
+	if c, ok := findEmergencyCategory(category); ok && red.Medical {
+		for _, f := range c.Fields {
+			medical[f.Key] = f.Medical
+		}
+	}
+	for key, value := range details {
+		if !medical[key] {
+			out[key] = value
+		}
+	}
+	return out
+}
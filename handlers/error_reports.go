@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== CLIENT ERROR REPORTS ====================
+
+// Limits on report fields so a misbehaving client cannot store huge payloads
+const (
+	maxErrorMessageLength = 2000
+	maxStackTraceLength   = 16000
+)
+
+// ClientErrorReportRequest represents a handled error reported by the app. RequestID is the
+// X-Request-ID of the failed API call, if any, so the report can be matched with server logs.
+type ClientErrorReportRequest struct {
+	ErrorType  string     `json:"error_type"`
+	Message    string     `json:"message"`
+	StackTrace string     `json:"stack_trace"`
+	Screen     string     `json:"screen"`
+	RequestID  string     `json:"request_id"`
+	OSVersion  string     `json:"os_version"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// ClientErrorReport is a stored error report
+type ClientErrorReport struct {
+	ID          int       `json:"id"`
+	UserID      string    `json:"user_id"`
+	ErrorType   string    `json:"error_type"`
+	Message     string    `json:"message"`
+	StackTrace  string    `json:"stack_trace,omitempty"`
+	Screen      string    `json:"screen"`
+	RequestID   string    `json:"request_id"`
+	AppVersion  string    `json:"app_version"`
+	Platform    string    `json:"platform"`
+	DeviceModel string    `json:"device_model"`
+	OSVersion   string    `json:"os_version"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// ReleaseErrorSummary aggregates error reports for one app release
+type ReleaseErrorSummary struct {
+	AppVersion    string         `json:"app_version"`
+	Platform      string         `json:"platform"`
+	Reports       int            `json:"reports"`
+	AffectedUsers int            `json:"affected_users"`
+	LastSeen      time.Time      `json:"last_seen"`
+	ByType        map[string]int `json:"by_type"`
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// ReportClientError - The app reports a handled error with its version and device context
+// POST /api/app/errors
+func ReportClientError(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ClientErrorReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.ErrorType = strings.TrimSpace(req.ErrorType)
+	if req.ErrorType == "" {
+		respondWithError(w, http.StatusBadRequest, "error_type is required")
+		return
+	}
+
+	occurredAt := time.Now()
+	if req.OccurredAt != nil && req.OccurredAt.Before(occurredAt) {
+		occurredAt = *req.OccurredAt
+	}
+
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO client_error_reports (user_id, error_type, message, stack_trace, screen, request_id,
+		                                  app_version, platform, device_model, os_version, occurred_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id
+	`, userID, truncate(req.ErrorType, 100), truncate(req.Message, maxErrorMessageLength),
+		truncate(req.StackTrace, maxStackTraceLength), truncate(req.Screen, 100), truncate(req.RequestID, 64),
+		r.Header.Get(headerAppVersion), strings.ToLower(r.Header.Get(headerPlatform)),
+		r.Header.Get(headerDeviceModel), truncate(req.OSVersion, 50), occurredAt).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing report: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":         id,
+		"request_id": middleware.GetRequestID(r.Context()),
+	})
+}
+
+// AdminGetErrorReports - Error reports aggregated per release; app_version lists the recent
+// reports of one release and request_id finds the reports tied to one server request
+// GET /api/admin/error-reports?days=7&app_version=2.3.0&platform=android&request_id=
+func AdminGetErrorReports(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days < 1 || days > 90 {
+		days = 7
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT COALESCE(app_version, ''), COALESCE(platform, ''), error_type,
+		       COUNT(*), COUNT(DISTINCT user_id), MAX(occurred_at)
+		FROM client_error_reports
+		WHERE occurred_at > NOW() - make_interval(days => $1)
+		GROUP BY 1, 2, 3
+		ORDER BY 1 DESC, 2
+	`, days)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	releases := []*ReleaseErrorSummary{}
+	byRelease := map[string]*ReleaseErrorSummary{}
+	for rows.Next() {
+		var appVersion, platform, errorType string
+		var count, users int
+		var lastSeen time.Time
+		if err := rows.Scan(&appVersion, &platform, &errorType, &count, &users, &lastSeen); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		key := platform + "/" + appVersion
+		summary, ok := byRelease[key]
+		if !ok {
+			summary = &ReleaseErrorSummary{AppVersion: appVersion, Platform: platform, ByType: map[string]int{}}
+			byRelease[key] = summary
+			releases = append(releases, summary)
+		}
+		summary.Reports += count
+		// Users can hit several error types, so this is an upper bound across types
+		if users > summary.AffectedUsers {
+			summary.AffectedUsers = users
+		}
+		if lastSeen.After(summary.LastSeen) {
+			summary.LastSeen = lastSeen
+		}
+		summary.ByType[errorType] = count
+	}
+
+	response := map[string]interface{}{
+		"days":     days,
+		"releases": releases,
+	}
+
+	appVersion := r.URL.Query().Get("app_version")
+	requestID := r.URL.Query().Get("request_id")
+	if appVersion != "" || requestID != "" {
+		reports, err := recentErrorReports(days, appVersion, r.URL.Query().Get("platform"), requestID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+			return
+		}
+		response["reports"] = reports
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func recentErrorReports(days int, appVersion, platform, requestID string) ([]ClientErrorReport, error) {
+	query := `
+		SELECT id, COALESCE(user_id, ''), error_type, COALESCE(message, ''), COALESCE(stack_trace, ''),
+		       COALESCE(screen, ''), COALESCE(request_id, ''), COALESCE(app_version, ''), COALESCE(platform, ''),
+		       COALESCE(device_model, ''), COALESCE(os_version, ''), occurred_at
+		FROM client_error_reports
+		WHERE occurred_at > NOW() - make_interval(days => $1)
+	`
+	args := []interface{}{days}
+	if appVersion != "" {
+		args = append(args, appVersion)
+		query += " AND app_version = $" + strconv.Itoa(len(args))
+	}
+	if platform != "" {
+		args = append(args, strings.ToLower(platform))
+		query += " AND platform = $" + strconv.Itoa(len(args))
+	}
+	if requestID != "" {
+		args = append(args, requestID)
+		query += " AND request_id = $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY occurred_at DESC LIMIT 200"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []ClientErrorReport{}
+	for rows.Next() {
+		var rep ClientErrorReport
+		var occurredAt sql.NullTime
+		if err := rows.Scan(&rep.ID, &rep.UserID, &rep.ErrorType, &rep.Message, &rep.StackTrace, &rep.Screen,
+			&rep.RequestID, &rep.AppVersion, &rep.Platform, &rep.DeviceModel, &rep.OSVersion, &occurredAt); err != nil {
+			return nil, err
+		}
+		if occurredAt.Valid {
+			rep.OccurredAt = occurredAt.Time
+		}
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== A/B EXPERIMENTS ====================
+
+// Experiment lifecycle
+const (
+	ExperimentDraft   = "DRAFT"
+	ExperimentRunning = "RUNNING"
+	ExperimentStopped = "STOPPED"
+)
+
+// ExperimentVariant is one arm of an experiment. Weights are relative to each other.
+type ExperimentVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// Experiment splits targeted users between content strategies (e.g. quiz_first vs video_first)
+type Experiment struct {
+	Key         string              `json:"key"`
+	Description string              `json:"description"`
+	Status      string              `json:"status"`
+	Variants    []ExperimentVariant `json:"variants"`
+	Roles       []string            `json:"roles"`        // empty means every role
+	MiningSites []string            `json:"mining_sites"` // empty means every site
+	StartedAt   *time.Time          `json:"started_at,omitempty"`
+	UpdatedBy   string              `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// ExperimentVariantResult holds completion metrics for users exposed to one variant
+type ExperimentVariantResult struct {
+	Variant            string  `json:"variant"`
+	ExposedUsers       int     `json:"exposed_users"`
+	CompletingUsers    int     `json:"completing_users"`
+	CompletionRate     float64 `json:"completion_rate"`
+	Completions        int     `json:"completions"`
+	QuizAttempts       int     `json:"quiz_attempts"`
+	PassRate           float64 `json:"pass_rate"`
+	AveragePercent     float64 `json:"average_percentage"`
+	CompletionsPerUser float64 `json:"completions_per_user"`
+}
+
+func scanExperiment(scanner interface{ Scan(...interface{}) error }) (Experiment, error) {
+	var e Experiment
+	var variantsJSON, rolesJSON, sitesJSON []byte
+	err := scanner.Scan(&e.Key, &e.Description, &e.Status, &variantsJSON, &rolesJSON, &sitesJSON,
+		&e.StartedAt, &e.UpdatedBy, &e.UpdatedAt)
+	if err != nil {
+		return e, err
+	}
+	json.Unmarshal(variantsJSON, &e.Variants)
+	json.Unmarshal(rolesJSON, &e.Roles)
+	json.Unmarshal(sitesJSON, &e.MiningSites)
+	if e.Variants == nil {
+		e.Variants = []ExperimentVariant{}
+	}
+	if e.Roles == nil {
+		e.Roles = []string{}
+	}
+	if e.MiningSites == nil {
+		e.MiningSites = []string{}
+	}
+	return e, nil
+}
+
+const experimentColumns = `key, COALESCE(description, ''), status, COALESCE(variants, '[]'::jsonb),
+	COALESCE(roles, '[]'::jsonb), COALESCE(mining_sites, '[]'::jsonb), started_at,
+	COALESCE(updated_by, ''), updated_at`
+
+func loadExperiments(runningOnly bool) ([]Experiment, error) {
+	query := "SELECT " + experimentColumns + " FROM experiments"
+	if runningOnly {
+		query += " WHERE status = '" + ExperimentRunning + "'"
+	}
+	rows, err := database.DB.Query(query + " ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []Experiment{}
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, nil
+}
+
+// variantFor assigns a user to a variant. The experiment key is hashed with the user ID so
+// assignments are stable and independent across experiments. Users outside the targeted
+// roles or sites get no variant.
+func (e Experiment) variantFor(userID, role, miningSite string) string {
+	if e.Status != ExperimentRunning {
+		return ""
+	}
+	if len(e.Roles) > 0 && !containsString(e.Roles, role) {
+		return ""
+	}
+	if len(e.MiningSites) > 0 && !containsString(e.MiningSites, miningSite) {
+		return ""
+	}
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte("experiment:" + e.Key + ":" + userID))
+	bucket := int(h.Sum32() % uint32(total))
+	for _, v := range e.Variants {
+		if bucket < v.Weight {
+			return v.Name
+		}
+		bucket -= v.Weight
+	}
+	return ""
+}
+
+// experimentVariant returns the authenticated user's variant of a running experiment, or ""
+// when the experiment is not running or does not target the user
+func experimentVariant(r *http.Request, key string) string {
+	e, err := scanExperiment(database.DB.QueryRow("SELECT "+experimentColumns+" FROM experiments WHERE key = $1", key))
+	if err != nil {
+		return ""
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	return e.variantFor(userID, role, userMiningSite(userID))
+}
+
+// GetMyExperiments - Variants of running experiments assigned to the current user
+// GET /api/app/experiments
+func GetMyExperiments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+
+	experiments, err := loadExperiments(true)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	miningSite := userMiningSite(userID)
+	assignments := map[string]string{}
+	for _, e := range experiments {
+		if variant := e.variantFor(userID, role, miningSite); variant != "" {
+			assignments[e.Key] = variant
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"experiments": assignments,
+	})
+}
+
+// LogExperimentExposure - Record that the user actually saw their variant. Only the first
+// exposure is kept, so results measure behaviour after that moment.
+// POST /api/app/experiments/{key}/exposure
+func LogExperimentExposure(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	variant := experimentVariant(r, key)
+	if variant == "" {
+		respondWithError(w, http.StatusNotFound, "You are not enrolled in this experiment")
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO experiment_exposures (experiment_key, user_id, variant, exposed_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (experiment_key, user_id) DO NOTHING
+	`, key, userID, variant)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging exposure")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"experiment": key,
+		"variant":    variant,
+	})
+}
+
+// AdminGetExperiments - List all experiments
+// GET /api/admin/experiments
+func AdminGetExperiments(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	experiments, err := loadExperiments(false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"experiments": experiments,
+	})
+}
+
+// UpsertExperimentRequest represents the request body for creating or updating an experiment
+type UpsertExperimentRequest struct {
+	Description string              `json:"description"`
+	Status      string              `json:"status"` // DRAFT, RUNNING or STOPPED
+	Variants    []ExperimentVariant `json:"variants"`
+	Roles       []string            `json:"roles"`
+	MiningSites []string            `json:"mining_sites"`
+}
+
+// AdminUpsertExperiment - Create or update an experiment. Variants cannot change once the
+// experiment has started, since that would reshuffle users mid-test.
+// PUT /api/admin/experiments/{key}
+func AdminUpsertExperiment(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	if !flagKeyPattern.MatchString(key) {
+		respondWithError(w, http.StatusBadRequest, "Experiment key must be 2-64 lowercase letters, digits or underscores")
+		return
+	}
+
+	var req UpsertExperimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.Status = strings.ToUpper(req.Status)
+	if req.Status == "" {
+		req.Status = ExperimentDraft
+	}
+	if req.Status != ExperimentDraft && req.Status != ExperimentRunning && req.Status != ExperimentStopped {
+		respondWithError(w, http.StatusBadRequest, "status must be DRAFT, RUNNING or STOPPED")
+		return
+	}
+	if len(req.Variants) < 2 {
+		respondWithError(w, http.StatusBadRequest, "An experiment needs at least two variants")
+		return
+	}
+	seen := map[string]bool{}
+	for _, v := range req.Variants {
+		if v.Name == "" || v.Weight < 1 || seen[v.Name] {
+			respondWithError(w, http.StatusBadRequest, "Variants need unique names and a positive weight")
+			return
+		}
+		seen[v.Name] = true
+	}
+	if req.Roles == nil {
+		req.Roles = []string{}
+	}
+	if req.MiningSites == nil {
+		req.MiningSites = []string{}
+	}
+
+	variantsJSON, _ := json.Marshal(req.Variants)
+	existing, err := scanExperiment(database.DB.QueryRow("SELECT "+experimentColumns+" FROM experiments WHERE key = $1", key))
+	if err == nil && existing.StartedAt != nil {
+		existingJSON, _ := json.Marshal(existing.Variants)
+		if string(existingJSON) != string(variantsJSON) {
+			respondWithError(w, http.StatusConflict, "Variants cannot change after the experiment has started")
+			return
+		}
+	}
+
+	rolesJSON, _ := json.Marshal(req.Roles)
+	sitesJSON, _ := json.Marshal(req.MiningSites)
+	_, err = database.DB.Exec(`
+		INSERT INTO experiments (key, description, status, variants, roles, mining_sites, started_at, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $3 = 'RUNNING' THEN NOW() END, $7, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description,
+			status = EXCLUDED.status,
+			variants = EXCLUDED.variants,
+			roles = EXCLUDED.roles,
+			mining_sites = EXCLUDED.mining_sites,
+			started_at = COALESCE(experiments.started_at, EXCLUDED.started_at),
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+	`, key, req.Description, req.Status, variantsJSON, rolesJSON, sitesJSON, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving experiment: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Experiment saved",
+	})
+}
+
+// AdminGetExperimentResults - Module completion and quiz metrics per variant, counting only
+// activity after each user's first exposure
+// GET /api/admin/experiments/{key}/results
+func AdminGetExperimentResults(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	key := mux.Vars(r)["key"]
+	experiment, err := scanExperiment(database.DB.QueryRow("SELECT "+experimentColumns+" FROM experiments WHERE key = $1", key))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT e.variant,
+		       COUNT(DISTINCT e.user_id),
+		       COUNT(DISTINCT c.miner_id),
+		       COUNT(c.id),
+		       COALESCE((SELECT COUNT(*) FROM module_attempts a JOIN experiment_exposures x
+		                 ON x.user_id = a.miner_id AND x.experiment_key = $1
+		                 WHERE x.variant = e.variant AND a.attempted_at >= x.exposed_at), 0),
+		       COALESCE((SELECT COUNT(*) FILTER (WHERE a.passed) FROM module_attempts a JOIN experiment_exposures x
+		                 ON x.user_id = a.miner_id AND x.experiment_key = $1
+		                 WHERE x.variant = e.variant AND a.attempted_at >= x.exposed_at), 0),
+		       COALESCE((SELECT AVG(a.percentage) FROM module_attempts a JOIN experiment_exposures x
+		                 ON x.user_id = a.miner_id AND x.experiment_key = $1
+		                 WHERE x.variant = e.variant AND a.attempted_at >= x.exposed_at), 0)
+		FROM experiment_exposures e
+		LEFT JOIN module_completions c ON c.miner_id = e.user_id AND c.completed_at >= e.exposed_at
+		WHERE e.experiment_key = $1
+		GROUP BY e.variant
+	`, key)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	byVariant := map[string]ExperimentVariantResult{}
+	for rows.Next() {
+		var res ExperimentVariantResult
+		var passes int
+		if err := rows.Scan(&res.Variant, &res.ExposedUsers, &res.CompletingUsers, &res.Completions,
+			&res.QuizAttempts, &passes, &res.AveragePercent); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning results: "+err.Error())
+			return
+		}
+		if res.ExposedUsers > 0 {
+			res.CompletionRate = float64(res.CompletingUsers) / float64(res.ExposedUsers)
+			res.CompletionsPerUser = float64(res.Completions) / float64(res.ExposedUsers)
+		}
+		if res.QuizAttempts > 0 {
+			res.PassRate = float64(passes) / float64(res.QuizAttempts)
+		}
+		byVariant[res.Variant] = res
+	}
+
+	// Keep the configured variant order and include variants nobody has seen yet
+	results := []ExperimentVariantResult{}
+	for _, v := range experiment.Variants {
+		res, ok := byVariant[v.Name]
+		if !ok {
+			res = ExperimentVariantResult{Variant: v.Name}
+		}
+		results = append(results, res)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"experiment": experiment,
+		"results":    results,
+	})
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== FEATURE FLAGS ====================
+
+// Flags checked on the server. Client-only flags (e.g. feed_redesign) need no constant here,
+// they are evaluated through GET /api/app/flags.
+const (
+	FlagAIPPEVerification = "ai_ppe_verification"
+)
+
+// flagCacheTTL bounds how long flag changes take to reach every request
+const flagCacheTTL = 30 * time.Second
+
+var flagKeyPattern = regexp.MustCompile(`^[a-z0-9_]{2,64}$`)
+
+// FeatureFlag targets a feature at roles, mining sites and a percentage of users
+type FeatureFlag struct {
+	Key            string    `json:"key"`
+	Description    string    `json:"description"`
+	Enabled        bool      `json:"enabled"`
+	RolloutPercent int       `json:"rollout_percent"`
+	Roles          []string  `json:"roles"`        // empty means every role
+	MiningSites    []string  `json:"mining_sites"` // empty means every site
+	UpdatedBy      string    `json:"updated_by,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+var (
+	flagCacheMu     sync.RWMutex
+	flagCache       map[string]FeatureFlag
+	flagCacheLoaded time.Time
+)
+
+func loadFeatureFlags() (map[string]FeatureFlag, error) {
+	flagCacheMu.RLock()
+	if flagCache != nil && time.Since(flagCacheLoaded) < flagCacheTTL {
+		defer flagCacheMu.RUnlock()
+		return flagCache, nil
+	}
+	flagCacheMu.RUnlock()
+
+	rows, err := database.DB.Query(`
+		SELECT key, COALESCE(description, ''), enabled, rollout_percent,
+		       COALESCE(roles, '[]'::jsonb), COALESCE(mining_sites, '[]'::jsonb),
+		       COALESCE(updated_by, ''), updated_at
+		FROM feature_flags
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := map[string]FeatureFlag{}
+	for rows.Next() {
+		var f FeatureFlag
+		var rolesJSON, sitesJSON []byte
+		if err := rows.Scan(&f.Key, &f.Description, &f.Enabled, &f.RolloutPercent,
+			&rolesJSON, &sitesJSON, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(rolesJSON, &f.Roles)
+		json.Unmarshal(sitesJSON, &f.MiningSites)
+		if f.Roles == nil {
+			f.Roles = []string{}
+		}
+		if f.MiningSites == nil {
+			f.MiningSites = []string{}
+		}
+		flags[f.Key] = f
+	}
+
+	flagCacheMu.Lock()
+	flagCache = flags
+	flagCacheLoaded = time.Now()
+	flagCacheMu.Unlock()
+	return flags, nil
+}
+
+func clearFlagCache() {
+	flagCacheMu.Lock()
+	flagCache = nil
+	flagCacheMu.Unlock()
+}
+
+// invalidateFlagCache drops the cached flags here and on every other instance
+func invalidateFlagCache() {
+	clearFlagCache()
+	broadcastInvalidation(cacheFlags)
+}
+
+// enabledFor evaluates the flag for one user. Percentage rollouts hash the flag key with the
+// user ID so each user gets a stable answer and different flags pick different pilot users.
+func (f FeatureFlag) enabledFor(userID, role, miningSite string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if len(f.Roles) > 0 && !containsString(f.Roles, role) {
+		return false
+	}
+	if len(f.MiningSites) > 0 && !containsString(f.MiningSites, miningSite) {
+		return false
+	}
+	if f.RolloutPercent >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(f.Key + ":" + userID))
+	return int(h.Sum32()%100) < f.RolloutPercent
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// featureEnabled reports whether a flag is on for the authenticated user.
+// Flags that have not been configured return defaultOn so existing behaviour is kept.
+func featureEnabled(ctx context.Context, key string, defaultOn bool) bool {
+	flags, err := loadFeatureFlags()
+	if err != nil {
+		log.Printf("Warning: failed to load feature flags: %v", err)
+		return defaultOn
+	}
+	f, ok := flags[key]
+	if !ok {
+		return defaultOn
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(ctx)
+	role, _ := middleware.GetUserRoleFromContext(ctx)
+	return f.enabledFor(userID, role, userMiningSite(userID))
+}
+
+func userMiningSite(userID string) string {
+	var miningSite string
+	database.DB.QueryRow("SELECT COALESCE(mining_site, '') FROM users WHERE user_id = $1", userID).Scan(&miningSite)
+	return miningSite
+}
+
+// GetMyFeatureFlags - Evaluated feature flags for the current user
+// GET /api/app/flags
+func GetMyFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+
+	flags, err := loadFeatureFlags()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	miningSite := userMiningSite(userID)
+	result := map[string]bool{}
+	for key, f := range flags {
+		result[key] = f.enabledFor(userID, role, miningSite)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"flags": result,
+	})
+}
+
+// AdminGetFeatureFlags - List all feature flags with their targeting
+// GET /api/admin/flags
+func AdminGetFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	invalidateFlagCache()
+	flags, err := loadFeatureFlags()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	list := []FeatureFlag{}
+	for _, f := range flags {
+		list = append(list, f)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"flags": list,
+	})
+}
+
+// UpsertFeatureFlagRequest represents the request body for creating or updating a flag
+type UpsertFeatureFlagRequest struct {
+	Description    string   `json:"description"`
+	Enabled        bool     `json:"enabled"`
+	RolloutPercent *int     `json:"rollout_percent"` // defaults to 100
+	Roles          []string `json:"roles"`
+	MiningSites    []string `json:"mining_sites"`
+}
+
+// AdminUpsertFeatureFlag - Create or update a feature flag
+// PUT /api/admin/flags/{key}
+func AdminUpsertFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	key := vars["key"]
+	if !flagKeyPattern.MatchString(key) {
+		respondWithError(w, http.StatusBadRequest, "Flag key must be 2-64 lowercase letters, digits or underscores")
+		return
+	}
+
+	var req UpsertFeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	rollout := 100
+	if req.RolloutPercent != nil {
+		rollout = *req.RolloutPercent
+	}
+	if rollout < 0 || rollout > 100 {
+		respondWithError(w, http.StatusBadRequest, "rollout_percent must be between 0 and 100")
+		return
+	}
+	if req.Roles == nil {
+		req.Roles = []string{}
+	}
+	if req.MiningSites == nil {
+		req.MiningSites = []string{}
+	}
+
+	rolesJSON, _ := json.Marshal(req.Roles)
+	sitesJSON, _ := json.Marshal(req.MiningSites)
+	_, err := database.DB.Exec(`
+		INSERT INTO feature_flags (key, description, enabled, rollout_percent, roles, mining_sites, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description,
+			enabled = EXCLUDED.enabled,
+			rollout_percent = EXCLUDED.rollout_percent,
+			roles = EXCLUDED.roles,
+			mining_sites = EXCLUDED.mining_sites,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+	`, key, req.Description, req.Enabled, rollout, rolesJSON, sitesJSON, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving feature flag: "+err.Error())
+		return
+	}
+	invalidateFlagCache()
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Feature flag saved",
+	})
+}
+
+// AdminDeleteFeatureFlag - Delete a feature flag (code falls back to its default)
+// DELETE /api/admin/flags/{key}
+func AdminDeleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	vars := mux.Vars(r)
+	result, err := database.DB.Exec("DELETE FROM feature_flags WHERE key = $1", vars["key"])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting feature flag")
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Feature flag not found")
+		return
+	}
+	invalidateFlagCache()
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Feature flag deleted",
+	})
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== ZONE GEOFENCES ====================
+
+// A zone can be outlined by a boundary polygon, a circle around a centre point, or both.
+// New emergencies with coordinates are placed in the zone they fall in, so a report from a
+// miner working away from their allocated zone still shows up under the right one.
+
+// maxZoneBoundaryPoints bounds the vertices of a zone boundary
+const maxZoneBoundaryPoints = 500
+
+// earthRadiusMeters is the mean Earth radius used for distances between coordinates
+const earthRadiusMeters = 6371000.0
+
+// ZoneGeometry is the area a zone covers. Boundary points are [latitude, longitude] pairs;
+// the polygon is closed automatically.
+type ZoneGeometry struct {
+	Boundary        [][2]float64 `json:"boundary,omitempty"`
+	CenterLatitude  *float64     `json:"center_latitude,omitempty"`
+	CenterLongitude *float64     `json:"center_longitude,omitempty"`
+	RadiusMeters    *float64     `json:"radius_meters,omitempty"`
+}
+
+// empty reports whether no area is set
+func (g ZoneGeometry) empty() bool {
+	return len(g.Boundary) == 0 && g.RadiusMeters == nil
+}
+
+// validate checks the boundary and circle are usable, returning a message for the client
+func (g ZoneGeometry) validate() string {
+	if len(g.Boundary) > 0 {
+		if len(g.Boundary) < 3 || len(g.Boundary) > maxZoneBoundaryPoints {
+			return "boundary needs between 3 and 500 points"
+		}
+		for _, p := range g.Boundary {
+			if !validCoordinate(p[0], p[1]) {
+				return "boundary points must be [latitude, longitude] pairs"
+			}
+		}
+	}
+	circle := g.CenterLatitude != nil || g.CenterLongitude != nil || g.RadiusMeters != nil
+	if circle {
+		if g.CenterLatitude == nil || g.CenterLongitude == nil || g.RadiusMeters == nil {
+			return "center_latitude, center_longitude and radius_meters go together"
+		}
+		if !validCoordinate(*g.CenterLatitude, *g.CenterLongitude) {
+			return "center_latitude and center_longitude must be valid coordinates"
+		}
+		if *g.RadiusMeters <= 0 || *g.RadiusMeters > 50000 {
+			return "radius_meters must be between 0 and 50000"
+		}
+	}
+	return ""
+}
+
+func validCoordinate(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 && !(lat == 0 && lon == 0)
+}
+
+// contains reports whether a point falls inside the boundary or the circle
+func (g ZoneGeometry) contains(lat, lon float64) bool {
+	if len(g.Boundary) >= 3 && pointInPolygon(lat, lon, g.Boundary) {
+		return true
+	}
+	if g.RadiusMeters != nil && g.CenterLatitude != nil && g.CenterLongitude != nil {
+		return distanceMeters(lat, lon, *g.CenterLatitude, *g.CenterLongitude) <= *g.RadiusMeters
+	}
+	return false
+}
+
+// areaSquareMeters approximates the zone's area, so a point inside nested zones resolves
+// to the innermost one. The boundary is projected onto a plane at its mean latitude, which
+// is accurate enough at the scale of a mine.
+func (g ZoneGeometry) areaSquareMeters() float64 {
+	area := math.Inf(1)
+	if len(g.Boundary) >= 3 {
+		meanLat := 0.0
+		for _, p := range g.Boundary {
+			meanLat += p[0]
+		}
+		meanLat /= float64(len(g.Boundary))
+		scaleY := earthRadiusMeters * math.Pi / 180
+		scaleX := scaleY * math.Cos(meanLat*math.Pi/180)
+
+		sum := 0.0
+		for i, p := range g.Boundary {
+			q := g.Boundary[(i+1)%len(g.Boundary)]
+			sum += p[1]*scaleX*q[0]*scaleY - q[1]*scaleX*p[0]*scaleY
+		}
+		area = math.Abs(sum) / 2
+	}
+	if g.RadiusMeters != nil {
+		area = math.Min(area, math.Pi**g.RadiusMeters**g.RadiusMeters)
+	}
+	return area
+}
+
+// pointInPolygon is the even-odd ray casting test with latitude and longitude treated as
+// plane coordinates
+func pointInPolygon(lat, lon float64, polygon [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		yi, xi := polygon[i][0], polygon[i][1]
+		yj, xj := polygon[j][0], polygon[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distanceMeters is the haversine distance between two coordinates
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// scanZoneGeometry builds a geometry from the mine_zones columns; nil when none is set
+func scanZoneGeometry(boundaryJSON []byte, centerLat, centerLon, radius sql.NullFloat64) *ZoneGeometry {
+	g := ZoneGeometry{}
+	json.Unmarshal(boundaryJSON, &g.Boundary)
+	if centerLat.Valid && centerLon.Valid && radius.Valid {
+		g.CenterLatitude, g.CenterLongitude, g.RadiusMeters = &centerLat.Float64, &centerLon.Float64, &radius.Float64
+	}
+	if g.empty() {
+		return nil
+	}
+	return &g
+}
+
+// resolveEmergencyZone stores the zone an emergency's coordinates fall in and returns its
+// ID. Only active zones of the reporter's organization, and of their site when they have
+// one, are considered. Reports without coordinates or outside every zone get none.
+func resolveEmergencyZone(emergencyID int) *int {
+	rows, err := database.DB.Query(`
+		SELECT z.id, z.boundary, z.center_latitude, z.center_longitude, z.radius_meters,
+		       e.latitude, e.longitude
+		FROM emergencies e
+		JOIN users u ON u.user_id = e.user_id
+		JOIN mine_zones z ON z.is_active = true AND z.org_id IS NOT DISTINCT FROM e.org_id
+		     AND (COALESCE(u.mining_site, '') = '' OR z.mining_site = u.mining_site)
+		WHERE e.id = $1 AND NOT (e.latitude = 0 AND e.longitude = 0)
+		  AND (z.boundary IS NOT NULL OR z.radius_meters IS NOT NULL)
+	`, emergencyID)
+	if err != nil {
+		log.Printf("Warning: failed to load zones for emergency %d: %v", emergencyID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	zoneID, smallest := 0, math.Inf(1)
+	for rows.Next() {
+		var id int
+		var boundaryJSON []byte
+		var centerLat, centerLon, radius sql.NullFloat64
+		var lat, lon float64
+		if err := rows.Scan(&id, &boundaryJSON, &centerLat, &centerLon, &radius, &lat, &lon); err != nil {
+			log.Printf("Warning: failed to read zones for emergency %d: %v", emergencyID, err)
+			return nil
+		}
+		g := scanZoneGeometry(boundaryJSON, centerLat, centerLon, radius)
+		if g == nil || !g.contains(lat, lon) {
+			continue
+		}
+		if area := g.areaSquareMeters(); area < smallest {
+			zoneID, smallest = id, area
+		}
+	}
+	if zoneID == 0 {
+		return nil
+	}
+
+	if _, err := database.DB.Exec("UPDATE emergencies SET zone_id = $1 WHERE id = $2", zoneID, emergencyID); err != nil {
+		log.Printf("Warning: failed to set zone of emergency %d: %v", emergencyID, err)
+		return nil
+	}
+	return &zoneID
+}
+
+// SetZoneGeometry - Outline a zone with a boundary polygon and/or a circle; an empty body
+// clears it. New emergencies reported inside are placed in the zone.
+// PUT /api/supervisor/zones/{id}/geometry
+func SetZoneGeometry(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid zone ID")
+		return
+	}
+	if _, err := supervisorZone(r, supervisorID, zoneID); err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Zone not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var g ZoneGeometry
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if msg := g.validate(); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	var boundary interface{}
+	if len(g.Boundary) > 0 {
+		boundaryJSON, _ := json.Marshal(g.Boundary)
+		boundary = string(boundaryJSON)
+	}
+	_, err = database.DB.Exec(`
+		UPDATE mine_zones SET boundary = $2, center_latitude = $3, center_longitude = $4,
+		       radius_meters = $5, updated_at = NOW()
+		WHERE id = $1
+	`, zoneID, boundary, g.CenterLatitude, g.CenterLongitude, g.RadiusMeters)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving zone geometry: "+err.Error())
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"zone_id": zoneID,
+	}
+	if !g.empty() {
+		response["geometry"] = g
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
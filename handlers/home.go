@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+// ==================== MINER APP HOME ====================
+
+// homeNotificationLimit is how many unread notifications the home screen previews
+const homeNotificationLimit = 5
+
+// HomeProfile is the profile snippet shown in the home screen header
+type HomeProfile struct {
+	UserID            string `json:"user_id"`
+	Name              string `json:"name"`
+	Role              string `json:"role"`
+	MiningSite        string `json:"mining_site"`
+	ProfilePictureURL string `json:"profile_picture_url,omitempty"`
+	SupervisorName    string `json:"supervisor_name,omitempty"`
+}
+
+// HomeStarVideo is today's star video and whether the user has completed it
+type HomeStarVideo struct {
+	ID             int    `json:"id"`
+	Title          string `json:"title"`
+	VideoURL       string `json:"video_url"`
+	ThumbnailURL   string `json:"thumbnail_url,omitempty"`
+	CompletedToday bool   `json:"completed_today"`
+}
+
+// HomeChecklistProgress is today's progress on one checklist
+type HomeChecklistProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+// HomeStreak is the user's quiz streak
+type HomeStreak struct {
+	Current        int  `json:"current"`
+	Longest        int  `json:"longest"`
+	CompletedToday bool `json:"completed_today"`
+}
+
+// HomeNotifications previews the user's unread notifications
+type HomeNotifications struct {
+	UnreadCount int            `json:"unread_count"`
+	Latest      []Notification `json:"latest"`
+}
+
+// AppHome is everything the miner app shows on launch
+type AppHome struct {
+	Profile       HomeProfile                      `json:"profile"`
+	StarVideo     *HomeStarVideo                   `json:"star_video"`
+	Checklists    map[string]HomeChecklistProgress `json:"checklists"`
+	Streak        HomeStreak                       `json:"streak"`
+	TrainingDue   []RemedialAssignment             `json:"training_due"`
+	Notifications HomeNotifications                `json:"notifications"`
+}
+
+// GetAppHome - Profile, star video, checklists, streak, assigned training and notifications in one call
+// GET /api/app/home
+func GetAppHome(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	home := AppHome{Checklists: map[string]HomeChecklistProgress{}}
+	loc := userLocation(userID)
+	today := time.Now().In(loc).Format("2006-01-02")
+
+	var supervisorID, profilePic sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT u.user_id, u.name, u.role, COALESCE(u.mining_site, ''), u.supervisor_id,
+			u.profile_picture_url, COALESCE(s.name, '')
+		FROM users u
+		LEFT JOIN users s ON s.user_id = u.supervisor_id
+		WHERE u.user_id = $1
+	`, userID).Scan(&home.Profile.UserID, &home.Profile.Name, &home.Profile.Role, &home.Profile.MiningSite,
+		&supervisorID, &profilePic, &home.Profile.SupervisorName)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	if profilePic.Valid && profilePic.String != "" {
+		home.Profile.ProfilePictureURL = signUploadURL(profilePic.String)
+	}
+
+	// Star videos and checklists are set by the user's supervisor
+	if supervisorID.Valid {
+		var star HomeStarVideo
+		var thumbnail sql.NullString
+		err := database.DB.QueryRow(`
+			SELECT vm.id, vm.title, vm.video_url, vm.thumbnail,
+				EXISTS(SELECT 1 FROM module_completions mc
+					WHERE mc.miner_id = $3 AND mc.video_id = vm.id AND mc.passed AND (mc.completed_at AT TIME ZONE $4)::date = $2)
+			FROM video_modules vm
+			JOIN star_videos sv ON vm.id = sv.video_id
+			WHERE sv.supervisor_id = $1 AND sv.set_date = $2 AND sv.is_active = true
+		`, supervisorID.String, today, userID, loc.String()).Scan(&star.ID, &star.Title, &star.VideoURL, &thumbnail, &star.CompletedToday)
+		if err == nil {
+			media := loadRenditions(w, r, []string{star.VideoURL, thumbnail.String})
+			star.VideoURL = media.sign(star.VideoURL)
+			if thumbnail.Valid && thumbnail.String != "" {
+				star.ThumbnailURL = media.sign(thumbnail.String)
+			}
+			home.StarVideo = &star
+		} else if err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+			return
+		}
+
+		for name, tables := range map[string][2]string{
+			"pre_start": {"pre_start_checklist", "pre_start_checklist_completions"},
+			"ppe":       {"ppe_checklist", "ppe_checklist_completions"},
+		} {
+			var progress HomeChecklistProgress
+			err := database.DB.QueryRow(`
+				SELECT COUNT(*), COUNT(c.id) FILTER (WHERE c.is_completed = true)
+				FROM `+tables[0]+` p
+				LEFT JOIN `+tables[1]+` c ON c.item_id = p.id AND c.user_id = $1 AND c.date = $3
+				WHERE (p.supervisor_id = $2 OR (p.is_default = true AND p.mining_site = (SELECT mining_site FROM users WHERE user_id = $2))) AND p.is_active = true
+			`, userID, supervisorID.String, today).Scan(&progress.Total, &progress.Completed)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+				return
+			}
+			home.Checklists[name] = progress
+		}
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT DATE(completed_at AT TIME ZONE $2) AS attempt_date
+		FROM module_completions
+		WHERE miner_id = $1 AND passed
+		ORDER BY attempt_date DESC
+	`, userID, loc.String())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	attemptDates := []string{}
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning date")
+			return
+		}
+		attemptDates = append(attemptDates, date.Format("2006-01-02"))
+	}
+	rows.Close()
+	leave := minerLeaveDays(userID)
+	home.Streak = HomeStreak{
+		Current:        calculateCurrentStreak(attemptDates, loc, leave),
+		Longest:        calculateLongestStreak(attemptDates, leave),
+		CompletedToday: len(attemptDates) > 0 && attemptDates[0] == today,
+	}
+
+	rows, err = database.DB.Query(remedialAssignmentSelect+`
+		WHERE ra.miner_id = $1 AND ra.completed_at IS NULL
+		ORDER BY ra.due_date NULLS LAST, ra.created_at
+	`, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	home.TrainingDue, err = scanRemedialAssignments(rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+		return
+	}
+
+	home.Notifications.Latest = []Notification{}
+	rows, err = database.DB.Query(`
+		SELECT id, type, title, COALESCE(message, ''), COALESCE(reference_type, ''),
+		       COALESCE(reference_id, ''), is_read, created_at
+		FROM notifications WHERE user_id = $1 AND is_read = false
+		ORDER BY created_at DESC LIMIT $2
+	`, userID, homeNotificationLimit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Message, &n.ReferenceType,
+			&n.ReferenceID, &n.IsRead, &n.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		home.Notifications.Latest = append(home.Notifications.Latest, n)
+	}
+	database.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND is_read = false", userID).
+		Scan(&home.Notifications.UnreadCount)
+
+	respondWithJSON(w, http.StatusOK, home)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ==================== IDEMPOTENCY KEYS ====================
+
+// The app sends an X-Idempotency-Key with submissions it may retry over a bad connection.
+// The first request with a key is processed and its response stored; a retry with the same
+// key and body gets that response back instead of being applied again, and reusing the key
+// for a different body is refused. Keys are per user and endpoint and kept for a day.
+
+const headerIdempotencyKey = "X-Idempotency-Key"
+
+// idempotencyKeyTTL is how long a key and its stored response are kept
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyClaimTimeout frees a key whose request never stored a response, e.g. because
+// the instance died mid-request
+const idempotencyClaimTimeout = time.Minute
+
+const maxIdempotencyKeyLength = 255
+
+// idempotentRequest is a keyed request whose response hasn't been stored yet
+type idempotentRequest struct {
+	userID, scope, key string
+}
+
+// beginIdempotent claims the request's idempotency key for scope. A nil result means no key
+// was sent and the request is processed as usual. It returns false when it has already
+// written the response: the stored one for a retry, or an error.
+func beginIdempotent(w http.ResponseWriter, r *http.Request, userID, scope string, body []byte) (*idempotentRequest, bool) {
+	key := strings.TrimSpace(r.Header.Get(headerIdempotencyKey))
+	if key == "" {
+		return nil, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		respondWithError(w, http.StatusBadRequest, headerIdempotencyKey+" is too long")
+		return nil, false
+	}
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+
+	database.DB.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND (created_at < NOW() - $2 * INTERVAL '1 second'
+		      OR (status_code IS NULL AND created_at < NOW() - $3 * INTERVAL '1 second'))
+	`, userID, idempotencyKeyTTL.Seconds(), idempotencyClaimTimeout.Seconds())
+
+	result, err := database.DB.Exec(`
+		INSERT INTO idempotency_keys (user_id, scope, idempotency_key, request_hash)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, userID, scope, key, requestHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return &idempotentRequest{userID: userID, scope: scope, key: key}, true
+	}
+
+	var storedHash, response string
+	var status sql.NullInt64
+	err = database.DB.QueryRow(`
+		SELECT request_hash, status_code, COALESCE(response, '') FROM idempotency_keys
+		WHERE user_id = $1 AND scope = $2 AND idempotency_key = $3
+	`, userID, scope, key).Scan(&storedHash, &status, &response)
+	switch {
+	case err == sql.ErrNoRows || (err == nil && storedHash == requestHash && !status.Valid):
+		respondWithError(w, http.StatusConflict, "A request with this idempotency key is still being processed")
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+	case storedHash != requestHash:
+		respondWithError(w, http.StatusUnprocessableEntity, "This idempotency key was already used for a different request")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(int(status.Int64))
+		w.Write([]byte(response))
+	}
+	return nil, false
+}
+
+// respond writes the response and stores it for retries of the request. A server error
+// releases the key instead, so a retry is processed again. Safe to call on nil.
+func (req *idempotentRequest) respond(w http.ResponseWriter, code int, payload interface{}) {
+	if req != nil {
+		var err error
+		if code >= http.StatusInternalServerError {
+			_, err = database.DB.Exec(
+				"DELETE FROM idempotency_keys WHERE user_id = $1 AND scope = $2 AND idempotency_key = $3",
+				req.userID, req.scope, req.key)
+		} else {
+			body, _ := json.Marshal(payload)
+			_, err = database.DB.Exec(`
+				UPDATE idempotency_keys SET status_code = $4, response = $5
+				WHERE user_id = $1 AND scope = $2 AND idempotency_key = $3
+			`, req.userID, req.scope, req.key, code, string(body))
+		}
+		if err != nil {
+			log.Printf("Warning: failed to store idempotent response for %s: %v", req.userID, err)
+		}
+	}
+	respondWithJSON(w, code, payload)
+}
+
+// respondError is respondWithError for a keyed request
+func (req *idempotentRequest) respondError(w http.ResponseWriter, code int, message string) {
+	req.respond(w, code, ErrorResponse{Error: message})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== IMPERSONATION ====================
+
+// Support staff debugging the miner app can act as a user for a short while. Every
+// impersonation is recorded, and the token says who is behind it.
+
+// impersonationLifetime is how long an impersonation token lasts; it can't be refreshed
+const impersonationLifetime = 30 * time.Minute
+
+// impersonator returns the admin acting as the user on this request, or "" when the user is
+// acting for themselves
+func impersonator(r *http.Request) string {
+	token, _ := middleware.GetTokenFromContext(r.Context())
+	return token.ImpersonatedBy
+}
+
+// refuseImpersonated responds 403 when an admin is impersonating the user, for actions
+// only the user should take. It reports whether it did.
+func refuseImpersonated(w http.ResponseWriter, r *http.Request) bool {
+	if impersonator(r) == "" {
+		return false
+	}
+	respondWithError(w, http.StatusForbidden, "Not allowed while impersonating a user")
+	return true
+}
+
+// ImpersonateRequest represents the request body for impersonating a user
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminImpersonate - Issue a short-lived token to act as a user, e.g. to reproduce an app
+// issue. Admins can't be impersonated. The reason is recorded in the audit log.
+// POST /api/admin/impersonate/{userId}
+func AdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseImpersonated(w, r) {
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	var user models.User
+	var isActive bool
+	args := []interface{}{mux.Vars(r)["userId"]}
+	scope := tenantClause(r, "org_id", &args)
+	err := database.DB.QueryRow(
+		`SELECT id, user_id, name, email, phone, role, COALESCE(mining_site, ''), COALESCE(location, ''),
+		        supervisor_id, COALESCE(is_active, true), created_at, updated_at
+		 FROM users WHERE user_id = $1`+scope,
+		args...,
+	).Scan(&user.ID, &user.UserID, &user.Name, &user.Email, &user.Phone, &user.Role, &user.MiningSite,
+		&user.Location, &user.SupervisorID, &isActive, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		respondWithError(w, http.StatusForbidden, "Admins can't be impersonated")
+		return
+	}
+	if !isActive {
+		respondWithError(w, http.StatusConflict, "User is deactivated")
+		return
+	}
+
+	// The token is for the app the user would be signed in to, not the admin's dashboard
+	audience := middleware.AudienceDashboard
+	if user.Role == models.RoleVisitor || middleware.HasPermission(string(user.Role), middleware.PermChecklistSubmit) {
+		audience = middleware.AudienceMobile
+	}
+	expiresAt := time.Now().Add(impersonationLifetime)
+	token, info, err := middleware.GenerateImpersonationToken(user.UserID, string(user.Role), audience, adminID, expiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	// No token is handed out unless it's on record
+	var logID int
+	err = database.DB.QueryRow(`
+		INSERT INTO impersonation_log (admin_id, user_id, jti, reason, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, adminID, user.UserID, info.ID, truncate(req.Reason, 500), clientIP(r), r.UserAgent(), info.ExpiresAt).Scan(&logID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"token":            token,
+		"user":             user,
+		"impersonation_id": logID,
+		"impersonated_by":  adminID,
+		"expires_at":       info.ExpiresAt,
+	})
+}
+
+// ImpersonationLogEntry is one recorded impersonation
+type ImpersonationLogEntry struct {
+	ID        int       `json:"id"`
+	AdminID   string    `json:"admin_id"`
+	AdminName string    `json:"admin_name"`
+	UserID    string    `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Reason    string    `json:"reason"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AdminGetImpersonations - Audit log of impersonations, newest first
+// GET /api/admin/impersonations?user_id=&admin_id=&limit=100
+func AdminGetImpersonations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	args := []interface{}{}
+	where := " WHERE 1=1" + tenantClause(r, "u.org_id", &args)
+	if v := q.Get("user_id"); v != "" {
+		args = append(args, v)
+		where += " AND l.user_id = $" + strconv.Itoa(len(args))
+	}
+	if v := q.Get("admin_id"); v != "" {
+		args = append(args, v)
+		where += " AND l.admin_id = $" + strconv.Itoa(len(args))
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+	args = append(args, limit)
+
+	rows, err := database.DB.Query(`
+		SELECT l.id, l.admin_id, COALESCE(a.name, ''), l.user_id, COALESCE(u.name, ''), l.reason,
+		       COALESCE(l.ip_address, ''), l.created_at, l.expires_at
+		FROM impersonation_log l
+		LEFT JOIN users a ON a.user_id = l.admin_id
+		LEFT JOIN users u ON u.user_id = l.user_id`+where+`
+		ORDER BY l.created_at DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	entries := []ImpersonationLogEntry{}
+	for rows.Next() {
+		var e ImpersonationLogEntry
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.AdminName, &e.UserID, &e.UserName, &e.Reason,
+			&e.IPAddress, &e.CreatedAt, &e.ExpiresAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"impersonations": entries,
+		"count":          len(entries),
+	})
+}
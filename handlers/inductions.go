@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== MINER INDUCTION WORKFLOW ====================
+
+const (
+	InductionStatusNotStarted = "NOT_STARTED"
+	InductionStatusInProgress = "IN_PROGRESS"
+	InductionStatusComplete   = "COMPLETE"
+)
+
+// Induction steps in the order they must be completed
+const (
+	InductionStepDocuments = "documents_uploaded"
+	InductionStepMedical   = "medical_cleared"
+	InductionStepModules   = "induction_modules_passed"
+	InductionStepPPE       = "ppe_issued"
+)
+
+var inductionSteps = []string{
+	InductionStepDocuments,
+	InductionStepMedical,
+	InductionStepModules,
+	InductionStepPPE,
+}
+
+// inductionModulePassPercent is the score a miner needs on each required induction module
+const inductionModulePassPercent = 80.0
+
+// InductionStep represents one step of a miner's induction
+type InductionStep struct {
+	Step        string     `json:"step"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// MinerInduction represents a miner's progress through induction
+type MinerInduction struct {
+	MinerID           string          `json:"miner_id"`
+	MinerName         string          `json:"miner_name"`
+	SupervisorID      string          `json:"supervisor_id"`
+	Status            string          `json:"status"`
+	CurrentStep       string          `json:"current_step,omitempty"`
+	ProgressPercent   float64         `json:"progress_percent"`
+	Steps             []InductionStep `json:"steps"`
+	RequiredModuleIDs []int           `json:"required_module_ids"`
+	Notes             string          `json:"notes"`
+	UpdatedBy         string          `json:"updated_by,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+const minerInductionSelect = `
+	SELECT mi.miner_id, u.name, COALESCE(u.supervisor_id, ''), mi.status,
+	       mi.documents_uploaded_at, mi.medical_cleared_at, mi.modules_passed_at, mi.ppe_issued_at,
+	       COALESCE(mi.required_module_ids, '[]'::jsonb), COALESCE(mi.notes, ''),
+	       COALESCE(mi.updated_by, ''), mi.created_at, mi.updated_at
+	FROM miner_inductions mi
+	JOIN users u ON mi.miner_id = u.user_id
+`
+
+func scanMinerInduction(scanner interface{ Scan(...interface{}) error }) (MinerInduction, error) {
+	var ind MinerInduction
+	var documentsAt, medicalAt, modulesAt, ppeAt sql.NullTime
+	var requiredJSON []byte
+	err := scanner.Scan(&ind.MinerID, &ind.MinerName, &ind.SupervisorID, &ind.Status,
+		&documentsAt, &medicalAt, &modulesAt, &ppeAt, &requiredJSON, &ind.Notes,
+		&ind.UpdatedBy, &ind.CreatedAt, &ind.UpdatedAt)
+	if err != nil {
+		return ind, err
+	}
+
+	json.Unmarshal(requiredJSON, &ind.RequiredModuleIDs)
+	if ind.RequiredModuleIDs == nil {
+		ind.RequiredModuleIDs = []int{}
+	}
+
+	completedAt := map[string]sql.NullTime{
+		InductionStepDocuments: documentsAt,
+		InductionStepMedical:   medicalAt,
+		InductionStepModules:   modulesAt,
+		InductionStepPPE:       ppeAt,
+	}
+	done := 0
+	for _, step := range inductionSteps {
+		s := InductionStep{Step: step}
+		if t := completedAt[step]; t.Valid {
+			s.Completed = true
+			s.CompletedAt = &t.Time
+			done++
+		} else if ind.CurrentStep == "" {
+			ind.CurrentStep = step
+		}
+		ind.Steps = append(ind.Steps, s)
+	}
+	ind.ProgressPercent = float64(done) / float64(len(inductionSteps)) * 100
+	return ind, nil
+}
+
+// createMinerInduction starts the induction workflow for a newly created miner
+func createMinerInduction(minerID string) {
+	_, err := database.DB.Exec(`
+		INSERT INTO miner_inductions (miner_id, status, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (miner_id) DO NOTHING
+	`, minerID, InductionStatusNotStarted)
+	if err != nil {
+		log.Printf("Warning: failed to start induction for %s: %v", minerID, err)
+	}
+}
+
+// minerInductionComplete reports whether a miner may be allocated to a zone.
+// Miners created before the induction workflow existed have no record and are treated as inducted.
+func minerInductionComplete(minerID string) (bool, error) {
+	var status string
+	err := database.DB.QueryRow("SELECT status FROM miner_inductions WHERE miner_id = $1", minerID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return status == InductionStatusComplete, nil
+}
+
+// GetSupervisorInductions - Induction progress for all miners under this supervisor
+// GET /api/supervisor/inductions?status=IN_PROGRESS
+func GetSupervisorInductions(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := minerInductionSelect + " WHERE u.supervisor_id = $1"
+	args := []interface{}{supervisorID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND mi.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY mi.created_at DESC"
+
+	listMinerInductions(w, query, args...)
+}
+
+// AdminGetInductions - Induction progress across the organization's miners (HR view)
+// GET /api/admin/inductions?status=IN_PROGRESS&supervisor_id=SUP-xxx
+func AdminGetInductions(w http.ResponseWriter, r *http.Request) {
+	query := minerInductionSelect + " WHERE 1=1"
+	args := []interface{}{}
+	argCount := 1
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += fmt.Sprintf(" AND mi.status = $%d", argCount)
+		args = append(args, status)
+		argCount++
+	}
+	if supervisorID := r.URL.Query().Get("supervisor_id"); supervisorID != "" {
+		query += fmt.Sprintf(" AND u.supervisor_id = $%d", argCount)
+		args = append(args, supervisorID)
+		argCount++
+	}
+	query += tenantClause(r, "u.org_id", &args)
+	query += " ORDER BY mi.created_at DESC"
+
+	listMinerInductions(w, query, args...)
+}
+
+func listMinerInductions(w http.ResponseWriter, query string, args ...interface{}) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	inductions := []MinerInduction{}
+	summary := map[string]int{
+		InductionStatusNotStarted: 0,
+		InductionStatusInProgress: 0,
+		InductionStatusComplete:   0,
+	}
+	for rows.Next() {
+		ind, err := scanMinerInduction(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		summary[ind.Status]++
+		inductions = append(inductions, ind)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"inductions": inductions,
+		"summary":    summary,
+		"count":      len(inductions),
+	})
+}
+
+// GetMinerInduction - Induction progress for a single miner
+// GET /api/supervisor/inductions/{minerId}
+func GetMinerInduction(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	minerID := vars["minerId"]
+
+	ind, err := scanMinerInduction(database.DB.QueryRow(minerInductionSelect+`
+		WHERE mi.miner_id = $1 AND u.supervisor_id = $2
+	`, minerID, supervisorID))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Induction record not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ind)
+}
+
+// InductionStepRequest represents the request body for completing an induction step
+type InductionStepRequest struct {
+	Step              string `json:"step"`
+	Notes             string `json:"notes"`
+	RequiredModuleIDs []int  `json:"required_module_ids,omitempty"` // only used when setting up the modules step
+}
+
+// CompleteInductionStep - Mark the next induction step as complete for a miner
+// POST /api/supervisor/inductions/{minerId}/steps
+func CompleteInductionStep(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	minerID := vars["minerId"]
+
+	var req InductionStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	ind, err := scanMinerInduction(database.DB.QueryRow(minerInductionSelect+`
+		WHERE mi.miner_id = $1 AND u.supervisor_id = $2
+	`, minerID, supervisorID))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Induction record not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if ind.Status == InductionStatusComplete {
+		respondWithError(w, http.StatusConflict, "Induction already complete")
+		return
+	}
+
+	// Steps must be completed in order
+	if req.Step != ind.CurrentStep {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Next induction step is '%s'", ind.CurrentStep))
+		return
+	}
+
+	requiredModules := ind.RequiredModuleIDs
+	if req.RequiredModuleIDs != nil {
+		requiredModules = req.RequiredModuleIDs
+	}
+
+	if req.Step == InductionStepModules {
+		missing, err := missingInductionModules(minerID, requiredModules)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if len(missing) > 0 {
+			respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":           "Required induction modules have not been passed",
+				"missing_modules": missing,
+			})
+			return
+		}
+	}
+
+	column := map[string]string{
+		InductionStepDocuments: "documents_uploaded_at",
+		InductionStepMedical:   "medical_cleared_at",
+		InductionStepModules:   "modules_passed_at",
+		InductionStepPPE:       "ppe_issued_at",
+	}[req.Step]
+
+	status := InductionStatusInProgress
+	if req.Step == inductionSteps[len(inductionSteps)-1] {
+		status = InductionStatusComplete
+	}
+
+	notes := ind.Notes
+	if req.Notes != "" {
+		if notes != "" {
+			notes += "\n"
+		}
+		notes += fmt.Sprintf("[%s] %s: %s", time.Now().Format("2006-01-02"), req.Step, req.Notes)
+	}
+
+	requiredJSON, _ := json.Marshal(requiredModules)
+	_, err = database.DB.Exec(`
+		UPDATE miner_inductions
+		SET `+column+` = NOW(), status = $1, notes = $2, required_module_ids = $3, updated_by = $4, updated_at = NOW()
+		WHERE miner_id = $5
+	`, status, notes, requiredJSON, supervisorID, minerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating induction: "+err.Error())
+		return
+	}
+
+	ind, err = scanMinerInduction(database.DB.QueryRow(minerInductionSelect+" WHERE mi.miner_id = $1", minerID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching updated induction")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ind)
+}
+
+// missingInductionModules returns the required module IDs the miner has not yet passed
+func missingInductionModules(minerID string, moduleIDs []int) ([]int, error) {
+	missing := []int{}
+	for _, videoID := range moduleIDs {
+		var passed bool
+		err := database.DB.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 FROM module_completions
+				WHERE miner_id = $1 AND video_id = $2 AND passed AND total_questions > 0
+				AND CAST(score AS FLOAT) / CAST(total_questions AS FLOAT) * 100 >= $3
+			)
+		`, minerID, videoID, inductionModulePassPercent).Scan(&passed)
+		if err != nil {
+			return nil, err
+		}
+		if !passed {
+			missing = append(missing, videoID)
+		}
+	}
+	return missing, nil
+}
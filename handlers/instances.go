@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"log"
+	"time"
+)
+
+// ==================== MULTI-INSTANCE SHARED STATE ====================
+
+// SharedStatePostgres keeps rate limits and login lockouts in Postgres and fans cache
+// invalidations out to every instance with LISTEN/NOTIFY
+const SharedStatePostgres = "postgres"
+
+// invalidationChannel carries the name of the cache an instance has just changed
+const invalidationChannel = "minesafe_invalidate"
+
+// Caches that other instances reload when they are invalidated
+const (
+	cacheFlags       = "flags"
+	cacheMaintenance = "maintenance"
+	cacheSigningKeys = "signing_keys"
+	cacheRevocations = "revocations"
+	cachePermissions = "permissions"
+)
+
+var sharedStateEnabled bool
+
+// cacheReloaders refresh this instance's copy of a cache without broadcasting again
+var cacheReloaders = map[string]func(){
+	cacheFlags: clearFlagCache,
+	cacheMaintenance: func() {
+		if state, err := loadMaintenanceState(); err == nil {
+			middleware.SetMaintenanceState(state)
+		}
+	},
+	cacheSigningKeys: func() {
+		if err := LoadSigningKeys(); err != nil {
+			log.Printf("Warning: failed to reload JWT signing keys: %v", err)
+		}
+	},
+	cacheRevocations: func() {
+		if err := loadRevocations(); err != nil {
+			log.Printf("Warning: failed to reload token revocations: %v", err)
+		}
+	},
+	cachePermissions: func() {
+		if err := loadRolePermissions(); err != nil {
+			log.Printf("Warning: failed to reload role permissions: %v", err)
+		}
+	},
+}
+
+// InitSharedState prepares the stateful pieces for running several instances behind a load
+// balancer. With the default in-memory backend nothing changes. Uploads are shared by
+// pointing UPLOADS_DIR at a volume every instance mounts.
+func InitSharedState(cfg *config.Config) {
+	if cfg.SharedStateBackend != SharedStatePostgres {
+		return
+	}
+
+	middleware.SetSharedCounterStore(database.SharedCounters{})
+	StartSharedCounterCleanupJob(10 * time.Minute)
+
+	if err := database.Listen(invalidationChannel, reloadCache); err != nil {
+		log.Printf("Warning: cache invalidation listener failed, instances rely on periodic sync: %v", err)
+	} else {
+		sharedStateEnabled = true
+	}
+	// Long-polling phones may be connected to any instance; an empty payload after a
+	// reconnect wakes them all to recheck
+	if err := database.Listen(notificationChannel, wakePollers); err != nil {
+		log.Printf("Warning: notification listener failed, long polls fall back to periodic checks: %v", err)
+	} else {
+		pollFanout = true
+	}
+	if err := database.Listen(liveAlertChannel, receiveLiveAlert); err != nil {
+		log.Printf("Warning: live alert listener failed, dashboards only get alerts raised on their own instance: %v", err)
+	} else {
+		liveFanout = true
+	}
+	log.Println("Shared state enabled: rate limits and login lockouts are kept in Postgres")
+}
+
+// reloadCache handles an invalidation from any instance. An empty name follows a listener
+// reconnect, when notifications may have been lost, so every cache is reloaded.
+func reloadCache(name string) {
+	if name == "" {
+		for _, reload := range cacheReloaders {
+			reload()
+		}
+		return
+	}
+	if reload, ok := cacheReloaders[name]; ok {
+		reload()
+	}
+}
+
+// broadcastInvalidation tells the other instances to reload a cache this instance changed
+func broadcastInvalidation(name string) {
+	if !sharedStateEnabled {
+		return
+	}
+	if err := database.Publish(invalidationChannel, name); err != nil {
+		log.Printf("Warning: failed to broadcast %s invalidation: %v", name, err)
+	}
+}
+
+// StartSharedCounterCleanupJob periodically deletes shared counters whose window has ended
+func StartSharedCounterCleanupJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := database.PurgeExpiredCounters(); err != nil {
+				log.Printf("Warning: shared counter cleanup failed: %v", err)
+			}
+		}
+	}()
+}
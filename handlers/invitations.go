@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== CREW INVITATIONS ====================
+
+// Invitation states as listed to supervisors
+const (
+	InvitationPending  = "PENDING"
+	InvitationExpired  = "EXPIRED"
+	InvitationAccepted = "ACCEPTED"
+)
+
+// inviteResendInterval stops an invitation being emailed again in quick succession
+const inviteResendInterval = time.Minute
+
+// InviteMinerRequest represents the request body for inviting a miner
+type InviteMinerRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// Invitation is a crew member's invitation as shown to their supervisor
+type Invitation struct {
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Status     string     `json:"status"`
+	SentCount  int        `json:"sent_count"`
+	LastSentAt time.Time  `json:"last_sent_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueInvitation creates or replaces the user's invitation token and emails the link.
+// Replacing the token invalidates any link sent earlier.
+func issueInvitation(userID, name, email, invitedBy string) (time.Time, error) {
+	cfg := config.Get()
+	token, err := generateRandomHex(32)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiresAt := time.Now().Add(cfg.InviteTTL)
+
+	_, err = database.DB.Exec(`
+		INSERT INTO user_invitations (user_id, token_hash, invited_by, expires_at, sent_count, last_sent_at, created_at)
+		VALUES ($1, $2, $3, $4, 1, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at,
+			sent_count = user_invitations.sent_count + 1, last_sent_at = NOW()
+	`, userID, hashInviteToken(token), invitedBy, expiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var supervisorName string
+	database.DB.QueryRow("SELECT name FROM users WHERE user_id = $1", invitedBy).Scan(&supervisorName)
+
+	acceptURL := cfg.InviteAcceptURL
+	if acceptURL == "" {
+		acceptURL = "http://localhost:" + cfg.Port + "/activate"
+	}
+	link := acceptURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\n%s has added you to their crew on MineSafe. "+
+		"Open the link below to choose your password and activate your account:\n\n%s\n\n"+
+		"The link expires on %s. If it has expired, ask your supervisor to send a new one.\n",
+		name, supervisorName, link, expiresAt.In(userLocation(userID)).Format("2 Jan 2006 15:04 MST"))
+
+	return expiresAt, sendBrandedEmail(brandingForUser(invitedBy), email, "Activate your MineSafe account", body)
+}
+
+// InviteMiner - Add a miner who sets their own password from an emailed activation link
+// POST /api/miners/invite
+func InviteMiner(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req InviteMinerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Name == "" || req.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "Name and email are required")
+		return
+	}
+	if code, msg := checkContactAvailable(&req.Email, &req.Phone, ""); code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	// Nobody knows this password; the account can't be signed in to until it is activated
+	placeholder, err := generateRandomHex(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+	hashedPassword, err := hashPassword(placeholder)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+
+	miner, code, msg := insertCrewMember(supervisorID, req.Name, req.Email, req.Phone, string(hashedPassword), models.RoleMiner)
+	if code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	// The account stays even if the email fails so the invitation can be resent
+	expiresAt, err := issueInvitation(miner.UserID, miner.Name, miner.Email, supervisorID)
+	emailSent := err == nil
+	if err != nil {
+		log.Printf("Warning: invitation for %s not sent: %v", miner.UserID, err)
+	}
+
+	miner.Password = ""
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"user":       miner,
+		"status":     InvitationPending,
+		"expires_at": expiresAt,
+		"email_sent": emailSent,
+	})
+}
+
+// ResendInvitation - Email a new activation link, invalidating the previous one
+// POST /api/miners/{id}/invite/resend
+func ResendInvitation(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	userID := mux.Vars(r)["id"]
+
+	var name, email string
+	var acceptedAt sql.NullTime
+	var lastSentAt time.Time
+	err := database.DB.QueryRow(`
+		SELECT u.name, u.email, i.accepted_at, i.last_sent_at
+		FROM user_invitations i JOIN users u ON u.user_id = i.user_id
+		WHERE i.user_id = $1 AND u.supervisor_id = $2
+	`, userID, supervisorID).Scan(&name, &email, &acceptedAt, &lastSentAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	if acceptedAt.Valid {
+		respondWithError(w, http.StatusConflict, "This account has already been activated")
+		return
+	}
+	if time.Since(lastSentAt) < inviteResendInterval {
+		respondWithError(w, http.StatusTooManyRequests, "The invitation was just sent; wait a minute before resending")
+		return
+	}
+
+	expiresAt, err := issueInvitation(userID, name, email, supervisorID)
+	if err != nil {
+		log.Printf("Warning: invitation for %s not resent: %v", userID, err)
+		respondWithError(w, http.StatusBadGateway, "Could not send the invitation email")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"status":     InvitationPending,
+		"expires_at": expiresAt,
+	})
+}
+
+// GetInvitations - Invitations sent to the supervisor's crew and whether they were accepted
+// GET /api/miners/invitations
+func GetInvitations(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT u.user_id, u.name, u.email, i.sent_count, i.last_sent_at, i.expires_at, i.accepted_at
+		FROM user_invitations i JOIN users u ON u.user_id = i.user_id
+		WHERE u.supervisor_id = $1
+		ORDER BY i.last_sent_at DESC
+	`, supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		var inv Invitation
+		var acceptedAt sql.NullTime
+		if err := rows.Scan(&inv.UserID, &inv.Name, &inv.Email, &inv.SentCount, &inv.LastSentAt, &inv.ExpiresAt, &acceptedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		switch {
+		case acceptedAt.Valid:
+			inv.Status = InvitationAccepted
+			inv.AcceptedAt = &acceptedAt.Time
+		case time.Now().After(inv.ExpiresAt):
+			inv.Status = InvitationExpired
+		default:
+			inv.Status = InvitationPending
+		}
+		invitations = append(invitations, inv)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"invitations": invitations,
+	})
+}
+
+// ActivateAccountRequest represents the request body for accepting an invitation
+type ActivateAccountRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ActivateAccount - Accept an invitation by choosing a password; the user is logged in
+// POST /api/auth/activate
+func ActivateAccount(w http.ResponseWriter, r *http.Request) {
+	var req ActivateAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+	if err := checkPasswordPolicy(req.Password); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var userID string
+	var expiresAt time.Time
+	var acceptedAt sql.NullTime
+	err := database.DB.QueryRow(
+		"SELECT user_id, expires_at, accepted_at FROM user_invitations WHERE token_hash = $1",
+		hashInviteToken(req.Token),
+	).Scan(&userID, &expiresAt, &acceptedAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "This activation link is not valid. Use the most recent email you received.")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if acceptedAt.Valid {
+		respondWithError(w, http.StatusConflict, "This account has already been activated; log in with your password")
+		return
+	}
+	if time.Now().After(expiresAt) {
+		respondWithError(w, http.StatusGone, "This activation link has expired. Ask your supervisor to send a new one.")
+		return
+	}
+
+	hashedPassword, err := hashPassword(req.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Accepting is conditional so two requests with the same link can't both succeed
+	result, err := tx.Exec(
+		"UPDATE user_invitations SET accepted_at = NOW() WHERE user_id = $1 AND accepted_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusConflict, "This account has already been activated; log in with your password")
+		return
+	}
+	if _, err := tx.Exec("UPDATE users SET password = $1, updated_at = NOW() WHERE user_id = $2", string(hashedPassword), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var user models.User
+	err = database.DB.QueryRow(
+		`SELECT id, user_id, name, email, phone, role, COALESCE(mining_site, ''), COALESCE(location, ''), supervisor_id, created_at, updated_at
+		 FROM users WHERE user_id = $1`,
+		userID,
+	).Scan(&user.ID, &user.UserID, &user.Name, &user.Email, &user.Phone,
+		&user.Role, &user.MiningSite, &user.Location, &user.SupervisorID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	token, err := issueLoginToken(r, user.UserID, string(user.Role))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+	log.Printf("Account %s activated from invitation", user.UserID)
+
+	respondWithJSON(w, http.StatusOK, AuthResponse{
+		Token:          token,
+		UserID:         user.UserID,
+		Role:           string(user.Role),
+		User:           &user,
+		OrganizationID: user.MiningSite,
+	})
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ==================== JWT SIGNING KEY ROTATION ====================
+
+const (
+	SigningKeyActive   = "ACTIVE"
+	SigningKeyRetiring = "RETIRING"
+	SigningKeyRetired  = "RETIRED"
+)
+
+// signingKeyRetireAfter is how long a rotated-out key keeps verifying tokens. It covers the
+// longest token we issue (visitor passes), so rotation never logs anyone out early.
+const signingKeyRetireAfter = maxVisitorPassDuration
+
+// JWTSigningKey describes a signing key without its secret
+type JWTSigningKey struct {
+	KeyID       string     `json:"kid"`
+	Status      string     `json:"status"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"` // unset for keys from JWT_KEYS/JWT_KEYS_FILE
+	RetireAfter *time.Time `json:"retire_after,omitempty"`
+	RotatedBy   string     `json:"rotated_by,omitempty"`
+}
+
+// LoadSigningKeys loads the active and retiring keys into the token middleware. Keys set in
+// JWT_KEYS or JWT_KEYS_FILE take precedence; otherwise they come from rotations made through
+// the admin API, and until the first rotation the configured JWT_SECRET is the only key.
+func LoadSigningKeys() error {
+	configured, err := config.Get().ConfiguredJWTKeys()
+	if err != nil {
+		return err
+	}
+	if configured != nil {
+		loadConfiguredSigningKeys(configured)
+		return nil
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE jwt_signing_keys SET status = $1
+		WHERE status = $2 AND retire_after <= NOW()
+	`, SigningKeyRetired, SigningKeyRetiring)
+	if err != nil {
+		return err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT kid, COALESCE(secret, ''), status FROM jwt_signing_keys
+		WHERE status IN ($1, $2)
+		ORDER BY created_at DESC
+	`, SigningKeyActive, SigningKeyRetiring)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	legacy := middleware.SigningKey{ID: middleware.LegacyKeyID, Secret: []byte(config.Get().JWTSecret)}
+	var active *middleware.SigningKey
+	verify := []middleware.SigningKey{}
+	legacyTracked := false
+
+	for rows.Next() {
+		var kid, secret, status string
+		if err := rows.Scan(&kid, &secret, &status); err != nil {
+			return err
+		}
+		key := middleware.SigningKey{ID: kid, Secret: []byte(secret)}
+		if kid == middleware.LegacyKeyID {
+			// The legacy key's secret lives in JWT_SECRET, the row only tracks its lifecycle
+			key = legacy
+			legacyTracked = true
+		}
+		if status == SigningKeyActive && active == nil {
+			k := key
+			active = &k
+		}
+		verify = append(verify, key)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if active == nil {
+		var rotated bool
+		database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM jwt_signing_keys)").Scan(&rotated)
+		if rotated {
+			log.Println("Warning: no active JWT signing key found, falling back to JWT_SECRET")
+		}
+		active = &legacy
+		if !legacyTracked {
+			verify = append(verify, legacy)
+		}
+	}
+
+	middleware.SetSigningKeys(*active, verify)
+	return nil
+}
+
+// loadConfiguredSigningKeys signs with the first configured key and verifies with the others
+// until their retire_after
+func loadConfiguredSigningKeys(keys []config.JWTKey) {
+	verify := []middleware.SigningKey{}
+	for _, k := range keys[1:] {
+		if k.RetireAfter != nil && time.Now().After(*k.RetireAfter) {
+			continue
+		}
+		verify = append(verify, configuredSigningKey(k))
+	}
+	middleware.SetSigningKeys(configuredSigningKey(keys[0]), verify)
+}
+
+func configuredSigningKey(k config.JWTKey) middleware.SigningKey {
+	if k.ID == middleware.LegacyKeyID && k.Secret == "" {
+		return middleware.SigningKey{ID: k.ID, Secret: []byte(config.Get().JWTSecret)}
+	}
+	return middleware.SigningKey{ID: k.ID, Secret: []byte(k.Secret)}
+}
+
+// configuredKeyStatus describes a configured key in the terms of the rotated keys
+func configuredKeyStatus(i int, k config.JWTKey) string {
+	switch {
+	case i == 0:
+		return SigningKeyActive
+	case k.RetireAfter != nil && time.Now().After(*k.RetireAfter):
+		return SigningKeyRetired
+	}
+	return SigningKeyRetiring
+}
+
+// StartSigningKeySync periodically reloads signing keys so rotations made on one instance
+// reach the others, edits to JWT_KEYS_FILE are picked up and retired keys stop being accepted
+func StartSigningKeySync(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := LoadSigningKeys(); err != nil {
+				log.Printf("Warning: failed to reload JWT signing keys: %v", err)
+			}
+		}
+	}()
+}
+
+// RotateSigningKeyRequest represents the request body for rotating the JWT signing key
+type RotateSigningKeyRequest struct {
+	// Immediate retires the previous keys right away, logging out every session.
+	// Use it when a key is known to be compromised.
+	Immediate bool `json:"immediate"`
+}
+
+// RotateSigningKey - Generate a new JWT signing key and retire the current one
+// POST /api/admin/jwt/rotate
+func RotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if keys, _ := config.Get().ConfiguredJWTKeys(); keys != nil {
+		respondWithError(w, http.StatusConflict, "Signing keys are set in JWT_KEYS or JWT_KEYS_FILE; rotate them there")
+		return
+	}
+
+	var req RotateSigningKeyRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	retireAfter := time.Now().Add(signingKeyRetireAfter)
+	if req.Immediate {
+		retireAfter = time.Now()
+	}
+
+	kid, err := generateRandomHex(8)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate key ID")
+		return
+	}
+	secret, err := generateRandomHex(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate key")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// The first rotation starts tracking JWT_SECRET as the legacy key so it can be retired too
+	_, err = tx.Exec(`
+		INSERT INTO jwt_signing_keys (kid, secret, status, created_at)
+		VALUES ($1, NULL, $2, NOW())
+		ON CONFLICT (kid) DO NOTHING
+	`, middleware.LegacyKeyID, SigningKeyActive)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating key: "+err.Error())
+		return
+	}
+
+	status := SigningKeyRetiring
+	if req.Immediate {
+		status = SigningKeyRetired
+	}
+	_, err = tx.Exec(`
+		UPDATE jwt_signing_keys SET status = $1, retire_after = $2
+		WHERE status = $3 OR ($4 AND status = $5)
+	`, status, retireAfter, SigningKeyActive, req.Immediate, SigningKeyRetiring)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating key: "+err.Error())
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO jwt_signing_keys (kid, secret, status, rotated_by, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, kid, secret, SigningKeyActive, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating key: "+err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating key")
+		return
+	}
+
+	if err := LoadSigningKeys(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Key rotated but reload failed: "+err.Error())
+		return
+	}
+	broadcastInvalidation(cacheSigningKeys)
+	log.Printf("JWT signing key rotated to %s by %s (immediate=%v)", kid, adminID, req.Immediate)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"kid":          kid,
+		"retire_after": retireAfter,
+		"message":      "Signing key rotated. New tokens use the new key; previous keys are accepted until retire_after.",
+	})
+}
+
+// GetSigningKeys - List signing keys and their lifecycle (secrets are never returned)
+// GET /api/admin/jwt/keys
+func GetSigningKeys(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	configured, err := config.Get().ConfiguredJWTKeys()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Invalid signing key configuration: "+err.Error())
+		return
+	}
+	if configured != nil {
+		keys := []JWTSigningKey{}
+		for i, k := range configured {
+			keys = append(keys, JWTSigningKey{KeyID: k.ID, Status: configuredKeyStatus(i, k), RetireAfter: k.RetireAfter})
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"keys":   keys,
+			"source": "config",
+		})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT kid, status, created_at, retire_after, COALESCE(rotated_by, '')
+		FROM jwt_signing_keys ORDER BY created_at DESC
+	`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	keys := []JWTSigningKey{}
+	for rows.Next() {
+		var k JWTSigningKey
+		var retireAfter sql.NullTime
+		if err := rows.Scan(&k.KeyID, &k.Status, &k.CreatedAt, &retireAfter, &k.RotatedBy); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		if retireAfter.Valid {
+			k.RetireAfter = &retireAfter.Time
+		}
+		keys = append(keys, k)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"keys":   keys,
+		"source": "database",
+	})
+}
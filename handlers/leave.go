@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== MINER LEAVE ====================
+
+// Leave types a supervisor can record
+const (
+	LeaveAnnual   = "ANNUAL"
+	LeaveSick     = "SICK"
+	LeaveTraining = "TRAINING"
+	LeaveOther    = "OTHER"
+)
+
+var leaveTypes = map[string]bool{LeaveAnnual: true, LeaveSick: true, LeaveTraining: true, LeaveOther: true}
+
+// leaveHistoryDays is how far back streaks look for leave that bridges gaps
+const leaveHistoryDays = 366
+
+// MinerLeave is a period a crew member is away; both dates are inclusive
+type MinerLeave struct {
+	ID         int       `json:"id"`
+	MinerID    string    `json:"miner_id"`
+	MinerName  string    `json:"miner_name,omitempty"`
+	StartDate  string    `json:"start_date"`
+	EndDate    string    `json:"end_date"`
+	LeaveType  string    `json:"leave_type"`
+	Note       string    `json:"note,omitempty"`
+	RecordedBy string    `json:"recorded_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const minerLeaveSelect = `
+	SELECT l.id, l.user_id, u.name, TO_CHAR(l.start_date, 'YYYY-MM-DD'), TO_CHAR(l.end_date, 'YYYY-MM-DD'),
+		l.leave_type, COALESCE(l.note, ''), COALESCE(l.recorded_by, ''), l.created_at
+	FROM miner_leave l
+	JOIN users u ON u.user_id = l.user_id`
+
+func scanMinerLeave(rows *sql.Rows) ([]MinerLeave, error) {
+	defer rows.Close()
+	leave := []MinerLeave{}
+	for rows.Next() {
+		var l MinerLeave
+		if err := rows.Scan(&l.ID, &l.MinerID, &l.MinerName, &l.StartDate, &l.EndDate,
+			&l.LeaveType, &l.Note, &l.RecordedBy, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		leave = append(leave, l)
+	}
+	return leave, rows.Err()
+}
+
+// minerLeaveOn returns the last day of the leave covering date, or "" when the miner is
+// not on leave that day
+func minerLeaveOn(minerID, date string) (string, error) {
+	var until sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT TO_CHAR(MAX(end_date), 'YYYY-MM-DD') FROM miner_leave
+		WHERE user_id = $1 AND $2::date BETWEEN start_date AND end_date
+	`, minerID, date).Scan(&until)
+	return until.String, err
+}
+
+// minerLeaveDays lists each day of the miner's leave in the past year, for streaks to
+// skip over
+func minerLeaveDays(minerID string) map[string]bool {
+	days := map[string]bool{}
+	rows, err := database.DB.Query(`
+		SELECT TO_CHAR(d, 'YYYY-MM-DD')
+		FROM miner_leave l
+		CROSS JOIN generate_series(GREATEST(l.start_date, CURRENT_DATE - $2::int), l.end_date, INTERVAL '1 day') AS d
+		WHERE l.user_id = $1 AND l.end_date >= CURRENT_DATE - $2::int
+	`, minerID, leaveHistoryDays)
+	if err != nil {
+		log.Printf("Warning: failed to load leave for %s: %v", minerID, err)
+		return days
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		if rows.Scan(&day) == nil {
+			days[day] = true
+		}
+	}
+	return days
+}
+
+// refreshLeaveCompliance recomputes the compliance summary over a leave period once it has
+// been recorded or removed, up to today
+func refreshLeaveCompliance(startDate, endDate string) {
+	today := time.Now().Format("2006-01-02")
+	if startDate > today {
+		return
+	}
+	if endDate > today {
+		endDate = today
+	}
+	if _, err := refreshComplianceSummary(startDate, endDate); err != nil {
+		log.Printf("Warning: compliance refresh after leave change failed: %v", err)
+	}
+}
+
+// MinerLeaveRequest represents the request body for recording leave
+type MinerLeaveRequest struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	LeaveType string `json:"leave_type"`
+	Note      string `json:"note"`
+}
+
+// CreateMinerLeave - Record that a crew member is on leave for a range of days
+// POST /api/miners/{id}/leave
+func CreateMinerLeave(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	minerID := mux.Vars(r)["id"]
+
+	var req MinerLeaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "start_date must be YYYY-MM-DD")
+		return
+	}
+	if req.EndDate == "" {
+		req.EndDate = req.StartDate
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "end_date must be YYYY-MM-DD")
+		return
+	}
+	if end.Before(start) || end.Sub(start) > leaveHistoryDays*24*time.Hour {
+		respondWithError(w, http.StatusBadRequest, "end_date must be on or after start_date and within a year of it")
+		return
+	}
+	req.LeaveType = strings.ToUpper(req.LeaveType)
+	if req.LeaveType == "" {
+		req.LeaveType = LeaveOther
+	}
+	if !leaveTypes[req.LeaveType] {
+		respondWithError(w, http.StatusBadRequest, "leave_type must be ANNUAL, SICK, TRAINING or OTHER")
+		return
+	}
+
+	var exists bool
+	err = database.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND supervisor_id = $2 AND role = ANY($3) AND deleted_at IS NULL)",
+		minerID, supervisorID, crewRoles(),
+	).Scan(&exists)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "Miner not found")
+		return
+	}
+
+	var overlapping int
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(MIN(id), 0) FROM miner_leave
+		WHERE user_id = $1 AND start_date <= $3 AND end_date >= $2
+	`, minerID, req.StartDate, req.EndDate).Scan(&overlapping)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if overlapping != 0 {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":    "Leave overlaps leave already recorded for this miner",
+			"leave_id": overlapping,
+		})
+		return
+	}
+
+	var id int
+	err = database.DB.QueryRow(`
+		INSERT INTO miner_leave (user_id, start_date, end_date, leave_type, note, recorded_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id
+	`, minerID, req.StartDate, req.EndDate, req.LeaveType, req.Note, supervisorID).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording leave: "+err.Error())
+		return
+	}
+	go refreshLeaveCompliance(req.StartDate, req.EndDate)
+
+	rows, err := database.DB.Query(minerLeaveSelect+" WHERE l.id = $1", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	leave, err := scanMinerLeave(rows)
+	if err != nil || len(leave) == 0 {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching recorded leave")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, leave[0])
+}
+
+// GetCrewLeave - Leave of the supervisor's crew overlapping a date range, or of one miner
+// GET /api/miners/leave?from=2025-01-01&to=2025-01-31
+// GET /api/miners/{id}/leave
+func GetCrewLeave(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := minerLeaveSelect + " WHERE u.supervisor_id = $1"
+	args := []interface{}{supervisorID}
+	if minerID := mux.Vars(r)["id"]; minerID != "" {
+		args = append(args, minerID)
+		query += " AND l.user_id = $" + strconv.Itoa(len(args))
+	}
+	for _, bound := range []struct{ param, clause string }{
+		{"from", " AND l.end_date >= $"},
+		{"to", " AND l.start_date <= $"},
+	} {
+		v := r.URL.Query().Get(bound.param)
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			respondWithError(w, http.StatusBadRequest, bound.param+" must be YYYY-MM-DD")
+			return
+		}
+		args = append(args, v)
+		query += bound.clause + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY l.start_date DESC, u.name"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	leave, err := scanMinerLeave(rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"leave": leave,
+	})
+}
+
+// DeleteMinerLeave - Remove leave recorded by mistake or cancelled
+// DELETE /api/miners/leave/{leaveId}
+func DeleteMinerLeave(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	leaveID, err := strconv.Atoi(mux.Vars(r)["leaveId"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid leave ID")
+		return
+	}
+
+	var startDate, endDate string
+	err = database.DB.QueryRow(`
+		DELETE FROM miner_leave l USING users u
+		WHERE l.id = $1 AND u.user_id = l.user_id AND u.supervisor_id = $2
+		RETURNING TO_CHAR(l.start_date, 'YYYY-MM-DD'), TO_CHAR(l.end_date, 'YYYY-MM-DD')
+	`, leaveID, supervisorID).Scan(&startDate, &endDate)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Leave not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	go refreshLeaveCompliance(startDate, endDate)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Leave removed",
+	})
+}
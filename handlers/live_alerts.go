@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ==================== LIVE ALERTS ====================
+
+// Supervisors' dashboards keep a server-sent event stream open instead of polling
+// GET /api/emergencies. New emergencies, emergency status changes and PPE checks missing
+// mandatory items are pushed to every supervisor connected for the affected miner's site.
+
+// Live alert types, sent as the event name
+const (
+	LiveAlertEmergencyCreated = "emergency.created"
+	LiveAlertEmergencyStatus  = "emergency.status_changed"
+	LiveAlertPPENoncompliance = "ppe.noncompliance"
+)
+
+// liveAlertChannel carries alerts between instances when shared state is enabled
+const liveAlertChannel = "minesafe_live_alerts"
+
+const (
+	// liveKeepAliveInterval keeps proxies from closing an idle stream
+	liveKeepAliveInterval = 20 * time.Second
+	// liveClientBuffer is how many alerts a slow client can fall behind before some are dropped
+	liveClientBuffer = 32
+)
+
+// LiveAlert is one event on the stream
+type LiveAlert struct {
+	Type   string          `json:"type"`
+	SiteID int             `json:"site_id"`
+	Data   json.RawMessage `json:"data"`
+	At     time.Time       `json:"at"`
+}
+
+var (
+	liveClientsMu sync.Mutex
+	liveClients   = map[int]map[chan LiveAlert]bool{} // site ID -> open streams
+	// liveFanout is set when alerts are announced to every instance via Postgres
+	liveFanout bool
+)
+
+func addLiveClient(siteID int) chan LiveAlert {
+	ch := make(chan LiveAlert, liveClientBuffer)
+	liveClientsMu.Lock()
+	if liveClients[siteID] == nil {
+		liveClients[siteID] = map[chan LiveAlert]bool{}
+	}
+	liveClients[siteID][ch] = true
+	liveClientsMu.Unlock()
+	return ch
+}
+
+func removeLiveClient(siteID int, ch chan LiveAlert) {
+	liveClientsMu.Lock()
+	delete(liveClients[siteID], ch)
+	if len(liveClients[siteID]) == 0 {
+		delete(liveClients, siteID)
+	}
+	liveClientsMu.Unlock()
+}
+
+// publishLiveAlert pushes an alert about userID to the supervisors connected for their site,
+// on whichever instance holds the streams. Users without a site have no one to alert.
+func publishLiveAlert(userID, alertType string, data interface{}) {
+	var siteID sql.NullInt64
+	if err := database.DB.QueryRow("SELECT site_id FROM users WHERE user_id = $1", userID).Scan(&siteID); err != nil || !siteID.Valid {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s alert: %v", alertType, err)
+		return
+	}
+	alert := LiveAlert{Type: alertType, SiteID: int(siteID.Int64), Data: raw, At: time.Now()}
+
+	if liveFanout {
+		payload, _ := json.Marshal(alert)
+		if err := database.Publish(liveAlertChannel, string(payload)); err == nil {
+			return
+		}
+	}
+	deliverLiveAlert(alert)
+}
+
+// emergencyAlert is the part of an emergency pushed to dashboards, small enough for a
+// Postgres notification; the full record is at GET /api/emergencies/{id}
+func emergencyAlert(e *models.Emergency) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   e.ID,
+		"user_id":              e.UserID,
+		"severity":             e.Severity,
+		"issue":                truncate(e.Issue, 500),
+		"category":             e.Category,
+		"status":               e.Status,
+		"location":             e.Location,
+		"latitude":             e.Lat,
+		"longitude":            e.Lon,
+		"reporting_time":       e.IncidentReportingTime,
+		"primary_emergency_id": e.PrimaryEmergencyID,
+	}
+}
+
+// receiveLiveAlert delivers an alert published by any instance. The empty payload sent
+// after a listener reconnect is ignored; dashboards reload the emergency list on reconnect.
+func receiveLiveAlert(payload string) {
+	if payload == "" {
+		return
+	}
+	var alert LiveAlert
+	if err := json.Unmarshal([]byte(payload), &alert); err != nil {
+		log.Printf("Warning: ignoring malformed live alert: %v", err)
+		return
+	}
+	deliverLiveAlert(alert)
+}
+
+// deliverLiveAlert hands the alert to this instance's streams for its site. A stream whose
+// buffer is full misses the alert rather than holding up everyone else.
+func deliverLiveAlert(alert LiveAlert) {
+	liveClientsMu.Lock()
+	defer liveClientsMu.Unlock()
+	for ch := range liveClients[alert.SiteID] {
+		select {
+		case ch <- alert:
+		default:
+		}
+	}
+}
+
+// StreamLiveAlerts - Server-sent event stream of emergency and PPE alerts for the
+// supervisor's site. Each event is named by its type with the LiveAlert as data. The
+// stream ends when the session token expires; reconnect with a fresh one.
+// GET /api/ws
+func StreamLiveAlerts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	var siteID sql.NullInt64
+	if err := database.DB.QueryRow("SELECT site_id FROM users WHERE user_id = $1", userID).Scan(&siteID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !siteID.Valid {
+		respondWithError(w, http.StatusBadRequest, "Your account is not assigned to a mining site")
+		return
+	}
+	site := int(siteID.Int64)
+
+	expires := time.Hour
+	if token, ok := middleware.GetTokenFromContext(r.Context()); ok && !token.ExpiresAt.IsZero() {
+		expires = time.Until(token.ExpiresAt)
+	}
+	sessionEnd := time.NewTimer(expires)
+	defer sessionEnd.Stop()
+
+	ch := addLiveClient(site)
+	defer removeLiveClient(site, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 5000\nevent: ready\ndata: {\"site_id\":%d}\n\n", site)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(liveKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sessionEnd.C:
+			fmt.Fprint(w, "event: session_expired\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case alert := <-ch:
+			data, _ := json.Marshal(alert)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", alert.Type, data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== LOGIN AUDIT LOG ====================
+
+// Every login attempt, successful or not, is recorded with the caller's IP and user agent
+// so admins can look into suspicious access. Attempts on unknown accounts keep the email
+// that was tried; they belong to no organization, so only platform admins see them.
+
+// Login methods, i.e. which endpoint the attempt came through
+const (
+	LoginMethodPassword = "PASSWORD"
+	LoginMethodApp      = "APP"
+	LoginMethodAdmin    = "ADMIN"
+	LoginMethodSSO      = "SSO"
+)
+
+// Login outcomes
+const (
+	LoginSuccess         = "SUCCESS"
+	LoginUnknownAccount  = "UNKNOWN_ACCOUNT"
+	LoginBadPassword     = "BAD_PASSWORD"
+	LoginEmailUnverified = "EMAIL_UNVERIFIED"
+	LoginDenied          = "DENIED"
+	LoginDeactivated     = "DEACTIVATED"
+	LoginLockedOut       = "LOCKED_OUT"
+)
+
+// recordLoginEvent adds a login attempt to the audit log. userID and role are empty when the
+// account couldn't be identified.
+func recordLoginEvent(r *http.Request, method, email, userID, role, outcome string) {
+	_, err := database.DB.Exec(`
+		INSERT INTO login_events (user_id, email, role, method, outcome, ip_address, user_agent, org_id)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7,
+		        (SELECT org_id FROM users WHERE user_id = $1))
+	`, userID, truncate(models.NormalizeEmail(email), 255), role, method, outcome, clientIP(r), r.UserAgent())
+	if err != nil {
+		log.Printf("Warning: failed to record login event: %v", err)
+	}
+}
+
+// loginMethodFor names the login endpoint a guarded request was sent to
+func loginMethodFor(r *http.Request) string {
+	switch r.URL.Path {
+	case "/api/app/miner/login":
+		return LoginMethodApp
+	case "/api/admin/login":
+		return LoginMethodAdmin
+	}
+	return LoginMethodPassword
+}
+
+// InitLoginEventLog records attempts the login guard turns away while an account or IP is
+// locked out; the login handlers record everything else themselves
+func InitLoginEventLog() {
+	middleware.SetLockoutRecorder(func(r *http.Request, account string) {
+		var userID, role string
+		database.DB.QueryRow("SELECT user_id, role FROM users WHERE LOWER(email) = $1", account).Scan(&userID, &role)
+		recordLoginEvent(r, loginMethodFor(r), account, userID, role, LoginLockedOut)
+	})
+}
+
+// LoginEvent is one login attempt in the audit log
+type LoginEvent struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id,omitempty"`
+	UserName  string    `json:"user_name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Method    string    `json:"method"`
+	Outcome   string    `json:"outcome"`
+	Success   bool      `json:"success"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminGetLoginEvents - Login attempts, newest first. Filters: user_id, email, ip, role,
+// method, outcome, success=true|false, from/to (YYYY-MM-DD, inclusive); paged with page and limit.
+// GET /api/admin/login-events?outcome=BAD_PASSWORD&ip=10.0.0.7&page=1&limit=50
+func AdminGetLoginEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	args := []interface{}{}
+	where := " WHERE 1=1" + tenantClause(r, "e.org_id", &args)
+	for _, filter := range []struct {
+		param, column string
+		upper         bool
+	}{
+		{"user_id", "e.user_id", false},
+		{"ip", "e.ip_address", false},
+		{"role", "e.role", true},
+		{"method", "e.method", true},
+		{"outcome", "e.outcome", true},
+	} {
+		v := strings.TrimSpace(q.Get(filter.param))
+		if v == "" {
+			continue
+		}
+		if filter.upper {
+			v = strings.ToUpper(v)
+		}
+		args = append(args, v)
+		where += " AND " + filter.column + " = $" + strconv.Itoa(len(args))
+	}
+	if v := q.Get("email"); v != "" {
+		args = append(args, models.NormalizeEmail(v))
+		where += " AND e.email = $" + strconv.Itoa(len(args))
+	}
+	switch q.Get("success") {
+	case "true":
+		where += " AND e.outcome = '" + LoginSuccess + "'"
+	case "false":
+		where += " AND e.outcome <> '" + LoginSuccess + "'"
+	}
+	for _, bound := range []struct{ param, op, day string }{
+		{"from", ">=", "$%d::date"},
+		{"to", "<", "$%d::date + 1"},
+	} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			respondWithError(w, http.StatusBadRequest, bound.param+" must be YYYY-MM-DD")
+			return
+		}
+		args = append(args, v)
+		where += " AND e.created_at " + bound.op + " " + fmt.Sprintf(bound.day, len(args))
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM login_events e"+where, args...).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+	args = append(args, limit, (page-1)*limit)
+
+	rows, err := database.DB.Query(`
+		SELECT e.id, COALESCE(e.user_id, ''), COALESCE(u.name, ''), COALESCE(e.email, ''), COALESCE(e.role, ''),
+		       e.method, e.outcome, COALESCE(e.ip_address, ''), COALESCE(e.user_agent, ''), e.created_at
+		FROM login_events e
+		LEFT JOIN users u ON u.user_id = e.user_id`+where+`
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	events := []LoginEvent{}
+	for rows.Next() {
+		var e LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.UserName, &e.Email, &e.Role, &e.Method, &e.Outcome,
+			&e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		e.Success = e.Outcome == LoginSuccess
+		events = append(events, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+		"page":   page,
+		"limit":  limit,
+		"total":  total,
+	})
+}
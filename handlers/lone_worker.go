@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ==================== LONE WORKER CHECK-INS ====================
+
+// A miner working alone turns on lone-worker mode and the app checks in on a timer. Each
+// heartbeat pushes the next deadline out by the interval; if a deadline passes by more than
+// the grace period, the watchdog raises an emergency at the last location the app sent.
+
+const (
+	defaultCheckinIntervalMinutes = 30
+	defaultCheckinGraceMinutes    = 5
+	maxCheckinIntervalMinutes     = 4 * 60
+	maxCheckinGraceMinutes        = 60
+)
+
+// LoneWorkerSession is a miner's current stretch of lone-worker mode
+type LoneWorkerSession struct {
+	ID              int        `json:"id"`
+	UserID          string     `json:"user_id"`
+	UserName        string     `json:"user_name,omitempty"`
+	IntervalMinutes int        `json:"interval_minutes"`
+	GraceMinutes    int        `json:"grace_minutes"`
+	StartedAt       time.Time  `json:"started_at"`
+	LastCheckinAt   time.Time  `json:"last_checkin_at"`
+	NextDueAt       time.Time  `json:"next_due_at"`
+	Latitude        *float64   `json:"latitude,omitempty"`
+	Longitude       *float64   `json:"longitude,omitempty"`
+	Overdue         bool       `json:"overdue"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	MissedAt        *time.Time `json:"missed_at,omitempty"`
+	EmergencyID     *int       `json:"emergency_id,omitempty"`
+}
+
+// CheckinHeartbeatRequest represents the request body for a lone-worker check-in. The
+// interval and grace are only read when the check-in starts lone-worker mode.
+type CheckinHeartbeatRequest struct {
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
+	IntervalMinutes int      `json:"interval_minutes"`
+	GraceMinutes    int      `json:"grace_minutes"`
+}
+
+const loneWorkerColumns = `
+	s.id, s.user_id, u.name, s.interval_seconds, s.grace_seconds, s.started_at, s.last_checkin_at,
+	s.next_due_at, s.last_latitude, s.last_longitude, s.ended_at, s.missed_at, s.emergency_id`
+
+func scanLoneWorkerSession(row interface{ Scan(...interface{}) error }) (LoneWorkerSession, error) {
+	var s LoneWorkerSession
+	var intervalSeconds, graceSeconds int
+	var lat, lon sql.NullFloat64
+	var endedAt, missedAt sql.NullTime
+	var emergencyID sql.NullInt64
+	err := row.Scan(&s.ID, &s.UserID, &s.UserName, &intervalSeconds, &graceSeconds, &s.StartedAt,
+		&s.LastCheckinAt, &s.NextDueAt, &lat, &lon, &endedAt, &missedAt, &emergencyID)
+	if err != nil {
+		return s, err
+	}
+	s.IntervalMinutes, s.GraceMinutes = intervalSeconds/60, graceSeconds/60
+	if lat.Valid && lon.Valid {
+		s.Latitude, s.Longitude = &lat.Float64, &lon.Float64
+	}
+	if endedAt.Valid {
+		s.EndedAt = &endedAt.Time
+	}
+	if missedAt.Valid {
+		s.MissedAt = &missedAt.Time
+	}
+	if emergencyID.Valid {
+		id := int(emergencyID.Int64)
+		s.EmergencyID = &id
+	}
+	s.Overdue = s.EndedAt == nil && time.Now().After(s.NextDueAt)
+	return s, nil
+}
+
+// CheckinHeartbeat - Check in as a lone worker, starting lone-worker mode if it is off
+// POST /api/app/checkin/heartbeat
+func CheckinHeartbeat(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CheckinHeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.IntervalMinutes == 0 {
+		req.IntervalMinutes = defaultCheckinIntervalMinutes
+	}
+	if req.GraceMinutes == 0 {
+		req.GraceMinutes = defaultCheckinGraceMinutes
+	}
+	if req.IntervalMinutes < 1 || req.IntervalMinutes > maxCheckinIntervalMinutes {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("interval_minutes must be between 1 and %d", maxCheckinIntervalMinutes))
+		return
+	}
+	if req.GraceMinutes < 1 || req.GraceMinutes > maxCheckinGraceMinutes {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("grace_minutes must be between 1 and %d", maxCheckinGraceMinutes))
+		return
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		respondWithError(w, http.StatusBadRequest, "latitude and longitude must be sent together")
+		return
+	}
+
+	// Extend the active session, keeping the last location when this check-in has none
+	row := database.DB.QueryRow(`
+		WITH s AS (
+			UPDATE lone_worker_sessions SET
+				last_checkin_at = NOW(),
+				next_due_at = NOW() + interval_seconds * INTERVAL '1 second',
+				last_latitude = COALESCE($2, last_latitude),
+				last_longitude = COALESCE($3, last_longitude)
+			WHERE user_id = $1 AND ended_at IS NULL
+			RETURNING *
+		)
+		SELECT`+loneWorkerColumns+` FROM s JOIN users u ON u.user_id = s.user_id
+	`, userID, req.Latitude, req.Longitude)
+	session, err := scanLoneWorkerSession(row)
+	status := http.StatusOK
+	if err == sql.ErrNoRows {
+		// Not in lone-worker mode yet; a unique index keeps concurrent first check-ins to one session
+		row = database.DB.QueryRow(`
+			WITH s AS (
+				INSERT INTO lone_worker_sessions (user_id, interval_seconds, grace_seconds, next_due_at,
+				                                  last_latitude, last_longitude)
+				VALUES ($1, $2, $3, NOW() + $2 * INTERVAL '1 second', $4, $5)
+				RETURNING *
+			)
+			SELECT`+loneWorkerColumns+` FROM s JOIN users u ON u.user_id = s.user_id
+		`, userID, req.IntervalMinutes*60, req.GraceMinutes*60, req.Latitude, req.Longitude)
+		session, err = scanLoneWorkerSession(row)
+		status = http.StatusCreated
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording check-in")
+		return
+	}
+
+	respondWithJSON(w, status, session)
+}
+
+// GetMyCheckinStatus - Whether lone-worker mode is on and when the next check-in is due.
+// After a missed check-in this is the ended session, with the emergency it raised.
+// GET /api/app/checkin
+func GetMyCheckinStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// The active session, or else the latest one if it ended in a missed check-in
+	session, err := scanLoneWorkerSession(database.DB.QueryRow(`
+		SELECT`+loneWorkerColumns+`
+		FROM lone_worker_sessions s
+		JOIN users u ON u.user_id = s.user_id
+		WHERE s.user_id = $1
+		ORDER BY s.ended_at IS NULL DESC, s.started_at DESC
+		LIMIT 1
+	`, userID))
+	if err == sql.ErrNoRows || (err == nil && session.EndedAt != nil && session.MissedAt == nil) {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"active":  session.EndedAt == nil,
+		"session": session,
+	})
+}
+
+// EndCheckins - Turn off lone-worker mode
+// DELETE /api/app/checkin
+func EndCheckins(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE lone_worker_sessions SET ended_at = NOW() WHERE user_id = $1 AND ended_at IS NULL", userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Lone-worker mode is not on")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Lone-worker mode ended",
+	})
+}
+
+// GetCrewLoneWorkers - Crew members in lone-worker mode, overdue check-ins first
+// GET /api/supervisor/lone-workers
+func GetCrewLoneWorkers(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT`+loneWorkerColumns+`
+		FROM lone_worker_sessions s
+		JOIN users u ON u.user_id = s.user_id
+		WHERE u.supervisor_id = $1 AND s.ended_at IS NULL
+		ORDER BY s.next_due_at
+	`, supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	sessions := []LoneWorkerSession{}
+	for rows.Next() {
+		s, err := scanLoneWorkerSession(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		sessions = append(sessions, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"lone_workers": sessions,
+	})
+}
+
+// StartLoneWorkerWatchdog periodically raises emergencies for missed lone-worker check-ins
+func StartLoneWorkerWatchdog(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			raiseMissedCheckins()
+			<-ticker.C
+		}
+	}()
+}
+
+func raiseMissedCheckins() {
+	// Ending the session in the same statement means a session is only ever raised once,
+	// even with several instances running the watchdog
+	rows, err := database.DB.Query(`
+		UPDATE lone_worker_sessions SET missed_at = NOW(), ended_at = NOW()
+		WHERE ended_at IS NULL AND next_due_at + grace_seconds * INTERVAL '1 second' < NOW()
+		RETURNING id, user_id, last_checkin_at, last_latitude, last_longitude
+	`)
+	if err != nil {
+		log.Printf("Warning: lone worker watchdog failed: %v", err)
+		return
+	}
+	type missed struct {
+		sessionID   int
+		userID      string
+		lastCheckin time.Time
+		lat, lon    sql.NullFloat64
+	}
+	var sessions []missed
+	for rows.Next() {
+		var m missed
+		if err := rows.Scan(&m.sessionID, &m.userID, &m.lastCheckin, &m.lat, &m.lon); err == nil {
+			sessions = append(sessions, m)
+		}
+	}
+	rows.Close()
+
+	for _, m := range sessions {
+		emergencyID, err := raiseMissedCheckinEmergency(m.userID, m.lastCheckin, m.lat.Float64, m.lon.Float64)
+		if err != nil {
+			log.Printf("Warning: failed to raise emergency for missed check-in (session %d): %v", m.sessionID, err)
+			continue
+		}
+		database.DB.Exec("UPDATE lone_worker_sessions SET emergency_id = $2 WHERE id = $1", m.sessionID, emergencyID)
+	}
+}
+
+// raiseMissedCheckinEmergency files an emergency on the miner's behalf at their last known
+// location and alerts their supervisor and the site's admins
+func raiseMissedCheckinEmergency(userID string, lastCheckin time.Time, lat, lon float64) (int, error) {
+	issue := fmt.Sprintf("Missed lone-worker check-in; last check-in at %s UTC", lastCheckin.UTC().Format("15:04"))
+	emergencyID, err := raiseEmergencyFor(userID, "HIGH", issue, lat, lon)
+	if err != nil {
+		return 0, err
+	}
+	notifyCrewLeads(userID, "LONE_WORKER_MISSED", "Missed lone-worker check-in",
+		"%s missed a lone-worker check-in; emergency #%d raised at their last known location", emergencyID)
+	return emergencyID, nil
+}
+
+// notifyCrewLeads alerts a miner's supervisor and their site's admins about an emergency
+// raised for them; format takes the miner's name and the emergency's ID
+func notifyCrewLeads(userID, notifType, title, format string, emergencyID int) {
+	var name, supervisorID, site string
+	database.DB.QueryRow(
+		"SELECT name, COALESCE(supervisor_id, ''), COALESCE(mining_site, '') FROM users WHERE user_id = $1", userID,
+	).Scan(&name, &supervisorID, &site)
+	message := fmt.Sprintf(format, name, emergencyID)
+	notifyUser(supervisorID, notifType, title, message, "emergency", strconv.Itoa(emergencyID))
+	for _, adminID := range siteAdmins(site) {
+		notifyUser(adminID, notifType, title, message, "emergency", strconv.Itoa(emergencyID))
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ==================== MAINTENANCE MODE ====================
+
+const maintenanceSettingKey = "maintenance"
+
+// loadMaintenanceState reads the persisted maintenance flag so every instance agrees on it
+func loadMaintenanceState() (middleware.MaintenanceState, error) {
+	var state middleware.MaintenanceState
+	var value []byte
+	err := database.DB.QueryRow("SELECT value FROM system_settings WHERE key = $1", maintenanceSettingKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(value, &state)
+	return state, err
+}
+
+// StartMaintenanceSync periodically refreshes the maintenance flag from the database
+func StartMaintenanceSync(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			state, err := loadMaintenanceState()
+			if err != nil {
+				log.Printf("Warning: failed to load maintenance state: %v", err)
+			} else {
+				middleware.SetMaintenanceState(state)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// GetMaintenanceMode - Current maintenance state
+// GET /api/admin/maintenance
+func GetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, middleware.GetMaintenanceState())
+}
+
+// SetMaintenanceModeRequest represents the request body for toggling maintenance mode
+type SetMaintenanceModeRequest struct {
+	Enabled         bool   `json:"enabled"`
+	Message         string `json:"message"`
+	DurationMinutes int    `json:"duration_minutes"` // optional, used for Retry-After
+}
+
+// SetMaintenanceMode - Put the API into (or take it out of) read-only maintenance mode
+// PUT /api/admin/maintenance
+func SetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req SetMaintenanceModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	state := middleware.MaintenanceState{Enabled: req.Enabled}
+	if req.Enabled {
+		now := time.Now()
+		state.StartedAt = &now
+		state.Message = req.Message
+		if state.Message == "" {
+			state.Message = "MineSafe is undergoing maintenance. Data can be viewed but not changed; emergency reporting remains available."
+		}
+		if req.DurationMinutes > 0 {
+			end := now.Add(time.Duration(req.DurationMinutes) * time.Minute)
+			state.ExpectedEnd = &end
+		}
+	}
+
+	value, _ := json.Marshal(state)
+	_, err := database.DB.Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, maintenanceSettingKey, value, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving maintenance state: "+err.Error())
+		return
+	}
+
+	middleware.SetMaintenanceState(state)
+	broadcastInvalidation(cacheMaintenance)
+	log.Printf("Maintenance mode set to %v by %s", state.Enabled, adminID)
+
+	respondWithJSON(w, http.StatusOK, state)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"net/http"
+)
+
+// ==================== TOPIC MASTERY ====================
+
+// TopicMastery is a miner's quiz performance across all modules tagged with a topic
+type TopicMastery struct {
+	Topic          string  `json:"topic"`
+	Score          float64 `json:"score"` // percent of questions answered correctly
+	CorrectAnswers int     `json:"correct_answers"`
+	TotalQuestions int     `json:"total_questions"`
+	Modules        int     `json:"modules"`
+	Weak           bool    `json:"weak"`
+}
+
+// loadTopicMastery aggregates the miner's latest result for each module by the module's
+// tags, weakest topic first. A topic is weak when its score is below the passing percent.
+func loadTopicMastery(minerID string, passingPercent float64) ([]TopicMastery, error) {
+	rows, err := database.DB.Query(`
+		WITH latest AS (
+			SELECT DISTINCT ON (video_id) video_id, score, total_questions
+			FROM module_completions
+			WHERE miner_id = $1 AND total_questions > 0
+			ORDER BY video_id, completed_at DESC
+		)
+		SELECT tag, SUM(l.score), SUM(l.total_questions), COUNT(*)
+		FROM latest l
+		JOIN video_modules vm ON vm.id = l.video_id
+		CROSS JOIN LATERAL jsonb_array_elements_text(COALESCE(vm.tags, '[]'::jsonb)) AS tag
+		GROUP BY tag
+		ORDER BY SUM(l.score)::float / SUM(l.total_questions), tag
+	`, minerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []TopicMastery{}
+	for rows.Next() {
+		var t TopicMastery
+		if err := rows.Scan(&t.Topic, &t.CorrectAnswers, &t.TotalQuestions, &t.Modules); err != nil {
+			return nil, err
+		}
+		t.Score = float64(t.CorrectAnswers) / float64(t.TotalQuestions) * 100
+		t.Weak = t.Score < passingPercent
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+// weakTopics returns the topics the miner is below the passing percent in, weakest first
+func weakTopics(minerID string) []string {
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		policy = defaultRetakePolicy
+	}
+	topics, err := loadTopicMastery(minerID, policy.PassingPercent)
+	if err != nil {
+		return nil
+	}
+	weak := []string{}
+	for _, t := range topics {
+		if t.Weak {
+			weak = append(weak, t.Topic)
+		}
+	}
+	return weak
+}
+
+// GetMyMastery - Quiz performance per topic for the current user
+// GET /api/app/mastery
+func GetMyMastery(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading retake policy")
+		return
+	}
+
+	topics, err := loadTopicMastery(userID, policy.PassingPercent)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	weak := []string{}
+	for _, t := range topics {
+		if t.Weak {
+			weak = append(weak, t.Topic)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"topics":          topics,
+		"weak_topics":     weak,
+		"passing_percent": policy.PassingPercent,
+	})
+}
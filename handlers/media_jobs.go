@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== MEDIA PIPELINE STATUS ====================
+
+// The media pipeline reports each transcoding, thumbnail and caption job it runs for an
+// uploaded video, keyed by the original's /uploads/ path like renditions are. Uploaders
+// can then see what is still running or failed instead of a video silently playing
+// without renditions.
+
+// Media job types
+const (
+	MediaJobTranscode = "TRANSCODE"
+	MediaJobThumbnail = "THUMBNAIL"
+	MediaJobCaptions  = "CAPTIONS"
+)
+
+// Media job states
+const (
+	MediaJobQueued    = "QUEUED"
+	MediaJobRunning   = "RUNNING"
+	MediaJobSucceeded = "SUCCEEDED"
+	MediaJobFailed    = "FAILED"
+)
+
+// Overall processing state of a video
+const (
+	MediaProcessingNotApplicable = "not_applicable" // linked rather than uploaded
+	MediaProcessingPending       = "pending"        // nothing reported by the pipeline yet
+	MediaProcessingRunning       = "processing"
+	MediaProcessingFailed        = "failed"
+	MediaProcessingReady         = "ready"
+)
+
+var mediaJobTypes = map[string]bool{MediaJobTranscode: true, MediaJobThumbnail: true, MediaJobCaptions: true}
+
+var mediaJobStates = map[string]bool{MediaJobQueued: true, MediaJobRunning: true, MediaJobSucceeded: true, MediaJobFailed: true}
+
+// MediaJob is one pipeline job for a source file
+type MediaJob struct {
+	JobType    string     `json:"job_type"`
+	Quality    string     `json:"quality,omitempty"`
+	Status     string     `json:"status"`
+	Progress   int        `json:"progress"`
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// MediaJobRequest is a job status update from the media pipeline
+type MediaJobRequest struct {
+	SourceURL string `json:"source_url"`
+	JobType   string `json:"job_type"`
+	Quality   string `json:"quality"` // rendition quality for transcode jobs
+	Status    string `json:"status"`
+	Progress  int    `json:"progress"` // 0-100
+	Error     string `json:"error"`
+}
+
+// recordMediaJob upserts a job's state. Moving back to QUEUED or RUNNING after a finish
+// counts as another attempt.
+func recordMediaJob(source string, req MediaJobRequest) error {
+	progress := req.Progress
+	if req.Status == MediaJobSucceeded {
+		progress = 100
+	}
+	_, err := database.DB.Exec(`
+		INSERT INTO media_jobs (source_url, job_type, quality, status, progress, error, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''),
+		        CASE WHEN $4 <> 'QUEUED' THEN NOW() END,
+		        CASE WHEN $4 IN ('SUCCEEDED', 'FAILED') THEN NOW() END)
+		ON CONFLICT (source_url, job_type, quality) DO UPDATE SET
+			status = EXCLUDED.status,
+			progress = EXCLUDED.progress,
+			error = EXCLUDED.error,
+			attempts = media_jobs.attempts + CASE WHEN media_jobs.finished_at IS NOT NULL
+			           AND EXCLUDED.status IN ('QUEUED', 'RUNNING') THEN 1 ELSE 0 END,
+			started_at = CASE WHEN EXCLUDED.status = 'QUEUED' THEN NULL
+			                  WHEN media_jobs.started_at IS NULL OR media_jobs.finished_at IS NOT NULL THEN NOW()
+			                  ELSE media_jobs.started_at END,
+			finished_at = EXCLUDED.finished_at,
+			updated_at = NOW()
+	`, source, req.JobType, req.Quality, req.Status, progress, truncate(req.Error, 2000))
+	return err
+}
+
+// ReportMediaJob - The media pipeline reports a job's progress, completion or failure.
+// Like renditions, only the pipeline's platform admin account may report.
+// PUT /api/admin/media/jobs
+func ReportMediaJob(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	var req MediaJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.JobType = strings.ToUpper(strings.TrimSpace(req.JobType))
+	req.Status = strings.ToUpper(strings.TrimSpace(req.Status))
+	req.Quality = strings.ToLower(strings.TrimSpace(req.Quality))
+	if !mediaJobTypes[req.JobType] {
+		respondWithError(w, http.StatusBadRequest, "job_type must be TRANSCODE, THUMBNAIL or CAPTIONS")
+		return
+	}
+	if !mediaJobStates[req.Status] {
+		respondWithError(w, http.StatusBadRequest, "status must be QUEUED, RUNNING, SUCCEEDED or FAILED")
+		return
+	}
+	if req.JobType == MediaJobTranscode {
+		if _, ok := renditionPreference[req.Quality]; !ok {
+			respondWithError(w, http.StatusBadRequest, "quality must be low or medium for a transcode job")
+			return
+		}
+	} else {
+		req.Quality = ""
+	}
+	if req.Progress < 0 || req.Progress > 100 {
+		respondWithError(w, http.StatusBadRequest, "progress must be between 0 and 100")
+		return
+	}
+	if req.Status == MediaJobFailed && strings.TrimSpace(req.Error) == "" {
+		respondWithError(w, http.StatusBadRequest, "error is required for a failed job")
+		return
+	}
+	source := renditionKey(req.SourceURL)
+	if source == "" {
+		respondWithError(w, http.StatusBadRequest, "source_url is required")
+		return
+	}
+
+	if err := recordMediaJob(source, req); err != nil {
+		log.Printf("Failed to record %s job for %s: %v", req.JobType, source, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"source_url": source,
+		"job_type":   req.JobType,
+		"status":     req.Status,
+	})
+}
+
+// mediaProcessingState sums up a video's jobs: any failure, then anything still running,
+// decides the state; renditions without job reports count as ready
+func mediaProcessingState(jobs []MediaJob, renditions int) string {
+	state := MediaProcessingPending
+	if renditions > 0 {
+		state = MediaProcessingReady
+	}
+	for _, job := range jobs {
+		switch job.Status {
+		case MediaJobFailed:
+			return MediaProcessingFailed
+		case MediaJobQueued, MediaJobRunning:
+			state = MediaProcessingRunning
+		case MediaJobSucceeded:
+			if state == MediaProcessingPending {
+				state = MediaProcessingReady
+			}
+		}
+	}
+	return state
+}
+
+// GetVideoProcessingStatus - Transcoding, thumbnail and caption progress for a video, with
+// errors and the renditions available so far. Visible to the uploader and admins.
+// GET /api/videos/{id}/processing-status
+func GetVideoProcessingStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID")
+		return
+	}
+
+	args := []interface{}{videoID}
+	scope := tenantClause(r, "org_id", &args)
+	var title, videoURL string
+	var createdBy sql.NullString
+	err = database.DB.QueryRow(
+		"SELECT title, video_url, created_by FROM video_modules WHERE id = $1"+scope, args...,
+	).Scan(&title, &videoURL, &createdBy)
+	if err == nil && role != string(models.RoleAdmin) && createdBy.String != userID {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Video not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	source, uploaded := storedUploadPath(videoURL)
+	if !uploaded {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"video_id":   videoID,
+			"title":      title,
+			"status":     MediaProcessingNotApplicable,
+			"jobs":       []MediaJob{},
+			"renditions": []string{},
+		})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT job_type, quality, status, progress, COALESCE(error, ''), attempts, started_at, finished_at, updated_at
+		FROM media_jobs WHERE source_url = $1
+		ORDER BY job_type, quality
+	`, source)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+	jobs := []MediaJob{}
+	for rows.Next() {
+		var job MediaJob
+		var started, finished sql.NullTime
+		if err := rows.Scan(&job.JobType, &job.Quality, &job.Status, &job.Progress, &job.Error, &job.Attempts,
+			&started, &finished, &job.UpdatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning job data: "+err.Error())
+			return
+		}
+		if started.Valid {
+			job.StartedAt = &started.Time
+		}
+		if finished.Valid {
+			job.FinishedAt = &finished.Time
+		}
+		jobs = append(jobs, job)
+	}
+
+	renditions := []string{}
+	qualityRows, err := database.DB.Query("SELECT quality FROM media_renditions WHERE source_url = $1 ORDER BY quality", source)
+	if err == nil {
+		defer qualityRows.Close()
+		for qualityRows.Next() {
+			var quality string
+			if qualityRows.Scan(&quality) == nil {
+				renditions = append(renditions, quality)
+			}
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"video_id":   videoID,
+		"title":      title,
+		"status":     mediaProcessingState(jobs, len(renditions)),
+		"jobs":       jobs,
+		"renditions": renditions,
+	})
+}
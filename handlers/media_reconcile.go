@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ==================== EMERGENCY MEDIA RECONCILIATION ====================
+
+const (
+	// mediaRetryAfter is how long a pending upload may sit before the reporter is reminded,
+	// and the gap between reminders
+	mediaRetryAfter = 30 * time.Minute
+	// mediaMaxReminders caps retry reminders per emergency
+	mediaMaxReminders = 3
+	// mediaExpiryAfter is when promised media is given up on
+	mediaExpiryAfter = 24 * time.Hour
+)
+
+// StartMediaReconciliationJob periodically reminds reporters to retry media uploads that are
+// pending or failed, and expires ones that never arrived
+func StartMediaReconciliationJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			reconcileEmergencyMedia()
+			<-ticker.C
+		}
+	}()
+}
+
+func reconcileEmergencyMedia() {
+	// Expire first so nobody is reminded about an upload that is being given up on
+	rows, err := database.DB.Query(`
+		UPDATE emergencies e SET media_status = $1, media_status_updated_at = NOW()
+		FROM users u
+		WHERE e.user_id = u.user_id AND e.media_status IN ($2, $3)
+		AND COALESCE(e.media_status_updated_at, e.reporting_time) <= NOW() - $4 * INTERVAL '1 second'
+		RETURNING e.id, e.user_id, COALESCE(u.supervisor_id, '')
+	`, models.StatusUploadExpired, models.StatusPendingUpload, models.StatusUploadFailed, mediaExpiryAfter.Seconds())
+	if err != nil {
+		log.Printf("Warning: media reconciliation failed: %v", err)
+		return
+	}
+	for rows.Next() {
+		var id int
+		var userID, supervisorID string
+		if err := rows.Scan(&id, &userID, &supervisorID); err != nil {
+			continue
+		}
+		ref := strconv.Itoa(id)
+		notifyUser(userID, "MEDIA_UPLOAD_EXPIRED", "Media upload expired",
+			fmt.Sprintf("Photos or video for emergency #%d were never received", id), "emergency", ref)
+		notifyUser(supervisorID, "MEDIA_UPLOAD_EXPIRED", "Media upload expired",
+			fmt.Sprintf("Emergency #%d is missing the media its reporter promised", id), "emergency", ref)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		UPDATE emergencies SET media_reminders = media_reminders + 1, media_reminded_at = NOW()
+		WHERE media_status IN ($1, $2) AND media_reminders < $3
+		AND COALESCE(media_reminded_at, media_status_updated_at, reporting_time) <= NOW() - $4 * INTERVAL '1 second'
+		RETURNING id, user_id, media_status
+	`, models.StatusPendingUpload, models.StatusUploadFailed, mediaMaxReminders, mediaRetryAfter.Seconds())
+	if err != nil {
+		log.Printf("Warning: media reconciliation failed: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var userID, status string
+		if err := rows.Scan(&id, &userID, &status); err != nil {
+			continue
+		}
+		message := fmt.Sprintf("Photos or video for emergency #%d have not been uploaded yet. Open the report to retry.", id)
+		if status == string(models.StatusUploadFailed) {
+			message = fmt.Sprintf("Uploading media for emergency #%d failed. Open the report to retry.", id)
+		}
+		notifyUser(userID, "MEDIA_UPLOAD_RETRY", "Retry media upload", message, "emergency", strconv.Itoa(id))
+	}
+}
+
+// MissingMediaEmergency is an emergency whose reporter promised media that has not arrived
+type MissingMediaEmergency struct {
+	ID                   int       `json:"id"`
+	UserID               string    `json:"user_id"`
+	UserName             string    `json:"user_name"`
+	Severity             string    `json:"severity"`
+	Issue                string    `json:"issue"`
+	MediaStatus          string    `json:"media_status"`
+	Reminders            int       `json:"reminders"`
+	ReportingTime        time.Time `json:"reporting_time"`
+	MediaStatusUpdatedAt time.Time `json:"media_status_updated_at"`
+}
+
+// GetMissingMediaEmergencies - Emergencies from this supervisor's miners still missing media
+// GET /api/supervisor/emergencies/missing-media?include_expired=true
+func GetMissingMediaEmergencies(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	statuses := []string{string(models.StatusPendingUpload), string(models.StatusUploadFailed)}
+	if r.URL.Query().Get("include_expired") == "true" {
+		statuses = append(statuses, string(models.StatusUploadExpired))
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT e.id, e.user_id, u.name, COALESCE(e.severity, ''), COALESCE(e.issue, ''), e.media_status,
+			COALESCE(e.media_reminders, 0), e.reporting_time, COALESCE(e.media_status_updated_at, e.reporting_time)
+		FROM emergencies e
+		JOIN users u ON e.user_id = u.user_id
+		WHERE u.supervisor_id = $1 AND e.media_status = ANY($2)
+		ORDER BY e.reporting_time DESC
+	`, supervisorID, pq.Array(statuses))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	emergencies := []MissingMediaEmergency{}
+	for rows.Next() {
+		var e MissingMediaEmergency
+		err := rows.Scan(&e.ID, &e.UserID, &e.UserName, &e.Severity, &e.Issue, &e.MediaStatus,
+			&e.Reminders, &e.ReportingTime, &e.MediaStatusUpdatedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning emergency data")
+			return
+		}
+		emergencies = append(emergencies, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, emergencies)
+}
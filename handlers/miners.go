@@ -7,12 +7,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func CreateMiner(w http.ResponseWriter, r *http.Request) {
+	createCrewMember(w, r, models.RoleMiner)
+}
+
+// createCrewMember adds a miner or operator to the calling supervisor's crew, at their site
+func createCrewMember(w http.ResponseWriter, r *http.Request, role models.Role) {
 	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
@@ -30,44 +35,48 @@ func CreateMiner(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", minerData.Email).Scan(&exists)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Database error")
-		return
+	// Handle both phone and phone_number fields
+	phone := minerData.Phone
+	if phone == "" && minerData.PhoneNumber != "" {
+		phone = minerData.PhoneNumber
 	}
-	if exists {
-		respondWithError(w, http.StatusConflict, "Email already registered")
+
+	if code, msg := checkContactAvailable(&minerData.Email, &phone, ""); code != 0 {
+		respondWithError(w, code, msg)
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(minerData.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(minerData.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error processing password")
 		return
 	}
 
-	// Handle both phone and phone_number fields
-	phone := minerData.Phone
-	if phone == "" && minerData.PhoneNumber != "" {
-		phone = minerData.PhoneNumber
+	miner, code, msg := insertCrewMember(supervisorID, minerData.Name, minerData.Email, phone, string(hashedPassword), role)
+	if code != 0 {
+		respondWithError(w, code, msg)
+		return
 	}
 
+	miner.Password = ""
+	respondWithJSON(w, http.StatusCreated, miner)
+}
+
+// insertCrewMember stores a new crew member at the supervisor's site and starts their
+// induction. Contact details must already have been checked with checkContactAvailable.
+func insertCrewMember(supervisorID, name, email, phone, hashedPassword string, role models.Role) (*models.User, int, string) {
 	var miningSite, location string
-	err = database.DB.QueryRow(
+	err := database.DB.QueryRow(
 		"SELECT mining_site, location FROM users WHERE user_id = $1",
 		supervisorID,
 	).Scan(&miningSite, &location)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error fetching supervisor details")
-		return
+		return nil, http.StatusInternalServerError, "Error fetching supervisor details"
 	}
 
-	miner, err := models.NewUser(minerData.Name, minerData.Email, phone, 
-		string(hashedPassword), miningSite, location, models.RoleMiner, &supervisorID)
+	miner, err := models.NewUser(name, email, phone, hashedPassword, miningSite, location, role, &supervisorID)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
+		return nil, http.StatusBadRequest, err.Error()
 	}
 
 	err = database.DB.QueryRow(
@@ -77,14 +86,12 @@ func CreateMiner(w http.ResponseWriter, r *http.Request) {
 		miner.UserID, miner.Name, miner.Email, miner.Phone, miner.Password, miner.Role,
 		miner.MiningSite, miner.Location, miner.SupervisorID, miner.CreatedAt, miner.UpdatedAt,
 	).Scan(&miner.ID)
-
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating miner: "+err.Error())
-		return
+		return nil, http.StatusInternalServerError, "Error creating " + strings.ToLower(string(role)) + ": " + err.Error()
 	}
 
-	miner.Password = ""
-	respondWithJSON(w, http.StatusCreated, miner)
+	createMinerInduction(miner.UserID)
+	return miner, 0, ""
 }
 
 func GetMiners(w http.ResponseWriter, r *http.Request) {
@@ -96,8 +103,8 @@ func GetMiners(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := database.DB.Query(
 		`SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at
-		 FROM users WHERE supervisor_id = $1 ORDER BY created_at DESC`,
-		supervisorID,
+		 FROM users WHERE supervisor_id = ANY($1) AND deleted_at IS NULL ORDER BY created_at DESC`,
+		managedSupervisors(supervisorID),
 	)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
@@ -133,8 +140,8 @@ func GetMiner(w http.ResponseWriter, r *http.Request) {
 	var miner models.User
 	err := database.DB.QueryRow(
 		`SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at
-		 FROM users WHERE user_id = $1 AND supervisor_id = $2`,
-		minerID, supervisorID,
+		 FROM users WHERE user_id = $1 AND supervisor_id = ANY($2) AND deleted_at IS NULL`,
+		minerID, managedSupervisors(supervisorID),
 	).Scan(&miner.ID, &miner.UserID, &miner.Name, &miner.Email, &miner.Phone,
 		&miner.Role, &miner.MiningSite, &miner.Location, &miner.SupervisorID, &miner.CreatedAt, &miner.UpdatedAt)
 
@@ -174,8 +181,8 @@ func UpdateMiner(w http.ResponseWriter, r *http.Request) {
 
 	var exists bool
 	err := database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND supervisor_id = $2)",
-		minerID, supervisorID,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND supervisor_id = ANY($2) AND deleted_at IS NULL)",
+		minerID, managedSupervisors(supervisorID),
 	).Scan(&exists)
 	if err != nil || !exists {
 		respondWithError(w, http.StatusNotFound, "Miner not found")
@@ -187,10 +194,15 @@ func UpdateMiner(w http.ResponseWriter, r *http.Request) {
 		phone = updateData.PhoneNumber
 	}
 
+	if code, msg := checkContactAvailable(&updateData.Email, &phone, minerID); code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+
 	result, err := database.DB.Exec(
 		`UPDATE users SET name = $1, email = $2, phone = $3, updated_at = NOW()
-		 WHERE user_id = $4 AND supervisor_id = $5`,
-		updateData.Name, updateData.Email, phone, minerID, supervisorID,
+		 WHERE user_id = $4 AND supervisor_id = ANY($5)`,
+		updateData.Name, updateData.Email, phone, minerID, managedSupervisors(supervisorID),
 	)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating miner")
@@ -229,17 +241,14 @@ func DeleteMiner(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	minerID := vars["id"]
 
-	result, err := database.DB.Exec(
-		"DELETE FROM users WHERE user_id = $1 AND supervisor_id = $2",
-		minerID, supervisorID,
-	)
+	// Soft delete, so completions and emergencies keep their miner
+	deleted, err := softDeleteUser(supervisorID, "user_id = $1 AND supervisor_id = $2", minerID, supervisorID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error deleting miner")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !deleted {
 		respondWithError(w, http.StatusNotFound, "Miner not found")
 		return
 	}
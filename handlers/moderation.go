@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== CONTENT MODERATION ====================
+
+// User-generated content that can be reported and moderated
+const (
+	ContentTypeVideo          = "VIDEO"
+	ContentTypeProfilePicture = "PROFILE_PICTURE"
+)
+
+// Moderation actions
+const (
+	ModerationRemove  = "remove"
+	ModerationRestore = "restore"
+	ModerationWarn    = "warn"
+)
+
+// maxModerationBatch bounds one bulk action
+const maxModerationBatch = 100
+
+// ModerationItem is one entry in the admin moderation queue
+type ModerationItem struct {
+	ContentType  string     `json:"content_type"`
+	ContentID    string     `json:"content_id"`
+	Title        string     `json:"title"`
+	MediaURL     string     `json:"media_url,omitempty"`
+	UploaderID   string     `json:"uploader_id"`
+	UploaderName string     `json:"uploader_name"`
+	MiningSite   string     `json:"mining_site"`
+	Reason       string     `json:"reason"` // REPORTED or REJECTED
+	State        string     `json:"state"`
+	ReportCount  int        `json:"report_count"`
+	Reports      []string   `json:"report_reasons"`
+	LastActivity *time.Time `json:"last_activity"`
+}
+
+// ModerationAction is an audit record of one admin action on one piece of content
+type ModerationAction struct {
+	ID          int       `json:"id"`
+	AdminID     string    `json:"admin_id"`
+	AdminName   string    `json:"admin_name"`
+	Action      string    `json:"action"`
+	ContentType string    `json:"content_type"`
+	ContentID   string    `json:"content_id"`
+	UploaderID  string    `json:"uploader_id"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReportContentRequest represents the request body for reporting content
+type ReportContentRequest struct {
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id"`
+	Reason      string `json:"reason"`
+}
+
+// ReportContent - Any user reports a video or profile picture for moderation
+// POST /api/content/report
+func ReportContent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ReportContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.ContentType = strings.ToUpper(req.ContentType)
+	if _, err := contentUploader(req.ContentType, req.ContentID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO content_reports (content_type, content_id, reported_by, reason, status, created_at)
+		VALUES ($1, $2, $3, $4, 'OPEN', NOW())
+		ON CONFLICT (content_type, content_id, reported_by) WHERE status = 'OPEN' DO NOTHING
+	`, req.ContentType, req.ContentID, userID, req.Reason)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving report: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": "Thanks, an admin will review this content",
+	})
+}
+
+// contentUploader returns who uploaded a piece of content
+func contentUploader(contentType, contentID string) (string, error) {
+	var uploader sql.NullString
+	var err error
+	switch contentType {
+	case ContentTypeVideo:
+		err = database.DB.QueryRow("SELECT created_by FROM video_modules WHERE id::text = $1", contentID).Scan(&uploader)
+	case ContentTypeProfilePicture:
+		err = database.DB.QueryRow("SELECT user_id FROM users WHERE user_id = $1", contentID).Scan(&uploader)
+	default:
+		return "", sql.ErrNoRows
+	}
+	return uploader.String, err
+}
+
+// AdminGetModerationQueue - Reported videos and profile pictures plus rejected modules
+// across the organization's sites, most recent first
+// GET /api/admin/moderation?type=VIDEO&site=North%20Pit
+func AdminGetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	contentType := strings.ToUpper(r.URL.Query().Get("type"))
+	site := r.URL.Query().Get("site")
+
+	args := []interface{}{}
+	videoScope := tenantClause(r, "vm.org_id", &args)
+	pictureScope := tenantClause(r, "u.org_id", &args)
+	rows, err := database.DB.Query(`
+		WITH reported AS (
+			SELECT content_type, content_id, COUNT(*) AS reports,
+			       jsonb_agg(COALESCE(reason, '')) AS reasons, MAX(created_at) AS last_report
+			FROM content_reports WHERE status = 'OPEN'
+			GROUP BY content_type, content_id
+		)
+		SELECT 'VIDEO', vm.id::text, vm.title, vm.video_url, COALESCE(vm.created_by, ''),
+		       COALESCE(u.name, ''), COALESCE(u.mining_site, ''),
+		       CASE WHEN rp.reports IS NULL THEN 'REJECTED' ELSE 'REPORTED' END,
+		       COALESCE(vm.approval_status, 'approved'), COALESCE(rp.reports, 0),
+		       COALESCE(rp.reasons, '[]'::jsonb), COALESCE(rp.last_report, vm.reviewed_at, vm.updated_at)
+		FROM video_modules vm
+		LEFT JOIN users u ON vm.created_by = u.user_id
+		LEFT JOIN reported rp ON rp.content_type = 'VIDEO' AND rp.content_id = vm.id::text
+		WHERE (rp.reports IS NOT NULL OR vm.approval_status = 'rejected')`+videoScope+`
+		UNION ALL
+		SELECT 'PROFILE_PICTURE', u.user_id, u.name, COALESCE(u.profile_picture_url, ''), u.user_id,
+		       u.name, COALESCE(u.mining_site, ''), 'REPORTED',
+		       CASE WHEN u.profile_picture_url IS NULL THEN 'removed' ELSE 'visible' END, rp.reports,
+		       rp.reasons, rp.last_report
+		FROM reported rp
+		JOIN users u ON rp.content_type = 'PROFILE_PICTURE' AND rp.content_id = u.user_id
+		WHERE 1=1`+pictureScope+`
+		ORDER BY 12 DESC
+		LIMIT 500
+	`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	items := []ModerationItem{}
+	for rows.Next() {
+		var item ModerationItem
+		var reasonsJSON []byte
+		var lastActivity sql.NullTime
+		if err := rows.Scan(&item.ContentType, &item.ContentID, &item.Title, &item.MediaURL, &item.UploaderID,
+			&item.UploaderName, &item.MiningSite, &item.Reason, &item.State, &item.ReportCount,
+			&reasonsJSON, &lastActivity); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning queue: "+err.Error())
+			return
+		}
+		if contentType != "" && item.ContentType != contentType {
+			continue
+		}
+		if site != "" && item.MiningSite != site {
+			continue
+		}
+		json.Unmarshal(reasonsJSON, &item.Reports)
+		if item.Reports == nil {
+			item.Reports = []string{}
+		}
+		if lastActivity.Valid {
+			item.LastActivity = &lastActivity.Time
+		}
+		item.MediaURL = signUploadURL(item.MediaURL)
+		items = append(items, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// ModerationTarget identifies one piece of content in a bulk action
+type ModerationTarget struct {
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id"`
+}
+
+// ModerationActionRequest represents the request body for a bulk moderation action
+type ModerationActionRequest struct {
+	Action string             `json:"action"` // remove, restore or warn
+	Reason string             `json:"reason"`
+	Items  []ModerationTarget `json:"items"`
+}
+
+// ModerationResult is the outcome of the action on one item
+type ModerationResult struct {
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+}
+
+// AdminModerateContent - Remove, restore or warn the uploader for several items at once.
+// Every applied action is written to the moderation audit log and closes open reports.
+// POST /api/admin/moderation/actions
+func AdminModerateContent(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ModerationActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Action = strings.ToLower(req.Action)
+	if req.Action != ModerationRemove && req.Action != ModerationRestore && req.Action != ModerationWarn {
+		respondWithError(w, http.StatusBadRequest, "action must be remove, restore or warn")
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxModerationBatch {
+		respondWithError(w, http.StatusBadRequest, "Provide between 1 and "+strconv.Itoa(maxModerationBatch)+" items")
+		return
+	}
+
+	results := []ModerationResult{}
+	applied := 0
+	for _, item := range req.Items {
+		item.ContentType = strings.ToUpper(item.ContentType)
+		result := ModerationResult{ContentType: item.ContentType, ContentID: item.ContentID}
+		if !contentInOrganization(r, item) {
+			result.Error = "content not found"
+		} else if err := applyModerationAction(adminID, req.Action, req.Reason, item); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+			applied++
+		}
+		results = append(results, result)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"action":  req.Action,
+		"applied": applied,
+		"results": results,
+	})
+}
+
+// contentInOrganization reports whether a piece of content belongs to the caller's
+// organization; platform admins can moderate everything
+func contentInOrganization(r *http.Request, item ModerationTarget) bool {
+	args := []interface{}{item.ContentID}
+	var query string
+	switch item.ContentType {
+	case ContentTypeVideo:
+		query = "SELECT EXISTS(SELECT 1 FROM video_modules WHERE id::text = $1" + tenantClause(r, "org_id", &args) + ")"
+	case ContentTypeProfilePicture:
+		query = "SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1" + tenantClause(r, "org_id", &args) + ")"
+	default:
+		return false
+	}
+	var ok bool
+	database.DB.QueryRow(query, args...).Scan(&ok)
+	return ok
+}
+
+// applyModerationAction performs one action and records it. Removed profile pictures keep
+// their URL in the audit record so a later restore can put them back.
+func applyModerationAction(adminID, action, reason string, item ModerationTarget) error {
+	uploaderID, err := contentUploader(item.ContentType, item.ContentID)
+	if err != nil {
+		return errors.New("content not found")
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previousValue := ""
+	switch {
+	case action == ModerationWarn:
+		// Nothing changes on the content itself
+	case item.ContentType == ContentTypeVideo && action == ModerationRemove:
+		_, err = tx.Exec(`
+			UPDATE video_modules SET is_active = false, approval_status = 'removed',
+			       reviewed_by = $1, reviewed_at = NOW(), review_feedback = $2, updated_at = NOW()
+			WHERE id::text = $3
+		`, adminID, reason, item.ContentID)
+	case item.ContentType == ContentTypeVideo && action == ModerationRestore:
+		_, err = tx.Exec(`
+			UPDATE video_modules SET is_active = true, approval_status = 'approved',
+			       reviewed_by = $1, reviewed_at = NOW(), updated_at = NOW()
+			WHERE id::text = $2
+		`, adminID, item.ContentID)
+	case item.ContentType == ContentTypeProfilePicture && action == ModerationRemove:
+		tx.QueryRow("SELECT COALESCE(profile_picture_url, '') FROM users WHERE user_id = $1", item.ContentID).Scan(&previousValue)
+		if previousValue == "" {
+			return errors.New("profile picture already removed")
+		}
+		_, err = tx.Exec("UPDATE users SET profile_picture_url = NULL, updated_at = NOW() WHERE user_id = $1", item.ContentID)
+	case item.ContentType == ContentTypeProfilePicture && action == ModerationRestore:
+		err = tx.QueryRow(`
+			SELECT previous_value FROM moderation_actions
+			WHERE content_type = $1 AND content_id = $2 AND action = 'remove' AND COALESCE(previous_value, '') != ''
+			ORDER BY created_at DESC LIMIT 1
+		`, item.ContentType, item.ContentID).Scan(&previousValue)
+		if err != nil {
+			return errors.New("no removed profile picture to restore")
+		}
+		_, err = tx.Exec("UPDATE users SET profile_picture_url = $1, updated_at = NOW() WHERE user_id = $2", previousValue, item.ContentID)
+		previousValue = ""
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO moderation_actions (admin_id, action, content_type, content_id, uploader_id, reason, previous_value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NOW())
+	`, adminID, action, item.ContentType, item.ContentID, uploaderID, reason, previousValue); err != nil {
+		return err
+	}
+	if action != ModerationWarn {
+		if _, err := tx.Exec(`
+			UPDATE content_reports SET status = 'RESOLVED', resolved_by = $1, resolved_at = NOW()
+			WHERE content_type = $2 AND content_id = $3 AND status = 'OPEN'
+		`, adminID, item.ContentType, item.ContentID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	switch action {
+	case ModerationWarn:
+		notifyUser(uploaderID, "CONTENT_WARNING", "Content warning",
+			"An admin reviewed content you shared and issued a warning. "+reason, item.ContentType, item.ContentID)
+	case ModerationRemove:
+		notifyUser(uploaderID, "CONTENT_REMOVED", "Content removed",
+			"Content you shared was removed by an admin. "+reason, item.ContentType, item.ContentID)
+	}
+	return nil
+}
+
+// AdminGetModerationActions - Moderation audit log
+// GET /api/admin/moderation/actions?content_type=VIDEO&content_id=12&limit=100
+func AdminGetModerationActions(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	query := `
+		SELECT m.id, m.admin_id, COALESCE(u.name, ''), m.action, m.content_type, m.content_id,
+		       COALESCE(m.uploader_id, ''), COALESCE(m.reason, ''), m.created_at
+		FROM moderation_actions m
+		LEFT JOIN users u ON m.admin_id = u.user_id
+		LEFT JOIN users up ON m.uploader_id = up.user_id
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	query += tenantClause(r, "up.org_id", &args)
+	if v := r.URL.Query().Get("content_type"); v != "" {
+		args = append(args, strings.ToUpper(v))
+		query += " AND m.content_type = $" + strconv.Itoa(len(args))
+	}
+	if v := r.URL.Query().Get("content_id"); v != "" {
+		args = append(args, v)
+		query += " AND m.content_id = $" + strconv.Itoa(len(args))
+	}
+	args = append(args, limit)
+	query += " ORDER BY m.created_at DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	actions := []ModerationAction{}
+	for rows.Next() {
+		var a ModerationAction
+		if err := rows.Scan(&a.ID, &a.AdminID, &a.AdminName, &a.Action, &a.ContentType, &a.ContentID,
+			&a.UploaderID, &a.Reason, &a.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning actions: "+err.Error())
+			return
+		}
+		actions = append(actions, a)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"actions": actions,
+	})
+}
@@ -30,13 +30,43 @@ func CreateVideoModule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Staged modules stay inactive until published
+	approvalStatus := "approved"
+	switch moduleData.Status {
+	case "", "published":
+		moduleData.PublishAt = nil
+	case ModuleStatusDraft:
+		approvalStatus = ModuleStatusDraft
+		moduleData.PublishAt = nil
+	case ModuleStatusScheduled:
+		if moduleData.PublishAt == nil || !moduleData.PublishAt.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "publish_at must be in the future for a scheduled module")
+			return
+		}
+		approvalStatus = ModuleStatusScheduled
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be draft, scheduled or published")
+		return
+	}
+	if moduleData.ExpiresAt != nil && !moduleData.ExpiresAt.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+	if !validPassingScore(moduleData.PassingScore) {
+		respondWithError(w, http.StatusBadRequest, "passing_score must be between 1 and 100")
+		return
+	}
+
 	var moduleID int
 	err := database.DB.QueryRow(
-		`INSERT INTO video_modules (title, description, video_url, duration, category, thumbnail, created_by, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`INSERT INTO video_modules (title, description, video_url, duration, category, thumbnail, created_by, created_at, updated_at,
+		                            is_active, approval_status, publish_at, expires_at, review_due_at, passing_score)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		 RETURNING id`,
 		moduleData.Title, moduleData.Description, moduleData.VideoURL, moduleData.Duration,
 		moduleData.Category, moduleData.Thumbnail, supervisorID, time.Now(), time.Now(),
+		approvalStatus == "approved", approvalStatus, moduleData.PublishAt, moduleData.ExpiresAt, moduleData.ReviewDueAt,
+		moduleData.PassingScore,
 	).Scan(&moduleID)
 
 	if err != nil {
@@ -46,25 +76,44 @@ func CreateVideoModule(w http.ResponseWriter, r *http.Request) {
 
 	var module models.VideoModule
 	err = database.DB.QueryRow(
-		`SELECT id, title, description, video_url, duration, category, thumbnail, is_active, created_by, created_at, updated_at
+		`SELECT id, title, description, video_url, duration, category, thumbnail, is_active, created_by, created_at, updated_at, publish_at, passing_score
 		 FROM video_modules WHERE id = $1`,
 		moduleID,
 	).Scan(&module.ID, &module.Title, &module.Description, &module.VideoURL, &module.Duration,
-		&module.Category, &module.Thumbnail, &module.IsActive, &module.CreatedBy, &module.CreatedAt, &module.UpdatedAt)
+		&module.Category, &module.Thumbnail, &module.IsActive, &module.CreatedBy, &module.CreatedAt, &module.UpdatedAt,
+		&module.PublishAt, &module.PassingScore)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error fetching created module")
 		return
 	}
+	module.VideoURL = signUploadURL(module.VideoURL)
 
 	respondWithJSON(w, http.StatusCreated, module)
 }
 
+// GetVideoModules - GET /api/modules?updated_since=2024-01-15T06:00:00Z
+// With updated_since, only modules changed since then are returned along with the IDs of
+// modules deactivated since then
 func GetVideoModules(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(
-		`SELECT id, title, COALESCE(description, ''), video_url, COALESCE(duration, 0), COALESCE(category, ''), COALESCE(thumbnail, ''), is_active, created_by, created_at, updated_at
-		 FROM video_modules WHERE is_active = true ORDER BY created_at DESC`,
-	)
+	since, err := parseUpdatedSince(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	serverTime := time.Now()
+
+	query := `SELECT id, title, COALESCE(description, ''), video_url, COALESCE(duration, 0), COALESCE(category, ''), COALESCE(thumbnail, ''), is_active, created_by, created_at, updated_at
+		 FROM video_modules WHERE is_active = true`
+	args := []interface{}{}
+	if since != nil {
+		query = `SELECT id, title, COALESCE(description, ''), video_url, COALESCE(duration, 0), COALESCE(category, ''), COALESCE(thumbnail, ''), is_active, created_by, created_at, updated_at
+		 FROM video_modules WHERE updated_at > $1`
+		args = append(args, *since)
+	}
+	query += sharedTenantClause(r, "org_id", &args) + " ORDER BY created_at DESC"
+
+	rows, err := database.DB.Query(query, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
@@ -72,6 +121,7 @@ func GetVideoModules(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	modules := []models.VideoModule{}
+	deleted := []int{}
 	for rows.Next() {
 		var module models.VideoModule
 		var createdBy sql.NullString
@@ -81,12 +131,29 @@ func GetVideoModules(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning module data: "+err.Error())
 			return
 		}
+		if !module.IsActive {
+			deleted = append(deleted, module.ID)
+			continue
+		}
 		if createdBy.Valid {
 			module.CreatedBy = &createdBy.String
 		}
 		modules = append(modules, module)
 	}
 
+	sources := make([]string, len(modules))
+	for i, m := range modules {
+		sources[i] = m.VideoURL
+	}
+	media := loadRenditions(w, r, sources)
+	for i := range modules {
+		modules[i].VideoURL = media.sign(modules[i].VideoURL)
+	}
+
+	if since != nil {
+		respondWithJSON(w, http.StatusOK, SyncResponse{Items: modules, Deleted: deleted, ServerTime: serverTime})
+		return
+	}
 	respondWithJSON(w, http.StatusOK, modules)
 }
 
@@ -96,12 +163,14 @@ func GetVideoModule(w http.ResponseWriter, r *http.Request) {
 
 	var module models.VideoModule
 	var createdBy sql.NullString
+	args := []interface{}{moduleID}
+	scope := sharedTenantClause(r, "org_id", &args)
 	err := database.DB.QueryRow(
-		`SELECT id, title, COALESCE(description, ''), video_url, COALESCE(duration, 0), COALESCE(category, ''), COALESCE(thumbnail, ''), is_active, created_by, created_at, updated_at
-		 FROM video_modules WHERE id = $1`,
-		moduleID,
+		`SELECT id, title, COALESCE(description, ''), video_url, COALESCE(duration, 0), COALESCE(category, ''), COALESCE(thumbnail, ''), is_active, created_by, created_at, updated_at, passing_score
+		 FROM video_modules WHERE id = $1`+scope,
+		args...,
 	).Scan(&module.ID, &module.Title, &module.Description, &module.VideoURL, &module.Duration,
-		&module.Category, &module.Thumbnail, &module.IsActive, &createdBy, &module.CreatedAt, &module.UpdatedAt)
+		&module.Category, &module.Thumbnail, &module.IsActive, &createdBy, &module.CreatedAt, &module.UpdatedAt, &module.PassingScore)
 
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Video module not found")
@@ -115,6 +184,7 @@ func GetVideoModule(w http.ResponseWriter, r *http.Request) {
 	if createdBy.Valid {
 		module.CreatedBy = &createdBy.String
 	}
+	module.VideoURL = loadRenditions(w, r, []string{module.VideoURL}).sign(module.VideoURL)
 
 	respondWithJSON(w, http.StatusOK, module)
 }
@@ -130,13 +200,16 @@ func SetStarVideo(w http.ResponseWriter, r *http.Request) {
 	videoID := vars["id"]
 
 	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1)", videoID).Scan(&exists)
+	err := database.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1 AND (org_id IS NULL OR org_id = $2))",
+		videoID, requestOrg(r),
+	).Scan(&exists)
 	if err != nil || !exists {
 		respondWithError(w, http.StatusNotFound, "Video module not found")
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := userToday(supervisorID)
 	_, err = database.DB.Exec(
 		`UPDATE star_videos SET is_active = false 
 		 WHERE supervisor_id = $1 AND set_date = $2 AND is_active = true`,
@@ -193,7 +266,17 @@ func GetStarVideo(w http.ResponseWriter, r *http.Request) {
 		supervisorID = userID
 	}
 
-	today := time.Now().Format("2006-01-02")
+	// Star videos are set for the supervisor's day
+	today := userToday(supervisorID)
+	source := userSiteSettings(supervisorID).StarVideoSource
+
+	// Sites sharing a star video use the latest pick by any of their supervisors
+	pickedBy := "sv.supervisor_id = $1"
+	if source == StarVideoSourceSite {
+		pickedBy = `sv.supervisor_id IN (
+			SELECT user_id FROM users WHERE role = 'SUPERVISOR' AND site_id = (SELECT site_id FROM users WHERE user_id = $1)
+			UNION SELECT $1)`
+	}
 
 	var module models.VideoModule
 	err = database.DB.QueryRow(
@@ -201,11 +284,29 @@ func GetStarVideo(w http.ResponseWriter, r *http.Request) {
 		        vm.thumbnail, vm.is_active, vm.created_by, vm.created_at, vm.updated_at
 		 FROM video_modules vm
 		 JOIN star_videos sv ON vm.id = sv.video_id
-		 WHERE sv.supervisor_id = $1 AND sv.set_date = $2 AND sv.is_active = true`,
+		 WHERE `+pickedBy+` AND sv.set_date = $2 AND sv.is_active = true
+		 ORDER BY sv.id DESC
+		 LIMIT 1`,
 		supervisorID, today,
 	).Scan(&module.ID, &module.Title, &module.Description, &module.VideoURL, &module.Duration,
 		&module.Category, &module.Thumbnail, &module.IsActive, &module.CreatedBy, &module.CreatedAt, &module.UpdatedAt)
 
+	// Sites rotating by engagement fall back to this week's top trending module
+	if err == sql.ErrNoRows && source == StarVideoSourceTrending {
+		err = database.DB.QueryRow(
+			`SELECT vm.id, vm.title, vm.description, vm.video_url, vm.duration, vm.category,
+			        vm.thumbnail, vm.is_active, vm.created_by, vm.created_at, vm.updated_at
+			 FROM video_modules vm
+			 JOIN video_trending t ON t.video_id = vm.id
+			 WHERE vm.is_active = true AND vm.approval_status = 'approved'
+			   AND (vm.org_id IS NULL OR vm.org_id = $1)
+			 ORDER BY t.trending_score DESC, vm.id
+			 LIMIT 1`,
+			requestOrg(r),
+		).Scan(&module.ID, &module.Title, &module.Description, &module.VideoURL, &module.Duration,
+			&module.Category, &module.Thumbnail, &module.IsActive, &module.CreatedBy, &module.CreatedAt, &module.UpdatedAt)
+	}
+
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "No star video set for today")
 		return
@@ -214,6 +315,7 @@ func GetStarVideo(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
 	}
+	module.VideoURL = loadRenditions(w, r, []string{module.VideoURL}).sign(module.VideoURL)
 
 	respondWithJSON(w, http.StatusOK, module)
 }
@@ -237,10 +339,9 @@ func CreateQuestion(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var exists bool
-	err := database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1)",
-		questionData.VideoID,
-	).Scan(&exists)
+	args := []interface{}{questionData.VideoID}
+	scope := sharedTenantClause(r, "org_id", &args)
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1"+scope+")", args...).Scan(&exists)
 	if err != nil || !exists {
 		respondWithError(w, http.StatusNotFound, "Video module not found")
 		return
@@ -254,10 +355,10 @@ func CreateQuestion(w http.ResponseWriter, r *http.Request) {
 
 	var questionID int
 	err = database.DB.QueryRow(
-		`INSERT INTO questions (video_id, question, options, answer)
-		 VALUES ($1, $2, $3, $4)
+		`INSERT INTO questions (video_id, question, options, answer, explanation)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''))
 		 RETURNING id`,
-		questionData.VideoID, questionData.Question, optionsJSON, questionData.Answer,
+		questionData.VideoID, questionData.Question, optionsJSON, questionData.Answer, questionData.Explanation,
 	).Scan(&questionID)
 
 	if err != nil {
@@ -268,9 +369,9 @@ func CreateQuestion(w http.ResponseWriter, r *http.Request) {
 	var question models.Question
 	var optionsStr string
 	err = database.DB.QueryRow(
-		`SELECT id, video_id, question, options, answer FROM questions WHERE id = $1`,
+		`SELECT id, video_id, question, options, answer, COALESCE(explanation, '') FROM questions WHERE id = $1`,
 		questionID,
-	).Scan(&question.ID, &question.VideoID, &question.Question, &optionsStr, &question.Answer)
+	).Scan(&question.ID, &question.VideoID, &question.Question, &optionsStr, &question.Answer, &question.Explanation)
 
 	if err == nil {
 		json.Unmarshal([]byte(optionsStr), &question.Options)
@@ -323,7 +424,7 @@ func SubmitModuleAnswers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := database.DB.Query(
-		`SELECT id, answer FROM questions WHERE video_id = $1 ORDER BY id`,
+		`SELECT id, question, options, answer, COALESCE(explanation, '') FROM questions WHERE video_id = $1 ORDER BY id`,
 		submission.VideoID,
 	)
 	if err != nil {
@@ -332,16 +433,17 @@ func SubmitModuleAnswers(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	correctAnswers := make(map[int]int)
-	questionIDs := []int{}
+	// Explanations are only ever sent back here, once the miner has committed to an answer
+	review := []models.QuestionReview{}
 	for rows.Next() {
-		var qID, answer int
-		rows.Scan(&qID, &answer)
-		correctAnswers[qID] = answer
-		questionIDs = append(questionIDs, qID)
+		var q models.QuestionReview
+		var optionsStr string
+		rows.Scan(&q.QuestionID, &q.Question, &optionsStr, &q.CorrectAnswer, &q.Explanation)
+		json.Unmarshal([]byte(optionsStr), &q.Options)
+		review = append(review, q)
 	}
 
-	totalQuestions := len(correctAnswers)
+	totalQuestions := len(review)
 	if totalQuestions == 0 {
 		respondWithError(w, http.StatusBadRequest, "No questions found for this video")
 		return
@@ -352,47 +454,125 @@ func SubmitModuleAnswers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading retake policy")
+		return
+	}
+	retake, err := checkRetakePolicy(minerID, submission.VideoID, policy)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	if !retake.Allowed {
+		respondWithJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error":  retake.Reason,
+			"status": retake,
+		})
+		return
+	}
+
 	// Calculate score
 	score := 0
-	for i, qID := range questionIDs {
-		if i < len(submission.Answers) && submission.Answers[i] == correctAnswers[qID] {
+	for i := range review {
+		review[i].SelectedAnswer = submission.Answers[i]
+		review[i].IsCorrect = submission.Answers[i] == review[i].CorrectAnswer
+		if review[i].IsCorrect {
 			score++
 		}
 	}
 
-	var completionID int
+	passingPercent, err := modulePassingPercent(submission.VideoID, policy)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	percentage := float64(score) / float64(totalQuestions) * 100
+	passed := percentage >= passingPercent
+
+	// The day's completion keeps the best attempt and is passed once any attempt passes;
+	// every attempt is kept in module_attempts
+	var completionID, bestScore int
+	var alreadyPassed bool
 	err = database.DB.QueryRow(
-		`SELECT id FROM module_completions 
-		 WHERE miner_id = $1 AND video_id = $2 AND completed_at::date = CURRENT_DATE`,
-		minerID, submission.VideoID,
-	).Scan(&completionID)
+		`SELECT id, COALESCE(score, 0), passed FROM module_completions 
+		 WHERE miner_id = $1 AND video_id = $2 AND (completed_at AT TIME ZONE $3)::date = (NOW() AT TIME ZONE $3)::date`,
+		minerID, submission.VideoID, userLocation(minerID).String(),
+	).Scan(&completionID, &bestScore, &alreadyPassed)
 
 	if err == nil {
-		_, err = database.DB.Exec(
-			`UPDATE module_completions SET score = $1, total_questions = $2, completed_at = NOW()
-			 WHERE id = $3`,
-			score, totalQuestions, completionID,
-		)
+		if score >= bestScore {
+			_, err = database.DB.Exec(
+				`UPDATE module_completions SET score = $1, total_questions = $2, completed_at = NOW(), passed = passed OR $4
+				 WHERE id = $3`,
+				score, totalQuestions, completionID, passed,
+			)
+		} else if passed && !alreadyPassed {
+			_, err = database.DB.Exec(
+				"UPDATE module_completions SET passed = true, completed_at = NOW() WHERE id = $1", completionID)
+		}
 	} else {
+		alreadyPassed = false
 		err = database.DB.QueryRow(
-			`INSERT INTO module_completions (miner_id, video_id, score, total_questions, completed_at)
-			 VALUES ($1, $2, $3, $4, NOW())
+			`INSERT INTO module_completions (miner_id, video_id, score, total_questions, passed, completed_at)
+			 VALUES ($1, $2, $3, $4, $5, NOW())
 			 RETURNING id`,
-			minerID, submission.VideoID, score, totalQuestions,
+			minerID, submission.VideoID, score, totalQuestions, passed,
 		).Scan(&completionID)
 	}
+	// Only passed completions count towards the module's completions
+	if err == nil && passed && !alreadyPassed {
+		database.DB.Exec("UPDATE video_modules SET completions_count = completions_count + 1 WHERE id = $1", submission.VideoID)
+	}
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error recording completion: "+err.Error())
 		return
 	}
 
+	var attemptID int
+	err = database.DB.QueryRow(
+		`INSERT INTO module_attempts (miner_id, video_id, completion_id, score, total_questions, percentage, passed, attempted_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		 RETURNING id`,
+		minerID, submission.VideoID, completionID, score, totalQuestions, percentage, passed,
+	).Scan(&attemptID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording attempt: "+err.Error())
+		return
+	}
+
+	recordSubmissionAttestation(r, minerID, SubmissionTypeQuizAttempt, completionID)
+
+	if passed {
+		completeRemedialAssignments(minerID, submission.VideoID, completionID)
+	}
+
+	if role, _ := middleware.GetUserRoleFromContext(r.Context()); role == string(models.RoleVisitor) {
+		recordVisitorInduction(minerID, submission.VideoID, score, totalQuestions)
+	}
+
+	message := "Module completed successfully"
+	if !passed {
+		message = "Module attempted but not passed"
+	}
+
+	// Status after this attempt, so the app can show remaining attempts and the cooldown
+	retake, _ = checkRetakePolicy(minerID, submission.VideoID, policy)
+
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"completion_id":   completionID,
+		"attempt_id":      attemptID,
+		"attempt_number":  retake.AttemptsToday,
 		"score":           score,
 		"total_questions": totalQuestions,
-		"percentage":      float64(score) / float64(totalQuestions) * 100,
-		"message":         "Module completed successfully",
+		"percentage":      percentage,
+		"passed":          passed,
+		"result":          quizResult(passed),
+		"passing_percent": passingPercent,
+		"retake":          retake,
+		"review":          review,
+		"message":         message,
 	})
 }
 
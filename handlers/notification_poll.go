@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ==================== LONG-POLL NOTIFICATIONS ====================
+
+// Old site phones where FCM is unreliable hold a request open instead and get new
+// notifications as soon as they are stored.
+
+const (
+	maxPollWait = 25 * time.Second
+	// pollRecheckInterval bounds how late a notification can be if its wake-up is lost,
+	// e.g. while the cross-instance listener reconnects
+	pollRecheckInterval = 5 * time.Second
+	pollBatchLimit      = 50
+)
+
+// notificationChannel carries the user ID of each new notification between instances
+const notificationChannel = "minesafe_notifications"
+
+var (
+	pollWaitersMu sync.Mutex
+	pollWaiters   = map[string]map[chan struct{}]bool{} // user ID -> open polls
+	// pollFanout is set when new notifications are announced to every instance via Postgres
+	pollFanout bool
+)
+
+// announceNotification wakes the user's open polls, on whichever instance holds them
+func announceNotification(userID string) {
+	if pollFanout {
+		if err := database.Publish(notificationChannel, userID); err == nil {
+			return
+		}
+	}
+	wakePollers(userID)
+}
+
+// wakePollers wakes this instance's open polls for userID, or every open poll when
+// userID is empty
+func wakePollers(userID string) {
+	pollWaitersMu.Lock()
+	defer pollWaitersMu.Unlock()
+	for user, waiters := range pollWaiters {
+		if userID != "" && user != userID {
+			continue
+		}
+		for ch := range waiters {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func addPollWaiter(userID string) chan struct{} {
+	ch := make(chan struct{}, 1)
+	pollWaitersMu.Lock()
+	if pollWaiters[userID] == nil {
+		pollWaiters[userID] = map[chan struct{}]bool{}
+	}
+	pollWaiters[userID][ch] = true
+	pollWaitersMu.Unlock()
+	return ch
+}
+
+func removePollWaiter(userID string, ch chan struct{}) {
+	pollWaitersMu.Lock()
+	delete(pollWaiters[userID], ch)
+	if len(pollWaiters[userID]) == 0 {
+		delete(pollWaiters, userID)
+	}
+	pollWaitersMu.Unlock()
+}
+
+// notificationsAfter returns the user's notifications with an ID above cursor, oldest first
+func notificationsAfter(userID string, cursor int) ([]Notification, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, type, title, COALESCE(message, ''), COALESCE(reference_type, ''),
+		       COALESCE(reference_id, ''), is_read, created_at
+		FROM notifications WHERE user_id = $1 AND id > $2
+		ORDER BY id LIMIT $3
+	`, userID, cursor, pollBatchLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Message, &n.ReferenceType,
+			&n.ReferenceID, &n.IsRead, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// PollNotifications - Long-poll for notifications newer than the cursor. Returns as soon as
+// there are any, or empty after the wait (at most 25s). Pass the returned cursor to the
+// next poll; without one the current position is returned straight away.
+// GET /api/app/notifications/poll?cursor=123&wait=25
+func PollNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	wait := maxPollWait
+	if v := r.URL.Query().Get("wait"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			respondWithError(w, http.StatusBadRequest, "wait must be a number of seconds")
+			return
+		}
+		if d := time.Duration(secs) * time.Second; d < wait {
+			wait = d
+		}
+	}
+
+	respond := func(cursor int, notifications []Notification) {
+		var unreadCount int
+		database.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND is_read = false", userID).Scan(&unreadCount)
+		w.Header().Set("Cache-Control", "no-store")
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"notifications": notifications,
+			"cursor":        cursor,
+			"unread_count":  unreadCount,
+		})
+	}
+
+	v := r.URL.Query().Get("cursor")
+	if v == "" {
+		var cursor int
+		if err := database.DB.QueryRow("SELECT COALESCE(MAX(id), 0) FROM notifications WHERE user_id = $1", userID).Scan(&cursor); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		respond(cursor, []Notification{})
+		return
+	}
+	cursor, err := strconv.Atoi(v)
+	if err != nil || cursor < 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	// Register before the first check so a notification stored in between still wakes us
+	wake := addPollWaiter(userID)
+	defer removePollWaiter(userID, wake)
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	recheck := time.NewTicker(pollRecheckInterval)
+	defer recheck.Stop()
+
+	for {
+		notifications, err := notificationsAfter(userID, cursor)
+		if err != nil {
+			log.Printf("Warning: notification poll for %s failed: %v", userID, err)
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if len(notifications) > 0 {
+			respond(notifications[len(notifications)-1].ID, notifications)
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-recheck.C:
+		case <-deadline.C:
+			respond(cursor, notifications)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== NOTIFICATIONS ====================
+
+// Notification represents an in-app notification for a user
+type Notification struct {
+	ID            int       `json:"id"`
+	Type          string    `json:"type"`
+	Title         string    `json:"title"`
+	Message       string    `json:"message"`
+	ReferenceType string    `json:"reference_type,omitempty"`
+	ReferenceID   string    `json:"reference_id,omitempty"`
+	IsRead        bool      `json:"is_read"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// notifyUser stores an in-app notification. Failures are logged rather than returned
+// so that notifications never block the action that triggered them.
+func notifyUser(userID, notifType, title, message, referenceType, referenceID string) {
+	if userID == "" {
+		return
+	}
+	_, err := database.DB.Exec(`
+		INSERT INTO notifications (user_id, type, title, message, reference_type, reference_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, userID, notifType, title, message, referenceType, referenceID)
+	if err != nil {
+		log.Printf("Warning: failed to notify %s: %v", userID, err)
+		return
+	}
+	announceNotification(userID)
+}
+
+// GetNotifications - Get the current user's notifications
+// GET /api/notifications?unread=true&limit=50
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, type, title, COALESCE(message, ''), COALESCE(reference_type, ''),
+		       COALESCE(reference_id, ''), is_read, created_at
+		FROM notifications WHERE user_id = $1
+	`
+	if r.URL.Query().Get("unread") == "true" {
+		query += " AND is_read = false"
+	}
+	query += " ORDER BY created_at DESC LIMIT $2"
+
+	rows, err := database.DB.Query(query, userID, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Message, &n.ReferenceType,
+			&n.ReferenceID, &n.IsRead, &n.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		notifications = append(notifications, n)
+	}
+
+	var unreadCount int
+	database.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND is_read = false", userID).Scan(&unreadCount)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"unread_count":  unreadCount,
+	})
+}
+
+// MarkNotificationRead - Mark one notification as read
+// PUT /api/notifications/{id}/read
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	result, err := database.DB.Exec("UPDATE notifications SET is_read = true WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// MarkAllNotificationsRead - Mark all of the current user's notifications as read
+// PUT /api/notifications/read-all
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result, err := database.DB.Exec("UPDATE notifications SET is_read = true WHERE user_id = $1 AND is_read = false", userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	updated, _ := result.RowsAffected()
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"updated": updated,
+	})
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ==================== OFFLINE SYNC ====================
+
+// Miners lose signal underground, so the app queues emergencies, PPE stats and checklist
+// completions made offline and uploads them together once it reconnects. Every item carries
+// a UUID the app generated when it was created, and is written in one transaction with that
+// UUID, so a queue re-sent after a dropped response is applied exactly once. Items are
+// processed in the order sent and independently of each other: one bad item doesn't hold
+// back the rest.
+
+// Offline item types
+const (
+	SyncItemEmergency         = "emergency"
+	SyncItemPPEStat           = "ppe_stat"
+	SyncItemPreStartChecklist = "pre_start_checklist"
+	SyncItemPPEChecklist      = "ppe_checklist"
+)
+
+// Item outcomes
+const (
+	SyncCreated    = "created"
+	SyncDuplicate  = "duplicate"  // synced before; record_id is the original record
+	SyncSuperseded = "superseded" // a newer record for the same day was already synced
+	SyncRejected   = "rejected"   // invalid; retrying won't help, so the app should drop it
+	SyncFailed     = "failed"     // server error; keep the item queued and retry later
+)
+
+// maxOfflineSyncItems bounds one upload; a longer queue is sent in several
+const maxOfflineSyncItems = 100
+
+// offlineSyncMaxAge is how long synced UUIDs are remembered. Older items are rejected,
+// since a duplicate of one could no longer be recognised.
+const offlineSyncMaxAge = 30 * 24 * time.Hour
+
+// offlineSirenWindow is how recent an offline CRITICAL emergency must be to still sound the
+// site sirens; supervisors are alerted about older ones, but an evacuation isn't started
+const offlineSirenWindow = 30 * time.Minute
+
+// OfflineSyncItem is one queued item
+type OfflineSyncItem struct {
+	ClientID   string          `json:"client_id"`
+	Type       string          `json:"type"`
+	RecordedAt *time.Time      `json:"recorded_at"` // when it was created on the phone
+	Payload    json.RawMessage `json:"payload"`     // the body the online endpoint takes
+}
+
+// OfflineSyncResult is the outcome of one item
+type OfflineSyncResult struct {
+	ClientID string `json:"client_id"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	RecordID int    `json:"record_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// syncRejection is an item error the app can't fix by retrying
+type syncRejection string
+
+func (e syncRejection) Error() string { return string(e) }
+
+// offlineWrite is a validated item ready to be written. apply runs in the item's
+// transaction and returns the record's ID and its outcome; after runs once it's committed.
+type offlineWrite struct {
+	apply func(tx *sql.Tx) (int, string, error)
+	after func(recordID int)
+}
+
+// SyncOfflineItems - Upload items the app queued while offline and get a result per item
+// POST /api/app/sync
+func SyncOfflineItems(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if deviceDeactivated(r, userID) {
+		respondWithError(w, http.StatusForbidden, "This device has been deactivated")
+		return
+	}
+
+	var req struct {
+		Items []OfflineSyncItem `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one item is required")
+		return
+	}
+	if len(req.Items) > maxOfflineSyncItems {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A sync can contain at most %d items", maxOfflineSyncItems))
+		return
+	}
+
+	database.DB.Exec(`
+		DELETE FROM offline_sync_items WHERE user_id = $1 AND synced_at < NOW() - $2 * INTERVAL '1 second'
+	`, userID, offlineSyncMaxAge.Seconds())
+
+	results := make([]OfflineSyncResult, 0, len(req.Items))
+	for _, item := range req.Items {
+		results = append(results, syncOfflineItem(r, userID, item))
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"results":     results,
+		"server_time": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// syncOfflineItem validates and writes one item
+func syncOfflineItem(r *http.Request, userID string, item OfflineSyncItem) OfflineSyncResult {
+	result := OfflineSyncResult{ClientID: item.ClientID, Type: item.Type}
+	reject := func(err error) OfflineSyncResult {
+		result.Status, result.Error = SyncRejected, err.Error()
+		return result
+	}
+
+	clientID, err := uuid.Parse(item.ClientID)
+	if err != nil {
+		return reject(syncRejection("client_id must be a UUID"))
+	}
+	if item.RecordedAt == nil {
+		return reject(syncRejection("recorded_at is required"))
+	}
+	now := time.Now()
+	recordedAt := *item.RecordedAt
+	if recordedAt.After(now) {
+		recordedAt = now // the phone's clock runs ahead
+	}
+	if now.Sub(recordedAt) > offlineSyncMaxAge {
+		return reject(syncRejection("Item is too old to sync"))
+	}
+
+	var write *offlineWrite
+	switch item.Type {
+	case SyncItemEmergency:
+		write, err = offlineEmergency(userID, recordedAt, item.Payload)
+	case SyncItemPPEStat:
+		write, err = offlinePPEStat(r, userID, recordedAt, item.Payload)
+	case SyncItemPreStartChecklist:
+		write, err = offlineChecklistCompletion(r, userID, "pre_start_checklist_completions", recordedAt, item.Payload)
+	case SyncItemPPEChecklist:
+		write, err = offlineChecklistCompletion(r, userID, "ppe_checklist_completions", recordedAt, item.Payload)
+	default:
+		err = syncRejection("Unknown item type")
+	}
+	if err != nil {
+		if _, ok := err.(syncRejection); ok {
+			return reject(err)
+		}
+		log.Printf("Warning: failed to check offline %s %s for %s: %v", item.Type, clientID, userID, err)
+		result.Status, result.Error = SyncFailed, "Error saving item"
+		return result
+	}
+
+	recordID, status, err := applyOfflineWrite(userID, clientID.String(), item.Type, recordedAt, write)
+	if err != nil {
+		if _, ok := err.(syncRejection); ok {
+			return reject(err)
+		}
+		// Constraint violations, e.g. a checklist item that has since been deleted
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Class() == "23" {
+			return reject(syncRejection("Item conflicts with existing data"))
+		}
+		log.Printf("Warning: failed to sync offline %s %s for %s: %v", item.Type, clientID, userID, err)
+		result.Status, result.Error = SyncFailed, "Error saving item"
+		return result
+	}
+	if status == SyncCreated && write.after != nil {
+		write.after(recordID)
+	}
+	result.Status, result.RecordID = status, recordID
+	return result
+}
+
+// applyOfflineWrite claims the item's UUID and writes it in one transaction. A UUID that was
+// claimed before returns the original record as a duplicate; a concurrent upload of the same
+// UUID waits on the claim until the first one commits or rolls back.
+func applyOfflineWrite(userID, clientID, itemType string, recordedAt time.Time, write *offlineWrite) (int, string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	claim, err := tx.Exec(`
+		INSERT INTO offline_sync_items (user_id, client_id, item_type, recorded_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, userID, clientID, itemType, recordedAt)
+	if err != nil {
+		return 0, "", err
+	}
+	if n, _ := claim.RowsAffected(); n == 0 {
+		var storedType string
+		var recordID sql.NullInt64
+		err := tx.QueryRow(`
+			SELECT item_type, record_id FROM offline_sync_items WHERE user_id = $1 AND client_id = $2
+		`, userID, clientID).Scan(&storedType, &recordID)
+		if err != nil {
+			return 0, "", err
+		}
+		if storedType != itemType {
+			return 0, "", syncRejection("This client_id was already used for a different item")
+		}
+		return int(recordID.Int64), SyncDuplicate, nil
+	}
+
+	recordID, status, err := write.apply(tx)
+	if err != nil {
+		return 0, "", err
+	}
+	_, err = tx.Exec(`
+		UPDATE offline_sync_items SET record_id = $3 WHERE user_id = $1 AND client_id = $2
+	`, userID, clientID, recordID)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	return recordID, status, nil
+}
+
+// offlineEmergency prepares a queued emergency report. It takes the same body as
+// POST /api/emergencies; the report's incident time is when it was recorded.
+func offlineEmergency(userID string, recordedAt time.Time, payload json.RawMessage) (*offlineWrite, error) {
+	var emergencyData models.EmergencyCreate
+	if err := json.Unmarshal(payload, &emergencyData); err != nil {
+		return nil, syncRejection("Invalid emergency payload")
+	}
+	emergencyData.UserID = userID
+	detailsJSON, err := normaliseEmergencyCreate(&emergencyData)
+	if err != nil {
+		return nil, syncRejection(err.Error())
+	}
+
+	emergency, err := models.NewEmergency(userID, emergencyData.EmergencyID, emergencyData.Severity,
+		emergencyData.Latitude, emergencyData.Longitude, emergencyData.Issue, emergencyData.MediaStatus,
+		nil, &recordedAt)
+	if err != nil {
+		return nil, syncRejection(err.Error())
+	}
+	emergency.Category = emergencyData.Category
+	emergency.Details = emergencyData.Details
+	if emergency.Lat != 0 && emergency.Lon != 0 {
+		if name, err := reverseGeocode(emergency.Lat, emergency.Lon); err == nil {
+			emergency.Location = &name
+		}
+	}
+
+	return &offlineWrite{
+		apply: func(tx *sql.Tx) (int, string, error) {
+			// The app may have got the report through online before its response was lost
+			var existingID int
+			err := tx.QueryRow(
+				"SELECT id FROM emergencies WHERE user_id = $1 AND emergency_id = $2",
+				userID, emergency.EmergencyID,
+			).Scan(&existingID)
+			if err == nil {
+				return existingID, SyncDuplicate, nil
+			}
+			if err != sql.ErrNoRows {
+				return 0, "", err
+			}
+
+			err = tx.QueryRow(
+				`INSERT INTO emergencies (user_id, emergency_id, severity, latitude, longitude, issue,
+				                          media_status, location, incident_time, reporting_time, status, category, details)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13)
+				 RETURNING id`,
+				emergency.UserID, emergency.EmergencyID, emergency.Severity, emergency.Lat, emergency.Lon,
+				emergency.Issue, emergency.MediaStatus, emergency.Location, emergency.IncidentTime,
+				emergency.IncidentReportingTime, emergency.Status, emergency.Category, detailsJSON,
+			).Scan(&emergency.ID)
+			return emergency.ID, SyncCreated, err
+		},
+		after: func(recordID int) {
+			emergency.ID = recordID
+			emergency.ZoneID = resolveEmergencyZone(emergency.ID)
+			emergency.PrimaryEmergencyID = clusterEmergency(emergency.ID)
+			go publishLiveAlert(emergency.UserID, LiveAlertEmergencyCreated, emergencyAlert(emergency))
+			go sendEmergencySMS(emergency)
+			if strings.EqualFold(emergency.Severity, "CRITICAL") && time.Since(recordedAt) <= offlineSirenWindow {
+				go triggerSirens(emergency.ID, SirenReasonCritical)
+			}
+		},
+	}, nil
+}
+
+// offlinePPEStat prepares a queued PPE verification. It takes the same body as
+// POST /api/ppestat and counts for the day it was recorded; an older upload never
+// overwrites a newer verification for that day.
+func offlinePPEStat(r *http.Request, userID string, recordedAt time.Time, payload json.RawMessage) (*offlineWrite, error) {
+	var req PPEStatRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, syncRejection("Invalid PPE stat payload")
+	}
+	if req.MinerName == "" {
+		database.DB.QueryRow("SELECT name FROM users WHERE user_id = $1", userID).Scan(&req.MinerName)
+	}
+	if !featureEnabled(r.Context(), FlagAIPPEVerification, true) {
+		req.AIVerification = nil
+	}
+	getAIValue := func(key string) string {
+		if val, ok := req.AIVerification[key]; ok {
+			return val
+		}
+		return "no"
+	}
+	manualChecklistJSON, _ := json.Marshal(req.ManualChecklist)
+	aiVerificationJSON, _ := json.Marshal(req.AIVerification)
+	date := recordedAt.In(userLocation(userID)).Format("2006-01-02")
+
+	return &offlineWrite{
+		apply: func(tx *sql.Tx) (int, string, error) {
+			var statID int
+			err := tx.QueryRow(`
+				INSERT INTO ppe_stats (
+					user_id, miner_name, date,
+					safety_helmet, protective_gloves, safety_shoes, high_visibility_vest,
+					safety_goggles, respirator, ear_protection, face_shield,
+					safety_harness, knee_pads,
+					manual_checklist, ai_verification, photo_captured,
+					completion_percentage, items_detected, total_items, created_at
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+				ON CONFLICT (user_id, date) DO UPDATE SET
+					miner_name = EXCLUDED.miner_name,
+					safety_helmet = EXCLUDED.safety_helmet,
+					protective_gloves = EXCLUDED.protective_gloves,
+					safety_shoes = EXCLUDED.safety_shoes,
+					high_visibility_vest = EXCLUDED.high_visibility_vest,
+					safety_goggles = EXCLUDED.safety_goggles,
+					respirator = EXCLUDED.respirator,
+					ear_protection = EXCLUDED.ear_protection,
+					face_shield = EXCLUDED.face_shield,
+					safety_harness = EXCLUDED.safety_harness,
+					knee_pads = EXCLUDED.knee_pads,
+					manual_checklist = EXCLUDED.manual_checklist,
+					ai_verification = EXCLUDED.ai_verification,
+					photo_captured = EXCLUDED.photo_captured,
+					completion_percentage = EXCLUDED.completion_percentage,
+					items_detected = EXCLUDED.items_detected,
+					total_items = EXCLUDED.total_items,
+					created_at = EXCLUDED.created_at
+				WHERE ppe_stats.created_at <= EXCLUDED.created_at
+				RETURNING id
+			`,
+				userID, req.MinerName, date,
+				getAIValue("safety_helmet"), getAIValue("protective_gloves"), getAIValue("safety_shoes"), getAIValue("high_visibility_vest"),
+				getAIValue("safety_goggles"), getAIValue("respirator"), getAIValue("ear_protection"), getAIValue("face_shield"),
+				getAIValue("safety_harness"), getAIValue("knee_pads"),
+				manualChecklistJSON, aiVerificationJSON, req.PhotoCaptured,
+				req.CompletionPercentage, req.ItemsDetected, req.TotalItems, recordedAt,
+			).Scan(&statID)
+			if err == sql.ErrNoRows {
+				err = tx.QueryRow("SELECT id FROM ppe_stats WHERE user_id = $1 AND date = $2", userID, date).Scan(&statID)
+				return statID, SyncSuperseded, err
+			}
+			return statID, SyncCreated, err
+		},
+		after: func(statID int) {
+			recordSubmissionAttestation(r, userID, SubmissionTypePPEStat, statID)
+			missing := missingMandatoryPPE(userSiteSettings(userID).PPEMandatoryItems, req.AIVerification, req.ManualChecklist)
+			if len(missing) > 0 {
+				go publishLiveAlert(userID, LiveAlertPPENoncompliance, map[string]interface{}{
+					"stat_id":           statID,
+					"user_id":           userID,
+					"miner_name":        req.MinerName,
+					"date":              date,
+					"mandatory_missing": missing,
+				})
+			}
+		},
+	}, nil
+}
+
+// offlineChecklistCompletion prepares a queued tick or untick on the pre-start or PPE
+// checklist. It takes the same body as the online complete endpoints and is held to the
+// same shift windows, checked against when it was recorded.
+func offlineChecklistCompletion(r *http.Request, userID, table string, recordedAt time.Time, payload json.RawMessage) (*offlineWrite, error) {
+	var update models.ChecklistCompletionUpdate
+	if err := json.Unmarshal(payload, &update); err != nil {
+		return nil, syncRejection("Invalid checklist payload")
+	}
+	if update.ItemID <= 0 {
+		return nil, syncRejection("item_id is required")
+	}
+	if role, _ := middleware.GetUserRoleFromContext(r.Context()); role == string(models.RoleMiner) {
+		allowed, _, err := withinShiftWindows(userID, recordedAt)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, syncRejection("This action is only allowed during your shift")
+		}
+	}
+	period := checklistPeriod(recordedAt.In(userLocation(userID)), userSiteSettings(userID).ChecklistFrequency)
+
+	return &offlineWrite{
+		apply: func(tx *sql.Tx) (int, string, error) {
+			var completionID int
+			err := tx.QueryRow(`
+				INSERT INTO `+table+` (user_id, item_id, is_completed, completed_at, date)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (user_id, item_id, date)
+				DO UPDATE SET is_completed = $3, completed_at = $4
+				WHERE `+table+`.completed_at IS NULL OR `+table+`.completed_at <= $4
+				RETURNING id
+			`, userID, update.ItemID, update.IsCompleted, recordedAt, period).Scan(&completionID)
+			if err == sql.ErrNoRows {
+				err = tx.QueryRow(`
+					SELECT id FROM `+table+` WHERE user_id = $1 AND item_id = $2 AND date = $3
+				`, userID, update.ItemID, period).Scan(&completionID)
+				return completionID, SyncSuperseded, err
+			}
+			return completionID, SyncCreated, err
+		},
+	}, nil
+}
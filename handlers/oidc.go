@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ==================== SINGLE SIGN-ON (OIDC) ====================
+
+// oidcStateTTL is how long a user has to finish signing in at the identity provider
+const oidcStateTTL = 10 * time.Minute
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcProvider is the identity provider's discovery document and signing keys. Both are
+// fetched on first use and again hourly; keys are also refetched when a token names a key
+// we haven't seen, which is how providers roll them over.
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+var (
+	oidcMu         sync.Mutex
+	oidcDiscovered *oidcProvider
+)
+
+// oidcClaims are the ID token claims used to find or create the user
+type oidcClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // bool, or "true"/"false" from some providers
+	Name          string      `json:"name"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// emailVerified reports whether the provider explicitly vouched for the email claim; a
+// missing claim counts as unverified
+func (c *oidcClaims) emailVerified() bool {
+	verified, _ := c.EmailVerified.(bool)
+	return verified || c.EmailVerified == "true"
+}
+
+func getOIDCProvider() (*oidcProvider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcDiscovered != nil && time.Since(oidcDiscovered.fetchedAt) < time.Hour {
+		return oidcDiscovered, nil
+	}
+
+	var provider oidcProvider
+	if err := getJSON(config.Get().OIDCIssuerURL+"/.well-known/openid-configuration", &provider); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	keys, err := fetchJWKS(provider.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	provider.keys = keys
+	provider.fetchedAt = time.Now()
+	oidcDiscovered = &provider
+	return oidcDiscovered, nil
+}
+
+// key returns the provider's signing key with this ID
+func (p *oidcProvider) key(kid string) (*rsa.PublicKey, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	keys, err := fetchJWKS(p.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func fetchJWKS(uri string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(uri, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys failed: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func getJSON(uri string, v interface{}) error {
+	resp, err := oidcHTTPClient.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", uri, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// OIDCLogin - Start single sign-on by redirecting to the identity provider
+// GET /api/auth/oidc/login
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, status, msg := oidcAuthorizationURL("")
+	if status != 0 {
+		respondWithError(w, status, msg)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// StartSSOLink - Link the signed-in user to an identity at the identity provider. Accounts
+// that are never linked by email (admins) connect single sign-on this way. The client sends
+// the user to authorization_url; the callback then links the identity and signs them in.
+// POST /api/me/sso/link
+func StartSSOLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseImpersonated(w, r) {
+		return
+	}
+
+	authURL, status, msg := oidcAuthorizationURL(userID)
+	if status != 0 {
+		respondWithError(w, status, msg)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"authorization_url": authURL,
+	})
+}
+
+// oidcAuthorizationURL records a new sign-in attempt and returns the identity provider URL
+// to send the user to. A non-empty linkUserID makes the callback link the identity to that
+// user. A non-zero status is an error to return to the caller.
+func oidcAuthorizationURL(linkUserID string) (string, int, string) {
+	cfg := config.Get()
+	if cfg.OIDCIssuerURL == "" {
+		return "", http.StatusNotFound, "Single sign-on is not configured"
+	}
+
+	provider, err := getOIDCProvider()
+	if err != nil {
+		log.Printf("OIDC provider unavailable: %v", err)
+		return "", http.StatusBadGateway, "Identity provider is unavailable"
+	}
+
+	state, errState := generateRandomHex(16)
+	nonce, errNonce := generateRandomHex(16)
+	verifier, errVerifier := generateRandomHex(32)
+	if errState != nil || errNonce != nil || errVerifier != nil {
+		return "", http.StatusInternalServerError, "Error starting sign-in"
+	}
+
+	database.DB.Exec("DELETE FROM oidc_login_states WHERE created_at < $1", time.Now().Add(-oidcStateTTL))
+	_, err = database.DB.Exec(
+		"INSERT INTO oidc_login_states (state, nonce, code_verifier, link_user_id, created_at) VALUES ($1, $2, $3, NULLIF($4, ''), NOW())",
+		state, nonce, verifier, linkUserID,
+	)
+	if err != nil {
+		return "", http.StatusInternalServerError, "Error starting sign-in"
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.OIDCClientID},
+		"redirect_uri":          {cfg.OIDCRedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizationEndpoint + separator + params.Encode(), 0, ""
+}
+
+// OIDCCallback - Finish single sign-on: exchange the code, verify the ID token and log the
+// matching user in. Unknown users from an allowed domain are created as supervisors.
+// GET /api/auth/oidc/callback?code=...&state=...
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+	if cfg.OIDCIssuerURL == "" {
+		respondWithError(w, http.StatusNotFound, "Single sign-on is not configured")
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		respondWithError(w, http.StatusUnauthorized, "Sign-in was not completed: "+providerErr)
+		return
+	}
+
+	var nonce, verifier string
+	var linkUserID sql.NullString
+	err := database.DB.QueryRow(
+		"DELETE FROM oidc_login_states WHERE state = $1 AND created_at > $2 RETURNING nonce, code_verifier, link_user_id",
+		query.Get("state"), time.Now().Add(-oidcStateTTL),
+	).Scan(&nonce, &verifier, &linkUserID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Sign-in expired, please try again")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	provider, err := getOIDCProvider()
+	if err != nil {
+		log.Printf("OIDC provider unavailable: %v", err)
+		respondWithError(w, http.StatusBadGateway, "Identity provider is unavailable")
+		return
+	}
+
+	rawIDToken, err := exchangeOIDCCode(provider, query.Get("code"), verifier)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		recordLoginEvent(r, LoginMethodSSO, "", "", "", LoginDenied)
+		respondWithError(w, http.StatusUnauthorized, "Sign-in failed")
+		return
+	}
+
+	claims, err := verifyIDToken(provider, rawIDToken, nonce)
+	if err != nil {
+		log.Printf("OIDC ID token rejected: %v", err)
+		recordLoginEvent(r, LoginMethodSSO, "", "", "", LoginDenied)
+		respondWithError(w, http.StatusUnauthorized, "Sign-in failed")
+		return
+	}
+
+	user, status, msg := userForIdentity(provider.Issuer, claims, linkUserID.String)
+	if status != 0 {
+		recordLoginEvent(r, LoginMethodSSO, claims.Email, "", "", LoginDenied)
+		respondWithError(w, status, msg)
+		return
+	}
+
+	token, err := issueLoginToken(r, user.UserID, string(user.Role))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+	log.Printf("SSO login for %s (%s) from %s", user.UserID, user.Email, clientIP(r))
+	recordLoginEvent(r, LoginMethodSSO, user.Email, user.UserID, string(user.Role), LoginSuccess)
+
+	if cfg.OIDCPostLoginRedirect != "" {
+		fragment := url.Values{"token": {token}, "user_id": {user.UserID}, "role": {string(user.Role)}}
+		http.Redirect(w, r, cfg.OIDCPostLoginRedirect+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusOK, AuthResponse{
+		Token:          token,
+		UserID:         user.UserID,
+		Role:           string(user.Role),
+		User:           user,
+		OrganizationID: user.MiningSite,
+	})
+}
+
+// exchangeOIDCCode trades the authorization code for the provider's ID token
+func exchangeOIDCCode(provider *oidcProvider, code, verifier string) (string, error) {
+	cfg := config.Get()
+	resp, err := oidcHTTPClient.PostForm(provider.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.OIDCRedirectURL},
+		"client_id":     {cfg.OIDCClientID},
+		"client_secret": {cfg.OIDCClientSecret},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint returned %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	return body.IDToken, nil
+}
+
+// verifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func verifyIDToken(provider *oidcProvider, raw, nonce string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return provider.key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(config.Get().OIDCClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// userForIdentity finds the user an external identity belongs to: by a link made at an
+// earlier sign-in, by the link the signed-in user asked for (linkUserID), then by verified
+// email. Admin accounts are never linked by email. Unknown emails from an auto-provisioned
+// domain get a new supervisor account. A non-zero status is an error to return to the caller.
+func userForIdentity(issuer string, claims *oidcClaims, linkUserID string) (*models.User, int, string) {
+	email := models.NormalizeEmail(claims.Email)
+
+	var userID string
+	err := database.DB.QueryRow(
+		"SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2",
+		issuer, claims.Subject,
+	).Scan(&userID)
+	if err == nil && linkUserID != "" && userID != linkUserID {
+		return nil, http.StatusConflict, "This identity is already linked to another MineSafe account"
+	}
+	if err == sql.ErrNoRows && linkUserID != "" {
+		userID = linkUserID
+		_, err = database.DB.Exec(`
+			INSERT INTO user_identities (user_id, issuer, subject, email, created_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NOW())
+			ON CONFLICT (issuer, subject) DO NOTHING
+		`, userID, issuer, claims.Subject, email)
+	} else if err == sql.ErrNoRows {
+		if email == "" {
+			return nil, http.StatusForbidden, "Your identity provider did not share an email address"
+		}
+		if !claims.emailVerified() {
+			return nil, http.StatusForbidden, "Your identity provider has not verified this email address"
+		}
+		var role models.Role
+		err = database.DB.QueryRow("SELECT user_id, role FROM users WHERE LOWER(email) = $1", email).Scan(&userID, &role)
+		if err == nil && role == models.RoleAdmin {
+			return nil, http.StatusForbidden, "Admin accounts must sign in with a password and link single sign-on from their profile"
+		}
+		if err == sql.ErrNoRows {
+			userID, err = provisionSSOSupervisor(email, claims.Name)
+			if userID == "" && err == nil {
+				return nil, http.StatusForbidden, "No MineSafe account exists for " + email
+			}
+		}
+		// The identity provider has vouched for the address, which is all sign-up verification checks
+		if err == nil {
+			_, err = database.DB.Exec("UPDATE users SET email_verified = true WHERE user_id = $1 AND email_verified = false", userID)
+		}
+		if err == nil {
+			_, err = database.DB.Exec(`
+				INSERT INTO user_identities (user_id, issuer, subject, email, created_at)
+				VALUES ($1, $2, $3, $4, NOW())
+				ON CONFLICT (issuer, subject) DO NOTHING
+			`, userID, issuer, claims.Subject, email)
+		}
+	}
+	if err != nil {
+		log.Printf("SSO user lookup failed for %s: %v", email, err)
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+
+	var user models.User
+	var active bool
+	err = database.DB.QueryRow(
+		`SELECT id, user_id, name, email, phone, password, role, COALESCE(mining_site, ''), COALESCE(location, ''), supervisor_id, created_at, updated_at,
+		        COALESCE(is_active, true) AND deleted_at IS NULL
+		 FROM users WHERE user_id = $1`,
+		userID,
+	).Scan(&user.ID, &user.UserID, &user.Name, &user.Email, &user.Phone, &user.Password,
+		&user.Role, &user.MiningSite, &user.Location, &user.SupervisorID, &user.CreatedAt, &user.UpdatedAt, &active)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	if !active {
+		return nil, http.StatusForbidden, "This account has been deactivated"
+	}
+	if user.Role == models.RoleVisitor {
+		return nil, http.StatusForbidden, "Visitor accounts cannot use single sign-on"
+	}
+
+	database.DB.Exec(
+		"UPDATE user_identities SET last_login_at = NOW(), email = NULLIF($3, '') WHERE issuer = $1 AND subject = $2",
+		issuer, claims.Subject, email,
+	)
+	return &user, 0, ""
+}
+
+// provisionSSOSupervisor creates a supervisor for an email in an auto-provisioned domain.
+// It returns an empty ID when the domain is not allowed. The account gets a random password,
+// so it can only sign in through the identity provider until an admin sets one.
+func provisionSSOSupervisor(email, name string) (string, error) {
+	domain := email[strings.LastIndex(email, "@")+1:]
+	allowed := false
+	for _, d := range config.Get().OIDCAutoProvisionDomains {
+		if d == domain {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return "", nil
+	}
+
+	password, err := generateRandomHex(32)
+	if err != nil {
+		return "", err
+	}
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = email
+	}
+
+	user, err := models.NewUser(name, email, "", string(hashedPassword), "", "", models.RoleSupervisor, nil)
+	if err != nil {
+		return "", err
+	}
+	_, err = database.DB.Exec(
+		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		user.UserID, user.Name, user.Email, user.Phone, user.Password, user.Role,
+		user.MiningSite, user.Location, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Provisioned supervisor %s for %s through single sign-on", user.UserID, email)
+	return user.UserID, nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+// ==================== ONLINE MIGRATIONS ====================
+
+// OnlineMigrationProgress is the state of a schema change made in the background; see
+// database/online_migrations.go
+type OnlineMigrationProgress struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	RowsDone   int64      `json:"rows_done"`
+	RowsTotal  *int64     `json:"rows_total,omitempty"`
+	Percent    *float64   `json:"percent,omitempty"` // backfill progress
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// AdminGetOnlineMigrations - Progress of index builds, backfills and contract steps on large
+// tables, so a deploy's schema change can be followed to completion
+// GET /api/admin/migrations
+func AdminGetOnlineMigrations(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	rows, err := database.DB.Query(`
+		SELECT name, status, rows_done, rows_total, COALESCE(error, ''), started_at, updated_at, finished_at
+		FROM online_migrations
+		ORDER BY started_at DESC, name
+	`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	migrations := []OnlineMigrationProgress{}
+	for rows.Next() {
+		var m OnlineMigrationProgress
+		var rowsTotal sql.NullInt64
+		var finishedAt sql.NullTime
+		err := rows.Scan(&m.Name, &m.Status, &m.RowsDone, &rowsTotal, &m.Error, &m.StartedAt, &m.UpdatedAt, &finishedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		if rowsTotal.Valid {
+			m.RowsTotal = &rowsTotal.Int64
+			percent := 100.0
+			if rowsTotal.Int64 > 0 && m.RowsDone < rowsTotal.Int64 && m.Status != database.OnlineMigrationDone {
+				percent = float64(m.RowsDone) * 100 / float64(rowsTotal.Int64)
+			}
+			m.Percent = &percent
+		}
+		if finishedAt.Valid {
+			m.FinishedAt = &finishedAt.Time
+		}
+		migrations = append(migrations, m)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"migrations": migrations,
+	})
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// ==================== OPERATORS & EQUIPMENT CHECKLISTS ====================
+
+// Operators are crew members who run equipment. They do the same daily pre-start and PPE
+// checklists as miners and, before using a machine, its equipment checklist. Access is
+// granted through permissions (see middleware/permissions.go) so other roles can be given
+// the same rights later.
+
+// crewRoles are the roles counted as a supervisor's crew on dashboards: everyone who submits
+// daily checklists. Pass it as a query argument and compare with role = ANY(...).
+func crewRoles() interface{} {
+	return pq.Array(middleware.RolesWithPermission(middleware.PermChecklistSubmit))
+}
+
+// CreateOperator - Supervisor adds an operator to their crew
+// POST /api/supervisor/operators
+func CreateOperator(w http.ResponseWriter, r *http.Request) {
+	createCrewMember(w, r, models.RoleOperator)
+}
+
+// GetOperators - Supervisor lists their operators
+// GET /api/supervisor/operators
+func GetOperators(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		`SELECT id, user_id, name, email, phone, role, mining_site, location, supervisor_id, created_at, updated_at
+		 FROM users WHERE supervisor_id = $1 AND role = ANY($2) AND deleted_at IS NULL ORDER BY created_at DESC`,
+		supervisorID, pq.Array(middleware.RolesWithPermission(middleware.PermEquipmentChecklistSubmit)),
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	operators := []models.User{}
+	for rows.Next() {
+		var op models.User
+		err := rows.Scan(&op.ID, &op.UserID, &op.Name, &op.Email, &op.Phone,
+			&op.Role, &op.MiningSite, &op.Location, &op.SupervisorID, &op.CreatedAt, &op.UpdatedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning operator data")
+			return
+		}
+		operators = append(operators, op)
+	}
+
+	respondWithJSON(w, http.StatusOK, operators)
+}
+
+// CreateEquipmentChecklistItem - Supervisor creates an equipment check
+// POST /api/checklists/equipment
+func CreateEquipmentChecklistItem(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var item models.EquipmentChecklistItemCreate
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if item.Title == "" {
+		respondWithError(w, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	var newItem models.EquipmentChecklistItem
+	var description sql.NullString
+	err := database.DB.QueryRow(`
+		INSERT INTO equipment_checklist (supervisor_id, equipment_type, title, description, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, true, NOW(), NOW())
+		RETURNING id, supervisor_id, equipment_type, title, description, is_active, created_at, updated_at
+	`, supervisorID, strings.TrimSpace(item.EquipmentType), item.Title, item.Description).Scan(
+		&newItem.ID, &newItem.SupervisorID, &newItem.EquipmentType, &newItem.Title, &description,
+		&newItem.IsActive, &newItem.CreatedAt, &newItem.UpdatedAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating checklist item: "+err.Error())
+		return
+	}
+	newItem.Description = description.String
+
+	respondWithJSON(w, http.StatusCreated, newItem)
+}
+
+// GetEquipmentChecklistItems - Supervisor gets their equipment checks
+// GET /api/checklists/equipment?equipment_type=haul_truck
+func GetEquipmentChecklistItems(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, supervisor_id, equipment_type, title, description, is_active, created_at, updated_at
+		FROM equipment_checklist
+		WHERE supervisor_id = $1 AND is_active = true AND ($2 = '' OR equipment_type = $2)
+		ORDER BY equipment_type, created_at ASC
+	`, supervisorID, r.URL.Query().Get("equipment_type"))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.EquipmentChecklistItem{}
+	for rows.Next() {
+		var item models.EquipmentChecklistItem
+		var description sql.NullString
+		err := rows.Scan(&item.ID, &item.SupervisorID, &item.EquipmentType, &item.Title, &description,
+			&item.IsActive, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning item")
+			return
+		}
+		item.Description = description.String
+		items = append(items, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+// UpdateEquipmentChecklistItem - Supervisor edits an equipment check
+// PUT /api/checklists/equipment/{id}
+func UpdateEquipmentChecklistItem(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var item models.EquipmentChecklistItemCreate
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if item.Title == "" {
+		respondWithError(w, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE equipment_checklist SET equipment_type = $3, title = $4, description = $5, updated_at = NOW()
+		WHERE id = $1 AND supervisor_id = $2 AND is_active = true
+	`, mux.Vars(r)["id"], supervisorID, strings.TrimSpace(item.EquipmentType), item.Title, item.Description)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Item not found or cannot be edited")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item updated successfully"})
+}
+
+// DeleteEquipmentChecklistItem - Supervisor removes an equipment check
+// DELETE /api/checklists/equipment/{id}
+func DeleteEquipmentChecklistItem(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE equipment_checklist SET is_active = false, updated_at = NOW()
+		WHERE id = $1 AND supervisor_id = $2 AND is_active = true
+	`, mux.Vars(r)["id"], supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Item not found or cannot be deleted")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item deleted successfully"})
+}
+
+// GetEquipmentChecklistForApp - Operator gets the checks for one machine with today's results
+// GET /api/app/equipment/checklist?equipment_id=HT-07&equipment_type=haul_truck
+func GetEquipmentChecklistForApp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	equipmentID := strings.TrimSpace(r.URL.Query().Get("equipment_id"))
+	if equipmentID == "" {
+		respondWithError(w, http.StatusBadRequest, "equipment_id is required")
+		return
+	}
+
+	var supervisorID sql.NullString
+	database.DB.QueryRow("SELECT supervisor_id FROM users WHERE user_id = $1", userID).Scan(&supervisorID)
+	if !supervisorID.Valid || supervisorID.String == "" {
+		respondWithError(w, http.StatusBadRequest, "User is not assigned to a supervisor")
+		return
+	}
+
+	// Checks for every machine always apply; typed checks only for that type
+	rows, err := database.DB.Query(`
+		SELECT e.id, e.equipment_type, e.title, COALESCE(e.description, ''),
+			COALESCE(c.is_completed, false), COALESCE(c.notes, ''), c.completed_at
+		FROM equipment_checklist e
+		LEFT JOIN equipment_checklist_completions c
+			ON c.item_id = e.id AND c.user_id = $1 AND c.equipment_id = $3 AND c.date = $4
+		WHERE e.supervisor_id = $2 AND e.is_active = true
+		  AND (e.equipment_type = '' OR e.equipment_type = $5)
+		ORDER BY e.equipment_type, e.created_at ASC
+	`, userID, supervisorID.String, equipmentID, userToday(userID), r.URL.Query().Get("equipment_type"))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	items := []models.EquipmentItemWithStatus{}
+	for rows.Next() {
+		var item models.EquipmentItemWithStatus
+		var completedAt sql.NullTime
+		err := rows.Scan(&item.ID, &item.EquipmentType, &item.Title, &item.Description,
+			&item.IsCompleted, &item.Notes, &completedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning item")
+			return
+		}
+		if completedAt.Valid {
+			item.CompletedAt = &completedAt.Time
+		}
+		items = append(items, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+// UpdateEquipmentChecklistForApp - Operator records one check on one machine
+// PUT /api/app/equipment/checklist/complete
+func UpdateEquipmentChecklistForApp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var update models.EquipmentCompletionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	update.EquipmentID = strings.TrimSpace(update.EquipmentID)
+	if update.ItemID == 0 || update.EquipmentID == "" {
+		respondWithError(w, http.StatusBadRequest, "item_id and equipment_id are required")
+		return
+	}
+
+	// The item has to be one of the operator's supervisor's checks
+	result, err := database.DB.Exec(`
+		INSERT INTO equipment_checklist_completions (user_id, item_id, equipment_id, is_completed, notes, completed_at, date)
+		SELECT $1, e.id, $3, $4, NULLIF($5, ''), NOW(), $6
+		FROM equipment_checklist e
+		WHERE e.id = $2 AND e.is_active = true
+		  AND e.supervisor_id = (SELECT supervisor_id FROM users WHERE user_id = $1)
+		ON CONFLICT (user_id, item_id, equipment_id, date)
+		DO UPDATE SET is_completed = EXCLUDED.is_completed, notes = EXCLUDED.notes, completed_at = NOW()
+	`, userID, update.ItemID, update.EquipmentID, update.IsCompleted, update.Notes, userToday(userID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating completion: "+err.Error())
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Checklist item not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Completion updated successfully"})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ==================== ORGANIZATIONS (TENANCY) ====================
+
+// Every site belongs to an organization. Users, zones, checklists, modules and emergencies
+// carry the org_id of their site (or author), and handlers only show a caller rows from
+// their own organization. Modules without an organization are the shared library every
+// organization sees. Admins outside any organization operate the platform and see every
+// tenant.
+
+// orgCacheTTL is how long a user's organization is trusted before it is looked up again,
+// which bounds how long a user moved to another site keeps seeing the old one
+const orgCacheTTL = time.Minute
+
+type cachedOrg struct {
+	id       int
+	loadedAt time.Time
+}
+
+var (
+	orgCacheMu sync.Mutex
+	orgCache   = map[string]cachedOrg{}
+)
+
+// userOrganization returns the organization a user belongs to, 0 when they have none
+func userOrganization(userID string) (int, error) {
+	orgCacheMu.Lock()
+	cached, ok := orgCache[userID]
+	orgCacheMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < orgCacheTTL {
+		return cached.id, nil
+	}
+
+	var orgID sql.NullInt64
+	err := database.DB.QueryRow("SELECT org_id FROM users WHERE user_id = $1", userID).Scan(&orgID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	orgCacheMu.Lock()
+	if len(orgCache) > 10000 {
+		orgCache = map[string]cachedOrg{}
+	}
+	orgCache[userID] = cachedOrg{id: int(orgID.Int64), loadedAt: time.Now()}
+	orgCacheMu.Unlock()
+	return int(orgID.Int64), nil
+}
+
+// InitTenancy makes every authenticated request carry the caller's organization
+func InitTenancy() {
+	middleware.SetOrganizationResolver(userOrganization)
+}
+
+// forgetUserOrganization drops a cached organization after the user is moved
+func forgetUserOrganization(userID string) {
+	orgCacheMu.Lock()
+	delete(orgCache, userID)
+	orgCacheMu.Unlock()
+}
+
+// requestOrg is the caller's organization as a query argument: NULL when they have none,
+// so they only match rows that have none either
+func requestOrg(r *http.Request) sql.NullInt64 {
+	orgID := middleware.GetOrgIDFromContext(r.Context())
+	return sql.NullInt64{Int64: int64(orgID), Valid: orgID != 0}
+}
+
+// platformAdmin reports whether the caller is an admin outside any organization
+func platformAdmin(r *http.Request) bool {
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	return role == string(models.RoleAdmin) && middleware.GetOrgIDFromContext(r.Context()) == 0
+}
+
+// refuseTenantAdmin responds 403 unless the caller is a platform admin, for settings that
+// apply to every organization such as signing keys, maintenance mode, role permissions and
+// backups. It reports whether it did.
+func refuseTenantAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if platformAdmin(r) {
+		return false
+	}
+	respondWithError(w, http.StatusForbidden, "Only platform admins can change platform-wide settings")
+	return true
+}
+
+// siteInOrganization reports whether a site with this name belongs to the caller's
+// organization
+func siteInOrganization(r *http.Request, siteName string) (bool, error) {
+	args := []interface{}{siteName}
+	scope := tenantClause(r, "org_id", &args)
+	var ok bool
+	err := database.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM sites WHERE LOWER(name) = LOWER(TRIM($1))"+scope+")",
+		args...,
+	).Scan(&ok)
+	return ok, err
+}
+
+// tenantClause restricts column to the caller's organization, appending the argument it
+// refers to. Platform admins aren't restricted.
+func tenantClause(r *http.Request, column string, args *[]interface{}) string {
+	if platformAdmin(r) {
+		return ""
+	}
+	*args = append(*args, requestOrg(r))
+	return " AND " + column + " IS NOT DISTINCT FROM $" + strconv.Itoa(len(*args))
+}
+
+// sharedTenantClause is tenantClause for modules, which also matches the shared library
+func sharedTenantClause(r *http.Request, column string, args *[]interface{}) string {
+	if platformAdmin(r) {
+		return ""
+	}
+	*args = append(*args, requestOrg(r))
+	n := strconv.Itoa(len(*args))
+	return " AND (" + column + " IS NULL OR " + column + " = $" + n + ")"
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== QUIZ PASSING SCORES ====================
+
+// Each module can set its own pass mark; modules without one use the retake policy's
+// passing_percent. Only a passing attempt marks the day's completion as passed, and only
+// passed completions count towards streaks, star videos, compliance and assignments.
+
+// modulePassingPercent returns the pass mark for a module's quiz
+func modulePassingPercent(videoID int, policy ModuleRetakePolicy) (float64, error) {
+	var passingScore sql.NullFloat64
+	err := database.DB.QueryRow("SELECT passing_score FROM video_modules WHERE id = $1", videoID).Scan(&passingScore)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if passingScore.Valid {
+		return passingScore.Float64, nil
+	}
+	return policy.PassingPercent, nil
+}
+
+// validPassingScore reports whether an optional pass mark is unset or within 1-100
+func validPassingScore(score *float64) bool {
+	return score == nil || (*score > 0 && *score <= 100)
+}
+
+// quizResult is the pass/fail result shown for a submitted attempt
+func quizResult(passed bool) string {
+	if passed {
+		return "pass"
+	}
+	return "fail"
+}
+
+// SetModulePassingScore - Set or clear the pass mark of one of this supervisor's modules.
+// A null passing_score falls back to the retake policy's passing_percent.
+// PUT /api/supervisor/modules/{id}/passing-score
+func SetModulePassingScore(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	moduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+
+	var req struct {
+		PassingScore *float64 `json:"passing_score"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !validPassingScore(req.PassingScore) {
+		respondWithError(w, http.StatusBadRequest, "passing_score must be between 1 and 100")
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE video_modules SET passing_score = $1, updated_at = NOW()
+		WHERE id = $2 AND created_by = $3
+	`, req.PassingScore, moduleID, supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating module: "+err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Module not found")
+		return
+	}
+
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading retake policy")
+		return
+	}
+	passingPercent, err := modulePassingPercent(moduleID, policy)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"module_id":       moduleID,
+		"passing_score":   req.PassingScore,
+		"passing_percent": passingPercent,
+	})
+}
+
+// ModuleFailureRate is how a supervisor's crew is doing on one module's quiz
+type ModuleFailureRate struct {
+	VideoID         int     `json:"video_id"`
+	Title           string  `json:"title"`
+	PassingPercent  float64 `json:"passing_percent"`
+	Attempts        int     `json:"attempts"`
+	FailedAttempts  int     `json:"failed_attempts"`
+	FailureRate     float64 `json:"failure_rate"`
+	AverageScore    float64 `json:"average_percentage"`
+	MinersAttempted int     `json:"miners_attempted"`
+	// Miners who attempted the quiz in the window but haven't passed it yet
+	MinersNotPassed int `json:"miners_not_passed"`
+}
+
+// GetModuleFailureRates - Quiz attempts and failure rates per module for this supervisor's
+// crew, highest failure rate first
+// GET /api/supervisor/modules/failure-rates?days=30
+func GetModuleFailureRates(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading retake policy")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		WITH attempts AS (
+			SELECT a.* FROM module_attempts a
+			WHERE a.miner_id IN (SELECT user_id FROM users WHERE supervisor_id = ANY($1) AND role = ANY($2) AND deleted_at IS NULL)
+			AND a.attempted_at >= NOW() - make_interval(days => $3)
+		),
+		per_miner AS (
+			SELECT video_id, miner_id, BOOL_OR(passed) AS passed FROM attempts GROUP BY video_id, miner_id
+		)
+		SELECT vm.id, vm.title, COALESCE(vm.passing_score, $4),
+		       COUNT(a.id), COUNT(a.id) FILTER (WHERE NOT a.passed), COALESCE(AVG(a.percentage), 0),
+		       (SELECT COUNT(*) FROM per_miner pm WHERE pm.video_id = vm.id),
+		       (SELECT COUNT(*) FROM per_miner pm WHERE pm.video_id = vm.id AND NOT pm.passed)
+		FROM attempts a
+		JOIN video_modules vm ON vm.id = a.video_id
+		GROUP BY vm.id, vm.title, vm.passing_score
+		ORDER BY COUNT(a.id) FILTER (WHERE NOT a.passed)::float / COUNT(a.id) DESC, vm.title
+	`, managedSupervisors(supervisorID), crewRoles(), days, policy.PassingPercent)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	modules := []ModuleFailureRate{}
+	for rows.Next() {
+		var m ModuleFailureRate
+		if err := rows.Scan(&m.VideoID, &m.Title, &m.PassingPercent, &m.Attempts, &m.FailedAttempts, &m.AverageScore,
+			&m.MinersAttempted, &m.MinersNotPassed); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning module data: "+err.Error())
+			return
+		}
+		if m.Attempts > 0 {
+			m.FailureRate = float64(m.FailedAttempts) / float64(m.Attempts) * 100
+		}
+		modules = append(modules, m)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"modules": modules,
+		"days":    days,
+	})
+}
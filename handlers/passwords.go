@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/internal/passhash"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"unicode"
+)
+
+// ==================== PASSWORDS ====================
+
+// bcrypt ignores everything after 72 bytes, so longer passwords are refused rather than truncated
+const maxPasswordBytes = 72
+
+// ConfigurePasswordHashing makes new password hashes with PASSWORD_HASH_ALGORITHM and its
+// cost settings
+func ConfigurePasswordHashing(cfg *config.Config) {
+	if cfg.PasswordHashAlgorithm == passhash.Bcrypt {
+		passhash.Configure(passhash.BcryptHasher{Cost: cfg.BcryptCost})
+		return
+	}
+	passhash.Configure(passhash.Argon2idHasher{
+		MemoryKiB:   uint32(cfg.Argon2MemoryKiB),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	})
+}
+
+// hashPassword hashes a password for storage with the configured algorithm
+func hashPassword(password string) (string, error) {
+	return passhash.Hash(password)
+}
+
+// checkPassword reports whether password matches the user's stored hash. A hash made with
+// another algorithm or cost is replaced while the password is at hand, so stored hashes
+// migrate as users log in.
+func checkPassword(userID, hash, password string) bool {
+	ok, rehash := passhash.Verify(hash, password)
+	if !ok || !rehash {
+		return ok
+	}
+	upgraded, err := passhash.Hash(password)
+	if err != nil {
+		log.Printf("Warning: failed to rehash password for %s: %v", userID, err)
+		return true
+	}
+	// Only replaces the hash that was checked, so a concurrent password change wins
+	if _, err := database.DB.Exec(
+		"UPDATE users SET password = $1 WHERE user_id = $2 AND password = $3", upgraded, userID, hash,
+	); err != nil {
+		log.Printf("Warning: failed to store rehashed password for %s: %v", userID, err)
+	}
+	return true
+}
+
+// checkPasswordPolicy reports why a password users chose doesn't meet PASSWORD_MIN_LENGTH and
+// PASSWORD_MIN_CHARACTER_CLASSES, or nil when it does
+func checkPasswordPolicy(password string) error {
+	cfg := config.Get()
+	if len([]rune(password)) < cfg.PasswordMinLength {
+		return fmt.Errorf("Password must be at least %d characters", cfg.PasswordMinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("Password must be at most %d bytes", maxPasswordBytes)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < cfg.PasswordMinClasses {
+		return fmt.Errorf("Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", cfg.PasswordMinClasses)
+	}
+	return nil
+}
+
+// ChangePasswordRequest represents the request body for changing one's own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword - Change the current user's password after checking the old one. Every
+// existing token, including the one used for this request, is revoked; log in again.
+// PUT /api/me/password
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseImpersonated(w, r) {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		respondWithError(w, http.StatusBadRequest, "current_password and new_password are required")
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		respondWithError(w, http.StatusBadRequest, "The new password must be different from the current one")
+		return
+	}
+	if err := checkPasswordPolicy(req.NewPassword); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var currentHash string
+	err := database.DB.QueryRow("SELECT password FROM users WHERE user_id = $1", userID).Scan(&currentHash)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	// Verify without upgrading the hash: the UPDATE below must still find the hash it checked
+	if ok, _ := passhash.Verify(currentHash, req.CurrentPassword); !ok {
+		// 403 rather than 401 so clients don't treat it as an expired session
+		respondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+
+	hashedPassword, err := hashPassword(req.NewPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+
+	// Only replace the hash that was checked, so a concurrent change isn't silently overwritten
+	result, err := database.DB.Exec(
+		"UPDATE users SET password = $1, updated_at = NOW() WHERE user_id = $2 AND password = $3",
+		string(hashedPassword), userID, currentHash,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusConflict, "The password was changed by another request; try again")
+		return
+	}
+
+	if err := revokeUserTokens(userID, userID, "password_changed", time.Now()); err != nil {
+		// The password has changed; report it but don't pretend it failed
+		log.Printf("Warning: failed to revoke tokens of %s after password change: %v", userID, err)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Password changed. Log in again with the new password on every device.",
+	})
+}
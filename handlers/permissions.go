@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// ==================== PERMISSIONS ====================
+
+// PermissionGrants describes a permission and the roles currently granted it
+type PermissionGrants struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+// LoadPermissions records any permission the code knows about but the database doesn't yet,
+// grants it to its default roles, and then loads every grant into the middleware. Defaults
+// are only applied to new permissions so a grant an admin revoked stays revoked.
+func LoadPermissions() error {
+	for perm, description := range middleware.PermissionDescriptions {
+		result, err := database.DB.Exec(`
+			INSERT INTO permissions (name, description) VALUES ($1, $2)
+			ON CONFLICT (name) DO NOTHING
+		`, string(perm), description)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		for role, perms := range middleware.DefaultRolePermissions {
+			for _, p := range perms {
+				if p != perm {
+					continue
+				}
+				_, err := database.DB.Exec(`
+					INSERT INTO role_permissions (role, permission, granted_by) VALUES ($1, $2, 'SYSTEM')
+					ON CONFLICT DO NOTHING
+				`, role, string(perm))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		log.Printf("Added permission %s", perm)
+	}
+	return loadRolePermissions()
+}
+
+func loadRolePermissions() error {
+	rows, err := database.DB.Query("SELECT role, permission FROM role_permissions")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	grants := map[string][]middleware.Permission{}
+	for rows.Next() {
+		var role, perm string
+		if err := rows.Scan(&role, &perm); err != nil {
+			return err
+		}
+		grants[role] = append(grants[role], middleware.Permission(perm))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	middleware.SetRolePermissions(grants)
+	return nil
+}
+
+// StartPermissionSync periodically reloads role grants changed on other instances
+func StartPermissionSync(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := loadRolePermissions(); err != nil {
+				log.Printf("Warning: failed to reload role permissions: %v", err)
+			}
+		}
+	}()
+}
+
+// AdminGetPermissions - List permissions and the roles granted each
+// GET /api/admin/permissions
+func AdminGetPermissions(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	rows, err := database.DB.Query(`
+		SELECT p.name, p.description, COALESCE(array_agg(rp.role ORDER BY rp.role) FILTER (WHERE rp.role IS NOT NULL), '{}')
+		FROM permissions p
+		LEFT JOIN role_permissions rp ON rp.permission = p.name
+		GROUP BY p.name, p.description
+		ORDER BY p.name
+	`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	permissions := []PermissionGrants{}
+	for rows.Next() {
+		var p PermissionGrants
+		if err := rows.Scan(&p.Name, &p.Description, pq.Array(&p.Roles)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		permissions = append(permissions, p)
+	}
+
+	roles := make([]string, 0, len(models.Roles))
+	for _, role := range models.Roles {
+		roles = append(roles, string(role))
+	}
+	sort.Strings(roles)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"permissions": permissions,
+		"roles":       roles,
+	})
+}
+
+// AdminGrantPermission - Grant a permission to a role
+// PUT /api/admin/permissions/{permission}/roles/{role}
+func AdminGrantPermission(w http.ResponseWriter, r *http.Request) {
+	changePermissionGrant(w, r, true)
+}
+
+// AdminRevokePermission - Take a permission away from a role
+// DELETE /api/admin/permissions/{permission}/roles/{role}
+func AdminRevokePermission(w http.ResponseWriter, r *http.Request) {
+	changePermissionGrant(w, r, false)
+}
+
+func changePermissionGrant(w http.ResponseWriter, r *http.Request, grant bool) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	perm, role := vars["permission"], vars["role"]
+
+	if _, known := middleware.PermissionDescriptions[middleware.Permission(perm)]; !known {
+		respondWithError(w, http.StatusNotFound, "Unknown permission: "+perm)
+		return
+	}
+	validRole := false
+	for _, rl := range models.Roles {
+		if string(rl) == role {
+			validRole = true
+		}
+	}
+	if !validRole {
+		respondWithError(w, http.StatusBadRequest, "Unknown role: "+role)
+		return
+	}
+
+	var err error
+	if grant {
+		_, err = database.DB.Exec(`
+			INSERT INTO role_permissions (role, permission, granted_by, granted_at) VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (role, permission) DO NOTHING
+		`, role, perm, adminID)
+	} else {
+		_, err = database.DB.Exec("DELETE FROM role_permissions WHERE role = $1 AND permission = $2", role, perm)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating permission: "+err.Error())
+		return
+	}
+
+	if err := loadRolePermissions(); err != nil {
+		log.Printf("Warning: failed to reload role permissions: %v", err)
+	}
+	broadcastInvalidation(cachePermissions)
+	action := "revoked"
+	if grant {
+		action = "granted"
+	}
+	log.Printf("Permission %s %s for role %s by %s", perm, action, role, adminID)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"permission": perm,
+		"role":       role,
+		"granted":    grant,
+	})
+}
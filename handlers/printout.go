@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/internal/pdf"
+	"MineSafeBackend/middleware"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ==================== PRINTABLE PRE-START SHEET ====================
+
+// Some sites still post a paper copy of the day's checklist status at the portal
+
+const (
+	printoutMargin    = 40.0
+	printoutRowHeight = 20.0
+)
+
+// printoutColumns are the left edges of the crew table columns; the last entry is the right edge
+var printoutColumns = []struct {
+	title string
+	x     float64
+}{
+	{"Name", printoutMargin},
+	{"Role", 215},
+	{"Pre-start", 290},
+	{"PPE checklist", 355},
+	{"PPE scan", 435},
+	{"Signature", 490},
+	{"", pdf.A4Width - printoutMargin},
+}
+
+type printoutRow struct {
+	name, role       string
+	preStart, ppe    int
+	ppeScanSubmitted bool
+}
+
+// activeChecklistItems lists the ids and titles of the items a supervisor's crew ticks off
+// in table (pre_start_checklist or ppe_checklist)
+func activeChecklistItems(table, supervisorID string) ([]int64, []string, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, title FROM `+table+`
+		WHERE (supervisor_id = $1 OR (is_default = true AND mining_site = (SELECT mining_site FROM users WHERE user_id = $1))) AND is_active = true
+		ORDER BY is_default DESC, created_at ASC
+	`, supervisorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	ids, titles := []int64{}, []string{}
+	for rows.Next() {
+		var id int64
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		titles = append(titles, title)
+	}
+	return ids, titles, rows.Err()
+}
+
+// GetChecklistPrintout - Printable PDF of each crew member's pre-start and PPE status for a day
+// GET /api/supervisor/checklists/printout?date=YYYY-MM-DD
+func GetChecklistPrintout(w http.ResponseWriter, r *http.Request) {
+	supervisorID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = userToday(supervisorID)
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+	settings := userSiteSettings(supervisorID)
+	period := checklistPeriod(day, settings.ChecklistFrequency)
+
+	preIDs, preTitles, err := activeChecklistItems("pre_start_checklist", supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	ppeIDs, ppeTitles, err := activeChecklistItems("ppe_checklist", supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var supervisorName string
+	database.DB.QueryRow("SELECT name FROM users WHERE user_id = $1", supervisorID).Scan(&supervisorName)
+	_, siteName, _ := supervisorSite(supervisorID)
+	branding := brandingForUser(supervisorID)
+
+	rows, err := database.DB.Query(`
+		SELECT u.name, u.role,
+			(SELECT COUNT(*) FROM pre_start_checklist_completions c
+			 WHERE c.user_id = u.user_id AND c.date = $2 AND c.is_completed = true AND c.item_id = ANY($4)),
+			(SELECT COUNT(*) FROM ppe_checklist_completions c
+			 WHERE c.user_id = u.user_id AND c.date = $2 AND c.is_completed = true AND c.item_id = ANY($5)),
+			EXISTS(SELECT 1 FROM ppe_stats p WHERE p.user_id = u.user_id AND p.date = $3)
+		FROM users u
+		WHERE u.supervisor_id = $1 AND u.role = ANY($6) AND u.is_active = true
+		ORDER BY u.name
+	`, supervisorID, period, date, pq.Array(preIDs), pq.Array(ppeIDs), crewRoles())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	crew := []printoutRow{}
+	for rows.Next() {
+		var row printoutRow
+		if err := rows.Scan(&row.name, &row.role, &row.preStart, &row.ppe, &row.ppeScanSubmitted); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning crew")
+			return
+		}
+		crew = append(crew, row)
+	}
+	if err := rows.Err(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	doc := pdf.New(pdf.A4Width, pdf.A4Height)
+	y := 0.0
+	newPage := func() {
+		doc.AddPage()
+		top := branding.pdfBanner(doc, printoutMargin, "Daily Pre-Start Sheet")
+		header := "Date: " + day.Format("Monday 2 January 2006")
+		if siteName != "" {
+			header = "Site: " + siteName + "    " + header
+		}
+		doc.Text(printoutMargin, top+18, 10, false, header)
+		doc.Text(printoutMargin, top+32, 10, false, "Supervisor: "+supervisorName)
+		if period != date {
+			doc.Text(printoutMargin, top+46, 9, false, "Weekly checklists: ticks count for the week starting "+period)
+		}
+		y = top + 63
+	}
+	tableHeader := func() {
+		doc.Line(printoutMargin, y, pdf.A4Width-printoutMargin, y)
+		for _, col := range printoutColumns {
+			doc.Text(col.x+3, y+14, 9, true, col.title)
+		}
+		y += printoutRowHeight
+		doc.Line(printoutMargin, y, pdf.A4Width-printoutMargin, y)
+	}
+	// Ensure room for height more points, starting a new page (and table header) if needed
+	reserve := func(height float64, withTable bool) {
+		if y+height > pdf.A4Height-60 {
+			newPage()
+			if withTable {
+				tableHeader()
+			}
+		}
+	}
+
+	newPage()
+	tableHeader()
+	for _, row := range crew {
+		reserve(printoutRowHeight, true)
+		scan := "No"
+		if row.ppeScanSubmitted {
+			scan = "Yes"
+		}
+		cells := []string{
+			truncate(row.name, 32),
+			row.role,
+			fmt.Sprintf("%d / %d", row.preStart, len(preIDs)),
+			fmt.Sprintf("%d / %d", row.ppe, len(ppeIDs)),
+			scan,
+			"",
+		}
+		for i, cell := range cells {
+			doc.Text(printoutColumns[i].x+3, y+14, 9, false, cell)
+		}
+		top := y
+		y += printoutRowHeight
+		doc.Line(printoutMargin, y, pdf.A4Width-printoutMargin, y)
+		for _, col := range printoutColumns {
+			doc.Line(col.x, top, col.x, y)
+		}
+	}
+	if len(crew) == 0 {
+		doc.Text(printoutMargin+3, y+14, 9, false, "No crew members assigned.")
+		y += printoutRowHeight
+	}
+
+	// The item lists let whoever reads the sheet see what each count covers
+	for _, list := range []struct {
+		title  string
+		titles []string
+	}{{"Pre-start checklist", preTitles}, {"PPE checklist", ppeTitles}} {
+		y += 10
+		reserve(30, false)
+		y += 14
+		doc.Text(printoutMargin, y, 11, true, list.title)
+		y += 4
+		for i, title := range list.titles {
+			reserve(14, false)
+			y += 14
+			doc.Text(printoutMargin+10, y, 9, false, strconv.Itoa(i+1)+". "+truncate(title, 90))
+		}
+	}
+
+	generated := "Generated " + time.Now().In(userLocation(supervisorID)).Format("2006-01-02 15:04 MST")
+	for i := 0; i < doc.PageCount(); i++ {
+		doc.SetPage(i)
+		doc.Text(printoutMargin, pdf.A4Height-30, 8, false, generated)
+		doc.Text(pdf.A4Width-printoutMargin-50, pdf.A4Height-30, 8, false, fmt.Sprintf("Page %d of %d", i+1, doc.PageCount()))
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"pre-start-%s.pdf\"", date))
+	w.Write(doc.Bytes())
+}
@@ -93,12 +93,15 @@ type UserProfileResponse struct {
 	MiningSite        string    `json:"mining_site"`
 	ProfilePictureURL string    `json:"profile_picture_url,omitempty"`
 	Tags              []string  `json:"tags"`
+	Timezone          string    `json:"timezone"`
 	CreatedAt         time.Time `json:"created_at"`
 }
 
 type UpdateProfileRequest struct {
 	Name  string `json:"name,omitempty"`
 	Phone string `json:"phone,omitempty"`
+	// IANA name such as Asia/Kolkata; an empty string goes back to the site's timezone
+	Timezone *string `json:"timezone,omitempty"`
 }
 
 // GetUserProfile - GET /api/app/profile
@@ -136,8 +139,10 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	if miningSite.Valid {
 		profile.MiningSite = miningSite.String
 	}
+	// The timezone dates are counted in, whether set by the user or inherited from the site
+	profile.Timezone = userLocation(userID).String()
 	if profilePic.Valid {
-		profile.ProfilePictureURL = profilePic.String
+		profile.ProfilePictureURL = signUploadURL(profilePic.String)
 	}
 
 	json.Unmarshal(tagsJSON, &profile.Tags)
@@ -167,6 +172,14 @@ func UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Phone != "" {
+		email := ""
+		if code, msg := checkContactAvailable(&email, &req.Phone, userID); code != 0 {
+			respondWithError(w, code, msg)
+			return
+		}
+	}
+
 	// Build dynamic update query
 	updates := []string{}
 	args := []interface{}{}
@@ -182,6 +195,17 @@ func UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
 		args = append(args, req.Phone)
 		argCount++
 	}
+	if req.Timezone != nil {
+		if *req.Timezone != "" {
+			if _, err := time.LoadLocation(*req.Timezone); err != nil {
+				respondWithError(w, http.StatusBadRequest, "Unknown timezone: "+*req.Timezone)
+				return
+			}
+		}
+		updates = append(updates, "timezone = NULLIF($"+string(rune('0'+argCount))+", '')")
+		args = append(args, *req.Timezone)
+		argCount++
+	}
 
 	if len(updates) == 0 {
 		respondWithError(w, http.StatusBadRequest, "No fields to update")
@@ -237,9 +261,12 @@ func UploadProfilePicture(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Only JPG, PNG, and GIF files are allowed")
 		return
 	}
+	if !screenUpload(w, uploadPolicyForUser(userID), file) {
+		return
+	}
 
 	// Create uploads directory
-	uploadsDir := "uploads/profile_pictures"
+	uploadsDir := uploadDir("profile_pictures")
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create upload directory")
 		return
@@ -275,7 +302,7 @@ func UploadProfilePicture(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"success":             true,
-		"profile_picture_url": pictureURL,
+		"profile_picture_url": signUploadURL(pictureURL),
 		"message":             "Profile picture uploaded successfully",
 	})
 }
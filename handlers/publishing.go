@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== MODULE STAGING & SCHEDULED PUBLISHING ====================
+
+// Approval statuses of modules that are staged rather than live
+const (
+	ModuleStatusDraft     = "draft"
+	ModuleStatusScheduled = "scheduled"
+)
+
+// StagedModule is a draft or scheduled module shown to its author
+type StagedModule struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Category  string     `json:"category"`
+	VideoURL  string     `json:"video_url"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// StartScheduledPublishJob periodically publishes modules whose publish time has passed
+func StartScheduledPublishJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			publishDueModules()
+			<-ticker.C
+		}
+	}()
+}
+
+// publishDueModules flips due modules live. The UPDATE claims each module, so with several
+// instances running only one of them sends the notifications.
+func publishDueModules() {
+	rows, err := database.DB.Query(`
+		UPDATE video_modules
+		SET is_active = true, approval_status = 'approved', published_at = NOW(), updated_at = NOW()
+		WHERE approval_status = $1 AND publish_at <= NOW()
+		RETURNING id, title, COALESCE(created_by, '')
+	`, ModuleStatusScheduled)
+	if err != nil {
+		log.Printf("Warning: scheduled publishing failed: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	type published struct {
+		id        int
+		title     string
+		createdBy string
+	}
+	modules := []published{}
+	for rows.Next() {
+		var m published
+		if err := rows.Scan(&m.id, &m.title, &m.createdBy); err != nil {
+			continue
+		}
+		modules = append(modules, m)
+	}
+	rows.Close()
+
+	for _, m := range modules {
+		notifyModulePublished(m.id, m.title, m.createdBy)
+	}
+}
+
+// notifyModulePublished tells the author's miners, and the author, that a module is live
+func notifyModulePublished(moduleID int, title, createdBy string) {
+	ref := strconv.Itoa(moduleID)
+	rows, err := database.DB.Query("SELECT user_id FROM users WHERE supervisor_id = $1 AND role = ANY($2) AND deleted_at IS NULL", createdBy, crewRoles())
+	if err != nil {
+		log.Printf("Warning: failed to load miners for module %d: %v", moduleID, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var minerID string
+		if err := rows.Scan(&minerID); err != nil {
+			continue
+		}
+		notifyUser(minerID, "MODULE_PUBLISHED", "New training module",
+			fmt.Sprintf("\"%s\" is now available to watch", title), "video_module", ref)
+	}
+	notifyUser(createdBy, "MODULE_PUBLISHED", "Module published",
+		fmt.Sprintf("Your module \"%s\" is now live", title), "video_module", ref)
+}
+
+// GetStagedModules - Draft and scheduled modules created by the current supervisor
+// GET /api/supervisor/modules/staged
+func GetStagedModules(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, title, COALESCE(category, ''), video_url, approval_status, publish_at, created_at, updated_at
+		FROM video_modules
+		WHERE created_by = $1 AND approval_status IN ($2, $3)
+		ORDER BY publish_at ASC NULLS LAST, created_at DESC
+	`, supervisorID, ModuleStatusDraft, ModuleStatusScheduled)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	modules := []StagedModule{}
+	for rows.Next() {
+		var m StagedModule
+		var publishAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Title, &m.Category, &m.VideoURL, &m.Status, &publishAt, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning module data: "+err.Error())
+			return
+		}
+		if publishAt.Valid {
+			m.PublishAt = &publishAt.Time
+		}
+		m.VideoURL = signUploadURL(m.VideoURL)
+		modules = append(modules, m)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"modules": modules,
+	})
+}
+
+// PublishModuleRequest represents the request body for scheduling a staged module.
+// Without publish_at the module is published immediately.
+type PublishModuleRequest struct {
+	PublishAt  *time.Time `json:"publish_at"`
+	Unschedule bool       `json:"unschedule"` // move a scheduled module back to draft
+}
+
+// PublishModule - Schedule, unschedule or immediately publish a staged module
+// PUT /api/supervisor/modules/{id}/publish
+func PublishModule(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	moduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+
+	var req PublishModuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var title, status string
+	err = database.DB.QueryRow(`
+		SELECT title, COALESCE(approval_status, '') FROM video_modules WHERE id = $1 AND created_by = $2
+	`, moduleID, supervisorID).Scan(&title, &status)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Module not found")
+		return
+	}
+	if status != ModuleStatusDraft && status != ModuleStatusScheduled {
+		respondWithError(w, http.StatusConflict, "Only draft or scheduled modules can be published")
+		return
+	}
+
+	switch {
+	case req.Unschedule:
+		_, err = database.DB.Exec(`
+			UPDATE video_modules SET approval_status = $1, publish_at = NULL, updated_at = NOW() WHERE id = $2
+		`, ModuleStatusDraft, moduleID)
+	case req.PublishAt != nil && req.PublishAt.After(time.Now()):
+		_, err = database.DB.Exec(`
+			UPDATE video_modules SET approval_status = $1, publish_at = $2, updated_at = NOW() WHERE id = $3
+		`, ModuleStatusScheduled, *req.PublishAt, moduleID)
+	default:
+		var result sql.Result
+		result, err = database.DB.Exec(`
+			UPDATE video_modules
+			SET is_active = true, approval_status = 'approved', publish_at = NULL, published_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND approval_status IN ($2, $3)
+		`, moduleID, ModuleStatusDraft, ModuleStatusScheduled)
+		// The scheduler may have published it in the meantime; only notify once
+		if err == nil {
+			if n, _ := result.RowsAffected(); n > 0 {
+				notifyModulePublished(moduleID, title, supervisorID)
+			}
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating module: "+err.Error())
+		return
+	}
+
+	var module StagedModule
+	var publishAt sql.NullTime
+	database.DB.QueryRow(`
+		SELECT id, title, COALESCE(category, ''), video_url, COALESCE(approval_status, ''), publish_at, created_at, updated_at
+		FROM video_modules WHERE id = $1
+	`, moduleID).Scan(&module.ID, &module.Title, &module.Category, &module.VideoURL, &module.Status,
+		&publishAt, &module.CreatedAt, &module.UpdatedAt)
+	if publishAt.Valid {
+		module.PublishAt = &publishAt.Time
+	}
+	if module.Status == "approved" {
+		module.Status = "published"
+	}
+	module.VideoURL = signUploadURL(module.VideoURL)
+
+	respondWithJSON(w, http.StatusOK, module)
+}
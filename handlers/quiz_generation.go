@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== QUIZ QUESTION DRAFTS ====================
+
+// Supervisors can have quiz questions drafted from a module's transcript by a configured
+// LLM endpoint. Drafts are never shown to miners: each one is approved (optionally edited)
+// into the module's questions or rejected by a supervisor.
+
+// Question draft review states
+const (
+	QuestionDraftPending  = "DRAFT"
+	QuestionDraftApproved = "APPROVED"
+	QuestionDraftRejected = "REJECTED"
+)
+
+const (
+	defaultDraftQuestions = 5
+	maxDraftQuestions     = 15
+	// maxTranscriptLength keeps requests within the context window of common models
+	maxTranscriptLength = 60000
+	minQuestionOptions  = 2
+	maxQuestionOptions  = 6
+)
+
+var quizGenerationHTTPClient = &http.Client{Timeout: 90 * time.Second}
+
+// quizGenerationPrompt tells the model what to write and the JSON shape to answer in
+const quizGenerationPrompt = `You write multiple-choice quiz questions for mine safety training videos.
+Only ask about facts stated in the transcript. Each question has 4 options with exactly one correct answer.
+Reply with JSON only, in this shape:
+{"questions": [{"question": "...", "options": ["...", "...", "...", "..."], "answer": 0, "explanation": "..."}]}
+where answer is the zero-based index of the correct option and explanation says briefly why it is correct.`
+
+// QuestionDraft is a generated question awaiting review
+type QuestionDraft struct {
+	ID          int        `json:"id"`
+	VideoID     int        `json:"video_id"`
+	Question    string     `json:"question"`
+	Options     []string   `json:"options"`
+	Answer      int        `json:"answer"`
+	Explanation string     `json:"explanation,omitempty"`
+	Status      string     `json:"status"`
+	Model       string     `json:"model,omitempty"`
+	GeneratedBy *string    `json:"generated_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReviewedBy  *string    `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	QuestionID  *int       `json:"question_id,omitempty"`
+}
+
+// validateQuizQuestion checks a drafted or edited question before it is stored
+func validateQuizQuestion(question string, options []string, answer int) error {
+	if strings.TrimSpace(question) == "" {
+		return errors.New("question is required")
+	}
+	if len(options) < minQuestionOptions || len(options) > maxQuestionOptions {
+		return fmt.Errorf("a question needs between %d and %d options", minQuestionOptions, maxQuestionOptions)
+	}
+	for _, option := range options {
+		if strings.TrimSpace(option) == "" {
+			return errors.New("options cannot be empty")
+		}
+	}
+	if answer < 0 || answer >= len(options) {
+		return errors.New("answer must be the index of one of the options")
+	}
+	return nil
+}
+
+// generateQuizQuestions asks the configured endpoint for count questions about transcript.
+// Questions that come back malformed are dropped.
+func generateQuizQuestions(cfg *config.Config, title, transcript string, count int) ([]models.QuestionCreate, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"model": cfg.QuizGenerationModel,
+		"messages": []map[string]string{
+			{"role": "system", "content": quizGenerationPrompt},
+			{"role": "user", "content": fmt.Sprintf("Module: %s\nWrite %d questions.\n\nTranscript:\n%s", title, count, transcript)},
+		},
+		"temperature": 0.2,
+	})
+	req, err := http.NewRequest(http.MethodPost, cfg.QuizGenerationURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.QuizGenerationAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.QuizGenerationAPIKey)
+	}
+
+	resp, err := quizGenerationHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint returned %s: %s", resp.Status, truncate(string(body), 200))
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil || len(completion.Choices) == 0 {
+		return nil, errors.New("endpoint returned no completion")
+	}
+
+	// Models often wrap JSON in a code fence; take the outermost object
+	content := completion.Choices[0].Message.Content
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("completion did not contain JSON")
+	}
+	var generated struct {
+		Questions []models.QuestionCreate `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &generated); err != nil {
+		return nil, fmt.Errorf("completion was not valid JSON: %v", err)
+	}
+
+	questions := []models.QuestionCreate{}
+	for _, q := range generated.Questions {
+		if validateQuizQuestion(q.Question, q.Options, q.Answer) != nil {
+			continue
+		}
+		questions = append(questions, q)
+		if len(questions) == count {
+			break
+		}
+	}
+	return questions, nil
+}
+
+// ownModuleTitle returns the title of a module in the caller's organization. Shared library
+// modules aren't included: their transcript and questions belong to the platform.
+func ownModuleTitle(r *http.Request, videoID int) (string, error) {
+	args := []interface{}{videoID}
+	scope := tenantClause(r, "org_id", &args)
+	var title string
+	err := database.DB.QueryRow("SELECT title FROM video_modules WHERE id = $1"+scope, args...).Scan(&title)
+	return title, err
+}
+
+// SetModuleTranscript - Store the transcript questions are drafted from
+// PUT /api/modules/{id}/transcript
+func SetModuleTranscript(w http.ResponseWriter, r *http.Request) {
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+
+	var req struct {
+		Transcript string `json:"transcript"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Transcript = strings.TrimSpace(req.Transcript)
+	if len(req.Transcript) > maxTranscriptLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("transcript cannot be longer than %d characters", maxTranscriptLength))
+		return
+	}
+
+	args := []interface{}{videoID, req.Transcript}
+	scope := tenantClause(r, "org_id", &args)
+	result, err := database.DB.Exec("UPDATE video_modules SET transcript = NULLIF($2, ''), updated_at = NOW() WHERE id = $1"+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving transcript: "+err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Video module not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":           true,
+		"transcript_length": len(req.Transcript),
+	})
+}
+
+// GenerateQuestionDrafts - Draft quiz questions from the module's transcript for review.
+// Nothing is added to the quiz until a draft is approved.
+// POST /api/modules/{id}/question-drafts
+func GenerateQuestionDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	cfg := config.Get()
+	if cfg.QuizGenerationURL == "" {
+		respondWithError(w, http.StatusServiceUnavailable, "Quiz generation is not configured")
+		return
+	}
+
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+
+	var req struct {
+		Count int `json:"count"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	if req.Count == 0 {
+		req.Count = defaultDraftQuestions
+	}
+	if req.Count < 1 || req.Count > maxDraftQuestions {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("count must be between 1 and %d", maxDraftQuestions))
+		return
+	}
+
+	title, err := ownModuleTitle(r, videoID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Video module not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	var transcript string
+	database.DB.QueryRow("SELECT COALESCE(transcript, '') FROM video_modules WHERE id = $1", videoID).Scan(&transcript)
+	if transcript == "" {
+		respondWithError(w, http.StatusBadRequest, "Add a transcript to the module before generating questions")
+		return
+	}
+
+	questions, err := generateQuizQuestions(cfg, title, transcript, req.Count)
+	if err != nil {
+		log.Printf("Warning: quiz generation for module %d failed: %v", videoID, err)
+		respondWithError(w, http.StatusBadGateway, "Quiz generation failed, please try again")
+		return
+	}
+	if len(questions) == 0 {
+		respondWithError(w, http.StatusBadGateway, "Quiz generation returned no usable questions")
+		return
+	}
+
+	drafts := []QuestionDraft{}
+	for _, q := range questions {
+		optionsJSON, _ := json.Marshal(q.Options)
+		draft := QuestionDraft{
+			VideoID: videoID, Question: q.Question, Options: q.Options, Answer: q.Answer,
+			Explanation: q.Explanation, Status: QuestionDraftPending, Model: cfg.QuizGenerationModel, GeneratedBy: &userID,
+		}
+		err := database.DB.QueryRow(`
+			INSERT INTO question_drafts (video_id, question, options, answer, explanation, status, model, generated_by)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+			RETURNING id, created_at
+		`, videoID, q.Question, optionsJSON, q.Answer, q.Explanation, QuestionDraftPending, cfg.QuizGenerationModel, userID,
+		).Scan(&draft.ID, &draft.CreatedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error saving question drafts: "+err.Error())
+			return
+		}
+		drafts = append(drafts, draft)
+	}
+	log.Printf("Drafted %d quiz questions for module %d for %s", len(drafts), videoID, userID)
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"drafts": drafts,
+	})
+}
+
+// GetQuestionDrafts - Drafted questions for a module, pending review unless ?status= says otherwise
+// GET /api/modules/{id}/question-drafts?status=DRAFT|APPROVED|REJECTED
+func GetQuestionDrafts(w http.ResponseWriter, r *http.Request) {
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+	status := strings.ToUpper(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = QuestionDraftPending
+	case QuestionDraftPending, QuestionDraftApproved, QuestionDraftRejected:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be DRAFT, APPROVED or REJECTED")
+		return
+	}
+
+	if _, err := ownModuleTitle(r, videoID); err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Video module not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, video_id, question, options, answer, COALESCE(explanation, ''), status, COALESCE(model, ''),
+		       generated_by, created_at, reviewed_by, reviewed_at, question_id
+		FROM question_drafts
+		WHERE video_id = $1 AND status = $2
+		ORDER BY created_at, id
+	`, videoID, status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	drafts := []QuestionDraft{}
+	for rows.Next() {
+		d, err := scanQuestionDraft(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning draft data: "+err.Error())
+			return
+		}
+		drafts = append(drafts, d)
+	}
+
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func scanQuestionDraft(row interface{ Scan(...interface{}) error }) (QuestionDraft, error) {
+	var d QuestionDraft
+	var optionsJSON []byte
+	var generatedBy, reviewedBy sql.NullString
+	var reviewedAt sql.NullTime
+	var questionID sql.NullInt64
+	err := row.Scan(&d.ID, &d.VideoID, &d.Question, &optionsJSON, &d.Answer, &d.Explanation, &d.Status, &d.Model,
+		&generatedBy, &d.CreatedAt, &reviewedBy, &reviewedAt, &questionID)
+	if err != nil {
+		return d, err
+	}
+	json.Unmarshal(optionsJSON, &d.Options)
+	if generatedBy.Valid {
+		d.GeneratedBy = &generatedBy.String
+	}
+	if reviewedBy.Valid {
+		d.ReviewedBy = &reviewedBy.String
+	}
+	if reviewedAt.Valid {
+		d.ReviewedAt = &reviewedAt.Time
+	}
+	if questionID.Valid {
+		id := int(questionID.Int64)
+		d.QuestionID = &id
+	}
+	return d, nil
+}
+
+// pendingQuestionDraft loads a draft awaiting review on a module in the caller's organization
+func pendingQuestionDraft(r *http.Request, draftID int) (QuestionDraft, error) {
+	args := []interface{}{draftID, QuestionDraftPending}
+	scope := tenantClause(r, "vm.org_id", &args)
+	return scanQuestionDraft(database.DB.QueryRow(`
+		SELECT d.id, d.video_id, d.question, d.options, d.answer, COALESCE(d.explanation, ''), d.status, COALESCE(d.model, ''),
+		       d.generated_by, d.created_at, d.reviewed_by, d.reviewed_at, d.question_id
+		FROM question_drafts d
+		JOIN video_modules vm ON vm.id = d.video_id
+		WHERE d.id = $1 AND d.status = $2`+scope, args...))
+}
+
+// ApproveQuestionDraft - Add a drafted question to the module's quiz, with any edits in the
+// body applied first
+// POST /api/modules/question-drafts/{id}/approve
+func ApproveQuestionDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	draftID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return
+	}
+
+	var edits struct {
+		Question    *string  `json:"question"`
+		Options     []string `json:"options"`
+		Answer      *int     `json:"answer"`
+		Explanation *string  `json:"explanation"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&edits); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	draft, err := pendingQuestionDraft(r, draftID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Question draft not found or already reviewed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if edits.Question != nil {
+		draft.Question = strings.TrimSpace(*edits.Question)
+	}
+	if edits.Options != nil {
+		draft.Options = edits.Options
+	}
+	if edits.Answer != nil {
+		draft.Answer = *edits.Answer
+	}
+	if edits.Explanation != nil {
+		draft.Explanation = strings.TrimSpace(*edits.Explanation)
+	}
+	if err := validateQuizQuestion(draft.Question, draft.Options, draft.Answer); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	optionsJSON, _ := json.Marshal(draft.Options)
+	var questionID int
+	err = tx.QueryRow(`
+		INSERT INTO questions (video_id, question, options, answer, explanation)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id
+	`, draft.VideoID, draft.Question, optionsJSON, draft.Answer, draft.Explanation).Scan(&questionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating question: "+err.Error())
+		return
+	}
+	result, err := tx.Exec(`
+		UPDATE question_drafts SET status = $2, question = $3, options = $4, answer = $5, explanation = NULLIF($6, ''),
+		       reviewed_by = $7, reviewed_at = NOW(), question_id = $8
+		WHERE id = $1 AND status = $9
+	`, draftID, QuestionDraftApproved, draft.Question, optionsJSON, draft.Answer, draft.Explanation, userID, questionID, QuestionDraftPending)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating draft: "+err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusConflict, "Question draft was reviewed by someone else")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error approving draft")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"question": models.Question{
+			ID: questionID, VideoID: draft.VideoID, Question: draft.Question,
+			Options: draft.Options, Answer: draft.Answer, Explanation: draft.Explanation,
+		},
+	})
+}
+
+// RejectQuestionDraft - Discard a drafted question
+// POST /api/modules/question-drafts/{id}/reject
+func RejectQuestionDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	draftID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return
+	}
+
+	if _, err := pendingQuestionDraft(r, draftID); err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Question draft not found or already reviewed")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE question_drafts SET status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE id = $1 AND status = $4
+	`, draftID, QuestionDraftRejected, userID, QuestionDraftPending)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating draft: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Question draft rejected",
+	})
+}
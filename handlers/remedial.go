@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ==================== REMEDIAL TRAINING ====================
+
+// Incidents that remedial training can be assigned from
+const (
+	RemedialSourceEmergency = "emergency"
+	RemedialSourcePPECheck  = "ppe_check"
+)
+
+// remedialSourceMiner finds the miner involved in an incident, limited to the supervisor's miners
+var remedialSourceMiner = map[string]string{
+	RemedialSourceEmergency: `SELECT e.user_id FROM emergencies e JOIN users u ON u.user_id = e.user_id
+		WHERE e.id = $1 AND u.supervisor_id = $2`,
+	RemedialSourcePPECheck: `SELECT p.user_id FROM ppe_stats p JOIN users u ON u.user_id = p.user_id
+		WHERE p.id = $1 AND u.supervisor_id = $2`,
+}
+
+// RemedialAssignmentRequest assigns modules to the miners involved in an incident.
+// MinerIDs defaults to the miner who reported the emergency or failed the PPE check.
+type RemedialAssignmentRequest struct {
+	SourceType string   `json:"source_type"`
+	SourceID   int      `json:"source_id"`
+	MinerIDs   []string `json:"miner_ids"`
+	ModuleIDs  []int    `json:"module_ids"`
+	Reason     string   `json:"reason"`
+	DueDate    string   `json:"due_date"` // YYYY-MM-DD, optional
+}
+
+// RemedialAssignment is one module a miner must pass because of an incident
+type RemedialAssignment struct {
+	ID          int     `json:"id"`
+	MinerID     string  `json:"miner_id"`
+	MinerName   string  `json:"miner_name,omitempty"`
+	VideoID     int     `json:"video_id"`
+	ModuleTitle string  `json:"module_title"`
+	AssignedBy  string  `json:"assigned_by,omitempty"`
+	SourceType  string  `json:"source_type"`
+	SourceID    int     `json:"source_id"`
+	Reason      string  `json:"reason,omitempty"`
+	DueDate     *string `json:"due_date,omitempty"`
+	// DueDate pushed back by the days the miner was on leave since it was assigned
+	EffectiveDueDate *string    `json:"effective_due_date,omitempty"`
+	Overdue          bool       `json:"overdue"`
+	CompletionID     *int       `json:"completion_id,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+const remedialAssignmentSelect = `
+	SELECT ra.id, ra.miner_id, COALESCE(u.name, ''), ra.video_id, COALESCE(vm.title, ''),
+		COALESCE(ra.assigned_by, ''), ra.source_type, ra.source_id, COALESCE(ra.reason, ''),
+		TO_CHAR(ra.due_date, 'YYYY-MM-DD'), TO_CHAR(ed.due, 'YYYY-MM-DD'),
+		COALESCE(ra.completed_at IS NULL AND ed.due < CURRENT_DATE, false),
+		ra.completion_id, ra.completed_at, ra.created_at
+	FROM remedial_assignments ra
+	JOIN users u ON u.user_id = ra.miner_id
+	LEFT JOIN video_modules vm ON vm.id = ra.video_id
+	CROSS JOIN LATERAL (
+		SELECT ra.due_date + COALESCE(SUM(LEAST(l.end_date, ra.due_date) - GREATEST(l.start_date, ra.created_at::date) + 1), 0)::int AS due
+		FROM miner_leave l
+		WHERE l.user_id = ra.miner_id AND l.start_date <= ra.due_date AND l.end_date >= ra.created_at::date
+	) ed`
+
+func scanRemedialAssignments(rows *sql.Rows) ([]RemedialAssignment, error) {
+	defer rows.Close()
+	assignments := []RemedialAssignment{}
+	for rows.Next() {
+		var a RemedialAssignment
+		var dueDate, effectiveDueDate sql.NullString
+		var completionID sql.NullInt64
+		var completedAt sql.NullTime
+		err := rows.Scan(&a.ID, &a.MinerID, &a.MinerName, &a.VideoID, &a.ModuleTitle, &a.AssignedBy,
+			&a.SourceType, &a.SourceID, &a.Reason, &dueDate, &effectiveDueDate, &a.Overdue,
+			&completionID, &completedAt, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if dueDate.Valid {
+			a.DueDate = &dueDate.String
+		}
+		if effectiveDueDate.Valid {
+			a.EffectiveDueDate = &effectiveDueDate.String
+		}
+		if completionID.Valid {
+			id := int(completionID.Int64)
+			a.CompletionID = &id
+		}
+		if completedAt.Valid {
+			a.CompletedAt = &completedAt.Time
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// completeRemedialAssignments closes the miner's open remedial assignments for a module
+// once they pass it, linking the completion that closed the loop
+func completeRemedialAssignments(minerID string, videoID, completionID int) {
+	_, err := database.DB.Exec(`
+		UPDATE remedial_assignments SET completion_id = $3, completed_at = NOW()
+		WHERE miner_id = $1 AND video_id = $2 AND completed_at IS NULL
+	`, minerID, videoID, completionID)
+	if err != nil {
+		log.Printf("Warning: failed to close remedial assignments for %s: %v", minerID, err)
+	}
+}
+
+// AssignRemedialTraining - Assign corrective modules to the miners involved in an incident
+// POST /api/supervisor/remedial-training
+func AssignRemedialTraining(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req RemedialAssignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	sourceQuery, ok := remedialSourceMiner[req.SourceType]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "source_type must be emergency or ppe_check")
+		return
+	}
+	if len(req.ModuleIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one module is required")
+		return
+	}
+
+	var dueDate *string
+	if req.DueDate != "" {
+		if _, err := time.Parse("2006-01-02", req.DueDate); err != nil {
+			respondWithError(w, http.StatusBadRequest, "due_date must be YYYY-MM-DD")
+			return
+		}
+		dueDate = &req.DueDate
+	}
+
+	var involvedMiner string
+	err := database.DB.QueryRow(sourceQuery, req.SourceID, supervisorID).Scan(&involvedMiner)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Incident not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	minerIDs := req.MinerIDs
+	if len(minerIDs) == 0 {
+		minerIDs = []string{involvedMiner}
+	}
+
+	var ownedMiners int
+	err = database.DB.QueryRow(
+		"SELECT COUNT(*) FROM users WHERE user_id = ANY($1) AND supervisor_id = $2 AND deleted_at IS NULL",
+		pq.Array(minerIDs), supervisorID,
+	).Scan(&ownedMiners)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if ownedMiners != len(minerIDs) {
+		respondWithError(w, http.StatusForbidden, "You can only assign training to your own miners")
+		return
+	}
+
+	var activeModules int
+	err = database.DB.QueryRow(
+		"SELECT COUNT(*) FROM video_modules WHERE id = ANY($1) AND is_active = true",
+		pq.Array(req.ModuleIDs),
+	).Scan(&activeModules)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if activeModules != len(req.ModuleIDs) {
+		respondWithError(w, http.StatusBadRequest, "One or more modules do not exist or are inactive")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	created := 0
+	for _, minerID := range minerIDs {
+		for _, videoID := range req.ModuleIDs {
+			result, err := tx.Exec(`
+				INSERT INTO remedial_assignments (miner_id, video_id, assigned_by, source_type, source_id, reason, due_date)
+				VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+				ON CONFLICT (miner_id, video_id, source_type, source_id) DO NOTHING
+			`, minerID, videoID, supervisorID, req.SourceType, req.SourceID, req.Reason, dueDate)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error assigning training: "+err.Error())
+				return
+			}
+			n, _ := result.RowsAffected()
+			created += int(n)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error assigning training: "+err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("You have been assigned %d training module(s) to complete", len(req.ModuleIDs))
+	if dueDate != nil {
+		message += " by " + *dueDate
+	}
+	for _, minerID := range minerIDs {
+		notifyUser(minerID, "REMEDIAL_TRAINING", "Training assigned", message, req.SourceType, strconv.Itoa(req.SourceID))
+	}
+
+	rows, err := database.DB.Query(remedialAssignmentSelect+`
+		WHERE ra.source_type = $1 AND ra.source_id = $2
+		ORDER BY ra.miner_id, ra.video_id
+	`, req.SourceType, req.SourceID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	assignments, err := scanRemedialAssignments(rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":     true,
+		"created":     created,
+		"assignments": assignments,
+	})
+}
+
+// GetRemedialTraining - Remedial assignments for this supervisor's miners, optionally for one incident
+// GET /api/supervisor/remedial-training?source_type=emergency&source_id=12&status=open|completed|overdue
+func GetRemedialTraining(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := remedialAssignmentSelect + " WHERE u.supervisor_id = $1"
+	args := []interface{}{supervisorID}
+	argCount := 2
+
+	if sourceType := r.URL.Query().Get("source_type"); sourceType != "" {
+		query += fmt.Sprintf(" AND ra.source_type = $%d", argCount)
+		args = append(args, sourceType)
+		argCount++
+	}
+	if sourceID, err := strconv.Atoi(r.URL.Query().Get("source_id")); err == nil {
+		query += fmt.Sprintf(" AND ra.source_id = $%d", argCount)
+		args = append(args, sourceID)
+		argCount++
+	}
+	switch r.URL.Query().Get("status") {
+	case "open":
+		query += " AND ra.completed_at IS NULL"
+	case "completed":
+		query += " AND ra.completed_at IS NOT NULL"
+	case "overdue":
+		query += " AND ra.completed_at IS NULL AND ed.due < CURRENT_DATE"
+	}
+	query += " ORDER BY ra.created_at DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	assignments, err := scanRemedialAssignments(rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+		return
+	}
+
+	// An incident's corrective loop is closed once every assignment from it is completed
+	type incidentKey struct {
+		SourceType string
+		SourceID   int
+	}
+	open := map[incidentKey]bool{}
+	for _, a := range assignments {
+		key := incidentKey{a.SourceType, a.SourceID}
+		open[key] = open[key] || a.CompletedAt == nil
+	}
+	closedIncidents := 0
+	for _, isOpen := range open {
+		if !isOpen {
+			closedIncidents++
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"assignments":      assignments,
+		"incidents":        len(open),
+		"incidents_closed": closedIncidents,
+	})
+}
+
+// GetMyRemedialTraining - Modules the current user has been assigned after an incident
+// GET /api/app/remedial-training
+func GetMyRemedialTraining(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rows, err := database.DB.Query(remedialAssignmentSelect+`
+		WHERE ra.miner_id = $1
+		ORDER BY ra.completed_at IS NOT NULL, ra.due_date NULLS LAST, ra.created_at DESC
+	`, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	assignments, err := scanRemedialAssignments(rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, assignments)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ==================== BANDWIDTH-AWARE MEDIA ====================
+
+// Phones on 2G at remote sites ask for smaller media with ?quality=low or the Save-Data
+// header. The media pipeline registers lower-resolution renditions of a video or thumbnail
+// against its original URL; feeds return the best rendition for the requested quality and
+// fall back to the original when there is none.
+
+const (
+	mediaQualityLow    = "low"
+	mediaQualityMedium = "medium"
+	mediaQualityHigh   = "high" // the original upload
+)
+
+// renditionPreference is the order renditions are tried in for each quality. A medium
+// request takes a low rendition over the original, which may be far too big.
+var renditionPreference = map[string][]string{
+	mediaQualityLow:    {mediaQualityLow, mediaQualityMedium},
+	mediaQualityMedium: {mediaQualityMedium, mediaQualityLow},
+}
+
+// requestedQuality reads ?quality=low|medium|high, falling back to low when the client sent
+// Save-Data: on. Unknown values get the original.
+func requestedQuality(r *http.Request) string {
+	switch q := strings.ToLower(r.URL.Query().Get("quality")); q {
+	case mediaQualityLow, mediaQualityMedium, mediaQualityHigh:
+		return q
+	}
+	if strings.EqualFold(strings.TrimSpace(r.Header.Get("Save-Data")), "on") {
+		return mediaQualityLow
+	}
+	return mediaQualityHigh
+}
+
+// renditionKey is the form an original is registered under: its /uploads/ path without
+// BASE_URL or signature, or the URL as stored for anything else
+func renditionKey(stored string) string {
+	if path, ok := storedUploadPath(stored); ok {
+		return path
+	}
+	return strings.TrimSpace(stored)
+}
+
+// storedUploadPath strips BASE_URL and any signature from an /uploads/ URL, reporting
+// whether it is one
+func storedUploadPath(u string) (string, bool) {
+	if base := config.Get().BaseURL; base != "" {
+		u = strings.TrimPrefix(u, base)
+	}
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		u = u[:i]
+	}
+	return u, strings.HasPrefix(u, uploadsURLPrefix)
+}
+
+// mediaRenditions maps original media URLs to the rendition chosen for one request
+type mediaRenditions struct {
+	quality string
+	urls    map[string]string
+}
+
+// loadRenditions looks up renditions of sources for the request's quality in one query.
+// Responses vary on Save-Data so caches don't hand full-size media to a 2G phone.
+func loadRenditions(w http.ResponseWriter, r *http.Request, sources []string) mediaRenditions {
+	w.Header().Add("Vary", "Save-Data")
+	m := mediaRenditions{quality: requestedQuality(r), urls: map[string]string{}}
+	preference, ok := renditionPreference[m.quality]
+	if !ok || len(sources) == 0 {
+		return m
+	}
+	keys := make([]string, len(sources))
+	for i, s := range sources {
+		keys[i] = renditionKey(s)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT ON (source_url) source_url, url
+		FROM media_renditions
+		WHERE source_url = ANY($1) AND quality = ANY($2)
+		ORDER BY source_url, array_position($2, quality::text)
+	`, pq.Array(keys), pq.Array(preference))
+	if err != nil {
+		// Originals still play, just slowly
+		return m
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source, url string
+		if rows.Scan(&source, &url) == nil {
+			m.urls[source] = url
+		}
+	}
+	return m
+}
+
+// sign returns the signed URL of the chosen rendition of stored, or of stored itself. The
+// rendition keeps BASE_URL if the original was stored with it.
+func (m mediaRenditions) sign(stored string) string {
+	url, ok := m.urls[renditionKey(stored)]
+	if !ok {
+		return signUploadURL(stored)
+	}
+	if base := config.Get().BaseURL; base != "" && strings.HasPrefix(stored, base) {
+		url = base + url
+	}
+	return signUploadURL(url)
+}
+
+// MediaRenditionRequest represents a rendition registered by the media pipeline
+type MediaRenditionRequest struct {
+	SourceURL   string `json:"source_url"`
+	Quality     string `json:"quality"`
+	URL         string `json:"url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	BitrateKbps int    `json:"bitrate_kbps"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// RegisterMediaRendition - Record a lower-resolution rendition of an uploaded video or
+// thumbnail, replacing any earlier one of the same quality. Only the media pipeline's
+// platform admin account may register one; a rendition can point at any stored upload.
+// PUT /api/admin/media/renditions
+func RegisterMediaRendition(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	var req MediaRenditionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if _, ok := renditionPreference[req.Quality]; !ok {
+		respondWithError(w, http.StatusBadRequest, "quality must be low or medium")
+		return
+	}
+	// Renditions are served through the same signed /uploads/ URLs as the originals
+	rendition, ok := storedUploadPath(req.URL)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "url must be an /uploads/ path")
+		return
+	}
+	source := renditionKey(req.SourceURL)
+	if source == "" {
+		respondWithError(w, http.StatusBadRequest, "source_url is required")
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO media_renditions (source_url, quality, url, width, height, bitrate_kbps, bytes)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, 0), NULLIF($7, 0))
+		ON CONFLICT (source_url, quality) DO UPDATE SET
+			url = EXCLUDED.url, width = EXCLUDED.width, height = EXCLUDED.height,
+			bitrate_kbps = EXCLUDED.bitrate_kbps, bytes = EXCLUDED.bytes, created_at = NOW()
+	`, source, req.Quality, rendition, req.Width, req.Height, req.BitrateKbps, req.Bytes)
+	if err != nil {
+		log.Printf("Failed to register %s rendition of %s: %v", req.Quality, source, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	// A registered rendition is a finished transcode, whether or not its job was reported
+	if err := recordMediaJob(source, MediaJobRequest{JobType: MediaJobTranscode, Quality: req.Quality, Status: MediaJobSucceeded}); err != nil {
+		log.Printf("Warning: failed to mark %s transcode of %s done: %v", req.Quality, source, err)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"source_url": source,
+		"quality":    req.Quality,
+		"url":        rendition,
+	})
+}
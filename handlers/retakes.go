@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== MODULE RETAKE POLICY ====================
+
+const retakePolicySettingKey = "module_retake_policy"
+
+// ModuleRetakePolicy controls how often a quiz can be attempted and what counts as a pass
+type ModuleRetakePolicy struct {
+	PassingPercent    float64 `json:"passing_percent"`
+	MaxAttemptsPerDay int     `json:"max_attempts_per_day"` // 0 = unlimited
+	CooldownMinutes   int     `json:"cooldown_minutes"`     // wait after a failed attempt
+}
+
+// defaultRetakePolicy starts from the induction pass mark
+var defaultRetakePolicy = ModuleRetakePolicy{
+	PassingPercent:    inductionModulePassPercent,
+	MaxAttemptsPerDay: 3,
+	CooldownMinutes:   10,
+}
+
+// ModuleAttempt is one submitted quiz attempt
+type ModuleAttempt struct {
+	ID             int       `json:"id"`
+	VideoID        int       `json:"video_id"`
+	Score          int       `json:"score"`
+	TotalQuestions int       `json:"total_questions"`
+	Percentage     float64   `json:"percentage"`
+	Passed         bool      `json:"passed"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+func loadRetakePolicy() (ModuleRetakePolicy, error) {
+	policy := defaultRetakePolicy
+	var value []byte
+	err := database.DB.QueryRow("SELECT value FROM system_settings WHERE key = $1", retakePolicySettingKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	err = json.Unmarshal(value, &policy)
+	return policy, err
+}
+
+// RetakeStatus describes whether a miner may attempt a module quiz right now
+type RetakeStatus struct {
+	Allowed           bool       `json:"allowed"`
+	AttemptsToday     int        `json:"attempts_today"`
+	AttemptsRemaining *int       `json:"attempts_remaining_today,omitempty"`
+	NextAttemptAt     *time.Time `json:"next_attempt_at,omitempty"`
+	Reason            string     `json:"reason,omitempty"`
+}
+
+// checkRetakePolicy applies the daily attempt limit and the cooldown after a failed attempt
+func checkRetakePolicy(minerID string, videoID int, policy ModuleRetakePolicy) (RetakeStatus, error) {
+	var status RetakeStatus
+	var lastAttempt sql.NullTime
+	var lastPassed sql.NullBool
+	err := database.DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM module_attempts WHERE miner_id = $1 AND video_id = $2
+				AND (attempted_at AT TIME ZONE $3)::date = (NOW() AT TIME ZONE $3)::date),
+			(SELECT attempted_at FROM module_attempts WHERE miner_id = $1 AND video_id = $2 ORDER BY attempted_at DESC LIMIT 1),
+			(SELECT passed FROM module_attempts WHERE miner_id = $1 AND video_id = $2 ORDER BY attempted_at DESC LIMIT 1)
+	`, minerID, videoID, userLocation(minerID).String()).Scan(&status.AttemptsToday, &lastAttempt, &lastPassed)
+	if err != nil {
+		return status, err
+	}
+
+	status.Allowed = true
+	if policy.MaxAttemptsPerDay > 0 {
+		remaining := policy.MaxAttemptsPerDay - status.AttemptsToday
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.AttemptsRemaining = &remaining
+		if remaining == 0 {
+			now := time.Now()
+			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			status.Allowed = false
+			status.NextAttemptAt = &tomorrow
+			status.Reason = "Daily attempt limit reached"
+			return status, nil
+		}
+	}
+
+	if policy.CooldownMinutes > 0 && lastAttempt.Valid && lastPassed.Valid && !lastPassed.Bool {
+		next := lastAttempt.Time.Add(time.Duration(policy.CooldownMinutes) * time.Minute)
+		if time.Now().Before(next) {
+			status.Allowed = false
+			status.NextAttemptAt = &next
+			status.Reason = "Please review the video before retaking the quiz"
+		}
+	}
+	return status, nil
+}
+
+// GetModuleAttempts - The current user's attempt history and retake status for a module
+// GET /api/modules/{id}/attempts
+func GetModuleAttempts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid module ID")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, video_id, score, total_questions, percentage, passed, attempted_at
+		FROM module_attempts
+		WHERE miner_id = $1 AND video_id = $2
+		ORDER BY attempted_at DESC
+	`, userID, videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	attempts := []ModuleAttempt{}
+	for rows.Next() {
+		var a ModuleAttempt
+		if err := rows.Scan(&a.ID, &a.VideoID, &a.Score, &a.TotalQuestions, &a.Percentage, &a.Passed, &a.AttemptedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		attempts = append(attempts, a)
+	}
+
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading retake policy")
+		return
+	}
+	// The pass mark shown is this module's own, if it has one
+	policy.PassingPercent, err = modulePassingPercent(videoID, policy)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	status, err := checkRetakePolicy(userID, videoID, policy)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"attempts": attempts,
+		"policy":   policy,
+		"status":   status,
+	})
+}
+
+// GetRetakePolicy - Current module retake policy
+// GET /api/admin/module-retake-policy
+func GetRetakePolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := loadRetakePolicy()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading retake policy")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// UpdateRetakePolicy - Change the passing score, daily attempt limit and cooldown
+// PUT /api/admin/module-retake-policy
+func UpdateRetakePolicy(w http.ResponseWriter, r *http.Request) {
+	if refuseTenantAdmin(w, r) {
+		return
+	}
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var policy ModuleRetakePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if policy.PassingPercent <= 0 || policy.PassingPercent > 100 {
+		respondWithError(w, http.StatusBadRequest, "passing_percent must be between 1 and 100")
+		return
+	}
+	if policy.MaxAttemptsPerDay < 0 || policy.CooldownMinutes < 0 {
+		respondWithError(w, http.StatusBadRequest, "max_attempts_per_day and cooldown_minutes cannot be negative")
+		return
+	}
+
+	value, _ := json.Marshal(policy)
+	_, err := database.DB.Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, retakePolicySettingKey, value, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving retake policy: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== TOKEN REVOCATION ====================
+
+// Revocations are checked on every request, so they are held in memory and reloaded from
+// the database periodically and whenever another instance revokes something
+var (
+	revocationMu  sync.RWMutex
+	revokedTokens = map[string]time.Time{} // jti -> token expiry
+	revokedBefore = map[string]time.Time{} // user -> tokens issued before this are invalid
+)
+
+func tokenRevoked(userID string, token middleware.TokenInfo) bool {
+	revocationMu.RLock()
+	defer revocationMu.RUnlock()
+	if token.ID != "" {
+		if _, ok := revokedTokens[token.ID]; ok {
+			return true
+		}
+	}
+	if before, ok := revokedBefore[userID]; ok && token.IssuedAt.Before(before) {
+		return true
+	}
+	return false
+}
+
+// loadRevocations replaces the in-memory lists with the unexpired revocations
+func loadRevocations() error {
+	tokens := map[string]time.Time{}
+	rows, err := database.DB.Query("SELECT jti, expires_at FROM revoked_tokens WHERE expires_at > NOW()")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			rows.Close()
+			return err
+		}
+		tokens[jti] = expiresAt
+	}
+	rows.Close()
+
+	users := map[string]time.Time{}
+	// Anything issued before now minus the token lifetime has expired anyway
+	rows, err = database.DB.Query(`
+		SELECT user_id, revoked_before FROM user_token_revocations
+		WHERE revoked_before > NOW() - $1 * INTERVAL '1 second'
+	`, middleware.MaxTokenLifetime().Seconds())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var before time.Time
+		if err := rows.Scan(&userID, &before); err != nil {
+			return err
+		}
+		users[userID] = before
+	}
+
+	revocationMu.Lock()
+	revokedTokens = tokens
+	revokedBefore = users
+	revocationMu.Unlock()
+	return nil
+}
+
+// StartRevocationSync makes AuthMiddleware reject revoked tokens and periodically reloads
+// revocations, purging expired ones
+func StartRevocationSync(interval time.Duration) {
+	middleware.SetRevocationChecker(tokenRevoked)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			database.DB.Exec("DELETE FROM revoked_tokens WHERE expires_at <= NOW()")
+			if err := loadRevocations(); err != nil {
+				log.Printf("Warning: failed to load token revocations: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// revokeToken invalidates one token until it would have expired anyway
+func revokeToken(userID string, token middleware.TokenInfo, reason string) error {
+	if token.ID == "" {
+		// Tokens from before jti was added can only be revoked with everything issued
+		// up to the same moment
+		return revokeUserTokens(userID, "", reason, token.IssuedAt)
+	}
+	expiresAt := token.ExpiresAt.UTC()
+	if token.ExpiresAt.IsZero() {
+		expiresAt = time.Now().UTC().Add(middleware.MaxTokenLifetime())
+	}
+	_, err := database.DB.Exec(`
+		INSERT INTO revoked_tokens (jti, user_id, expires_at, reason, revoked_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (jti) DO NOTHING
+	`, token.ID, userID, expiresAt, reason)
+	if err != nil {
+		return err
+	}
+
+	database.DB.Exec("UPDATE user_sessions SET revoked_at = NOW() WHERE jti = $1 AND revoked_at IS NULL", token.ID)
+
+	revocationMu.Lock()
+	revokedTokens[token.ID] = expiresAt
+	revocationMu.Unlock()
+	broadcastInvalidation(cacheRevocations)
+	return nil
+}
+
+// revokeUserTokens invalidates every token of the user issued up to the given time. JWT iat
+// has second precision, so everything issued within that second is revoked too.
+func revokeUserTokens(userID, revokedBy, reason string, upTo time.Time) error {
+	before := upTo.UTC().Truncate(time.Second).Add(time.Second)
+	_, err := database.DB.Exec(`
+		INSERT INTO user_token_revocations (user_id, revoked_before, revoked_by, reason, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+			revoked_by = EXCLUDED.revoked_by, reason = EXCLUDED.reason, updated_at = NOW()
+	`, userID, before, revokedBy, reason)
+	if err != nil {
+		return err
+	}
+
+	database.DB.Exec(
+		"UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = $1 AND created_at < $2 AND revoked_at IS NULL",
+		userID, before,
+	)
+
+	revocationMu.Lock()
+	if before.After(revokedBefore[userID]) {
+		revokedBefore[userID] = before
+	}
+	revocationMu.Unlock()
+	broadcastInvalidation(cacheRevocations)
+	return nil
+}
+
+// Logout - Revoke the token this request was made with
+// POST /api/auth/logout
+func Logout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	token, hasToken := middleware.GetTokenFromContext(r.Context())
+	if !ok || !hasToken {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := revokeToken(userID, token, "logout"); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging out: "+err.Error())
+		return
+	}
+	if token.FromCookie {
+		middleware.ClearSessionCookies(w)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Logged out",
+	})
+}
+
+// LogoutEverywhere - Revoke every token of the current user, on all devices
+// POST /api/auth/logout-all
+func LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if refuseImpersonated(w, r) {
+		return
+	}
+
+	if err := revokeUserTokens(userID, userID, "logout_all", time.Now()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging out: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Logged out on all devices",
+	})
+}
+
+// AdminRevokeSessionsRequest represents the request body for revoking a user's sessions
+type AdminRevokeSessionsRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminRevokeUserSessions - Revoke every session of a compromised account. The user can
+// log in again, so change or reset the password as well if it may be known.
+// POST /api/admin/users/{id}/revoke-sessions
+func AdminRevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	userID := mux.Vars(r)["id"]
+
+	var req AdminRevokeSessionsRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "revoked_by_admin"
+	}
+
+	var exists bool
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", userID).Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := revokeUserTokens(userID, adminID, req.Reason, time.Now()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking sessions: "+err.Error())
+		return
+	}
+	log.Printf("All sessions of %s revoked by %s (%s)", userID, adminID, req.Reason)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "All sessions revoked",
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== SESSIONS ====================
+
+// sessionTouchInterval limits how often a session's last seen time is written
+const sessionTouchInterval = 5 * time.Minute
+
+// sessionRetention is how long expired or revoked sessions are kept before being purged
+const sessionRetention = 30 * 24 * time.Hour
+
+var (
+	sessionTouchMu sync.Mutex
+	sessionTouched = map[string]time.Time{} // jti -> last write
+)
+
+// Session is a device signed in to the user's account
+type Session struct {
+	ID         int       `json:"id"`
+	DeviceName string    `json:"device_name"`
+	Platform   string    `json:"platform,omitempty"`
+	Client     string    `json:"client"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// sessionDeviceName describes the device a login came from, preferring the model the app sends
+func sessionDeviceName(r *http.Request) string {
+	if model := strings.TrimSpace(r.Header.Get(headerDeviceModel)); model != "" {
+		return truncate(model, 255)
+	}
+	ua := r.UserAgent()
+	switch {
+	case ua == "":
+		return "Unknown device"
+	case strings.Contains(ua, "Mozilla/"):
+		return "Web browser"
+	}
+	return truncate(ua, 255)
+}
+
+// issueSessionToken issues a token for one client app and records the session it starts
+func issueSessionToken(r *http.Request, userID, role, audience string, expiresAt time.Time) (string, error) {
+	token, info, err := middleware.GenerateSessionToken(userID, role, audience, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	recordSession(r, userID, audience, info)
+	return token, nil
+}
+
+// recordSession adds a newly issued token to the user's session list
+func recordSession(r *http.Request, userID, audience string, info middleware.TokenInfo) {
+	_, err := database.DB.Exec(`
+		INSERT INTO user_sessions (jti, user_id, audience, device_name, device_id, platform, user_agent,
+		                           ip_address, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $9, $10)
+	`, info.ID, userID, audience, sessionDeviceName(r), strings.TrimSpace(r.Header.Get(headerDeviceID)),
+		truncate(r.Header.Get(headerPlatform), 50), r.UserAgent(), clientIP(r), info.IssuedAt, info.ExpiresAt)
+	if err != nil {
+		// The token still works; it just won't show up in the session list
+		log.Printf("Warning: failed to record session for %s: %v", userID, err)
+	}
+}
+
+// issueLoginToken issues a regular session token for the client app the request came from
+func issueLoginToken(r *http.Request, userID, role string) (string, error) {
+	audience := tokenAudience(r, role)
+	return issueSessionToken(r, userID, role, audience, time.Now().Add(middleware.TokenLifetimeFor(audience)))
+}
+
+// touchSession records that a session was used, at most once per sessionTouchInterval
+func touchSession(r *http.Request, userID string, token middleware.TokenInfo) {
+	if token.ID == "" {
+		return
+	}
+	now := time.Now()
+	sessionTouchMu.Lock()
+	if last, ok := sessionTouched[token.ID]; ok && now.Sub(last) < sessionTouchInterval {
+		sessionTouchMu.Unlock()
+		return
+	}
+	sessionTouched[token.ID] = now
+	sessionTouchMu.Unlock()
+
+	ip := clientIP(r)
+	go func() {
+		_, err := database.DB.Exec(
+			"UPDATE user_sessions SET last_seen_at = NOW(), ip_address = $3 WHERE jti = $1 AND user_id = $2",
+			token.ID, userID, ip,
+		)
+		if err != nil {
+			log.Printf("Warning: failed to update session last seen: %v", err)
+		}
+	}()
+}
+
+// StartSessionTracking updates last seen times of sessions as they are used and purges
+// sessions that expired or were revoked long ago
+func StartSessionTracking(interval time.Duration) {
+	middleware.SetSessionTracker(touchSession)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			database.DB.Exec(
+				"DELETE FROM user_sessions WHERE COALESCE(revoked_at, expires_at) < NOW() - $1 * INTERVAL '1 second'",
+				sessionRetention.Seconds(),
+			)
+
+			sessionTouchMu.Lock()
+			for jti, last := range sessionTouched {
+				if time.Since(last) > sessionTouchInterval {
+					delete(sessionTouched, jti)
+				}
+			}
+			sessionTouchMu.Unlock()
+			<-ticker.C
+		}
+	}()
+}
+
+// GetMySessions - Devices currently signed in to the user's account
+// GET /api/me/sessions
+func GetMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	current, _ := middleware.GetTokenFromContext(r.Context())
+
+	// Sessions issued before a "log out everywhere" are gone even without their own revocation
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.jti, COALESCE(s.device_name, ''), COALESCE(s.platform, ''), COALESCE(s.audience, ''),
+		       COALESCE(s.ip_address, ''), s.created_at, s.last_seen_at, s.expires_at
+		FROM user_sessions s
+		LEFT JOIN user_token_revocations t ON t.user_id = s.user_id
+		WHERE s.user_id = $1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+		AND (t.revoked_before IS NULL OR s.created_at >= t.revoked_before)
+		ORDER BY s.last_seen_at DESC
+	`, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		var jti string
+		if err := rows.Scan(&s.ID, &jti, &s.DeviceName, &s.Platform, &s.Client, &s.IPAddress,
+			&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning sessions")
+			return
+		}
+		s.Current = jti == current.ID
+		sessions = append(sessions, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// RevokeMySession - Sign out one of the user's devices
+// DELETE /api/me/sessions/{id}
+func RevokeMySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	var token middleware.TokenInfo
+	err = database.DB.QueryRow(`
+		SELECT jti, expires_at FROM user_sessions
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`, sessionID, userID).Scan(&token.ID, &token.ExpiresAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := revokeToken(userID, token, "session_revoked"); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking session: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Device signed out",
+	})
+}
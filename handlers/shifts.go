@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== SHIFT WINDOWS ====================
+
+// ShiftWindow is a period during which miners at a site may submit checklist and PPE records
+type ShiftWindow struct {
+	ID           int       `json:"id"`
+	MiningSite   string    `json:"mining_site"`
+	Name         string    `json:"name"`
+	StartTime    string    `json:"start_time"` // HH:MM, site local time
+	EndTime      string    `json:"end_time"`   // HH:MM, may be before start_time for night shifts
+	Days         []int     `json:"days"`       // 0 = Sunday ... 6 = Saturday; empty means every day
+	Timezone     string    `json:"timezone"`
+	GraceMinutes int       `json:"grace_minutes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateShiftWindowRequest represents the request body for configuring a shift window
+type CreateShiftWindowRequest struct {
+	MiningSite   string `json:"mining_site"`
+	Name         string `json:"name"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	Days         []int  `json:"days"`
+	Timezone     string `json:"timezone"`
+	GraceMinutes int    `json:"grace_minutes"`
+}
+
+// CreateShiftWindow - Configure a shift window for a mining site
+// POST /api/admin/shift-windows
+func CreateShiftWindow(w http.ResponseWriter, r *http.Request) {
+	var req CreateShiftWindowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.MiningSite == "" {
+		respondWithError(w, http.StatusBadRequest, "mining_site is required")
+		return
+	}
+	if inOrg, err := siteInOrganization(r, req.MiningSite); err != nil || !inOrg {
+		respondWithError(w, http.StatusBadRequest, "Unknown mining site")
+		return
+	}
+	if _, err := time.Parse("15:04", req.StartTime); err != nil {
+		respondWithError(w, http.StatusBadRequest, "start_time must be HH:MM")
+		return
+	}
+	if _, err := time.Parse("15:04", req.EndTime); err != nil {
+		respondWithError(w, http.StatusBadRequest, "end_time must be HH:MM")
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Unknown timezone: "+req.Timezone)
+		return
+	}
+	for _, d := range req.Days {
+		if d < 0 || d > 6 {
+			respondWithError(w, http.StatusBadRequest, "days must be between 0 (Sunday) and 6 (Saturday)")
+			return
+		}
+	}
+	if req.Days == nil {
+		req.Days = []int{}
+	}
+	if req.GraceMinutes < 0 {
+		req.GraceMinutes = 0
+	}
+
+	daysJSON, _ := json.Marshal(req.Days)
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO shift_windows (mining_site, name, start_time, end_time, days, timezone, grace_minutes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id
+	`, req.MiningSite, req.Name, req.StartTime, req.EndTime, daysJSON, req.Timezone, req.GraceMinutes).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating shift window: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Shift window created successfully",
+	})
+}
+
+// GetShiftWindows - List the organization's shift windows, optionally for one site
+// GET /api/admin/shift-windows?mining_site=North%20Pit
+func GetShiftWindows(w http.ResponseWriter, r *http.Request) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if miningSite := r.URL.Query().Get("mining_site"); miningSite != "" {
+		where += " AND mining_site = $1"
+		args = append(args, miningSite)
+	}
+	where += tenantClause(r, "org_id", &args)
+
+	windows, err := queryShiftWindows(where, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"shift_windows": windows,
+	})
+}
+
+// DeleteShiftWindow - Remove a shift window
+// DELETE /api/admin/shift-windows/{id}
+func DeleteShiftWindow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	args := []interface{}{vars["id"]}
+	scope := tenantClause(r, "org_id", &args)
+	result, err := database.DB.Exec("DELETE FROM shift_windows WHERE id = $1"+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting shift window")
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Shift window not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Shift window deleted successfully",
+	})
+}
+
+// loadShiftWindows returns the configured windows, all sites when miningSite is empty
+func loadShiftWindows(miningSite string) ([]ShiftWindow, error) {
+	if miningSite == "" {
+		return queryShiftWindows("")
+	}
+	return queryShiftWindows(" WHERE mining_site = $1", miningSite)
+}
+
+// queryShiftWindows returns the windows matching a WHERE clause
+func queryShiftWindows(where string, args ...interface{}) ([]ShiftWindow, error) {
+	query := `
+		SELECT id, mining_site, COALESCE(name, ''), TO_CHAR(start_time, 'HH24:MI'), TO_CHAR(end_time, 'HH24:MI'),
+		       COALESCE(days, '[]'::jsonb), timezone, grace_minutes, created_at
+		FROM shift_windows
+	` + where + " ORDER BY mining_site, start_time"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []ShiftWindow{}
+	for rows.Next() {
+		var sw ShiftWindow
+		var daysJSON []byte
+		if err := rows.Scan(&sw.ID, &sw.MiningSite, &sw.Name, &sw.StartTime, &sw.EndTime,
+			&daysJSON, &sw.Timezone, &sw.GraceMinutes, &sw.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(daysJSON, &sw.Days)
+		if sw.Days == nil {
+			sw.Days = []int{}
+		}
+		windows = append(windows, sw)
+	}
+	return windows, nil
+}
+
+// contains reports whether t falls inside the window, including its grace period
+func (sw ShiftWindow) contains(t time.Time) bool {
+	loc, err := time.LoadLocation(sw.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+
+	start, err1 := time.Parse("15:04", sw.StartTime)
+	end, err2 := time.Parse("15:04", sw.EndTime)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+	if endMin <= startMin {
+		endMin += 24 * 60 // night shift crossing midnight
+	}
+	startMin -= sw.GraceMinutes
+	endMin += sw.GraceMinutes
+
+	// A night shift that started yesterday counts against yesterday's weekday
+	nowMin := local.Hour()*60 + local.Minute()
+	for offset := 0; offset <= 1; offset++ {
+		if !sw.runsOn(local.AddDate(0, 0, -offset).Weekday()) {
+			continue
+		}
+		m := nowMin + offset*24*60
+		if m >= startMin && m < endMin {
+			return true
+		}
+	}
+	return false
+}
+
+func (sw ShiftWindow) runsOn(day time.Weekday) bool {
+	if len(sw.Days) == 0 {
+		return true
+	}
+	for _, d := range sw.Days {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// withinShiftWindows reports whether t falls in one of the shift windows of the user's
+// site, returning the windows. Users without a site, or at a site with no windows, are
+// always within them.
+func withinShiftWindows(userID string, t time.Time) (bool, []ShiftWindow, error) {
+	var miningSite string
+	database.DB.QueryRow("SELECT COALESCE(mining_site, '') FROM users WHERE user_id = $1", userID).Scan(&miningSite)
+	if miningSite == "" {
+		return true, nil, nil
+	}
+
+	windows, err := loadShiftWindows(miningSite)
+	if err != nil {
+		return false, nil, err
+	}
+	if len(windows) == 0 {
+		return true, windows, nil
+	}
+	for _, sw := range windows {
+		if sw.contains(t) {
+			return true, windows, nil
+		}
+	}
+	return false, windows, nil
+}
+
+// RequireShiftWindow rejects miner write operations made outside their site's configured
+// shift windows. Sites with no windows configured are unrestricted, and only miners are
+// restricted so supervisors can still correct records after hours.
+func RequireShiftWindow(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, _ := middleware.GetUserRoleFromContext(r.Context())
+		if role != string(models.RoleMiner) {
+			next(w, r)
+			return
+		}
+
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		now := time.Now()
+		allowed, windows, err := withinShiftWindows(userID, now)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if allowed {
+			next(w, r)
+			return
+		}
+
+		log.Printf("Rejected out-of-shift %s %s by %s", r.Method, r.URL.Path, userID)
+		respondWithJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error":         "This action is only allowed during your shift",
+			"shift_windows": windows,
+			"server_time":   now.UTC().Format(time.RFC3339),
+		})
+	}
+}
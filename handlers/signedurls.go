@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== SIGNED UPLOAD URLS ====================
+
+const uploadsURLPrefix = "/uploads/"
+
+// signUploadURL turns a stored /uploads/ path into a short-lived signed URL. Anything else
+// (seeded /assets/ videos, YouTube links, external media) is returned unchanged.
+// Expiry is rounded to the TTL so the same file keeps the same URL within a window and
+// clients can still cache it.
+func signUploadURL(stored string) string {
+	cfg := config.Get()
+	path := stored
+	if cfg.BaseURL != "" {
+		path = strings.TrimPrefix(path, cfg.BaseURL)
+	}
+	if !strings.HasPrefix(path, uploadsURLPrefix) {
+		return stored
+	}
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	ttl := int64(cfg.SignedURLTTL.Seconds())
+	expires := (time.Now().Unix()/ttl + 2) * ttl
+
+	signed := path + "?expires=" + strconv.FormatInt(expires, 10) + "&sig=" + uploadSignature(path, expires)
+	if cfg.BaseURL != "" && strings.HasPrefix(stored, cfg.BaseURL) {
+		return cfg.BaseURL + signed
+	}
+	return signed
+}
+
+// uploadDir is a directory under UPLOADS_DIR, e.g. uploadDir("videos")
+func uploadDir(sub string) string {
+	return filepath.Join(config.Get().UploadsDir, sub)
+}
+
+// uploadFilePath maps a stored /uploads/ URL path to its file under UPLOADS_DIR. The path is
+// cleaned so a validly signed URL can never escape the uploads directory.
+func uploadFilePath(urlPath string) string {
+	rel := filepath.Clean("/" + strings.TrimPrefix(urlPath, uploadsURLPrefix))
+	return filepath.Join(config.Get().UploadsDir, rel)
+}
+
+// signUploadURLPtr signs an optional URL
+func signUploadURLPtr(stored *string) *string {
+	if stored == nil {
+		return nil
+	}
+	signed := signUploadURL(*stored)
+	return &signed
+}
+
+func uploadSignature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.Get().UploadURLSecret))
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeSignedUpload - Serve an uploaded file after verifying its URL signature
+// GET /uploads/{path}?expires=...&sig=...
+func ServeSignedUpload(w http.ResponseWriter, r *http.Request) {
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	sig := r.URL.Query().Get("sig")
+	if err != nil || sig == "" {
+		respondWithError(w, http.StatusForbidden, "Signed URL required")
+		return
+	}
+	if time.Now().Unix() > expires {
+		respondWithError(w, http.StatusForbidden, "Signed URL has expired")
+		return
+	}
+	if !hmac.Equal([]byte(sig), []byte(uploadSignature(r.URL.Path, expires))) {
+		respondWithError(w, http.StatusForbidden, "Invalid URL signature")
+		return
+	}
+
+	filePath := uploadFilePath(r.URL.Path)
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		respondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(expires-time.Now().Unix(), 10))
+	http.ServeFile(w, r, filePath)
+}
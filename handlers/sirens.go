@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== SIREN / IOT TRIGGERS ====================
+
+// Endpoint types for physical alerting devices
+const (
+	SirenEndpointWebhook = "WEBHOOK"
+	SirenEndpointModbus  = "MODBUS_GATEWAY"
+)
+
+// Reasons a siren trigger is sent
+const (
+	SirenReasonCritical   = "CRITICAL_EMERGENCY"
+	SirenReasonEvacuation = "EVACUATION"
+)
+
+// Trigger delivery states
+const (
+	SirenTriggerAcknowledged = "ACKNOWLEDGED"
+	SirenTriggerFailed       = "FAILED"
+)
+
+// sirenTriggerAttempts is how many times a device is called before the trigger is marked failed
+const sirenTriggerAttempts = 3
+
+var sirenHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// SirenEndpoint represents a configured siren, display board or Modbus gateway
+type SirenEndpoint struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	EndpointType string    `json:"endpoint_type"`
+	URL          string    `json:"url"`
+	MiningSite   string    `json:"mining_site"`
+	UnitID       int       `json:"unit_id,omitempty"`  // Modbus slave/unit ID
+	Register     int       `json:"register,omitempty"` // Modbus coil/register to write
+	Value        int       `json:"value,omitempty"`    // Value written to the register to sound the siren
+	HasSecret    bool      `json:"has_secret"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SirenEndpointRequest represents the request body for configuring a siren endpoint
+type SirenEndpointRequest struct {
+	Name         string `json:"name"`
+	EndpointType string `json:"endpoint_type"`
+	URL          string `json:"url"`
+	MiningSite   string `json:"mining_site"` // empty means all sites
+	Secret       string `json:"secret"`      // used to sign webhook payloads
+	UnitID       int    `json:"unit_id"`
+	Register     int    `json:"register"`
+	Value        int    `json:"value"`
+}
+
+// SirenTrigger is a logged call to a siren endpoint
+type SirenTrigger struct {
+	ID             int        `json:"id"`
+	EndpointID     int        `json:"endpoint_id"`
+	EndpointName   string     `json:"endpoint_name"`
+	EmergencyID    int        `json:"emergency_id"`
+	Reason         string     `json:"reason"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseCode   int        `json:"response_code"`
+	ResponseBody   string     `json:"response_body"`
+	Error          string     `json:"error,omitempty"`
+	TriggeredAt    time.Time  `json:"triggered_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+}
+
+// CreateSirenEndpoint - Configure a siren/display board endpoint
+// POST /api/admin/sirens
+func CreateSirenEndpoint(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req SirenEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.EndpointType = strings.ToUpper(req.EndpointType)
+	if req.EndpointType == "" {
+		req.EndpointType = SirenEndpointWebhook
+	}
+	if req.EndpointType != SirenEndpointWebhook && req.EndpointType != SirenEndpointModbus {
+		respondWithError(w, http.StatusBadRequest, "endpoint_type must be WEBHOOK or MODBUS_GATEWAY")
+		return
+	}
+	if req.Name == "" || !(strings.HasPrefix(req.URL, "http://") || strings.HasPrefix(req.URL, "https://")) {
+		respondWithError(w, http.StatusBadRequest, "name and an http(s) url are required")
+		return
+	}
+	if req.EndpointType == SirenEndpointModbus && req.Value == 0 {
+		req.Value = 1
+	}
+	if req.MiningSite != "" {
+		if inOrg, err := siteInOrganization(r, req.MiningSite); err != nil || !inOrg {
+			respondWithError(w, http.StatusBadRequest, "Unknown mining site")
+			return
+		}
+	}
+
+	// Without a site the endpoint covers every site of the admin's organization, or every
+	// site when a platform admin sets it up
+	var endpointID int
+	err := database.DB.QueryRow(`
+		INSERT INTO siren_endpoints (name, endpoint_type, url, mining_site, secret, unit_id, register, value, created_by, created_at, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), $10)
+		RETURNING id
+	`, req.Name, req.EndpointType, req.URL, req.MiningSite, req.Secret, req.UnitID, req.Register, req.Value, adminID,
+		requestOrg(r)).Scan(&endpointID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating siren endpoint: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      endpointID,
+		"message": "Siren endpoint created successfully",
+	})
+}
+
+// GetSirenEndpoints - List configured siren endpoints
+// GET /api/admin/sirens
+func GetSirenEndpoints(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{}
+	scope := tenantClause(r, "org_id", &args)
+	rows, err := database.DB.Query(`
+		SELECT id, name, endpoint_type, url, COALESCE(mining_site, ''), COALESCE(unit_id, 0),
+		       COALESCE(register, 0), COALESCE(value, 0), COALESCE(secret, '') != '', is_active, created_at
+		FROM siren_endpoints WHERE 1=1`+scope+` ORDER BY name ASC
+	`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	endpoints := []SirenEndpoint{}
+	for rows.Next() {
+		var e SirenEndpoint
+		if err := rows.Scan(&e.ID, &e.Name, &e.EndpointType, &e.URL, &e.MiningSite, &e.UnitID,
+			&e.Register, &e.Value, &e.HasSecret, &e.IsActive, &e.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"endpoints": endpoints,
+	})
+}
+
+// DeleteSirenEndpoint - Disable a siren endpoint (trigger history is kept)
+// DELETE /api/admin/sirens/{id}
+func DeleteSirenEndpoint(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{mux.Vars(r)["id"]}
+	scope := tenantClause(r, "org_id", &args)
+	result, err := database.DB.Exec("UPDATE siren_endpoints SET is_active = false WHERE id = $1"+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error disabling siren endpoint")
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Siren endpoint not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Siren endpoint disabled",
+	})
+}
+
+// GetSirenTriggers - Trigger and acknowledgment log
+// GET /api/admin/sirens/triggers?emergency_id=12
+func GetSirenTriggers(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT t.id, t.endpoint_id, e.name, t.emergency_id, t.reason, t.status, t.attempts,
+		       COALESCE(t.response_code, 0), COALESCE(t.response_body, ''), COALESCE(t.error, ''),
+		       t.triggered_at, t.acknowledged_at
+		FROM siren_triggers t
+		JOIN siren_endpoints e ON t.endpoint_id = e.id
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	query += tenantClause(r, "e.org_id", &args)
+	if emergencyID := r.URL.Query().Get("emergency_id"); emergencyID != "" {
+		args = append(args, emergencyID)
+		query += " AND t.emergency_id = $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY t.triggered_at DESC LIMIT 200"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	triggers := []SirenTrigger{}
+	for rows.Next() {
+		var t SirenTrigger
+		var ackAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.EndpointID, &t.EndpointName, &t.EmergencyID, &t.Reason, &t.Status,
+			&t.Attempts, &t.ResponseCode, &t.ResponseBody, &t.Error, &t.TriggeredAt, &ackAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		if ackAt.Valid {
+			t.AcknowledgedAt = &ackAt.Time
+		}
+		triggers = append(triggers, t)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"triggers": triggers,
+	})
+}
+
+// StartEvacuation - Start a site evacuation for an emergency and sound the sirens
+// POST /api/supervisor/emergencies/{id}/evacuate
+func StartEvacuation(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	emergencyID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid emergency ID")
+		return
+	}
+
+	// Only emergencies of the caller's organization, and of their own site when they have
+	// one. Claiming the evacuation in the UPDATE itself means concurrent requests sound the
+	// sirens once.
+	args := []interface{}{supervisorID, emergencyID}
+	scope := tenantClause(r, "e.org_id", &args) + `
+		AND (COALESCE((SELECT mining_site FROM users WHERE user_id = $1), '') = ''
+		     OR (SELECT mining_site FROM users WHERE user_id = e.user_id) = (SELECT mining_site FROM users WHERE user_id = $1))`
+	result, err := database.DB.Exec(`
+		UPDATE emergencies e SET evacuation_started_at = NOW(), evacuation_started_by = $1
+		WHERE e.id = $2 AND e.evacuation_started_at IS NULL`+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting evacuation: "+err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM emergencies e WHERE e.id = $2"+scope+")", args...).Scan(&exists)
+		if exists {
+			respondWithError(w, http.StatusConflict, "Evacuation already started")
+		} else {
+			respondWithError(w, http.StatusNotFound, "Emergency not found")
+		}
+		return
+	}
+
+	go triggerSirens(emergencyID, SirenReasonEvacuation)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Evacuation started, site sirens are being triggered",
+	})
+}
+
+// triggerSirens calls every active endpoint for the emergency's mining site and logs
+// each device's acknowledgment. It is meant to run in its own goroutine.
+func triggerSirens(emergencyID int, reason string) {
+	var severity, issue, miningSite string
+	var location sql.NullString
+	var orgID sql.NullInt64
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(e.severity, ''), COALESCE(e.issue, ''), e.location, COALESCE(u.mining_site, ''), e.org_id
+		FROM emergencies e
+		LEFT JOIN users u ON e.user_id = u.user_id
+		WHERE e.id = $1
+	`, emergencyID).Scan(&severity, &issue, &location, &miningSite, &orgID)
+	if err != nil {
+		log.Printf("Warning: siren trigger for emergency %d failed: %v", emergencyID, err)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, name, endpoint_type, url, COALESCE(secret, ''), COALESCE(unit_id, 0),
+		       COALESCE(register, 0), COALESCE(value, 0)
+		FROM siren_endpoints
+		WHERE is_active = true AND (COALESCE(mining_site, '') = '' OR mining_site = $1)
+		  AND (org_id IS NULL OR org_id = $2)
+	`, miningSite, orgID)
+	if err != nil {
+		log.Printf("Warning: siren trigger for emergency %d failed: %v", emergencyID, err)
+		return
+	}
+
+	type target struct {
+		SirenEndpoint
+		secret string
+	}
+	targets := []target{}
+	for rows.Next() {
+		var t target
+		if err := rows.Scan(&t.ID, &t.Name, &t.EndpointType, &t.URL, &t.secret, &t.UnitID, &t.Register, &t.Value); err != nil {
+			log.Printf("Warning: error scanning siren endpoint: %v", err)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+
+	event := map[string]interface{}{
+		"event":        reason,
+		"emergency_id": emergencyID,
+		"severity":     severity,
+		"issue":        issue,
+		"location":     location.String,
+		"mining_site":  miningSite,
+		"triggered_at": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, t := range targets {
+		var payload interface{} = event
+		if t.EndpointType == SirenEndpointModbus {
+			// Modbus HTTP gateways expect a register write rather than the event itself
+			payload = map[string]interface{}{
+				"unit_id":  t.UnitID,
+				"register": t.Register,
+				"value":    t.Value,
+				"event":    event,
+			}
+		}
+		body, _ := json.Marshal(payload)
+		go deliverSirenTrigger(t.ID, t.Name, t.URL, t.secret, body, emergencyID, reason)
+	}
+}
+
+func deliverSirenTrigger(endpointID int, name, url, secret string, body []byte, emergencyID int, reason string) {
+	var statusCode int
+	var respBody string
+	var lastErr error
+
+	attempts := 0
+	for attempts < sirenTriggerAttempts {
+		attempts++
+		statusCode, respBody, lastErr = postSirenPayload(url, secret, body)
+		if lastErr == nil && statusCode >= 200 && statusCode < 300 {
+			break
+		}
+		if attempts < sirenTriggerAttempts {
+			time.Sleep(time.Duration(attempts) * time.Second)
+		}
+	}
+
+	status := SirenTriggerAcknowledged
+	var ackAt interface{} = time.Now()
+	errMsg := ""
+	if lastErr != nil || statusCode < 200 || statusCode >= 300 {
+		status = SirenTriggerFailed
+		ackAt = nil
+		if lastErr != nil {
+			errMsg = lastErr.Error()
+		} else {
+			errMsg = fmt.Sprintf("unexpected status %d", statusCode)
+		}
+		log.Printf("Warning: siren '%s' did not acknowledge emergency %d: %s", name, emergencyID, errMsg)
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO siren_triggers (endpoint_id, emergency_id, reason, status, attempts, response_code,
+		                            response_body, error, triggered_at, acknowledged_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), $9)
+	`, endpointID, emergencyID, reason, status, attempts, statusCode, respBody, errMsg, ackAt)
+	if err != nil {
+		log.Printf("Warning: failed to log siren trigger for '%s': %v", name, err)
+	}
+}
+
+func postSirenPayload(url, secret string, body []byte) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-MineSafe-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := sirenHTTPClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, string(respBody), nil
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ==================== SITE SETTINGS ====================
+
+// Site settings are stored per site in system_settings under this prefix and the site ID
+const siteSettingsKeyPrefix = "site_settings:"
+
+// How often crews tick the pre-start and PPE checklists
+const (
+	ChecklistFrequencyDaily  = "DAILY"
+	ChecklistFrequencyWeekly = "WEEKLY" // ticks last from Monday to Sunday
+)
+
+// Where the star video crews see comes from
+const (
+	StarVideoSourceSupervisor = "SUPERVISOR" // each supervisor picks for their own crew
+	StarVideoSourceSite       = "SITE"       // the latest pick by any supervisor at the site
+	StarVideoSourceTrending   = "TRENDING"   // the supervisor's pick, else the top trending module
+)
+
+// ppeItems are the items recorded on every PPE verification
+var ppeItems = []string{
+	"safety_helmet", "protective_gloves", "safety_shoes", "high_visibility_vest", "safety_goggles",
+	"respirator", "ear_protection", "face_shield", "safety_harness", "knee_pads",
+}
+
+// SiteSettings are the behaviors a site's supervisors can tune themselves
+type SiteSettings struct {
+	Timezone           string   `json:"timezone"` // empty means the first shift's, then DEFAULT_TIMEZONE
+	ChecklistFrequency string   `json:"checklist_frequency"`
+	PPEMandatoryItems  []string `json:"ppe_mandatory_items"` // PPE verifications report these if missing
+	StarVideoSource    string   `json:"star_video_source"`
+	// Wearable heart rates outside this range alert the supervisor
+	HeartRateMin int `json:"heart_rate_min"`
+	HeartRateMax int `json:"heart_rate_max"`
+	// How long a miner has to dismiss a detected fall before an SOS is raised for them
+	FallConfirmSeconds int `json:"fall_confirm_seconds"`
+}
+
+// SiteShift is a shift window as edited from the site settings page
+type SiteShift struct {
+	Name         string `json:"name"`
+	StartTime    string `json:"start_time"` // HH:MM, site local time
+	EndTime      string `json:"end_time"`
+	Days         []int  `json:"days"` // 0 = Sunday ... 6 = Saturday; empty means every day
+	GraceMinutes int    `json:"grace_minutes"`
+}
+
+func defaultSiteSettings() SiteSettings {
+	return SiteSettings{
+		ChecklistFrequency: ChecklistFrequencyDaily,
+		PPEMandatoryItems:  []string{},
+		StarVideoSource:    StarVideoSourceSupervisor,
+		HeartRateMin:       40,
+		HeartRateMax:       180,
+		FallConfirmSeconds: 60,
+	}
+}
+
+func loadSiteSettings(siteID int) (SiteSettings, error) {
+	settings := defaultSiteSettings()
+	var value []byte
+	err := database.DB.QueryRow("SELECT value FROM system_settings WHERE key = $1", siteSettingsKeyPrefix+strconv.Itoa(siteID)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	err = json.Unmarshal(value, &settings)
+	return settings, err
+}
+
+// userSiteSettings returns the settings for the user's site, or the defaults if the user
+// has no site or the settings can't be read
+func userSiteSettings(userID string) SiteSettings {
+	var siteID sql.NullInt64
+	database.DB.QueryRow("SELECT site_id FROM users WHERE user_id = $1", userID).Scan(&siteID)
+	if !siteID.Valid {
+		return defaultSiteSettings()
+	}
+	settings, err := loadSiteSettings(int(siteID.Int64))
+	if err != nil {
+		log.Printf("Warning: failed to load settings for site %d: %v", siteID.Int64, err)
+		return defaultSiteSettings()
+	}
+	return settings
+}
+
+// checklistPeriod is the date checklist ticks made at t are recorded against: the day
+// itself, or the Monday of its week at weekly sites
+func checklistPeriod(t time.Time, frequency string) string {
+	if frequency == ChecklistFrequencyWeekly {
+		offset := (int(t.Weekday()) + 6) % 7
+		t = t.AddDate(0, 0, -offset)
+	}
+	return t.Format("2006-01-02")
+}
+
+// userChecklistPeriod is the current checklist period for the user
+func userChecklistPeriod(userID string) string {
+	return checklistPeriod(time.Now().In(userLocation(userID)), userSiteSettings(userID).ChecklistFrequency)
+}
+
+// missingMandatoryPPE lists the site's mandatory PPE items neither detected nor ticked
+func missingMandatoryPPE(mandatory []string, ai map[string]string, manual map[string]bool) []string {
+	missing := []string{}
+	for _, item := range mandatory {
+		if ai[item] != "yes" && !manual[item] {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+// supervisorSite returns the site the caller belongs to
+func supervisorSite(userID string) (int, string, error) {
+	var siteID sql.NullInt64
+	var name string
+	err := database.DB.QueryRow(`
+		SELECT u.site_id, COALESCE(s.name, '')
+		FROM users u LEFT JOIN sites s ON s.id = u.site_id
+		WHERE u.user_id = $1
+	`, userID).Scan(&siteID, &name)
+	if err != nil {
+		return 0, "", err
+	}
+	if !siteID.Valid {
+		return 0, "", sql.ErrNoRows
+	}
+	return int(siteID.Int64), name, nil
+}
+
+// writeSiteSettings responds with the settings and shift windows for a site
+func writeSiteSettings(w http.ResponseWriter, siteID int, siteName string) {
+	settings, err := loadSiteSettings(siteID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading site settings")
+		return
+	}
+	windows, err := loadShiftWindows(siteName)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	timezone := settings.Timezone
+	shifts := []SiteShift{}
+	for _, sw := range windows {
+		if timezone == "" {
+			timezone = sw.Timezone
+		}
+		shifts = append(shifts, SiteShift{
+			Name:         sw.Name,
+			StartTime:    sw.StartTime,
+			EndTime:      sw.EndTime,
+			Days:         sw.Days,
+			GraceMinutes: sw.GraceMinutes,
+		})
+	}
+	if timezone == "" {
+		timezone = defaultLocation().String()
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"site_id":             siteID,
+		"mining_site":         siteName,
+		"timezone":            timezone,
+		"shifts":              shifts,
+		"checklist_frequency": settings.ChecklistFrequency,
+		"ppe_mandatory_items": settings.PPEMandatoryItems,
+		"star_video_source":   settings.StarVideoSource,
+		"ppe_items":           ppeItems,
+
+		"heart_rate_min":       settings.HeartRateMin,
+		"heart_rate_max":       settings.HeartRateMax,
+		"fall_confirm_seconds": settings.FallConfirmSeconds,
+	})
+}
+
+// GetSiteSettings - Settings for the supervisor's site
+// GET /api/supervisor/site-settings
+func GetSiteSettings(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	siteID, siteName, err := supervisorSite(supervisorID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Your account is not assigned to a site")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	writeSiteSettings(w, siteID, siteName)
+}
+
+// UpdateSiteSettingsRequest represents the request body for changing site settings. Fields
+// left out keep their current value; shifts, when given, replace all of the site's shifts.
+type UpdateSiteSettingsRequest struct {
+	Timezone           *string      `json:"timezone"`
+	Shifts             *[]SiteShift `json:"shifts"`
+	ChecklistFrequency *string      `json:"checklist_frequency"`
+	PPEMandatoryItems  *[]string    `json:"ppe_mandatory_items"`
+	StarVideoSource    *string      `json:"star_video_source"`
+	HeartRateMin       *int         `json:"heart_rate_min"`
+	HeartRateMax       *int         `json:"heart_rate_max"`
+	FallConfirmSeconds *int         `json:"fall_confirm_seconds"`
+}
+
+// UpdateSiteSettings - Change shift times, checklist frequency, mandatory PPE, the star
+// video source and wearable alert thresholds for the supervisor's site
+// PUT /api/supervisor/site-settings
+func UpdateSiteSettings(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	siteID, siteName, err := supervisorSite(supervisorID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Your account is not assigned to a site")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	var req UpdateSiteSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	settings, err := loadSiteSettings(siteID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading site settings")
+		return
+	}
+
+	if req.ChecklistFrequency != nil {
+		switch *req.ChecklistFrequency {
+		case ChecklistFrequencyDaily, ChecklistFrequencyWeekly:
+			settings.ChecklistFrequency = *req.ChecklistFrequency
+		default:
+			respondWithError(w, http.StatusBadRequest, "checklist_frequency must be DAILY or WEEKLY")
+			return
+		}
+	}
+	if req.StarVideoSource != nil {
+		switch *req.StarVideoSource {
+		case StarVideoSourceSupervisor, StarVideoSourceSite, StarVideoSourceTrending:
+			settings.StarVideoSource = *req.StarVideoSource
+		default:
+			respondWithError(w, http.StatusBadRequest, "star_video_source must be SUPERVISOR, SITE or TRENDING")
+			return
+		}
+	}
+	if req.PPEMandatoryItems != nil {
+		items := []string{}
+		for _, item := range *req.PPEMandatoryItems {
+			known := false
+			for _, p := range ppeItems {
+				if p == item {
+					known = true
+				}
+			}
+			if !known {
+				respondWithError(w, http.StatusBadRequest, "Unknown PPE item: "+item)
+				return
+			}
+			items = append(items, item)
+		}
+		settings.PPEMandatoryItems = items
+	}
+
+	if req.HeartRateMin != nil {
+		settings.HeartRateMin = *req.HeartRateMin
+	}
+	if req.HeartRateMax != nil {
+		settings.HeartRateMax = *req.HeartRateMax
+	}
+	if settings.HeartRateMin < 20 || settings.HeartRateMax > 250 || settings.HeartRateMin >= settings.HeartRateMax {
+		respondWithError(w, http.StatusBadRequest, "Heart rate range must lie within 20-250 bpm with heart_rate_min below heart_rate_max")
+		return
+	}
+	if req.FallConfirmSeconds != nil {
+		if *req.FallConfirmSeconds < 10 || *req.FallConfirmSeconds > 600 {
+			respondWithError(w, http.StatusBadRequest, "fall_confirm_seconds must be between 10 and 600")
+			return
+		}
+		settings.FallConfirmSeconds = *req.FallConfirmSeconds
+	}
+
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			respondWithError(w, http.StatusBadRequest, "Unknown timezone: "+*req.Timezone)
+			return
+		}
+		settings.Timezone = *req.Timezone
+	}
+	if req.Shifts != nil {
+		for i, shift := range *req.Shifts {
+			if _, err := time.Parse("15:04", shift.StartTime); err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("shifts[%d].start_time must be HH:MM", i))
+				return
+			}
+			if _, err := time.Parse("15:04", shift.EndTime); err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("shifts[%d].end_time must be HH:MM", i))
+				return
+			}
+			for _, d := range shift.Days {
+				if d < 0 || d > 6 {
+					respondWithError(w, http.StatusBadRequest, fmt.Sprintf("shifts[%d].days must be between 0 (Sunday) and 6 (Saturday)", i))
+					return
+				}
+			}
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	value, _ := json.Marshal(settings)
+	_, err = tx.Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, siteSettingsKeyPrefix+strconv.Itoa(siteID), value, supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving site settings: "+err.Error())
+		return
+	}
+
+	if req.Shifts != nil {
+		timezone := settings.Timezone
+		if timezone == "" {
+			timezone = defaultLocation().String()
+			tx.QueryRow("SELECT timezone FROM shift_windows WHERE site_id = $1 ORDER BY id LIMIT 1", siteID).Scan(&timezone)
+		}
+		if _, err := tx.Exec("DELETE FROM shift_windows WHERE site_id = $1", siteID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating shifts: "+err.Error())
+			return
+		}
+		for _, shift := range *req.Shifts {
+			if shift.Days == nil {
+				shift.Days = []int{}
+			}
+			if shift.GraceMinutes < 0 {
+				shift.GraceMinutes = 0
+			}
+			daysJSON, _ := json.Marshal(shift.Days)
+			_, err := tx.Exec(`
+				INSERT INTO shift_windows (mining_site, name, start_time, end_time, days, timezone, grace_minutes, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+			`, siteName, shift.Name, shift.StartTime, shift.EndTime, daysJSON, timezone, shift.GraceMinutes)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error updating shifts: "+err.Error())
+				return
+			}
+		}
+	} else if req.Timezone != nil {
+		if _, err := tx.Exec("UPDATE shift_windows SET timezone = $1 WHERE site_id = $2", *req.Timezone, siteID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating shifts: "+err.Error())
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving site settings: "+err.Error())
+		return
+	}
+	log.Printf("Site settings for %s updated by %s", siteName, supervisorID)
+
+	writeSiteSettings(w, siteID, siteName)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== MINING SITES ====================
+
+// SiteRequest represents the request body for creating or renaming a site
+type SiteRequest struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// AdminGetSites - List the organization's mining sites with their user and zone counts
+// GET /api/admin/sites
+func AdminGetSites(w http.ResponseWriter, r *http.Request) {
+	sites, err := database.GetSites(middleware.GetOrgIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"sites": sites,
+		"count": len(sites),
+	})
+}
+
+// AdminCreateSite - Add a mining site to the admin's organization and provision its default
+// checklists. A platform admin's site starts a new organization.
+// POST /api/admin/sites
+func AdminCreateSite(w http.ResponseWriter, r *http.Request) {
+	var req SiteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	id, err := database.CreateSite(req.Name, req.Location, middleware.GetOrgIDFromContext(r.Context()))
+	if err == database.ErrSiteNameTaken {
+		respondWithError(w, http.StatusConflict, "A site with this name already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating site: "+err.Error())
+		return
+	}
+
+	if err := database.ProvisionOrganizationChecklists(req.Name); err != nil {
+		log.Printf("Failed to provision checklists for site %s: %v", req.Name, err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Site created successfully",
+	})
+}
+
+// AdminUpdateSite - Rename a mining site or change its location. Everything assigned to the
+// site follows the new name.
+// PUT /api/admin/sites/{id}
+func AdminUpdateSite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid site ID")
+		return
+	}
+
+	var req SiteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	var inOrg bool
+	args := []interface{}{id}
+	scope := tenantClause(r, "org_id", &args)
+	if err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM sites WHERE id = $1"+scope+")", args...).Scan(&inOrg); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !inOrg {
+		respondWithError(w, http.StatusNotFound, "Site not found")
+		return
+	}
+
+	err = database.RenameSite(id, req.Name, req.Location)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Site not found")
+		return
+	}
+	if err == database.ErrSiteNameTaken {
+		respondWithError(w, http.StatusConflict, "A site with this name already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating site: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Site updated successfully",
+	})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/internal/notifications"
+	"MineSafeBackend/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== EMERGENCY SMS ====================
+
+// Supervisors underground often have no data signal, so HIGH and CRITICAL emergencies are
+// also texted to the reporter's supervisor and to the site's emergency contacts on call.
+// Every text is logged with its outcome.
+
+// SMS delivery states
+const (
+	SMSStatusSent    = "SENT"
+	SMSStatusFailed  = "FAILED"
+	SMSStatusSkipped = "SKIPPED" // the number isn't in international format
+)
+
+var smsSender notifications.SMSSender
+
+// InitSMS builds the SMS sender for the configured provider; without one no texts are sent
+func InitSMS(cfg *config.Config) {
+	sender, err := notifications.NewSMSSender(notifications.SMSConfig{
+		Provider:           cfg.SMSProvider,
+		TwilioAccountSID:   cfg.TwilioAccountSID,
+		TwilioAuthToken:    cfg.TwilioAuthToken,
+		TwilioFrom:         cfg.TwilioFrom,
+		AWSRegion:          cfg.AWSRegion,
+		AWSAccessKeyID:     cfg.AWSAccessKeyID,
+		AWSSecretAccessKey: cfg.AWSSecretAccessKey,
+		SNSSenderID:        cfg.SNSSenderID,
+	})
+	if err != nil {
+		if err != notifications.ErrNotConfigured {
+			log.Printf("Warning: SMS alerts disabled: %v", err)
+		}
+		return
+	}
+	smsSender = sender
+	log.Printf("Emergency SMS alerts enabled via %s", cfg.SMSProvider)
+}
+
+// smsAlertSeverity reports whether an emergency is severe enough to text about
+func smsAlertSeverity(severity string) bool {
+	return strings.EqualFold(severity, "HIGH") || strings.EqualFold(severity, "CRITICAL")
+}
+
+// smsRecipient is a number an emergency is texted to
+type smsRecipient struct {
+	Name  string
+	Phone string
+}
+
+// emergencySMSRecipients lists the reporter's supervisor and the active directory contacts
+// at their site who are on call now, each number once
+func emergencySMSRecipients(userID string) (string, []smsRecipient, error) {
+	var reporterName, miningSite, supervisorName, supervisorPhone string
+	err := database.DB.QueryRow(`
+		SELECT u.name, COALESCE(u.mining_site, ''), COALESCE(s.name, ''), COALESCE(s.phone, '')
+		FROM users u
+		LEFT JOIN users s ON s.user_id = u.supervisor_id AND COALESCE(s.is_active, true)
+		WHERE u.user_id = $1
+	`, userID).Scan(&reporterName, &miningSite, &supervisorName, &supervisorPhone)
+	if err != nil {
+		return "", nil, err
+	}
+
+	recipients := []smsRecipient{}
+	if supervisorPhone != "" {
+		recipients = append(recipients, smsRecipient{Name: supervisorName, Phone: supervisorPhone})
+	}
+	if miningSite == "" {
+		return reporterName, recipients, nil
+	}
+
+	windows, err := loadShiftWindows(miningSite)
+	if err != nil {
+		return "", nil, err
+	}
+	windowByID := map[int]ShiftWindow{}
+	for _, sw := range windows {
+		windowByID[sw.ID] = sw
+	}
+	contacts, err := loadDirectoryContacts(
+		" WHERE is_active = true AND LOWER(mining_site) = LOWER(TRIM($1))", miningSite)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	for _, c := range contacts {
+		if c.ShiftWindowID != nil {
+			if sw, exists := windowByID[*c.ShiftWindowID]; !exists || !sw.contains(now) {
+				continue
+			}
+		}
+		recipients = append(recipients, smsRecipient{Name: c.Name, Phone: c.Phone})
+	}
+	return reporterName, recipients, nil
+}
+
+// sendEmergencySMS texts a HIGH or CRITICAL emergency to everyone who should hear of it.
+// Run it in a goroutine; providers can take seconds per message.
+func sendEmergencySMS(e *models.Emergency) {
+	if smsSender == nil || !smsAlertSeverity(e.Severity) {
+		return
+	}
+	reporterName, recipients, err := emergencySMSRecipients(e.UserID)
+	if err != nil {
+		log.Printf("Warning: failed to find SMS recipients for emergency %d: %v", e.ID, err)
+		return
+	}
+
+	where := fmt.Sprintf("%.5f, %.5f", e.Lat, e.Lon)
+	if e.Location != nil && *e.Location != "" {
+		where = *e.Location
+	}
+	body := fmt.Sprintf("MineSafe %s emergency #%d: %s reported at %s. %s",
+		strings.ToUpper(e.Severity), e.ID, reporterName, where, truncate(e.Issue, 200))
+
+	sent := map[string]bool{}
+	for _, rcpt := range recipients {
+		phone, valid := notifications.NormalizePhone(rcpt.Phone)
+		if sent[phone] {
+			continue
+		}
+		sent[phone] = true
+
+		status, errText := SMSStatusSent, ""
+		if !valid {
+			status, errText = SMSStatusSkipped, "not an international (+country code) number"
+		} else if err := smsSender.SendSMS(phone, body); err != nil {
+			status, errText = SMSStatusFailed, err.Error()
+			log.Printf("Warning: failed to text emergency %d to %s: %v", e.ID, rcpt.Name, err)
+		}
+		_, err := database.DB.Exec(`
+			INSERT INTO emergency_sms (emergency_id, recipient_name, phone, status, error, sent_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NOW())
+		`, e.ID, rcpt.Name, phone, status, truncate(errText, 500))
+		if err != nil {
+			log.Printf("Warning: failed to log SMS for emergency %d: %v", e.ID, err)
+		}
+	}
+}
+
+// EmergencySMS is a logged text about an emergency
+type EmergencySMS struct {
+	ID            int       `json:"id"`
+	EmergencyID   int       `json:"emergency_id"`
+	RecipientName string    `json:"recipient_name"`
+	Phone         string    `json:"phone"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	SentAt        time.Time `json:"sent_at"`
+}
+
+// GetEmergencySMSLog - Texts sent about emergencies, newest first
+// GET /api/admin/emergency-sms?emergency_id=12
+func GetEmergencySMSLog(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT m.id, m.emergency_id, m.recipient_name, m.phone, m.status, COALESCE(m.error, ''), m.sent_at
+		FROM emergency_sms m
+		JOIN emergencies e ON e.id = m.emergency_id
+		WHERE true`
+	args := []interface{}{}
+	if v := r.URL.Query().Get("emergency_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid emergency_id")
+			return
+		}
+		args = append(args, id)
+		query += " AND m.emergency_id = $1"
+	}
+	query += tenantClause(r, "e.org_id", &args) + " ORDER BY m.sent_at DESC LIMIT 200"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	messages := []EmergencySMS{}
+	for rows.Next() {
+		var m EmergencySMS
+		if err := rows.Scan(&m.ID, &m.EmergencyID, &m.RecipientName, &m.Phone, &m.Status, &m.Error, &m.SentAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		messages = append(messages, m)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"messages": messages,
+	})
+}
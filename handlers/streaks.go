@@ -16,21 +16,41 @@ func GetLearningStreaks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all miners under this supervisor with their streaks
+	// Get all miners under this supervisor with their streaks. Completions are aggregated
+	// per miner before the join, reading only the last 30 days through
+	// idx_module_completions_miner_completed instead of grouping the joined rows.
 	rows, err := database.DB.Query(`
+		WITH recent AS (
+			SELECT mc.miner_id,
+			       COUNT(DISTINCT DATE(mc.completed_at AT TIME ZONE $2)) AS active_days,
+			       MAX(mc.completed_at) AS last_completed,
+			       COUNT(*) AS total_modules
+			FROM module_completions mc
+			WHERE mc.miner_id IN (SELECT user_id FROM users WHERE supervisor_id = $1 AND role = ANY($3) AND deleted_at IS NULL)
+			AND mc.completed_at >= NOW() - INTERVAL '30 days' AND mc.passed
+			GROUP BY mc.miner_id
+		),
+		leave AS (
+			SELECT l.user_id,
+			       SUM(LEAST(l.end_date, CURRENT_DATE) - GREATEST(l.start_date, CURRENT_DATE - 29) + 1) AS days
+			FROM miner_leave l
+			WHERE l.user_id IN (SELECT user_id FROM users WHERE supervisor_id = $1 AND role = ANY($3) AND deleted_at IS NULL)
+			AND l.end_date >= CURRENT_DATE - 29 AND l.start_date <= CURRENT_DATE
+			GROUP BY l.user_id
+		)
 		SELECT 
 			u.user_id,
 			u.name,
-			COALESCE(COUNT(DISTINCT DATE(mc.completed_at)), 0) as current_streak,
-			COALESCE(MAX(mc.completed_at), u.created_at) as last_completed,
-			COALESCE(COUNT(mc.id), 0) as total_modules
+			COALESCE(r.active_days, 0) as current_streak,
+			COALESCE(r.last_completed, u.created_at) as last_completed,
+			COALESCE(r.total_modules, 0) as total_modules,
+			COALESCE(lv.days, 0) as leave_days
 		FROM users u
-		LEFT JOIN module_completions mc ON u.user_id = mc.miner_id 
-			AND mc.completed_at >= NOW() - INTERVAL '30 days'
-		WHERE u.supervisor_id = $1 AND u.role = 'MINER'
-		GROUP BY u.user_id, u.name, u.created_at
+		LEFT JOIN recent r ON r.miner_id = u.user_id
+		LEFT JOIN leave lv ON lv.user_id = u.user_id
+		WHERE u.supervisor_id = $1 AND u.role = ANY($3) AND u.deleted_at IS NULL
 		ORDER BY current_streak DESC, u.name
-	`, supervisorID)
+	`, supervisorID, userLocation(supervisorID).String(), crewRoles())
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -42,7 +62,7 @@ func GetLearningStreaks(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var streak models.LearningStreak
 		err := rows.Scan(&streak.MinerID, &streak.MinerName, &streak.CurrentStreak, 
-			&streak.LastCompleted, &streak.TotalModules)
+			&streak.LastCompleted, &streak.TotalModules, &streak.LeaveDays)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning streak data")
 			return
@@ -66,16 +86,19 @@ func GetMinerStreak(w http.ResponseWriter, r *http.Request) {
 		SELECT 
 			u.user_id,
 			u.name,
-			COALESCE(COUNT(DISTINCT DATE(mc.completed_at)), 0) as current_streak,
+			COALESCE(COUNT(DISTINCT DATE(mc.completed_at AT TIME ZONE $2)), 0) as current_streak,
 			COALESCE(MAX(mc.completed_at), u.created_at) as last_completed,
-			COALESCE(COUNT(mc.id), 0) as total_modules
+			COALESCE(COUNT(mc.id), 0) as total_modules,
+			(SELECT COALESCE(SUM(LEAST(l.end_date, CURRENT_DATE) - GREATEST(l.start_date, CURRENT_DATE - 29) + 1), 0)
+			 FROM miner_leave l WHERE l.user_id = u.user_id
+			 AND l.end_date >= CURRENT_DATE - 29 AND l.start_date <= CURRENT_DATE) as leave_days
 		FROM users u
 		LEFT JOIN module_completions mc ON u.user_id = mc.miner_id 
-			AND mc.completed_at >= NOW() - INTERVAL '30 days'
+			AND mc.completed_at >= NOW() - INTERVAL '30 days' AND mc.passed
 		WHERE u.user_id = $1
 		GROUP BY u.user_id, u.name, u.created_at
-	`, minerID).Scan(&streak.MinerID, &streak.MinerName, &streak.CurrentStreak, 
-		&streak.LastCompleted, &streak.TotalModules)
+	`, minerID, userLocation(minerID).String()).Scan(&streak.MinerID, &streak.MinerName, &streak.CurrentStreak, 
+		&streak.LastCompleted, &streak.TotalModules, &streak.LeaveDays)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -104,7 +127,7 @@ func GetMinerCompletions(w http.ResponseWriter, r *http.Request) {
 			vm.title as video_title
 		FROM module_completions mc
 		JOIN video_modules vm ON mc.video_id = vm.id
-		WHERE mc.miner_id = $1
+		WHERE mc.miner_id = $1 AND mc.passed
 		ORDER BY mc.completed_at DESC
 		LIMIT 50
 	`, minerID)
@@ -157,66 +180,73 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats := make(map[string]interface{})
-
-	// Total miners
-	var totalMiners int
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM users WHERE supervisor_id = $1 AND role = 'MINER'",
-		supervisorID,
-	).Scan(&totalMiners)
-	stats["total_miners"] = totalMiners
-
-	// Active miners (completed a module in last 7 days)
-	var activeMiners int
-	database.DB.QueryRow(`
-		SELECT COUNT(DISTINCT miner_id) 
+	// One pass over the team's completions computes every completion statistic with
+	// FILTERed aggregates instead of a separate scan and join per number
+	var totalMiners, totalOperators, activeMiners, totalModules, monthlyCompletions, todayCompletions int
+	var avgScore float64
+	loc := userLocation(supervisorID)
+	today := time.Now().In(loc).Format("2006-01-02")
+	err := database.DB.QueryRow(`
+		WITH team AS (
+			SELECT user_id, role FROM users WHERE supervisor_id = $1 AND role = ANY($4) AND deleted_at IS NULL
+		),
+		star AS (
+			SELECT video_id FROM star_videos
+			WHERE supervisor_id = $1 AND set_date = $2 AND is_active = true
+		)
+		SELECT
+			(SELECT COUNT(*) FROM team),
+			(SELECT COUNT(*) FROM team WHERE role = $5),
+			COUNT(DISTINCT mc.miner_id) FILTER (WHERE mc.passed AND mc.completed_at >= NOW() - INTERVAL '7 days'),
+			(SELECT COUNT(*) FROM video_modules WHERE is_active = true),
+			COUNT(*) FILTER (WHERE mc.passed AND mc.completed_at >= DATE_TRUNC('month', NOW())),
+			COALESCE(AVG(CAST(mc.score AS FLOAT) / NULLIF(CAST(mc.total_questions AS FLOAT), 0) * 100), 0),
+			COUNT(DISTINCT mc.miner_id) FILTER (WHERE mc.passed AND (mc.completed_at AT TIME ZONE $3)::date = $2
+				AND mc.video_id IN (SELECT video_id FROM star))
 		FROM module_completions mc
-		JOIN users u ON mc.miner_id = u.user_id
-		WHERE u.supervisor_id = $1 AND mc.completed_at >= NOW() - INTERVAL '7 days'
-	`, supervisorID).Scan(&activeMiners)
-	stats["active_miners"] = activeMiners
-
-	// Total video modules
-	var totalModules int
-	database.DB.QueryRow("SELECT COUNT(*) FROM video_modules WHERE is_active = true").Scan(&totalModules)
-	stats["total_modules"] = totalModules
-
-	// Total completions this month
-	var monthlyCompletions int
+		WHERE mc.miner_id IN (SELECT user_id FROM team)
+	`, supervisorID, today, loc.String(), crewRoles(), models.RoleOperator).Scan(&totalMiners, &totalOperators, &activeMiners, &totalModules, &monthlyCompletions, &avgScore, &todayCompletions)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	// Checklist and PPE compliance over the last 7 days, from the daily summary; days on
+	// leave don't count against the team
+	var checklistPct, ppePct float64
 	database.DB.QueryRow(`
-		SELECT COUNT(*) 
-		FROM module_completions mc
-		JOIN users u ON mc.miner_id = u.user_id
-		WHERE u.supervisor_id = $1 
-		AND mc.completed_at >= DATE_TRUNC('month', NOW())
-	`, supervisorID).Scan(&monthlyCompletions)
-	stats["monthly_completions"] = monthlyCompletions
+		SELECT COALESCE(ROUND(AVG(checklist_pct), 1), 0), COALESCE(ROUND(AVG(ppe_pct), 1), 0)
+		FROM daily_compliance_summary
+		WHERE supervisor_id = $1 AND date > $2::date - 7 AND on_leave = false
+	`, supervisorID, today).Scan(&checklistPct, &ppePct)
 
-	// Average score
-	var avgScore float64
+	var onLeave int
 	database.DB.QueryRow(`
-		SELECT COALESCE(AVG(CAST(mc.score AS FLOAT) / CAST(mc.total_questions AS FLOAT) * 100), 0)
-		FROM module_completions mc
-		JOIN users u ON mc.miner_id = u.user_id
-		WHERE u.supervisor_id = $1
-	`, supervisorID).Scan(&avgScore)
-	stats["average_score"] = avgScore
+		SELECT COUNT(DISTINCT l.user_id) FROM miner_leave l
+		JOIN users u ON u.user_id = l.user_id
+		WHERE u.supervisor_id = $1 AND u.deleted_at IS NULL AND $2::date BETWEEN l.start_date AND l.end_date
+	`, supervisorID, today).Scan(&onLeave)
 
-	// Today's star video completion count
-	var todayCompletions int
+	// Machines that had their pre-use checks done today
+	var equipmentChecked int
 	database.DB.QueryRow(`
-		SELECT COUNT(DISTINCT mc.miner_id)
-		FROM module_completions mc
-		JOIN star_videos sv ON mc.video_id = sv.video_id
-		JOIN users u ON mc.miner_id = u.user_id
-		WHERE u.supervisor_id = $1 
-		AND sv.supervisor_id = $1
-		AND sv.set_date = CURRENT_DATE
-		AND sv.is_active = true
-		AND mc.completed_at::date = CURRENT_DATE
-	`, supervisorID).Scan(&todayCompletions)
-	stats["today_completions"] = todayCompletions
-
-	respondWithJSON(w, http.StatusOK, stats)
+		SELECT COUNT(DISTINCT c.equipment_id)
+		FROM equipment_checklist_completions c
+		JOIN users u ON u.user_id = c.user_id
+		WHERE u.supervisor_id = $1 AND c.date = $2 AND c.is_completed = true
+	`, supervisorID, today).Scan(&equipmentChecked)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"total_miners":        totalMiners,
+		"total_operators":     totalOperators,
+		"on_leave_today":      onLeave,
+		"equipment_checked":   equipmentChecked,
+		"active_miners":       activeMiners,
+		"total_modules":       totalModules,
+		"monthly_completions": monthlyCompletions,
+		"average_score":       avgScore,
+		"today_completions":   todayCompletions,
+		"checklist_pct_7d":    checklistPct,
+		"ppe_pct_7d":          ppePct,
+	})
 }
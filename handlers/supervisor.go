@@ -6,8 +6,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/mail"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -103,7 +108,9 @@ func ReviewModule(w http.ResponseWriter, r *http.Request) {
 
 	// Check if module exists
 	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1)", moduleID).Scan(&exists)
+	args := []interface{}{moduleID}
+	scope := tenantClause(r, "org_id", &args)
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1"+scope+")", args...).Scan(&exists)
 	if err != nil || !exists {
 		respondWithError(w, http.StatusNotFound, "Module not found")
 		return
@@ -192,11 +199,14 @@ func GetUploadedModules(w http.ResponseWriter, r *http.Request) {
 
 // Zone represents a mine zone/department
 type Zone struct {
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
-	Location     string `json:"location"`
-	Capacity     int    `json:"capacity"`
-	CurrentCount int    `json:"currentCount"`
+	ID                int      `json:"id"`
+	Name              string   `json:"name"`
+	Location          string   `json:"location"`
+	Capacity          int      `json:"capacity"`
+	CurrentCount      int      `json:"currentCount"`
+	RequiredDocuments []string `json:"requiredDocuments"`
+	// Area the zone covers, used to place emergencies in it
+	Geometry *ZoneGeometry `json:"geometry,omitempty"`
 }
 
 // GetZones - Get list of available mine zones/departments
@@ -219,15 +229,18 @@ func GetZones(w http.ResponseWriter, r *http.Request) {
 	// Get zones for this mining site
 	query := `
 		SELECT z.id, z.name, z.location, z.capacity,
-		       (SELECT COUNT(*) FROM users u WHERE u.zone_id = z.id) as current_count
+		       (SELECT COUNT(*) FROM users u WHERE u.zone_id = z.id AND u.deleted_at IS NULL) as current_count,
+		       COALESCE(z.required_documents, '[]'::jsonb),
+		       z.boundary, z.center_latitude, z.center_longitude, z.radius_meters
 		FROM mine_zones z
 		WHERE z.is_active = true
 	`
 	args := []interface{}{}
+	query += tenantClause(r, "z.org_id", &args)
 
 	if miningSite.Valid && miningSite.String != "" {
-		query += " AND z.mining_site = $1"
 		args = append(args, miningSite.String)
+		query += fmt.Sprintf(" AND z.mining_site = $%d", len(args))
 	}
 
 	query += " ORDER BY z.name ASC"
@@ -242,11 +255,16 @@ func GetZones(w http.ResponseWriter, r *http.Request) {
 	zones := []Zone{}
 	for rows.Next() {
 		var zone Zone
-		err := rows.Scan(&zone.ID, &zone.Name, &zone.Location, &zone.Capacity, &zone.CurrentCount)
+		var requiredJSON, boundaryJSON []byte
+		var centerLat, centerLon, radius sql.NullFloat64
+		err := rows.Scan(&zone.ID, &zone.Name, &zone.Location, &zone.Capacity, &zone.CurrentCount, &requiredJSON,
+			&boundaryJSON, &centerLat, &centerLon, &radius)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
 			return
 		}
+		zone.RequiredDocuments = parseRequiredDocuments(requiredJSON)
+		zone.Geometry = scanZoneGeometry(boundaryJSON, centerLat, centerLon, radius)
 		zones = append(zones, zone)
 	}
 
@@ -255,12 +273,30 @@ func GetZones(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// supervisorZone returns the name of an active zone among those GetZones lists for the
+// supervisor, or sql.ErrNoRows
+func supervisorZone(r *http.Request, supervisorID string, zoneID int) (string, error) {
+	var miningSite sql.NullString
+	database.DB.QueryRow("SELECT mining_site FROM users WHERE user_id = $1", supervisorID).Scan(&miningSite)
+	args := []interface{}{zoneID}
+	query := "SELECT name FROM mine_zones WHERE id = $1 AND is_active = true" + tenantClause(r, "org_id", &args)
+	if miningSite.Valid && miningSite.String != "" {
+		args = append(args, miningSite.String)
+		query += fmt.Sprintf(" AND mining_site = $%d", len(args))
+	}
+	var name string
+	err := database.DB.QueryRow(query, args...).Scan(&name)
+	return name, err
+}
+
 // CreateZoneRequest represents the request body for creating a zone
 type CreateZoneRequest struct {
 	Name       string `json:"name"`
 	Location   string `json:"location"`
 	Capacity   int    `json:"capacity"`
 	MiningSite string `json:"mining_site"`
+	// Document types (LICENSE, MEDICAL_CERTIFICATE, BLASTING_TICKET) a miner must hold unexpired
+	RequiredDocuments []string `json:"required_documents"`
 }
 
 // CreateZone - Create a new mine zone
@@ -290,18 +326,33 @@ func CreateZone(w http.ResponseWriter, r *http.Request) {
 		if miningSite.Valid {
 			req.MiningSite = miningSite.String
 		}
+	} else if ok, err := siteInOrganization(r, req.MiningSite); err != nil || !ok {
+		respondWithError(w, http.StatusBadRequest, "mining_site must be one of your organization's sites")
+		return
 	}
 
 	if req.Capacity == 0 {
 		req.Capacity = 50 // Default capacity
 	}
 
+	for i, docType := range req.RequiredDocuments {
+		req.RequiredDocuments[i] = strings.ToUpper(docType)
+		if !validDocumentTypes[req.RequiredDocuments[i]] {
+			respondWithError(w, http.StatusBadRequest, "Unknown document type: "+docType)
+			return
+		}
+	}
+	if req.RequiredDocuments == nil {
+		req.RequiredDocuments = []string{}
+	}
+	requiredJSON, _ := json.Marshal(req.RequiredDocuments)
+
 	var zoneID int
 	err := database.DB.QueryRow(`
-		INSERT INTO mine_zones (name, location, capacity, mining_site, created_by, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO mine_zones (name, location, capacity, mining_site, required_documents, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
-	`, req.Name, req.Location, req.Capacity, req.MiningSite, supervisorID, time.Now(), time.Now()).Scan(&zoneID)
+	`, req.Name, req.Location, req.Capacity, req.MiningSite, requiredJSON, supervisorID, time.Now(), time.Now()).Scan(&zoneID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating zone: "+err.Error())
 		return
@@ -342,7 +393,7 @@ func AllocateMinerToZone(w http.ResponseWriter, r *http.Request) {
 
 	// Verify miner belongs to this supervisor
 	var minerSupervisor sql.NullString
-	err := database.DB.QueryRow("SELECT supervisor_id FROM users WHERE user_id = $1 AND role = 'MINER'", req.MinerID).Scan(&minerSupervisor)
+	err := database.DB.QueryRow("SELECT supervisor_id FROM users WHERE user_id = $1 AND role = 'MINER' AND deleted_at IS NULL", req.MinerID).Scan(&minerSupervisor)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Miner not found")
 		return
@@ -357,13 +408,27 @@ func AllocateMinerToZone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New miners must finish induction before they can work in a zone
+	inducted, err := minerInductionComplete(req.MinerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !inducted {
+		respondWithError(w, http.StatusConflict, "Miner has not completed induction")
+		return
+	}
+
 	// Verify zone exists and check capacity
 	zoneIDInt, _ := strconv.Atoi(req.ZoneID)
 	var zoneCapacity, currentCount int
+	var requiredJSON []byte
+	args := []interface{}{zoneIDInt}
+	scope := tenantClause(r, "z.org_id", &args)
 	err = database.DB.QueryRow(`
-		SELECT z.capacity, (SELECT COUNT(*) FROM users WHERE zone_id = z.id) 
-		FROM mine_zones z WHERE z.id = $1 AND z.is_active = true
-	`, zoneIDInt).Scan(&zoneCapacity, &currentCount)
+		SELECT z.capacity, (SELECT COUNT(*) FROM users WHERE zone_id = z.id AND deleted_at IS NULL),
+		       COALESCE(z.required_documents, '[]'::jsonb)
+		FROM mine_zones z WHERE z.id = $1 AND z.is_active = true`+scope, args...).Scan(&zoneCapacity, &currentCount, &requiredJSON)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Zone not found")
 		return
@@ -378,6 +443,29 @@ func AllocateMinerToZone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Nobody is sent into a zone being cleared for a blast
+	if locked, err := zoneBlastLocked(zoneIDInt); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if locked {
+		respondWithError(w, http.StatusConflict, "Zone is locked for a blast clearance")
+		return
+	}
+
+	// Zones can require licenses or certificates that must not be expired
+	missingDocs, err := missingZoneDocuments(req.MinerID, parseRequiredDocuments(requiredJSON))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(missingDocs) > 0 {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":             "Miner is missing valid documents required for this zone",
+			"missing_documents": missingDocs,
+		})
+		return
+	}
+
 	// Update miner's zone
 	_, err = database.DB.Exec("UPDATE users SET zone_id = $1, updated_at = $2 WHERE user_id = $3", zoneIDInt, time.Now(), req.MinerID)
 	if err != nil {
@@ -385,20 +473,33 @@ func AllocateMinerToZone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"success": true,
 		"message": "Miner assigned to zone successfully",
-	})
+	}
+	// Allocation still goes ahead, e.g. when planning the roster ahead of their return
+	if until, err := minerLeaveOn(req.MinerID, userToday(supervisorID)); err == nil && until != "" {
+		response["warning"] = "Miner is on leave until " + until
+		response["on_leave_until"] = until
+	}
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 // ==================== EMERGENCY REPORT MANAGEMENT ====================
 
-// DownloadEmergencyReport - Download emergency report as PDF
+// DownloadEmergencyReport - Download emergency report as PDF. ?redact=reporter,medical
+// leaves out the reporter's identity and injury details.
 // GET /api/supervisor/emergencies/{id}/download
 func DownloadEmergencyReport(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	emergencyID := vars["id"]
 
+	redaction, err := reportRedaction(r, strings.Split(r.URL.Query().Get("redact"), ","))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Fetch emergency details
 	var emergency struct {
 		ID            int
@@ -412,18 +513,24 @@ func DownloadEmergencyReport(w http.ResponseWriter, r *http.Request) {
 		ReportingTime time.Time
 		Status        string
 		UserName      string
+		OrgID         int
+		Category      string
+		Details       []byte
 	}
 
-	err := database.DB.QueryRow(`
+	args := []interface{}{emergencyID}
+	scope := tenantClause(r, "e.org_id", &args)
+	err = database.DB.QueryRow(`
 		SELECT e.id, e.user_id, e.emergency_id, e.severity, e.latitude, e.longitude, 
-		       e.issue, e.location, e.reporting_time, e.status, u.name
+		       e.issue, e.location, e.reporting_time, e.status, u.name, COALESCE(e.org_id, 0),
+		       COALESCE(e.category, ''), e.details
 		FROM emergencies e
 		JOIN users u ON e.user_id = u.user_id
-		WHERE e.id = $1
-	`, emergencyID).Scan(
+		WHERE e.id = $1`+scope, args...).Scan(
 		&emergency.ID, &emergency.UserID, &emergency.EmergencyID, &emergency.Severity,
 		&emergency.Latitude, &emergency.Longitude, &emergency.Issue, &emergency.Location,
-		&emergency.ReportingTime, &emergency.Status, &emergency.UserName,
+		&emergency.ReportingTime, &emergency.Status, &emergency.UserName, &emergency.OrgID,
+		&emergency.Category, &emergency.Details,
 	)
 
 	if err == sql.ErrNoRows {
@@ -442,22 +549,30 @@ func DownloadEmergencyReport(w http.ResponseWriter, r *http.Request) {
 		location = *emergency.Location
 	}
 
+	// Printed times are in the viewer's timezone
+	viewerID, _ := middleware.GetUserIDFromContext(r.Context())
+	loc := userLocation(viewerID)
+
 	reportData := map[string]interface{}{
 		"reportTitle": fmt.Sprintf("Emergency Report #%d", emergency.ID),
 		"emergencyId": emergency.EmergencyID,
-		"reportedBy":  emergency.UserName,
-		"reportedAt":  emergency.ReportingTime.Format("2006-01-02 15:04:05"),
+		"reportedBy":  redaction.reporter(emergency.UserName),
+		"reportedAt":  emergency.ReportingTime.In(loc).Format("2006-01-02 15:04:05 MST"),
 		"severity":    emergency.Severity,
 		"status":      emergency.Status,
 		"location":    location,
 		"coordinates": fmt.Sprintf("%.6f, %.6f", emergency.Latitude, emergency.Longitude),
 		"issue":       emergency.Issue,
-		"generatedAt": time.Now().Format("2006-01-02 15:04:05"),
+		"category":    emergency.Category,
+		"details":     redaction.details(emergency.Category, emergency.Details),
+		"generatedAt": time.Now().In(loc).Format("2006-01-02 15:04:05 MST"),
+		"branding":    brandingForOrg(emergency.OrgID).reportData(),
 	}
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"success":     true,
 		"reportData":  reportData,
+		"redacted":    redaction.applied(),
 		"downloadUrl": fmt.Sprintf("/api/supervisor/emergencies/%s/pdf", emergencyID),
 	})
 }
@@ -466,6 +581,8 @@ func DownloadEmergencyReport(w http.ResponseWriter, r *http.Request) {
 type ForwardEmergencyRequest struct {
 	Recipients []string `json:"recipients"`
 	Message    string   `json:"message"`
+	// Parts of the report to leave out: reporter, medical
+	Redact []string `json:"redact"`
 }
 
 // ForwardEmergencyReport - Forward report to higher authorities
@@ -490,28 +607,78 @@ func ForwardEmergencyReport(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "At least one recipient is required")
 		return
 	}
+	redaction, err := reportRedaction(r, req.Redact)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Verify emergency exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM emergencies WHERE id = $1)", emergencyID).Scan(&exists)
-	if err != nil || !exists {
+	var severity, issue, status, reporterName, location, category string
+	var reportedAt time.Time
+	var orgID int
+	var detailsJSON []byte
+	args := []interface{}{emergencyID}
+	scope := tenantClause(r, "e.org_id", &args)
+	err = database.DB.QueryRow(`
+		SELECT e.severity, e.issue, e.status, u.name, COALESCE(e.location, 'Not Available'), e.reporting_time,
+		       COALESCE(e.org_id, 0), COALESCE(e.category, ''), e.details
+		FROM emergencies e
+		JOIN users u ON e.user_id = u.user_id
+		WHERE e.id = $1`+scope, args...).Scan(&severity, &issue, &status, &reporterName, &location, &reportedAt, &orgID,
+		&category, &detailsJSON)
+	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Emergency not found")
 		return
 	}
 
-	// Log the forward action (in production, you'd send actual emails)
+	// Log the forward action
+	redactedJSON, _ := json.Marshal(redaction.applied())
 	_, err = database.DB.Exec(`
-		INSERT INTO emergency_forwards (emergency_id, forwarded_by, recipients, message, forwarded_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, emergencyID, supervisorID, fmt.Sprintf("%v", req.Recipients), req.Message, time.Now())
-
-	// If table doesn't exist, we still return success (email sending is simulated)
-	// In production, integrate with email service (SendGrid, SES, etc.)
+		INSERT INTO emergency_forwards (emergency_id, forwarded_by, recipients, message, forwarded_at, redacted)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, emergencyID, supervisorID, fmt.Sprintf("%v", req.Recipients), req.Message, time.Now(), redactedJSON)
+
+	// If table doesn't exist, we still return success
+
+	// Recipients given as email addresses get the report in the organization's name
+	branding := brandingForOrg(orgID)
+	subject := fmt.Sprintf("%s emergency report #%s", branding.Name, emergencyID)
+	body := fmt.Sprintf("Emergency report #%s\n\nReported by: %s\nReported at: %s\nSeverity: %s\nStatus: %s\nLocation: %s\n",
+		emergencyID, redaction.reporter(reporterName), reportedAt.In(userLocation(supervisorID)).Format("2006-01-02 15:04:05 MST"),
+		severity, status, location)
+	details := redaction.details(category, detailsJSON)
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		body += fmt.Sprintf("%s: %v\n", key, details[key])
+	}
+	body += "\n" + issue + "\n"
+	if req.Message != "" {
+		body = req.Message + "\n\n" + body
+	}
+	emailed := 0
+	for _, recipient := range req.Recipients {
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil {
+			continue
+		}
+		if err := sendBrandedEmail(branding, addr.Address, subject, body); err != nil {
+			log.Printf("Warning: failed to email emergency report %s: %v", emergencyID, err)
+			continue
+		}
+		emailed++
+	}
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"success":    true,
 		"message":    fmt.Sprintf("Emergency report forwarded to %d recipient(s)", len(req.Recipients)),
 		"recipients": req.Recipients,
+		"emailed":    emailed,
+		"redacted":   redaction.applied(),
 	})
 }
 
@@ -525,7 +692,9 @@ type SupervisorMiner struct {
 	Phone          string  `json:"phone"`
 	Zone           *string `json:"zone"`
 	Status         string  `json:"status"`
+	Role           string  `json:"role"`
 	ProfilePicture *string `json:"profilePicture,omitempty"`
+	OnLeaveUntil   *string `json:"onLeaveUntil,omitempty"`
 }
 
 // GetSupervisorMiners - Get all miners assigned to this supervisor with zone info
@@ -541,12 +710,14 @@ func GetSupervisorMiners(w http.ResponseWriter, r *http.Request) {
 		SELECT u.user_id, u.name, u.user_id as miner_id, COALESCE(u.phone, ''), 
 		       z.name as zone_name, 
 		       CASE WHEN u.is_active THEN 'active' ELSE 'inactive' END as status,
-		       u.profile_picture_url
+		       u.profile_picture_url, u.role,
+		       (SELECT TO_CHAR(MAX(l.end_date), 'YYYY-MM-DD') FROM miner_leave l
+		        WHERE l.user_id = u.user_id AND $3::date BETWEEN l.start_date AND l.end_date)
 		FROM users u
 		LEFT JOIN mine_zones z ON u.zone_id = z.id
-		WHERE u.supervisor_id = $1 AND u.role = 'MINER'
+		WHERE u.supervisor_id = ANY($1) AND u.role = ANY($2) AND u.deleted_at IS NULL
 		ORDER BY u.name ASC
-	`, supervisorID)
+	`, managedSupervisors(supervisorID), crewRoles(), userToday(supervisorID))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
@@ -556,8 +727,8 @@ func GetSupervisorMiners(w http.ResponseWriter, r *http.Request) {
 	miners := []SupervisorMiner{}
 	for rows.Next() {
 		var miner SupervisorMiner
-		var zoneName, profilePic sql.NullString
-		err := rows.Scan(&miner.ID, &miner.Name, &miner.MinerID, &miner.Phone, &zoneName, &miner.Status, &profilePic)
+		var zoneName, profilePic, onLeaveUntil sql.NullString
+		err := rows.Scan(&miner.ID, &miner.Name, &miner.MinerID, &miner.Phone, &zoneName, &miner.Status, &profilePic, &miner.Role, &onLeaveUntil)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
 			return
@@ -566,7 +737,10 @@ func GetSupervisorMiners(w http.ResponseWriter, r *http.Request) {
 			miner.Zone = &zoneName.String
 		}
 		if profilePic.Valid {
-			miner.ProfilePicture = &profilePic.String
+			miner.ProfilePicture = signUploadURLPtr(&profilePic.String)
+		}
+		if onLeaveUntil.Valid {
+			miner.OnLeaveUntil = &onLeaveUntil.String
 		}
 		miners = append(miners, miner)
 	}
@@ -632,12 +806,27 @@ func SubmitPPEStat(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	if deviceDeactivated(r, userID) {
+		respondWithError(w, http.StatusForbidden, "This device has been deactivated")
+		return
+	}
 
-	var req PPEStatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	// A retried or replayed submission gets the original result instead of overwriting today's
+	idem, proceed := beginIdempotent(w, r, userID, SubmissionTypePPEStat, body)
+	if !proceed {
+		return
+	}
+
+	var req PPEStatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		idem.respondError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
 
 	// Use authenticated user ID if not provided
 	if req.MinerID == "" {
@@ -649,6 +838,11 @@ func SubmitPPEStat(w http.ResponseWriter, r *http.Request) {
 		database.DB.QueryRow("SELECT name FROM users WHERE user_id = $1", userID).Scan(&req.MinerName)
 	}
 
+	// AI PPE detection is rolled out per site; results from users outside the rollout are ignored
+	if !featureEnabled(r.Context(), FlagAIPPEVerification, true) {
+		req.AIVerification = nil
+	}
+
 	// Extract AI verification values (default to "no" if not provided)
 	getAIValue := func(key string) string {
 		if req.AIVerification != nil {
@@ -663,11 +857,11 @@ func SubmitPPEStat(w http.ResponseWriter, r *http.Request) {
 	manualChecklistJSON, _ := json.Marshal(req.ManualChecklist)
 	aiVerificationJSON, _ := json.Marshal(req.AIVerification)
 
-	today := time.Now().Format("2006-01-02")
+	today := userToday(userID)
 
 	// Upsert - insert or update if exists for same user and date
 	var statID int
-	err := database.DB.QueryRow(`
+	err = database.DB.QueryRow(`
 		INSERT INTO ppe_stats (
 			user_id, miner_name, date,
 			safety_helmet, protective_gloves, safety_shoes, high_visibility_vest,
@@ -706,20 +900,35 @@ func SubmitPPEStat(w http.ResponseWriter, r *http.Request) {
 	).Scan(&statID)
 
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error saving PPE stats: "+err.Error())
+		idem.respondError(w, http.StatusInternalServerError, "Error saving PPE stats: "+err.Error())
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
-		"success": true,
-		"message": "PPE verification submitted successfully",
-		"stat_id": statID,
-		"date":    today,
+	recordSubmissionAttestation(r, userID, SubmissionTypePPEStat, statID)
+
+	// Items the site has made mandatory are reported back so the app can stop the miner
+	missing := missingMandatoryPPE(userSiteSettings(userID).PPEMandatoryItems, req.AIVerification, req.ManualChecklist)
+	if len(missing) > 0 {
+		go publishLiveAlert(userID, LiveAlertPPENoncompliance, map[string]interface{}{
+			"stat_id":           statID,
+			"user_id":           userID,
+			"miner_name":        req.MinerName,
+			"date":              today,
+			"mandatory_missing": missing,
+		})
+	}
+
+	idem.respond(w, http.StatusCreated, map[string]interface{}{
+		"success":           true,
+		"message":           "PPE verification submitted successfully",
+		"stat_id":           statID,
+		"date":              today,
+		"mandatory_missing": missing,
 	})
 }
 
 // GetPPEStats - Get PPE statistics for supervisor's miners
-// GET /api/supervisor/ppestats?date=2025-01-01&miner_id=MIN-xxx
+// GET /api/supervisor/ppestats?date=2025-01-01&miner_id=MIN-xxx&limit=200
 func GetPPEStats(w http.ResponseWriter, r *http.Request) {
 	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -739,10 +948,9 @@ func GetPPEStats(w http.ResponseWriter, r *http.Request) {
 			   ps.manual_checklist, ps.ai_verification, ps.photo_captured,
 			   ps.completion_percentage, ps.items_detected, ps.total_items, ps.created_at
 		FROM ppe_stats ps
-		JOIN users u ON ps.user_id = u.user_id
-		WHERE u.supervisor_id = $1
+		WHERE ps.user_id IN (SELECT user_id FROM users WHERE supervisor_id = ANY($1))
 	`
-	args := []interface{}{supervisorID}
+	args := []interface{}{managedSupervisors(supervisorID)}
 	argCount := 2
 
 	if dateFilter != "" {
@@ -759,6 +967,12 @@ func GetPPEStats(w http.ResponseWriter, r *http.Request) {
 
 	query += " ORDER BY ps.date DESC, ps.created_at DESC"
 
+	// Without a date or miner the history grows without bound; limit lets the dashboard page it
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argCount)
+		args = append(args, limit)
+	}
+
 	rows, err := database.DB.Query(query, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ==================== DELTA SYNC ====================
+
+// SyncResponse is returned by list endpoints when called with ?updated_since=. Items holds
+// rows created or changed since then, Deleted the IDs of rows removed since then, and
+// ServerTime is the value to send as updated_since on the next sync.
+type SyncResponse struct {
+	Items      interface{} `json:"items"`
+	Deleted    interface{} `json:"deleted"`
+	ServerTime time.Time   `json:"server_time"`
+}
+
+// parseUpdatedSince reads the optional updated_since query parameter (RFC3339)
+func parseUpdatedSince(r *http.Request) (*time.Time, error) {
+	value := r.URL.Query().Get("updated_since")
+	if value == "" {
+		return nil, nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("updated_since must be an RFC3339 timestamp")
+	}
+	return &since, nil
+}
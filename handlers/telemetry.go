@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ==================== CLIENT TELEMETRY ====================
+
+// Client event types accepted for product analytics
+const (
+	TelemetryScreenView     = "screen_view"
+	TelemetryVideoBuffering = "video_buffering"
+	TelemetrySyncFailure    = "sync_failure"
+)
+
+var telemetryEventTypes = map[string]bool{
+	TelemetryScreenView:     true,
+	TelemetryVideoBuffering: true,
+	TelemetrySyncFailure:    true,
+}
+
+// maxTelemetryEvents bounds one upload; the app queues the rest for its next flush
+const maxTelemetryEvents = 200
+
+// Properties that could identify a miner are dropped before storing
+var telemetryPIIKeys = []string{"email", "phone", "name", "password", "token", "address", "latitude", "longitude", "lat", "lng"}
+
+var (
+	telemetryEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	telemetryPhonePattern = regexp.MustCompile(`\+?\d[\d\s-]{7,}\d`)
+)
+
+// TelemetryEvent is one client-side event
+type TelemetryEvent struct {
+	Type       string                 `json:"type"`
+	Screen     string                 `json:"screen,omitempty"`
+	SessionID  string                 `json:"session_id,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	OccurredAt *time.Time             `json:"occurred_at,omitempty"`
+}
+
+// TelemetryRequest represents the request body for a batch of client events
+type TelemetryRequest struct {
+	Events []TelemetryEvent `json:"events"`
+}
+
+// telemetrySampled reports whether a user's events are kept. Sampling is per user rather
+// than per event so a sampled miner's whole training flow is recorded.
+func telemetrySampled(userID string) bool {
+	percent := config.Get().TelemetrySamplePercent
+	if percent >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte("telemetry:" + userID))
+	return int(h.Sum32()%100) < percent
+}
+
+// scrubTelemetryProperties removes identifying keys and masks emails and phone numbers
+// left in free-text values
+func scrubTelemetryProperties(props map[string]interface{}) map[string]interface{} {
+	clean := map[string]interface{}{}
+	for key, value := range props {
+		lower := strings.ToLower(key)
+		drop := false
+		for _, pii := range telemetryPIIKeys {
+			if lower == pii || strings.HasSuffix(lower, "_"+pii) {
+				drop = true
+				break
+			}
+		}
+		if drop {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			v = telemetryEmailPattern.ReplaceAllString(v, "[email]")
+			clean[key] = telemetryPhonePattern.ReplaceAllString(v, "[phone]")
+		case map[string]interface{}:
+			clean[key] = scrubTelemetryProperties(v)
+		default:
+			clean[key] = v
+		}
+	}
+	return clean
+}
+
+// IngestTelemetry - Batched client events (screen views, buffering stalls, sync failures)
+// POST /api/app/telemetry
+func IngestTelemetry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TelemetryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.Events) > maxTelemetryEvents {
+		respondWithError(w, http.StatusBadRequest, "Too many events in one batch")
+		return
+	}
+
+	// Unsampled users get the same response so the app simply clears its queue
+	if !telemetrySampled(userID) {
+		respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+			"accepted": 0,
+			"dropped":  len(req.Events),
+			"sampled":  false,
+		})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO client_events (user_id, event_type, screen, session_id, properties, app_version, platform, occurred_at, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	accepted := 0
+	for _, event := range req.Events {
+		if !telemetryEventTypes[event.Type] {
+			continue
+		}
+		occurredAt := now
+		if event.OccurredAt != nil && event.OccurredAt.Before(now.Add(5*time.Minute)) {
+			occurredAt = *event.OccurredAt
+		}
+		propsJSON, _ := json.Marshal(scrubTelemetryProperties(event.Properties))
+		if _, err := stmt.Exec(userID, event.Type, event.Screen, event.SessionID, propsJSON,
+			r.Header.Get(headerAppVersion), r.Header.Get(headerPlatform), occurredAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error storing events: "+err.Error())
+			return
+		}
+		accepted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing events")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"accepted": accepted,
+		"dropped":  len(req.Events) - accepted,
+		"sampled":  true,
+	})
+}
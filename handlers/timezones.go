@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"time"
+)
+
+// ==================== TIMEZONES ====================
+
+// Timestamps are stored as TIMESTAMPTZ and returned as RFC 3339 instants. Anything that
+// depends on the calendar day (today's checklist, daily quiz limits, streaks, report
+// headers) is worked out in the user's timezone: their own setting, then their site's
+// setting, then the timezone of their site's shift windows, then DEFAULT_TIMEZONE.
+
+// userLocation resolves the timezone a user's days are counted in
+func userLocation(userID string) *time.Location {
+	var tz string
+	database.DB.QueryRow(`
+		SELECT COALESCE(NULLIF(u.timezone, ''),
+			(SELECT NULLIF(st.value->>'timezone', '') FROM system_settings st WHERE st.key = 'site_settings:' || u.site_id),
+			(SELECT sw.timezone FROM shift_windows sw WHERE sw.mining_site = u.mining_site ORDER BY sw.id LIMIT 1),
+			'')
+		FROM users u WHERE u.user_id = $1
+	`, userID).Scan(&tz)
+	if tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return defaultLocation()
+}
+
+// defaultLocation is DEFAULT_TIMEZONE, validated when the config is loaded
+func defaultLocation() *time.Location {
+	loc, err := time.LoadLocation(config.Get().DefaultTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// userToday is the current date in the user's timezone as YYYY-MM-DD
+func userToday(userID string) string {
+	return time.Now().In(userLocation(userID)).Format("2006-01-02")
+}
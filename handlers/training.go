@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // ==================== QUIZ RESPONSES ====================
@@ -66,18 +67,18 @@ func GetQuizByTitle(w http.ResponseWriter, r *http.Request) {
 		SELECT q.id, q.title, vm.title as video_title, COALESCE(q.tags, '[]'::jsonb) as tags
 		FROM quizzes q
 		JOIN video_modules vm ON q.video_id = vm.id
-		WHERE LOWER(vm.title) LIKE LOWER($1)
+		WHERE LOWER(vm.title) LIKE LOWER($1) AND (vm.org_id IS NULL OR vm.org_id = $2)
 		LIMIT 1
-	`, "%"+title+"%").Scan(&quizID, &quizTitle, &videoTitle, &tagsJSON)
+	`, "%"+title+"%", requestOrg(r)).Scan(&quizID, &quizTitle, &videoTitle, &tagsJSON)
 
 	if err == sql.ErrNoRows {
 		// Try to find questions directly linked to video_modules (legacy support)
 		var videoID int
 		err = database.DB.QueryRow(`
 			SELECT id, title FROM video_modules 
-			WHERE LOWER(title) LIKE LOWER($1) AND is_active = true
+			WHERE LOWER(title) LIKE LOWER($1) AND is_active = true AND (org_id IS NULL OR org_id = $2)
 			LIMIT 1
-		`, "%"+title+"%").Scan(&videoID, &videoTitle)
+		`, "%"+title+"%", requestOrg(r)).Scan(&videoID, &videoTitle)
 
 		if err == sql.ErrNoRows {
 			respondWithError(w, http.StatusNotFound, "No quiz found for the given video title")
@@ -174,7 +175,9 @@ func GetQuizByTitle(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetQuizList - GET /api/training/quizzes
+// GetQuizList - GET /api/training/quizzes?updated_since=2024-01-15T06:00:00Z
+// With updated_since, only quizzes whose video, questions or the user's results changed
+// since then are returned, along with the IDs of quizzes whose video was deactivated
 func GetQuizList(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -182,6 +185,29 @@ func GetQuizList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	since, err := parseUpdatedSince(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	serverTime := time.Now()
+
+	args := []interface{}{userID}
+	quizFilter := "vm.is_active = true"
+	legacyFilter := "vm.is_active = true"
+	if since != nil {
+		args = append(args, *since)
+		quizFilter = `(q.updated_at > $2 OR vm.updated_at > $2
+			OR EXISTS(SELECT 1 FROM quiz_questions WHERE quiz_id = q.id AND created_at > $2)
+			OR EXISTS(SELECT 1 FROM quiz_completions WHERE quiz_id = q.id AND user_id = $1 AND completed_at > $2))`
+		legacyFilter = `(vm.updated_at > $2
+			OR EXISTS(SELECT 1 FROM module_completions WHERE video_id = vm.id AND miner_id = $1 AND completed_at > $2))`
+	}
+	tenant := sharedTenantClause(r, "vm.org_id", &args)
+	quizFilter += tenant
+	legacyFilter += tenant
+	deleted := []string{}
+
 	// Get all quizzes with completion status
 	rows, err := database.DB.Query(`
 		SELECT 
@@ -189,12 +215,13 @@ func GetQuizList(w http.ResponseWriter, r *http.Request) {
 			COALESCE(q.tags, '[]'::jsonb) as tags,
 			(SELECT COUNT(*) FROM quiz_questions WHERE quiz_id = q.id) as num_questions,
 			EXISTS(SELECT 1 FROM quiz_completions WHERE quiz_id = q.id AND user_id = $1) as completed,
-			(SELECT MAX(score) FROM quiz_completions WHERE quiz_id = q.id AND user_id = $1) as best_score
+			(SELECT MAX(score) FROM quiz_completions WHERE quiz_id = q.id AND user_id = $1) as best_score,
+			COALESCE(vm.is_active, false)
 		FROM quizzes q
 		JOIN video_modules vm ON q.video_id = vm.id
-		WHERE vm.is_active = true
+		WHERE `+quizFilter+`
 		ORDER BY q.created_at DESC
-	`, userID)
+	`, args...)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -208,13 +235,18 @@ func GetQuizList(w http.ResponseWriter, r *http.Request) {
 		var tagsJSON []byte
 		var idInt int
 		var bestScore sql.NullInt64
+		var isActive bool
 
-		err := rows.Scan(&idInt, &quiz.Title, &quiz.VideoTitle, &tagsJSON, &quiz.NumQuestions, &quiz.Completed, &bestScore)
+		err := rows.Scan(&idInt, &quiz.Title, &quiz.VideoTitle, &tagsJSON, &quiz.NumQuestions, &quiz.Completed, &bestScore, &isActive)
 		if err != nil {
 			continue
 		}
 
 		quiz.ID = strconv.Itoa(idInt)
+		if !isActive {
+			deleted = append(deleted, quiz.ID)
+			continue
+		}
 		json.Unmarshal(tagsJSON, &quiz.Tags)
 		if quiz.Tags == nil {
 			quiz.Tags = []string{}
@@ -234,14 +266,15 @@ func GetQuizList(w http.ResponseWriter, r *http.Request) {
 			vm.id, vm.title,
 			COALESCE(vm.tags, '[]'::jsonb) as tags,
 			(SELECT COUNT(*) FROM questions WHERE video_id = vm.id) as num_questions,
-			EXISTS(SELECT 1 FROM module_completions WHERE video_id = vm.id AND miner_id = $1) as completed,
-			(SELECT MAX(score) FROM module_completions WHERE video_id = vm.id AND miner_id = $1) as best_score
+			EXISTS(SELECT 1 FROM module_completions WHERE video_id = vm.id AND miner_id = $1 AND passed) as completed,
+			(SELECT MAX(score) FROM module_completions WHERE video_id = vm.id AND miner_id = $1) as best_score,
+			COALESCE(vm.is_active, false), vm.created_at
 		FROM video_modules vm
-		WHERE vm.is_active = true
+		WHERE `+legacyFilter+`
 		AND EXISTS(SELECT 1 FROM questions WHERE video_id = vm.id)
 		AND NOT EXISTS(SELECT 1 FROM quizzes WHERE video_id = vm.id)
 		ORDER BY vm.created_at DESC
-	`, userID)
+	`, args...)
 
 	if err == nil {
 		defer legacyRows.Close()
@@ -250,13 +283,19 @@ func GetQuizList(w http.ResponseWriter, r *http.Request) {
 			var tagsJSON []byte
 			var idInt int
 			var bestScore sql.NullInt64
+			var isActive bool
+			var createdAt sql.NullTime
 
-			err := legacyRows.Scan(&idInt, &quiz.VideoTitle, &tagsJSON, &quiz.NumQuestions, &quiz.Completed, &bestScore)
+			err := legacyRows.Scan(&idInt, &quiz.VideoTitle, &tagsJSON, &quiz.NumQuestions, &quiz.Completed, &bestScore, &isActive, &createdAt)
 			if err != nil {
 				continue
 			}
 
 			quiz.ID = "legacy-" + strconv.Itoa(idInt)
+			if !isActive {
+				deleted = append(deleted, quiz.ID)
+				continue
+			}
 			quiz.Title = "Quiz: " + quiz.VideoTitle
 			json.Unmarshal(tagsJSON, &quiz.Tags)
 			if quiz.Tags == nil {
@@ -272,6 +311,10 @@ func GetQuizList(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if since != nil {
+		respondWithJSON(w, http.StatusOK, SyncResponse{Items: quizzes, Deleted: deleted, ServerTime: serverTime})
+		return
+	}
 	respondWithJSON(w, http.StatusOK, QuizListResponse{
 		Quizzes: quizzes,
 	})
@@ -318,9 +361,9 @@ func GetVideoModulesWithQuizzes(w http.ResponseWriter, r *http.Request) {
 			(SELECT COUNT(*) FROM quiz_questions WHERE quiz_id = q.id) as num_questions
 		FROM video_modules vm
 		LEFT JOIN quizzes q ON q.video_id = vm.id
-		WHERE vm.is_active = true
+		WHERE vm.is_active = true AND (vm.org_id IS NULL OR vm.org_id = $1)
 		ORDER BY vm.id ASC
-	`)
+	`, requestOrg(r))
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -352,6 +395,7 @@ func GetVideoModulesWithQuizzes(w http.ResponseWriter, r *http.Request) {
 
 		module.Row = rowNum
 		rowNum++
+		module.VideoURL = signUploadURL(module.VideoURL)
 
 		json.Unmarshal(videoTagsJSON, &module.VideoTags)
 		if module.VideoTags == nil {
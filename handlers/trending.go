@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ==================== TRENDING VIDEOS ====================
+
+// TrendingVideo is a video with its engagement over the last 7 days
+type TrendingVideo struct {
+	ID             int       `json:"id"`
+	Title          string    `json:"title"`
+	VideoURL       string    `json:"video_url"`
+	ThumbnailURL   string    `json:"thumbnail_url"`
+	Category       string    `json:"category"`
+	Tags           []string  `json:"tags"`
+	Views          int       `json:"views"`
+	Likes          int       `json:"likes"`
+	Dislikes       int       `json:"dislikes"`
+	Completions    int       `json:"completions"`
+	CompletionRate float64   `json:"completion_rate"`
+	QuizPassRate   float64   `json:"quiz_pass_rate"`
+	Score          float64   `json:"score"`
+	RefreshedAt    time.Time `json:"refreshed_at"`
+}
+
+// trendingSorts maps the sort parameter to its ORDER BY. "helpful" favours videos that
+// miners finish and pass the quiz for, with likes breaking ties.
+var trendingSorts = map[string]string{
+	"trending": "t.trending_score DESC, t.likes DESC",
+	"helpful":  "t.pass_rate DESC, t.completion_rate DESC, t.likes - t.dislikes DESC",
+}
+
+// refreshTrendingVideos recomputes the video_trending materialized view
+func refreshTrendingVideos() error {
+	_, err := database.DB.Exec("REFRESH MATERIALIZED VIEW CONCURRENTLY video_trending")
+	return err
+}
+
+// StartTrendingRefreshJob periodically refreshes trending video engagement
+func StartTrendingRefreshJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := refreshTrendingVideos(); err != nil {
+				log.Printf("Warning: failed to refresh trending videos: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// GetTrendingVideos - GET /api/videos/trending?sort=trending|helpful&limit=20
+// Videos ranked by engagement over the last 7 days (views, likes, completions, quiz passes)
+func GetTrendingVideos(w http.ResponseWriter, r *http.Request) {
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "trending"
+	}
+	orderBy, ok := trendingSorts[sort]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "sort must be trending or helpful")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+
+	// Videos nobody engaged with this week are left out rather than padding the list
+	rows, err := database.DB.Query(`
+		SELECT vm.id, vm.title, vm.video_url, COALESCE(vm.thumbnail, ''), COALESCE(vm.category, ''),
+			COALESCE(vm.tags, '[]'::jsonb), t.views, t.likes, t.dislikes, t.completions,
+			t.completion_rate, t.pass_rate, t.trending_score, t.refreshed_at
+		FROM video_trending t
+		JOIN video_modules vm ON vm.id = t.video_id
+		WHERE vm.is_active = true AND (t.views > 0 OR t.likes > 0 OR t.completions > 0)
+		  AND (vm.org_id IS NULL OR vm.org_id = $2)
+		ORDER BY `+orderBy+`
+		LIMIT $1
+	`, limit, requestOrg(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	videos := []TrendingVideo{}
+	for rows.Next() {
+		var v TrendingVideo
+		var tagsJSON []byte
+		err := rows.Scan(&v.ID, &v.Title, &v.VideoURL, &v.ThumbnailURL, &v.Category, &tagsJSON,
+			&v.Views, &v.Likes, &v.Dislikes, &v.Completions, &v.CompletionRate, &v.QuizPassRate, &v.Score, &v.RefreshedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning video: "+err.Error())
+			return
+		}
+		json.Unmarshal(tagsJSON, &v.Tags)
+		if v.Tags == nil {
+			v.Tags = []string{}
+		}
+		videos = append(videos, v)
+	}
+
+	sources := []string{}
+	for _, v := range videos {
+		sources = append(sources, v.VideoURL, v.ThumbnailURL)
+	}
+	media := loadRenditions(w, r, sources)
+	for i := range videos {
+		videos[i].VideoURL = media.sign(videos[i].VideoURL)
+		if videos[i].ThumbnailURL != "" {
+			videos[i].ThumbnailURL = media.sign(videos[i].ThumbnailURL)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"sort":    sort,
+		"window":  "7d",
+		"quality": media.quality,
+		"videos":  videos,
+	})
+}
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== UPLOAD POLICY ====================
+
+// Each organization sets how large and how long uploaded videos may be, which video formats
+// are accepted and whether uploads are virus scanned. Sites can override the size and length
+// limits. Every upload endpoint checks the uploader's policy; scanning covers all uploaded
+// files, not only videos.
+
+// Upload policies are stored in system_settings under these prefixes and the org or site ID
+const (
+	uploadPolicyKeyPrefix = "upload_policy:"
+	uploadLimitsKeyPrefix = "upload_limits:"
+)
+
+const (
+	// maxVideoMBLimit bounds what an organization can allow per video; the body limit on
+	// /api/videos/upload leaves room for the form around a video this size
+	maxVideoMBLimit = 500
+	// uploadScanTimeout bounds a whole scan, including streaming the file to clamd
+	uploadScanTimeout = 2 * time.Minute
+)
+
+// videoFormats are the video formats uploads can be accepted in. All are ISO base media
+// files, so their length can be read from the header without decoding.
+var videoFormats = []string{"mp4", "m4v", "mov"}
+
+var errUploadInfected = errors.New("upload is infected")
+
+// UploadPolicy is an organization's rules for uploaded files
+type UploadPolicy struct {
+	MaxVideoMB      int      `json:"max_video_mb"`
+	MaxVideoMinutes int      `json:"max_video_minutes"` // 0 means no limit
+	AllowedFormats  []string `json:"allowed_formats"`   // video file extensions without the dot
+	ScanUploads     bool     `json:"scan_uploads"`
+}
+
+// SiteUploadLimits override the organization's video limits for one site; 0 keeps the
+// organization's
+type SiteUploadLimits struct {
+	MaxVideoMB      int `json:"max_video_mb"`
+	MaxVideoMinutes int `json:"max_video_minutes"`
+}
+
+func defaultUploadPolicy() UploadPolicy {
+	return UploadPolicy{
+		MaxVideoMB:     100,
+		AllowedFormats: []string{"mp4"},
+	}
+}
+
+// loadUploadPolicy loads an organization's upload policy; org 0 is the platform's own
+func loadUploadPolicy(orgID int) (UploadPolicy, error) {
+	policy := defaultUploadPolicy()
+	var value []byte
+	err := database.DB.QueryRow("SELECT value FROM system_settings WHERE key = $1", uploadPolicyKeyPrefix+strconv.Itoa(orgID)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	err = json.Unmarshal(value, &policy)
+	return policy, err
+}
+
+func loadSiteUploadLimits(siteID int) (SiteUploadLimits, error) {
+	var limits SiteUploadLimits
+	var value []byte
+	err := database.DB.QueryRow("SELECT value FROM system_settings WHERE key = $1", uploadLimitsKeyPrefix+strconv.Itoa(siteID)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return limits, nil
+	}
+	if err != nil {
+		return limits, err
+	}
+	err = json.Unmarshal(value, &limits)
+	return limits, err
+}
+
+// uploadPolicyForUser is the policy the user's uploads are checked against: their
+// organization's, with their site's limits applied. The defaults apply if it can't be read.
+func uploadPolicyForUser(userID string) UploadPolicy {
+	orgID, _ := userOrganization(userID)
+	policy, err := loadUploadPolicy(orgID)
+	if err != nil {
+		log.Printf("Warning: failed to load upload policy for org %d: %v", orgID, err)
+		policy = defaultUploadPolicy()
+	}
+
+	var siteID sql.NullInt64
+	database.DB.QueryRow("SELECT site_id FROM users WHERE user_id = $1", userID).Scan(&siteID)
+	if !siteID.Valid {
+		return policy
+	}
+	limits, err := loadSiteUploadLimits(int(siteID.Int64))
+	if err != nil {
+		log.Printf("Warning: failed to load upload limits for site %d: %v", siteID.Int64, err)
+		return policy
+	}
+	if limits.MaxVideoMB > 0 {
+		policy.MaxVideoMB = limits.MaxVideoMB
+	}
+	if limits.MaxVideoMinutes > 0 {
+		policy.MaxVideoMinutes = limits.MaxVideoMinutes
+	}
+	return policy
+}
+
+func (p UploadPolicy) maxVideoBytes() int64 {
+	return int64(p.MaxVideoMB) << 20
+}
+
+// checkVideoUpload applies the policy's format, size and length limits to an uploaded video
+// and returns its format. It writes the error response itself when the video is refused.
+func checkVideoUpload(w http.ResponseWriter, policy UploadPolicy, file multipart.File, header *multipart.FileHeader) (string, bool) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	if !containsString(policy.AllowedFormats, format) {
+		respondWithError(w, http.StatusBadRequest, "Allowed video formats: "+strings.ToUpper(strings.Join(policy.AllowedFormats, ", ")))
+		return "", false
+	}
+	if header.Size > policy.maxVideoBytes() {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Videos must be %d MB or smaller", policy.MaxVideoMB))
+		return "", false
+	}
+	if policy.MaxVideoMinutes > 0 {
+		length, err := isoMediaDuration(file)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Could not read the video's length; the file may be damaged")
+			return "", false
+		}
+		if length > time.Duration(policy.MaxVideoMinutes)*time.Minute {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Videos must be %d minutes or shorter", policy.MaxVideoMinutes))
+			return "", false
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to read video")
+		return "", false
+	}
+	return format, true
+}
+
+// screenUpload virus scans an uploaded file when the policy asks for it and rewinds it for
+// saving. It writes the error response itself when the file is refused.
+func screenUpload(w http.ResponseWriter, policy UploadPolicy, file multipart.File) bool {
+	if !policy.ScanUploads {
+		return true
+	}
+	err := scanUpload(file)
+	if err == errUploadInfected {
+		respondWithError(w, http.StatusUnprocessableEntity, "The file failed a virus scan and was not saved")
+		return false
+	}
+	if err != nil {
+		log.Printf("Warning: upload scan failed: %v", err)
+		respondWithError(w, http.StatusServiceUnavailable, "Virus scanning is unavailable, please try again later")
+		return false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to read upload")
+		return false
+	}
+	return true
+}
+
+// scanUpload streams the file to clamd with INSTREAM and returns errUploadInfected if a
+// signature matched
+func scanUpload(file io.ReadSeeker) error {
+	addr := config.Get().UploadScanAddr
+	if addr == "" {
+		return errors.New("UPLOAD_SCAN_ADDR is not set")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(uploadScanTimeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			var size [4]byte
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, werr := conn.Write(append(size[:], buf[:n]...)); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return err
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return err
+	}
+	result := strings.TrimRight(string(reply), "\x00\n")
+	switch {
+	case strings.HasSuffix(result, " OK"):
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		log.Printf("Upload rejected by virus scan: %s", result)
+		return errUploadInfected
+	default:
+		return fmt.Errorf("clamd replied %q", result)
+	}
+}
+
+// isoMediaDuration reads the length of an MP4/MOV file from its moov/mvhd header
+func isoMediaDuration(f io.ReadSeeker) (time.Duration, error) {
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	moov, moovEnd, err := findISOBox(f, 0, end, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findISOBox(f, moov, moovEnd, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(mvhd, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var header [32]byte
+	if _, err := io.ReadFull(f, header[:1]); err != nil {
+		return 0, err
+	}
+	var timescale uint32
+	var duration uint64
+	if header[0] == 1 {
+		// version, flags, 64-bit creation and modification times, timescale, 64-bit duration
+		if _, err := io.ReadFull(f, header[1:32]); err != nil {
+			return 0, err
+		}
+		timescale = binary.BigEndian.Uint32(header[20:24])
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		if _, err := io.ReadFull(f, header[1:20]); err != nil {
+			return 0, err
+		}
+		timescale = binary.BigEndian.Uint32(header[12:16])
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	if timescale == 0 {
+		return 0, errors.New("mvhd has no timescale")
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), nil
+}
+
+// findISOBox finds a box of the given type between start and end and returns where its
+// payload starts and ends
+func findISOBox(f io.ReadSeeker, start, end int64, boxType string) (int64, int64, error) {
+	for pos := start; pos+8 <= end; {
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		var header [16]byte
+		if _, err := io.ReadFull(f, header[:8]); err != nil {
+			return 0, 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerLen := int64(8)
+		switch size {
+		case 0: // extends to the end of the file
+			size = end - pos
+		case 1: // 64-bit size follows the type
+			if _, err := io.ReadFull(f, header[8:16]); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen || pos+size > end {
+			return 0, 0, errors.New("malformed media box")
+		}
+		if string(header[4:8]) == boxType {
+			return pos + headerLen, pos + size, nil
+		}
+		pos += size
+	}
+	return 0, 0, fmt.Errorf("no %s box", boxType)
+}
+
+// GetMyUploadPolicy - The limits the caller's video uploads are checked against, so the
+// app can refuse a file before sending it
+// GET /api/videos/upload-policy
+func GetMyUploadPolicy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, uploadPolicyForUser(userID))
+}
+
+// AdminGetUploadPolicy - The organization's upload policy
+// GET /api/admin/upload-policy
+func AdminGetUploadPolicy(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := brandingOrg(w, r)
+	if !ok {
+		return
+	}
+	policy, err := loadUploadPolicy(orgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading upload policy")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"policy":             policy,
+		"supported_formats":  videoFormats,
+		"scanner_configured": config.Get().UploadScanAddr != "",
+	})
+}
+
+// AdminUpdateUploadPolicy - Replace the organization's video size, length and format limits
+// and turn virus scanning on or off
+// PUT /api/admin/upload-policy
+func AdminUpdateUploadPolicy(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	orgID, ok := brandingOrg(w, r)
+	if !ok {
+		return
+	}
+
+	var policy UploadPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if policy.MaxVideoMB < 1 || policy.MaxVideoMB > maxVideoMBLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("max_video_mb must be between 1 and %d", maxVideoMBLimit))
+		return
+	}
+	if policy.MaxVideoMinutes < 0 {
+		respondWithError(w, http.StatusBadRequest, "max_video_minutes cannot be negative")
+		return
+	}
+	formats := []string{}
+	for _, f := range policy.AllowedFormats {
+		f = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(f)), ".")
+		if !containsString(videoFormats, f) {
+			respondWithError(w, http.StatusBadRequest, "Unsupported video format: "+f+" (supported: "+strings.Join(videoFormats, ", ")+")")
+			return
+		}
+		formats = append(formats, f)
+	}
+	if len(formats) == 0 {
+		respondWithError(w, http.StatusBadRequest, "allowed_formats needs at least one format")
+		return
+	}
+	policy.AllowedFormats = formats
+	if policy.ScanUploads && config.Get().UploadScanAddr == "" {
+		respondWithError(w, http.StatusBadRequest, "Virus scanning needs UPLOAD_SCAN_ADDR set on the server")
+		return
+	}
+
+	value, _ := json.Marshal(policy)
+	_, err := database.DB.Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, uploadPolicyKeyPrefix+strconv.Itoa(orgID), value, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving upload policy: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// adminSiteID parses the site in the URL and checks it belongs to the admin's organization
+func adminSiteID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	siteID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid site ID")
+		return 0, false
+	}
+	var inOrg bool
+	args := []interface{}{siteID}
+	scope := tenantClause(r, "org_id", &args)
+	if err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM sites WHERE id = $1"+scope+")", args...).Scan(&inOrg); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return 0, false
+	}
+	if !inOrg {
+		respondWithError(w, http.StatusNotFound, "Site not found")
+		return 0, false
+	}
+	return siteID, true
+}
+
+// AdminGetSiteUploadLimits - A site's overrides of its organization's video limits
+// GET /api/admin/sites/{id}/upload-limits
+func AdminGetSiteUploadLimits(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := adminSiteID(w, r)
+	if !ok {
+		return
+	}
+	limits, err := loadSiteUploadLimits(siteID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading upload limits")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, limits)
+}
+
+// AdminUpdateSiteUploadLimits - Override the organization's video size and length limits at
+// one site, e.g. a smaller size where the site's uplink is slow; 0 keeps the organization's
+// PUT /api/admin/sites/{id}/upload-limits
+func AdminUpdateSiteUploadLimits(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	siteID, ok := adminSiteID(w, r)
+	if !ok {
+		return
+	}
+
+	var limits SiteUploadLimits
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if limits.MaxVideoMB < 0 || limits.MaxVideoMB > maxVideoMBLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("max_video_mb must be between 0 and %d", maxVideoMBLimit))
+		return
+	}
+	if limits.MaxVideoMinutes < 0 {
+		respondWithError(w, http.StatusBadRequest, "max_video_minutes cannot be negative")
+		return
+	}
+
+	value, _ := json.Marshal(limits)
+	_, err := database.DB.Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, uploadLimitsKeyPrefix+strconv.Itoa(siteID), value, adminID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving upload limits: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, limits)
+}
@@ -5,15 +5,20 @@ import (
 	"MineSafeBackend/middleware"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 // ==================== VIDEO FEED RESPONSES ====================
@@ -35,21 +40,122 @@ type VideoFeedResponse struct {
 	Videos  []VideoFeedItem `json:"videos"`
 	HasMore bool            `json:"has_more"`
 	Total   int             `json:"total"`
+	// Since is the cut-off applied by since= or new=true, so the app can keep paging with it
+	Since *time.Time `json:"since,omitempty"`
+	// Quality is the media quality served: low, medium or high (see requestedQuality)
+	Quality string `json:"quality"`
+}
+
+// signFeedMedia signs the videos' media URLs, swapping in renditions for the requested
+// quality, and returns the quality
+func signFeedMedia(w http.ResponseWriter, r *http.Request, videos []VideoFeedItem) string {
+	sources := make([]string, 0, 2*len(videos))
+	for _, v := range videos {
+		sources = append(sources, v.VideoURL)
+		if v.ThumbnailURL != "" {
+			sources = append(sources, v.ThumbnailURL)
+		}
+	}
+	media := loadRenditions(w, r, sources)
+	for i := range videos {
+		videos[i].VideoURL = media.sign(videos[i].VideoURL)
+		if videos[i].ThumbnailURL != "" {
+			videos[i].ThumbnailURL = media.sign(videos[i].ThumbnailURL)
+		}
+	}
+	return media.quality
 }
 
 // ==================== VIDEO FEED ENDPOINTS ====================
 
+// feedVisitGap is how long the feed must be left alone before the next open counts as a new
+// visit for new=true, so paging and refreshing within a session keep the same cut-off
+const feedVisitGap = 30 * time.Minute
+
+// recordFeedVisit tracks feed visits and returns when the previous visit started
+func recordFeedVisit(userID string) (*time.Time, error) {
+	var previous sql.NullTime
+	err := database.DB.QueryRow(`
+		UPDATE users SET
+			feed_previous_visit_at = CASE
+				WHEN feed_last_visit_at IS NULL OR feed_last_visit_at < $2 THEN feed_last_visit_at
+				ELSE feed_previous_visit_at END,
+			feed_last_visit_at = NOW()
+		WHERE user_id = $1
+		RETURNING feed_previous_visit_at
+	`, userID, time.Now().Add(-feedVisitGap)).Scan(&previous)
+	if err != nil || !previous.Valid {
+		return nil, err
+	}
+	return &previous.Time, nil
+}
+
+// parseListParam splits a comma-separated query parameter, dropping empty entries
+func parseListParam(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// FeedPagination is the paging block of the v2 feed envelope
+type FeedPagination struct {
+	Page    int  `json:"page"`
+	Limit   int  `json:"limit"`
+	Total   int  `json:"total"`
+	HasMore bool `json:"has_more"`
+}
+
+// VideoFeedResponseV2 is the v2 feed: items under data and paging under pagination, the
+// envelope later list endpoints move to
+type VideoFeedResponseV2 struct {
+	Data       []VideoFeedItem `json:"data"`
+	Pagination FeedPagination  `json:"pagination"`
+	Since      *time.Time      `json:"since,omitempty"`
+	Quality    string          `json:"quality"`
+}
+
 // GetVideoFeed - GET /api/videos/feed?page=1&limit=10
+// Optional filters: hide_completed=true, category=PPE,Safety, language=Hindi,
+// since=2024-01-31 (or RFC3339), new=true (only videos added since the last visit),
+// quality=low|medium|high (Save-Data: on means low)
 func GetVideoFeed(w http.ResponseWriter, r *http.Request) {
-	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if feed, _, _, ok := videoFeed(w, r); ok {
+		respondWithJSON(w, http.StatusOK, feed)
+	}
+}
+
+// GetVideoFeedV2 - GET /api/v2/videos/feed, the same feed and filters in the v2 envelope
+func GetVideoFeedV2(w http.ResponseWriter, r *http.Request) {
+	feed, page, limit, ok := videoFeed(w, r)
 	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, VideoFeedResponseV2{
+		Data:       feed.Videos,
+		Pagination: FeedPagination{Page: page, Limit: limit, Total: feed.Total, HasMore: feed.HasMore},
+		Since:      feed.Since,
+		Quality:    feed.Quality,
+	})
+}
+
+// videoFeed loads one page of the caller's feed. When ok is false it has already written
+// the error response.
+func videoFeed(w http.ResponseWriter, r *http.Request) (feed VideoFeedResponse, page, limit int, ok bool) {
+	userID, found := middleware.GetUserIDFromContext(r.Context())
+	if !found {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
+	query := r.URL.Query()
+
 	// Parse pagination params
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, _ = strconv.Atoi(query.Get("page"))
+	limit, _ = strconv.Atoi(query.Get("limit"))
 
 	if page < 1 {
 		page = 1
@@ -60,16 +166,66 @@ func GetVideoFeed(w http.ResponseWriter, r *http.Request) {
 
 	offset := (page - 1) * limit
 
+	// Build filters
+	args := []interface{}{}
+	where := " WHERE vm.is_active = true" + sharedTenantClause(r, "vm.org_id", &args)
+	argCount := len(args) + 1
+
+	if query.Get("hide_completed") == "true" {
+		where += fmt.Sprintf(" AND NOT EXISTS (SELECT 1 FROM module_completions mc WHERE mc.miner_id = $%d AND mc.video_id = vm.id AND mc.passed)", argCount)
+		args = append(args, userID)
+		argCount++
+	}
+	if categories := parseListParam(query.Get("category")); len(categories) > 0 {
+		where += fmt.Sprintf(" AND vm.category = ANY($%d)", argCount)
+		args = append(args, pq.Array(categories))
+		argCount++
+	}
+	if languages := parseListParam(query.Get("language")); len(languages) > 0 {
+		where += fmt.Sprintf(" AND vm.language = ANY($%d)", argCount)
+		args = append(args, pq.Array(languages))
+		argCount++
+	}
+
+	var since *time.Time
+	if s := query.Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", s)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "since must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+			return
+		}
+		since = &t
+	}
+
+	// Visits are tracked on the first page only so paging doesn't move the cut-off
+	if page == 1 {
+		previousVisit, err := recordFeedVisit(userID)
+		if err != nil {
+			log.Printf("Warning: failed to record feed visit for %s: %v", userID, err)
+		}
+		if since == nil && query.Get("new") == "true" {
+			since = previousVisit
+		}
+	}
+	if since != nil {
+		where += fmt.Sprintf(" AND vm.created_at > $%d", argCount)
+		args = append(args, *since)
+		argCount++
+	}
+
 	// Get total count
 	var total int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM video_modules WHERE is_active = true").Scan(&total)
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM video_modules vm"+where, args...).Scan(&total)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
 	// Get videos with user reaction status
-	rows, err := database.DB.Query(`
+	rows, err := database.DB.Query(fmt.Sprintf(`
 		SELECT 
 			vm.id, vm.title, vm.video_url, vm.thumbnail, 
 			COALESCE(vm.tags, '[]'::jsonb) as tags,
@@ -79,11 +235,9 @@ func GetVideoFeed(w http.ResponseWriter, r *http.Request) {
 			EXISTS(SELECT 1 FROM questions q WHERE q.video_id = vm.id) OR 
 			EXISTS(SELECT 1 FROM quizzes qz WHERE qz.video_id = vm.id) as has_quiz
 		FROM video_modules vm
-		LEFT JOIN video_reactions vr ON vm.id = vr.video_id AND vr.user_id = $1
-		WHERE vm.is_active = true
-		ORDER BY vm.created_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, limit, offset)
+		LEFT JOIN video_reactions vr ON vm.id = vr.video_id AND vr.user_id = $%d`, argCount)+where+
+		fmt.Sprintf(" ORDER BY vm.created_at DESC LIMIT $%d OFFSET $%d", argCount+1, argCount+2),
+		append(args, userID, limit, offset)...)
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -125,14 +279,18 @@ func GetVideoFeed(w http.ResponseWriter, r *http.Request) {
 
 	hasMore := offset+limit < total
 
-	respondWithJSON(w, http.StatusOK, VideoFeedResponse{
+	feed = VideoFeedResponse{
 		Videos:  videos,
 		HasMore: hasMore,
 		Total:   total,
-	})
+		Since:   since,
+		Quality: signFeedMedia(w, r, videos),
+	}
+	return feed, page, limit, true
 }
 
 // GetRecommendedVideos - GET /api/videos/recommended?tags=PPE,safety,HEMI
+// Videos on topics the user is weak in (see GetMyMastery) come first
 func GetRecommendedVideos(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -164,6 +322,10 @@ func GetRecommendedVideos(w http.ResponseWriter, r *http.Request) {
 	// Build tags JSON array for query
 	tagsJSON, _ := json.Marshal(tags)
 
+	// Topics the miner is weak in are always included and ranked first
+	weak := weakTopics(userID)
+	weakJSON, _ := json.Marshal(weak)
+
 	// Get videos matching any of the tags
 	rows, err := database.DB.Query(`
 		SELECT 
@@ -176,14 +338,16 @@ func GetRecommendedVideos(w http.ResponseWriter, r *http.Request) {
 			EXISTS(SELECT 1 FROM quizzes qz WHERE qz.video_id = vm.id) as has_quiz
 		FROM video_modules vm
 		LEFT JOIN video_reactions vr ON vm.id = vr.video_id AND vr.user_id = $1
-		WHERE vm.is_active = true
+		WHERE vm.is_active = true AND (vm.org_id IS NULL OR vm.org_id = $4)
 		AND (
 			$2::jsonb = '[]'::jsonb OR
-			vm.tags ?| ARRAY(SELECT jsonb_array_elements_text($2::jsonb))
+			vm.tags ?| ARRAY(SELECT jsonb_array_elements_text($2::jsonb)) OR
+			vm.tags ?| ARRAY(SELECT jsonb_array_elements_text($3::jsonb))
 		)
-		ORDER BY vm.likes_count DESC, vm.created_at DESC
+		ORDER BY COALESCE(vm.tags ?| ARRAY(SELECT jsonb_array_elements_text($3::jsonb)), false) DESC,
+			vm.likes_count DESC, vm.created_at DESC
 		LIMIT 20
-	`, userID, tagsJSON)
+	`, userID, tagsJSON, weakJSON, requestOrg(r))
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
@@ -226,110 +390,210 @@ func GetRecommendedVideos(w http.ResponseWriter, r *http.Request) {
 		Videos:  videos,
 		HasMore: false,
 		Total:   len(videos),
+		Quality: signFeedMedia(w, r, videos),
 	})
 }
 
-// LikeVideo - POST /api/videos/{id}/like
-func LikeVideo(w http.ResponseWriter, r *http.Request) {
-	userID, ok := middleware.GetUserIDFromContext(r.Context())
-	if !ok {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
+// ==================== VIDEO REACTIONS ====================
 
-	vars := mux.Vars(r)
-	videoID := vars["id"]
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+	ReactionNone    = "none"
+)
 
-	// Check if video exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1)", videoID).Scan(&exists)
-	if err != nil || !exists {
-		respondWithError(w, http.StatusNotFound, "Video not found")
-		return
-	}
+// reactionCountColumns maps a reaction to its denormalized counter on video_modules
+var reactionCountColumns = map[string]string{
+	ReactionLike:    "likes_count",
+	ReactionDislike: "dislikes_count",
+}
 
-	// Check current reaction
-	var currentReaction sql.NullString
-	database.DB.QueryRow("SELECT reaction_type FROM video_reactions WHERE user_id = $1 AND video_id = $2",
-		userID, videoID).Scan(&currentReaction)
+// VideoReactionResponse is returned by every reaction endpoint so the app can update the
+// video in place instead of refetching the feed
+type VideoReactionResponse struct {
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+	VideoID      int    `json:"video_id"`
+	Likes        int    `json:"likes"`
+	Dislikes     int    `json:"dislikes"`
+	UserReaction string `json:"user_reaction"` // like, dislike or none
+	UserLiked    bool   `json:"user_liked"`
+	UserDisliked bool   `json:"user_disliked"`
+}
 
+// setVideoReaction sets the user's reaction to a video and keeps the counters in step.
+// With toggle, repeating the current reaction clears it (the like/dislike button behaviour).
+// The video row is locked so concurrent reactions can't interleave their counter updates.
+// Videos outside orgID and the shared library are treated as missing.
+func setVideoReaction(userID string, orgID sql.NullInt64, videoID int, reaction string, toggle bool) (*VideoReactionResponse, error) {
 	tx, err := database.DB.Begin()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Database error")
-		return
+		return nil, err
 	}
 	defer tx.Rollback()
 
-	if currentReaction.Valid && currentReaction.String == "like" {
-		// Remove like (toggle off)
-		_, err = tx.Exec("DELETE FROM video_reactions WHERE user_id = $1 AND video_id = $2", userID, videoID)
-		if err == nil {
-			_, err = tx.Exec("UPDATE video_modules SET likes_count = GREATEST(likes_count - 1, 0) WHERE id = $1", videoID)
-		}
-	} else {
-		// If had dislike, remove it first
-		if currentReaction.Valid && currentReaction.String == "dislike" {
-			_, err = tx.Exec("UPDATE video_modules SET dislikes_count = GREATEST(dislikes_count - 1, 0) WHERE id = $1", videoID)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Database error")
-				return
+	var id int
+	err = tx.QueryRow(
+		"SELECT id FROM video_modules WHERE id = $1 AND (org_id IS NULL OR org_id = $2) FOR UPDATE",
+		videoID, orgID,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	current := ReactionNone
+	var existing sql.NullString
+	err = tx.QueryRow("SELECT reaction_type FROM video_reactions WHERE user_id = $1 AND video_id = $2",
+		userID, videoID).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if existing.Valid {
+		current = existing.String
+	}
+
+	if toggle && current == reaction {
+		reaction = ReactionNone
+	}
+
+	if reaction != current {
+		if column, ok := reactionCountColumns[current]; ok {
+			if _, err := tx.Exec("UPDATE video_modules SET "+column+" = GREATEST("+column+" - 1, 0) WHERE id = $1", videoID); err != nil {
+				return nil, err
 			}
 		}
 
-		// Upsert like
-		_, err = tx.Exec(`
-			INSERT INTO video_reactions (user_id, video_id, reaction_type, created_at)
-			VALUES ($1, $2, 'like', NOW())
-			ON CONFLICT (user_id, video_id) DO UPDATE SET reaction_type = 'like', created_at = NOW()
-		`, userID, videoID)
-
-		if err == nil {
-			if !currentReaction.Valid || currentReaction.String != "like" {
-				_, err = tx.Exec("UPDATE video_modules SET likes_count = likes_count + 1 WHERE id = $1", videoID)
+		if reaction == ReactionNone {
+			_, err = tx.Exec("DELETE FROM video_reactions WHERE user_id = $1 AND video_id = $2", userID, videoID)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO video_reactions (user_id, video_id, reaction_type, created_at)
+				VALUES ($1, $2, $3, NOW())
+				ON CONFLICT (user_id, video_id) DO UPDATE SET reaction_type = $3, created_at = NOW()
+			`, userID, videoID, reaction)
+			if err == nil {
+				column := reactionCountColumns[reaction]
+				_, err = tx.Exec("UPDATE video_modules SET "+column+" = COALESCE("+column+", 0) + 1 WHERE id = $1", videoID)
 			}
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	resp := &VideoReactionResponse{
+		Success:      true,
+		Message:      "Reaction updated",
+		VideoID:      videoID,
+		UserReaction: reaction,
+		UserLiked:    reaction == ReactionLike,
+		UserDisliked: reaction == ReactionDislike,
+	}
+	err = tx.QueryRow("SELECT COALESCE(likes_count, 0), COALESCE(dislikes_count, 0) FROM video_modules WHERE id = $1",
+		videoID).Scan(&resp.Likes, &resp.Dislikes)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// respondWithVideoReaction applies a reaction for the current user and writes the new state
+func respondWithVideoReaction(w http.ResponseWriter, r *http.Request, reaction string, toggle bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	if err = tx.Commit(); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Database error")
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Reaction updated",
-	})
+	resp, err := setVideoReaction(userID, requestOrg(r), videoID, reaction, toggle)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Video not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// LikeVideo - POST /api/videos/{id}/like
+// Toggles the user's like
+func LikeVideo(w http.ResponseWriter, r *http.Request) {
+	respondWithVideoReaction(w, r, ReactionLike, true)
 }
 
 // DislikeVideo - POST /api/videos/{id}/dislike
+// Toggles the user's dislike
 func DislikeVideo(w http.ResponseWriter, r *http.Request) {
+	respondWithVideoReaction(w, r, ReactionDislike, true)
+}
+
+// SetVideoReactionRequest represents the request body for setting a reaction
+type SetVideoReactionRequest struct {
+	Reaction string `json:"reaction"` // like, dislike or none
+}
+
+// SetVideoReaction - POST /api/videos/{id}/reaction
+// Sets the user's reaction explicitly, so retries are idempotent
+func SetVideoReaction(w http.ResponseWriter, r *http.Request) {
+	var req SetVideoReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	reaction := strings.ToLower(strings.TrimSpace(req.Reaction))
+	if reaction == "" {
+		reaction = ReactionNone
+	}
+	if reaction != ReactionLike && reaction != ReactionDislike && reaction != ReactionNone {
+		respondWithError(w, http.StatusBadRequest, "reaction must be like, dislike or none")
+		return
+	}
+
+	respondWithVideoReaction(w, r, reaction, false)
+}
+
+// videoViewDedupWindow stops replays and reloads from counting as new views
+const videoViewDedupWindow = 30 * time.Minute
+
+// RecordVideoView - POST /api/videos/{id}/view
+// Counts a view, at most once per user per video every 30 minutes
+func RecordVideoView(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	vars := mux.Vars(r)
-	videoID := vars["id"]
+	videoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID")
+		return
+	}
 
-	// Check if video exists
 	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1)", videoID).Scan(&exists)
+	err = database.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1 AND (org_id IS NULL OR org_id = $2))",
+		videoID, requestOrg(r),
+	).Scan(&exists)
 	if err != nil || !exists {
 		respondWithError(w, http.StatusNotFound, "Video not found")
 		return
 	}
 
-	// Check current reaction
-	var currentReaction sql.NullString
-	database.DB.QueryRow("SELECT reaction_type FROM video_reactions WHERE user_id = $1 AND video_id = $2",
-		userID, videoID).Scan(&currentReaction)
-
 	tx, err := database.DB.Begin()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
@@ -337,41 +601,26 @@ func DislikeVideo(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
-	if currentReaction.Valid && currentReaction.String == "dislike" {
-		// Remove dislike (toggle off)
-		_, err = tx.Exec("DELETE FROM video_reactions WHERE user_id = $1 AND video_id = $2", userID, videoID)
-		if err == nil {
-			_, err = tx.Exec("UPDATE video_modules SET dislikes_count = GREATEST(dislikes_count - 1, 0) WHERE id = $1", videoID)
-		}
-	} else {
-		// If had like, remove it first
-		if currentReaction.Valid && currentReaction.String == "like" {
-			_, err = tx.Exec("UPDATE video_modules SET likes_count = GREATEST(likes_count - 1, 0) WHERE id = $1", videoID)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Database error")
-				return
-			}
-		}
-
-		// Upsert dislike
-		_, err = tx.Exec(`
-			INSERT INTO video_reactions (user_id, video_id, reaction_type, created_at)
-			VALUES ($1, $2, 'dislike', NOW())
-			ON CONFLICT (user_id, video_id) DO UPDATE SET reaction_type = 'dislike', created_at = NOW()
-		`, userID, videoID)
-
-		if err == nil {
-			if !currentReaction.Valid || currentReaction.String != "dislike" {
-				_, err = tx.Exec("UPDATE video_modules SET dislikes_count = dislikes_count + 1 WHERE id = $1", videoID)
-			}
-		}
-	}
-
+	result, err := tx.Exec(`
+		INSERT INTO video_views (user_id, video_id, viewed_at)
+		SELECT $1, $2, NOW()
+		WHERE NOT EXISTS (
+			SELECT 1 FROM video_views WHERE user_id = $1 AND video_id = $2 AND viewed_at > $3
+		)
+	`, userID, videoID, time.Now().Add(-videoViewDedupWindow))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		return
 	}
 
+	counted, _ := result.RowsAffected()
+	if counted > 0 {
+		if _, err := tx.Exec("UPDATE video_modules SET views_count = COALESCE(views_count, 0) + 1 WHERE id = $1", videoID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+			return
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -379,7 +628,7 @@ func DislikeVideo(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "Reaction updated",
+		"counted": counted > 0,
 	})
 }
 
@@ -393,8 +642,15 @@ func UploadVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form (max 100MB)
-	err := r.ParseMultipartForm(100 << 20)
+	// The body can't be much more than the policy's largest video
+	policy := uploadPolicyForUser(userID)
+	r.Body = http.MaxBytesReader(w, r.Body, policy.maxVideoBytes()+1<<20)
+	err := r.ParseMultipartForm(32 << 20)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Videos must be %d MB or smaller", policy.MaxVideoMB))
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
 		return
@@ -430,21 +686,23 @@ func UploadVideo(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Validate file type
-	if !strings.HasSuffix(strings.ToLower(handler.Filename), ".mp4") {
-		respondWithError(w, http.StatusBadRequest, "Only MP4 files are allowed")
+	format, ok := checkVideoUpload(w, policy, file, handler)
+	if !ok {
+		return
+	}
+	if !screenUpload(w, policy, file) {
 		return
 	}
 
 	// Create uploads directory if not exists
-	uploadsDir := "uploads/videos"
+	uploadsDir := uploadDir("videos")
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create upload directory")
 		return
 	}
 
 	// Generate unique filename
-	videoFileName := uuid.New().String() + ".mp4"
+	videoFileName := uuid.New().String() + "." + format
 	videoFilePath := filepath.Join(uploadsDir, videoFileName)
 
 	// Save file
@@ -483,9 +741,10 @@ func UploadVideo(w http.ResponseWriter, r *http.Request) {
 	if quizStr != "" {
 		var quizData struct {
 			Questions []struct {
-				Question string   `json:"question"`
-				Options  []string `json:"options"`
-				Correct  int      `json:"correct"`
+				Question    string   `json:"question"`
+				Options     []string `json:"options"`
+				Correct     int      `json:"correct"`
+				Explanation string   `json:"explanation"`
 			} `json:"questions"`
 		}
 
@@ -504,9 +763,9 @@ func UploadVideo(w http.ResponseWriter, r *http.Request) {
 				for _, q := range quizData.Questions {
 					optionsJSON, _ := json.Marshal(q.Options)
 					database.DB.Exec(`
-						INSERT INTO quiz_questions (quiz_id, question, options, correct_answer, created_at)
-						VALUES ($1, $2, $3, $4, NOW())
-					`, quizID, q.Question, optionsJSON, q.Correct)
+						INSERT INTO quiz_questions (quiz_id, question, options, correct_answer, explanation, created_at)
+						VALUES ($1, $2, $3, $4, NULLIF($5, ''), NOW())
+					`, quizID, q.Question, optionsJSON, q.Correct, q.Explanation)
 				}
 			}
 		}
@@ -630,6 +889,7 @@ func GetMySubmittedVideos(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning video: "+err.Error())
 			return
 		}
+		video.VideoURL = signUploadURL(video.VideoURL)
 		// Handle time conversion
 		if t, ok := createdAt.(interface{ Format(string) string }); ok {
 			video.CreatedAt = t.Format("2006-01-02T15:04:05Z07:00")
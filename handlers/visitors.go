@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/models"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== VISITOR MANAGEMENT ====================
+
+const (
+	VisitorStatusPendingInduction = "PENDING_INDUCTION"
+	VisitorStatusInducted         = "INDUCTED"
+	VisitorStatusExpired          = "EXPIRED"
+	VisitorStatusRevoked          = "REVOKED"
+)
+
+// visitorInductionPassPercent is the minimum quiz score for a visitor's induction to count
+const visitorInductionPassPercent = 80.0
+
+// maxVisitorPassDuration caps how long a temporary visitor account can stay valid
+const maxVisitorPassDuration = 14 * 24 * time.Hour
+
+// VisitorRegisterRequest represents the request body for registering a site visitor
+type VisitorRegisterRequest struct {
+	Name             string `json:"name"`
+	Email            string `json:"email"`
+	Phone            string `json:"phone"`
+	Company          string `json:"company"`
+	Purpose          string `json:"purpose"`
+	InductionVideoID int    `json:"induction_video_id"`
+	ValidFrom        string `json:"valid_from"`  // RFC3339, defaults to now
+	ValidUntil       string `json:"valid_until"` // RFC3339, defaults to 24h after valid_from
+}
+
+// VisitorPass represents a visitor's temporary site pass
+type VisitorPass struct {
+	ID               int        `json:"id"`
+	VisitorID        string     `json:"visitor_id"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	Phone            string     `json:"phone"`
+	Company          string     `json:"company"`
+	Purpose          string     `json:"purpose"`
+	HostSupervisorID string     `json:"host_supervisor_id"`
+	HostName         string     `json:"host_name"`
+	InductionVideoID *int       `json:"induction_video_id"`
+	InductionTitle   string     `json:"induction_title"`
+	BadgeNumber      string     `json:"badge_number"`
+	ValidFrom        time.Time  `json:"valid_from"`
+	ValidUntil       time.Time  `json:"valid_until"`
+	Status           string     `json:"status"`
+	InductedAt       *time.Time `json:"inducted_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+const visitorPassSelect = `
+	SELECT vp.id, u.user_id, u.name, u.email, COALESCE(u.phone, ''), COALESCE(vp.company, ''),
+	       COALESCE(vp.purpose, ''), COALESCE(vp.host_supervisor_id, ''), COALESCE(h.name, ''),
+	       vp.induction_video_id, COALESCE(vm.title, ''), vp.badge_number, vp.valid_from,
+	       vp.valid_until, vp.status, vp.inducted_at, vp.created_at
+	FROM visitor_passes vp
+	JOIN users u ON vp.user_id = u.user_id
+	LEFT JOIN users h ON vp.host_supervisor_id = h.user_id
+	LEFT JOIN video_modules vm ON vp.induction_video_id = vm.id
+`
+
+func scanVisitorPass(scanner interface{ Scan(...interface{}) error }) (VisitorPass, error) {
+	var pass VisitorPass
+	var inductionVideoID sql.NullInt64
+	var inductedAt sql.NullTime
+	err := scanner.Scan(&pass.ID, &pass.VisitorID, &pass.Name, &pass.Email, &pass.Phone, &pass.Company,
+		&pass.Purpose, &pass.HostSupervisorID, &pass.HostName, &inductionVideoID, &pass.InductionTitle,
+		&pass.BadgeNumber, &pass.ValidFrom, &pass.ValidUntil, &pass.Status, &inductedAt, &pass.CreatedAt)
+	if err != nil {
+		return pass, err
+	}
+	if inductionVideoID.Valid {
+		id := int(inductionVideoID.Int64)
+		pass.InductionVideoID = &id
+	}
+	if inductedAt.Valid {
+		pass.InductedAt = &inductedAt.Time
+	}
+	// A pass past its window is expired even if the expiry job hasn't run yet
+	if pass.Status != VisitorStatusRevoked && time.Now().After(pass.ValidUntil) {
+		pass.Status = VisitorStatusExpired
+	}
+	return pass, nil
+}
+
+// generateRandomHex returns a random hex string of n bytes
+func generateRandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RegisterVisitor - Register a site visitor with a temporary account
+// POST /api/supervisor/visitors
+func RegisterVisitor(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req VisitorRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Name == "" || req.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "Name and email are required")
+		return
+	}
+
+	if req.InductionVideoID == 0 {
+		respondWithError(w, http.StatusBadRequest, "induction_video_id is required")
+		return
+	}
+
+	// Resolve the validity window
+	validFrom := time.Now()
+	if req.ValidFrom != "" {
+		t, err := time.Parse(time.RFC3339, req.ValidFrom)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "valid_from must be RFC3339")
+			return
+		}
+		validFrom = t
+	}
+	validUntil := validFrom.Add(24 * time.Hour)
+	if req.ValidUntil != "" {
+		t, err := time.Parse(time.RFC3339, req.ValidUntil)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "valid_until must be RFC3339")
+			return
+		}
+		validUntil = t
+	}
+	if !validUntil.After(validFrom) {
+		respondWithError(w, http.StatusBadRequest, "valid_until must be after valid_from")
+		return
+	}
+	if validUntil.Sub(validFrom) > maxVisitorPassDuration {
+		respondWithError(w, http.StatusBadRequest, "Visitor passes cannot exceed 14 days")
+		return
+	}
+
+	// Induction video must exist and be active
+	var videoExists bool
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM video_modules WHERE id = $1 AND is_active = true)",
+		req.InductionVideoID).Scan(&videoExists)
+	if err != nil || !videoExists {
+		respondWithError(w, http.StatusBadRequest, "Induction video not found")
+		return
+	}
+
+	if code, msg := checkContactAvailable(&req.Email, &req.Phone, ""); code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	// Visitors get a generated one-time password handed over at the gate
+	tempPassword, err := generateRandomHex(4)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating credentials")
+		return
+	}
+	hashedPassword, err := hashPassword(tempPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+	badgeSuffix, err := generateRandomHex(3)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating badge number")
+		return
+	}
+	badgeNumber := "VIS-" + strings.ToUpper(badgeSuffix)
+
+	var miningSite, location sql.NullString
+	database.DB.QueryRow("SELECT mining_site, location FROM users WHERE user_id = $1", supervisorID).Scan(&miningSite, &location)
+
+	visitor, err := models.NewUser(req.Name, req.Email, req.Phone, string(hashedPassword),
+		miningSite.String, location.String, models.RoleVisitor, &supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO users (user_id, name, email, phone, password, role, mining_site, location, supervisor_id, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id`,
+		visitor.UserID, visitor.Name, visitor.Email, visitor.Phone, visitor.Password, visitor.Role,
+		visitor.MiningSite, visitor.Location, visitor.SupervisorID, visitor.CreatedAt, visitor.UpdatedAt,
+	).Scan(&visitor.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating visitor: "+err.Error())
+		return
+	}
+
+	var passID int
+	err = tx.QueryRow(`
+		INSERT INTO visitor_passes (user_id, host_supervisor_id, company, purpose, induction_video_id,
+		                            badge_number, valid_from, valid_until, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING id
+	`, visitor.UserID, supervisorID, req.Company, req.Purpose, req.InductionVideoID,
+		badgeNumber, validFrom, validUntil, VisitorStatusPendingInduction).Scan(&passID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating visitor pass: "+err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":       true,
+		"pass_id":       passID,
+		"visitor_id":    visitor.UserID,
+		"badge_number":  badgeNumber,
+		"email":         visitor.Email,
+		"temp_password": tempPassword,
+		"valid_from":    validFrom,
+		"valid_until":   validUntil,
+		"status":        VisitorStatusPendingInduction,
+		"message":       "Visitor registered. Induction must be completed before a badge is issued",
+	})
+}
+
+// GetVisitors - List visitors hosted by this supervisor
+// GET /api/supervisor/visitors?status=INDUCTED
+func GetVisitors(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	statusFilter := r.URL.Query().Get("status")
+
+	rows, err := database.DB.Query(visitorPassSelect+`
+		WHERE vp.host_supervisor_id = $1
+		ORDER BY vp.valid_from DESC
+	`, supervisorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	visitors := []VisitorPass{}
+	for rows.Next() {
+		pass, err := scanVisitorPass(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data: "+err.Error())
+			return
+		}
+		if statusFilter != "" && pass.Status != statusFilter {
+			continue
+		}
+		visitors = append(visitors, pass)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"visitors": visitors,
+		"count":    len(visitors),
+	})
+}
+
+// GetVisitorBadge - Badge print payload for an inducted visitor
+// GET /api/supervisor/visitors/{id}/badge
+func GetVisitorBadge(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	passID := vars["id"]
+
+	pass, err := scanVisitorPass(database.DB.QueryRow(visitorPassSelect+`
+		WHERE vp.id = $1 AND vp.host_supervisor_id = $2
+	`, passID, supervisorID))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Visitor not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if pass.Status != VisitorStatusInducted {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Badge unavailable: visitor status is %s", pass.Status))
+		return
+	}
+
+	var miningSite sql.NullString
+	database.DB.QueryRow("SELECT mining_site FROM users WHERE user_id = $1", pass.VisitorID).Scan(&miningSite)
+
+	// Badges show the site's local time
+	loc := userLocation(pass.VisitorID)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"badge": map[string]interface{}{
+			"badgeNumber":    pass.BadgeNumber,
+			"visitorName":    pass.Name,
+			"company":        pass.Company,
+			"purpose":        pass.Purpose,
+			"hostName":       pass.HostName,
+			"miningSite":     miningSite.String,
+			"inductionTitle": pass.InductionTitle,
+			"inductedAt":     pass.InductedAt,
+			"validFrom":      pass.ValidFrom.In(loc).Format("2006-01-02 15:04 MST"),
+			"validUntil":     pass.ValidUntil.In(loc).Format("2006-01-02 15:04 MST"),
+			"qrPayload":      fmt.Sprintf("MINESAFE-VISITOR:%s:%d", pass.BadgeNumber, pass.ValidUntil.Unix()),
+		},
+		"generatedAt": time.Now().In(loc).Format("2006-01-02 15:04:05 MST"),
+	})
+}
+
+// RevokeVisitor - Revoke a visitor pass before it expires
+// DELETE /api/supervisor/visitors/{id}
+func RevokeVisitor(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	passID := vars["id"]
+
+	var visitorID string
+	err := database.DB.QueryRow(`
+		UPDATE visitor_passes SET status = $1
+		WHERE id = $2 AND host_supervisor_id = $3
+		RETURNING user_id
+	`, VisitorStatusRevoked, passID, supervisorID).Scan(&visitorID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Visitor not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	database.DB.Exec("UPDATE users SET is_active = false, updated_at = NOW() WHERE user_id = $1", visitorID)
+	if err := revokeUserTokens(visitorID, supervisorID, "visitor_revoked", time.Now()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Visitor pass revoked but sessions could not be revoked: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Visitor pass revoked",
+	})
+}
+
+// GetMyVisitorPass - Visitor views their own pass and induction status
+// GET /api/app/visitor/pass
+func GetMyVisitorPass(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pass, err := scanVisitorPass(database.DB.QueryRow(visitorPassSelect+`
+		WHERE vp.user_id = $1
+		ORDER BY vp.created_at DESC
+		LIMIT 1
+	`, userID))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No visitor pass found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"pass":               pass,
+		"induction_required": pass.Status == VisitorStatusPendingInduction,
+		"pass_score_percent": visitorInductionPassPercent,
+	})
+}
+
+// activeVisitorPassExpiry returns when the visitor's current pass ends, or an error
+// if the visitor has no pass that is valid right now
+func activeVisitorPassExpiry(userID string) (time.Time, error) {
+	var validUntil time.Time
+	err := database.DB.QueryRow(`
+		SELECT valid_until FROM visitor_passes
+		WHERE user_id = $1 AND status IN ($2, $3)
+		AND valid_from <= NOW() AND valid_until > NOW()
+		ORDER BY valid_until DESC
+		LIMIT 1
+	`, userID, VisitorStatusPendingInduction, VisitorStatusInducted).Scan(&validUntil)
+	return validUntil, err
+}
+
+// recordVisitorInduction marks a visitor as inducted once they pass the quiz for their induction video
+func recordVisitorInduction(userID string, videoID, score, totalQuestions int) {
+	if totalQuestions == 0 {
+		return
+	}
+	if float64(score)/float64(totalQuestions)*100 < visitorInductionPassPercent {
+		return
+	}
+	_, err := database.DB.Exec(`
+		UPDATE visitor_passes SET status = $1, inducted_at = NOW()
+		WHERE user_id = $2 AND induction_video_id = $3 AND status = $4
+	`, VisitorStatusInducted, userID, videoID, VisitorStatusPendingInduction)
+	if err != nil {
+		log.Printf("Warning: failed to record visitor induction for %s: %v", userID, err)
+	}
+}
+
+// StartVisitorExpiryJob periodically expires visitor passes past their validity window
+// and deactivates the temporary accounts behind them
+func StartVisitorExpiryJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			expireVisitorPasses()
+			<-ticker.C
+		}
+	}()
+}
+
+func expireVisitorPasses() {
+	result, err := database.DB.Exec(`
+		UPDATE visitor_passes SET status = $1
+		WHERE valid_until <= NOW() AND status IN ($2, $3)
+	`, VisitorStatusExpired, VisitorStatusPendingInduction, VisitorStatusInducted)
+	if err != nil {
+		log.Printf("Warning: visitor expiry job failed: %v", err)
+		return
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE users SET is_active = false, updated_at = NOW()
+		WHERE role = 'VISITOR' AND is_active = true
+		AND NOT EXISTS (
+			SELECT 1 FROM visitor_passes vp
+			WHERE vp.user_id = users.user_id AND vp.status IN ($1, $2)
+		)
+	`, VisitorStatusPendingInduction, VisitorStatusInducted)
+	if err != nil {
+		log.Printf("Warning: visitor account deactivation failed: %v", err)
+		return
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		log.Printf("Expired %d visitor passes", rowsAffected)
+	}
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== WEARABLES ====================
+
+// Wearables (smart watches, vests) post heart rate readings and fall events for the miner
+// they are issued to. Each device authenticates with its own API key, minted when an admin
+// registers it. A heart rate outside the site's range alerts the supervisor; a fall asks the
+// miner to dismiss it and raises an SOS for them if nobody does within the site's timeout.
+
+// Wearable reading types
+const (
+	WearableHeartRate = "HEART_RATE"
+	WearableFall      = "FALL"
+)
+
+// deviceKeyHeader carries a wearable's or zone sensor's API key
+const deviceKeyHeader = "X-API-Key"
+
+// maxWearableReadings bounds one upload; devices buffer while out of coverage
+const maxWearableReadings = 500
+
+// heartRateAlertInterval keeps a miner's sustained abnormal heart rate from alerting every reading
+const heartRateAlertInterval = 15 * time.Minute
+
+// Wearable is a registered wearable device
+type Wearable struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	MiningSite string     `json:"mining_site"`
+	UserID     string     `json:"user_id,omitempty"`
+	UserName   string     `json:"user_name,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// WearableRequest represents the request body for registering or reassigning a wearable
+type WearableRequest struct {
+	Name       string  `json:"name"`
+	MiningSite string  `json:"mining_site"`
+	UserID     *string `json:"user_id"` // the miner wearing it; empty to unassign
+}
+
+// hashDeviceKey is how wearable and zone sensor API keys are stored
+func hashDeviceKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// wearableAssignable checks the miner is in the caller's organization and at the device's site
+func wearableAssignable(r *http.Request, userID, site string) bool {
+	args := []interface{}{userID, site}
+	var ok bool
+	database.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND deleted_at IS NULL AND LOWER(mining_site) = LOWER(TRIM($2))`+
+		tenantClause(r, "org_id", &args)+`)`, args...).Scan(&ok)
+	return ok
+}
+
+// CreateWearable - Register a wearable; the API key it authenticates with is only returned here
+// POST /api/admin/wearables
+func CreateWearable(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req WearableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.MiningSite == "" {
+		respondWithError(w, http.StatusBadRequest, "name and mining_site are required")
+		return
+	}
+	if inOrg, err := siteInOrganization(r, req.MiningSite); err != nil || !inOrg {
+		respondWithError(w, http.StatusBadRequest, "Unknown mining site")
+		return
+	}
+	userID := ""
+	if req.UserID != nil {
+		userID = strings.TrimSpace(*req.UserID)
+	}
+	if userID != "" && !wearableAssignable(r, userID, req.MiningSite) {
+		respondWithError(w, http.StatusBadRequest, "user_id must be a miner at the same site")
+		return
+	}
+
+	key, err := generateRandomHex(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating API key")
+		return
+	}
+	var id int
+	err = database.DB.QueryRow(`
+		INSERT INTO wearable_devices (name, mining_site, user_id, key_hash, created_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id
+	`, truncate(req.Name, 255), req.MiningSite, userID, hashDeviceKey(key), adminID).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error registering wearable: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"api_key": key,
+		"message": "Configure the device to send this key in " + deviceKeyHeader + "; it won't be shown again",
+	})
+}
+
+// GetWearables - Registered wearables and who wears them
+// GET /api/admin/wearables?mining_site=North%20Pit
+func GetWearables(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{}
+	where := " WHERE d.revoked_at IS NULL" + tenantClause(r, "d.org_id", &args)
+	if site := r.URL.Query().Get("mining_site"); site != "" {
+		args = append(args, site)
+		where += " AND LOWER(d.mining_site) = LOWER(TRIM($" + strconv.Itoa(len(args)) + "))"
+	}
+	rows, err := database.DB.Query(`
+		SELECT d.id, d.name, d.mining_site, COALESCE(d.user_id, ''), COALESCE(u.name, ''), d.created_at, d.last_seen_at
+		FROM wearable_devices d
+		LEFT JOIN users u ON u.user_id = d.user_id`+where+`
+		ORDER BY d.mining_site, d.name`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	wearables := []Wearable{}
+	for rows.Next() {
+		var d Wearable
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&d.ID, &d.Name, &d.MiningSite, &d.UserID, &d.UserName, &d.CreatedAt, &lastSeen); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		if lastSeen.Valid {
+			d.LastSeenAt = &lastSeen.Time
+		}
+		wearables = append(wearables, d)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"wearables": wearables,
+	})
+}
+
+// AssignWearable - Hand a wearable to another miner at its site, or unassign it
+// PUT /api/admin/wearables/{id}
+func AssignWearable(w http.ResponseWriter, r *http.Request) {
+	var req WearableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == nil {
+		respondWithError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	userID := strings.TrimSpace(*req.UserID)
+
+	args := []interface{}{mux.Vars(r)["id"]}
+	scope := tenantClause(r, "org_id", &args)
+	var site string
+	err := database.DB.QueryRow("SELECT mining_site FROM wearable_devices WHERE id = $1 AND revoked_at IS NULL"+scope, args...).Scan(&site)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Wearable not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if userID != "" && !wearableAssignable(r, userID, site) {
+		respondWithError(w, http.StatusBadRequest, "user_id must be a miner at the same site")
+		return
+	}
+
+	if _, err := database.DB.Exec("UPDATE wearable_devices SET user_id = NULLIF($2, '') WHERE id = $1",
+		mux.Vars(r)["id"], userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Wearable assignment updated",
+	})
+}
+
+// RevokeWearable - Revoke a lost or retired wearable's API key
+// DELETE /api/admin/wearables/{id}
+func RevokeWearable(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{mux.Vars(r)["id"]}
+	scope := tenantClause(r, "org_id", &args)
+	result, err := database.DB.Exec("UPDATE wearable_devices SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL"+scope, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Wearable not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Wearable revoked",
+	})
+}
+
+// WearableReading is one heart rate sample or fall event from a wearable
+type WearableReading struct {
+	Type       string    `json:"type"`
+	HeartRate  int       `json:"heart_rate"`
+	Latitude   *float64  `json:"latitude"`
+	Longitude  *float64  `json:"longitude"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// IngestWearableReadings - Heart rate samples and fall events from a wearable, authenticated by
+// its API key in X-API-Key. Readings are attributed to the miner wearing the device.
+// POST /api/sensors/wearables/readings
+func IngestWearableReadings(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get(deviceKeyHeader)
+	if key == "" {
+		respondWithError(w, http.StatusUnauthorized, "API key required")
+		return
+	}
+	var deviceID int
+	var userID string
+	var siteID sql.NullInt64
+	var lastAlert sql.NullTime
+	err := database.DB.QueryRow(`
+		UPDATE wearable_devices SET last_seen_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, COALESCE(user_id, ''), site_id, last_heart_rate_alert_at
+	`, hashDeviceKey(key)).Scan(&deviceID, &userID, &siteID, &lastAlert)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var req struct {
+		Readings []WearableReading `json:"readings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.Readings) == 0 || len(req.Readings) > maxWearableReadings {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Send between 1 and %d readings", maxWearableReadings))
+		return
+	}
+	if userID == "" {
+		// Nobody to attribute the readings to; accept them so the device doesn't retry forever
+		respondWithJSON(w, http.StatusAccepted, map[string]interface{}{"accepted": 0, "message": "Wearable is not assigned to a miner"})
+		return
+	}
+
+	settings := defaultSiteSettings()
+	if siteID.Valid {
+		if s, err := loadSiteSettings(int(siteID.Int64)); err == nil {
+			settings = s
+		}
+	}
+
+	now := time.Now()
+	accepted, falls := 0, []int{}
+	var abnormal *WearableReading
+	for i := range req.Readings {
+		reading := &req.Readings[i]
+		reading.Type = strings.ToUpper(reading.Type)
+		if reading.RecordedAt.IsZero() || reading.RecordedAt.After(now.Add(time.Minute)) {
+			reading.RecordedAt = now
+		}
+		switch reading.Type {
+		case WearableHeartRate:
+			if reading.HeartRate <= 0 || reading.HeartRate > 300 {
+				continue
+			}
+			if (reading.HeartRate < settings.HeartRateMin || reading.HeartRate > settings.HeartRateMax) &&
+				(abnormal == nil || reading.RecordedAt.After(abnormal.RecordedAt)) {
+				abnormal = reading
+			}
+		case WearableFall:
+		default:
+			continue
+		}
+
+		_, err := database.DB.Exec(`
+			INSERT INTO wearable_readings (device_id, user_id, reading_type, heart_rate, latitude, longitude, recorded_at)
+			VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7)
+		`, deviceID, userID, reading.Type, reading.HeartRate, reading.Latitude, reading.Longitude, reading.RecordedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error saving readings")
+			return
+		}
+		accepted++
+
+		// Falls buffered for longer than the confirm window are raised on the watchdog's next pass
+		if reading.Type == WearableFall {
+			var fallID int
+			err := database.DB.QueryRow(`
+				INSERT INTO wearable_falls (device_id, user_id, latitude, longitude, detected_at, sos_due_at)
+				VALUES ($1, $2, $3, $4, $5, $5 + $6 * INTERVAL '1 second')
+				RETURNING id
+			`, deviceID, userID, reading.Latitude, reading.Longitude, reading.RecordedAt, settings.FallConfirmSeconds).Scan(&fallID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error saving fall event")
+				return
+			}
+			falls = append(falls, fallID)
+			notifyUser(userID, "WEARABLE_FALL", "Fall detected",
+				fmt.Sprintf("Are you OK? Dismiss this within %d seconds or an SOS will be raised for you", settings.FallConfirmSeconds),
+				"wearable_fall", strconv.Itoa(fallID))
+		}
+	}
+
+	if abnormal != nil && (!lastAlert.Valid || now.Sub(lastAlert.Time) >= heartRateAlertInterval) {
+		database.DB.Exec("UPDATE wearable_devices SET last_heart_rate_alert_at = NOW() WHERE id = $1", deviceID)
+		var name, supervisorID string
+		database.DB.QueryRow("SELECT name, COALESCE(supervisor_id, '') FROM users WHERE user_id = $1", userID).Scan(&name, &supervisorID)
+		notifyUser(supervisorID, "WEARABLE_HEART_RATE", "Abnormal heart rate",
+			fmt.Sprintf("%s's heart rate was %d bpm at %s UTC (site range %d-%d)", name, abnormal.HeartRate,
+				abnormal.RecordedAt.UTC().Format("15:04"), settings.HeartRateMin, settings.HeartRateMax),
+			"user", userID)
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"accepted": accepted,
+		"falls":    falls,
+	})
+}
+
+// DismissWearableFall - The miner confirms they are OK after a detected fall, cancelling the SOS
+// POST /api/app/wearables/falls/{id}/dismiss
+func DismissWearableFall(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE wearable_falls SET dismissed_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND dismissed_at IS NULL AND raised_at IS NULL
+	`, mux.Vars(r)["id"], userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusConflict, "Fall not found, already dismissed or SOS already raised")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Glad you're OK. The SOS was cancelled.",
+	})
+}
+
+// StartWearableFallJob periodically raises an SOS for falls nobody dismissed in time
+func StartWearableFallJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			raiseUndismissedFalls()
+			<-ticker.C
+		}
+	}()
+}
+
+func raiseUndismissedFalls() {
+	// Claiming the falls in the same statement keeps each one to a single SOS across instances
+	rows, err := database.DB.Query(`
+		UPDATE wearable_falls SET raised_at = NOW()
+		WHERE raised_at IS NULL AND dismissed_at IS NULL AND sos_due_at < NOW()
+		RETURNING id, user_id, detected_at, latitude, longitude
+	`)
+	if err != nil {
+		log.Printf("Warning: wearable fall job failed: %v", err)
+		return
+	}
+	type fall struct {
+		id         int
+		userID     string
+		detectedAt time.Time
+		lat, lon   sql.NullFloat64
+	}
+	var falls []fall
+	for rows.Next() {
+		var f fall
+		if err := rows.Scan(&f.id, &f.userID, &f.detectedAt, &f.lat, &f.lon); err == nil {
+			falls = append(falls, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range falls {
+		issue := fmt.Sprintf("Fall detected by wearable at %s UTC and not dismissed", f.detectedAt.UTC().Format("15:04"))
+		emergencyID, err := raiseEmergencyFor(f.userID, "HIGH", issue, f.lat.Float64, f.lon.Float64)
+		if err != nil {
+			// Release the claim so the next pass tries again
+			log.Printf("Warning: failed to raise SOS for wearable fall %d: %v", f.id, err)
+			database.DB.Exec("UPDATE wearable_falls SET raised_at = NULL WHERE id = $1", f.id)
+			continue
+		}
+		database.DB.Exec("UPDATE wearable_falls SET emergency_id = $2 WHERE id = $1", f.id, emergencyID)
+		notifyCrewLeads(f.userID, "WEARABLE_FALL_SOS", "Fall detected",
+			"%s's wearable detected a fall that wasn't dismissed; emergency #%d raised", emergencyID)
+	}
+}
@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"MineSafeBackend/database"
+	"MineSafeBackend/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== ZONE ENVIRONMENT ====================
+
+// Gas, dust, temperature and airflow sensors installed in a zone post readings with their own
+// API key, minted when an admin registers the sensor. A metric outside its limit opens a breach
+// for the zone, which alerts the zone's supervisor and site admins and stays open until a
+// reading is back within the limit. Supervisors get a per-zone dashboard of latest values,
+// 24 hour trends and breaches, with a risk score drawn from the zone's breach history.
+
+// maxSensorReadings bounds one upload; sensors buffer while the network is down
+const maxSensorReadings = 500
+
+// environmentStaleAfter is how old a zone's latest reading of a metric can be before the
+// dashboard flags the sensor as silent
+const environmentStaleAfter = 15 * time.Minute
+
+// breachMergeWindow joins a breach to the previous one of the same metric when the value
+// dipped back within the limit only briefly, so a reading hovering at the limit alerts once
+const breachMergeWindow = 15 * time.Minute
+
+// riskWindowDays is how far back breaches count towards a zone's risk score
+const riskWindowDays = 30
+
+// Breach directions
+const (
+	BreachHigh = "HIGH"
+	BreachLow  = "LOW"
+)
+
+// environmentMetric is a measured quantity and the range it must stay within
+type environmentMetric struct {
+	Metric string   `json:"metric"`
+	Unit   string   `json:"unit"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+func envLimit(v float64) *float64 {
+	return &v
+}
+
+// environmentMetrics are the metrics sensors may report, with exposure and ventilation limits
+var environmentMetrics = []environmentMetric{
+	{Metric: "CH4", Unit: "% vol", Max: envLimit(1.0)},
+	{Metric: "CO", Unit: "ppm", Max: envLimit(25)},
+	{Metric: "H2S", Unit: "ppm", Max: envLimit(10)},
+	{Metric: "O2", Unit: "% vol", Min: envLimit(19.5), Max: envLimit(23.5)},
+	{Metric: "DUST", Unit: "mg/m3", Max: envLimit(1.5)},
+	{Metric: "TEMPERATURE", Unit: "C", Max: envLimit(32)},
+	{Metric: "AIRFLOW", Unit: "m/s", Min: envLimit(0.3)},
+}
+
+func findEnvironmentMetric(name string) (environmentMetric, bool) {
+	for _, m := range environmentMetrics {
+		if m.Metric == name {
+			return m, true
+		}
+	}
+	return environmentMetric{}, false
+}
+
+// breach reports whether value is outside the metric's range and which limit it crossed
+func (m environmentMetric) breach(value float64) (direction string, threshold float64, breached bool) {
+	if m.Max != nil && value > *m.Max {
+		return BreachHigh, *m.Max, true
+	}
+	if m.Min != nil && value < *m.Min {
+		return BreachLow, *m.Min, true
+	}
+	return "", 0, false
+}
+
+// ZoneSensor is a registered environmental sensor
+type ZoneSensor struct {
+	ID         int        `json:"id"`
+	ZoneID     int        `json:"zone_id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// CreateZoneSensor - Register a sensor in a zone; the API key it authenticates with is only
+// returned here
+// POST /api/admin/zones/{id}/sensors
+func CreateZoneSensor(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	args := []interface{}{mux.Vars(r)["id"]}
+	var zoneID int
+	err := database.DB.QueryRow("SELECT id FROM mine_zones WHERE id = $1 AND is_active = true"+
+		tenantClause(r, "org_id", &args), args...).Scan(&zoneID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	key, err := generateRandomHex(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating API key")
+		return
+	}
+	var id int
+	err = database.DB.QueryRow(`
+		INSERT INTO zone_sensors (zone_id, name, key_hash, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, zoneID, truncate(req.Name, 255), hashDeviceKey(key), adminID).Scan(&id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error registering sensor: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"api_key": key,
+		"message": "Configure the sensor to send this key in " + deviceKeyHeader + "; it won't be shown again",
+	})
+}
+
+// GetZoneSensors - Sensors registered in a zone
+// GET /api/admin/zones/{id}/sensors
+func GetZoneSensors(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{mux.Vars(r)["id"]}
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.zone_id, s.name, s.created_at, s.last_seen_at
+		FROM zone_sensors s
+		JOIN mine_zones z ON z.id = s.zone_id
+		WHERE s.zone_id = $1 AND s.revoked_at IS NULL`+tenantClause(r, "z.org_id", &args)+`
+		ORDER BY s.name`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	sensors := []ZoneSensor{}
+	for rows.Next() {
+		var s ZoneSensor
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&s.ID, &s.ZoneID, &s.Name, &s.CreatedAt, &lastSeen); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		if lastSeen.Valid {
+			s.LastSeenAt = &lastSeen.Time
+		}
+		sensors = append(sensors, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"sensors": sensors,
+	})
+}
+
+// RevokeZoneSensor - Revoke a removed or replaced sensor's API key
+// DELETE /api/admin/zone-sensors/{id}
+func RevokeZoneSensor(w http.ResponseWriter, r *http.Request) {
+	args := []interface{}{mux.Vars(r)["id"]}
+	result, err := database.DB.Exec(`
+		UPDATE zone_sensors s SET revoked_at = NOW()
+		FROM mine_zones z
+		WHERE s.id = $1 AND z.id = s.zone_id AND s.revoked_at IS NULL`+tenantClause(r, "z.org_id", &args), args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Sensor not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Sensor revoked",
+	})
+}
+
+// SensorReading is one measurement from a zone sensor
+type SensorReading struct {
+	Metric     string    `json:"metric"`
+	Value      float64   `json:"value"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// IngestZoneSensorReadings - Measurements from a zone sensor, authenticated by its API key in
+// X-API-Key. Metrics: CH4, CO, H2S, O2, DUST, TEMPERATURE, AIRFLOW.
+// POST /api/sensors/zones/readings
+func IngestZoneSensorReadings(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get(deviceKeyHeader)
+	if key == "" {
+		respondWithError(w, http.StatusUnauthorized, "API key required")
+		return
+	}
+	var sensorID, zoneID int
+	var zoneName, site, zoneOwner string
+	err := database.DB.QueryRow(`
+		UPDATE zone_sensors s SET last_seen_at = NOW()
+		FROM mine_zones z
+		WHERE s.key_hash = $1 AND s.revoked_at IS NULL AND z.id = s.zone_id
+		RETURNING s.id, s.zone_id, z.name, COALESCE(z.mining_site, ''), COALESCE(z.created_by, '')
+	`, hashDeviceKey(key)).Scan(&sensorID, &zoneID, &zoneName, &site, &zoneOwner)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var req struct {
+		Readings []SensorReading `json:"readings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.Readings) == 0 || len(req.Readings) > maxSensorReadings {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Send between 1 and %d readings", maxSensorReadings))
+		return
+	}
+
+	// Breaches open and close in the order things happened, not the order they were buffered
+	now := time.Now()
+	for i := range req.Readings {
+		reading := &req.Readings[i]
+		reading.Metric = strings.ToUpper(strings.TrimSpace(reading.Metric))
+		if reading.RecordedAt.IsZero() || reading.RecordedAt.After(now.Add(time.Minute)) {
+			reading.RecordedAt = now
+		}
+	}
+	sort.SliceStable(req.Readings, func(i, j int) bool {
+		return req.Readings[i].RecordedAt.Before(req.Readings[j].RecordedAt)
+	})
+
+	accepted := 0
+	opened := []SensorReading{}
+	for _, reading := range req.Readings {
+		metric, known := findEnvironmentMetric(reading.Metric)
+		if !known || math.IsNaN(reading.Value) || math.IsInf(reading.Value, 0) {
+			continue
+		}
+		_, err := database.DB.Exec(`
+			INSERT INTO zone_environment_readings (sensor_id, zone_id, metric, value, recorded_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, sensorID, zoneID, metric.Metric, reading.Value, reading.RecordedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error saving readings")
+			return
+		}
+		accepted++
+
+		isNew, err := trackEnvironmentBreach(zoneID, metric, reading.Value, reading.RecordedAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating breaches")
+			return
+		}
+		if isNew {
+			opened = append(opened, reading)
+		}
+	}
+
+	if len(opened) > 0 {
+		recipients := append([]string{zoneOwner}, siteAdmins(site)...)
+		for _, reading := range opened {
+			metric, _ := findEnvironmentMetric(reading.Metric)
+			_, threshold, _ := metric.breach(reading.Value)
+			message := fmt.Sprintf("%s reached %g %s in %s at %s UTC (limit %g %s)", metric.Metric, reading.Value,
+				metric.Unit, zoneName, reading.RecordedAt.UTC().Format("15:04"), threshold, metric.Unit)
+			notified := map[string]bool{}
+			for _, userID := range recipients {
+				if notified[userID] {
+					continue
+				}
+				notified[userID] = true
+				notifyUser(userID, "ZONE_ENVIRONMENT_BREACH", "Air quality alert", message, "zone", strconv.Itoa(zoneID))
+			}
+		}
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"accepted": accepted,
+		"breaches": len(opened),
+	})
+}
+
+// trackEnvironmentBreach opens, extends or closes the zone's breach of a metric for a reading.
+// It reports true when the reading started a new breach.
+func trackEnvironmentBreach(zoneID int, metric environmentMetric, value float64, at time.Time) (bool, error) {
+	direction, threshold, breached := metric.breach(value)
+	if !breached {
+		_, err := database.DB.Exec(`
+			UPDATE zone_environment_breaches SET ended_at = $3
+			WHERE zone_id = $1 AND metric = $2 AND ended_at IS NULL AND started_at <= $3
+		`, zoneID, metric.Metric, at)
+		return false, err
+	}
+
+	// Still breaching, or back over the limit shortly after it ended: carry on the same breach
+	result, err := database.DB.Exec(`
+		UPDATE zone_environment_breaches SET ended_at = NULL,
+			peak_value = CASE WHEN direction = $4 THEN GREATEST(peak_value, $3) ELSE LEAST(peak_value, $3) END
+		WHERE id = (SELECT id FROM zone_environment_breaches
+		            WHERE zone_id = $1 AND metric = $2
+		            AND (ended_at IS NULL OR ended_at >= $5::timestamptz - $6 * INTERVAL '1 second')
+		            ORDER BY ended_at DESC NULLS FIRST LIMIT 1)
+	`, zoneID, metric.Metric, value, BreachHigh, at, breachMergeWindow.Seconds())
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return false, nil
+	}
+
+	result, err = database.DB.Exec(`
+		INSERT INTO zone_environment_breaches (zone_id, metric, direction, threshold, peak_value, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (zone_id, metric) WHERE ended_at IS NULL DO NOTHING
+	`, zoneID, metric.Metric, direction, threshold, value, at)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// zoneRiskScore rates a zone from 0 to 100 on its breaches over the last riskWindowDays. A
+// breach weighs more the longer it lasted and the more recently it started; open ones most.
+func zoneRiskScore(zoneID int) (int, error) {
+	rows, err := database.DB.Query(`
+		SELECT EXTRACT(EPOCH FROM COALESCE(ended_at, NOW()) - started_at) / 3600,
+		       EXTRACT(EPOCH FROM NOW() - started_at) / 86400,
+		       ended_at IS NULL
+		FROM zone_environment_breaches
+		WHERE zone_id = $1 AND COALESCE(ended_at, NOW()) >= NOW() - $2 * INTERVAL '1 day'
+	`, zoneID, riskWindowDays)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	score := 0.0
+	for rows.Next() {
+		var hours, ageDays float64
+		var open bool
+		if err := rows.Scan(&hours, &ageDays, &open); err != nil {
+			return 0, err
+		}
+		weight := 10 + 2*math.Min(hours, 10)
+		if open {
+			weight += 20
+		}
+		// A breach from the start of the window counts a quarter as much as one from today
+		score += weight * (1 - 0.75*math.Min(math.Max(ageDays, 0), riskWindowDays)/riskWindowDays)
+	}
+	return int(math.Min(100, math.Round(score))), nil
+}
+
+func riskLevel(score int) string {
+	switch {
+	case score >= 75:
+		return "CRITICAL"
+	case score >= 50:
+		return "HIGH"
+	case score >= 25:
+		return "MODERATE"
+	}
+	return "LOW"
+}
+
+// EnvironmentReading is the latest value of a metric in a zone
+type EnvironmentReading struct {
+	Metric     string    `json:"metric"`
+	Unit       string    `json:"unit"`
+	Value      float64   `json:"value"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Status     string    `json:"status"` // OK, BREACH or STALE
+}
+
+// EnvironmentTrendPoint summarizes a metric over one hour
+type EnvironmentTrendPoint struct {
+	Hour time.Time `json:"hour"`
+	Min  float64   `json:"min"`
+	Avg  float64   `json:"avg"`
+	Max  float64   `json:"max"`
+}
+
+// EnvironmentBreach is a period a zone's metric spent outside its limit
+type EnvironmentBreach struct {
+	ID        int        `json:"id"`
+	Metric    string     `json:"metric"`
+	Direction string     `json:"direction"`
+	Threshold float64    `json:"threshold"`
+	PeakValue float64    `json:"peak_value"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Open      bool       `json:"open"`
+}
+
+// GetZoneEnvironment - Latest values, hourly trends over the last 24 hours, breaches that are
+// open or ended in that time, and the zone's risk score
+// GET /api/supervisor/zones/{id}/environment
+func GetZoneEnvironment(w http.ResponseWriter, r *http.Request) {
+	supervisorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid zone id")
+		return
+	}
+
+	zoneName, err := supervisorZone(r, supervisorID, zoneID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT ON (metric) metric, value, recorded_at
+		FROM zone_environment_readings
+		WHERE zone_id = $1
+		ORDER BY metric, recorded_at DESC
+	`, zoneID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	latestByMetric := map[string]EnvironmentReading{}
+	for rows.Next() {
+		var reading EnvironmentReading
+		if err := rows.Scan(&reading.Metric, &reading.Value, &reading.RecordedAt); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		latestByMetric[reading.Metric] = reading
+	}
+	rows.Close()
+
+	latest := []EnvironmentReading{}
+	for _, metric := range environmentMetrics {
+		reading, ok := latestByMetric[metric.Metric]
+		if !ok {
+			continue
+		}
+		reading.Unit = metric.Unit
+		reading.Status = "OK"
+		if _, _, breached := metric.breach(reading.Value); breached {
+			reading.Status = "BREACH"
+		} else if time.Since(reading.RecordedAt) > environmentStaleAfter {
+			reading.Status = "STALE"
+		}
+		latest = append(latest, reading)
+	}
+
+	rows, err = database.DB.Query(`
+		SELECT metric, DATE_TRUNC('hour', recorded_at), MIN(value), AVG(value), MAX(value)
+		FROM zone_environment_readings
+		WHERE zone_id = $1 AND recorded_at >= NOW() - INTERVAL '24 hours'
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, zoneID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	trends := map[string][]EnvironmentTrendPoint{}
+	for rows.Next() {
+		var metric string
+		var point EnvironmentTrendPoint
+		if err := rows.Scan(&metric, &point.Hour, &point.Min, &point.Avg, &point.Max); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		trends[metric] = append(trends[metric], point)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT id, metric, direction, threshold, peak_value, started_at, ended_at
+		FROM zone_environment_breaches
+		WHERE zone_id = $1 AND (ended_at IS NULL OR ended_at >= NOW() - INTERVAL '24 hours')
+		ORDER BY started_at DESC
+	`, zoneID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	breaches := []EnvironmentBreach{}
+	for rows.Next() {
+		var b EnvironmentBreach
+		var endedAt sql.NullTime
+		if err := rows.Scan(&b.ID, &b.Metric, &b.Direction, &b.Threshold, &b.PeakValue, &b.StartedAt, &endedAt); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Error scanning data")
+			return
+		}
+		if endedAt.Valid {
+			b.EndedAt = &endedAt.Time
+		}
+		b.Open = !endedAt.Valid
+		breaches = append(breaches, b)
+	}
+	rows.Close()
+
+	score, err := zoneRiskScore(zoneID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"zone_id":    zoneID,
+		"zone_name":  zoneName,
+		"latest":     latest,
+		"trends":     trends,
+		"breaches":   breaches,
+		"limits":     environmentMetrics,
+		"risk_score": score,
+		"risk_level": riskLevel(score),
+	})
+}
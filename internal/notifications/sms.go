@@ -0,0 +1,227 @@
+// Package notifications sends SMS through Twilio or Amazon SNS. Both are called over their
+// HTTP APIs directly so no provider SDK is needed.
+package notifications
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SMS providers a sender can be built for
+const (
+	ProviderNone   = "none"
+	ProviderTwilio = "twilio"
+	ProviderSNS    = "sns"
+)
+
+// ErrNotConfigured is returned by NewSMSSender when no provider is set
+var ErrNotConfigured = errors.New("no SMS provider configured")
+
+// maxSMSLength keeps a message to a few concatenated segments
+const maxSMSLength = 480
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// SMSSender sends a text message to a phone number in E.164 format
+type SMSSender interface {
+	SendSMS(to, body string) error
+}
+
+// SMSConfig holds the credentials for whichever provider is selected
+type SMSConfig struct {
+	Provider string
+
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string
+
+	AWSRegion          string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	// SNSSenderID is the alphanumeric sender shown where carriers support it
+	SNSSenderID string
+}
+
+// NewSMSSender builds the sender for cfg.Provider
+func NewSMSSender(cfg SMSConfig) (SMSSender, error) {
+	switch cfg.Provider {
+	case ProviderTwilio:
+		return &TwilioSender{AccountSID: cfg.TwilioAccountSID, AuthToken: cfg.TwilioAuthToken, From: cfg.TwilioFrom}, nil
+	case ProviderSNS:
+		return &SNSSender{Region: cfg.AWSRegion, AccessKeyID: cfg.AWSAccessKeyID,
+			SecretAccessKey: cfg.AWSSecretAccessKey, SenderID: cfg.SNSSenderID}, nil
+	case "", ProviderNone:
+		return nil, ErrNotConfigured
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q", cfg.Provider)
+	}
+}
+
+// NormalizePhone strips spaces, dashes, dots and brackets from a number and reports whether
+// what is left is an E.164 number
+func NormalizePhone(phone string) (string, bool) {
+	phone = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, phone)
+	if len(phone) < 8 || len(phone) > 16 || phone[0] != '+' {
+		return phone, false
+	}
+	for _, r := range phone[1:] {
+		if r < '0' || r > '9' {
+			return phone, false
+		}
+	}
+	return phone, true
+}
+
+func truncateSMS(body string) string {
+	if runes := []rune(body); len(runes) > maxSMSLength {
+		return string(runes[:maxSMSLength-1]) + "…"
+	}
+	return body
+}
+
+// TwilioSender sends through Twilio's Messages API
+type TwilioSender struct {
+	AccountSID string
+	AuthToken  string
+	From       string // a Twilio number or messaging service SID
+}
+
+func (t *TwilioSender) SendSMS(to, body string) error {
+	form := url.Values{"To": {to}, "Body": {truncateSMS(body)}}
+	if strings.HasPrefix(t.From, "MG") {
+		form.Set("MessagingServiceSid", t.From)
+	} else {
+		form.Set("From", t.From)
+	}
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(t.AccountSID) + "/Messages.json"
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&apiErr)
+		return fmt.Errorf("twilio returned %s: %d %s", resp.Status, apiErr.Code, apiErr.Message)
+	}
+	return nil
+}
+
+// SNSSender sends through Amazon SNS Publish to a phone number, signing requests with
+// Signature Version 4
+type SNSSender struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SenderID        string
+}
+
+func (s *SNSSender) SendSMS(to, body string) error {
+	form := url.Values{
+		"Action":      {"Publish"},
+		"Version":     {"2010-03-31"},
+		"PhoneNumber": {to},
+		"Message":     {truncateSMS(body)},
+		// Emergency texts go out as transactional so carriers prioritise them
+		"MessageAttributes.entry.1.Name":              {"AWS.SNS.SMS.SMSType"},
+		"MessageAttributes.entry.1.Value.DataType":    {"String"},
+		"MessageAttributes.entry.1.Value.StringValue": {"Transactional"},
+	}
+	if s.SenderID != "" {
+		form.Set("MessageAttributes.entry.2.Name", "AWS.SNS.SMS.SenderID")
+		form.Set("MessageAttributes.entry.2.Value.DataType", "String")
+		form.Set("MessageAttributes.entry.2.Value.StringValue", s.SenderID)
+	}
+	payload := form.Encode()
+
+	host := "sns." + s.Region + ".amazonaws.com"
+	req, err := http.NewRequest(http.MethodPost, "https://"+host+"/", strings.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	s.sign(req, host, payload, time.Now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sns returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// sign adds the SigV4 Authorization header for a form POST to SNS
+func (s *SNSSender) sign(req *http.Request, host, payload string, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         host,
+		"x-amz-date":   amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256([]byte(payload))
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost, "/", "", canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/sns/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "sns")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
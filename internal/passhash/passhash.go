@@ -0,0 +1,175 @@
+// Package passhash hashes and verifies passwords. Hashes record their algorithm and cost,
+// so stored hashes made with older settings keep verifying and can be replaced with one
+// made with the current settings the next time the user logs in.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms new hashes can be made with
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// ErrUnknownHash is returned for a stored hash no hasher recognises
+var ErrUnknownHash = errors.New("unrecognised password hash")
+
+// Hasher is one password hashing algorithm
+type Hasher interface {
+	// Hash hashes password with the hasher's current settings
+	Hash(password string) (string, error)
+	// Recognizes reports whether hash was made by this algorithm
+	Recognizes(hash string) bool
+	// Verify reports whether password matches hash
+	Verify(hash, password string) (bool, error)
+	// Outdated reports whether hash was made with different settings than Hash uses now
+	Outdated(hash string) bool
+}
+
+// BcryptHasher hashes with bcrypt at a fixed cost
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes with argon2id, stored in the PHC string format
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+type Argon2idHasher struct {
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+var b64 = base64.RawStdEncoding
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.MemoryKiB, h.Parallelism, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.MemoryKiB, h.Iterations, h.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// decode splits an argon2id hash into the settings it was made with, its salt and key
+func (h Argon2idHasher) decode(hash string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return params, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2 parameters: %w", err)
+	}
+	if salt, err = b64.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, err
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+func (h Argon2idHasher) Verify(hash, password string) (bool, error) {
+	params, salt, key, err := h.decode(hash)
+	if err != nil {
+		return false, err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+func (h Argon2idHasher) Outdated(hash string) bool {
+	params, _, key, err := h.decode(hash)
+	return err != nil || params != h || len(key) != argon2KeyLen
+}
+
+// supported verify stored hashes of every algorithm; verification reads the cost from the
+// hash itself, so their settings don't matter
+var supported = []Hasher{BcryptHasher{Cost: bcrypt.DefaultCost}, Argon2idHasher{}}
+
+var (
+	mu      sync.RWMutex
+	current Hasher = BcryptHasher{Cost: bcrypt.DefaultCost}
+)
+
+// Configure sets the hasher new hashes are made with. Hashes from every supported
+// algorithm keep verifying.
+func Configure(h Hasher) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = h
+}
+
+// Hash hashes password with the configured hasher
+func Hash(password string) (string, error) {
+	mu.RLock()
+	h := current
+	mu.RUnlock()
+	return h.Hash(password)
+}
+
+// Verify reports whether password matches hash and, when it does, whether hash should be
+// replaced with Hash(password) because it was made with another algorithm or cost
+func Verify(hash, password string) (ok, rehash bool) {
+	mu.RLock()
+	h := current
+	mu.RUnlock()
+	for _, candidate := range append([]Hasher{h}, supported...) {
+		if !candidate.Recognizes(hash) {
+			continue
+		}
+		matched, err := candidate.Verify(hash, password)
+		if err != nil || !matched {
+			return false, false
+		}
+		// Only the configured hasher's own hashes can be up to date
+		return true, candidate != h || h.Outdated(hash)
+	}
+	return false, false
+}
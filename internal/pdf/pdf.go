@@ -0,0 +1,207 @@
+// Package pdf writes simple text-and-rule PDF documents using the standard
+// Helvetica fonts, which every PDF viewer ships with, so nothing is embedded.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	A4Width  = 595.0
+	A4Height = 842.0
+)
+
+// Document is a PDF being built one page at a time. Coordinates are in points
+// measured from the top-left corner of the page.
+type Document struct {
+	width, height float64
+	pages         []*bytes.Buffer
+	current       int
+	images        []image.Image
+}
+
+// Color is an RGB color
+type Color struct {
+	R, G, B uint8
+}
+
+// Black is the color every page starts with
+var Black = Color{}
+
+// HexColor parses a #RRGGBB color
+func HexColor(s string) (Color, bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return Color{}, false
+	}
+	rgb, err := hex.DecodeString(s[1:])
+	if err != nil {
+		return Color{}, false
+	}
+	return Color{rgb[0], rgb[1], rgb[2]}, true
+}
+
+// New starts an empty document with pages of the given size
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height, current: -1}
+}
+
+// AddPage appends a blank page and makes it the one drawn on
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.current = len(d.pages) - 1
+}
+
+// PageCount is the number of pages added so far
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// SetPage switches drawing to an earlier page, e.g. to add "page n of m" footers
+func (d *Document) SetPage(n int) {
+	if n >= 0 && n < len(d.pages) {
+		d.current = n
+	}
+}
+
+// Text draws s with its baseline at (x, y). Characters outside Latin-1 are printed as '?'.
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.height-y, escape(s))
+}
+
+// SetColor sets the color of text and filled boxes drawn after it on the current page
+func (d *Document) SetColor(c Color) {
+	fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg\n", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+// FillRect fills a w by h box whose top-left corner is (x, y) with the current color
+func (d *Document) FillRect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "%.2f %.2f %.2f %.2f re f\n", x, d.height-y-h, w, h)
+}
+
+// Image draws img scaled into the w by h box whose top-left corner is (x, y). Transparent
+// pixels are drawn as white.
+func (d *Document) Image(x, y, w, h float64, img image.Image) {
+	d.images = append(d.images, img)
+	fmt.Fprintf(d.page(), "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", w, h, x, d.height-y-h, len(d.images))
+}
+
+// Line draws a thin rule from (x1, y1) to (x2, y2)
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, d.height-y1, x2, d.height-y2)
+}
+
+// Rect draws the outline of a w by h box whose top-left corner is (x, y)
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f %.2f %.2f re S\n", x, d.height-y-h, w, h)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if d.current < 0 {
+		d.AddPage()
+	}
+	return d.pages[d.current]
+}
+
+// Bytes renders the finished document
+func (d *Document) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var out bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1-4 are fixed; each page then takes a page object and a content stream, and
+	// images follow the pages
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	xobjects := ""
+	for i := range d.images {
+		xobjects += fmt.Sprintf(" /Im%d %d 0 R", i+1, 5+len(d.pages)*2+i)
+	}
+	if xobjects != "" {
+		xobjects = " /XObject <<" + xobjects + " >>"
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >>%s >> /Contents %d 0 R >>",
+			d.width, d.height, xobjects, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+	for _, img := range d.images {
+		bounds := img.Bounds()
+		pixels := rgbPixels(img)
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			bounds.Dx(), bounds.Dy(), len(pixels), pixels))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// rgbPixels flattens img onto white and returns its rows of RGB bytes, deflated
+func rgbPixels(img image.Image) []byte {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	bounds := img.Bounds()
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Premultiplied 16-bit channels; add the uncovered share of white
+			r, g, b, a := img.At(x, y).RGBA()
+			white := 0xffff - a
+			row = append(row, byte((r+white)>>8), byte((g+white)>>8), byte((b+white)>>8))
+		}
+		zw.Write(row)
+	}
+	zw.Close()
+	return compressed.Bytes()
+}
+
+// escape encodes s as a Latin-1 PDF string literal body
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		case r >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
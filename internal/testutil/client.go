@@ -0,0 +1,135 @@
+package testutil
+
+import (
+	"MineSafeBackend/database"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Client sends requests straight to the API handler, optionally as a logged-in user
+type Client struct {
+	Handler http.Handler
+	Token   string
+	UserID  string
+	Role    string
+}
+
+// Response is a recorded API response
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+// NewClient returns an anonymous client for the handler
+func NewClient(handler http.Handler) *Client {
+	return &Client{Handler: handler}
+}
+
+// As returns a client that sends the given bearer token
+func (c *Client) As(token, userID, role string) *Client {
+	return &Client{Handler: c.Handler, Token: token, UserID: userID, Role: role}
+}
+
+// Do sends a request with an optional JSON body
+func (c *Client) Do(t testing.TB, method, path string, body interface{}) *Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler.ServeHTTP(rec, req)
+	return &Response{Status: rec.Code, Body: rec.Body.Bytes()}
+}
+
+// Expect fails the test unless the response has the given status
+func (r *Response) Expect(t testing.TB, status int) *Response {
+	t.Helper()
+	if r.Status != status {
+		t.Fatalf("expected status %d, got %d: %s", status, r.Status, r.Body)
+	}
+	return r
+}
+
+// JSON decodes the response body into v
+func (r *Response) JSON(t testing.TB, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decoding response %s: %v", r.Body, err)
+	}
+}
+
+// UniqueEmail returns an email address no other test will use
+func UniqueEmail(prefix string) string {
+	return prefix + "-" + uuid.New().String()[:8] + "@minesafe.test"
+}
+
+// UniquePhone returns an Indian mobile number no other test is likely to use; sign-up
+// refuses a phone number that is already registered
+func UniquePhone() string {
+	return fmt.Sprintf("+919%09d", uuid.New().ID()%1000000000)
+}
+
+// UniqueSite returns a mining site name no other test will use; signup refuses a site that
+// is already registered
+func UniqueSite(prefix string) string {
+	return prefix + " " + uuid.New().String()[:8]
+}
+
+type authResponse struct {
+	Token  string `json:"token"`
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// SignupSupervisor registers a new supervisor, marks their email verified (the verification
+// link is only ever emailed) and returns a client logged in as them
+func (c *Client) SignupSupervisor(t testing.TB, miningSite string) *Client {
+	t.Helper()
+	email, password := UniqueEmail("supervisor"), "supervisor-password"
+	var auth authResponse
+	c.Do(t, http.MethodPost, "/api/auth/signup", map[string]string{
+		"name":        "Test Supervisor",
+		"email":       email,
+		"phone":       UniquePhone(),
+		"password":    password,
+		"mining_site": miningSite,
+		"location":    "Test Location",
+	}).Expect(t, http.StatusCreated).JSON(t, &auth)
+
+	if _, err := database.DB.Exec("UPDATE users SET email_verified = true WHERE user_id = $1", auth.UserID); err != nil {
+		t.Fatalf("verifying supervisor email: %v", err)
+	}
+	return c.Login(t, email, password)
+}
+
+// Login logs in with email and password and returns a client for that user
+func (c *Client) Login(t testing.TB, email, password string) *Client {
+	t.Helper()
+	var auth authResponse
+	c.Do(t, http.MethodPost, "/api/auth/login", map[string]string{
+		"email":    email,
+		"password": password,
+	}).Expect(t, http.StatusOK).JSON(t, &auth)
+	return c.As(auth.Token, auth.UserID, auth.Role)
+}
@@ -0,0 +1,97 @@
+// Package testutil provides the integration test harness: a throwaway Postgres database
+// and helpers for making authenticated requests against the API router.
+package testutil
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+const (
+	defaultPostgresImage = "postgres:16-alpine"
+	postgresUser         = "minesafe"
+	postgresPassword     = "minesafe"
+	postgresDB           = "minesafe_test"
+	postgresStartTimeout = 60 * time.Second
+)
+
+// Postgres is a database started for a test run
+type Postgres struct {
+	DSN         string
+	containerID string
+}
+
+// StartPostgres returns a database for integration tests. TEST_DATABASE_URL is used when
+// set (e.g. a CI service container); otherwise a disposable container is started with
+// the docker CLI and removed again by Stop.
+func StartPostgres() (*Postgres, error) {
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		return &Postgres{DSN: dsn}, waitForPostgres(dsn)
+	}
+
+	image := os.Getenv("TEST_POSTGRES_IMAGE")
+	if image == "" {
+		image = defaultPostgresImage
+	}
+
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_USER="+postgresUser,
+		"-e", "POSTGRES_PASSWORD="+postgresPassword,
+		"-e", "POSTGRES_DB="+postgresDB,
+		"-p", "127.0.0.1::5432",
+		image,
+	).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("starting postgres container: %v: %s", err, out)
+	}
+	pg := &Postgres{containerID: strings.TrimSpace(string(out))}
+
+	out, err = exec.Command("docker", "port", pg.containerID, "5432/tcp").Output()
+	if err != nil {
+		pg.Stop()
+		return nil, fmt.Errorf("reading postgres container port: %v", err)
+	}
+	// docker port may print one line per address family; the first is enough
+	hostPort := strings.TrimSpace(strings.Split(string(out), "\n")[0])
+
+	pg.DSN = fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable",
+		postgresUser, postgresPassword, hostPort, postgresDB)
+	if err := waitForPostgres(pg.DSN); err != nil {
+		pg.Stop()
+		return nil, err
+	}
+	return pg, nil
+}
+
+// Stop removes the container, if one was started
+func (p *Postgres) Stop() {
+	if p.containerID != "" {
+		exec.Command("docker", "rm", "-f", p.containerID).Run()
+	}
+}
+
+func waitForPostgres(dsn string) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	deadline := time.Now().Add(postgresStartTimeout)
+	for {
+		err = db.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("postgres not ready after %s: %v", postgresStartTimeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
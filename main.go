@@ -1,69 +1,273 @@
 package main
 
 import (
+	"MineSafeBackend/config"
 	"MineSafeBackend/database"
 	"MineSafeBackend/handlers"
 	"MineSafeBackend/middleware"
+	"MineSafeBackend/perf"
+	"encoding/json"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
+	// Load and validate configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	// Hash new passwords with the configured algorithm; older hashes are upgraded at login
+	handlers.ConfigurePasswordHashing(cfg)
+
 	// Initialize database
 	if err := database.InitDB(); err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer database.CloseDB()
 
-	// Initialize JWT
-	middleware.InitJWT()
+	// "seed-demo" loads demo sites, users and history into a fresh staging database and exits
+	if len(os.Args) > 1 && os.Args[1] == "seed-demo" {
+		if cfg.IsProduction() {
+			log.Fatal("Refusing to load demo data with APP_ENV=production")
+		}
+		if err := database.SeedDemoData(); err != nil {
+			log.Fatal("Failed to load demo data: ", err)
+		}
+		return
+	}
+
+	// "seed-loadtest [miners]" creates the accounts the perf scenarios log in with and exits
+	if len(os.Args) > 1 && os.Args[1] == "seed-loadtest" {
+		if cfg.IsProduction() {
+			log.Fatal("Refusing to load load test data with APP_ENV=production")
+		}
+		miners := perf.MorningRush.VirtualUsers
+		if len(os.Args) > 2 {
+			if n, err := strconv.Atoi(os.Args[2]); err == nil && n > 0 {
+				miners = n
+			}
+		}
+		if err := database.SeedLoadTestMiners(miners); err != nil {
+			log.Fatal("Failed to load load test data: ", err)
+		}
+		return
+	}
+
+	// "restore-backup <file>" replaces the database with a backup taken from the dashboard
+	// (a name in BACKUP_DIR or a path) and exits. Stop the API first; it migrates the
+	// restored schema when it starts again.
+	if len(os.Args) > 1 && os.Args[1] == "restore-backup" {
+		if len(os.Args) < 3 {
+			log.Fatal("Usage: main restore-backup <backup file>")
+		}
+		file := os.Args[2]
+		if filepath.Base(file) == file {
+			file = filepath.Join(cfg.BackupDir, file)
+		}
+		if err := database.RestoreDatabase(file); err != nil {
+			log.Fatal("Failed to restore backup: ", err)
+		}
+		log.Printf("Restored %s", file)
+		return
+	}
+
+	// Build indexes and backfill large tables in the background without blocking the API
+	database.StartOnlineMigrations()
+
+	// Check storage, assets and external credentials; STRICT_STARTUP makes failures fatal
+	if report := handlers.RunStartupDiagnostics(); !report.Passed && cfg.StrictStartup {
+		log.Fatal("Startup diagnostics failed with STRICT_STARTUP enabled")
+	}
+
+	// Initialize JWT and load rotated signing keys
+	middleware.InitJWT(cfg.JWTSecret)
+	middleware.SetTokenPolicy(map[string]time.Duration{
+		middleware.AudienceDashboard: cfg.DashboardTokenTTL,
+		middleware.AudienceMobile:    cfg.MobileTokenTTL,
+	}, cfg.MinTokenVersion)
+	if err := handlers.LoadSigningKeys(); err != nil {
+		log.Printf("Warning: failed to load JWT signing keys, using JWT_SECRET only: %v", err)
+	}
+	handlers.StartSigningKeySync(time.Minute)
+	if cfg.SessionCookies {
+		middleware.EnableCookieSessions(middleware.CookieSessionConfig{
+			Domain:     cfg.SessionCookieDomain,
+			Secure:     cfg.IsProduction(),
+			CSRFSecret: []byte(cfg.JWTSecret),
+		})
+	}
+
+	// Announce when old API versions go away
+	if !cfg.APIV1Sunset.IsZero() {
+		middleware.SetAPISunset(middleware.APIVersion1, cfg.APIV1Sunset)
+	}
+
+	// Reject logged-out and revoked tokens
+	handlers.StartRevocationSync(time.Minute)
+
+	// Track when each signed-in device was last seen
+	handlers.StartSessionTracking(time.Hour)
+
+	// Scope every request to the caller's organization
+	handlers.InitTenancy()
+
+	// Load role permissions, adding any new ones with their default grants
+	if err := handlers.LoadPermissions(); err != nil {
+		log.Printf("Warning: failed to load role permissions, using built-in defaults: %v", err)
+	}
+	handlers.StartPermissionSync(time.Minute)
+
+	// Share rate limits, lockouts and cache invalidation between instances if configured
+	handlers.InitSharedState(cfg)
 
 	// Initialize rate limiter (100 requests per minute)
 	middleware.InitRateLimiter(100)
 
+	// Lock out accounts and IPs after repeated failed logins
+	middleware.InitLoginGuard()
+	handlers.InitLoginEventLog()
+
+	// Require a CAPTCHA token on public sign-up endpoints if a provider is configured
+	middleware.InitCaptcha(middleware.CaptchaConfig{
+		Provider:  cfg.CaptchaProvider,
+		Secret:    cfg.CaptchaSecret,
+		VerifyURL: cfg.CaptchaVerifyURL,
+	})
+
+	// Text HIGH and CRITICAL emergencies if an SMS provider is configured
+	handlers.InitSMS(cfg)
+
+	// Expire visitor passes past their validity window
+	handlers.StartVisitorExpiryJob(15 * time.Minute)
+
+	// Notify users and supervisors about expiring licenses and certificates
+	handlers.StartDocumentExpiryJob(6 * time.Hour)
+
+	// Keep the read-only maintenance flag in sync across instances
+	handlers.StartMaintenanceSync(15 * time.Second)
+
+	// Repair like/dislike/view/completion counters that drift under concurrent updates
+	handlers.StartCounterReconciliationJob(time.Hour)
+
+	// Recompute trending video engagement for the explore tab
+	handlers.StartTrendingRefreshJob(15 * time.Minute)
+
+	// Remind reporters to retry pending or failed emergency media, expire it after a day
+	handlers.StartMediaReconciliationJob(10 * time.Minute)
+
+	// Publish scheduled modules when their publish time arrives
+	handlers.StartScheduledPublishJob(time.Minute)
+
+	// Take expired modules out of the feed and remind authors of due reviews
+	handlers.StartContentReviewJob(time.Hour)
+
+	// Keep the per-miner daily compliance summary current
+	handlers.StartComplianceSummaryJob(15 * time.Minute)
+
+	// Flag and escalate emergencies left pending or resolving too long
+	handlers.StartStaleEmergencyJob(5 * time.Minute)
+
+	// Raise emergencies for lone workers who missed a check-in
+	handlers.StartLoneWorkerWatchdog(time.Minute)
+
+	// Raise an SOS for wearable-detected falls the miner didn't dismiss
+	handlers.StartWearableFallJob(15 * time.Second)
+
+	handler := newRouter(cfg)
+
+	log.Printf("Server starting on port %s...", cfg.Port)
+	if err := http.ListenAndServe(":"+cfg.Port, handler); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
+
+// newRouter builds the HTTP handler with every route and middleware. It is shared with
+// the integration tests so they exercise exactly what the server runs.
+func newRouter(cfg *config.Config) http.Handler {
 	// Create router
 	router := mux.NewRouter()
+	// Every route is also served under /api/v1/ and /api/v2/; handlers that differ between
+	// versions are wired with middleware.ByVersion (see /api/videos/feed)
+	versioned := middleware.APIVersioning(router)
 
-	// Serve uploaded files (videos, profile pictures)
-	router.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+	// Serve uploaded files (videos, profile pictures, documents) through signed URLs only
+	router.PathPrefix("/uploads/").HandlerFunc(handlers.ServeSignedUpload)
 
-	// Serve database assets (seeded videos)
-	router.PathPrefix("/assets/").Handler(http.StripPrefix("/assets/", http.FileServer(http.Dir("database/assets"))))
+	// Serve bundled assets (seeded videos) with cache headers
+	router.PathPrefix("/assets/").HandlerFunc(handlers.ServeAsset).Methods("GET", "HEAD")
 
 	// Public routes
 	router.HandleFunc("/api/health", healthCheck).Methods("GET")
-	router.HandleFunc("/api/auth/signup", handlers.SupervisorSignup).Methods("POST")
-	router.HandleFunc("/api/auth/login", handlers.Login).Methods("POST")
-	router.HandleFunc("/api/auth/register-admin", handlers.RegisterAdmin).Methods("POST")
-	router.HandleFunc("/api/app/miner/login", handlers.MinerAppLogin).Methods("POST")
+	router.HandleFunc("/api/versions", apiVersions).Methods("GET")
+	// Sign-up and registration endpoints need a CAPTCHA token when CAPTCHA_PROVIDER is set
+	router.HandleFunc("/api/auth/signup", middleware.RequireCaptcha(handlers.SupervisorSignup)).Methods("POST")
+	router.HandleFunc("/api/auth/login", middleware.LoginGuard(handlers.Login)).Methods("POST")
+	// Admin registration needs a code minted by an existing admin; failed codes count like failed logins
+	router.HandleFunc("/api/auth/register-admin", middleware.RequireCaptcha(middleware.LoginGuard(handlers.RegisterAdmin))).Methods("POST")
+	// Activate an invited account by choosing a password
+	router.HandleFunc("/api/auth/activate", handlers.ActivateAccount).Methods("POST")
+	// Verify a self-signed-up supervisor's email address, or send a new link
+	router.HandleFunc("/api/auth/verify-email", handlers.VerifyEmail).Methods("POST")
+	router.HandleFunc("/api/auth/verify-email/resend", middleware.RequireCaptcha(handlers.ResendEmailVerification)).Methods("POST")
+	// Single sign-on with the organization's identity provider (OIDC authorization code flow)
+	router.HandleFunc("/api/auth/oidc/login", handlers.OIDCLogin).Methods("GET")
+	router.HandleFunc("/api/auth/oidc/callback", handlers.OIDCCallback).Methods("GET")
+	router.HandleFunc("/api/app/miner/login", middleware.LoginGuard(handlers.MinerAppLogin)).Methods("POST")
+	router.HandleFunc("/api/app/version-check", handlers.AppVersionCheck).Methods("GET")
+	// Wearable heart rate and fall readings, authenticated by the device's API key
+	router.HandleFunc("/api/sensors/wearables/readings", handlers.IngestWearableReadings).Methods("POST")
+	// Gas, dust, temperature and airflow readings from zone sensors, authenticated the same way
+	router.HandleFunc("/api/sensors/zones/readings", handlers.IngestZoneSensorReadings).Methods("POST")
 
 	// ==================== ADMIN AUTH (Public) ====================
-	router.HandleFunc("/api/admin/signup", handlers.AdminSignup).Methods("POST")
-	router.HandleFunc("/api/admin/login", handlers.AdminLogin).Methods("POST")
+	router.HandleFunc("/api/admin/signup", middleware.RequireCaptcha(middleware.LoginGuard(handlers.AdminSignup))).Methods("POST")
+	router.HandleFunc("/api/admin/login", middleware.LoginGuard(handlers.AdminLogin)).Methods("POST")
 
 	// Protected routes
 	api := router.PathPrefix("/api").Subrouter()
 	api.Use(middleware.AuthMiddleware)
+	// Requests authenticated by the dashboard session cookie must carry its CSRF token
+	api.Use(middleware.CSRFProtect)
+	// Auditor tokens are read-only and limited to the /api/audit routes
+	api.Use(middleware.AuditorReadOnly)
+
+	// POST /api/batch - Several API calls in one round trip, each run through the full router
+	api.HandleFunc("/batch", handlers.Batch(versioned)).Methods("POST")
 
 	// ==================== VIDEO FEED & RECOMMENDATIONS ====================
 	// GET /api/videos/feed?page=1&limit=10 - Paginated video feed (TikTok-style)
-	api.HandleFunc("/videos/feed", handlers.GetVideoFeed).Methods("GET")
+	// Filters: hide_completed=true, category=, language=, since=YYYY-MM-DD, new=true
+	// Feed and media endpoints take ?quality=low|medium|high; Save-Data: on means low
+	// v2 wraps the feed in the data/pagination envelope
+	api.HandleFunc("/videos/feed", middleware.ByVersion(map[int]http.HandlerFunc{
+		middleware.APIVersion1: handlers.GetVideoFeed,
+		middleware.APIVersion2: handlers.GetVideoFeedV2,
+	})).Methods("GET")
+	// GET /api/videos/trending?sort=trending|helpful - Most engaging videos this week (explore tab)
+	api.HandleFunc("/videos/trending", handlers.GetTrendingVideos).Methods("GET")
 	// GET /api/videos/recommended?tags=PPE,safety - Tag-based recommendations
 	api.HandleFunc("/videos/recommended", handlers.GetRecommendedVideos).Methods("GET")
 	// POST /api/videos/{id}/like - Like a video
 	api.HandleFunc("/videos/{id}/like", handlers.LikeVideo).Methods("POST")
 	// POST /api/videos/{id}/dislike - Dislike a video
 	api.HandleFunc("/videos/{id}/dislike", handlers.DislikeVideo).Methods("POST")
+	// POST /api/videos/{id}/reaction - Set reaction explicitly (like, dislike or none)
+	api.HandleFunc("/videos/{id}/reaction", handlers.SetVideoReaction).Methods("POST")
+	// POST /api/videos/{id}/view - Count a video view
+	api.HandleFunc("/videos/{id}/view", handlers.RecordVideoView).Methods("POST")
+	// GET /api/videos/{id}/processing-status - Transcoding, thumbnail and caption progress for the uploader
+	api.HandleFunc("/videos/{id}/processing-status", handlers.GetVideoProcessingStatus).Methods("GET")
+	// GET /api/videos/upload-policy - Size, length and format limits for the caller's video uploads
+	api.HandleFunc("/videos/upload-policy", handlers.GetMyUploadPolicy).Methods("GET")
 	// POST /api/videos/upload - Upload video with optional quiz (multipart)
 	api.HandleFunc("/videos/upload", handlers.UploadVideo).Methods("POST")
 	// POST /api/videos/submit-link - Submit video link for approval (miners)
@@ -74,7 +278,7 @@ func main() {
 	// ==================== TRAINING & QUIZ ====================
 	// GET /api/training/quiz?title=Safety%20Helmet%20Usage - Get quiz by video title
 	api.HandleFunc("/training/quiz", handlers.GetQuizByTitle).Methods("GET")
-	// GET /api/training/quizzes - Get list of all quizzes
+	// GET /api/training/quizzes?updated_since=RFC3339 - Get list of all quizzes (or changes since)
 	api.HandleFunc("/training/quizzes", handlers.GetQuizList).Methods("GET")
 	// GET /api/training/modules - Get all video modules with quiz info (numbered rows)
 	api.HandleFunc("/training/modules", handlers.GetVideoModulesWithQuizzes).Methods("GET")
@@ -93,19 +297,92 @@ func main() {
 	// POST /api/app/profile/picture - Upload profile picture
 	api.HandleFunc("/app/profile/picture", handlers.UploadProfilePicture).Methods("POST")
 
-	// App routes (User protected - MINER)
+	// App routes (User protected - MINER/OPERATOR); checklist GETs accept ?updated_since= for delta sync
 	api.HandleFunc("/app/quiz-calendar", handlers.GetQuizCalendarAndStreak).Methods("GET")
 	api.HandleFunc("/app/checklists/pre-start", handlers.GetPreStartChecklistForApp).Methods("GET")
-	api.HandleFunc("/app/checklists/pre-start/complete", handlers.UpdatePreStartChecklistForApp).Methods("PUT")
+	api.HandleFunc("/app/checklists/pre-start/complete", handlers.RequireShiftWindow(handlers.UpdatePreStartChecklistForApp)).Methods("PUT")
 	api.HandleFunc("/app/checklists/ppe", handlers.GetPPEChecklistForApp).Methods("GET")
-	api.HandleFunc("/app/checklists/ppe/complete", handlers.UpdatePPEChecklistForApp).Methods("PUT")
+	api.HandleFunc("/app/checklists/ppe/complete", handlers.RequireShiftWindow(handlers.UpdatePPEChecklistForApp)).Methods("PUT")
+	// POST /api/app/sync - Upload emergencies, PPE stats and checklist ticks queued offline; one result per item
+	api.HandleFunc("/app/sync", handlers.SyncOfflineItems).Methods("POST")
+	// Pre-use equipment checks (operators)
+	equipmentAppRoutes := api.PathPrefix("/app/equipment").Subrouter()
+	equipmentAppRoutes.Use(middleware.RequirePermission(middleware.PermEquipmentChecklistSubmit))
+	equipmentAppRoutes.HandleFunc("/checklist", handlers.GetEquipmentChecklistForApp).Methods("GET")
+	equipmentAppRoutes.HandleFunc("/checklist/complete", handlers.RequireShiftWindow(handlers.UpdateEquipmentChecklistForApp)).Methods("PUT")
+
+	// GET /api/app/home - Everything the app shows on launch in one call
+	api.HandleFunc("/app/home", handlers.GetAppHome).Methods("GET")
+	// GET /api/app/mastery - Quiz performance per topic, weakest first
+	api.HandleFunc("/app/mastery", handlers.GetMyMastery).Methods("GET")
+	// GET /api/app/remedial-training - Modules assigned after an incident
+	api.HandleFunc("/app/remedial-training", handlers.GetMyRemedialTraining).Methods("GET")
+	// GET /api/app/flags - Feature flags evaluated for the current user
+	api.HandleFunc("/app/flags", handlers.GetMyFeatureFlags).Methods("GET")
+	// GET /api/app/experiments - A/B experiment variants assigned to the current user
+	api.HandleFunc("/app/experiments", handlers.GetMyExperiments).Methods("GET")
+	// POST /api/app/experiments/{key}/exposure - Log that the user saw their variant
+	api.HandleFunc("/app/experiments/{key}/exposure", handlers.LogExperimentExposure).Methods("POST")
+	// POST /api/app/telemetry - Batched client analytics events (sampled, PII scrubbed)
+	api.HandleFunc("/app/telemetry", handlers.IngestTelemetry).Methods("POST")
+	// POST /api/app/errors - Handled error report with app version and device context
+	api.HandleFunc("/app/errors", handlers.ReportClientError).Methods("POST")
+
+	// POST /api/app/devices - Register this phone; returns a token bound to its X-Device-ID
+	api.HandleFunc("/app/devices", handlers.RegisterDevice).Methods("POST")
+	// GET /api/app/emergency-contacts - Prioritized SOS call list with who is on call now
+	api.HandleFunc("/app/emergency-contacts", handlers.GetMyEmergencyContacts).Methods("GET")
+	// POST /api/app/checkin/heartbeat - Lone-worker check-in; the first one turns lone-worker mode on
+	api.HandleFunc("/app/checkin/heartbeat", handlers.CheckinHeartbeat).Methods("POST")
+	// GET /api/app/checkin - Lone-worker mode status and next check-in deadline
+	api.HandleFunc("/app/checkin", handlers.GetMyCheckinStatus).Methods("GET")
+	// DELETE /api/app/checkin - Turn lone-worker mode off
+	api.HandleFunc("/app/checkin", handlers.EndCheckins).Methods("DELETE")
+	// POST /api/app/wearables/falls/{id}/dismiss - "I'm OK" after a detected fall; cancels the SOS
+	api.HandleFunc("/app/wearables/falls/{id}/dismiss", handlers.DismissWearableFall).Methods("POST")
+	// POST /api/app/blast-clearances/{id}/evacuated - Confirm I have left a zone being cleared for a blast
+	api.HandleFunc("/app/blast-clearances/{id}/evacuated", handlers.ConfirmEvacuatedByMiner).Methods("POST")
+	// GET /api/app/notifications/poll - Long-poll for new notifications where push is unreliable
+	api.HandleFunc("/app/notifications/poll", handlers.PollNotifications).Methods("GET")
+	// GET /api/app/visitor/pass - Visitor's temporary pass and induction status
+	api.HandleFunc("/app/visitor/pass", handlers.GetMyVisitorPass).Methods("GET")
 
 	// User routes
 	api.HandleFunc("/me", handlers.GetMe).Methods("GET")
+	// Change own password (checks the current one) and revoke every existing token
+	api.HandleFunc("/me/password", handlers.ChangePassword).Methods("PUT")
+	// Link single sign-on to the signed-in account; admins are never linked by email
+	api.HandleFunc("/me/sso/link", handlers.StartSSOLink).Methods("POST")
+	// Signed-in devices of the current user, and signing one out remotely
+	api.HandleFunc("/me/sessions", handlers.GetMySessions).Methods("GET")
+	api.HandleFunc("/me/sessions/{id}", handlers.RevokeMySession).Methods("DELETE")
+	// Keep the dashboard session in an HttpOnly cookie; returns the CSRF token
+	api.HandleFunc("/auth/session", handlers.StartCookieSession).Methods("POST")
+	// Revoke the current token, or every token of the current user
+	api.HandleFunc("/auth/logout", handlers.Logout).Methods("POST")
+	api.HandleFunc("/auth/logout-all", handlers.LogoutEverywhere).Methods("POST")
+	// POST /api/content/report - Report a video or profile picture to admins
+	api.HandleFunc("/content/report", handlers.ReportContent).Methods("POST")
+
+	// ==================== NOTIFICATIONS ====================
+	// GET /api/notifications?unread=true - Get my notifications
+	api.HandleFunc("/notifications", handlers.GetNotifications).Methods("GET")
+	// PUT /api/notifications/read-all - Mark all notifications read
+	api.HandleFunc("/notifications/read-all", handlers.MarkAllNotificationsRead).Methods("PUT")
+	// PUT /api/notifications/{id}/read - Mark a notification read
+	api.HandleFunc("/notifications/{id}/read", handlers.MarkNotificationRead).Methods("PUT")
+
+	// ==================== DOCUMENTS ====================
+	// POST /api/documents - Upload license/medical certificate/blasting ticket (multipart)
+	api.HandleFunc("/documents", handlers.UploadDocument).Methods("POST")
+	// GET /api/documents/me - Get my documents with expiry status
+	api.HandleFunc("/documents/me", handlers.GetMyDocuments).Methods("GET")
+	// DELETE /api/documents/{id} - Delete a document (owner or admin)
+	api.HandleFunc("/documents/{id}", handlers.DeleteDocument).Methods("DELETE")
 
 	// ==================== PPE STATISTICS (Miner) ====================
 	// POST /api/ppestat - Submit PPE verification from app
-	api.HandleFunc("/ppestat", handlers.SubmitPPEStat).Methods("POST")
+	api.HandleFunc("/ppestat", handlers.RequireShiftWindow(handlers.SubmitPPEStat)).Methods("POST")
 	// GET /api/ppestat/me - Get my PPE history
 	api.HandleFunc("/ppestat/me", handlers.GetMyPPEStats).Methods("GET")
 
@@ -113,34 +390,116 @@ func main() {
 	minerRoutes := api.PathPrefix("/miners").Subrouter()
 	minerRoutes.Use(middleware.SupervisorOnly)
 	minerRoutes.HandleFunc("", handlers.CreateMiner).Methods("POST")
+	// Invite a miner by email; they choose their own password at /api/auth/activate
+	minerRoutes.HandleFunc("/invite", handlers.InviteMiner).Methods("POST")
+	minerRoutes.HandleFunc("/invitations", handlers.GetInvitations).Methods("GET")
+	minerRoutes.HandleFunc("/{id}/invite/resend", handlers.ResendInvitation).Methods("POST")
+	// Leave and absences; registered before /{id} so "leave" isn't taken for a miner ID
+	minerRoutes.HandleFunc("/leave", handlers.GetCrewLeave).Methods("GET")
+	minerRoutes.HandleFunc("/leave/{leaveId}", handlers.DeleteMinerLeave).Methods("DELETE")
+	minerRoutes.HandleFunc("/{id}/leave", handlers.CreateMinerLeave).Methods("POST")
+	minerRoutes.HandleFunc("/{id}/leave", handlers.GetCrewLeave).Methods("GET")
 	minerRoutes.HandleFunc("", handlers.GetMiners).Methods("GET")
 	minerRoutes.HandleFunc("/{id}", handlers.GetMiner).Methods("GET")
 	minerRoutes.HandleFunc("/{id}", handlers.UpdateMiner).Methods("PUT")
 	minerRoutes.HandleFunc("/{id}", handlers.DeleteMiner).Methods("DELETE")
 
 	// ==================== SUPERVISOR MODULE ROUTES ====================
+	// Module approval is granted by permission, so it is registered ahead of the
+	// supervisor-only subrouter
+	moduleApproval := api.PathPrefix("/supervisor/modules/review").Subrouter()
+	moduleApproval.Use(middleware.RequirePermission(middleware.PermModuleApprove))
+	moduleApproval.HandleFunc("/{id}", handlers.ReviewModule).Methods("POST")
+
 	supervisorRoutes := api.PathPrefix("/supervisor").Subrouter()
 	supervisorRoutes.Use(middleware.SupervisorOnly)
 	// Module management
 	supervisorRoutes.HandleFunc("/modules/pending", handlers.GetPendingModules).Methods("GET")
-	supervisorRoutes.HandleFunc("/modules/review/{id}", handlers.ReviewModule).Methods("POST")
 	supervisorRoutes.HandleFunc("/modules/uploaded", handlers.GetUploadedModules).Methods("GET")
+	// Draft and scheduled modules; publish now, reschedule or move back to draft
+	supervisorRoutes.HandleFunc("/modules/staged", handlers.GetStagedModules).Methods("GET")
+	supervisorRoutes.HandleFunc("/modules/{id}/publish", handlers.PublishModule).Methods("PUT")
+	// Modules due for review or expiring; record a review and set the next dates
+	supervisorRoutes.HandleFunc("/modules/review-due", handlers.GetModulesDueForReview).Methods("GET")
+	supervisorRoutes.HandleFunc("/modules/{id}/content-review", handlers.ReviewModuleContent).Methods("PUT")
+	// Quiz pass mark per module (null uses the retake policy's), and the crew's failure rates
+	supervisorRoutes.HandleFunc("/modules/{id}/passing-score", handlers.SetModulePassingScore).Methods("PUT")
+	supervisorRoutes.HandleFunc("/modules/failure-rates", handlers.GetModuleFailureRates).Methods("GET")
+	// Daily checklist, PPE and training compliance per miner
+	supervisorRoutes.HandleFunc("/compliance", handlers.GetComplianceSummary).Methods("GET")
+	// Check proposed zone/shift assignments (capacity, documents, medicals, induction) before committing them
+	supervisorRoutes.HandleFunc("/plan/validate", handlers.ValidateCrewPlan).Methods("POST")
+	// Printable PDF of the day's pre-start and PPE status per crew member, for posting at the portal
+	supervisorRoutes.HandleFunc("/checklists/printout", handlers.GetChecklistPrintout).Methods("GET")
 	// Zone management
 	supervisorRoutes.HandleFunc("/zones", handlers.GetZones).Methods("GET")
 	supervisorRoutes.HandleFunc("/zones", handlers.CreateZone).Methods("POST")
+	// Boundary polygon and/or radius that new emergencies are placed in the zone by
+	supervisorRoutes.HandleFunc("/zones/{id}/geometry", handlers.SetZoneGeometry).Methods("PUT")
+	// Air quality dashboard: latest values, 24h trends, breaches and risk score
+	supervisorRoutes.HandleFunc("/zones/{id}/environment", handlers.GetZoneEnvironment).Methods("GET")
+	// Blast clearance: lock and evacuate a zone, sign off, clear for firing, then all-clear
+	supervisorRoutes.HandleFunc("/zones/{id}/blast-clearance", handlers.InitiateBlastClearance).Methods("POST")
+	supervisorRoutes.HandleFunc("/zones/{id}/blast-clearances", handlers.GetZoneBlastClearances).Methods("GET")
+	supervisorRoutes.HandleFunc("/blast-clearances/{id}", handlers.GetBlastClearance).Methods("GET")
+	supervisorRoutes.HandleFunc("/blast-clearances/{id}/evacuated", handlers.ConfirmEvacuatedBySupervisor).Methods("POST")
+	supervisorRoutes.HandleFunc("/blast-clearances/{id}/sign-off", handlers.SignOffBlastClearance).Methods("POST")
+	supervisorRoutes.HandleFunc("/blast-clearances/{id}/clear", handlers.ClearBlastZone).Methods("POST")
+	supervisorRoutes.HandleFunc("/blast-clearances/{id}/all-clear", handlers.GiveBlastAllClear).Methods("POST")
+	supervisorRoutes.HandleFunc("/blast-clearances/{id}/cancel", handlers.CancelBlastClearance).Methods("POST")
 	supervisorRoutes.HandleFunc("/allocate", handlers.AllocateMinerToZone).Methods("POST")
-	// Miners view with zone info
+	// Crew view (miners and operators) with zone info
 	supervisorRoutes.HandleFunc("/miners", handlers.GetSupervisorMiners).Methods("GET")
+	// Operators
+	operatorRoutes := supervisorRoutes.PathPrefix("/operators").Subrouter()
+	operatorRoutes.Use(middleware.RequirePermission(middleware.PermOperatorManage))
+	operatorRoutes.HandleFunc("", handlers.CreateOperator).Methods("POST")
+	operatorRoutes.HandleFunc("", handlers.GetOperators).Methods("GET")
 	// Emergency report management
+	supervisorRoutes.HandleFunc("/emergencies/missing-media", handlers.GetMissingMediaEmergencies).Methods("GET")
 	supervisorRoutes.HandleFunc("/emergencies/{id}/download", handlers.DownloadEmergencyReport).Methods("GET")
 	supervisorRoutes.HandleFunc("/emergencies/{id}/forward", handlers.ForwardEmergencyReport).Methods("POST")
+	supervisorRoutes.HandleFunc("/emergencies/{id}/evacuate", handlers.StartEvacuation).Methods("POST")
+	supervisorRoutes.HandleFunc("/emergencies/{id}/merge", handlers.MergeEmergencies).Methods("POST")
+	supervisorRoutes.HandleFunc("/emergencies/{id}/unmerge", handlers.UnmergeEmergency).Methods("POST")
+	// Morning briefing: star video, attendance, approvals, emergencies and checklists
+	supervisorRoutes.HandleFunc("/briefing", handlers.GetSupervisorBriefing).Methods("GET")
+	// Shift times, checklist frequency, mandatory PPE and star video source for their site
+	supervisorRoutes.HandleFunc("/site-settings", handlers.GetSiteSettings).Methods("GET")
+	supervisorRoutes.Handle("/site-settings", middleware.RequirePermission(middleware.PermSiteSettingsManage)(http.HandlerFunc(handlers.UpdateSiteSettings))).Methods("PUT")
 	// PPE Statistics (Supervisor view)
 	supervisorRoutes.HandleFunc("/ppestats", handlers.GetPPEStats).Methods("GET")
-
-	// Video module routes
+	// Remedial training after emergencies and failed PPE checks
+	supervisorRoutes.HandleFunc("/remedial-training", handlers.AssignRemedialTraining).Methods("POST")
+	supervisorRoutes.HandleFunc("/remedial-training", handlers.GetRemedialTraining).Methods("GET")
+	// Device attestation / suspicious submissions
+	supervisorRoutes.HandleFunc("/integrity", handlers.GetSuspiciousSubmissions).Methods("GET")
+	// Miners' registered phones; deactivating a lost one signs it out
+	supervisorRoutes.HandleFunc("/devices", handlers.GetCrewDevices).Methods("GET")
+	supervisorRoutes.HandleFunc("/devices/{id}/deactivate", handlers.DeactivateDevice).Methods("POST")
+	// Acting supervisor cover while a supervisor is away
+	supervisorRoutes.HandleFunc("/delegations", handlers.CreateDelegation).Methods("POST")
+	supervisorRoutes.HandleFunc("/delegations", handlers.GetDelegations).Methods("GET")
+	supervisorRoutes.HandleFunc("/delegations/{id}", handlers.RevokeDelegation).Methods("DELETE")
+	// Crew in lone-worker mode and when each is next due to check in
+	supervisorRoutes.HandleFunc("/lone-workers", handlers.GetCrewLoneWorkers).Methods("GET")
+	// Miner induction workflow
+	supervisorRoutes.HandleFunc("/inductions", handlers.GetSupervisorInductions).Methods("GET")
+	supervisorRoutes.HandleFunc("/inductions/{minerId}", handlers.GetMinerInduction).Methods("GET")
+	supervisorRoutes.HandleFunc("/inductions/{minerId}/steps", handlers.CompleteInductionStep).Methods("POST")
+	// Miner documents (licenses, certificates)
+	supervisorRoutes.HandleFunc("/documents", handlers.GetSupervisorDocuments).Methods("GET")
+	// Visitor management
+	supervisorRoutes.HandleFunc("/visitors", handlers.RegisterVisitor).Methods("POST")
+	supervisorRoutes.HandleFunc("/visitors", handlers.GetVisitors).Methods("GET")
+	supervisorRoutes.HandleFunc("/visitors/{id}/badge", handlers.GetVisitorBadge).Methods("GET")
+	supervisorRoutes.HandleFunc("/visitors/{id}", handlers.RevokeVisitor).Methods("DELETE")
+
+	// Video module routes (GET /api/modules?updated_since=RFC3339 returns changes and deletions only)
 	api.HandleFunc("/modules", handlers.GetVideoModules).Methods("GET")
 	api.HandleFunc("/modules/{id}", handlers.GetVideoModule).Methods("GET")
 	api.HandleFunc("/modules/{id}/questions", handlers.GetQuestions).Methods("GET")
+	api.HandleFunc("/modules/{id}/attempts", handlers.GetModuleAttempts).Methods("GET")
 	api.HandleFunc("/modules/submit", handlers.SubmitModuleAnswers).Methods("POST")
 	api.HandleFunc("/modules/star", handlers.GetStarVideo).Methods("GET")
 
@@ -150,12 +509,27 @@ func main() {
 	moduleManagement.HandleFunc("", handlers.CreateVideoModule).Methods("POST")
 	moduleManagement.HandleFunc("/{id}/star", handlers.SetStarVideo).Methods("POST")
 	moduleManagement.HandleFunc("/questions", handlers.CreateQuestion).Methods("POST")
+	// Transcript, quiz questions drafted from it by the configured LLM endpoint, and their review
+	moduleManagement.HandleFunc("/{id}/transcript", handlers.SetModuleTranscript).Methods("PUT")
+	moduleManagement.HandleFunc("/{id}/question-drafts", handlers.GenerateQuestionDrafts).Methods("POST")
+	moduleManagement.HandleFunc("/{id}/question-drafts", handlers.GetQuestionDrafts).Methods("GET")
+	moduleManagement.HandleFunc("/question-drafts/{id}/approve", handlers.ApproveQuestionDraft).Methods("POST")
+	moduleManagement.HandleFunc("/question-drafts/{id}/reject", handlers.RejectQuestionDraft).Methods("POST")
 
 	// Learning streak routes
 	api.HandleFunc("/streaks", handlers.GetLearningStreaks).Methods("GET")
 	api.HandleFunc("/streak/me", handlers.GetMinerStreak).Methods("GET")
 	api.HandleFunc("/completions/me", handlers.GetMinerCompletions).Methods("GET")
 
+	// Equipment checklist management; registered before /checklists so that subrouter's
+	// SupervisorOnly doesn't catch these paths
+	equipmentChecklistRoutes := api.PathPrefix("/checklists/equipment").Subrouter()
+	equipmentChecklistRoutes.Use(middleware.RequirePermission(middleware.PermEquipmentChecklistManage))
+	equipmentChecklistRoutes.HandleFunc("", handlers.CreateEquipmentChecklistItem).Methods("POST")
+	equipmentChecklistRoutes.HandleFunc("", handlers.GetEquipmentChecklistItems).Methods("GET")
+	equipmentChecklistRoutes.HandleFunc("/{id}", handlers.UpdateEquipmentChecklistItem).Methods("PUT")
+	equipmentChecklistRoutes.HandleFunc("/{id}", handlers.DeleteEquipmentChecklistItem).Methods("DELETE")
+
 	// Checklist management routes (supervisor only)
 	checklistRoutes := api.PathPrefix("/checklists").Subrouter()
 	checklistRoutes.Use(middleware.SupervisorOnly)
@@ -164,11 +538,13 @@ func main() {
 	checklistRoutes.HandleFunc("/pre-start", handlers.GetPreStartChecklistItems).Methods("GET")
 	checklistRoutes.HandleFunc("/pre-start/{id}", handlers.DeletePreStartChecklistItem).Methods("DELETE")
 	checklistRoutes.HandleFunc("/pre-start/complete", handlers.UpdatePreStartChecklistCompletion).Methods("PUT")
+	checklistRoutes.HandleFunc("/pre-start/{id}", handlers.UpdatePreStartChecklistItem).Methods("PUT")
 	// PPE Checklist (Supervisor)
 	checklistRoutes.HandleFunc("/ppe", handlers.CreatePPEChecklistItem).Methods("POST")
 	checklistRoutes.HandleFunc("/ppe", handlers.GetPPEChecklistItems).Methods("GET")
 	checklistRoutes.HandleFunc("/ppe/{id}", handlers.DeletePPEChecklistItem).Methods("DELETE")
 	checklistRoutes.HandleFunc("/ppe/complete", handlers.UpdatePPEChecklistCompletion).Methods("PUT")
+	checklistRoutes.HandleFunc("/ppe/{id}", handlers.UpdatePPEChecklistItem).Methods("PUT")
 
 	// Dashboard routes (supervisor only)
 	dashboardRoutes := api.PathPrefix("/dashboard").Subrouter()
@@ -178,9 +554,32 @@ func main() {
 	// Emergency routes
 	api.HandleFunc("/emergencies", handlers.CreateEmergency).Methods("POST")
 	api.HandleFunc("/emergencies", handlers.GetEmergencies).Methods("GET")
+	// GET /api/emergencies/categories - Quick-report categories and their fields
+	api.HandleFunc("/emergencies/categories", handlers.GetEmergencyCategories).Methods("GET")
+	// GET /api/emergencies/stats?group_by=severity|zone|month|category - Dashboard counts
+	api.HandleFunc("/emergencies/stats", handlers.GetEmergencyStats).Methods("GET")
 	api.HandleFunc("/emergencies/{id}", handlers.GetEmergency).Methods("GET")
+	// POST /api/emergencies/{id}/ack - Supervisor acknowledges an emergency
+	api.HandleFunc("/emergencies/{id}/ack", handlers.AcknowledgeEmergency).Methods("POST")
+	// GET /api/emergencies/{id}/cluster - Incident with every report linked to it
+	api.HandleFunc("/emergencies/{id}/cluster", handlers.GetEmergencyCluster).Methods("GET")
 	api.HandleFunc("/emergencies/{id}/media", handlers.UpdateEmergencyMedia).Methods("PUT")
-	api.HandleFunc("/emergencies/{id}/status", handlers.UpdateEmergencyStatus).Methods("PUT")
+	// POST /api/emergencies/{id}/media - Upload photos and videos for an emergency (multipart)
+	api.HandleFunc("/emergencies/{id}/media", handlers.UploadEmergencyMedia).Methods("POST")
+	api.Handle("/emergencies/{id}/status", middleware.RequirePermission(middleware.PermEmergencyResolve)(http.HandlerFunc(handlers.UpdateEmergencyStatus))).Methods("PUT")
+	// GET /api/ws - Server-sent event stream of new emergencies, status changes and PPE
+	// non-compliance at the supervisor's site, instead of polling /api/emergencies
+	api.Handle("/ws", middleware.SupervisorOnly(http.HandlerFunc(handlers.StreamLiveAlerts))).Methods("GET")
+
+	// ==================== AUDITOR ROUTES (audit:read) ====================
+	auditRoutes := api.PathPrefix("/audit").Subrouter()
+	auditRoutes.Use(middleware.RequirePermission(middleware.PermAuditRead))
+	// GET /api/audit/access - Records and date range the caller's audit token covers
+	auditRoutes.HandleFunc("/access", handlers.AuditGetAccess).Methods("GET")
+	// GET /api/audit/emergencies - Emergencies in range, reporter and injuries redacted by role
+	auditRoutes.HandleFunc("/emergencies", handlers.AuditGetEmergencies).Methods("GET")
+	// GET /api/audit/training - Training modules completed in range
+	auditRoutes.HandleFunc("/training", handlers.AuditGetTrainingRecords).Methods("GET")
 
 	// ==================== ADMIN ROUTES (Admin only) ====================
 	adminRoutes := api.PathPrefix("/admin").Subrouter()
@@ -197,6 +596,118 @@ func main() {
 	adminRoutes.HandleFunc("/miners/{id}", handlers.AdminGetMiner).Methods("GET")
 	adminRoutes.HandleFunc("/miners/{id}", handlers.AdminUpdateMiner).Methods("PUT")
 	adminRoutes.HandleFunc("/miners/{id}", handlers.AdminDeleteMiner).Methods("DELETE")
+	// Revoke every session of a compromised account
+	adminRoutes.HandleFunc("/users/{id}/revoke-sessions", handlers.AdminRevokeUserSessions).Methods("POST")
+	adminRoutes.HandleFunc("/users/{id}/deactivate", handlers.AdminDeactivateUser).Methods("POST")
+	adminRoutes.HandleFunc("/users/{id}/reactivate", handlers.AdminReactivateUser).Methods("POST")
+	// Act as a user for 30 minutes to debug their app; every use is logged
+	adminRoutes.HandleFunc("/impersonate/{userId}", handlers.AdminImpersonate).Methods("POST")
+	adminRoutes.HandleFunc("/impersonations", handlers.AdminGetImpersonations).Methods("GET")
+	// Time-boxed read-only tokens for external auditors, scoped to record types and dates
+	adminRoutes.HandleFunc("/audit-tokens", handlers.AdminCreateAuditToken).Methods("POST")
+	adminRoutes.HandleFunc("/audit-tokens", handlers.AdminGetAuditTokens).Methods("GET")
+	adminRoutes.HandleFunc("/audit-tokens/{id}", handlers.AdminRevokeAuditToken).Methods("DELETE")
+	// Database backups with pg_dump (platform admins); restore with ./main restore-backup
+	adminRoutes.HandleFunc("/backups", handlers.AdminCreateBackup).Methods("POST")
+	adminRoutes.HandleFunc("/backups", handlers.AdminGetBackups).Methods("GET")
+	adminRoutes.HandleFunc("/backups/{id}/download", handlers.AdminDownloadBackup).Methods("GET")
+	// GET /api/admin/migrations - Progress of background schema changes on large tables (platform admins)
+	adminRoutes.HandleFunc("/migrations", handlers.AdminGetOnlineMigrations).Methods("GET")
+	// Successful and failed logins with IP and user agent
+	adminRoutes.HandleFunc("/login-events", handlers.AdminGetLoginEvents).Methods("GET")
+	// Organization logo, colors and email footer used on generated PDFs, reports and emails
+	adminRoutes.HandleFunc("/branding", handlers.AdminGetBranding).Methods("GET")
+	adminRoutes.HandleFunc("/branding", handlers.AdminUpdateBranding).Methods("PUT")
+	adminRoutes.HandleFunc("/branding/logo", handlers.AdminUploadBrandingLogo).Methods("POST")
+	// Induction progress (HR view)
+	adminRoutes.HandleFunc("/inductions", handlers.AdminGetInductions).Methods("GET")
+	// Document compliance across all users
+	adminRoutes.HandleFunc("/documents", handlers.AdminGetDocuments).Methods("GET")
+	// Siren / display board integrations
+	adminRoutes.HandleFunc("/sirens", handlers.CreateSirenEndpoint).Methods("POST")
+	adminRoutes.HandleFunc("/sirens", handlers.GetSirenEndpoints).Methods("GET")
+	adminRoutes.HandleFunc("/sirens/triggers", handlers.GetSirenTriggers).Methods("GET")
+	adminRoutes.HandleFunc("/sirens/{id}", handlers.DeleteSirenEndpoint).Methods("DELETE")
+	// Wearables issued to miners and their API keys
+	adminRoutes.HandleFunc("/wearables", handlers.CreateWearable).Methods("POST")
+	adminRoutes.HandleFunc("/wearables", handlers.GetWearables).Methods("GET")
+	adminRoutes.HandleFunc("/wearables/{id}", handlers.AssignWearable).Methods("PUT")
+	adminRoutes.HandleFunc("/wearables/{id}", handlers.RevokeWearable).Methods("DELETE")
+	// Environmental sensors installed in zones and their API keys
+	adminRoutes.HandleFunc("/zones/{id}/sensors", handlers.CreateZoneSensor).Methods("POST")
+	adminRoutes.HandleFunc("/zones/{id}/sensors", handlers.GetZoneSensors).Methods("GET")
+	adminRoutes.HandleFunc("/zone-sensors/{id}", handlers.RevokeZoneSensor).Methods("DELETE")
+	// Medic, control room and other numbers on each site's SOS call list
+	adminRoutes.HandleFunc("/emergency-contacts", handlers.CreateEmergencyContact).Methods("POST")
+	adminRoutes.HandleFunc("/emergency-contacts", handlers.GetEmergencyContactDirectory).Methods("GET")
+	adminRoutes.HandleFunc("/emergency-contacts/{id}", handlers.DeleteEmergencyContact).Methods("DELETE")
+	// Texts sent about HIGH and CRITICAL emergencies and whether they went out
+	adminRoutes.HandleFunc("/emergency-sms", handlers.GetEmergencySMSLog).Methods("GET")
+	// Role permissions; grants take effect on every instance without a restart
+	adminRoutes.HandleFunc("/permissions", handlers.AdminGetPermissions).Methods("GET")
+	adminRoutes.HandleFunc("/permissions/{permission}/roles/{role}", handlers.AdminGrantPermission).Methods("PUT")
+	adminRoutes.HandleFunc("/permissions/{permission}/roles/{role}", handlers.AdminRevokePermission).Methods("DELETE")
+	// Mining sites; renaming one carries the new name to everything assigned to it
+	adminRoutes.HandleFunc("/sites", handlers.AdminCreateSite).Methods("POST")
+	adminRoutes.HandleFunc("/sites", handlers.AdminGetSites).Methods("GET")
+	adminRoutes.HandleFunc("/sites/{id}", handlers.AdminUpdateSite).Methods("PUT")
+	// Upload size, length, format and virus scanning policy, with per-site video limits
+	adminRoutes.HandleFunc("/upload-policy", handlers.AdminGetUploadPolicy).Methods("GET")
+	adminRoutes.HandleFunc("/upload-policy", handlers.AdminUpdateUploadPolicy).Methods("PUT")
+	adminRoutes.HandleFunc("/sites/{id}/upload-limits", handlers.AdminGetSiteUploadLimits).Methods("GET")
+	adminRoutes.HandleFunc("/sites/{id}/upload-limits", handlers.AdminUpdateSiteUploadLimits).Methods("PUT")
+	// Shift windows restricting miner checklist/PPE submissions
+	adminRoutes.HandleFunc("/shift-windows", handlers.CreateShiftWindow).Methods("POST")
+	adminRoutes.HandleFunc("/shift-windows", handlers.GetShiftWindows).Methods("GET")
+	adminRoutes.HandleFunc("/shift-windows/{id}", handlers.DeleteShiftWindow).Methods("DELETE")
+	// Mobile app version gating
+	adminRoutes.HandleFunc("/app-versions/{platform}", handlers.UpdateAppVersionPolicy).Methods("PUT")
+	// Feature flags (per site, per role, percentage rollouts)
+	adminRoutes.HandleFunc("/flags", handlers.AdminGetFeatureFlags).Methods("GET")
+	adminRoutes.HandleFunc("/flags/{key}", handlers.AdminUpsertFeatureFlag).Methods("PUT")
+	adminRoutes.HandleFunc("/flags/{key}", handlers.AdminDeleteFeatureFlag).Methods("DELETE")
+	// Moderation queue, bulk actions and audit log
+	adminRoutes.HandleFunc("/moderation", handlers.AdminGetModerationQueue).Methods("GET")
+	adminRoutes.HandleFunc("/moderation/actions", handlers.AdminModerateContent).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/actions", handlers.AdminGetModerationActions).Methods("GET")
+	// A/B experiments and their per-variant results
+	adminRoutes.HandleFunc("/experiments", handlers.AdminGetExperiments).Methods("GET")
+	adminRoutes.HandleFunc("/experiments/{key}", handlers.AdminUpsertExperiment).Methods("PUT")
+	adminRoutes.HandleFunc("/experiments/{key}/results", handlers.AdminGetExperimentResults).Methods("GET")
+	// Maintenance / read-only mode
+	adminRoutes.HandleFunc("/maintenance", handlers.GetMaintenanceMode).Methods("GET")
+	adminRoutes.HandleFunc("/maintenance", handlers.SetMaintenanceMode).Methods("PUT")
+	// Redacted runtime configuration
+	adminRoutes.HandleFunc("/config", handlers.AdminGetConfig).Methods("GET")
+	// Startup self-check report
+	adminRoutes.HandleFunc("/diagnostics", handlers.AdminGetDiagnostics).Methods("GET")
+	// Recompute the daily compliance summary for a date range
+	adminRoutes.HandleFunc("/compliance/refresh", handlers.AdminRefreshComplianceSummary).Methods("POST")
+	// Compare sites on normalized safety KPIs with percentile rankings
+	adminRoutes.HandleFunc("/benchmark", handlers.AdminGetBenchmark).Methods("GET")
+	// Registration codes for new admin accounts, with which admins each one created
+	adminRoutes.HandleFunc("/codes", handlers.CreateAdminCode).Methods("POST")
+	adminRoutes.HandleFunc("/codes", handlers.GetAdminCodes).Methods("GET")
+	adminRoutes.HandleFunc("/codes/{id}", handlers.RevokeAdminCode).Methods("DELETE")
+	// Lower-resolution media renditions from the media pipeline, served for ?quality=low or Save-Data
+	adminRoutes.HandleFunc("/media/renditions", handlers.RegisterMediaRendition).Methods("PUT")
+	// Transcode, thumbnail and caption job progress and failures from the media pipeline
+	adminRoutes.HandleFunc("/media/jobs", handlers.ReportMediaJob).Methods("PUT")
+	// JWT signing key rotation
+	adminRoutes.HandleFunc("/jwt/keys", handlers.GetSigningKeys).Methods("GET")
+	adminRoutes.HandleFunc("/jwt/rotate", handlers.RotateSigningKey).Methods("POST")
+	// Recompute denormalized video counters from source tables
+	adminRoutes.HandleFunc("/counters/reconcile", handlers.ReconcileCounters).Methods("POST")
+	// Module quiz passing score, daily attempt limit and cooldown
+	adminRoutes.HandleFunc("/module-retake-policy", handlers.GetRetakePolicy).Methods("GET")
+	adminRoutes.HandleFunc("/module-retake-policy", handlers.UpdateRetakePolicy).Methods("PUT")
+	// Supervisor acknowledgment times
+	adminRoutes.HandleFunc("/emergencies/ack-stats", handlers.GetEmergencyAckStats).Methods("GET")
+	// App error reports per release
+	adminRoutes.HandleFunc("/error-reports", handlers.AdminGetErrorReports).Methods("GET")
+	// Stale emergency policy
+	adminRoutes.HandleFunc("/emergency-stale-policy", handlers.GetEmergencyStalePolicy).Methods("GET")
+	adminRoutes.HandleFunc("/emergency-stale-policy", handlers.UpdateEmergencyStalePolicy).Methods("PUT")
 
 	//app routes
 	//integrations := router.PathPrefix("/application").Subrouter()
@@ -206,10 +717,12 @@ func main() {
 	// Apply logging middleware
 	router.Use(middleware.LoggingMiddleware)
 	router.Use(middleware.RateLimitMiddleware)
+	router.Use(middleware.MaintenanceMiddleware)
+	router.Use(middleware.BodyLimitMiddleware)
 
 	// Configure CORS
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins: getAllowedOrigins(),
+		AllowedOrigins: cfg.AllowedOrigins,
 		AllowedMethods: []string{
 			http.MethodGet,
 			http.MethodPost,
@@ -222,26 +735,32 @@ func main() {
 			"Authorization",
 			"Content-Type",
 			"X-CSRF-Token",
+			"X-App-Version",
+			"X-Device-Model",
+			"X-Device-ID",
+			"X-Platform",
+			"X-Integrity-Provider",
+			"X-Integrity-Verdict",
+			"X-Request-ID",
+			"X-Client-ID",
+			"X-Idempotency-Key",
+			"X-Captcha-Token",
+			"Save-Data",
+			"X-API-Version",
 		},
 		ExposedHeaders: []string{
 			"Link",
+			"X-Request-ID",
+			"X-API-Version",
+			"Deprecation",
+			"Sunset",
+			"Idempotent-Replayed",
 		},
 		AllowCredentials: true,
 		MaxAge:           300,
 	})
 
-	handler := corsHandler.Handler(router)
-
-	// Get port from environment
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("Server starting on port %s...", port)
-	if err := http.ListenAndServe(":"+port, handler); err != nil {
-		log.Fatal("Failed to start server:", err)
-	}
+	return corsHandler.Handler(versioned)
 }
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
@@ -250,38 +769,21 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"healthy","service":"MineSafe Backend"}`))
 }
 
-func getAllowedOrigins() []string {
-	origins := os.Getenv("ALLOWED_ORIGINS")
-	if origins == "" {
-		// Default allowed origins for development
-		return []string{
-			"*",
+// apiVersions lists the API versions served and when deprecated ones are retired
+func apiVersions(w http.ResponseWriter, r *http.Request) {
+	versions := []map[string]interface{}{}
+	for v := middleware.APIVersion1; v <= middleware.LatestAPIVersion; v++ {
+		entry := map[string]interface{}{"version": v, "prefix": "/api/v" + strconv.Itoa(v) + "/"}
+		if sunset, ok := middleware.APISunset(v); ok {
+			entry["deprecated"] = true
+			entry["sunset"] = sunset
 		}
+		versions = append(versions, entry)
 	}
-
-	// Parse comma-separated origins from environment
-	return parseCommaSeparated(origins)
-}
-
-func parseCommaSeparated(s string) []string {
-	if s == "" {
-		return []string{}
-	}
-
-	var result []string
-	current := ""
-	for _, char := range s {
-		if char == ',' {
-			if current != "" {
-				result = append(result, current)
-				current = ""
-			}
-		} else if char != ' ' {
-			current += string(char)
-		}
-	}
-	if current != "" {
-		result = append(result, current)
-	}
-	return result
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"versions": versions,
+		"default":  middleware.DefaultAPIVersion,
+		"latest":   middleware.LatestAPIVersion,
+	})
 }
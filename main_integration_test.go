@@ -0,0 +1,201 @@
+//go:build integration
+
+// Integration tests run the full router against a real Postgres database:
+//
+//	go test -tags integration ./...
+//
+// A disposable container is started with docker unless TEST_DATABASE_URL is set.
+package main
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/handlers"
+	"MineSafeBackend/internal/testutil"
+	"MineSafeBackend/middleware"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var api *testutil.Client
+
+func TestMain(m *testing.M) {
+	os.Exit(runIntegrationTests(m))
+}
+
+func runIntegrationTests(m *testing.M) int {
+	pg, err := testutil.StartPostgres()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "integration tests need docker or TEST_DATABASE_URL:", err)
+		return 1
+	}
+	defer pg.Stop()
+
+	os.Setenv("APP_ENV", "development")
+	os.Setenv("DATABASE_URL", pg.DSN)
+	os.Setenv("JWT_SECRET", "integration-test-secret-at-least-32-chars")
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := database.InitDB(); err != nil {
+		fmt.Fprintln(os.Stderr, "migrations failed:", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	middleware.InitJWT(cfg.JWTSecret)
+	if err := handlers.LoadSigningKeys(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	api = testutil.NewClient(newRouter(cfg))
+	return m.Run()
+}
+
+func TestHealthCheck(t *testing.T) {
+	api.Do(t, http.MethodGet, "/api/health", nil).Expect(t, http.StatusOK)
+}
+
+func TestProtectedRoutesRequireAuth(t *testing.T) {
+	api.Do(t, http.MethodGet, "/api/me", nil).Expect(t, http.StatusUnauthorized)
+	api.As("not-a-token", "", "").Do(t, http.MethodGet, "/api/me", nil).Expect(t, http.StatusUnauthorized)
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	supervisor := api.SignupSupervisor(t, testutil.UniqueSite("Jharia"))
+
+	var me struct {
+		Email string `json:"email"`
+	}
+	supervisor.Do(t, http.MethodGet, "/api/me", nil).Expect(t, http.StatusOK).JSON(t, &me)
+
+	api.Do(t, http.MethodPost, "/api/auth/login", map[string]string{
+		"email":    me.Email,
+		"password": "wrong-password",
+	}).Expect(t, http.StatusUnauthorized)
+}
+
+// TestSignupCannotJoinAnotherTenantsSite checks that naming a registered site at signup
+// doesn't put the new supervisor in that site's organization
+func TestSignupCannotJoinAnotherTenantsSite(t *testing.T) {
+	site := testutil.UniqueSite("Jharia")
+	api.SignupSupervisor(t, site)
+
+	var resp struct {
+		Error string `json:"error"`
+	}
+	api.Do(t, http.MethodPost, "/api/auth/signup", map[string]string{
+		"name":        "Intruder",
+		"email":       testutil.UniqueEmail("intruder"),
+		"phone":       testutil.UniquePhone(),
+		"password":    "intruder-password",
+		"mining_site": " " + strings.ToUpper(site) + " ",
+		"location":    "Elsewhere",
+	}).Expect(t, http.StatusConflict).JSON(t, &resp)
+	if !strings.Contains(resp.Error, "mining site") {
+		t.Fatalf("signup refused with %q, want the site refusal", resp.Error)
+	}
+}
+
+// TestSupervisorMinerChecklistEmergencyFlow covers the core day: a supervisor signs up and
+// creates a miner, the miner completes the pre-start checklist and reports an emergency,
+// and the supervisor sees the report.
+func TestSupervisorMinerChecklistEmergencyFlow(t *testing.T) {
+	site := testutil.UniqueSite("Jharia")
+	supervisor := api.SignupSupervisor(t, site)
+
+	// Create a miner
+	minerEmail := testutil.UniqueEmail("miner")
+	var miner struct {
+		UserID       string `json:"user_id"`
+		SupervisorID string `json:"supervisor_id"`
+		MiningSite   string `json:"mining_site"`
+	}
+	supervisor.Do(t, http.MethodPost, "/api/miners", map[string]string{
+		"name":     "Test Miner",
+		"email":    minerEmail,
+		"phone":    "+910000000001",
+		"password": "miner-password",
+	}).Expect(t, http.StatusCreated).JSON(t, &miner)
+
+	if miner.SupervisorID != supervisor.UserID {
+		t.Fatalf("miner supervisor = %q, want %q", miner.SupervisorID, supervisor.UserID)
+	}
+	if miner.MiningSite != site {
+		t.Fatalf("miner mining site = %q, want inherited %q", miner.MiningSite, site)
+	}
+
+	minerClient := api.Login(t, minerEmail, "miner-password")
+	if minerClient.UserID != miner.UserID {
+		t.Fatalf("logged in as %q, want %q", minerClient.UserID, miner.UserID)
+	}
+
+	// Miners can't use supervisor routes
+	minerClient.Do(t, http.MethodGet, "/api/miners", nil).Expect(t, http.StatusForbidden)
+
+	// Supervisor adds a pre-start checklist item
+	var item struct {
+		ID int `json:"id"`
+	}
+	supervisor.Do(t, http.MethodPost, "/api/checklists/pre-start", map[string]string{
+		"title":       "Check gas detector",
+		"description": "Bump test before entering",
+	}).Expect(t, http.StatusCreated).JSON(t, &item)
+
+	// Miner completes it
+	minerClient.Do(t, http.MethodPut, "/api/app/checklists/pre-start/complete", map[string]interface{}{
+		"item_id":      item.ID,
+		"is_completed": true,
+	}).Expect(t, http.StatusOK)
+
+	var checklist []struct {
+		ID          int  `json:"id"`
+		IsCompleted bool `json:"is_completed"`
+	}
+	minerClient.Do(t, http.MethodGet, "/api/app/checklists/pre-start", nil).Expect(t, http.StatusOK).JSON(t, &checklist)
+	completed := false
+	for _, i := range checklist {
+		if i.ID == item.ID {
+			completed = i.IsCompleted
+		}
+	}
+	if !completed {
+		t.Fatalf("checklist item %d not marked completed: %+v", item.ID, checklist)
+	}
+
+	// Miner reports an emergency; resending it is deduplicated
+	emergencyID := int(time.Now().Unix() % 1000000)
+	report := map[string]interface{}{
+		"user_id":      miner.UserID,
+		"emergency_id": emergencyID,
+		"severity":     "HIGH",
+		"issue":        "Roof fall near panel 4",
+	}
+	var emergency struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	minerClient.Do(t, http.MethodPost, "/api/emergencies", report).Expect(t, http.StatusCreated).JSON(t, &emergency)
+	if emergency.Status != "PENDING" {
+		t.Fatalf("new emergency status = %q, want PENDING", emergency.Status)
+	}
+
+	var duplicate struct {
+		Duplicate bool `json:"duplicate"`
+	}
+	minerClient.Do(t, http.MethodPost, "/api/emergencies", report).Expect(t, http.StatusOK).JSON(t, &duplicate)
+	if !duplicate.Duplicate {
+		t.Fatal("resent emergency was not reported as a duplicate")
+	}
+
+	// Supervisor can open the report
+	supervisor.Do(t, http.MethodGet, fmt.Sprintf("/api/emergencies/%d", emergency.ID), nil).Expect(t, http.StatusOK)
+}
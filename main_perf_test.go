@@ -0,0 +1,90 @@
+//go:build perf
+
+// Perf tests replay the load scenarios in package perf and fail when a step exceeds its SLO:
+//
+//	go test -tags perf -run MorningRush -timeout 30m .
+//
+// Like the integration tests they use TEST_DATABASE_URL or a disposable Postgres container.
+package main
+
+import (
+	"MineSafeBackend/config"
+	"MineSafeBackend/database"
+	"MineSafeBackend/handlers"
+	"MineSafeBackend/internal/testutil"
+	"MineSafeBackend/middleware"
+	"MineSafeBackend/perf"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestMorningRush replays perf.MorningRush against a freshly seeded site.
+// PERF_VIRTUAL_USERS and PERF_RAMP_UP_SECONDS shrink the scenario for a quick local run;
+// the budgets stay the same. PERF_TARGET_URL runs it against a deployed server instead,
+// using the miners created there by "go run . seed-loadtest".
+func TestMorningRush(t *testing.T) {
+	scenario := perf.MorningRush
+	if n, err := strconv.Atoi(os.Getenv("PERF_VIRTUAL_USERS")); err == nil && n > 0 {
+		scenario.VirtualUsers = n
+	}
+	if s, err := strconv.Atoi(os.Getenv("PERF_RAMP_UP_SECONDS")); err == nil && s >= 0 {
+		scenario.RampUp = time.Duration(s) * time.Second
+	}
+
+	baseURL := os.Getenv("PERF_TARGET_URL")
+	if baseURL == "" {
+		baseURL = startPerfServer(t)
+		if err := database.SeedLoadTestMiners(scenario.VirtualUsers); err != nil {
+			t.Fatalf("seeding miners: %v", err)
+		}
+	}
+
+	users := make([]perf.Credentials, scenario.VirtualUsers)
+	for i := range users {
+		users[i] = perf.Credentials{Email: database.LoadTestMinerEmail(i), Password: database.LoadTestPassword}
+	}
+
+	results := perf.Run(scenario, baseURL, users)
+	for _, r := range results {
+		t.Logf("%-22s requests=%-5d errors=%-4d p50=%-10v p95=%-10v p99=%v",
+			r.Step, r.Requests, r.Errors, r.P50, r.P95, r.P99)
+	}
+	for _, v := range perf.Violations(results) {
+		t.Errorf("SLO violated: %s", v)
+	}
+}
+
+// startPerfServer serves the full router over HTTP against a throwaway database
+func startPerfServer(t *testing.T) string {
+	t.Helper()
+	pg, err := testutil.StartPostgres()
+	if err != nil {
+		t.Skipf("perf tests need docker or TEST_DATABASE_URL: %v", err)
+	}
+	t.Cleanup(pg.Stop)
+
+	os.Setenv("APP_ENV", "development")
+	os.Setenv("DATABASE_URL", pg.DSN)
+	os.Setenv("JWT_SECRET", "perf-test-secret-at-least-32-characters")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("migrations failed: %v", err)
+	}
+	t.Cleanup(func() { database.CloseDB() })
+
+	middleware.InitJWT(cfg.JWTSecret)
+	if err := handlers.LoadSigningKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(newRouter(cfg))
+	t.Cleanup(server.Close)
+	return server.URL
+}
@@ -2,62 +2,285 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type contextKey string
 
 const UserIDKey contextKey = "userID"
 const UserRoleKey contextKey = "userRole"
+const TokenKey contextKey = "token"
+const OrgIDKey contextKey = "orgID"
 
-var jwtSecret []byte
+// LegacyKeyID identifies the configured JWT_SECRET. Tokens signed with it carry no kid header.
+const LegacyKeyID = "legacy"
 
-func InitJWT() {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-in-production"
+// TokenLifetime is how long a regular session token stays valid unless configured per audience
+const TokenLifetime = time.Hour * 24 * 7 // 7 days
+
+// Client apps tokens are issued to, carried in the aud and client_id claims
+const (
+	AudienceDashboard = "minesafe-dashboard"
+	AudienceMobile    = "minesafe-mobile"
+)
+
+// TokenVersion is the claims format of new tokens. Tokens without a ver claim predate
+// audiences and count as version 1.
+const TokenVersion = 2
+
+var (
+	tokenPolicyMu   sync.RWMutex
+	tokenLifetimes  = map[string]time.Duration{AudienceDashboard: TokenLifetime, AudienceMobile: TokenLifetime}
+	minTokenVersion = 1
+)
+
+// SetTokenPolicy sets how long tokens for each audience last and the oldest claims version
+// still accepted; raising it to TokenVersion rejects tokens issued without an audience
+func SetTokenPolicy(lifetimes map[string]time.Duration, minVersion int) {
+	tokenPolicyMu.Lock()
+	defer tokenPolicyMu.Unlock()
+	for aud, lifetime := range lifetimes {
+		tokenLifetimes[aud] = lifetime
+	}
+	minTokenVersion = minVersion
+}
+
+// TokenLifetimeFor is how long a new token for audience stays valid
+func TokenLifetimeFor(audience string) time.Duration {
+	tokenPolicyMu.RLock()
+	defer tokenPolicyMu.RUnlock()
+	if lifetime, ok := tokenLifetimes[audience]; ok {
+		return lifetime
+	}
+	return TokenLifetime
+}
+
+// MaxTokenLifetime is the longest any token issued now can stay valid
+func MaxTokenLifetime() time.Duration {
+	tokenPolicyMu.RLock()
+	defer tokenPolicyMu.RUnlock()
+	longest := TokenLifetime
+	for _, lifetime := range tokenLifetimes {
+		if lifetime > longest {
+			longest = lifetime
+		}
 	}
-	jwtSecret = []byte(secret)
+	return longest
+}
+
+// IsAudience reports whether aud is a client app tokens are issued to
+func IsAudience(aud string) bool {
+	return aud == AudienceDashboard || aud == AudienceMobile
+}
+
+// SigningKey is an HMAC key used to sign or verify tokens
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+var (
+	keyMu            sync.RWMutex
+	signingKey       SigningKey
+	verificationKeys map[string][]byte
+)
+
+// TokenInfo identifies the token a request was authenticated with. ID is empty for tokens
+// issued before tokens carried a jti, Audience for tokens issued before audiences.
+// ImpersonatedBy is the admin acting as the user, empty for the user's own tokens.
+// FromCookie is set when the token came from the session cookie rather than a header.
+type TokenInfo struct {
+	ID             string
+	IssuedAt       time.Time
+	ExpiresAt      time.Time
+	Audience       string
+	Version        int
+	ImpersonatedBy string
+	FromCookie     bool
+	// Device is the fingerprint of the device the token is bound to, if any
+	Device string
+}
+
+// RevocationChecker reports whether a token has been revoked
+type RevocationChecker func(userID string, token TokenInfo) bool
+
+var isRevoked RevocationChecker
+
+// SetRevocationChecker makes AuthMiddleware reject revoked tokens
+func SetRevocationChecker(check RevocationChecker) {
+	isRevoked = check
+}
+
+// SessionTracker is told about every authenticated request, e.g. to update a device's last seen time
+type SessionTracker func(r *http.Request, userID string, token TokenInfo)
+
+var trackSession SessionTracker
+
+// SetSessionTracker makes AuthMiddleware report authenticated requests to track
+func SetSessionTracker(track SessionTracker) {
+	trackSession = track
+}
+
+// OrganizationResolver looks up the organization (tenant) a user belongs to; 0 means none
+type OrganizationResolver func(userID string) (int, error)
+
+var resolveOrganization OrganizationResolver
+
+// SetOrganizationResolver makes AuthMiddleware put the caller's organization in the context
+func SetOrganizationResolver(resolve OrganizationResolver) {
+	resolveOrganization = resolve
+}
+
+func InitJWT(secret string) {
+	legacy := SigningKey{ID: LegacyKeyID, Secret: []byte(secret)}
+	SetSigningKeys(legacy, []SigningKey{legacy})
+}
+
+// SetSigningKeys replaces the key used for new tokens and the set of keys accepted when
+// verifying, so signing keys can be rotated without invalidating existing sessions
+func SetSigningKeys(active SigningKey, verify []SigningKey) {
+	keys := make(map[string][]byte, len(verify)+1)
+	for _, k := range verify {
+		keys[k.ID] = k.Secret
+	}
+	keys[active.ID] = active.Secret
+
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	signingKey = active
+	verificationKeys = keys
+}
+
+func lookupVerificationKey(kid string) ([]byte, bool) {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	secret, ok := verificationKeys[kid]
+	return secret, ok
+}
+
+// GenerateToken issues a session token for one client app, valid for that app's lifetime
+func GenerateToken(userID string, role string, audience string) (string, error) {
+	return GenerateTokenWithExpiry(userID, role, audience, time.Now().Add(TokenLifetimeFor(audience)))
+}
+
+// GenerateTokenWithExpiry issues a token that expires at a fixed time,
+// used for temporary accounts such as site visitors
+func GenerateTokenWithExpiry(userID string, role string, audience string, expiresAt time.Time) (string, error) {
+	token, _, err := GenerateSessionToken(userID, role, audience, expiresAt)
+	return token, err
+}
+
+// GenerateSessionToken issues a token and also returns its jti and times, so the session
+// it starts can be recorded
+func GenerateSessionToken(userID string, role string, audience string, expiresAt time.Time) (string, TokenInfo, error) {
+	return generateToken(userID, role, audience, "", "", expiresAt)
+}
+
+// GenerateDeviceToken issues a session token bound to a registered device. It is only
+// accepted from requests whose X-Device-ID has the given fingerprint, so a copied token is
+// useless on another phone.
+func GenerateDeviceToken(userID string, role string, audience string, device string, expiresAt time.Time) (string, TokenInfo, error) {
+	return generateToken(userID, role, audience, "", device, expiresAt)
+}
+
+// DeviceFingerprint is the form a device ID is bound into tokens with
+func DeviceFingerprint(deviceID string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(deviceID)))
+	return hex.EncodeToString(sum[:])
 }
 
-func GenerateToken(userID string, role string) (string, error) {
+// GenerateImpersonationToken issues a token that lets an admin act as the user. It carries
+// an impersonated_by claim so the app can show a banner and handlers can refuse actions
+// only the user should take.
+func GenerateImpersonationToken(userID string, role string, audience string, adminID string, expiresAt time.Time) (string, TokenInfo, error) {
+	return generateToken(userID, role, audience, adminID, "", expiresAt)
+}
+
+func generateToken(userID, role, audience, impersonatedBy, device string, expiresAt time.Time) (string, TokenInfo, error) {
+	if !IsAudience(audience) {
+		return "", TokenInfo{}, fmt.Errorf("unknown token audience: %s", audience)
+	}
+	info := TokenInfo{
+		ID:             uuid.New().String(),
+		IssuedAt:       time.Now().Truncate(time.Second),
+		ExpiresAt:      expiresAt.Truncate(time.Second),
+		Audience:       audience,
+		Version:        TokenVersion,
+		ImpersonatedBy: impersonatedBy,
+		Device:         device,
+	}
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
-		"iat":     time.Now().Unix(),
+		"user_id":   userID,
+		"role":      role,
+		"aud":       audience,
+		"client_id": audience,
+		"ver":       TokenVersion,
+		"exp":       info.ExpiresAt.Unix(),
+		"iat":       info.IssuedAt.Unix(),
+		"jti":       info.ID,
 	}
+	if impersonatedBy != "" {
+		claims["impersonated_by"] = impersonatedBy
+	}
+	if device != "" {
+		claims["dev"] = device
+	}
+
+	keyMu.RLock()
+	key := signingKey
+	keyMu.RUnlock()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	if key.ID != LegacyKeyID {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.Secret)
+	return signed, info, err
 }
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tokenString string
+		fromCookie := false
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
-
-		bearerToken := strings.Split(authHeader, " ")
-		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
-			return
+			cookie, err := r.Cookie(SessionCookieName)
+			if cookieSessions == nil || err != nil || cookie.Value == "" {
+				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				return
+			}
+			tokenString, fromCookie = cookie.Value, true
+		} else {
+			bearerToken := strings.Split(authHeader, " ")
+			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+				http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+				return
+			}
+			tokenString = bearerToken[1]
 		}
 
-		tokenString := bearerToken[1]
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return jwtSecret, nil
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				kid = LegacyKeyID
+			}
+			secret, ok := lookupVerificationKey(kid)
+			if !ok {
+				return nil, fmt.Errorf("unknown or retired signing key: %s", kid)
+			}
+			return secret, nil
 		})
 
 		if err != nil || !token.Valid {
@@ -83,13 +306,84 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		info, err := tokenClaimsInfo(claims)
+		if err != nil {
+			http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		info.ID, _ = claims["jti"].(string)
+		info.ImpersonatedBy, _ = claims["impersonated_by"].(string)
+		info.FromCookie = fromCookie
+		info.Device, _ = claims["dev"].(string)
+		if info.Device != "" && DeviceFingerprint(r.Header.Get("X-Device-ID")) != info.Device {
+			http.Error(w, "Token is bound to another device", http.StatusUnauthorized)
+			return
+		}
+		if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+			info.IssuedAt = iat.Time
+		}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			info.ExpiresAt = exp.Time
+		}
+		if isRevoked != nil && isRevoked(userID, info) {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+		if trackSession != nil {
+			trackSession(r, userID, info)
+		}
+		// Handlers scope their queries to this, so a failed lookup refuses the request
+		orgID := 0
+		if resolveOrganization != nil {
+			if orgID, err = resolveOrganization(userID); err != nil {
+				http.Error(w, "Could not determine organization", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
 		ctx = context.WithValue(ctx, UserRoleKey, role)
+		ctx = context.WithValue(ctx, TokenKey, info)
+		ctx = context.WithValue(ctx, OrgIDKey, orgID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// tokenClaimsInfo checks the audience, client_id and ver claims against the token policy
+func tokenClaimsInfo(claims jwt.MapClaims) (TokenInfo, error) {
+	info := TokenInfo{Version: 1}
+	if ver, ok := claims["ver"]; ok {
+		v, isNumber := ver.(float64)
+		if !isNumber || v != float64(int(v)) {
+			return info, fmt.Errorf("malformed ver claim")
+		}
+		info.Version = int(v)
+	}
+
+	tokenPolicyMu.RLock()
+	minVersion := minTokenVersion
+	tokenPolicyMu.RUnlock()
+	if info.Version < minVersion || info.Version > TokenVersion {
+		return info, fmt.Errorf("token version %d is no longer accepted, log in again", info.Version)
+	}
+
+	audiences, err := claims.GetAudience()
+	if err != nil || len(audiences) > 1 {
+		return info, fmt.Errorf("malformed aud claim")
+	}
+	if len(audiences) == 1 {
+		info.Audience = audiences[0]
+	}
+	if info.Version >= 2 {
+		clientID, _ := claims["client_id"].(string)
+		if !IsAudience(info.Audience) || clientID != info.Audience {
+			return info, fmt.Errorf("unknown audience")
+		}
+	}
+	return info, nil
+}
+
 func SupervisorOnly(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		role := r.Context().Value(UserRoleKey)
@@ -112,6 +406,21 @@ func AdminOnly(next http.Handler) http.Handler {
 	})
 }
 
+// AuditorReadOnly keeps AUDITOR tokens to reading the auditor API. Any other request made
+// with one is refused, whatever permissions the role has been granted.
+func AuditorReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(UserRoleKey) == "AUDITOR" {
+			path, _ := SplitAPIVersion(r.URL.Path)
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !strings.HasPrefix(path, "/api/audit/") {
+				http.Error(w, "Auditor tokens can only read /api/audit", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	userID, ok := ctx.Value(UserIDKey).(string)
 	return userID, ok
@@ -121,3 +430,14 @@ func GetUserRoleFromContext(ctx context.Context) (string, bool) {
 	role, ok := ctx.Value(UserRoleKey).(string)
 	return role, ok
 }
+
+func GetTokenFromContext(ctx context.Context) (TokenInfo, bool) {
+	info, ok := ctx.Value(TokenKey).(TokenInfo)
+	return info, ok
+}
+
+// GetOrgIDFromContext returns the caller's organization; 0 when they don't belong to one
+func GetOrgIDFromContext(ctx context.Context) int {
+	orgID, _ := ctx.Value(OrgIDKey).(int)
+	return orgID
+}
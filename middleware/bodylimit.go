@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultBodyLimit applies to every JSON endpoint not listed in uploadBodyLimits
+	DefaultBodyLimit int64 = 1 << 20 // 1MB
+
+	// Multipart uploads are capped slightly above the file size the handler accepts
+	// so the form fields and multipart framing still fit.
+	imageUploadBodyLimit int64 = 11 << 20  // 10MB files
+	videoUploadBodyLimit int64 = 512 << 20 // 500MB videos
+)
+
+// uploadBodyLimits lists the routes that accept file uploads and their body limits
+var uploadBodyLimits = map[string]int64{
+	"/api/videos/upload":       videoUploadBodyLimit,
+	"/api/app/profile/picture": imageUploadBodyLimit,
+	"/api/documents":           imageUploadBodyLimit,
+}
+
+// uploadBodyLimitPatterns covers upload routes with an ID in the path, by prefix and suffix
+var uploadBodyLimitPatterns = []struct {
+	prefix, suffix string
+	limit          int64
+}{
+	{"/api/emergencies/", "/media", videoUploadBodyLimit},
+}
+
+// BodyLimitFor returns the maximum request body size for a path
+func BodyLimitFor(path string) int64 {
+	if limit, ok := uploadBodyLimits[path]; ok {
+		return limit
+	}
+	for _, p := range uploadBodyLimitPatterns {
+		if strings.HasPrefix(path, p.prefix) && strings.HasSuffix(path, p.suffix) {
+			return p.limit
+		}
+	}
+	return DefaultBodyLimit
+}
+
+// BodyLimitMiddleware caps request bodies per route so a client can't exhaust memory by
+// posting a huge JSON payload. Requests that declare a larger Content-Length are rejected
+// up front; chunked bodies are cut off by http.MaxBytesReader once they pass the limit.
+func BodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || isReadOnlyMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := BodyLimitFor(r.URL.Path)
+		if r.ContentLength > limit {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     fmt.Sprintf("Request body too large (limit %d bytes)", limit),
+				"max_bytes": limit,
+			})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,11 +1,29 @@
 package middleware
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"regexp"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// RequestIDHeader carries the request ID to and from clients so app error reports can be
+// matched with server logs
+const RequestIDHeader = "X-Request-ID"
+
+const RequestIDKey contextKey = "requestID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// GetRequestID returns the ID assigned to the current request by LoggingMiddleware
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	return requestID
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -16,10 +34,25 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush lets streaming handlers push data through the wrapper
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		
+
+		// Keep a well-formed ID sent by a proxy or the app, otherwise assign one
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		r = r.WithContext(context.WithValue(r.Context(), RequestIDKey, requestID))
+
 		rw := &responseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
@@ -28,11 +61,12 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r)
 
 		log.Printf(
-			"%s %s %d %s",
+			"%s %s %d %s request_id=%s",
 			r.Method,
 			r.RequestURI,
 			rw.statusCode,
 			time.Since(start),
+			requestID,
 		)
 	})
 }
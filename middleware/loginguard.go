@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loginFailureWindow is how far back failed attempts are counted
+	loginFailureWindow = 15 * time.Minute
+	// maxAccountFailures locks a single account after this many failures in the window
+	maxAccountFailures = 5
+	// maxIPFailures locks an IP after this many failures across any accounts in the window
+	maxIPFailures = 20
+	// loginLockoutDuration is how long a locked account or IP has to wait
+	loginLockoutDuration = 15 * time.Minute
+)
+
+type loginCounter struct {
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+type loginGuard struct {
+	mu       sync.Mutex
+	accounts map[string]*loginCounter
+	ips      map[string]*loginCounter
+}
+
+var guard *loginGuard
+
+// LockoutRecorder is told about each login attempt turned away during a lockout
+type LockoutRecorder func(r *http.Request, account string)
+
+var recordLockout LockoutRecorder
+
+// SetLockoutRecorder makes LoginGuard report attempts it rejects while locked out
+func SetLockoutRecorder(record LockoutRecorder) {
+	recordLockout = record
+}
+
+func InitLoginGuard() {
+	guard = &loginGuard{
+		accounts: make(map[string]*loginCounter),
+		ips:      make(map[string]*loginCounter),
+	}
+
+	// Forget counters nobody has touched for a while
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			guard.cleanup()
+		}
+	}()
+}
+
+func (g *loginGuard) cleanup() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for _, counters := range []map[string]*loginCounter{g.accounts, g.ips} {
+		for key, c := range counters {
+			c.prune(now)
+			if len(c.failures) == 0 && now.After(c.lockedUntil) {
+				delete(counters, key)
+			}
+		}
+	}
+}
+
+func (c *loginCounter) prune(now time.Time) {
+	valid := c.failures[:0]
+	for _, t := range c.failures {
+		if now.Sub(t) < loginFailureWindow {
+			valid = append(valid, t)
+		}
+	}
+	c.failures = valid
+}
+
+// lockedFor returns how long the account or IP is still locked out, if at all
+func (g *loginGuard) lockedFor(account, ip string) (time.Duration, string) {
+	if sharedCounters != nil {
+		for _, s := range [][2]string{{"account", account}, {"ip", ip}} {
+			scope, key := s[0], s[1]
+			if key == "" {
+				continue
+			}
+			if n, until, err := sharedCounters.Get("login:lock:" + scope + ":" + key); err == nil && n > 0 {
+				return time.Until(until), scope
+			}
+		}
+		return 0, ""
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if c, ok := g.accounts[account]; ok && now.Before(c.lockedUntil) {
+		return c.lockedUntil.Sub(now), "account"
+	}
+	if c, ok := g.ips[ip]; ok && now.Before(c.lockedUntil) {
+		return c.lockedUntil.Sub(now), "ip"
+	}
+	return 0, ""
+}
+
+func (g *loginGuard) recordFailure(account, ip string) {
+	if sharedCounters != nil {
+		g.bumpShared("account", account, maxAccountFailures)
+		g.bumpShared("ip", ip, maxIPFailures)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	g.bump(g.accounts, account, maxAccountFailures, now)
+	g.bump(g.ips, ip, maxIPFailures, now)
+}
+
+func (g *loginGuard) bump(counters map[string]*loginCounter, key string, max int, now time.Time) {
+	if key == "" {
+		return
+	}
+	c, ok := counters[key]
+	if !ok {
+		c = &loginCounter{}
+		counters[key] = c
+	}
+	c.prune(now)
+	c.failures = append(c.failures, now)
+	if len(c.failures) >= max {
+		c.lockedUntil = now.Add(loginLockoutDuration)
+		c.failures = nil
+	}
+}
+
+// bumpShared counts a failure in the shared store and locks the key out at max failures.
+// Shared counters use fixed windows, so the limit applies per 15-minute window rather than
+// to any sliding 15 minutes.
+func (g *loginGuard) bumpShared(scope, key string, max int) {
+	if key == "" {
+		return
+	}
+	n, _, err := sharedCounters.Incr("login:fail:"+scope+":"+key, loginFailureWindow)
+	if err != nil || n < max {
+		return
+	}
+	sharedCounters.Incr("login:lock:"+scope+":"+key, loginLockoutDuration)
+	sharedCounters.Reset("login:fail:" + scope + ":" + key)
+}
+
+// recordSuccess clears the account's failures. IP failures are kept so one valid
+// account can't be used to reset the counter while guessing others.
+func (g *loginGuard) recordSuccess(account string) {
+	if sharedCounters != nil {
+		sharedCounters.Reset("login:fail:account:" + account)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.accounts, account)
+}
+
+// statusRecorder captures the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// LoginGuard protects a login handler against brute force with per-account and per-IP
+// failure counters. A 401 from the handler counts as a failure; once either counter
+// reaches its limit further attempts get a 429 until the lockout expires.
+func LoginGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if guard == nil {
+			next(w, r)
+			return
+		}
+
+		// Peek at the email so the handler still sees the full body
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var creds struct {
+			Email string `json:"email"`
+		}
+		json.Unmarshal(body, &creds)
+		account := strings.ToLower(strings.TrimSpace(creds.Email))
+		ip := remoteIP(r)
+
+		if retryAfter, scope := guard.lockedFor(account, ip); retryAfter > 0 {
+			if recordLockout != nil {
+				recordLockout(r, account)
+			}
+			secs := int(retryAfter.Seconds()) + 1
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":               "Too many failed login attempts. Try again later.",
+				"locked":              scope,
+				"retry_after_seconds": secs,
+			})
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		switch {
+		case rec.status == http.StatusUnauthorized:
+			guard.recordFailure(account, ip)
+		case rec.status >= 200 && rec.status < 300:
+			guard.recordSuccess(account)
+		}
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
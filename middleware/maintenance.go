@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceState describes whether the API is currently read-only
+type MaintenanceState struct {
+	Enabled     bool       `json:"enabled"`
+	Message     string     `json:"message"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	ExpectedEnd *time.Time `json:"expected_end,omitempty"`
+}
+
+var (
+	maintenanceMu    sync.RWMutex
+	maintenanceState MaintenanceState
+)
+
+// Writes that stay available during maintenance: emergency reporting, logging in,
+// and the admin endpoint that turns maintenance off again.
+var maintenanceWriteAllowlist = []string{
+	"/api/emergencies",
+	"/api/auth/login",
+	"/api/admin/login",
+	"/api/app/miner/login",
+	"/api/admin/maintenance",
+}
+
+func SetMaintenanceState(state MaintenanceState) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	maintenanceState = state
+}
+
+func GetMaintenanceState() MaintenanceState {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+	return maintenanceState
+}
+
+// MaintenanceMiddleware rejects write requests with 503 while maintenance mode is on.
+// Reads keep working so the apps remain usable during migrations.
+func MaintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := GetMaintenanceState()
+		if !state.Enabled || isReadOnlyMethod(r.Method) || maintenanceAllowed(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if state.ExpectedEnd != nil {
+			if secs := int(time.Until(*state.ExpectedEnd).Seconds()); secs > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(secs))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":        "Service is in maintenance mode",
+			"maintenance":  true,
+			"read_only":    true,
+			"message":      state.Message,
+			"expected_end": state.ExpectedEnd,
+		})
+	})
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func maintenanceAllowed(path string) bool {
+	for _, prefix := range maintenanceWriteAllowlist {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Permission is a right granted to roles, checked instead of comparing role names
+type Permission string
+
+const (
+	// Daily pre-start and PPE checklists; whoever holds it counts as crew on dashboards
+	PermChecklistSubmit Permission = "checklist:submit"
+	// Pre-use checks on a specific piece of equipment
+	PermEquipmentChecklistSubmit Permission = "equipment_checklist:submit"
+	// Creating and editing the equipment checks crew members run
+	PermEquipmentChecklistManage Permission = "equipment_checklist:manage"
+	// Adding operators to a crew
+	PermOperatorManage Permission = "operator:manage"
+	// Moving an emergency between pending, resolving, resolved and cancelled
+	PermEmergencyResolve Permission = "emergency:resolve"
+	// Approving or rejecting uploaded training modules
+	PermModuleApprove Permission = "module:approve"
+	// Changing shift times, checklist frequency, mandatory PPE and star video source for a site
+	PermSiteSettingsManage Permission = "site_settings:manage"
+	// Seeing who reported an emergency in downloaded and forwarded reports
+	PermEmergencyReporterView Permission = "emergency:view_reporter"
+	// Seeing injury details in downloaded and forwarded reports
+	PermEmergencyMedicalView Permission = "emergency:view_medical"
+	// Reading emergencies and training records through the auditor API
+	PermAuditRead Permission = "audit:read"
+)
+
+// PermissionDescriptions lists every permission the server checks, with the description
+// admins see when granting it
+var PermissionDescriptions = map[Permission]string{
+	PermChecklistSubmit:          "Submit daily pre-start and PPE checklists",
+	PermEquipmentChecklistSubmit: "Submit pre-use equipment checks",
+	PermEquipmentChecklistManage: "Create and edit equipment checklists",
+	PermOperatorManage:           "Add operators to a crew",
+	PermEmergencyResolve:         "Change the status of an emergency",
+	PermModuleApprove:            "Approve or reject uploaded training modules",
+	PermSiteSettingsManage:       "Change the settings of their own site",
+	PermEmergencyReporterView:    "See the reporter's identity in exported and forwarded emergency reports",
+	PermEmergencyMedicalView:     "See injury details in exported and forwarded emergency reports",
+	PermAuditRead:                "Read emergencies and training records through the auditor API",
+}
+
+// DefaultRolePermissions are granted the first time a permission is added to the database.
+// After that the role_permissions table decides and admins can change grants at runtime.
+var DefaultRolePermissions = map[string][]Permission{
+	"MINER":      {PermChecklistSubmit},
+	"OPERATOR":   {PermChecklistSubmit, PermEquipmentChecklistSubmit},
+	"SUPERVISOR": {PermEquipmentChecklistManage, PermOperatorManage, PermEmergencyResolve, PermModuleApprove, PermSiteSettingsManage, PermEmergencyReporterView, PermEmergencyMedicalView},
+	"ADMIN":      {PermEmergencyResolve, PermEmergencyReporterView, PermEmergencyMedicalView, PermAuditRead},
+	"AUDITOR":    {PermAuditRead},
+}
+
+var (
+	rolePermissionsMu sync.RWMutex
+	rolePermissions   = DefaultRolePermissions
+)
+
+// SetRolePermissions replaces the grants checked by HasPermission, e.g. after they are
+// loaded from the database or changed by an admin
+func SetRolePermissions(grants map[string][]Permission) {
+	rolePermissionsMu.Lock()
+	rolePermissions = grants
+	rolePermissionsMu.Unlock()
+}
+
+// HasPermission reports whether role has been granted perm
+func HasPermission(role string, perm Permission) bool {
+	rolePermissionsMu.RLock()
+	defer rolePermissionsMu.RUnlock()
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesWithPermission lists the roles granted perm, sorted, for filtering users in SQL
+func RolesWithPermission(perm Permission) []string {
+	rolePermissionsMu.RLock()
+	candidates := make([]string, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		candidates = append(candidates, role)
+	}
+	rolePermissionsMu.RUnlock()
+
+	roles := []string{}
+	for _, role := range candidates {
+		if HasPermission(role, perm) {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// RequirePermission only lets through callers whose role has perm. Use it after
+// AuthMiddleware.
+func RequirePermission(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := GetUserRoleFromContext(r.Context())
+			if !HasPermission(role, perm) {
+				http.Error(w, "Permission required: "+string(perm), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -53,6 +53,14 @@ func (rl *rateLimiter) cleanup() {
 }
 
 func (rl *rateLimiter) allow(ip string) bool {
+	// With several instances the count has to be shared; fall back to this instance's
+	// count if the store is unavailable rather than rejecting every request
+	if sharedCounters != nil {
+		if count, _, err := sharedCounters.Incr("ratelimit:"+ip, rl.window); err == nil {
+			return count <= rl.limit
+		}
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -0,0 +1,18 @@
+package middleware
+
+import "time"
+
+// SharedCounterStore holds fixed-window counters shared by every instance. Without one the
+// rate limiter and login guard count in memory, which only holds with a single instance.
+type SharedCounterStore interface {
+	Incr(key string, window time.Duration) (int, time.Time, error)
+	Get(key string) (int, time.Time, error)
+	Reset(key string) error
+}
+
+var sharedCounters SharedCounterStore
+
+// SetSharedCounterStore switches the rate limiter and login guard to shared counters
+func SetSharedCounterStore(store SharedCounterStore) {
+	sharedCounters = store
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API versions. Routes are registered once under /api/; a version prefix (/api/v2/...) is
+// stripped before routing and the version travels in the request context, so a route only
+// needs its own handler per version where the versions actually differ.
+const (
+	APIVersion1 = 1
+	APIVersion2 = 2
+	// LatestAPIVersion is the newest version clients can ask for
+	LatestAPIVersion = APIVersion2
+	// DefaultAPIVersion applies to unversioned /api/ paths without a version header, which is
+	// what apps released before versioning call
+	DefaultAPIVersion = APIVersion1
+)
+
+const APIVersionKey contextKey = "apiVersion"
+
+// APIVersionHeader lets clients pick a version without changing paths; an Accept media type
+// of application/vnd.minesafe.v2+json works too
+const APIVersionHeader = "X-API-Version"
+
+const versionMediaType = "application/vnd.minesafe.v"
+
+var (
+	sunsetMu sync.RWMutex
+	// sunsets holds the date each deprecated version stops being served
+	sunsets = map[int]time.Time{}
+)
+
+// SetAPISunset deprecates a version: its responses carry Deprecation and Sunset headers
+// announcing when it goes away
+func SetAPISunset(version int, at time.Time) {
+	sunsetMu.Lock()
+	defer sunsetMu.Unlock()
+	sunsets[version] = at
+}
+
+// APISunset returns when a version stops being served, if it is deprecated
+func APISunset(version int) (time.Time, bool) {
+	sunsetMu.RLock()
+	defer sunsetMu.RUnlock()
+	at, ok := sunsets[version]
+	return at, ok
+}
+
+// SplitAPIVersion strips a /api/vN prefix from path, returning the unversioned path and N.
+// Paths without one are returned unchanged with version 0.
+func SplitAPIVersion(path string) (string, int) {
+	if !strings.HasPrefix(path, "/api/v") {
+		return path, 0
+	}
+	digits, tail, _ := strings.Cut(strings.TrimPrefix(path, "/api/v"), "/")
+	version, err := strconv.Atoi(digits)
+	if err != nil || version < 1 {
+		return path, 0
+	}
+	return "/api/" + tail, version
+}
+
+// negotiatedVersion reads the version an unversioned request asks for, 0 when it names none
+func negotiatedVersion(r *http.Request) int {
+	if v := r.Header.Get(APIVersionHeader); v != "" {
+		version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(v), "v"))
+		if err != nil {
+			return -1
+		}
+		return version
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.HasPrefix(mediaType, versionMediaType) {
+			digits, _, _ := strings.Cut(strings.TrimPrefix(mediaType, versionMediaType), "+")
+			if version, err := strconv.Atoi(digits); err == nil {
+				return version
+			}
+		}
+	}
+	return 0
+}
+
+// APIVersioning resolves the API version of every /api/ request, from the path prefix or
+// else the version header or Accept type, and routes it to the unversioned path. Responses
+// say which version served them; deprecated versions also get Deprecation, Sunset and a
+// Link to the same route in the latest version. It wraps the router, since the path has to
+// be rewritten before routing.
+func APIVersioning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path, version := SplitAPIVersion(r.URL.Path)
+		if version == 0 {
+			version = negotiatedVersion(r)
+		}
+		if version == 0 {
+			version = DefaultAPIVersion
+		}
+		if version < APIVersion1 || version > LatestAPIVersion {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":              "Unsupported API version",
+				"supported_versions": supportedVersions(),
+			})
+			return
+		}
+
+		w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+		if at, ok := APISunset(version); ok {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", at.UTC().Format(http.TimeFormat))
+			w.Header().Add("Link", `</api/v`+strconv.Itoa(LatestAPIVersion)+strings.TrimPrefix(path, "/api")+`>; rel="successor-version"`)
+		}
+
+		if path != r.URL.Path {
+			u := *r.URL
+			u.Path, u.RawPath = path, ""
+			r = r.Clone(r.Context())
+			r.URL = &u
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), APIVersionKey, version)))
+	})
+}
+
+func supportedVersions() []int {
+	versions := []int{}
+	for v := APIVersion1; v <= LatestAPIVersion; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// GetAPIVersion returns the API version the request was made with
+func GetAPIVersion(ctx context.Context) int {
+	if version, ok := ctx.Value(APIVersionKey).(int); ok {
+		return version
+	}
+	return DefaultAPIVersion
+}
+
+// ByVersion wires a route to a handler per API version. A request is served by the handler
+// of the newest version not newer than its own, so a route only lists the versions where
+// its behaviour changed.
+func ByVersion(handlers map[int]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for v := GetAPIVersion(r.Context()); v >= APIVersion1; v-- {
+			if h, ok := handlers[v]; ok {
+				h(w, r)
+				return
+			}
+		}
+		http.Error(w, "Not available in this API version", http.StatusNotFound)
+	}
+}
@@ -78,3 +78,41 @@ type CalendarStreakResponse struct {
 	TotalDays     int      `json:"total_days"`
 	AttemptDates  []string `json:"attempt_dates"` // Array of dates in YYYY-MM-DD format
 }
+
+// EquipmentChecklistItem is a pre-use check an operator runs on a piece of equipment
+type EquipmentChecklistItem struct {
+	ID            int       `json:"id" db:"id"`
+	SupervisorID  string    `json:"supervisor_id" db:"supervisor_id"`
+	EquipmentType string    `json:"equipment_type" db:"equipment_type"` // empty applies to every machine
+	Title         string    `json:"title" db:"title"`
+	Description   string    `json:"description" db:"description"`
+	IsActive      bool      `json:"is_active" db:"is_active"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// EquipmentChecklistItemCreate is used for creating and editing equipment checklist items
+type EquipmentChecklistItemCreate struct {
+	EquipmentType string `json:"equipment_type"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+}
+
+// EquipmentCompletionUpdate records one check on one machine, identified by its unit or asset number
+type EquipmentCompletionUpdate struct {
+	ItemID      int    `json:"item_id"`
+	EquipmentID string `json:"equipment_id"`
+	IsCompleted bool   `json:"is_completed"`
+	Notes       string `json:"notes,omitempty"`
+}
+
+// EquipmentItemWithStatus combines an equipment check with today's result for one machine
+type EquipmentItemWithStatus struct {
+	ID            int        `json:"id"`
+	EquipmentType string     `json:"equipment_type"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	IsCompleted   bool       `json:"is_completed"`
+	Notes         string     `json:"notes,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
@@ -13,6 +13,7 @@ const (
 	StatusPendingUpload MediaStatus = "PENDING_UPLOAD"
 	StatusNotApplicable MediaStatus = "NOT_APPLICABLE"
 	StatusUploadFailed  MediaStatus = "UPLOAD_FAILED"
+	StatusUploadExpired MediaStatus = "UPLOAD_EXPIRED"
 )
 
 const (
@@ -37,6 +38,13 @@ type Emergency struct {
 	IncidentReportingTime time.Time        `json:"reporting_time" db:"reporting_time"`
 	Status                ResolutionStatus `json:"status" db:"status"`
 	ResolutionTime        *time.Time       `json:"resolution_time,omitempty" db:"resolution_time"`
+	// Set when this report was clustered under another report of the same incident
+	PrimaryEmergencyID *int `json:"primary_emergency_id,omitempty" db:"primary_emergency_id"`
+	// Quick-report category and its structured fields
+	Category string                 `json:"category,omitempty" db:"category"`
+	Details  map[string]interface{} `json:"details,omitempty" db:"details"`
+	// Zone whose geofence the coordinates fall in
+	ZoneID *int `json:"zone_id,omitempty" db:"zone_id"`
 }
 
 type EmergencyCreate struct {
@@ -47,6 +55,9 @@ type EmergencyCreate struct {
 	Longitude   float64     `json:"longitude"`
 	Issue       string      `json:"issue"`
 	MediaStatus MediaStatus `json:"media_status,omitempty"`
+	// Optional quick-report category; Details must match the category's fields
+	Category string                 `json:"category,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
 }
 
 func NewEmergency(userID string, emergencyID int, severity string, lat, lon float64, issue string, mediaStatus MediaStatus, mediaURL *string, incidentTime *time.Time) (*Emergency, error) {
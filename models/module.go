@@ -16,6 +16,10 @@ type VideoModule struct {
 	CreatedBy   *string   `json:"created_by,omitempty" db:"created_by"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	// Set while a module is staged; it goes live at this time
+	PublishAt *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	// Percentage needed to pass the quiz; unset uses the retake policy's passing_percent
+	PassingScore *float64 `json:"passing_score,omitempty" db:"passing_score"`
 }
 
 type StarVideo struct {
@@ -32,6 +36,8 @@ type Question struct {
 	Question string   `json:"question" db:"question"`
 	Options  []string `json:"options" db:"options"` // Stored as JSON array
 	Answer   int      `json:"answer" db:"answer"`   // Index of correct answer
+	// Shown to miners after they submit, never with the questions
+	Explanation string `json:"explanation,omitempty" db:"explanation"`
 }
 
 type ModuleCompletion struct {
@@ -49,6 +55,8 @@ type LearningStreak struct {
 	CurrentStreak int       `json:"current_streak"`
 	LastCompleted time.Time `json:"last_completed"`
 	TotalModules  int       `json:"total_modules"`
+	// Days on leave in the same 30-day window, which the streak is not expected to cover
+	LeaveDays int `json:"leave_days"`
 }
 
 type VideoModuleCreate struct {
@@ -59,13 +67,33 @@ type VideoModuleCreate struct {
 	Category    string `json:"category"`
 	Thumbnail   string `json:"thumbnail"`
 	VideoType   string `json:"video_type"` // "youtube", "upload", or "url"
+	// "draft" or "scheduled" stage the module instead of publishing it immediately
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"` // required for "scheduled"
+	// Optional: when the module is taken down, and when its author should re-check it
+	ExpiresAt   *time.Time `json:"expires_at"`
+	ReviewDueAt *time.Time `json:"review_due_at"`
+	// Optional pass mark for the quiz, 1-100
+	PassingScore *float64 `json:"passing_score"`
+}
+
+// QuestionReview is the per-question result returned after a module quiz is submitted
+type QuestionReview struct {
+	QuestionID     int      `json:"question_id"`
+	Question       string   `json:"question"`
+	Options        []string `json:"options"`
+	SelectedAnswer int      `json:"selected_answer"`
+	CorrectAnswer  int      `json:"correct_answer"`
+	IsCorrect      bool     `json:"is_correct"`
+	Explanation    string   `json:"explanation,omitempty"`
 }
 
 type QuestionCreate struct {
-	VideoID  int      `json:"video_id"`
-	Question string   `json:"question"`
-	Options  []string `json:"options"`
-	Answer   int      `json:"answer"`
+	VideoID     int      `json:"video_id"`
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+	Answer      int      `json:"answer"`
+	Explanation string   `json:"explanation"`
 }
 
 type ModuleAnswer struct {
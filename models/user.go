@@ -2,6 +2,8 @@ package models
 
 import (
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,8 +15,56 @@ const (
 	RoleSupervisor Role = "SUPERVISOR"
 	RoleMiner      Role = "MINER"
 	RoleAdmin      Role = "ADMIN"
+	RoleVisitor    Role = "VISITOR"
+	RoleOperator   Role = "OPERATOR"
+	// RoleAuditor is an external safety auditor reading records through a scoped audit token
+	RoleAuditor Role = "AUDITOR"
 )
 
+// Roles lists every role a user can hold
+var Roles = []Role{RoleSupervisor, RoleMiner, RoleAdmin, RoleVisitor, RoleOperator, RoleAuditor}
+
+// DefaultPhoneCountryCode is assumed for numbers entered without one. The demo data and
+// the sites the app ships to are in India.
+const DefaultPhoneCountryCode = "91"
+
+var (
+	ErrInvalidPhone = errors.New("invalid phone number: use international format, e.g. +919876543210")
+
+	phoneSeparators = regexp.MustCompile(`[\s().-]`)
+	e164Pattern     = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+)
+
+// NormalizeEmail returns the form emails are stored and compared in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone converts a phone number to E.164. Separators are dropped, a 00 prefix
+// becomes +, and a national number gets DefaultPhoneCountryCode. An empty number stays
+// empty; anything else that is not E.164 afterwards returns ErrInvalidPhone.
+func NormalizePhone(phone string) (string, error) {
+	p := phoneSeparators.ReplaceAllString(strings.TrimSpace(phone), "")
+	if p == "" {
+		return "", nil
+	}
+	switch {
+	case strings.HasPrefix(p, "+"):
+	case strings.HasPrefix(p, "00"):
+		p = "+" + p[2:]
+	case len(p) == 10:
+		p = "+" + DefaultPhoneCountryCode + p
+	case len(p) == 11 && p[0] == '0':
+		p = "+" + DefaultPhoneCountryCode + p[1:]
+	case len(p) == 12 && strings.HasPrefix(p, DefaultPhoneCountryCode):
+		p = "+" + p
+	}
+	if !e164Pattern.MatchString(p) {
+		return "", ErrInvalidPhone
+	}
+	return p, nil
+}
+
 type User struct {
 	ID           int       `json:"id" db:"id"`
 	UserID       string    `json:"user_id" db:"user_id"`
@@ -53,9 +103,14 @@ type MinerCreate struct {
 }
 
 func NewUser(name, email, phone, password, miningSite, location string, role Role, supervisorID *string) (*User, error) {
+	email = NormalizeEmail(email)
 	if name == "" || email == "" || password == "" {
 		return nil, errors.New("invalid user details: name, email, and password are required")
 	}
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
 
 	var userID string
 	switch role {
@@ -65,6 +120,12 @@ func NewUser(name, email, phone, password, miningSite, location string, role Rol
 		userID = "MIN-" + uuid.New().String()
 	case RoleAdmin:
 		userID = "ADM-" + uuid.New().String()
+	case RoleVisitor:
+		userID = "VIS-" + uuid.New().String()
+	case RoleOperator:
+		userID = "OPR-" + uuid.New().String()
+	case RoleAuditor:
+		userID = "AUD-" + uuid.New().String()
 	default:
 		return nil, errors.New("invalid role")
 	}
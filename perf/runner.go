@@ -0,0 +1,170 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Steps of the morning rush, in the order a miner's app makes them
+const (
+	StepLogin             = "miner_login"
+	StepHome              = "app_home"
+	StepPreStartChecklist = "pre_start_checklist"
+	StepPreStartComplete  = "pre_start_complete"
+	StepPPEChecklist      = "ppe_checklist"
+	StepPPEComplete       = "ppe_complete"
+	StepVideoFeed         = "video_feed"
+)
+
+// Credentials log one virtual user in
+type Credentials struct {
+	Email    string
+	Password string
+}
+
+// recorder collects latencies per step from every virtual user
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func (rec *recorder) record(step string, took time.Duration, err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err != nil {
+		rec.errors[step]++
+		return
+	}
+	rec.latencies[step] = append(rec.latencies[step], took)
+}
+
+func (rec *recorder) results() []StepResult {
+	steps := map[string]bool{}
+	for step := range rec.latencies {
+		steps[step] = true
+	}
+	for step := range rec.errors {
+		steps[step] = true
+	}
+	results := []StepResult{}
+	for step := range steps {
+		results = append(results, summarise(step, rec.latencies[step], rec.errors[step]))
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Step < results[j].Step })
+	return results
+}
+
+// Run replays the scenario against baseURL, one virtual user per credential, spreading
+// their arrival over the ramp-up
+func Run(scenario Scenario, baseURL string, users []Credentials) []StepResult {
+	rec := &recorder{latencies: map[string][]time.Duration{}, errors: map[string]int{}}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: scenario.VirtualUsers},
+	}
+
+	var gap time.Duration
+	if len(users) > 1 {
+		gap = scenario.RampUp / time.Duration(len(users))
+	}
+
+	var wg sync.WaitGroup
+	for i, creds := range users {
+		wg.Add(1)
+		go func(delay time.Duration, creds Credentials) {
+			defer wg.Done()
+			time.Sleep(delay)
+			vu := &virtualUser{client: client, baseURL: baseURL, rec: rec}
+			vu.startShift(creds)
+		}(time.Duration(i)*gap, creds)
+	}
+	wg.Wait()
+	return rec.results()
+}
+
+type virtualUser struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	rec     *recorder
+}
+
+// startShift is what a miner's app does at the gate: log in, load home, tick every
+// pre-start and PPE item, then open the training feed
+func (vu *virtualUser) startShift(creds Credentials) {
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := vu.call(StepLogin, http.MethodPost, "/api/app/miner/login", map[string]string{
+		"email": creds.Email, "password": creds.Password, "role": "MINER",
+	}, &login); err != nil {
+		return
+	}
+	vu.token = login.Token
+
+	vu.call(StepHome, http.MethodGet, "/api/app/home", nil, nil)
+
+	var items []struct {
+		ID int `json:"id"`
+	}
+	if vu.call(StepPreStartChecklist, http.MethodGet, "/api/app/checklists/pre-start", nil, &items) == nil {
+		for _, item := range items {
+			vu.call(StepPreStartComplete, http.MethodPut, "/api/app/checklists/pre-start/complete",
+				map[string]interface{}{"item_id": item.ID, "is_completed": true}, nil)
+		}
+	}
+
+	items = nil
+	if vu.call(StepPPEChecklist, http.MethodGet, "/api/app/checklists/ppe", nil, &items) == nil {
+		for _, item := range items {
+			vu.call(StepPPEComplete, http.MethodPut, "/api/app/checklists/ppe/complete",
+				map[string]interface{}{"item_id": item.ID, "is_completed": true}, nil)
+		}
+	}
+
+	vu.call(StepVideoFeed, http.MethodGet, "/api/videos/feed", nil, nil)
+}
+
+// call makes one request, records its latency under step and decodes the response into out
+func (vu *virtualUser) call(step, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, _ := json.Marshal(body)
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, vu.baseURL+path, reader)
+	if err != nil {
+		vu.rec.record(step, 0, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if vu.token != "" {
+		req.Header.Set("Authorization", "Bearer "+vu.token)
+	}
+
+	start := time.Now()
+	resp, err := vu.client.Do(req)
+	if err != nil {
+		vu.rec.record(step, 0, err)
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	took := time.Since(start)
+
+	if err == nil && resp.StatusCode >= 300 {
+		err = fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, data)
+	}
+	if err == nil && out != nil {
+		err = json.Unmarshal(data, out)
+	}
+	vu.rec.record(step, took, err)
+	return err
+}
@@ -0,0 +1,104 @@
+// Package perf holds the API's latency budgets as code and a load generator that replays
+// the morning rush against them. Run it with:
+//
+//	go test -tags perf -run MorningRush -timeout 30m .
+//
+// The test lives in package main so it can serve the real router.
+package perf
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SLO is the budget for one step of a scenario
+type SLO struct {
+	P95          time.Duration
+	MaxErrorRate float64 // share of failed requests allowed, 0-1
+}
+
+// SLOs are the budgets per scenario step. Login is dominated by password hashing and gets more room;
+// the checklist calls are what a miner waits on at the gate and must stay fast.
+var SLOs = map[string]SLO{
+	StepLogin:             {P95: 1500 * time.Millisecond, MaxErrorRate: 0.001},
+	StepHome:              {P95: 500 * time.Millisecond, MaxErrorRate: 0.001},
+	StepPreStartChecklist: {P95: 300 * time.Millisecond, MaxErrorRate: 0.001},
+	StepPreStartComplete:  {P95: 300 * time.Millisecond, MaxErrorRate: 0.001},
+	StepPPEChecklist:      {P95: 300 * time.Millisecond, MaxErrorRate: 0.001},
+	StepPPEComplete:       {P95: 300 * time.Millisecond, MaxErrorRate: 0.001},
+	StepVideoFeed:         {P95: 800 * time.Millisecond, MaxErrorRate: 0.01},
+}
+
+// Scenario is a load profile
+type Scenario struct {
+	Name         string
+	VirtualUsers int           // concurrent miners
+	RampUp       time.Duration // time over which the miners arrive
+}
+
+// MorningRush is shift start at a large site: 500 miners log in within a minute and work
+// through their pre-start and PPE checklists before going underground
+var MorningRush = Scenario{
+	Name:         "morning_rush",
+	VirtualUsers: 500,
+	RampUp:       time.Minute,
+}
+
+// StepResult summarises the requests of one step
+type StepResult struct {
+	Step      string
+	Requests  int
+	Errors    int
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+	ErrorRate float64
+}
+
+func summarise(step string, latencies []time.Duration, errors int) StepResult {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	result := StepResult{Step: step, Requests: len(sorted) + errors, Errors: errors}
+	if result.Requests > 0 {
+		result.ErrorRate = float64(errors) / float64(result.Requests)
+	}
+	result.P50 = percentile(sorted, 0.50)
+	result.P95 = percentile(sorted, 0.95)
+	result.P99 = percentile(sorted, 0.99)
+	return result
+}
+
+// percentile uses the nearest-rank method on sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(float64(len(sorted))*p+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Violations lists every step that exceeded its budget
+func Violations(results []StepResult) []string {
+	var violations []string
+	for _, r := range results {
+		slo, ok := SLOs[r.Step]
+		if !ok {
+			continue
+		}
+		if r.P95 > slo.P95 {
+			violations = append(violations, fmt.Sprintf("%s: p95 %v exceeds budget %v", r.Step, r.P95, slo.P95))
+		}
+		if r.ErrorRate > slo.MaxErrorRate {
+			violations = append(violations, fmt.Sprintf("%s: error rate %.2f%% exceeds budget %.2f%%",
+				r.Step, r.ErrorRate*100, slo.MaxErrorRate*100))
+		}
+	}
+	return violations
+}